@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"todo-app/internal/manager"
+	"todo-app/internal/storage"
+)
+
+// backup выполняет export и import: args - команда, имя пользователя и
+// необязательный файл. Данные читаются и пишутся через TaskManager от
+// имени этого пользователя, с теми же проверками, что и GET /api/v1/export
+// и POST /api/v1/import.
+func backup(dbPath string, args []string, mode manager.ImportMode, dryRun bool) error {
+	s, err := storage.NewSQLiteStorage(dbPath)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	user, err := s.GetUserByLogin(args[1])
+	if err != nil {
+		return err
+	}
+	tasks := manager.NewTaskManagerWithStorage(s).WithSource(manager.SourceImport)
+	tasks.SetSubTaskManager(manager.NewSubTaskManagerWithStorage(s))
+	// Импортированные задачи получают автонапоминания о сроке
+	manager.NewReminderManagerWithStorage(s, tasks)
+
+	if args[0] == "export" {
+		return exportUser(tasks, user, args[2:])
+	}
+	return importUser(tasks, user, args[2:], mode, dryRun)
+}
+
+func exportUser(tasks *manager.TaskManager, user *manager.User, file []string) error {
+	data, err := tasks.ExportForUser(user.ID)
+	if err != nil {
+		return err
+	}
+	out := os.Stdout
+	if len(file) > 0 {
+		if out, err = os.Create(file[0]); err != nil {
+			return err
+		}
+		defer out.Close()
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(data); err != nil {
+		return err
+	}
+	log.Printf("🎉 Выгружено: проектов %d, задач %d, подзадач %d, напоминаний %d",
+		len(data.Projects), len(data.Tasks), len(data.SubTasks), len(data.Reminders))
+	return nil
+}
+
+func importUser(tasks *manager.TaskManager, user *manager.User, file []string, mode manager.ImportMode, dryRun bool) error {
+	var in io.Reader = os.Stdin
+	if len(file) > 0 {
+		f, err := os.Open(file[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	var data manager.Export
+	if err := json.NewDecoder(in).Decode(&data); err != nil {
+		return fmt.Errorf("некорректный файл выгрузки: %v", err)
+	}
+	report, err := tasks.ImportForUser(user.ID, &data, mode, dryRun)
+	if err != nil {
+		return err
+	}
+
+	if report.DryRun {
+		fmt.Println("Пробный запуск, ничего не сохранено.")
+	}
+	fmt.Printf("Режим: %s\n", report.Mode)
+	if report.Mode == manager.ImportReplace {
+		fmt.Printf("  в корзину: %d задач, во Входящие участников: %d, удалено проектов: %d\n",
+			report.TasksTrashed, report.TasksMoved, report.ProjectsDeleted)
+	}
+	fmt.Printf("  проектов: %d новых, %d совпали с существующими\n", report.ProjectsCreated, report.ProjectsMerged)
+	fmt.Printf("  задач: %d, подзадач: %d, тегов: %d\n", report.TasksCreated, report.SubTasksCreated, report.Tags)
+	fmt.Printf("  напоминаний: %d, пропущено: %d\n", report.RemindersCreated, report.RemindersSkipped)
+	return nil
+}
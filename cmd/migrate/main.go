@@ -1,91 +1,166 @@
 package main
 
 import (
-	"database/sql"
-	//"fmt"
+	"flag"
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
 
-	_ "modernc.org/sqlite"
+	"todo-app/internal/manager"
+	"todo-app/internal/storage"
 )
 
+const usage = `Использование: migrate [-db путь] [-mode merge|replace] [-dry-run] <команда>
+
+Команды:
+  up        применить все новые миграции
+  down      откатить последнюю миграцию
+  status    показать список миграций
+  to N      привести схему к версии N (0 - откатить все)
+  claim-legacy ПОЛЬЗОВАТЕЛЬ
+            передать учетной записи задачи, созданные до появления учетных записей
+  export ПОЛЬЗОВАТЕЛЬ [ФАЙЛ]
+            выгрузить данные пользователя в JSON (без файла - в stdout)
+  import ПОЛЬЗОВАТЕЛЬ [ФАЙЛ]
+            загрузить выгрузку (без файла - из stdin); -mode и -dry-run как
+            у POST /api/v1/import
+
+export и import переносят пользователя между установками или из памяти
+в SQLite. Это команды оператора с доступом к файлу базы: они действуют
+от имени указанного пользователя.
+`
+
 func main() {
-	log.Println("🔄 Создание новой базы данных...")
+	dbPath := flag.String("db", "./data/todoapp.db", "путь к файлу базы SQLite")
+	mode := flag.String("mode", string(manager.ImportMerge), "режим import: merge - добавить к существующим данным, replace - заменить их")
+	dryRun := flag.Bool("dry-run", false, "import только показывает, что будет сделано")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if !manager.ImportMode(*mode).Valid() {
+		fmt.Fprintf(flag.CommandLine.Output(), "-mode: ожидается merge или replace, получено %q\n", *mode)
+		os.Exit(2)
+	}
 
 	// Убедимся что папка существует
-	os.MkdirAll("data", 0755)
+	if err := os.MkdirAll(filepath.Dir(*dbPath), 0755); err != nil {
+		log.Fatal("❌ Ошибка создания директории:", err)
+	}
 
-	// Простое создание БД
-	db, err := sql.Open("sqlite", "./data/todoapp.db")
+	db, err := storage.OpenDB(*dbPath)
 	if err != nil {
-		log.Fatal("❌ Ошибка открытия БД:", err)
+		log.Fatal("❌ ", err)
 	}
 	defer db.Close()
 
-	// Проверяем соединение
-	if err := db.Ping(); err != nil {
-		log.Fatal("❌ Ошибка подключения:", err)
-	}
-
-	log.Println("✅ База данных создана!")
-
-	// Создаем таблицы
-	tables := []string{
-		`CREATE TABLE IF NOT EXISTS users (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			device_id TEXT UNIQUE NOT NULL,
-			telegram_id INTEGER UNIQUE,
-			fcm_token TEXT,
-			created_at DATETIME NOT NULL,
-			updated_at DATETIME NOT NULL
-		)`,
-
-		`CREATE TABLE IF NOT EXISTS tasks (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER REFERENCES users(id),
-			description TEXT NOT NULL,
-			created_at DATETIME NOT NULL,
-			updated_at DATETIME NOT NULL,
-			completed BOOLEAN NOT NULL DEFAULT FALSE,
-			priority TEXT NOT NULL DEFAULT 'medium',
-			due_date DATETIME,
-			tags TEXT
-		)`,
-
-		`CREATE TABLE IF NOT EXISTS subtasks (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			user_id INTEGER REFERENCES users(id),
-			task_id INTEGER NOT NULL,
-			description TEXT NOT NULL,
-			created_at DATETIME NOT NULL,
-			updated_at DATETIME NOT NULL,
-			completed BOOLEAN NOT NULL DEFAULT FALSE,
-			FOREIGN KEY (task_id) REFERENCES tasks (id) ON DELETE CASCADE
-		)`,
+	migrator, err := storage.NewMigrator(db)
+	if err != nil {
+		log.Fatal("❌ ", err)
 	}
 
-	for i, table := range tables {
-		_, err = db.Exec(table)
+	switch args[0] {
+	case "up":
+		count, err := migrator.Up()
+		if err != nil {
+			log.Fatal("❌ ", err)
+		}
+		log.Printf("🎉 Применено миграций: %d", count)
+	case "down":
+		if err := migrator.Down(); err != nil {
+			log.Fatal("❌ ", err)
+		}
+	case "to":
+		if len(args) < 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		version, err := strconv.Atoi(args[1])
 		if err != nil {
-			log.Fatal("❌ Ошибка создания таблицы:", err)
+			log.Fatal("❌ Версия должна быть числом: ", args[1])
+		}
+		if err := migrator.To(version); err != nil {
+			log.Fatal("❌ ", err)
+		}
+	case "claim-legacy":
+		if len(args) < 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		if err := claimLegacy(*dbPath, args[1]); err != nil {
+			log.Fatal("❌ ", err)
+		}
+		return
+	case "export", "import":
+		if len(args) < 2 || len(args) > 3 {
+			flag.Usage()
+			os.Exit(2)
 		}
-		log.Printf("✅ Таблица %d создана", i+1)
+		if err := backup(*dbPath, args, manager.ImportMode(*mode), *dryRun); err != nil {
+			log.Fatal("❌ ", err)
+		}
+		return
+	case "status":
+		// обрабатывается ниже
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := printStatus(migrator, *dbPath); err != nil {
+		log.Fatal("❌ ", err)
+	}
+}
+
+// claimLegacy передает задачи пользователя LegacyDeviceID учетной записи
+// username. Регистрация их не забирает, чтобы первый зарегистрированный
+// не получил чужие задачи.
+func claimLegacy(dbPath, username string) error {
+	s, err := storage.NewSQLiteStorage(dbPath)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	user, err := s.GetUserByLogin(username)
+	if err != nil {
+		return err
+	}
+	claimed, err := s.ClaimLegacyTasks(user.ID)
+	if err != nil {
+		return err
 	}
+	log.Printf("🎉 Пользователю %s передано задач: %d", user.Username, claimed)
+	return nil
+}
 
-	// Создаем default пользователя
-	_, err = db.Exec(`INSERT INTO users (device_id, created_at, updated_at) 
-		VALUES ('default_legacy_user', datetime('now'), datetime('now'))`)
+func printStatus(migrator *storage.Migrator, dbPath string) error {
+	statuses, err := migrator.Status()
 	if err != nil {
-		log.Println("⚠️ Пользователь уже существует или ошибка:", err)
-	} else {
-		log.Println("✅ Default пользователь создан")
+		return err
+	}
+	version, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("📁 База данных: %s\n", dbPath)
+	fmt.Printf("Версия схемы: %d (последняя: %d)\n\n", version, migrator.Latest())
+	for _, s := range statuses {
+		if s.Applied {
+			fmt.Printf("  ✅ %03d_%s  %s\n", s.Version, s.Name, s.AppliedAt.Format("2006-01-02 15:04:05"))
+		} else {
+			fmt.Printf("  ⏳ %03d_%s  не применена\n", s.Version, s.Name)
+		}
 	}
-_, err = db.Exec(`UPDATE users SET telegram_id = MY_ID NUMBER WHERE device_id = 'default_legacy_user'`)
-if err != nil {
-    log.Println("⚠️ Ошибка привязки Telegram ID:", err)
-} else {
-    log.Println("✅ Ваш Telegram ID привязан к default пользователю")
+	return nil
 }
-	log.Println("🎉 Миграция завершена успешно!")
-	log.Println("📁 База данных: data/todoapp.db")
-}
\ No newline at end of file
@@ -2,16 +2,20 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
-	//"time"
+	"sync"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 	"todo-app/internal/logger"
 	"todo-app/internal/manager"
+	"todo-app/internal/scheduler"
 	"todo-app/internal/storage"
 )
 
@@ -20,9 +24,28 @@ type Bot struct {
 	taskManager *manager.TaskManager
 	storage     manager.Storage
 	userManager *manager.UserManager
+	filters     *manager.SavedFilterManager
+	comments    *manager.CommentManager
+	attachments *manager.AttachmentManager
+
+	// lastActions - последнее удаление или отметка выполнения пользователя
+	// для /undo. Сообщения обрабатываются параллельно, поэтому под мьютексом.
+	undoMu      sync.Mutex
+	lastActions map[int]undoAction
 }
 
-func NewBot(token string, tm *manager.TaskManager, storage manager.Storage, um *manager.UserManager) (*Bot, error) {
+// undoAction - действие, которое можно отменить командой /undo
+type undoAction struct {
+	taskID    int
+	deleted   bool // true - задача удалена, false - изменена отметка выполнения
+	spawnedID int  // следующее повторение, созданное отметкой; отмена убирает его
+}
+
+// fileDownloadTimeout ограничивает загрузку файла с серверов Telegram
+const fileDownloadTimeout = time.Minute
+
+func NewBot(token string, tm *manager.TaskManager, storage manager.Storage, um *manager.UserManager, fm *manager.SavedFilterManager,
+	cm *manager.CommentManager, am *manager.AttachmentManager) (*Bot, error) {
 	bot, err := tgbotapi.NewBotAPI(token)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка создания бота: %v", err)
@@ -36,6 +59,10 @@ func NewBot(token string, tm *manager.TaskManager, storage manager.Storage, um *
 		taskManager: tm,
 		storage:     storage,
 		userManager: um,
+		filters:     fm,
+		comments:    cm,
+		attachments: am,
+		lastActions: make(map[int]undoAction),
 	}, nil
 }
 
@@ -71,6 +98,12 @@ func (b *Bot) handleMessage(msg *tgbotapi.Message) {
 		b.handleCommand(msg)
 		return
 	}
+	if b.taskFromFile(msg) {
+		return
+	}
+	if b.commentFromReply(msg) {
+		return
+	}
 
 	b.handleTextMessage(msg)
 }
@@ -83,10 +116,24 @@ func (b *Bot) handleCommand(msg *tgbotapi.Message) {
 		b.addTask(msg)
 	case "list":
 		b.handleListCommand(msg)
+	case "find":
+		b.findTasks(msg)
 	case "done":
 		b.completeTask(msg)
 	case "delete":
 		b.deleteTask(msg)
+	case "undo":
+		b.undoLastAction(msg)
+	case "assign":
+		b.assignTask(msg)
+	case "mine":
+		b.listAssigned(msg)
+	case "link":
+		b.linkAccount(msg)
+	case "unlink":
+		b.unlinkAccount(msg)
+	case "join":
+		b.joinProject(msg)
 	case "help":
 		b.sendHelp(msg.Chat.ID)
 	default:
@@ -100,40 +147,161 @@ func (b *Bot) handleTextMessage(msg *tgbotapi.Message) {
 	}
 }
 
+// commentFromReply превращает ответ на уведомление бота о задаче в
+// комментарий к ней. false - сообщение не такой ответ.
+func (b *Bot) commentFromReply(msg *tgbotapi.Message) bool {
+	reply := msg.ReplyToMessage
+	if reply == nil || reply.From == nil || reply.From.ID != b.api.Self.ID {
+		return false
+	}
+	taskID, ok := scheduler.NotificationTaskID(reply.Text)
+	if !ok || strings.TrimSpace(msg.Text) == "" {
+		return false
+	}
+
+	user, ok := b.currentUser(msg.Chat.ID, msg.From.ID)
+	if !ok {
+		return true
+	}
+	if _, err := b.comments.AddComment(*user, taskID, msg.Text); err != nil {
+		if errors.Is(err, manager.ErrInvalid) {
+			b.sendMessage(msg.Chat.ID, "❌ "+escapeMarkdown(err.Error()))
+			return true
+		}
+		b.sendMessage(msg.Chat.ID, taskErrorText(taskID, err))
+		return true
+	}
+	b.sendMessage(msg.Chat.ID, fmt.Sprintf("💬 Комментарий к задаче #%d добавлен", taskID))
+	return true
+}
+
+// taskFromFile создает задачу из подписи к фото или документу и прикрепляет
+// к ней файл. false - в сообщении нет файла.
+func (b *Bot) taskFromFile(msg *tgbotapi.Message) bool {
+	var fileID, filename string
+	var size int
+	switch {
+	case msg.Photo != nil && len(*msg.Photo) > 0:
+		// Telegram присылает несколько размеров, последний - самый крупный
+		photos := *msg.Photo
+		photo := photos[len(photos)-1]
+		fileID, filename, size = photo.FileID, "photo.jpg", photo.FileSize
+	case msg.Document != nil:
+		fileID, filename, size = msg.Document.FileID, msg.Document.FileName, msg.Document.FileSize
+	default:
+		return false
+	}
+
+	if strings.TrimSpace(msg.Caption) == "" {
+		b.sendMessage(msg.Chat.ID, "📎 Добавьте к файлу подпись - она станет текстом задачи")
+		return true
+	}
+	if size > manager.MaxAttachmentSize {
+		b.sendMessage(msg.Chat.ID, fmt.Sprintf("❌ Файл больше %d МБ", manager.MaxAttachmentSize>>20))
+		return true
+	}
+
+	taskID := b.addTaskFromText(msg.Chat.ID, msg.From.ID, msg.Caption)
+	if taskID == 0 {
+		return true
+	}
+	user, ok := b.currentUser(msg.Chat.ID, msg.From.ID)
+	if !ok {
+		return true
+	}
+	if err := b.attachFile(user.ID, taskID, fileID, filename); err != nil {
+		logger.Error(context.Background(), err, "Ошибка прикрепления файла из Telegram", "taskID", taskID)
+		b.sendMessage(msg.Chat.ID, fmt.Sprintf("⚠️ Задача #%d создана, но файл не прикреплен: %s", taskID, escapeMarkdown(err.Error())))
+		return true
+	}
+	b.sendMessage(msg.Chat.ID, fmt.Sprintf("📎 Файл прикреплен к задаче #%d", taskID))
+	return true
+}
+
+// attachFile скачивает файл с серверов Telegram и прикрепляет его к задаче
+func (b *Bot) attachFile(userID, taskID int, fileID, filename string) error {
+	url, err := b.api.GetFileDirectURL(fileID)
+	if err != nil {
+		return fmt.Errorf("не удалось получить ссылку на файл: %v", err)
+	}
+	client := &http.Client{Timeout: fileDownloadTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return fmt.Errorf("не удалось скачать файл: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("не удалось скачать файл: статус %d", resp.StatusCode)
+	}
+	_, err = b.attachments.AddAttachment(userID, taskID, filename, resp.Body)
+	return err
+}
+
+// currentUser возвращает пользователя, привязанного к Telegram-аккаунту отправителя.
+// При первом обращении пользователь создается, так что у каждого аккаунта свой список задач.
+func (b *Bot) currentUser(chatID int64, telegramID int) (*manager.User, bool) {
+	user, err := b.userManager.GetOrCreateUserByTelegramID(int64(telegramID))
+	if err != nil {
+		logger.Error(context.Background(), err, "Ошибка получения пользователя", "telegramID", telegramID)
+		b.sendMessage(chatID, "❌ Ошибка создания пользователя: "+err.Error())
+		return nil, false
+	}
+	return user, true
+}
+
+// taskErrorText формирует ответ на ошибку операции с задачей
+func taskErrorText(taskID int, err error) string {
+	switch {
+	case errors.Is(err, manager.ErrNotFound):
+		return fmt.Sprintf("❌ Задача #%d не найдена", taskID)
+	case errors.Is(err, manager.ErrForbidden):
+		return fmt.Sprintf("⛔ Нет доступа к задаче #%d: %s", taskID, err.Error())
+	}
+	return "❌ Ошибка: " + err.Error()
+}
+
 func (b *Bot) handleStartCommand(msg *tgbotapi.Message) {
-    // Создаем или получаем пользователя
-    _, err := b.userManager.GetOrCreateUserByTelegramID(int64(msg.From.ID))
-    if err != nil {
-        b.sendMessage(msg.Chat.ID, "❌ Ошибка создания пользователя: " + err.Error())
-        return
-    }
+	if _, ok := b.currentUser(msg.Chat.ID, msg.From.ID); !ok {
+		return
+	}
 
 	text := `🎯 *Добро пожаловать в TodoBot!*
 
 *Доступные команды:*
 /add [задача] - Добавить задачу
 /list - Показать все задачи  
+/list [список] - Показать сохраненный список
+/find [запрос] - Найти задачи по тексту
 /done [номер] - Отметить задачу выполненной
-/delete [номер] - Удалить задачу
+/delete [номер] - Переместить задачу в корзину
+/undo - Отменить последнее удаление или отметку
+/assign [номер] @[логин] - Назначить исполнителя
+/mine - Задачи, назначенные вам
+/join [код] - Присоединиться к общему проекту
 /help - Помощь
 
 *Примеры:*
 /add Купить молоко #покупки
 /add Создать отчет до пятницы 🚀
-/done 1`
+/done 1
+
+Номера задач показывает /list`
 
 	b.sendMessage(msg.Chat.ID, text)
 }
 
 func (b *Bot) handleListCommand(msg *tgbotapi.Message) {
-    // ВСЕГДА используем default пользователя
-    defaultUser, err := b.userManager.GetUserByDeviceID("default_legacy_user")
-    if err != nil {
-        b.sendMessage(msg.Chat.ID, "❌ Ошибка: система не настроена")
+    user, ok := b.currentUser(msg.Chat.ID, msg.From.ID)
+    if !ok {
+        return
+    }
+
+    if name := strings.TrimSpace(msg.CommandArguments()); name != "" {
+        b.listSavedFilter(msg.Chat.ID, user.ID, name)
         return
     }
 
-    tasks, err := b.taskManager.GetAllTasksForUser(defaultUser.ID)
+    tasks, err := b.taskManager.GetAllTasksForUser(user.ID)
     if err != nil {
         b.sendMessage(msg.Chat.ID, "❌ Ошибка загрузки задач: "+err.Error())
         return
@@ -144,11 +312,102 @@ func (b *Bot) handleListCommand(msg *tgbotapi.Message) {
         return
     }
 
-	// Формируем список задач
+	projects, err := b.taskManager.GetProjectsForUser(user.ID)
+	if err != nil {
+		b.sendMessage(msg.Chat.ID, "❌ Ошибка загрузки проектов: "+err.Error())
+		return
+	}
+
+	// Задачи общих проектов, где пользователь участник, идут отдельными
+	// разделами после его собственных
+	shared := make(map[int][]manager.Task)
+	var own []manager.Task
+	for _, task := range tasks {
+		if project, ok := findSharedProject(projects, task.ProjectID); ok {
+			shared[project.ID] = append(shared[project.ID], task)
+			continue
+		}
+		own = append(own, task)
+	}
+
+	var sections []string
+	if len(own) > 0 {
+		sections = append(sections, formatTaskList("📋 *Ваши задачи:*", own))
+	}
+	for _, project := range projects {
+		if len(shared[project.ID]) > 0 {
+			title := fmt.Sprintf("👥 *%s* (%s):", escapeMarkdown(project.Name), roleText(project.Role))
+			sections = append(sections, formatTaskList(title, shared[project.ID]))
+		}
+	}
+	b.sendMessage(msg.Chat.ID, strings.Join(sections, ""))
+}
+
+// findSharedProject ищет проект задачи среди общих проектов, где
+// пользователь не владелец
+func findSharedProject(projects []manager.Project, projectID int) (manager.Project, bool) {
+	for _, project := range projects {
+		if project.ID == projectID && project.Role != manager.RoleOwner {
+			return project, true
+		}
+	}
+	return manager.Project{}, false
+}
+
+// roleText - роль участника проекта для сообщений бота
+func roleText(role manager.Role) string {
+	switch role {
+	case manager.RoleOwner:
+		return "владелец"
+	case manager.RoleEditor:
+		return "редактор"
+	case manager.RoleViewer:
+		return "только просмотр"
+	}
+	return string(role)
+}
+
+// listSavedFilter показывает задачи сохраненного фильтра (умного списка)
+// в его порядке сортировки
+func (b *Bot) listSavedFilter(chatID int64, userID int, name string) {
+	filter, err := b.filters.FindFilter(userID, name)
+	if errors.Is(err, manager.ErrNotFound) {
+		filters, _ := b.filters.GetFilters(userID)
+		if len(filters) == 0 {
+			b.sendMessage(chatID, "❌ Список не найден. Сохраненных списков пока нет: создайте их в веб-интерфейсе через расширенный фильтр")
+			return
+		}
+		names := make([]string, len(filters))
+		for i, f := range filters {
+			names[i] = "• " + escapeMarkdown(f.Name)
+		}
+		b.sendMessage(chatID, "❌ Список не найден. Ваши списки:\n"+strings.Join(names, "\n"))
+		return
+	}
+	if err != nil {
+		b.sendMessage(chatID, "❌ Ошибка загрузки списка: "+err.Error())
+		return
+	}
+
+	filter, tasks, err := b.filters.RunFilter(userID, filter.ID)
+	if err != nil {
+		b.sendMessage(chatID, "❌ Ошибка загрузки задач: "+err.Error())
+		return
+	}
+	if len(tasks) == 0 {
+		b.sendMessage(chatID, fmt.Sprintf("📭 В списке «%s» нет задач", escapeMarkdown(filter.Name)))
+		return
+	}
+	b.sendMessage(chatID, formatTaskList(fmt.Sprintf("⭐ *%s:*", escapeMarkdown(filter.Name)), tasks))
+}
+
+// formatTaskList формирует сообщение со списком задач под заголовком
+func formatTaskList(title string, tasks []manager.Task) string {
 	var response strings.Builder
-	response.WriteString("📋 *Ваши задачи:*\n\n")
+	response.WriteString(title + "\n\n")
 	
-	for i, task := range tasks {
+	// Показываем настоящие ID: именно их принимают /done и /delete
+	for _, task := range tasks {
 		status := "❌"
 		if task.Completed {
 			status = "✅"
@@ -164,7 +423,7 @@ func (b *Bot) handleListCommand(msg *tgbotapi.Message) {
 			priorityEmoji = "🔴"
 		}
 
-		response.WriteString(fmt.Sprintf("%d. %s%s %s", i+1, status, priorityEmoji, task.Description))
+		response.WriteString(fmt.Sprintf("#%d %s%s %s", task.ID, status, priorityEmoji, task.Description))
 
 		if len(task.Tags) > 0 {
 			response.WriteString(fmt.Sprintf(" \\#%s", strings.Join(task.Tags, " \\#")))
@@ -173,10 +432,73 @@ func (b *Bot) handleListCommand(msg *tgbotapi.Message) {
 		if !task.DueDate.IsZero() {
 			response.WriteString(fmt.Sprintf("\n   📅 %s", task.DueDate.Format("02.01.2006")))
 		}
+		if task.Recurrence != "" {
+			response.WriteString("\n   🔁 повторяется")
+		}
+		if task.AssigneeID != 0 {
+			response.WriteString("\n   👤 назначена")
+		}
 
 		response.WriteString("\n\n")
 	}
 
+	return response.String()
+}
+
+// findTaskResults - сколько найденных задач показывает /find
+const findTaskResults = 10
+
+// findTasks ищет задачи по тексту описания и подзадач
+func (b *Bot) findTasks(msg *tgbotapi.Message) {
+	query := strings.TrimSpace(msg.CommandArguments())
+	if query == "" {
+		b.sendMessage(msg.Chat.ID, "Укажите, что искать: /find отчет")
+		return
+	}
+
+	user, ok := b.currentUser(msg.Chat.ID, msg.From.ID)
+	if !ok {
+		return
+	}
+
+	results, err := b.taskManager.SearchForUser(user.ID, query, manager.FilterOptions{})
+	if err != nil {
+		if errors.Is(err, manager.ErrInvalid) {
+			b.sendMessage(msg.Chat.ID, "❌ "+err.Error())
+			return
+		}
+		b.sendMessage(msg.Chat.ID, "❌ Ошибка поиска: "+err.Error())
+		return
+	}
+	if len(results) == 0 {
+		b.sendMessage(msg.Chat.ID, "🔍 Ничего не найдено")
+		return
+	}
+
+	var response strings.Builder
+	response.WriteString(fmt.Sprintf("🔍 *Найдено задач: %d*\n\n", len(results)))
+	for i, result := range results {
+		if i == findTaskResults {
+			response.WriteString(fmt.Sprintf("…и еще %d. Уточните запрос.", len(results)-i))
+			break
+		}
+		status := "❌"
+		if result.Task.Completed {
+			status = "✅"
+		}
+		// Совпадение в описании выделяем в нем самом, в подзадачах - отдельной строкой
+		description := escapeMarkdown(result.Task.Description)
+		snippet := result.Highlight("*", "*", escapeMarkdown)
+		if result.Highlight("", "", func(s string) string { return s }) == result.Task.Description {
+			description, snippet = snippet, ""
+		}
+		response.WriteString(fmt.Sprintf("#%d %s %s\n", result.Task.ID, status, description))
+		if snippet != "" {
+			response.WriteString("   ↳ " + snippet + "\n")
+		}
+		response.WriteString("\n")
+	}
+
 	b.sendMessage(msg.Chat.ID, response.String())
 }
 
@@ -190,16 +512,12 @@ func (b *Bot) addTask(msg *tgbotapi.Message) {
 	b.addTaskFromText(msg.Chat.ID, msg.From.ID, args)
 }
 
-func (b *Bot) addTaskFromText(chatID int64, userID int, text string) {
-    // ВСЕГДА используем default пользователя из веб-интерфейса
-    defaultUser, err := b.userManager.GetUserByDeviceID("default_legacy_user")
-    if err != nil {
-        // Если default пользователь не найден, создаем его
-        defaultUser, err = b.userManager.CreateUser("default_legacy_user", 0)
-        if err != nil {
-            b.sendMessage(chatID, "❌ Ошибка создания пользователя: "+err.Error())
-            return
-        }
+// addTaskFromText создает задачу из текста с #тегами и возвращает ее ID;
+// 0 - задача не создана, пользователь уже получил сообщение об ошибке
+func (b *Bot) addTaskFromText(chatID int64, telegramID int, text string) int {
+    user, ok := b.currentUser(chatID, telegramID)
+    if !ok {
+        return 0
     }
 
     var tags []string
@@ -220,11 +538,11 @@ func (b *Bot) addTaskFromText(chatID int64, userID int, text string) {
         description = strings.TrimSpace(description)
     }
 
-    // Добавляем задачу для default пользователя
-    taskID, err := b.taskManager.AddTaskForUser(defaultUser.ID, description, tags)
+    // Добавляем задачу владельцу Telegram-аккаунта
+    taskID, err := b.taskManager.AddTaskForUser(user.ID, description, tags)
     if err != nil {
         b.sendMessage(chatID, "❌ Ошибка: "+err.Error())
-        return
+        return 0
     }
 
     response := fmt.Sprintf("✅ *Задача добавлена!*\n\nID: #%d\nЗадача: %s", taskID, description)
@@ -233,6 +551,7 @@ func (b *Bot) addTaskFromText(chatID int64, userID int, text string) {
     }
 
     b.sendMessage(chatID, response)
+    return taskID
 }
 
 func (b *Bot) completeTask(msg *tgbotapi.Message) {
@@ -248,12 +567,18 @@ func (b *Bot) completeTask(msg *tgbotapi.Message) {
 		return
 	}
 
-	_, err = b.taskManager.ToggleComplete(taskID)
+	user, ok := b.currentUser(msg.Chat.ID, msg.From.ID)
+	if !ok {
+		return
+	}
+
+	_, spawnedID, err := b.taskManager.ToggleCompleteForUser(user.ID, taskID)
 	if err != nil {
-		b.sendMessage(msg.Chat.ID, "❌ Ошибка: "+err.Error())
+		b.sendMessage(msg.Chat.ID, taskErrorText(taskID, err))
 		return
 	}
 
+	b.rememberAction(user.ID, undoAction{taskID: taskID, spawnedID: spawnedID})
 	b.sendMessage(msg.Chat.ID, fmt.Sprintf("✅ Задача #%d отмечена выполненной!", taskID))
 }
 
@@ -270,13 +595,217 @@ func (b *Bot) deleteTask(msg *tgbotapi.Message) {
 		return
 	}
 
-	err = b.taskManager.DeleteTask(taskID)
+	user, ok := b.currentUser(msg.Chat.ID, msg.From.ID)
+	if !ok {
+		return
+	}
+
+	err = b.taskManager.DeleteTaskForUser(user.ID, taskID)
+	if err != nil {
+		b.sendMessage(msg.Chat.ID, taskErrorText(taskID, err))
+		return
+	}
+
+	b.rememberAction(user.ID, undoAction{taskID: taskID, deleted: true})
+	b.sendMessage(msg.Chat.ID, fmt.Sprintf("🗑️ Задача #%d перемещена в корзину. /undo - вернуть", taskID))
+}
+
+func (b *Bot) rememberAction(userID int, action undoAction) {
+	b.undoMu.Lock()
+	defer b.undoMu.Unlock()
+	b.lastActions[userID] = action
+}
+
+// undoLastAction отменяет последнее /delete или /done пользователя:
+// возвращает задачу из корзины или снимает отметку выполнения вместе со
+// следующим повторением, которое она создала.
+// Отменить можно один раз, память о действиях живет до перезапуска бота.
+func (b *Bot) undoLastAction(msg *tgbotapi.Message) {
+	user, ok := b.currentUser(msg.Chat.ID, msg.From.ID)
+	if !ok {
+		return
+	}
+
+	b.undoMu.Lock()
+	action, exists := b.lastActions[user.ID]
+	delete(b.lastActions, user.ID)
+	b.undoMu.Unlock()
+	if !exists {
+		b.sendMessage(msg.Chat.ID, "Нечего отменять")
+		return
+	}
+
+	if action.deleted {
+		if _, err := b.taskManager.RestoreTaskForUser(user.ID, action.taskID); err != nil {
+			b.sendMessage(msg.Chat.ID, taskErrorText(action.taskID, err))
+			return
+		}
+		b.sendMessage(msg.Chat.ID, fmt.Sprintf("♻️ Задача #%d восстановлена из корзины", action.taskID))
+		return
+	}
+	task, err := b.taskManager.UndoCompleteForUser(user.ID, action.taskID, action.spawnedID)
+	if err != nil {
+		b.sendMessage(msg.Chat.ID, taskErrorText(action.taskID, err))
+		return
+	}
+	if task.Completed {
+		b.sendMessage(msg.Chat.ID, fmt.Sprintf("✅ Задача #%d снова отмечена выполненной", action.taskID))
+	} else {
+		b.sendMessage(msg.Chat.ID, fmt.Sprintf("↩ Отметка выполнения задачи #%d снята", action.taskID))
+	}
+}
+
+// assignTask назначает исполнителя задачи: /assign 12 @username.
+// Без имени или с «-» назначение снимается.
+func (b *Bot) assignTask(msg *tgbotapi.Message) {
+	args := strings.Fields(msg.CommandArguments())
+	if len(args) == 0 || len(args) > 2 {
+		b.sendMessage(msg.Chat.ID, "Укажите номер задачи и исполнителя: /assign 12 @логин")
+		return
+	}
+
+	taskID, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+	if err != nil {
+		b.sendMessage(msg.Chat.ID, "Номер задачи должен быть числом")
+		return
+	}
+
+	user, ok := b.currentUser(msg.Chat.ID, msg.From.ID)
+	if !ok {
+		return
+	}
+
+	assigneeID := 0
+	assigneeName := ""
+	if len(args) == 2 && args[1] != "-" {
+		assignee, err := b.userManager.GetUserByUsername(args[1])
+		if err != nil {
+			b.sendMessage(msg.Chat.ID, "❌ "+escapeMarkdown(err.Error()))
+			return
+		}
+		assigneeID = assignee.ID
+		assigneeName = assignee.Username
+	}
+
+	_, err = b.taskManager.UpdateTaskForUser(user.ID, taskID, manager.UpdateTaskRequest{AssigneeID: &assigneeID})
+	if err != nil {
+		if errors.Is(err, manager.ErrInvalid) {
+			b.sendMessage(msg.Chat.ID, "⛔ "+escapeMarkdown(err.Error()))
+			return
+		}
+		b.sendMessage(msg.Chat.ID, taskErrorText(taskID, err))
+		return
+	}
+
+	if assigneeID == 0 {
+		b.sendMessage(msg.Chat.ID, fmt.Sprintf("👤 С задачи #%d снят исполнитель", taskID))
+		return
+	}
+	b.sendMessage(msg.Chat.ID, fmt.Sprintf("👤 Задача #%d назначена: *%s*", taskID, escapeMarkdown(assigneeName)))
+}
+
+// listAssigned показывает открытые задачи, назначенные пользователю
+func (b *Bot) listAssigned(msg *tgbotapi.Message) {
+	user, ok := b.currentUser(msg.Chat.ID, msg.From.ID)
+	if !ok {
+		return
+	}
+
+	completed := false
+	tasks, err := b.taskManager.FilterTasksAdvancedForUser(user.ID, manager.FilterOptions{
+		AssigneeID: &user.ID,
+		Completed:  &completed,
+	})
+	if err != nil {
+		b.sendMessage(msg.Chat.ID, "❌ Ошибка загрузки задач: "+err.Error())
+		return
+	}
+	if len(tasks) == 0 {
+		b.sendMessage(msg.Chat.ID, "📭 Вам не назначено открытых задач")
+		return
+	}
+	manager.SortTasks(tasks, manager.SortByDueDate)
+	b.sendMessage(msg.Chat.ID, formatTaskList("👤 *Назначено вам:*", tasks))
+}
+
+// linkAccount привязывает Telegram к учетной записи веб-интерфейса по одноразовому коду
+func (b *Bot) linkAccount(msg *tgbotapi.Message) {
+	code := strings.TrimSpace(msg.CommandArguments())
+	if code == "" {
+		b.sendMessage(msg.Chat.ID, "Получите код в веб-интерфейсе (кнопка «Привязать Telegram») и отправьте: /link КОД")
+		return
+	}
+
+	user, moved, err := b.userManager.LinkTelegram(code, int64(msg.From.ID))
+	if err != nil {
+		switch {
+		case errors.Is(err, manager.ErrNotFound):
+			b.sendMessage(msg.Chat.ID, "❌ Код не найден или истек. Получите новый в веб-интерфейсе.")
+		case errors.Is(err, manager.ErrConflict):
+			b.sendMessage(msg.Chat.ID, "⛔ "+err.Error())
+		default:
+			logger.Error(context.Background(), err, "Ошибка привязки Telegram", "telegramID", msg.From.ID)
+			b.sendMessage(msg.Chat.ID, "❌ Ошибка привязки: "+err.Error())
+		}
+		return
+	}
+
+	response := fmt.Sprintf("🔗 Telegram привязан к учетной записи *%s*", escapeMarkdown(user.Username))
+	if moved > 0 {
+		response += fmt.Sprintf("\nПеренесено задач: %d", moved)
+	}
+	b.sendMessage(msg.Chat.ID, response)
+}
+
+// joinProject присоединяет пользователя к общему проекту по коду приглашения
+func (b *Bot) joinProject(msg *tgbotapi.Message) {
+	code := strings.TrimSpace(msg.CommandArguments())
+	if code == "" {
+		b.sendMessage(msg.Chat.ID, "Попросите владельца проекта выдать код приглашения и отправьте: /join КОД")
+		return
+	}
+	user, ok := b.currentUser(msg.Chat.ID, msg.From.ID)
+	if !ok {
+		return
+	}
+
+	project, err := b.taskManager.JoinProjectForUser(*user, code)
+	if err != nil {
+		switch {
+		case errors.Is(err, manager.ErrNotFound):
+			b.sendMessage(msg.Chat.ID, "❌ Код приглашения не найден или истек")
+		case errors.Is(err, manager.ErrConflict):
+			b.sendMessage(msg.Chat.ID, "⛔ "+escapeMarkdown(err.Error()))
+		default:
+			logger.Error(context.Background(), err, "Ошибка присоединения к проекту", "userID", user.ID)
+			b.sendMessage(msg.Chat.ID, "❌ Ошибка: "+err.Error())
+		}
+		return
+	}
+
+	b.sendMessage(msg.Chat.ID, fmt.Sprintf("👥 Вы участник проекта *%s* (%s). Его задачи теперь видны в /list",
+		escapeMarkdown(project.Name), roleText(project.Role)))
+}
+
+// unlinkAccount отвязывает Telegram от учетной записи веб-интерфейса
+func (b *Bot) unlinkAccount(msg *tgbotapi.Message) {
+	user, err := b.userManager.GetUserByTelegramID(int64(msg.From.ID))
+	if err == nil {
+		err = b.userManager.UnlinkTelegram(user.ID)
+	}
 	if err != nil {
-		b.sendMessage(msg.Chat.ID, "❌ Ошибка: "+err.Error())
+		switch {
+		case errors.Is(err, manager.ErrNotFound):
+			b.sendMessage(msg.Chat.ID, "Telegram не привязан к учетной записи")
+		case errors.Is(err, manager.ErrForbidden):
+			b.sendMessage(msg.Chat.ID, "Этот аккаунт существует только в Telegram, отвязывать нечего")
+		default:
+			b.sendMessage(msg.Chat.ID, "❌ Ошибка: "+err.Error())
+		}
 		return
 	}
 
-	b.sendMessage(msg.Chat.ID, fmt.Sprintf("🗑️ Задача #%d удалена!", taskID))
+	b.sendMessage(msg.Chat.ID, "🔓 Telegram отвязан. Задачи остались в учетной записи "+escapeMarkdown(user.Username)+", новые задачи из бота будут в отдельном списке.")
 }
 
 func (b *Bot) sendHelp(chatID int64) {
@@ -285,19 +814,39 @@ func (b *Bot) sendHelp(chatID int64) {
 */start* - Начать работу с ботом
 */add [задача]* - Добавить новую задачу
 */list* - Показать все задачи
+*/list [список]* - Показать сохраненный список (умный фильтр)
+*/find [запрос]* - Найти задачи по тексту описания и подзадач
 */done [номер]* - Отметить задачу выполненной  
-*/delete [номер]* - Удалить задачу
+*/delete [номер]* - Переместить задачу в корзину
+*/undo* - Отменить последнее удаление или отметку выполнения
+*/assign [номер] @[логин]* - Назначить исполнителя задачи (без логина - снять)
+*/mine* - Показать задачи, назначенные вам
+*/link [код]* - Привязать Telegram к учетной записи сайта
+*/unlink* - Отвязать Telegram
+*/join [код]* - Присоединиться к общему проекту по коду приглашения
 */help* - Показать эту справку
 
+💬 Ответьте на уведомление о задаче, чтобы оставить к ней комментарий
+📎 Отправьте фото или документ с подписью - получится задача с вложением
+
 *Примеры использования:*
 /add Купить молоко #покупки
 /add Подготовить отчет до пятницы 🚀
 /done 1
-/list`
+/assign 12 @anna
+/find отч*
+/find "годовой отчет"
+/list
+/list Этот спринт`
 
 	b.sendMessage(chatID, helpText)
 }
 
+// escapeMarkdown экранирует служебные символы Markdown в пользовательском тексте
+func escapeMarkdown(text string) string {
+	return strings.NewReplacer("_", "\\_", "*", "\\*", "`", "\\`", "[", "\\[").Replace(text)
+}
+
 func (b *Bot) sendMessage(chatID int64, text string) {
 	msg := tgbotapi.NewMessage(chatID, text)
 	msg.ParseMode = "Markdown"
@@ -327,8 +876,19 @@ func main() {
 
 	logger.Info(ctx, "SQLite хранилище успешно инициализировано")
 
-	taskManager := manager.NewTaskManagerWithStorage(dbStorage)
+	// Изменения из бота попадают в историю задач с источником telegram
+	taskManager := manager.NewTaskManagerWithStorage(dbStorage).WithSource(manager.SourceTelegram)
 	userManager := manager.NewUserManager(dbStorage)
+	// Подключаем напоминания, чтобы /done отменял напоминания выполненных задач
+	reminderManager := manager.NewReminderManagerWithStorage(dbStorage, taskManager)
+	filterManager := manager.NewSavedFilterManagerWithStorage(dbStorage, taskManager)
+	commentManager := manager.NewCommentManagerWithStorage(dbStorage, taskManager)
+	// Каталог вложений общий с веб-сервером
+	attachmentsDir := os.Getenv("ATTACHMENTS_DIR")
+	if attachmentsDir == "" {
+		attachmentsDir = manager.DefaultAttachmentsDir
+	}
+	attachmentManager := manager.NewAttachmentManagerWithStorage(dbStorage, taskManager, attachmentsDir)
 
 	// Токен бота
 	botToken := os.Getenv("TELEGRAM_BOT_TOKEN")
@@ -336,12 +896,16 @@ func main() {
 		botToken = "MY_TELEGRAM_TOKEN" // fallback
 	}
 
-	bot, err := NewBot(botToken, taskManager, dbStorage, userManager)
+	bot, err := NewBot(botToken, taskManager, dbStorage, userManager, filterManager, commentManager, attachmentManager)
 	if err != nil {
 		logger.Error(ctx, err, "Ошибка создания бота")
 		return
 	}
 
 	logger.Info(ctx, "Бот успешно инициализирован")
+
+	notifier := scheduler.NewTelegramNotifier(bot.api)
+	go scheduler.New("telegram-bot", reminderManager, userManager, notifier).Run(ctx)
+
 	bot.Start()
 }
\ No newline at end of file
@@ -3,8 +3,11 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"html/template"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"strconv"
@@ -14,14 +17,132 @@ import (
 	"sort"
 
 	"github.com/go-chi/chi/v5"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"todo-app/internal/api"
 	"todo-app/internal/logger"
 	"todo-app/internal/manager"
+	"todo-app/internal/openapi"
+	"todo-app/internal/scheduler"
 	"todo-app/internal/storage"
 )
 
 type TemplateData struct {
 	Tasks []manager.Task
+	User  *manager.User
+
+	// Query - текст поиска; Snippets - фрагменты с совпадениями по ID задачи
+	Query    string
+	Snippets map[int]template.HTML
+
+	// SavedFilters - умные списки для боковой панели; ActiveFilter - открытый
+	// список; FilterQuery - параметры расширенного фильтра, который можно сохранить
+	SavedFilters []manager.SavedFilter
+	ActiveFilter *manager.SavedFilter
+	FilterQuery  string
+
+	// Projects - все проекты пользователя вместе с архивом; ActiveProject -
+	// открытый проект
+	Projects      []manager.Project
+	ActiveProject *manager.Project
+
+	// Members - владелец и участники открытого проекта
+	Members []manager.Member
+
+	// Assigned - открыт список «Назначено мне»; Usernames - имена
+	// исполнителей задач на странице по ID
+	Assigned  bool
+	Usernames map[int]string
+
+	// Trash - открыта корзина; TrashDays - через сколько дней задачи из нее
+	// удаляются окончательно
+	Trash     bool
+	TrashDays int
+
+	// Conflict - изменение задачи не применено, потому что ее успели
+	// поменять в другом месте; страница предлагает обновить или перезаписать
+	Conflict *TaskConflict
+}
+
+// TaskConflict - задача в текущем виде и значения формы, которые не сохранились
+type TaskConflict struct {
+	Task manager.Task
+	Form url.Values
+}
+
+// AuthPageData - данные для страниц входа и регистрации
+type AuthPageData struct {
+	Error    string
+	Notice   string
+	Login    string
+	Username string
+	Email    string
+}
+
+const sessionCookieName = api.SessionCookieName
+
+// trashRetention - срок хранения задач в корзине, задается TRASH_RETENTION_DAYS
+var trashRetention = manager.DefaultTrashRetention
+
+// publicPaths доступны без входа
+var publicPaths = map[string]bool{
+	"/login":        true,
+	"/register":     true,
+	"/metrics":      true,
+	"/openapi.json": true,
+}
+
+func renderAuthPage(w http.ResponseWriter, page string, status int, data AuthPageData) {
+	tmpl := template.Must(template.ParseFiles("static/" + page))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	tmpl.Execute(w, data)
+}
+
+// renderIndex отдает главную страницу со списком задач
+func renderIndex(w http.ResponseWriter, data TemplateData) {
+	tmpl := template.Must(template.New("index.html").Funcs(templateFuncs).ParseFiles("static/index.html"))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if data.Conflict != nil {
+		w.WriteHeader(http.StatusConflict)
+	}
+	tmpl.Execute(w, data)
+}
+
+func setSessionCookie(w http.ResponseWriter, r *http.Request, token string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func clearSessionCookie(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// startSession открывает сессию и перенаправляет на главную
+func startSession(w http.ResponseWriter, r *http.Request, userManager *manager.UserManager, user *manager.User) {
+	token, session, err := userManager.CreateSession(user.ID)
+	if err != nil {
+		logger.Error(r.Context(), err, "Ошибка создания сессии")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	setSessionCookie(w, r, token, session.ExpiresAt)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
 var templateFuncs = template.FuncMap{
@@ -50,74 +171,360 @@ var templateFuncs = template.FuncMap{
 	},
 }
 
+// parseFilterForm читает фильтры формы расширенной фильтрации; userID
+// подставляется вместо assignee=me. Некорректные значения пропускаются,
+// как и раньше в обработчике.
+func parseFilterForm(query url.Values, userID int) manager.FilterOptions {
+	options := manager.FilterOptions{}
+
+	if completedStr := query.Get("completed"); completedStr != "" {
+		completed := completedStr == "true"
+		options.Completed = &completed
+	}
+
+	if priorityStr := query.Get("priority"); priorityStr != "" {
+		priority := manager.Priority(priorityStr)
+		if priority.Valid() {
+			options.Priority = &priority
+		}
+	}
+
+	if tagsStr := query.Get("tags"); tagsStr != "" {
+		for _, tag := range strings.Split(tagsStr, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				options.Tags = append(options.Tags, tag)
+			}
+		}
+	}
+
+	if startStr := query.Get("start_date"); startStr != "" {
+		if start, err := time.Parse("02.01.2006", startStr); err == nil {
+			options.StartDate = &start
+		}
+	}
+
+	if endStr := query.Get("end_date"); endStr != "" {
+		if end, err := time.Parse("02.01.2006", endStr); err == nil {
+			options.EndDate = &end
+		}
+	}
+
+	if hasDueDateStr := query.Get("has_due_date"); hasDueDateStr != "" {
+		hasDueDate := hasDueDateStr == "true"
+		options.HasDueDate = &hasDueDate
+	}
+
+	if projectID, err := strconv.Atoi(query.Get("project_id")); err == nil {
+		options.ProjectID = &projectID
+	}
+
+	switch assignee := query.Get("assignee"); assignee {
+	case "me":
+		options.AssigneeID = &userID
+	case "none":
+		none := 0
+		options.AssigneeID = &none
+	default:
+		if assigneeID, err := strconv.Atoi(assignee); err == nil {
+			options.AssigneeID = &assigneeID
+		}
+	}
+
+	// Запрос не пропускается молча: ошибку разбора покажет обработчик
+	options.Query = strings.TrimSpace(query.Get("query"))
+	return options
+}
+
+// formAssigneeID читает поле assignee формы задачи - имя исполнителя.
+// Пустое поле снимает назначение, отсутствующее - оставляет как есть.
+func formAssigneeID(r *http.Request, users *manager.UserManager) (*int, error) {
+	if _, present := r.Form["assignee"]; !present {
+		return nil, nil
+	}
+	username := strings.TrimSpace(r.Form.Get("assignee"))
+	if username == "" {
+		none := 0
+		return &none, nil
+	}
+	assignee, err := users.GetUserByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	return &assignee.ID, nil
+}
+
+// formProjectID читает необязательное поле project_id формы задачи;
+// ok = false, если поле заполнено, но это не число
+func formProjectID(r *http.Request) (projectID *int, ok bool) {
+	value := strings.TrimSpace(r.FormValue("project_id"))
+	if value == "" {
+		return nil, true
+	}
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, false
+	}
+	return &id, true
+}
+
+// formIDs разбирает повторяющееся поле формы со списком ID
+func formIDs(values []string) ([]int, bool) {
+	ids := make([]int, 0, len(values))
+	for _, value := range values {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, false
+		}
+		ids = append(ids, id)
+	}
+	return ids, true
+}
+
+// httpStatusForError выбирает HTTP-статус по типизированной ошибке менеджера
+func httpStatusForError(err error) int {
+	switch {
+	case errors.Is(err, manager.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, manager.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, manager.ErrConflict):
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
+// reminderNotifier выбирает, куда веб-сервер отправляет напоминания:
+// в Telegram, если задан TELEGRAM_BOT_TOKEN, или в stdout при REMINDER_NOTIFIER=log.
+// Без них планировщик не запускается и напоминания отправляет бот.
+func reminderNotifier(ctx context.Context) scheduler.Notifier {
+	if token := os.Getenv("TELEGRAM_BOT_TOKEN"); token != "" {
+		api, err := tgbotapi.NewBotAPI(token)
+		if err != nil {
+			logger.Error(ctx, err, "Ошибка подключения к Telegram, напоминания отправит бот")
+			return nil
+		}
+		return scheduler.NewTelegramNotifier(api)
+	}
+	if os.Getenv("REMINDER_NOTIFIER") == "log" {
+		return scheduler.NewLogNotifier(os.Stdout)
+	}
+	return nil
+}
+
+// purgeTrash раз в час окончательно удаляет задачи, пролежавшие в корзине
+// дольше retention, и файлы вложений, на которые больше нет ссылок
+func purgeTrash(ctx context.Context, taskManager *manager.TaskManager, attachmentManager *manager.AttachmentManager,
+	retention time.Duration) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		purged, err := taskManager.PurgeTrash(time.Now().Add(-retention))
+		if err != nil {
+			logger.Error(ctx, err, "Ошибка очистки корзины")
+		} else if purged > 0 {
+			if _, err := attachmentManager.PruneBlobs(); err != nil {
+				logger.Error(ctx, err, "Ошибка очистки файлов вложений")
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// printWelcomeMessage выводит маршруты из спецификации OpenAPI, поэтому
+// список не отстает от роутера. Маршруты /api/v1 сведены в одну строку.
 func printWelcomeMessage() {
+	var endpoints strings.Builder
+	if doc, err := openapi.Load(); err == nil {
+		for _, route := range doc.Routes() {
+			if strings.HasPrefix(route.Path, "/api/") {
+				continue
+			}
+			fmt.Fprintf(&endpoints, "  %-6s %s - %s\n", route.Method, route.Path, route.Operation.Summary)
+		}
+	}
+	endpoints.WriteString("  /api/v1/...    - JSON API, see /openapi.json\n")
+
 	println(`
 🚀 Todo-App Server
 -----------------------------
 Available endpoints:
-  POST   /tasks          - Add new task
-  POST   /tasks/toggle/{id} - Toggle task completion
-  POST   /tasks/update/{id} - Update task
-  POST   /tasks/delete/{id} - Delete task
-  GET    /tasks/filter/{status} - Filter tasks (all/completed/active)
-  GET    /tasks/priority/{priority} - Filter by priority (low/medium/high)
-  GET    /tasks/tag/{tag} - Filter by tag
-  GET    /tasks/upcoming/{days} - Upcoming tasks (within days)
-  GET    /               - Web Interface (:8080)
-  GET    /metrics        - Prometheus metrics
------------------------------
+` + endpoints.String() + `-----------------------------
 Storage type: In-Memory
 Start time: ` + time.Now().Format("2006-01-02 15:04:05") + `
 -----------------------------
 `)
 }
 
-func main() {
-	ctx := context.Background()
-	logger.SetLevel(logger.LevelInfo)
-	printWelcomeMessage()
-	logger.Info(ctx, "Starting todo-app server...")
-
-	if err := os.MkdirAll("data", 0755); err != nil {
-		logger.Error(ctx, err, "Ошибка создания директории data")
-		return
-	}
-
-	dbStorage, err := storage.NewSQLiteStorage("./data/todoapp.db")
-	if err != nil {
-		logger.Error(ctx, err, "Ошибка инициализации SQLite хранилища")
-		return
-	}
-	defer dbStorage.Close()
-
-	logger.Info(ctx, "SQLite хранилище успешно инициализировано")
-
-	taskManager := manager.NewTaskManagerWithStorage(dbStorage)
-	userManager := manager.NewUserManager(dbStorage)
-	subTaskManager := manager.NewSubTaskManager()
-
+// newRouter собирает все маршруты веб-сервера. Каждый маршрут описан
+// в internal/openapi/openapi.json; тесты сверяют роутер со спецификацией.
+func newRouter(taskManager *manager.TaskManager, subTaskManager *manager.SubTaskManager,
+	userManager *manager.UserManager, reminderManager *manager.ReminderManager,
+	filterManager *manager.SavedFilterManager, commentManager *manager.CommentManager,
+	attachmentManager *manager.AttachmentManager) *chi.Mux {
 	r := chi.NewRouter()
 	
-	// Middleware аутентификации ПЕРВЫМ
+	// Middleware аутентификации ПЕРВЫМ: пользователь берется из сессии,
+	// без нее - перенаправление на страницу входа
 	r.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			user, err := userManager.GetUserByDeviceID("default_legacy_user")
-			if err != nil {
-				user, err = userManager.CreateUser("default_legacy_user", 0)
-				if err != nil {
-					logger.Error(r.Context(), err, "Ошибка создания пользователя")
+			// API проверяет сессию сам и отвечает 401 вместо редиректа
+			if publicPaths[r.URL.Path] || strings.HasPrefix(r.URL.Path, "/api/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var user *manager.User
+			if cookie, err := r.Cookie(sessionCookieName); err == nil {
+				user, err = userManager.GetUserBySession(cookie.Value)
+				if err != nil && !errors.Is(err, manager.ErrNotFound) {
+					logger.Error(r.Context(), err, "Ошибка проверки сессии")
 					http.Error(w, "Internal server error", http.StatusInternalServerError)
 					return
 				}
 			}
+			if user == nil {
+				http.Redirect(w, r, "/login", http.StatusSeeOther)
+				return
+			}
 			
 			ctx := context.WithValue(r.Context(), "user", user)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	})
 
+	r.Get("/login", func(w http.ResponseWriter, r *http.Request) {
+		renderAuthPage(w, "login.html", http.StatusOK, AuthPageData{})
+	})
+
+	r.Post("/login", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Invalid form data", http.StatusBadRequest)
+			return
+		}
+		login := r.FormValue("login")
+		user, err := userManager.Authenticate(login, r.FormValue("password"))
+		if err != nil {
+			logger.Info(r.Context(), "Неудачная попытка входа", "login", login)
+			status := http.StatusUnauthorized
+			if errors.Is(err, manager.ErrTooManyLoginAttempts) {
+				status = http.StatusTooManyRequests
+			}
+			renderAuthPage(w, "login.html", status, AuthPageData{Error: err.Error(), Login: login})
+			return
+		}
+		logger.Info(r.Context(), "Пользователь вошел", "userID", user.ID)
+		startSession(w, r, userManager, user)
+	})
+
+	r.Get("/register", func(w http.ResponseWriter, r *http.Request) {
+		renderAuthPage(w, "register.html", http.StatusOK, AuthPageData{})
+	})
+
+	r.Post("/register", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Invalid form data", http.StatusBadRequest)
+			return
+		}
+		data := AuthPageData{Username: r.FormValue("username"), Email: r.FormValue("email")}
+		password := r.FormValue("password")
+		if password != r.FormValue("password_confirm") {
+			data.Error = "пароли не совпадают"
+			renderAuthPage(w, "register.html", http.StatusBadRequest, data)
+			return
+		}
+		user, err := userManager.Register(data.Username, data.Email, password)
+		if err != nil {
+			data.Error = err.Error()
+			renderAuthPage(w, "register.html", httpStatusForError(err), data)
+			return
+		}
+		startSession(w, r, userManager, user)
+	})
+
+	r.Post("/logout", func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie(sessionCookieName); err == nil {
+			if err := userManager.DeleteSession(cookie.Value); err != nil {
+				logger.Error(r.Context(), err, "Ошибка удаления сессии")
+			}
+		}
+		clearSessionCookie(w, r)
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+	})
+
+	// Привязка Telegram: код действует LinkCodeTTL и вводится в боте командой /link
+	r.Post("/account/telegram/link-code", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+
+		code, linkCode, err := userManager.CreateLinkCode(user.ID)
+		if err != nil {
+			logger.Error(r.Context(), err, "Ошибка создания кода привязки")
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"code":       code,
+			"command":    "/link " + code,
+			"expires_at": linkCode.ExpiresAt,
+		})
+	})
+
+	r.Post("/account/telegram/unlink", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+
+		if err := userManager.UnlinkTelegram(user.ID); err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
+		}
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	})
+
+	// renderTasks дополняет страницу со списком задач сохраненными фильтрами,
+	// проектами и именами исполнителей
+	renderTasks := func(w http.ResponseWriter, data TemplateData) {
+		if data.User != nil {
+			filters, err := filterManager.GetFilters(data.User.ID)
+			if err != nil {
+				logger.Error(context.Background(), err, "Ошибка загрузки сохраненных фильтров", "userID", data.User.ID)
+			}
+			data.SavedFilters = filters
+			projects, err := taskManager.GetProjectsForUser(data.User.ID)
+			if err != nil {
+				logger.Error(context.Background(), err, "Ошибка загрузки проектов", "userID", data.User.ID)
+			}
+			data.Projects = projects
+			data.Usernames = make(map[int]string)
+			for _, task := range data.Tasks {
+				if _, known := data.Usernames[task.AssigneeID]; task.AssigneeID == 0 || known {
+					continue
+				}
+				if assignee, err := userManager.GetUserByID(task.AssigneeID); err == nil {
+					data.Usernames[task.AssigneeID] = assignee.Username
+				}
+			}
+		}
+		renderIndex(w, data)
+	}
+
 	// Затем роуты
-	r.Handle("/metrics", promhttp.Handler())
+	r.Method(http.MethodGet, "/metrics", promhttp.Handler())
+	r.Method(http.MethodGet, "/openapi.json", openapi.Handler())
+	// Изменения через API попадают в историю задач с источником api
+	r.Mount("/api/v1", api.New(taskManager.WithSource(manager.SourceAPI), subTaskManager.WithSource(manager.SourceAPI),
+		userManager, filterManager, commentManager, attachmentManager).Handler())
 
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		user, ok := r.Context().Value("user").(*manager.User)
@@ -132,9 +539,7 @@ func main() {
 			return
 		}
 		
-		tmpl := template.Must(template.New("index.html").Funcs(templateFuncs).ParseFiles("static/index.html"))
-		data := TemplateData{Tasks: tasks}
-		tmpl.Execute(w, data)
+		renderTasks(w, TemplateData{Tasks: tasks, User: user})
 	})
 
 	r.Get("/tasks/filter/{status}", func(w http.ResponseWriter, r *http.Request) {
@@ -160,20 +565,13 @@ func main() {
 			return
 		}
 		
-		tasks, err := taskManager.GetAllTasksForUser(user.ID)
+		tasks, err := taskManager.FilterTasksForUser(user.ID, completed)
 		if err != nil {
 			http.Error(w, "Ошибка загрузки задач", http.StatusInternalServerError)
 			return
 		}
-		var filteredTasks []manager.Task
-		for _, task := range tasks {
-			if completed == nil || task.Completed == *completed {
-				filteredTasks = append(filteredTasks, task)
-			}
-		}
 		
-		tmpl := template.Must(template.New("index.html").Funcs(templateFuncs).ParseFiles("static/index.html"))
-		tmpl.Execute(w, TemplateData{Tasks: filteredTasks})
+		renderTasks(w, TemplateData{Tasks: tasks, User: user})
 	})
 
 	r.Get("/tasks/priority/{priority}", func(w http.ResponseWriter, r *http.Request) {
@@ -189,20 +587,13 @@ func main() {
 			return
 		}
 		
-		tasks, err := taskManager.GetAllTasksForUser(user.ID)
+		tasks, err := taskManager.FilterByPriorityForUser(user.ID, priority)
 		if err != nil {
 			http.Error(w, "Ошибка загрузки задач", http.StatusInternalServerError)
 			return
 		}
-		var filteredTasks []manager.Task
-		for _, task := range tasks {
-			if task.Priority == priority {
-				filteredTasks = append(filteredTasks, task)
-			}
-		}
 		
-		tmpl := template.Must(template.New("index.html").Funcs(templateFuncs).ParseFiles("static/index.html"))
-		tmpl.Execute(w, TemplateData{Tasks: filteredTasks})
+		renderTasks(w, TemplateData{Tasks: tasks, User: user})
 	})
 
 	r.Get("/tasks/tag/{tag}", func(w http.ResponseWriter, r *http.Request) {
@@ -213,23 +604,32 @@ func main() {
 		}
 
 		tag := chi.URLParam(r, "tag")
-		tasks, err := taskManager.GetAllTasksForUser(user.ID)
+		tasks, err := taskManager.FilterByTagForUser(user.ID, tag)
 		if err != nil {
 			http.Error(w, "Ошибка загрузки задач", http.StatusInternalServerError)
 			return
 		}
-		var filteredTasks []manager.Task
-		for _, task := range tasks {
-			for _, taskTag := range task.Tags {
-				if strings.EqualFold(taskTag, tag) {
-					filteredTasks = append(filteredTasks, task)
-					break
-				}
-			}
-		}
 		
-		tmpl := template.Must(template.New("index.html").Funcs(templateFuncs).ParseFiles("static/index.html"))
-		tmpl.Execute(w, TemplateData{Tasks: filteredTasks})
+		renderTasks(w, TemplateData{Tasks: tasks, User: user})
+	})
+
+	// «Назначено мне»: задачи, где пользователь исполнитель, по сроку
+	r.Get("/tasks/assigned", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+
+		tasks, err := taskManager.FilterTasksAdvancedForUser(user.ID, manager.FilterOptions{AssigneeID: &user.ID})
+		if err != nil {
+			http.Error(w, "Ошибка загрузки задач", http.StatusInternalServerError)
+			return
+		}
+		if sortOrder := manager.SortOrder(r.URL.Query().Get("sort")); sortOrder.Valid() {
+			manager.SortTasks(tasks, sortOrder)
+		}
+		renderTasks(w, TemplateData{Tasks: tasks, User: user, Assigned: true})
 	})
 
 	r.Get("/tasks/upcoming/{days}", func(w http.ResponseWriter, r *http.Request) {
@@ -246,24 +646,13 @@ func main() {
 			return
 		}
 		
-		tasks, err := taskManager.GetAllTasksForUser(user.ID)
+		tasks, err := taskManager.GetUpcomingTasksForUser(user.ID, days)
 		if err != nil {
 			http.Error(w, "Ошибка загрузки задач", http.StatusInternalServerError)
 			return
 		}
-		now := time.Now()
-		var filteredTasks []manager.Task
-		for _, task := range tasks {
-			if !task.DueDate.IsZero() && !task.Completed {
-				daysUntilDue := int(task.DueDate.Sub(now).Hours() / 24)
-				if daysUntilDue >= 0 && daysUntilDue <= days {
-					filteredTasks = append(filteredTasks, task)
-				}
-			}
-		}
 		
-		tmpl := template.Must(template.New("index.html").Funcs(templateFuncs).ParseFiles("static/index.html"))
-		tmpl.Execute(w, TemplateData{Tasks: filteredTasks})
+		renderTasks(w, TemplateData{Tasks: tasks, User: user})
 	})
 
 	r.Post("/tasks", func(w http.ResponseWriter, r *http.Request) {
@@ -308,27 +697,44 @@ func main() {
         }
     }
 
-    // ИЗМЕНИТЬ эту строку:
-    taskID, err := taskManager.AddTaskForUser(user.ID, description, tags)
-    if err != nil {
+    // Правило проверяем до создания задачи, чтобы не оставить задачу без него
+    recurrence := strings.TrimSpace(r.FormValue("recurrence"))
+    if recurrence != "" {
+        if _, err := manager.ParseRecurrence(recurrence); err != nil {
+            manager.AddTaskCount.WithLabelValues("error").Inc()
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+    }
+
+    // Без project_id задача попадает во Входящие
+    projectID, ok := formProjectID(r)
+    if !ok {
         manager.AddTaskCount.WithLabelValues("error").Inc()
-        http.Error(w, err.Error(), http.StatusBadRequest)
+        http.Error(w, "Неверный ID проекта", http.StatusBadRequest)
         return
     }
 
-    _, err = taskManager.UpdateTask(taskID, manager.UpdateTaskRequest{
-        Priority: &priority,
-        DueDate:  &dueDate,
+    // Проект проверяется до создания задачи, поэтому задача не остается во Входящих
+    _, err := taskManager.CreateTaskForUser(user.ID, description, tags, manager.UpdateTaskRequest{
+        Priority:   &priority,
+        DueDate:    &dueDate,
+        Recurrence: &recurrence,
+        ProjectID:  projectID,
     })
     if err != nil {
         manager.AddTaskCount.WithLabelValues("error").Inc()
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        http.Error(w, err.Error(), httpStatusForError(err))
         return
     }
 
     manager.AddTaskCount.WithLabelValues("success").Inc()
     manager.AddTaskDuration.Observe(time.Since(startTime).Seconds())
     manager.TaskDescLength.Observe(float64(len(description)))
+    if projectID != nil {
+        http.Redirect(w, r, fmt.Sprintf("/projects/%d", *projectID), http.StatusSeeOther)
+        return
+    }
     http.Redirect(w, r, "/", http.StatusSeeOther)
 })
 
@@ -348,32 +754,34 @@ func main() {
 			return
 		}
 		
-		tasks, err := taskManager.GetAllTasksForUser(user.ID)
-		if err != nil {
-			http.Error(w, "Ошибка загрузки задач", http.StatusInternalServerError)
-			return
-		}
-		taskFound := false
-		for _, task := range tasks {
-			if task.ID == id {
-				taskFound = true
-				break
+		
+		// «Отменить» в уведомлении передает ?undo_spawned=ID: отмена убирает
+		// и следующее повторение, созданное отметкой
+		if value := r.URL.Query().Get("undo_spawned"); value != "" {
+			spawnedID, err := strconv.Atoi(value)
+			if err != nil {
+				http.Error(w, "Неверный ID повторения", http.StatusBadRequest)
+				return
 			}
-		}
-		if !taskFound {
-			http.Error(w, "Задача не найдена", http.StatusNotFound)
+			if _, err := taskManager.UndoCompleteForUser(user.ID, id, spawnedID); err != nil {
+				manager.UpdateTaskCount.WithLabelValues("error").Inc()
+				http.Error(w, err.Error(), httpStatusForError(err))
+				return
+			}
+			http.Redirect(w, r, "/", http.StatusSeeOther)
 			return
 		}
-		
-		_, err = taskManager.ToggleComplete(id)
+
+		_, spawnedID, err := taskManager.ToggleCompleteForUser(user.ID, id)
 		if err != nil {
 			manager.UpdateTaskCount.WithLabelValues("error").Inc()
-			http.Error(w, err.Error(), http.StatusNotFound)
+			http.Error(w, err.Error(), httpStatusForError(err))
 			return
 		}
 		manager.UpdateTaskCount.WithLabelValues("success").Inc()
 		manager.UpdateTaskDuration.Observe(time.Since(startTime).Seconds())
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		// Параметр показывает на главной уведомление с кнопкой «Отменить»
+		http.Redirect(w, r, fmt.Sprintf("/?toggled=%d&spawned=%d", id, spawnedID), http.StatusSeeOther)
 	})
 
 	r.Post("/tasks/update/{id}", func(w http.ResponseWriter, r *http.Request) {
@@ -392,22 +800,6 @@ func main() {
 			return
 		}
 		
-		tasks, err := taskManager.GetAllTasksForUser(user.ID)
-		if err != nil {
-			http.Error(w, "Ошибка загрузки задач", http.StatusInternalServerError)
-			return
-		}
-		taskFound := false
-		for _, task := range tasks {
-			if task.ID == id {
-				taskFound = true
-				break
-			}
-		}
-		if !taskFound {
-			http.Error(w, "Задача не найдена", http.StatusNotFound)
-			return
-		}
 		
 		description := r.FormValue("description")
 		if description == "" {
@@ -433,19 +825,72 @@ func main() {
 				tags[i] = strings.TrimSpace(tags[i])
 			}
 		}
-		_, err = taskManager.UpdateTask(id, manager.UpdateTaskRequest{
-			Description: &description,
-			Priority:    &priority,
-			DueDate:     &dueDate,
-			Tags:        &tags,
-		})
+		recurrence := r.FormValue("recurrence")
+		projectID, ok := formProjectID(r)
+		if !ok {
+			manager.UpdateTaskCount.WithLabelValues("error").Inc()
+			http.Error(w, "Неверный ID проекта", http.StatusBadRequest)
+			return
+		}
+		assigneeID, err := formAssigneeID(r, userManager)
 		if err != nil {
 			manager.UpdateTaskCount.WithLabelValues("error").Inc()
-			http.Error(w, err.Error(), http.StatusNotFound)
+			http.Error(w, err.Error(), httpStatusForError(err))
 			return
 		}
-		manager.UpdateTaskCount.WithLabelValues("success").Inc()
-		manager.UpdateTaskDuration.Observe(time.Since(startTime).Seconds())
+		// Версия, с которой открыли форму: правка не затрет чужое изменение
+		var version *int
+		if v, err := strconv.Atoi(r.FormValue("version")); err == nil {
+			version = &v
+		}
+		if r.FormValue("scope") == "series" {
+			// Срок у каждого экземпляра свой, поэтому для серии не меняется
+			_, err = taskManager.UpdateSeriesForUser(user.ID, id, manager.UpdateTaskRequest{
+				Description: &description,
+				Priority:    &priority,
+				Tags:        &tags,
+				Recurrence:  &recurrence,
+				ProjectID:   projectID,
+				AssigneeID:  assigneeID,
+				Version:     version,
+			})
+		} else {
+			_, err = taskManager.UpdateTaskForUser(user.ID, id, manager.UpdateTaskRequest{
+				Description: &description,
+				Priority:    &priority,
+				DueDate:     &dueDate,
+				Tags:        &tags,
+				Recurrence:  &recurrence,
+				ProjectID:   projectID,
+				AssigneeID:  assigneeID,
+				Version:     version,
+			})
+		}
+		var conflict *manager.VersionConflictError
+		if errors.As(err, &conflict) {
+			manager.UpdateTaskCount.WithLabelValues("error").Inc()
+			tasks, err := taskManager.GetAllTasksForUser(user.ID)
+			if err != nil {
+				http.Error(w, "Ошибка загрузки задач", http.StatusInternalServerError)
+				return
+			}
+			form := make(url.Values)
+			for name, values := range r.PostForm {
+				if name != "version" {
+					form[name] = values
+				}
+			}
+			renderTasks(w, TemplateData{Tasks: tasks, User: user,
+				Conflict: &TaskConflict{Task: conflict.Current, Form: form}})
+			return
+		}
+		if err != nil {
+			manager.UpdateTaskCount.WithLabelValues("error").Inc()
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
+		}
+		manager.UpdateTaskCount.WithLabelValues("success").Inc()
+		manager.UpdateTaskDuration.Observe(time.Since(startTime).Seconds())
 		http.Redirect(w, r, "/", http.StatusSeeOther)
 	})
 
@@ -465,31 +910,51 @@ func main() {
 			return
 		}
 		
-		tasks, err := taskManager.GetAllTasksForUser(user.ID)
+		
+		if err := taskManager.DeleteTaskForUser(user.ID, id); err != nil {
+			manager.DeleteTaskCount.WithLabelValues("error").Inc()
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
+		}
+		manager.DeleteTaskCount.WithLabelValues("success").Inc()
+		manager.DeleteTaskDuration.Observe(time.Since(startTime).Seconds())
+		http.Redirect(w, r, fmt.Sprintf("/?deleted=%d", id), http.StatusSeeOther)
+	})
+
+	// Корзина: удаленные задачи до окончательной очистки
+	r.Get("/trash", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+
+		tasks, err := taskManager.GetTrashForUser(user.ID)
 		if err != nil {
-			http.Error(w, "Ошибка загрузки задач", http.StatusInternalServerError)
+			http.Error(w, "Ошибка загрузки корзины", http.StatusInternalServerError)
 			return
 		}
-		taskFound := false
-		for _, task := range tasks {
-			if task.ID == id {
-				taskFound = true
-				break
-			}
+		renderTasks(w, TemplateData{Tasks: tasks, User: user, Trash: true,
+			TrashDays: int(trashRetention / (24 * time.Hour))})
+	})
+
+	r.Post("/tasks/restore/{id}", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
 		}
-		if !taskFound {
-			http.Error(w, "Задача не найдена", http.StatusNotFound)
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Неверный ID задачи", http.StatusBadRequest)
 			return
 		}
-		
-		if err := taskManager.DeleteTask(id); err != nil {
-			manager.DeleteTaskCount.WithLabelValues("error").Inc()
-			http.Error(w, err.Error(), http.StatusNotFound)
+		if _, err := taskManager.RestoreTaskForUser(user.ID, id); err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
 			return
 		}
-		manager.DeleteTaskCount.WithLabelValues("success").Inc()
-		manager.DeleteTaskDuration.Observe(time.Since(startTime).Seconds())
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		http.Redirect(w, r, "/trash", http.StatusSeeOther)
 	})
 
 	r.Get("/tasks/filter/date", func(w http.ResponseWriter, r *http.Request) {
@@ -518,20 +983,13 @@ func main() {
 			return
 		}
 
-		tasks, err := taskManager.GetAllTasksForUser(user.ID)
+		tasks, err := taskManager.FilterByDateRangeForUser(user.ID, start, end)
 		if err != nil {
 			http.Error(w, "Ошибка загрузки задач", http.StatusInternalServerError)
 			return
 		}
-		var filteredTasks []manager.Task
-		for _, task := range tasks {
-			if !task.DueDate.IsZero() && !task.DueDate.Before(start) && !task.DueDate.After(end) {
-				filteredTasks = append(filteredTasks, task)
-			}
-		}
 		
-		tmpl := template.Must(template.New("index.html").Funcs(templateFuncs).ParseFiles("static/index.html"))
-		tmpl.Execute(w, TemplateData{Tasks: filteredTasks})
+		renderTasks(w, TemplateData{Tasks: tasks, User: user})
 	})
 
 	// Подзадачи
@@ -549,24 +1007,16 @@ func main() {
 			return
 		}
 		
-		tasks, err := taskManager.GetAllTasksForUser(user.ID)
-		if err != nil {
-			http.Error(w, "Ошибка загрузки задач", http.StatusInternalServerError)
+		if _, err := taskManager.GetTaskForUser(user.ID, taskID); err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
 			return
 		}
-		taskFound := false
-		for _, task := range tasks {
-			if task.ID == taskID {
-				taskFound = true
-				break
-			}
-		}
-		if !taskFound {
-			http.Error(w, "Задача не найдена", http.StatusNotFound)
+		
+		subtasks, err := subTaskManager.GetSubTasks(user.ID, taskID)
+		if err != nil {
+			http.Error(w, "Ошибка загрузки подзадач", http.StatusInternalServerError)
 			return
 		}
-		
-		subtasks := subTaskManager.GetSubTasks(taskID)
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(subtasks)
 	})
@@ -585,20 +1035,8 @@ func main() {
 			return
 		}
 		
-		tasks, err := taskManager.GetAllTasksForUser(user.ID)
-		if err != nil {
-			http.Error(w, "Ошибка загрузки задач", http.StatusInternalServerError)
-			return
-		}
-		taskFound := false
-		for _, task := range tasks {
-			if task.ID == taskID {
-				taskFound = true
-				break
-			}
-		}
-		if !taskFound {
-			http.Error(w, "Задача не найдена", http.StatusNotFound)
+		if _, err := taskManager.GetTaskForUser(user.ID, taskID); err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
 			return
 		}
 		
@@ -608,9 +1046,9 @@ func main() {
 			return
 		}
 		
-		id, err := subTaskManager.AddSubTask(taskID, description)
+		id, err := subTaskManager.AddSubTask(user.ID, taskID, description)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			http.Error(w, err.Error(), httpStatusForError(err))
 			return
 		}
 		
@@ -619,6 +1057,12 @@ func main() {
 	})
 	
 	r.Post("/subtasks/{id}/toggle", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+
 		idStr := chi.URLParam(r, "id")
 		id, err := strconv.Atoi(idStr)
 		if err != nil {
@@ -626,8 +1070,8 @@ func main() {
 			return
 		}
 		
-		if err := subTaskManager.ToggleSubTask(id); err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+		if err := subTaskManager.ToggleSubTask(user.ID, id); err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
 			return
 		}
 		
@@ -635,6 +1079,12 @@ func main() {
 	})
 	
 	r.Delete("/subtasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+
 		idStr := chi.URLParam(r, "id")
 		id, err := strconv.Atoi(idStr)
 		if err != nil {
@@ -642,129 +1092,766 @@ func main() {
 			return
 		}
 		
-		if err := subTaskManager.DeleteSubTask(id); err != nil {
-			http.Error(w, err.Error(), http.StatusNotFound)
+		if err := subTaskManager.DeleteSubTask(user.ID, id); err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
 			return
 		}
 		
 		w.WriteHeader(http.StatusOK)
 	})
 
-	r.Get("/tasks/filter/advanced", func(w http.ResponseWriter, r *http.Request) {
+	// Комментарии
+	r.Get("/tasks/{taskID}/comments", func(w http.ResponseWriter, r *http.Request) {
 		user, ok := r.Context().Value("user").(*manager.User)
 		if !ok {
 			http.Error(w, "User not found", http.StatusInternalServerError)
 			return
 		}
 
-		query := r.URL.Query()
-		options := manager.FilterOptions{}
-		
-		if completedStr := query.Get("completed"); completedStr != "" {
-			completed := completedStr == "true"
-			options.Completed = &completed
+		taskID, err := strconv.Atoi(chi.URLParam(r, "taskID"))
+		if err != nil {
+			http.Error(w, "Неверный ID задачи", http.StatusBadRequest)
+			return
 		}
-		
-		if priorityStr := query.Get("priority"); priorityStr != "" {
-			priority := manager.Priority(priorityStr)
-			if priority == manager.PriorityLow || priority == manager.PriorityMedium || priority == manager.PriorityHigh {
-				options.Priority = &priority
-			}
+
+		comments, err := commentManager.GetComments(user.ID, taskID)
+		if err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
 		}
-		
-		if tagsStr := query.Get("tags"); tagsStr != "" {
-			rawTags := strings.Split(tagsStr, ",")
-			options.Tags = make([]string, 0)
-			for _, tag := range rawTags {
-				tag = strings.TrimSpace(tag)
-				if tag != "" {
-					options.Tags = append(options.Tags, tag)
-				}
-			}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(comments)
+	})
+
+	r.Post("/tasks/{taskID}/comments", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
 		}
-		
-		if startStr := query.Get("start_date"); startStr != "" {
-			if start, err := time.Parse("02.01.2006", startStr); err == nil {
-				options.StartDate = &start
-			}
+
+		taskID, err := strconv.Atoi(chi.URLParam(r, "taskID"))
+		if err != nil {
+			http.Error(w, "Неверный ID задачи", http.StatusBadRequest)
+			return
 		}
-		
-		if endStr := query.Get("end_date"); endStr != "" {
-			if end, err := time.Parse("02.01.2006", endStr); err == nil {
-				options.EndDate = &end
-			}
+
+		comment, err := commentManager.AddComment(*user, taskID, r.FormValue("body"))
+		if err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
 		}
-		
-		if hasDueDateStr := query.Get("has_due_date"); hasDueDateStr != "" {
-			hasDueDate := hasDueDateStr == "true"
-			options.HasDueDate = &hasDueDate
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(comment)
+	})
+
+	r.Post("/comments/{id}", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
 		}
-		
-		tasks, err := taskManager.GetAllTasksForUser(user.ID)
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
 		if err != nil {
-			http.Error(w, "Ошибка загрузки задач", http.StatusInternalServerError)
+			http.Error(w, "Неверный ID комментария", http.StatusBadRequest)
 			return
 		}
-		var filteredTasks []manager.Task
-		
-		for _, task := range tasks {
-			if options.Completed != nil && task.Completed != *options.Completed {
-				continue
-			}
-			
-			if options.Priority != nil && task.Priority != *options.Priority {
-				continue
-			}
-			
-			if len(options.Tags) > 0 {
-				hasMatchingTag := false
-				for _, filterTag := range options.Tags {
-					filterTag = strings.TrimSpace(strings.ToLower(filterTag))
-					for _, taskTag := range task.Tags {
-						if strings.ToLower(taskTag) == filterTag {
-							hasMatchingTag = true
-							break
-						}
-					}
-					if hasMatchingTag {
-						break
-					}
-				}
-				if !hasMatchingTag {
-					continue
-				}
-			}
-			
-			if options.HasDueDate != nil {
-				hasDueDate := !task.DueDate.IsZero()
-				if hasDueDate != *options.HasDueDate {
-					continue
-				}
-			}
-			
-			if options.StartDate != nil || options.EndDate != nil {
-				if task.DueDate.IsZero() {
-					continue
-				}
-				
-				if options.StartDate != nil && task.DueDate.Before(*options.StartDate) {
-					continue
-				}
-				if options.EndDate != nil && task.DueDate.After(*options.EndDate) {
-					continue
-				}
-			}
-			
-			filteredTasks = append(filteredTasks, task)
+
+		comment, err := commentManager.UpdateComment(user.ID, id, r.FormValue("body"))
+		if err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
 		}
-		
-		tmpl := template.Must(template.New("index.html").Funcs(templateFuncs).ParseFiles("static/index.html"))
-		tmpl.Execute(w, TemplateData{Tasks: filteredTasks})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(comment)
+	})
+
+	r.Delete("/comments/{id}", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Неверный ID комментария", http.StatusBadRequest)
+			return
+		}
+
+		if err := commentManager.DeleteComment(user.ID, id); err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// История изменений
+	r.Get("/tasks/{taskID}/events", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+
+		taskID, err := strconv.Atoi(chi.URLParam(r, "taskID"))
+		if err != nil {
+			http.Error(w, "Неверный ID задачи", http.StatusBadRequest)
+			return
+		}
+
+		events, err := taskManager.GetTaskEvents(user.ID, taskID)
+		if err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(events)
+	})
+
+	// Вложения
+	r.Get("/tasks/{taskID}/attachments", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+
+		taskID, err := strconv.Atoi(chi.URLParam(r, "taskID"))
+		if err != nil {
+			http.Error(w, "Неверный ID задачи", http.StatusBadRequest)
+			return
+		}
+
+		attachments, err := attachmentManager.GetAttachments(user.ID, taskID)
+		if err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(attachments)
+	})
+
+	r.Post("/tasks/{taskID}/attachments", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+
+		taskID, err := strconv.Atoi(chi.URLParam(r, "taskID"))
+		if err != nil {
+			http.Error(w, "Неверный ID задачи", http.StatusBadRequest)
+			return
+		}
+
+		// Форма с файлом: тело ограничено, чтобы не писать на диск лишнее
+		r.Body = http.MaxBytesReader(w, r.Body, manager.MaxAttachmentSize+64<<10)
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "Файл не передан или слишком большой", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		attachment, err := attachmentManager.AddAttachment(user.ID, taskID, header.Filename, file)
+		if err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(attachment)
+	})
+
+	r.Get("/attachments/{id}", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Неверный ID вложения", http.StatusBadRequest)
+			return
+		}
+
+		attachment, file, err := attachmentManager.OpenAttachment(user.ID, id)
+		if err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
+		}
+		defer file.Close()
+		api.ServeAttachment(w, r, attachment, file)
+	})
+
+	r.Delete("/attachments/{id}", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Неверный ID вложения", http.StatusBadRequest)
+			return
+		}
+
+		if err := attachmentManager.DeleteAttachment(user.ID, id); err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
 	})
 
+	// Напоминания
+	r.Get("/tasks/{taskID}/reminders", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+
+		taskID, err := strconv.Atoi(chi.URLParam(r, "taskID"))
+		if err != nil {
+			http.Error(w, "Неверный ID задачи", http.StatusBadRequest)
+			return
+		}
+
+		reminders, err := reminderManager.GetReminders(user.ID, taskID)
+		if err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(reminders)
+	})
+
+	r.Post("/tasks/{taskID}/reminders", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+
+		taskID, err := strconv.Atoi(chi.URLParam(r, "taskID"))
+		if err != nil {
+			http.Error(w, "Неверный ID задачи", http.StatusBadRequest)
+			return
+		}
+
+		var req manager.CreateReminderRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Неверный JSON", http.StatusBadRequest)
+			return
+		}
+
+		reminder, err := reminderManager.CreateReminder(user.ID, taskID, req)
+		if err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(reminder)
+	})
+
+	r.Delete("/tasks/{taskID}/reminders/{reminderID}", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+
+		taskID, err := strconv.Atoi(chi.URLParam(r, "taskID"))
+		if err != nil {
+			http.Error(w, "Неверный ID задачи", http.StatusBadRequest)
+			return
+		}
+		reminderID, err := strconv.Atoi(chi.URLParam(r, "reminderID"))
+		if err != nil {
+			http.Error(w, "Неверный ID напоминания", http.StatusBadRequest)
+			return
+		}
+
+		reminder, err := reminderManager.GetReminder(user.ID, reminderID)
+		if err == nil && reminder.TaskID != taskID {
+			err = manager.NotFound("у задачи %d нет напоминания %d", taskID, reminderID)
+		}
+		if err == nil {
+			err = reminderManager.DeleteReminder(user.ID, reminderID)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	r.Get("/reminders/settings", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+
+		settings, err := reminderManager.GetSettings(user.ID)
+		if err != nil {
+			http.Error(w, "Ошибка загрузки настроек", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(settings)
+	})
+
+	r.Put("/reminders/settings", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+
+		var settings manager.ReminderSettings
+		if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+			http.Error(w, "Неверный JSON", http.StatusBadRequest)
+			return
+		}
+		settings.UserID = user.ID
+
+		saved, err := reminderManager.SaveSettings(settings)
+		if err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(saved)
+	})
+
+	r.Get("/tasks/filter/advanced", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+
+		options := parseFilterForm(r.URL.Query(), user.ID)
+		tasks, err := taskManager.FilterTasksAdvancedForUser(user.ID, options)
+		if errors.Is(err, manager.ErrInvalid) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Ошибка загрузки задач", http.StatusInternalServerError)
+			return
+		}
+		if sortOrder := manager.SortOrder(r.URL.Query().Get("sort")); sortOrder.Valid() {
+			manager.SortTasks(tasks, sortOrder)
+		}
+		
+		renderTasks(w, TemplateData{Tasks: tasks, User: user, FilterQuery: r.URL.RawQuery})
+	})
+
+	// Сохранение расширенного фильтра: filter - его строка запроса вместе с sort
+	r.Post("/filters", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Invalid form data", http.StatusBadRequest)
+			return
+		}
+		values, err := url.ParseQuery(r.FormValue("filter"))
+		if err != nil {
+			http.Error(w, "Неверные параметры фильтра", http.StatusBadRequest)
+			return
+		}
+
+		filter, err := filterManager.CreateFilter(user.ID, manager.SavedFilterRequest{
+			Name:    r.FormValue("name"),
+			Options: parseFilterForm(values, user.ID),
+			Sort:    manager.SortOrder(values.Get("sort")),
+		})
+		if err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/filters/%d", filter.ID), http.StatusSeeOther)
+	})
+
+	r.Get("/filters/{id}", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Неверный ID фильтра", http.StatusBadRequest)
+			return
+		}
+
+		filter, tasks, err := filterManager.RunFilter(user.ID, id)
+		if err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
+		}
+		renderTasks(w, TemplateData{Tasks: tasks, User: user, ActiveFilter: filter})
+	})
+
+	r.Post("/filters/delete/{id}", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Неверный ID фильтра", http.StatusBadRequest)
+			return
+		}
+
+		if err := filterManager.DeleteFilter(user.ID, id); err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
+		}
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	})
+
+	r.Post("/projects", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+
+		project, err := taskManager.CreateProjectForUser(user.ID, manager.CreateProjectRequest{
+			Name:  r.FormValue("name"),
+			Color: r.FormValue("color"),
+		})
+		if err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/projects/%d", project.ID), http.StatusSeeOther)
+	})
+
+	r.Get("/projects/{id}", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Неверный ID проекта", http.StatusBadRequest)
+			return
+		}
+
+		project, err := taskManager.GetProjectForUser(user.ID, id)
+		if err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
+		}
+		tasks, err := taskManager.FilterTasksAdvancedForUser(user.ID, manager.FilterOptions{ProjectID: &id})
+		if err != nil {
+			http.Error(w, "Ошибка загрузки задач", http.StatusInternalServerError)
+			return
+		}
+		sortOrder := manager.SortOrder(r.URL.Query().Get("sort"))
+		if !sortOrder.Valid() {
+			sortOrder = manager.SortByDueDate
+		}
+		manager.SortTasks(tasks, sortOrder)
+		members, err := taskManager.GetMembersForUser(user.ID, id)
+		if err != nil {
+			logger.Error(r.Context(), err, "Ошибка загрузки участников проекта", "projectID", id)
+		}
+		renderTasks(w, TemplateData{Tasks: tasks, User: user, ActiveProject: project, Members: members})
+	})
+
+	// Владелец открывает проект пользователю по имени или меняет его роль
+	r.Post("/projects/{id}/members", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Неверный ID проекта", http.StatusBadRequest)
+			return
+		}
+
+		member, err := userManager.GetUserByUsername(r.FormValue("username"))
+		if err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
+		}
+		if _, err := taskManager.ShareProjectForUser(user.ID, id, *member, manager.Role(r.FormValue("role"))); err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/projects/%d", id), http.StatusSeeOther)
+	})
+
+	// Исключение участника владельцем или выход участника из проекта
+	r.Post("/projects/{id}/members/{userID}/delete", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Неверный ID проекта", http.StatusBadRequest)
+			return
+		}
+		memberID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+		if err != nil {
+			http.Error(w, "Неверный ID пользователя", http.StatusBadRequest)
+			return
+		}
+
+		if err := taskManager.RemoveMemberForUser(user.ID, id, memberID); err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
+		}
+		if memberID == user.ID {
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/projects/%d", id), http.StatusSeeOther)
+	})
+
+	// Код приглашения показывается на странице проекта, как код привязки Telegram
+	r.Post("/projects/{id}/invites", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Неверный ID проекта", http.StatusBadRequest)
+			return
+		}
+
+		invite, err := taskManager.CreateProjectInviteForUser(user.ID, id, manager.Role(r.FormValue("role")))
+		if err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(invite)
+	})
+
+	r.Post("/projects/join", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+
+		project, err := taskManager.JoinProjectForUser(*user, r.FormValue("code"))
+		if err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/projects/%d", project.ID), http.StatusSeeOther)
+	})
+
+	r.Post("/projects/update/{id}", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Неверный ID проекта", http.StatusBadRequest)
+			return
+		}
+
+		var req manager.UpdateProjectRequest
+		if name := r.FormValue("name"); name != "" {
+			req.Name = &name
+		}
+		if color := r.FormValue("color"); color != "" {
+			req.Color = &color
+		}
+		if _, err := taskManager.UpdateProjectForUser(user.ID, id, req); err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/projects/%d", id), http.StatusSeeOther)
+	})
+
+	// Массовая архивация: ids - отмеченные в боковой панели проекты,
+	// archived=false возвращает их из архива
+	r.Post("/projects/archive", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Invalid form data", http.StatusBadRequest)
+			return
+		}
+		ids, ok := formIDs(r.PostForm["ids"])
+		if !ok {
+			http.Error(w, "Неверный ID проекта", http.StatusBadRequest)
+			return
+		}
+
+		archived := r.PostForm.Get("archived") != "false"
+		if _, err := taskManager.ArchiveProjectsForUser(user.ID, ids, archived); err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
+		}
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	})
+
+	r.Post("/projects/delete/{id}", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			http.Error(w, "Неверный ID проекта", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := taskManager.DeleteProjectForUser(user.ID, id); err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
+		}
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+	})
+
+	r.Post("/tasks/move", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Invalid form data", http.StatusBadRequest)
+			return
+		}
+		taskIDs, ok := formIDs(r.PostForm["task_ids"])
+		if !ok {
+			http.Error(w, "Неверный ID задачи", http.StatusBadRequest)
+			return
+		}
+		projectID, err := strconv.Atoi(r.PostForm.Get("project_id"))
+		if err != nil {
+			http.Error(w, "Неверный ID проекта", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := taskManager.MoveTasksForUser(user.ID, taskIDs, projectID); err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("/projects/%d", projectID), http.StatusSeeOther)
+	})
+
+	// Полнотекстовый поиск; фильтры - те же поля, что у расширенной фильтрации
+	r.Get("/tasks/search", func(w http.ResponseWriter, r *http.Request) {
+		user, ok := r.Context().Value("user").(*manager.User)
+		if !ok {
+			http.Error(w, "User not found", http.StatusInternalServerError)
+			return
+		}
+
+		query := r.URL.Query().Get("q")
+		results, err := taskManager.SearchForUser(user.ID, query, parseFilterForm(r.URL.Query(), user.ID))
+		if err != nil {
+			http.Error(w, err.Error(), httpStatusForError(err))
+			return
+		}
+
+		data := TemplateData{User: user, Query: query, Tasks: []manager.Task{}, Snippets: make(map[int]template.HTML)}
+		for _, result := range results {
+			data.Tasks = append(data.Tasks, result.Task)
+			data.Snippets[result.Task.ID] = template.HTML(result.Highlight("<mark>", "</mark>", template.HTMLEscapeString))
+		}
+		renderTasks(w, data)
+	})
+
+	return r
+}
+
+func main() {
+	ctx := context.Background()
+	logger.SetLevel(logger.LevelInfo)
+	printWelcomeMessage()
+	logger.Info(ctx, "Starting todo-app server...")
+
+	if err := os.MkdirAll("data", 0755); err != nil {
+		logger.Error(ctx, err, "Ошибка создания директории data")
+		return
+	}
+
+	dbStorage, err := storage.NewSQLiteStorage("./data/todoapp.db")
+	if err != nil {
+		logger.Error(ctx, err, "Ошибка инициализации SQLite хранилища")
+		return
+	}
+	defer dbStorage.Close()
+
+	logger.Info(ctx, "SQLite хранилище успешно инициализировано")
+
+	taskManager := manager.NewTaskManagerWithStorage(dbStorage)
+	userManager := manager.NewUserManager(dbStorage)
+	subTaskManager := manager.NewSubTaskManagerWithStorage(dbStorage)
+	// Доступ к подзадачам проверяется по роли в проекте родительской задачи
+	taskManager.SetSubTaskManager(subTaskManager)
+	reminderManager := manager.NewReminderManagerWithStorage(dbStorage, taskManager)
+	filterManager := manager.NewSavedFilterManagerWithStorage(dbStorage, taskManager)
+	commentManager := manager.NewCommentManagerWithStorage(dbStorage, taskManager)
+	// Файлы вложений лежат рядом с базой, если ATTACHMENTS_DIR не задан
+	attachmentsDir := os.Getenv("ATTACHMENTS_DIR")
+	if attachmentsDir == "" {
+		attachmentsDir = manager.DefaultAttachmentsDir
+	}
+	attachmentManager := manager.NewAttachmentManagerWithStorage(dbStorage, taskManager, attachmentsDir)
+
+	schedulerCtx, stopScheduler := context.WithCancel(ctx)
+	defer stopScheduler()
+	if notifier := reminderNotifier(ctx); notifier != nil {
+		go scheduler.New("todo-app", reminderManager, userManager, notifier).Run(schedulerCtx)
+	} else {
+		logger.Info(ctx, "Планировщик напоминаний не запущен: нет TELEGRAM_BOT_TOKEN и REMINDER_NOTIFIER=log")
+	}
+
+	if days, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS")); err == nil && days > 0 {
+		trashRetention = time.Duration(days) * 24 * time.Hour
+	}
+	go purgeTrash(schedulerCtx, taskManager, attachmentManager, trashRetention)
+
+	if removed, err := userManager.CleanupSessions(); err != nil {
+		logger.Error(ctx, err, "Ошибка очистки истекших сессий")
+	} else if removed > 0 {
+		logger.Info(ctx, "Удалены истекшие сессии", "count", removed)
+	}
+	if removed, err := attachmentManager.PruneBlobs(); err != nil {
+		logger.Error(ctx, err, "Ошибка очистки файлов вложений")
+	} else if removed > 0 {
+		logger.Info(ctx, "Удалены файлы вложений без ссылок", "count", removed)
+	}
+
 	server := &http.Server{
 		Addr:    ":8080",
-		Handler: r,
+		Handler: newRouter(taskManager, subTaskManager, userManager, reminderManager, filterManager, commentManager,
+			attachmentManager),
 	}
 
 	quit := make(chan os.Signal, 1)
@@ -780,6 +1867,7 @@ func main() {
 
 	<-quit
 	logger.Info(ctx, "Shutting down server...")
+	stopScheduler()
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := server.Shutdown(ctx); err != nil {
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"todo-app/internal/manager"
+	"todo-app/internal/openapi"
+)
+
+// Обработчики читают шаблоны из static/ относительно корня репозитория
+func TestMain(m *testing.M) {
+	if err := os.Chdir("../.."); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func newTestRouter(t *testing.T) *chi.Mux {
+	tasks := manager.NewTaskManager()
+	subtasks := manager.NewSubTaskManager()
+	tasks.SetSubTaskManager(subtasks)
+	return newRouter(tasks, subtasks, manager.NewUserManager(nil), manager.NewReminderManager(tasks),
+		manager.NewSavedFilterManager(tasks), manager.NewCommentManager(tasks), manager.NewAttachmentManager(tasks, t.TempDir()))
+}
+
+// TestRouterMatchesSpec проверяет, что каждый маршрут роутера описан
+// в спецификации и в спецификации нет маршрутов, которых нет в роутере
+func TestRouterMatchesSpec(t *testing.T) {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routed := make(map[string]bool)
+	err = chi.Walk(newTestRouter(t), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		routed[method+" "+route] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	specified := make(map[string]bool)
+	for _, route := range doc.Routes() {
+		specified[route.Method+" "+route.Path] = true
+	}
+
+	var undocumented, missing []string
+	for route := range routed {
+		if !specified[route] {
+			undocumented = append(undocumented, route)
+		}
+	}
+	for route := range specified {
+		if !routed[route] {
+			missing = append(missing, route)
+		}
+	}
+	sort.Strings(undocumented)
+	sort.Strings(missing)
+	for _, route := range undocumented {
+		t.Errorf("Маршрут не описан в openapi.json: %s", route)
+	}
+	for _, route := range missing {
+		t.Errorf("В openapi.json есть маршрут, которого нет в роутере: %s", route)
+	}
+}
+
+type webClient struct {
+	t      *testing.T
+	server *httptest.Server
+	client *http.Client
+}
+
+// newWebClient поднимает веб-сервер в памяти со сверкой каждого запроса
+// и ответа со спецификацией. Редиректы не выполняются, чтобы проверять 303.
+func newWebClient(t *testing.T) *webClient {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker := doc.Checker("", func(err error) {
+		t.Errorf("Расхождение со спецификацией: %v", err)
+	})
+	server := httptest.NewServer(checker(newTestRouter(t)))
+	t.Cleanup(server.Close)
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{
+		Jar: jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return &webClient{t: t, server: server, client: client}
+}
+
+// fileUpload - файл для отправки полем file формы multipart/form-data
+type fileUpload struct {
+	name    string
+	content string
+}
+
+// do отправляет форму (form != nil), JSON (строка), файл или пустой запрос
+// и проверяет статус ответа
+func (c *webClient) do(method, path string, body interface{}, wantStatus int) []byte {
+	c.t.Helper()
+	var reader io.Reader
+	contentType := ""
+	switch b := body.(type) {
+	case url.Values:
+		reader = strings.NewReader(b.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	case string:
+		reader = strings.NewReader(b)
+		contentType = "application/json"
+	case fileUpload:
+		var buf bytes.Buffer
+		form := multipart.NewWriter(&buf)
+		part, _ := form.CreateFormFile("file", b.name)
+		io.WriteString(part, b.content)
+		form.Close()
+		reader = &buf
+		contentType = form.FormDataContentType()
+	}
+	req, err := http.NewRequest(method, c.server.URL+path, reader)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != wantStatus {
+		c.t.Fatalf("%s %s: статус %d, ожидался %d: %s", method, path, resp.StatusCode, wantStatus, data)
+	}
+	return data
+}
+
+func TestWebRoutesConformToSpec(t *testing.T) {
+	c := newWebClient(t)
+
+	c.do("GET", "/", nil, http.StatusSeeOther)
+	c.do("GET", "/openapi.json", nil, http.StatusOK)
+	c.do("GET", "/metrics", nil, http.StatusOK)
+	c.do("GET", "/register", nil, http.StatusOK)
+	c.do("POST", "/register", url.Values{
+		"username": {"alice"}, "password": {"password123"}, "password_confirm": {"password123"},
+	}, http.StatusSeeOther)
+	c.do("POST", "/logout", nil, http.StatusSeeOther)
+	c.do("GET", "/login", nil, http.StatusOK)
+	c.do("POST", "/login", url.Values{"login": {"alice"}, "password": {"wrong-password"}}, http.StatusUnauthorized)
+	c.do("POST", "/login", url.Values{"login": {"alice"}, "password": {"password123"}}, http.StatusSeeOther)
+
+	c.do("POST", "/tasks", url.Values{
+		"description": {"Отчет"}, "priority": {"high"}, "due_date": {"2026-10-20"}, "tags": {"работа, срочно"},
+	}, http.StatusSeeOther)
+	c.do("POST", "/tasks", url.Values{"description": {""}}, http.StatusBadRequest)
+	c.do("POST", "/tasks/update/1", url.Values{
+		"description": {"Годовой отчет"}, "priority": {"medium"}, "due_date": {"2026-10-21"}, "tags": {"работа"},
+	}, http.StatusSeeOther)
+	c.do("POST", "/tasks/toggle/1", nil, http.StatusSeeOther)
+	c.do("POST", "/tasks/toggle/2", nil, http.StatusNotFound)
+
+	// Форма, открытая до отметки выполнения, не затирает ее, а предлагает перезаписать
+	var task manager.Task
+	if err := json.Unmarshal(c.do("GET", "/api/v1/tasks/1", nil, http.StatusOK), &task); err != nil {
+		t.Fatal(err)
+	}
+	stale := url.Values{"description": {"Годовой отчет за год"}, "priority": {"medium"}, "due_date": {"2026-10-21"},
+		"tags": {"работа"}, "version": {strconv.Itoa(task.Version - 1)}}
+	if page := c.do("POST", "/tasks/update/1", stale, http.StatusConflict); !strings.Contains(string(page), "изменили в другом месте") ||
+		!strings.Contains(string(page), fmt.Sprintf(`name="version" value="%d"`, task.Version)) {
+		t.Error("На странице конфликта нет предложения перезаписать с текущей версией")
+	}
+	stale.Set("version", strconv.Itoa(task.Version))
+	c.do("POST", "/tasks/update/1", stale, http.StatusSeeOther)
+	stale.Set("description", "Годовой отчет")
+	stale.Del("version")
+	c.do("POST", "/tasks/update/1", stale, http.StatusSeeOther)
+
+	for _, path := range []string{
+		"/",
+		"/tasks/filter/completed",
+		"/tasks/filter/date?start=01.10.2026&end=31.10.2026",
+		"/tasks/filter/advanced?completed=true&priority=medium&tags=работа&has_due_date=true",
+		"/tasks/priority/medium",
+		"/tasks/tag/работа",
+		"/tasks/upcoming/7",
+		"/tasks/search?q=отч*&completed=true",
+		"/tasks/filter/advanced?query=" + url.QueryEscape("tag:работа -is:done OR due<+7d"),
+		"/tasks/filter/advanced?priority=medium&sort=priority",
+	} {
+		c.do("GET", path, nil, http.StatusOK)
+	}
+	c.do("GET", "/tasks/filter/someday", nil, http.StatusBadRequest)
+	c.do("GET", "/tasks/search?q=%2A", nil, http.StatusBadRequest)
+	c.do("GET", "/tasks/filter/advanced?query=priority%3Aurgent", nil, http.StatusBadRequest)
+
+	c.do("POST", "/filters", url.Values{"name": {"Срочное"}, "filter": {"priority=medium&sort=priority"}}, http.StatusSeeOther)
+	c.do("POST", "/filters", url.Values{"name": {"срочное"}, "filter": {"priority=high"}}, http.StatusConflict)
+	c.do("POST", "/filters", url.Values{"name": {"Ошибка"}, "filter": {"query=due%3Csoon"}}, http.StatusBadRequest)
+	if page := c.do("GET", "/filters/1", nil, http.StatusOK); !strings.Contains(string(page), "Срочное") {
+		t.Error("На странице списка нет его названия")
+	}
+	c.do("GET", "/filters/2", nil, http.StatusNotFound)
+	c.do("POST", "/filters/delete/1", nil, http.StatusSeeOther)
+	c.do("GET", "/filters/1", nil, http.StatusNotFound)
+
+	// Первая задача создала Входящие с ID 1, новый проект получает ID 2
+	c.do("POST", "/projects", url.Values{"name": {"Работа"}, "color": {"#ff5722"}}, http.StatusSeeOther)
+	c.do("POST", "/projects", url.Values{"name": {"работа"}}, http.StatusConflict)
+	c.do("POST", "/tasks", url.Values{"description": {"План"}, "project_id": {"2"}}, http.StatusSeeOther)
+	c.do("POST", "/tasks/move", url.Values{"task_ids": {"1", "2"}, "project_id": {"2"}}, http.StatusSeeOther)
+	c.do("POST", "/tasks/move", url.Values{"task_ids": {"1"}, "project_id": {"7"}}, http.StatusNotFound)
+	if page := c.do("GET", "/projects/2", nil, http.StatusOK); !strings.Contains(string(page), "Годовой отчет") {
+		t.Error("Перенесенной задачи нет на странице проекта")
+	}
+	c.do("GET", "/tasks/filter/advanced?project_id=2&sort=created", nil, http.StatusOK)
+	c.do("POST", "/projects/update/2", url.Values{"name": {"Офис"}, "color": {"#2196f3"}}, http.StatusSeeOther)
+	c.do("POST", "/projects/update/1", url.Values{"name": {"Почта"}}, http.StatusBadRequest)
+	c.do("POST", "/projects/archive", url.Values{"ids": {"2"}}, http.StatusSeeOther)
+	c.do("POST", "/tasks/update/1", url.Values{
+		"description": {"Годовой отчет"}, "priority": {"medium"}, "project_id": {"2"},
+	}, http.StatusConflict)
+	c.do("POST", "/projects/archive", url.Values{"ids": {"2"}, "archived": {"false"}}, http.StatusSeeOther)
+	c.do("POST", "/projects/delete/2", nil, http.StatusSeeOther)
+	c.do("POST", "/projects/delete/1", nil, http.StatusBadRequest)
+	c.do("GET", "/projects/2", nil, http.StatusNotFound)
+
+	c.do("POST", "/tasks/1/subtasks", url.Values{"description": {"Собрать цифры"}}, http.StatusOK)
+	var subtasks []manager.SubTask
+	if err := json.Unmarshal(c.do("GET", "/tasks/1/subtasks", nil, http.StatusOK), &subtasks); err != nil || len(subtasks) != 1 {
+		t.Fatalf("Подзадачи: %v, %v", subtasks, err)
+	}
+	c.do("POST", "/subtasks/1/toggle", nil, http.StatusOK)
+	c.do("DELETE", "/subtasks/1", nil, http.StatusOK)
+
+	c.do("POST", "/tasks/1/reminders", `{"days_before": 1, "message": "Скоро срок"}`, http.StatusCreated)
+	var reminders []manager.Reminder
+	if err := json.Unmarshal(c.do("GET", "/tasks/1/reminders", nil, http.StatusOK), &reminders); err != nil || len(reminders) == 0 {
+		t.Fatalf("Напоминания: %v, %v", reminders, err)
+	}
+	c.do("DELETE", "/tasks/1/reminders/1", nil, http.StatusNoContent)
+	c.do("GET", "/reminders/settings", nil, http.StatusOK)
+	c.do("PUT", "/reminders/settings", `{"user_id": 0, "enabled": true, "remind_before_days": 2, "telegram_notifications": true, "push_notifications": false}`,
+		http.StatusOK)
+
+	c.do("POST", "/account/telegram/link-code", nil, http.StatusOK)
+	c.do("POST", "/tasks/delete/1", nil, http.StatusSeeOther)
+	if page := c.do("GET", "/trash", nil, http.StatusOK); !strings.Contains(string(page), "Годовой отчет") {
+		t.Error("Удаленной задачи нет в корзине")
+	}
+	c.do("POST", "/tasks/restore/1", nil, http.StatusSeeOther)
+	c.do("POST", "/tasks/restore/1", nil, http.StatusNotFound)
+	if page := c.do("GET", "/", nil, http.StatusOK); !strings.Contains(string(page), "Годовой отчет") {
+		t.Error("Восстановленной задачи нет в списке")
+	}
+}
+
+// session - второй пользователь на том же сервере со своими cookie
+func (c *webClient) session() *webClient {
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar, CheckRedirect: c.client.CheckRedirect}
+	return &webClient{t: c.t, server: c.server, client: client}
+}
+
+func TestWebProjectSharing(t *testing.T) {
+	alice := newWebClient(t)
+	bob := alice.session()
+	for _, c := range []struct {
+		client   *webClient
+		username string
+	}{{alice, "alice"}, {bob, "bob"}} {
+		c.client.do("POST", "/register", url.Values{
+			"username": {c.username}, "password": {"password123"}, "password_confirm": {"password123"},
+		}, http.StatusSeeOther)
+	}
+
+	// Входящие alice - проект 1, «Семья» - проект 2 с задачей 2
+	alice.do("POST", "/tasks", url.Values{"description": {"Личное"}}, http.StatusSeeOther)
+	alice.do("POST", "/projects", url.Values{"name": {"Семья"}}, http.StatusSeeOther)
+	alice.do("POST", "/tasks", url.Values{"description": {"Купить продукты"}, "project_id": {"2"}}, http.StatusSeeOther)
+
+	bob.do("GET", "/projects/2", nil, http.StatusForbidden)
+	alice.do("POST", "/projects/2/members", url.Values{"username": {"carol"}, "role": {"viewer"}}, http.StatusNotFound)
+	alice.do("POST", "/projects/2/members", url.Values{"username": {"@Bob"}, "role": {"viewer"}}, http.StatusSeeOther)
+	alice.do("POST", "/projects/1/members", url.Values{"username": {"bob"}, "role": {"viewer"}}, http.StatusBadRequest)
+
+	if page := bob.do("GET", "/projects/2", nil, http.StatusOK); !strings.Contains(string(page), "Купить продукты") {
+		t.Error("Участнику не видна задача общего проекта")
+	}
+	if page := bob.do("GET", "/", nil, http.StatusOK); strings.Contains(string(page), "Личное") {
+		t.Error("Участнику видна задача из Входящих владельца")
+	}
+	bob.do("POST", "/tasks/toggle/2", nil, http.StatusForbidden)
+	bob.do("POST", "/projects/2/invites", url.Values{"role": {"editor"}}, http.StatusForbidden)
+
+	var invite manager.ProjectInvite
+	if err := json.Unmarshal(alice.do("POST", "/projects/2/invites", url.Values{"role": {"editor"}}, http.StatusOK), &invite); err != nil {
+		t.Fatal(err)
+	}
+	bob.do("POST", "/projects/join", url.Values{"code": {strings.ToLower(invite.Code)}}, http.StatusSeeOther)
+	bob.do("POST", "/projects/join", url.Values{"code": {invite.Code}}, http.StatusNotFound)
+	bob.do("POST", "/tasks/toggle/2", nil, http.StatusSeeOther)
+	bob.do("POST", "/projects/delete/2", nil, http.StatusForbidden)
+
+	// Исполнитель задается по имени; пустое поле снимает назначение
+	update := url.Values{"description": {"Купить продукты"}, "priority": {"medium"}, "assignee": {"@bob"}}
+	alice.do("POST", "/tasks/update/2", update, http.StatusSeeOther)
+	if page := bob.do("GET", "/tasks/assigned", nil, http.StatusOK); !strings.Contains(string(page), "Купить продукты") {
+		t.Error("Назначенная задача не видна в «Назначено мне»")
+	}
+	update.Set("assignee", "carol")
+	alice.do("POST", "/tasks/update/2", update, http.StatusNotFound)
+	update.Set("assignee", "")
+	alice.do("POST", "/tasks/update/2", update, http.StatusSeeOther)
+	if page := bob.do("GET", "/tasks/assigned?sort=due_date", nil, http.StatusOK); strings.Contains(string(page), "Купить продукты") {
+		t.Error("Снятое назначение осталось в «Назначено мне»")
+	}
+
+	// Обсуждение задачи: участник комментирует, менять может только автор
+	var comment manager.Comment
+	if err := json.Unmarshal(bob.do("POST", "/tasks/2/comments", url.Values{"body": {"Молоко есть?"}}, http.StatusOK), &comment); err != nil {
+		t.Fatal(err)
+	}
+	alice.do("POST", fmt.Sprintf("/comments/%d", comment.ID), url.Values{"body": {"Есть"}}, http.StatusForbidden)
+	bob.do("POST", fmt.Sprintf("/comments/%d", comment.ID), url.Values{"body": {"Молока нет"}}, http.StatusOK)
+	if page := alice.do("GET", "/tasks/2/comments", nil, http.StatusOK); !strings.Contains(string(page), "Молока нет") {
+		t.Errorf("Комментарий не виден владельцу: %s", page)
+	}
+	bob.do("DELETE", fmt.Sprintf("/comments/%d", comment.ID), nil, http.StatusOK)
+
+	// Редактор прикрепляет файл, владелец его скачивает
+	var attachment manager.Attachment
+	upload := fileUpload{name: "список.txt", content: "Молоко, хлеб"}
+	if err := json.Unmarshal(bob.do("POST", "/tasks/2/attachments", upload, http.StatusOK), &attachment); err != nil {
+		t.Fatal(err)
+	}
+	bob.do("POST", "/tasks/1/attachments", upload, http.StatusForbidden)
+	alice.do("POST", "/tasks/2/attachments", fileUpload{name: "a.html", content: "<html></html>"}, http.StatusBadRequest)
+	if page := alice.do("GET", "/tasks/2/attachments", nil, http.StatusOK); !strings.Contains(string(page), "список.txt") {
+		t.Errorf("Вложение не видно владельцу: %s", page)
+	}
+	if file := alice.do("GET", fmt.Sprintf("/attachments/%d", attachment.ID), nil, http.StatusOK); string(file) != upload.content {
+		t.Errorf("Скачан неверный файл: %q", file)
+	}
+	alice.do("DELETE", fmt.Sprintf("/attachments/%d", attachment.ID), nil, http.StatusOK)
+
+	// История показывает, кто и откуда менял задачу
+	var events []manager.TaskEvent
+	if err := json.Unmarshal(bob.do("GET", "/tasks/2/events", nil, http.StatusOK), &events); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) == 0 || events[0].Type != manager.EventUpdated || events[0].UserID != 1 ||
+		events[0].Source != manager.SourceWeb || events[0].Changes[0].Field != "assignee_id" {
+		t.Errorf("Неожиданная история задачи: %+v", events)
+	}
+	bob.do("GET", "/tasks/1/events", nil, http.StatusForbidden)
+
+	bob.do("POST", "/projects/2/members/2/delete", nil, http.StatusSeeOther)
+	bob.do("GET", "/projects/2", nil, http.StatusForbidden)
+}
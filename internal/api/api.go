@@ -0,0 +1,174 @@
+// Package api - версионированный JSON API (/api/v1) для скриптов и мобильного
+// клиента. Работает поверх тех же менеджеров, что и веб-интерфейс.
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"todo-app/internal/logger"
+	"todo-app/internal/manager"
+)
+
+// SessionCookieName - cookie сессии веб-интерфейса. API принимает ее наравне
+// с заголовком Authorization, чтобы страницы могли обращаться к API напрямую.
+const SessionCookieName = "todo_session"
+
+type ctxKey int
+
+const (
+	ctxUser ctxKey = iota
+	ctxToken
+)
+
+// Server обслуживает /api/v1
+type Server struct {
+	tasks       *manager.TaskManager
+	subtasks    *manager.SubTaskManager
+	users       *manager.UserManager
+	filters     *manager.SavedFilterManager
+	comments    *manager.CommentManager
+	attachments *manager.AttachmentManager
+}
+
+func New(tasks *manager.TaskManager, subtasks *manager.SubTaskManager, users *manager.UserManager,
+	filters *manager.SavedFilterManager, comments *manager.CommentManager, attachments *manager.AttachmentManager) *Server {
+	return &Server{tasks: tasks, subtasks: subtasks, users: users, filters: filters, comments: comments,
+		attachments: attachments}
+}
+
+// Handler возвращает роутер API; монтируется в /api/v1
+func (s *Server) Handler() http.Handler {
+	r := chi.NewRouter()
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, codeNotFound, "ресурс не найден")
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "метод не поддерживается")
+	})
+
+	r.Post("/auth/register", s.register)
+	r.Post("/auth/login", s.login)
+
+	r.Group(func(r chi.Router) {
+		r.Use(s.authenticate)
+
+		r.Post("/auth/logout", s.logout)
+
+		r.Get("/users/me", s.me)
+		r.Post("/users/me/telegram/link-code", s.createLinkCode)
+		r.Delete("/users/me/telegram", s.unlinkTelegram)
+
+		r.Get("/tasks", s.listTasks)
+		r.Get("/search", s.search)
+		r.Post("/tasks", s.createTask)
+		r.Get("/tasks/{id}", s.getTask)
+		r.Patch("/tasks/{id}", s.updateTask)
+		r.Delete("/tasks/{id}", s.deleteTask)
+		r.Post("/tasks/{id}/toggle", s.toggleTask)
+		r.Post("/tasks/{id}/restore", s.restoreTask)
+		r.Get("/trash", s.listTrash)
+		r.Patch("/tasks/{id}/series", s.updateSeries)
+		r.Post("/tasks/move", s.moveTasks)
+
+		r.Get("/tasks/{id}/subtasks", s.listSubTasks)
+		r.Post("/tasks/{id}/subtasks", s.createSubTask)
+		r.Post("/subtasks/{id}/toggle", s.toggleSubTask)
+		r.Delete("/subtasks/{id}", s.deleteSubTask)
+
+		r.Get("/tasks/{id}/events", s.listTaskEvents)
+
+		r.Get("/sync", s.listChanges)
+		r.Post("/sync", s.pushChanges)
+		r.Get("/export", s.exportData)
+		r.Post("/import", s.importData)
+
+		r.Get("/tasks/{id}/comments", s.listComments)
+		r.Post("/tasks/{id}/comments", s.createComment)
+		r.Patch("/comments/{id}", s.updateComment)
+		r.Delete("/comments/{id}", s.deleteComment)
+
+		r.Get("/tasks/{id}/attachments", s.listAttachments)
+		r.Post("/tasks/{id}/attachments", s.createAttachment)
+		r.Get("/attachments/{id}", s.downloadAttachment)
+		r.Delete("/attachments/{id}", s.deleteAttachment)
+
+		r.Get("/tags", s.listTags)
+		r.Post("/tags/merge", s.mergeTags)
+		r.Patch("/tags/{name}", s.renameTag)
+		r.Delete("/tags/{name}", s.deleteTag)
+
+		r.Get("/filters", s.listFilters)
+		r.Post("/filters", s.createFilter)
+		r.Get("/filters/{id}", s.getFilter)
+		r.Put("/filters/{id}", s.updateFilter)
+		r.Delete("/filters/{id}", s.deleteFilter)
+		r.Get("/filters/{id}/tasks", s.filterTasks)
+
+		r.Get("/projects", s.listProjects)
+		r.Post("/projects", s.createProject)
+		r.Post("/projects/archive", s.archiveProjects)
+		r.Post("/projects/join", s.joinProject)
+		r.Get("/projects/{id}", s.getProject)
+		r.Patch("/projects/{id}", s.updateProject)
+		r.Delete("/projects/{id}", s.deleteProject)
+		r.Get("/projects/{id}/tasks", s.projectTasks)
+		r.Get("/projects/{id}/members", s.listMembers)
+		r.Post("/projects/{id}/members", s.shareProject)
+		r.Delete("/projects/{id}/members/{userID}", s.removeMember)
+		r.Post("/projects/{id}/invites", s.createInvite)
+	})
+	return r
+}
+
+// authenticate берет токен из "Authorization: Bearer" или из cookie сессии.
+// В отличие от веб-интерфейса, без сессии отвечает 401, а не редиректом.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token == "" {
+			if cookie, err := r.Cookie(SessionCookieName); err == nil {
+				token = cookie.Value
+			}
+		}
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, codeUnauthorized, "требуется авторизация")
+			return
+		}
+
+		user, err := s.users.GetUserBySession(token)
+		if errors.Is(err, manager.ErrNotFound) {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeError(w, http.StatusUnauthorized, codeUnauthorized, "сессия не найдена или истекла")
+			return
+		}
+		if err != nil {
+			logger.Error(r.Context(), err, "Ошибка проверки сессии API")
+			writeError(w, http.StatusInternalServerError, codeInternal, "внутренняя ошибка сервера")
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), ctxUser, user)
+		ctx = context.WithValue(ctx, ctxToken, token)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// currentUser - пользователь, установленный authenticate
+func currentUser(r *http.Request) *manager.User {
+	user, _ := r.Context().Value(ctxUser).(*manager.User)
+	return user
+}
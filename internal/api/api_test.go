@@ -0,0 +1,894 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"todo-app/internal/manager"
+)
+
+type testClient struct {
+	t      *testing.T
+	server *httptest.Server
+	token  string
+	header http.Header // Дополнительные заголовки запросов, см. with
+}
+
+// newTestServer поднимает API в памяти. Каждый запрос и ответ сверяется
+// со спецификацией OpenAPI, поэтому любой тест ловит расхождение с ней.
+func newTestServer(t *testing.T) *httptest.Server {
+	tasks := manager.NewTaskManager()
+	subtasks := manager.NewSubTaskManager()
+	tasks.SetSubTaskManager(subtasks)
+	handler := New(tasks.WithSource(manager.SourceAPI), subtasks.WithSource(manager.SourceAPI),
+		manager.NewUserManager(nil), manager.NewSavedFilterManager(tasks),
+		manager.NewCommentManager(tasks), manager.NewAttachmentManager(tasks, t.TempDir())).Handler()
+	server := httptest.NewServer(specChecker(t)(handler))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// signUp регистрирует пользователя и возвращает клиента с его токеном
+func signUp(t *testing.T, server *httptest.Server, username string) *testClient {
+	c := &testClient{t: t, server: server}
+	var session SessionResponse
+	c.do("POST", "/auth/register", RegisterRequest{Username: username, Password: "password123"}, http.StatusCreated, &session)
+	if session.Token == "" || session.User == nil || session.User.Username != username {
+		t.Fatalf("Неожиданный ответ регистрации: %+v", session)
+	}
+	c.token = session.Token
+	return c
+}
+
+// do выполняет запрос, проверяет статус и разбирает ответ в out
+func (c *testClient) do(method, path string, body interface{}, wantStatus int, out interface{}) *http.Response {
+	c.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.server.URL+path, reader)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	for name, values := range c.header {
+		req.Header[name] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != wantStatus {
+		c.t.Fatalf("%s %s: статус %d, ожидался %d: %s", method, path, resp.StatusCode, wantStatus, data)
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			c.t.Fatalf("%s %s: некорректный JSON %q: %v", method, path, data, err)
+		}
+	}
+	return resp
+}
+
+// with возвращает клиента, который добавляет заголовок к каждому запросу
+func (c *testClient) with(name, value string) *testClient {
+	copy := *c
+	copy.header = http.Header{}
+	copy.header.Set(name, value)
+	return &copy
+}
+
+// expectError проверяет статус и код в конверте ошибки
+func (c *testClient) expectError(method, path string, body interface{}, wantStatus int, wantCode string) {
+	c.t.Helper()
+	var errBody ErrorBody
+	c.do(method, path, body, wantStatus, &errBody)
+	if errBody.Error.Code != wantCode || errBody.Error.Message == "" {
+		c.t.Errorf("%s %s: ошибка %+v, ожидался код %s", method, path, errBody.Error, wantCode)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	server := newTestServer(t)
+	anonymous := &testClient{t: t, server: server}
+	anonymous.expectError("GET", "/tasks", nil, http.StatusUnauthorized, codeUnauthorized)
+
+	alice := signUp(t, server, "alice")
+	anonymous.expectError("POST", "/auth/register", RegisterRequest{Username: "alice", Password: "password123"},
+		http.StatusConflict, codeConflict)
+	anonymous.expectError("POST", "/auth/register", RegisterRequest{Username: "bob", Password: "short"},
+		http.StatusUnprocessableEntity, codeValidation)
+	anonymous.expectError("POST", "/auth/login", LoginRequest{Login: "alice", Password: "wrong-password"},
+		http.StatusUnauthorized, codeUnauthorized)
+
+	var session SessionResponse
+	anonymous.do("POST", "/auth/login", LoginRequest{Login: "alice", Password: "password123"}, http.StatusOK, &session)
+	if session.Token == "" || session.Token == alice.token {
+		t.Errorf("Вход должен выдавать новый токен: %+v", session)
+	}
+
+	var me manager.User
+	alice.do("GET", "/users/me", nil, http.StatusOK, &me)
+	if me.Username != "alice" {
+		t.Errorf("users/me вернул %+v", me)
+	}
+
+	// Cookie веб-интерфейса тоже подходит
+	req, _ := http.NewRequest("GET", server.URL+"/users/me", nil)
+	req.AddCookie(&http.Cookie{Name: SessionCookieName, Value: session.Token})
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Запрос с cookie сессии: статус %d", resp.StatusCode)
+	}
+
+	alice.do("POST", "/auth/logout", nil, http.StatusNoContent, nil)
+	alice.expectError("GET", "/users/me", nil, http.StatusUnauthorized, codeUnauthorized)
+}
+
+func TestTaskLifecycle(t *testing.T) {
+	alice := signUp(t, newTestServer(t), "alice")
+
+	var task Task
+	resp := alice.do("POST", "/tasks", map[string]interface{}{
+		"description": "Отчет",
+		"tags":        []string{"работа", "Работа", " срочно "},
+		"priority":    "high",
+		"due_date":    "2026-10-20T00:00:00Z",
+	}, http.StatusCreated, &task)
+	if resp.Header.Get("Location") != "/api/v1/tasks/1" {
+		t.Errorf("Location = %q", resp.Header.Get("Location"))
+	}
+	if task.ID != 1 || task.Priority != manager.PriorityHigh || task.DueDate == nil || len(task.Tags) != 2 {
+		t.Errorf("Неожиданная задача: %+v", task)
+	}
+
+	var plain map[string]interface{}
+	alice.do("POST", "/tasks", map[string]interface{}{"description": "Без срока"}, http.StatusCreated, &plain)
+	if plain["due_date"] != nil || plain["priority"] != "medium" {
+		t.Errorf("Задача без срока: %v", plain)
+	}
+
+	alice.do("GET", "/tasks/1", nil, http.StatusOK, &task)
+	alice.do("PATCH", "/tasks/1", map[string]interface{}{"description": "Годовой отчет", "completed": true}, http.StatusOK, &task)
+	if task.Description != "Годовой отчет" || !task.Completed {
+		t.Errorf("Изменения не применены: %+v", task)
+	}
+	alice.do("POST", "/tasks/1/toggle", nil, http.StatusOK, &task)
+	if task.Completed {
+		t.Error("toggle должен снять отметку о выполнении")
+	}
+
+	alice.expectError("PATCH", "/tasks/1", map[string]interface{}{"priority": "urgent"}, http.StatusUnprocessableEntity, codeValidation)
+	alice.expectError("PATCH", "/tasks/1", map[string]interface{}{"descripton": "опечатка"}, http.StatusBadRequest, codeBadRequest)
+	alice.expectError("PATCH", "/tasks/1/series", map[string]interface{}{"priority": "low"}, http.StatusUnprocessableEntity, codeValidation)
+	alice.expectError("POST", "/tasks", map[string]interface{}{"description": ""}, http.StatusUnprocessableEntity, codeValidation)
+	alice.expectError("GET", "/tasks/abc", nil, http.StatusBadRequest, codeBadRequest)
+	alice.expectError("GET", "/tasks/99", nil, http.StatusNotFound, codeNotFound)
+
+	// Некорректное правило повторения не оставляет созданной задачи
+	var list TaskList
+	alice.expectError("POST", "/tasks", map[string]interface{}{"description": "Повтор", "recurrence": "FREQ=HOURLY"},
+		http.StatusUnprocessableEntity, codeValidation)
+	alice.do("GET", "/tasks", nil, http.StatusOK, &list)
+	if len(list.Tasks) != 2 {
+		t.Errorf("Ожидалось 2 задачи, получено %d", len(list.Tasks))
+	}
+
+	alice.do("DELETE", "/tasks/1", nil, http.StatusNoContent, nil)
+	alice.expectError("GET", "/tasks/1", nil, http.StatusNotFound, codeNotFound)
+}
+
+func TestContentType(t *testing.T) {
+	alice := signUp(t, newTestServer(t), "alice")
+
+	req, _ := http.NewRequest("POST", alice.server.URL+"/tasks", bytes.NewBufferString("description=Задача"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+alice.token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("Форма вместо JSON: статус %d", resp.StatusCode)
+	}
+
+	alice.expectError("GET", "/unknown", nil, http.StatusNotFound, codeNotFound)
+	alice.expectError("PUT", "/tasks", nil, http.StatusMethodNotAllowed, codeMethodNotAllowed)
+}
+
+func TestTaskFilters(t *testing.T) {
+	alice := signUp(t, newTestServer(t), "alice")
+	for _, body := range []map[string]interface{}{
+		{"description": "A", "tags": []string{"work"}, "priority": "high", "due_date": "2026-10-10T00:00:00Z"},
+		{"description": "B", "tags": []string{"home"}, "due_date": "2026-11-10T00:00:00Z"},
+		{"description": "C", "tags": []string{"work", "home"}},
+	} {
+		alice.do("POST", "/tasks", body, http.StatusCreated, nil)
+	}
+	alice.do("POST", "/tasks/3/toggle", nil, http.StatusOK, nil)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"A", "B", "C"}},
+		{"?completed=true", []string{"C"}},
+		{"?priority=high", []string{"A"}},
+		{"?tags=home", []string{"B", "C"}},
+		{"?tags=work&tags=home", []string{"A", "B", "C"}},
+		{"?has_due_date=false", []string{"C"}},
+		{"?start_date=2026-11-01&end_date=2026-11-30", []string{"B"}},
+		{"?query=" + url.QueryEscape("tag:work -is:done OR due>2026-11-01"), []string{"A", "B"}},
+		{"?query=" + url.QueryEscape(`(tag:home "c") OR priority:high`) + "&has_due_date=false", []string{"C"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			var list TaskList
+			alice.do("GET", "/tasks"+tt.query, nil, http.StatusOK, &list)
+			got := []string{}
+			for _, task := range list.Tasks {
+				got = append(got, task.Description)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Получено %v, ожидалось %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Получено %v, ожидалось %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	alice.expectError("GET", "/tasks?completed=maybe", nil, http.StatusBadRequest, codeBadRequest)
+	alice.expectError("GET", "/tasks?priority=urgent", nil, http.StatusBadRequest, codeBadRequest)
+	alice.expectError("GET", "/tasks?start_date=10.10.2026", nil, http.StatusBadRequest, codeBadRequest)
+	alice.expectError("GET", "/tasks?query=due%3Csoon", nil, http.StatusUnprocessableEntity, codeValidation)
+
+	var tags TagList
+	alice.do("GET", "/tags", nil, http.StatusOK, &tags)
+	if len(tags.Tags) != 2 || tags.Tags[0] != (manager.TagCount{Name: "home", Count: 2}) {
+		t.Errorf("Неожиданные теги: %+v", tags.Tags)
+	}
+}
+
+func TestTagManagement(t *testing.T) {
+	server := newTestServer(t)
+	alice := signUp(t, server, "alice")
+	bob := signUp(t, server, "bob")
+	alice.do("POST", "/tasks", map[string]interface{}{"description": "A", "tags": []string{"работа", "офис"}}, http.StatusCreated, nil)
+	alice.do("POST", "/tasks", map[string]interface{}{"description": "B", "tags": []string{"Офис", "срочно"}}, http.StatusCreated, nil)
+
+	var result TagChangeResult
+	alice.do("PATCH", "/tags/ОФИС", RenameTagRequest{Name: "Офис"}, http.StatusOK, &result)
+	if result.Tasks != 2 {
+		t.Errorf("Переименование: ожидалось 2 задачи, получено %d", result.Tasks)
+	}
+	alice.expectError("PATCH", "/tags/офис", RenameTagRequest{Name: "работа"}, http.StatusConflict, codeConflict)
+	bob.expectError("DELETE", "/tags/офис", nil, http.StatusNotFound, codeNotFound)
+
+	alice.do("POST", "/tags/merge", MergeTagsRequest{Sources: []string{"офис", "срочно"}, Target: "работа"}, http.StatusOK, &result)
+	if result.Tasks != 2 {
+		t.Errorf("Объединение: ожидалось 2 задачи, получено %d", result.Tasks)
+	}
+	alice.expectError("POST", "/tags/merge", MergeTagsRequest{Target: "работа"}, http.StatusUnprocessableEntity, codeValidation)
+
+	var tags TagList
+	alice.do("GET", "/tags", nil, http.StatusOK, &tags)
+	if len(tags.Tags) != 1 || tags.Tags[0] != (manager.TagCount{Name: "работа", Count: 2}) {
+		t.Errorf("Неожиданные теги: %+v", tags.Tags)
+	}
+
+	alice.do("DELETE", "/tags/работа", nil, http.StatusOK, &result)
+	alice.do("GET", "/tags", nil, http.StatusOK, &tags)
+	if result.Tasks != 2 || len(tags.Tags) != 0 {
+		t.Errorf("После удаления: %d задач, теги %+v", result.Tasks, tags.Tags)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	alice := signUp(t, newTestServer(t), "alice")
+	alice.do("POST", "/tasks", map[string]interface{}{"description": "Отчет <черновик>", "priority": "high"}, http.StatusCreated, nil)
+	alice.do("POST", "/tasks", map[string]interface{}{"description": "Отчетность"}, http.StatusCreated, nil)
+
+	var found SearchResults
+	alice.do("GET", "/search?q=отчет*&priority=high", nil, http.StatusOK, &found)
+	if len(found.Results) != 1 || found.Results[0].Snippet != "<mark>Отчет</mark> &lt;черновик&gt;" {
+		t.Errorf("Неожиданный результат: %+v", found.Results)
+	}
+	alice.do("GET", "/search?q=квартал", nil, http.StatusOK, &found)
+	if len(found.Results) != 0 {
+		t.Errorf("Ожидался пустой результат, получено %+v", found.Results)
+	}
+	alice.expectError("GET", "/search?q=", nil, http.StatusUnprocessableEntity, codeValidation)
+	alice.expectError("GET", "/search?q=отчет&completed=maybe", nil, http.StatusBadRequest, codeBadRequest)
+}
+
+func TestSavedFilters(t *testing.T) {
+	server := newTestServer(t)
+	alice := signUp(t, server, "alice")
+	bob := signUp(t, server, "bob")
+	for _, body := range []map[string]interface{}{
+		{"description": "A", "tags": []string{"work"}, "priority": "low", "due_date": "2026-10-10T00:00:00Z"},
+		{"description": "B", "tags": []string{"work"}, "priority": "high", "due_date": "2026-11-10T00:00:00Z"},
+		{"description": "C", "tags": []string{"home"}, "priority": "high"},
+	} {
+		alice.do("POST", "/tasks", body, http.StatusCreated, nil)
+	}
+
+	var filter manager.SavedFilter
+	resp := alice.do("POST", "/filters", manager.SavedFilterRequest{
+		Name:    "Работа",
+		Options: manager.FilterOptions{Query: "tag:work OR priority:high"},
+		Sort:    manager.SortByPriority,
+	}, http.StatusCreated, &filter)
+	if resp.Header.Get("Location") != "/api/v1/filters/1" || filter.Sort != manager.SortByPriority {
+		t.Errorf("Неожиданный ответ: %s %+v", resp.Header.Get("Location"), filter)
+	}
+	alice.expectError("POST", "/filters", manager.SavedFilterRequest{Name: "работа"}, http.StatusConflict, codeConflict)
+	alice.expectError("POST", "/filters", manager.SavedFilterRequest{Name: "Сорт", Sort: "name"}, http.StatusUnprocessableEntity, codeValidation)
+	alice.expectError("POST", "/filters", manager.SavedFilterRequest{
+		Name: "Запрос", Options: manager.FilterOptions{Query: "due<soon"},
+	}, http.StatusUnprocessableEntity, codeValidation)
+
+	descriptions := func(list TaskList) string {
+		var got []string
+		for _, task := range list.Tasks {
+			got = append(got, task.Description)
+		}
+		return strings.Join(got, " ")
+	}
+	var list TaskList
+	alice.do("GET", "/filters/1/tasks", nil, http.StatusOK, &list)
+	if got := descriptions(list); got != "B C A" {
+		t.Errorf("Задачи фильтра по приоритету: %s", got)
+	}
+	alice.do("GET", "/tasks?sort=priority", nil, http.StatusOK, &list)
+	if got := descriptions(list); got != "B C A" {
+		t.Errorf("Список по приоритету: %s", got)
+	}
+	alice.expectError("GET", "/tasks?sort=name", nil, http.StatusBadRequest, codeBadRequest)
+
+	alice.do("PUT", "/filters/1", manager.SavedFilterRequest{Name: "Дом", Options: manager.FilterOptions{Tags: []string{"home"}}}, http.StatusOK, &filter)
+	alice.do("GET", "/filters/1/tasks", nil, http.StatusOK, &list)
+	if filter.Sort != manager.SortByDueDate || descriptions(list) != "C" {
+		t.Errorf("После замены: %+v, задачи %s", filter, descriptions(list))
+	}
+
+	var filters SavedFilterList
+	alice.do("GET", "/filters", nil, http.StatusOK, &filters)
+	if len(filters.Filters) != 1 || filters.Filters[0].Name != "Дом" {
+		t.Errorf("Неожиданные фильтры: %+v", filters.Filters)
+	}
+	bob.do("GET", "/filters", nil, http.StatusOK, &filters)
+	if len(filters.Filters) != 0 {
+		t.Errorf("Чужие фильтры не должны быть видны: %+v", filters.Filters)
+	}
+	bob.expectError("GET", "/filters/1/tasks", nil, http.StatusForbidden, codeForbidden)
+	bob.expectError("DELETE", "/filters/1", nil, http.StatusForbidden, codeForbidden)
+
+	alice.do("DELETE", "/filters/1", nil, http.StatusNoContent, nil)
+	alice.expectError("GET", "/filters/1", nil, http.StatusNotFound, codeNotFound)
+}
+
+func TestProjects(t *testing.T) {
+	server := newTestServer(t)
+	alice := signUp(t, server, "alice")
+	bob := signUp(t, server, "bob")
+
+	var inboxTask Task
+	alice.do("POST", "/tasks", map[string]interface{}{"description": "Разобрать"}, http.StatusCreated, &inboxTask)
+
+	var work manager.Project
+	resp := alice.do("POST", "/projects", manager.CreateProjectRequest{Name: "Работа", Color: "#FF5722"}, http.StatusCreated, &work)
+	if resp.Header.Get("Location") != fmt.Sprintf("/api/v1/projects/%d", work.ID) || work.Color != "#ff5722" {
+		t.Errorf("Неожиданный ответ: %s %+v", resp.Header.Get("Location"), work)
+	}
+	alice.expectError("POST", "/projects", manager.CreateProjectRequest{Name: "РАБОТА"}, http.StatusConflict, codeConflict)
+	alice.expectError("POST", "/projects", manager.CreateProjectRequest{Name: "Цвет", Color: "red"}, http.StatusUnprocessableEntity, codeValidation)
+
+	var report Task
+	alice.do("POST", "/tasks", map[string]interface{}{"description": "Отчет", "project_id": work.ID}, http.StatusCreated, &report)
+	if report.ProjectID != work.ID || inboxTask.ProjectID == work.ID || inboxTask.ProjectID == 0 {
+		t.Errorf("Ожидались задачи во Входящих и в проекте: %+v, %+v", inboxTask, report)
+	}
+	bob.expectError("POST", "/tasks", map[string]interface{}{"description": "Чужой", "project_id": work.ID}, http.StatusForbidden, codeForbidden)
+
+	var list TaskList
+	alice.do("GET", fmt.Sprintf("/projects/%d/tasks", work.ID), nil, http.StatusOK, &list)
+	if len(list.Tasks) != 1 || list.Tasks[0].ID != report.ID {
+		t.Errorf("Задачи проекта: %+v", list.Tasks)
+	}
+	alice.do("GET", fmt.Sprintf("/tasks?project_id=%d", inboxTask.ProjectID), nil, http.StatusOK, &list)
+	if len(list.Tasks) != 1 || list.Tasks[0].ID != inboxTask.ID {
+		t.Errorf("Фильтр по проекту: %+v", list.Tasks)
+	}
+	alice.expectError("GET", "/tasks?project_id=inbox", nil, http.StatusBadRequest, codeBadRequest)
+	bob.expectError("GET", fmt.Sprintf("/projects/%d/tasks", work.ID), nil, http.StatusForbidden, codeForbidden)
+
+	var moved MoveTasksResult
+	alice.do("POST", "/tasks/move", MoveTasksRequest{TaskIDs: []int{inboxTask.ID, report.ID}, ProjectID: work.ID}, http.StatusOK, &moved)
+	if moved.Tasks != 1 {
+		t.Errorf("Ожидался перенос 1 задачи, получено %d", moved.Tasks)
+	}
+	bob.expectError("POST", "/tasks/move", MoveTasksRequest{TaskIDs: []int{report.ID}, ProjectID: work.ID}, http.StatusForbidden, codeForbidden)
+
+	var projects ProjectList
+	alice.do("GET", "/projects", nil, http.StatusOK, &projects)
+	if len(projects.Projects) != 2 || !projects.Projects[0].Inbox || projects.Projects[1].OpenTasks != 2 {
+		t.Errorf("Неожиданные проекты: %+v", projects.Projects)
+	}
+	inbox := projects.Projects[0]
+	alice.expectError("PATCH", fmt.Sprintf("/projects/%d", inbox.ID), map[string]interface{}{"name": "Почта"}, http.StatusUnprocessableEntity, codeValidation)
+	alice.expectError("DELETE", fmt.Sprintf("/projects/%d", inbox.ID), nil, http.StatusUnprocessableEntity, codeValidation)
+
+	var archived ArchiveProjectsResult
+	alice.expectError("POST", "/projects/archive", ArchiveProjectsRequest{IDs: []int{work.ID, inbox.ID}, Archived: true}, http.StatusUnprocessableEntity, codeValidation)
+	alice.do("POST", "/projects/archive", ArchiveProjectsRequest{IDs: []int{work.ID}, Archived: true}, http.StatusOK, &archived)
+	if archived.Projects != 1 {
+		t.Errorf("Ожидалась архивация 1 проекта, получено %d", archived.Projects)
+	}
+	alice.do("GET", "/projects?archived=false", nil, http.StatusOK, &projects)
+	if len(projects.Projects) != 1 || !projects.Projects[0].Inbox {
+		t.Errorf("Без архива ожидались только Входящие: %+v", projects.Projects)
+	}
+	alice.expectError("POST", "/tasks/move", MoveTasksRequest{TaskIDs: []int{report.ID}, ProjectID: work.ID}, http.StatusConflict, codeConflict)
+
+	alice.do("PATCH", fmt.Sprintf("/projects/%d", work.ID), map[string]interface{}{"name": "Старая работа", "archived": false}, http.StatusOK, &work)
+	if work.Name != "Старая работа" || work.Archived {
+		t.Errorf("Неожиданный проект после изменения: %+v", work)
+	}
+	alice.do("DELETE", fmt.Sprintf("/projects/%d", work.ID), nil, http.StatusOK, &moved)
+	if moved.Tasks != 2 {
+		t.Errorf("Ожидался перенос 2 задач во Входящие, получено %d", moved.Tasks)
+	}
+	alice.expectError("GET", fmt.Sprintf("/projects/%d", work.ID), nil, http.StatusNotFound, codeNotFound)
+	alice.do("GET", fmt.Sprintf("/tasks/%d", report.ID), nil, http.StatusOK, &report)
+	if report.ProjectID != inbox.ID {
+		t.Errorf("Задача удаленного проекта должна перейти во Входящие: %+v", report)
+	}
+}
+
+func TestProjectMembers(t *testing.T) {
+	server := newTestServer(t)
+	alice := signUp(t, server, "alice")
+	bob := signUp(t, server, "bob")
+	carol := signUp(t, server, "carol")
+
+	var family manager.Project
+	alice.do("POST", "/projects", manager.CreateProjectRequest{Name: "Семья"}, http.StatusCreated, &family)
+	if family.Role != manager.RoleOwner || family.Members != 0 {
+		t.Errorf("Новый проект: %+v", family)
+	}
+	var task Task
+	alice.do("POST", "/tasks", map[string]interface{}{"description": "Купить продукты", "project_id": family.ID}, http.StatusCreated, &task)
+
+	members := fmt.Sprintf("/projects/%d/members", family.ID)
+	var member manager.Member
+	alice.do("POST", members, ShareProjectRequest{Username: "@Bob", Role: manager.RoleViewer}, http.StatusOK, &member)
+	if member.Username != "bob" || member.Role != manager.RoleViewer {
+		t.Errorf("Неожиданный участник: %+v", member)
+	}
+	alice.expectError("POST", members, ShareProjectRequest{Username: "dave", Role: manager.RoleViewer}, http.StatusNotFound, codeNotFound)
+	alice.expectError("POST", members, ShareProjectRequest{Username: "bob", Role: "admin"}, http.StatusUnprocessableEntity, codeValidation)
+	bob.expectError("POST", members, ShareProjectRequest{Username: "carol", Role: manager.RoleEditor}, http.StatusForbidden, codeForbidden)
+
+	var list TaskList
+	bob.do("GET", "/tasks", nil, http.StatusOK, &list)
+	if len(list.Tasks) != 1 || list.Tasks[0].ID != task.ID {
+		t.Errorf("Участнику должна быть видна задача общего проекта: %+v", list.Tasks)
+	}
+	bob.expectError("PATCH", fmt.Sprintf("/tasks/%d", task.ID), map[string]interface{}{"description": "Хлеб"}, http.StatusForbidden, codeForbidden)
+	carol.expectError("GET", fmt.Sprintf("/tasks/%d", task.ID), nil, http.StatusForbidden, codeForbidden)
+
+	var invite manager.ProjectInvite
+	alice.do("POST", fmt.Sprintf("/projects/%d/invites", family.ID), CreateInviteRequest{Role: manager.RoleEditor}, http.StatusCreated, &invite)
+	var joined manager.Project
+	carol.do("POST", "/projects/join", JoinProjectRequest{Code: invite.Code}, http.StatusOK, &joined)
+	if joined.ID != family.ID || joined.Role != manager.RoleEditor {
+		t.Errorf("Проект после присоединения: %+v", joined)
+	}
+	bob.expectError("POST", "/projects/join", JoinProjectRequest{Code: invite.Code}, http.StatusNotFound, codeNotFound)
+	carol.do("PATCH", fmt.Sprintf("/tasks/%d", task.ID), map[string]interface{}{"description": "Купить хлеб"}, http.StatusOK, &task)
+
+	var memberList MemberList
+	bob.do("GET", members, nil, http.StatusOK, &memberList)
+	if len(memberList.Members) != 3 || memberList.Members[0].Role != manager.RoleOwner {
+		t.Errorf("Неожиданные участники: %+v", memberList.Members)
+	}
+	carol.expectError("DELETE", fmt.Sprintf("%s/%d", members, member.UserID), nil, http.StatusForbidden, codeForbidden)
+	alice.do("DELETE", fmt.Sprintf("%s/%d", members, member.UserID), nil, http.StatusNoContent, nil)
+	carol.do("DELETE", fmt.Sprintf("%s/%d", members, memberList.Members[2].UserID), nil, http.StatusNoContent, nil)
+	carol.expectError("GET", fmt.Sprintf("/projects/%d", family.ID), nil, http.StatusForbidden, codeForbidden)
+}
+
+func TestTaskAssignees(t *testing.T) {
+	server := newTestServer(t)
+	alice := signUp(t, server, "alice")
+	bob := signUp(t, server, "bob")
+	carol := signUp(t, server, "carol")
+
+	var family manager.Project
+	alice.do("POST", "/projects", manager.CreateProjectRequest{Name: "Семья"}, http.StatusCreated, &family)
+	members := fmt.Sprintf("/projects/%d/members", family.ID)
+	var editor, viewer manager.Member
+	alice.do("POST", members, ShareProjectRequest{Username: "bob", Role: manager.RoleEditor}, http.StatusOK, &editor)
+	alice.do("POST", members, ShareProjectRequest{Username: "carol", Role: manager.RoleViewer}, http.StatusOK, &viewer)
+
+	var task Task
+	alice.do("POST", "/tasks", map[string]interface{}{"description": "Купить продукты", "project_id": family.ID, "assignee_id": editor.UserID}, http.StatusCreated, &task)
+	if task.AssigneeID != editor.UserID {
+		t.Errorf("Исполнитель не сохранен: %+v", task)
+	}
+	alice.expectError("POST", "/tasks", map[string]interface{}{"description": "Полить цветы", "project_id": family.ID, "assignee_id": viewer.UserID}, http.StatusUnprocessableEntity, codeValidation)
+	var other Task
+	alice.do("POST", "/tasks", map[string]interface{}{"description": "Полить цветы", "project_id": family.ID}, http.StatusCreated, &other)
+
+	var list TaskList
+	bob.do("GET", "/tasks?assignee=me", nil, http.StatusOK, &list)
+	if len(list.Tasks) != 1 || list.Tasks[0].ID != task.ID {
+		t.Errorf("Назначено bob: %+v", list.Tasks)
+	}
+	carol.do("GET", "/tasks?assignee=none", nil, http.StatusOK, &list)
+	if len(list.Tasks) != 1 || list.Tasks[0].ID != other.ID {
+		t.Errorf("Без исполнителя: %+v", list.Tasks)
+	}
+	alice.expectError("GET", "/tasks?assignee=anyone", nil, http.StatusBadRequest, codeBadRequest)
+
+	path := fmt.Sprintf("/tasks/%d", task.ID)
+	carol.expectError("PATCH", path, map[string]interface{}{"assignee_id": viewer.UserID}, http.StatusForbidden, codeForbidden)
+	var unassigned Task
+	bob.do("PATCH", path, map[string]interface{}{"assignee_id": 0}, http.StatusOK, &unassigned)
+	if unassigned.AssigneeID != 0 {
+		t.Errorf("Назначение не снято: %+v", unassigned)
+	}
+}
+
+func TestComments(t *testing.T) {
+	server := newTestServer(t)
+	alice := signUp(t, server, "alice")
+	bob := signUp(t, server, "bob")
+
+	var task Task
+	alice.do("POST", "/tasks", map[string]interface{}{"description": "Подготовить отчет"}, http.StatusCreated, &task)
+	comments := fmt.Sprintf("/tasks/%d/comments", task.ID)
+
+	var comment manager.Comment
+	resp := alice.do("POST", comments, CommentRequest{Body: "Нужны цифры за март"}, http.StatusCreated, &comment)
+	if comment.Username != "alice" || resp.Header.Get("Location") != fmt.Sprintf("/api/v1/comments/%d", comment.ID) {
+		t.Errorf("Неожиданный комментарий: %+v, Location %q", comment, resp.Header.Get("Location"))
+	}
+	alice.expectError("POST", comments, CommentRequest{Body: " "}, http.StatusUnprocessableEntity, codeValidation)
+	bob.expectError("GET", comments, nil, http.StatusForbidden, codeForbidden)
+	bob.expectError("POST", comments, CommentRequest{Body: "Чужое"}, http.StatusForbidden, codeForbidden)
+
+	path := fmt.Sprintf("/comments/%d", comment.ID)
+	bob.expectError("PATCH", path, CommentRequest{Body: "Чужое"}, http.StatusForbidden, codeForbidden)
+	alice.do("PATCH", path, CommentRequest{Body: "Нужны цифры за апрель"}, http.StatusOK, &comment)
+
+	var list CommentList
+	alice.do("GET", comments, nil, http.StatusOK, &list)
+	if len(list.Comments) != 1 || list.Comments[0].Body != "Нужны цифры за апрель" {
+		t.Errorf("Неожиданные комментарии: %+v", list.Comments)
+	}
+	alice.do("DELETE", path, nil, http.StatusNoContent, nil)
+	alice.expectError("DELETE", path, nil, http.StatusNotFound, codeNotFound)
+}
+
+func TestTaskEvents(t *testing.T) {
+	server := newTestServer(t)
+	alice := signUp(t, server, "alice")
+	bob := signUp(t, server, "bob")
+
+	var task Task
+	alice.do("POST", "/tasks", map[string]interface{}{"description": "Подготовить отчет"}, http.StatusCreated, &task)
+	alice.do("PATCH", fmt.Sprintf("/tasks/%d", task.ID), map[string]interface{}{"priority": "high"}, http.StatusOK, nil)
+	events := fmt.Sprintf("/tasks/%d/events", task.ID)
+
+	var list TaskEventList
+	alice.do("GET", events, nil, http.StatusOK, &list)
+	if len(list.Events) != 2 || list.Events[0].Type != manager.EventUpdated || list.Events[1].Type != manager.EventCreated {
+		t.Fatalf("Неожиданная история: %+v", list.Events)
+	}
+	change := list.Events[0].Changes
+	if list.Events[0].Source != manager.SourceAPI || len(change) != 1 || change[0].Field != "priority" || change[0].New != "high" {
+		t.Errorf("Неожиданное изменение: %+v", list.Events[0])
+	}
+	bob.expectError("GET", events, nil, http.StatusForbidden, codeForbidden)
+	alice.expectError("GET", "/tasks/99/events", nil, http.StatusNotFound, codeNotFound)
+}
+
+func TestTrash(t *testing.T) {
+	server := newTestServer(t)
+	alice := signUp(t, server, "alice")
+	bob := signUp(t, server, "bob")
+
+	var task Task
+	alice.do("POST", "/tasks", map[string]interface{}{"description": "Подготовить отчет"}, http.StatusCreated, &task)
+	path := fmt.Sprintf("/tasks/%d", task.ID)
+	alice.do("DELETE", path, nil, http.StatusNoContent, nil)
+	alice.expectError("GET", path, nil, http.StatusNotFound, codeNotFound)
+
+	var trash TaskList
+	alice.do("GET", "/trash", nil, http.StatusOK, &trash)
+	if len(trash.Tasks) != 1 || trash.Tasks[0].ID != task.ID || trash.Tasks[0].DeletedAt == nil {
+		t.Fatalf("Неожиданная корзина: %+v", trash.Tasks)
+	}
+	bob.do("GET", "/trash", nil, http.StatusOK, &trash)
+	if len(trash.Tasks) != 0 {
+		t.Errorf("Чужая корзина видна: %+v", trash.Tasks)
+	}
+	bob.expectError("POST", path+"/restore", nil, http.StatusForbidden, codeForbidden)
+
+	var restored Task
+	alice.do("POST", path+"/restore", nil, http.StatusOK, &restored)
+	if restored.ID != task.ID || restored.DeletedAt != nil {
+		t.Errorf("Неожиданная восстановленная задача: %+v", restored)
+	}
+	alice.expectError("POST", path+"/restore", nil, http.StatusNotFound, codeNotFound)
+	alice.do("GET", path, nil, http.StatusOK, nil)
+}
+
+func TestOptimisticConcurrency(t *testing.T) {
+	server := newTestServer(t)
+	alice := signUp(t, server, "alice")
+
+	var task Task
+	alice.do("POST", "/tasks", map[string]interface{}{"description": "Отчет"}, http.StatusCreated, &task)
+	path := fmt.Sprintf("/tasks/%d", task.ID)
+	etag := alice.do("GET", path, nil, http.StatusOK, nil).Header.Get("ETag")
+	if etag != fmt.Sprintf("%q", fmt.Sprint(task.Version)) {
+		t.Fatalf("ETag %q не соответствует версии %d", etag, task.Version)
+	}
+
+	// Правка с актуальным ETag проходит и возвращает новый
+	resp := alice.with("If-Match", etag).do("PATCH", path, map[string]interface{}{"priority": "high"}, http.StatusOK, &task)
+	if resp.Header.Get("ETag") == etag || resp.Header.Get("ETag") != fmt.Sprintf("%q", fmt.Sprint(task.Version)) {
+		t.Errorf("ETag после изменения: %q, версия %d", resp.Header.Get("ETag"), task.Version)
+	}
+
+	// Устаревший ETag - 412 с текущим ETag, устаревшая версия в теле - 409
+	var errBody ErrorBody
+	resp = alice.with("If-Match", etag).do("PATCH", path, map[string]interface{}{"priority": "low"}, http.StatusPreconditionFailed, &errBody)
+	if errBody.Error.Code != codePrecondition || resp.Header.Get("ETag") != fmt.Sprintf("%q", fmt.Sprint(task.Version)) {
+		t.Errorf("Неожиданный ответ на устаревший ETag: %+v, ETag %q", errBody.Error, resp.Header.Get("ETag"))
+	}
+	alice.expectError("PATCH", path, map[string]interface{}{"priority": "low", "version": 1}, http.StatusConflict, codeConflict)
+	alice.with("If-Match", "W/1").expectError("PATCH", path, map[string]interface{}{"priority": "low"}, http.StatusBadRequest, codeBadRequest)
+
+	// Без условия последнее изменение побеждает
+	alice.with("If-Match", "*").do("PATCH", path, map[string]interface{}{"priority": "low"}, http.StatusOK, &task)
+	if task.Priority != manager.PriorityLow {
+		t.Errorf("Безусловное изменение не применено: %+v", task)
+	}
+}
+
+// upload отправляет файл полем file формы multipart/form-data
+func (c *testClient) upload(path, filename string, content []byte, wantStatus int, out interface{}) *http.Response {
+	c.t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", filename)
+	part.Write(content)
+	form.Close()
+
+	req, err := http.NewRequest("POST", c.server.URL+path, &body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != wantStatus {
+		c.t.Fatalf("POST %s: статус %d, ожидался %d: %s", path, resp.StatusCode, wantStatus, data)
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			c.t.Fatalf("POST %s: некорректный JSON %q: %v", path, data, err)
+		}
+	}
+	return resp
+}
+
+func TestAttachments(t *testing.T) {
+	server := newTestServer(t)
+	alice := signUp(t, server, "alice")
+	bob := signUp(t, server, "bob")
+
+	var task Task
+	alice.do("POST", "/tasks", map[string]interface{}{"description": "Оплатить счет"}, http.StatusCreated, &task)
+	attachments := fmt.Sprintf("/tasks/%d/attachments", task.ID)
+
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	var attachment manager.Attachment
+	resp := alice.upload(attachments, "скриншот.png", png, http.StatusCreated, &attachment)
+	if attachment.ContentType != "image/png" || attachment.Filename != "скриншот.png" ||
+		resp.Header.Get("Location") != fmt.Sprintf("/api/v1/attachments/%d", attachment.ID) {
+		t.Errorf("Неожиданное вложение: %+v, Location %q", attachment, resp.Header.Get("Location"))
+	}
+	var errBody ErrorBody
+	alice.upload(attachments, "page.html", []byte("<html><script>alert(1)</script>"), http.StatusUnprocessableEntity, &errBody)
+	if errBody.Error.Code != codeValidation {
+		t.Errorf("HTML: ожидался код %s, получено %+v", codeValidation, errBody.Error)
+	}
+	bob.upload(attachments, "чужой.png", png, http.StatusForbidden, nil)
+	alice.expectError("POST", attachments, CommentRequest{Body: "не файл"}, http.StatusUnsupportedMediaType, codeUnsupportedMedia)
+
+	var list AttachmentList
+	alice.do("GET", attachments, nil, http.StatusOK, &list)
+	if len(list.Attachments) != 1 || list.Attachments[0].ID != attachment.ID {
+		t.Errorf("Неожиданные вложения: %+v", list.Attachments)
+	}
+
+	path := fmt.Sprintf("/attachments/%d", attachment.ID)
+	resp = alice.do("GET", path, nil, http.StatusOK, nil)
+	if resp.Header.Get("Content-Type") != "image/png" || resp.Header.Get("X-Content-Type-Options") != "nosniff" ||
+		!strings.HasPrefix(resp.Header.Get("Content-Disposition"), "inline;") {
+		t.Errorf("Неожиданные заголовки скачивания: %v", resp.Header)
+	}
+	bob.expectError("GET", path, nil, http.StatusForbidden, codeForbidden)
+	bob.expectError("DELETE", path, nil, http.StatusForbidden, codeForbidden)
+	alice.do("DELETE", path, nil, http.StatusNoContent, nil)
+	alice.expectError("GET", path, nil, http.StatusNotFound, codeNotFound)
+}
+
+func TestSubTasksAndIsolation(t *testing.T) {
+	server := newTestServer(t)
+	alice := signUp(t, server, "alice")
+	bob := signUp(t, server, "bob")
+
+	alice.do("POST", "/tasks", map[string]interface{}{"description": "Переезд"}, http.StatusCreated, nil)
+
+	var subtask manager.SubTask
+	alice.do("POST", "/tasks/1/subtasks", CreateSubTaskRequest{Description: "Коробки"}, http.StatusCreated, &subtask)
+	if subtask.TaskID != 1 || subtask.Description != "Коробки" {
+		t.Errorf("Неожиданная подзадача: %+v", subtask)
+	}
+	alice.do("POST", "/subtasks/1/toggle", nil, http.StatusNoContent, nil)
+
+	var list SubTaskList
+	alice.do("GET", "/tasks/1/subtasks", nil, http.StatusOK, &list)
+	if len(list.SubTasks) != 1 || !list.SubTasks[0].Completed {
+		t.Errorf("Неожиданные подзадачи: %+v", list.SubTasks)
+	}
+
+	bob.expectError("GET", "/tasks/1", nil, http.StatusForbidden, codeForbidden)
+	bob.expectError("POST", "/tasks/1/subtasks", CreateSubTaskRequest{Description: "Чужая"}, http.StatusForbidden, codeForbidden)
+	bob.expectError("DELETE", "/subtasks/1", nil, http.StatusForbidden, codeForbidden)
+	alice.expectError("POST", "/tasks/7/subtasks", CreateSubTaskRequest{Description: "Нет задачи"}, http.StatusNotFound, codeNotFound)
+
+	var tasks TaskList
+	bob.do("GET", "/tasks", nil, http.StatusOK, &tasks)
+	if len(tasks.Tasks) != 0 {
+		t.Errorf("Бобу видны чужие задачи: %+v", tasks.Tasks)
+	}
+
+	alice.do("DELETE", "/subtasks/1", nil, http.StatusNoContent, nil)
+}
+
+func TestSync(t *testing.T) {
+	server := newTestServer(t)
+	alice := signUp(t, server, "alice")
+
+	var task Task
+	alice.do("POST", "/tasks", map[string]interface{}{"description": "Отчет"}, http.StatusCreated, &task)
+	var snapshot ChangeSet
+	alice.do("GET", "/sync", nil, http.StatusOK, &snapshot)
+	if len(snapshot.Tasks) != 1 || snapshot.Tasks[0].ID != task.ID || snapshot.Tasks[0].DueDate != nil || snapshot.Next == 0 {
+		t.Fatalf("Снимок: %+v", snapshot)
+	}
+
+	// Клиент без связи создал задачу с подзадачей и правил старую версию отчета
+	alice.do("PATCH", fmt.Sprintf("/tasks/%d", task.ID), map[string]interface{}{"priority": "high"}, http.StatusOK, nil)
+	var pushed PushResponse
+	alice.do("POST", "/sync", map[string]interface{}{"changes": []map[string]interface{}{
+		{"op": "create_task", "client_id": "c1", "changes": map[string]interface{}{"description": "Позвонить", "tags": []string{"дом"}}},
+		{"op": "create_subtask", "task_client_id": "c1", "changes": map[string]interface{}{"description": "Найти номер"}},
+		{"op": "update_task", "task_id": task.ID, "version": task.Version, "changes": map[string]interface{}{"completed": true}},
+		{"op": "delete_subtask", "subtask_id": 42},
+	}}, http.StatusOK, &pushed)
+	if len(pushed.Results) != 4 {
+		t.Fatalf("Итоги пакета: %+v", pushed)
+	}
+	created := pushed.Results[0]
+	if created.Status != manager.ChangeApplied || created.ClientID != "c1" || created.Task == nil || created.Task.Tags[0] != "дом" {
+		t.Errorf("Создание задачи: %+v", created)
+	}
+	if conflict := pushed.Results[2]; conflict.Status != manager.ChangeConflict || conflict.Error.Code != codeConflict ||
+		conflict.Task == nil || conflict.Task.Priority != manager.PriorityHigh {
+		t.Errorf("Конфликт версий: %+v", conflict)
+	}
+	if rejected := pushed.Results[3]; rejected.Status != manager.ChangeRejected || rejected.Error.Code != codeNotFound {
+		t.Errorf("Отказ: %+v", rejected)
+	}
+
+	var changes ChangeSet
+	alice.do("GET", fmt.Sprintf("/sync?since=%d", snapshot.Next), nil, http.StatusOK, &changes)
+	if len(changes.Tasks) != 2 || len(changes.SubTasks) != 1 || changes.SubTasks[0].TaskID != created.TaskID {
+		t.Errorf("Изменения после снимка: %+v", changes)
+	}
+
+	alice.do("DELETE", fmt.Sprintf("/tasks/%d", created.TaskID), nil, http.StatusNoContent, nil)
+	alice.do("GET", fmt.Sprintf("/sync?since=%d&limit=10", changes.Next), nil, http.StatusOK, &changes)
+	if len(changes.Tasks) != 0 || len(changes.Deleted) != 2 || changes.HasMore {
+		t.Errorf("Удаление: %+v", changes)
+	}
+
+	alice.expectError("GET", "/sync?since=-1", nil, http.StatusBadRequest, codeBadRequest)
+	alice.expectError("GET", "/sync?limit=5000", nil, http.StatusBadRequest, codeBadRequest)
+	alice.expectError("GET", fmt.Sprintf("/sync?since=%d", changes.Next+100), nil, http.StatusConflict, codeConflict)
+}
+
+func TestExportImport(t *testing.T) {
+	server := newTestServer(t)
+	alice := signUp(t, server, "alice")
+	bob := signUp(t, server, "bob")
+
+	var project manager.Project
+	alice.do("POST", "/projects", map[string]interface{}{"name": "Работа"}, http.StatusCreated, &project)
+	alice.do("POST", "/tasks", map[string]interface{}{"description": "Отчет", "project_id": project.ID, "tags": []string{"срочно"}}, http.StatusCreated, nil)
+	alice.do("POST", "/tasks", map[string]interface{}{"description": "Личное"}, http.StatusCreated, nil)
+
+	var data manager.Export
+	resp := alice.do("GET", "/export", nil, http.StatusOK, &data)
+	if !strings.HasPrefix(resp.Header.Get("Content-Disposition"), `attachment; filename="todo-export-`) {
+		t.Errorf("Content-Disposition: %q", resp.Header.Get("Content-Disposition"))
+	}
+	if len(data.Projects) != 2 || len(data.Tasks) != 2 || len(data.Tags) != 1 {
+		t.Fatalf("Выгрузка: %+v", data)
+	}
+
+	var report manager.ImportReport
+	bob.do("POST", "/import?dry_run=true", data, http.StatusOK, &report)
+	if !report.DryRun || report.Mode != manager.ImportMerge || report.TasksCreated != 2 || report.TaskIDs != nil {
+		t.Errorf("Пробный импорт: %+v", report)
+	}
+	var list TaskList
+	bob.do("GET", "/tasks", nil, http.StatusOK, &list)
+	if len(list.Tasks) != 0 {
+		t.Fatalf("Пробный импорт сохранил задачи: %+v", list)
+	}
+
+	bob.do("POST", "/import?mode=replace", data, http.StatusOK, &report)
+	if report.DryRun || report.TasksCreated != 2 || report.ProjectsCreated != 1 || len(report.TaskIDs) != 2 {
+		t.Errorf("Импорт: %+v", report)
+	}
+	bob.do("GET", "/tasks", nil, http.StatusOK, &list)
+	if len(list.Tasks) != 2 {
+		t.Errorf("Задачи после импорта: %+v", list)
+	}
+
+	bob.expectError("POST", "/import?mode=append", data, http.StatusBadRequest, codeBadRequest)
+	bob.expectError("POST", "/import?dry_run=maybe", data, http.StatusBadRequest, codeBadRequest)
+	data.Version = 99
+	bob.expectError("POST", "/import", data, http.StatusUnprocessableEntity, codeValidation)
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+
+	"todo-app/internal/manager"
+)
+
+// maxUploadSize - предел тела multipart-запроса: файл и служебные заголовки
+const maxUploadSize = manager.MaxAttachmentSize + 64<<10
+
+type AttachmentList struct {
+	Attachments []manager.Attachment `json:"attachments"`
+}
+
+func (s *Server) listAttachments(w http.ResponseWriter, r *http.Request) {
+	taskID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	attachments, err := s.attachments.GetAttachments(currentUser(r).ID, taskID)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, AttachmentList{Attachments: attachments})
+}
+
+// createAttachment принимает файл из поля file запроса multipart/form-data.
+// Тело читается потоком, без промежуточного разбора всей формы.
+func (s *Server) createAttachment(w http.ResponseWriter, r *http.Request) {
+	taskID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		writeError(w, http.StatusUnsupportedMediaType, codeUnsupportedMedia, "ожидается Content-Type: multipart/form-data")
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "некорректное тело multipart: "+err.Error())
+		return
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "в запросе нет поля file")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "некорректное тело multipart: "+err.Error())
+			return
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+		attachment, err := s.attachments.AddAttachment(currentUser(r).ID, taskID, part.FileName(), part)
+		part.Close()
+		if err != nil {
+			writeManagerError(w, r, err)
+			return
+		}
+		w.Header().Set("Location", fmt.Sprintf("/api/v1/attachments/%d", attachment.ID))
+		writeJSON(w, http.StatusCreated, attachment)
+		return
+	}
+}
+
+// downloadAttachment отдает содержимое вложения. Изображения показываются
+// в браузере, остальное скачивается; nosniff не дает браузеру угадывать тип.
+func (s *Server) downloadAttachment(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	attachment, file, err := s.attachments.OpenAttachment(currentUser(r).ID, id)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	defer file.Close()
+	ServeAttachment(w, r, attachment, file)
+}
+
+// ServeAttachment пишет файл вложения с заголовками типа и имени.
+// Используется и веб-интерфейсом.
+func ServeAttachment(w http.ResponseWriter, r *http.Request, attachment *manager.Attachment, content io.ReadSeeker) {
+	disposition := "attachment"
+	if attachment.Inline() {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("%s; filename*=UTF-8''%s", disposition, url.PathEscape(attachment.Filename)))
+	http.ServeContent(w, r, "", attachment.CreatedAt, content)
+}
+
+func (s *Server) deleteAttachment(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	if err := s.attachments.DeleteAttachment(currentUser(r).ID, id); err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"todo-app/internal/manager"
+)
+
+type CommentList struct {
+	Comments []manager.Comment `json:"comments"`
+}
+
+// CommentRequest - создание или изменение комментария
+type CommentRequest struct {
+	Body string `json:"body"`
+}
+
+func (s *Server) listComments(w http.ResponseWriter, r *http.Request) {
+	taskID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	comments, err := s.comments.GetComments(currentUser(r).ID, taskID)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, CommentList{Comments: comments})
+}
+
+func (s *Server) createComment(w http.ResponseWriter, r *http.Request) {
+	taskID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var req CommentRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	comment, err := s.comments.AddComment(*currentUser(r), taskID, req.Body)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/comments/%d", comment.ID))
+	writeJSON(w, http.StatusCreated, comment)
+}
+
+// updateComment меняет текст комментария; доступно только автору
+func (s *Server) updateComment(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var req CommentRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	comment, err := s.comments.UpdateComment(currentUser(r).ID, id, req.Body)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, comment)
+}
+
+func (s *Server) deleteComment(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	if err := s.comments.DeleteComment(currentUser(r).ID, id); err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"todo-app/internal/manager"
+)
+
+// maxImportSize ограничивает тело запроса импорта: выгрузка со всеми
+// задачами пользователя больше обычного запроса
+const maxImportSize = 32 << 20
+
+// exportData отдает все данные пользователя файлом выгрузки, см. manager.Export
+func (s *Server) exportData(w http.ResponseWriter, r *http.Request) {
+	data, err := s.tasks.ExportForUser(currentUser(r).ID)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	filename := "todo-export-" + data.ExportedAt.Format("2006-01-02") + ".json"
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	writeJSON(w, http.StatusOK, data)
+}
+
+// importData загружает выгрузку в учетную запись пользователя.
+// ?mode=merge (по умолчанию) добавляет данные к существующим, ?mode=replace
+// сначала перемещает задачи в корзину и удаляет проекты; ?dry_run=true
+// только возвращает отчет.
+func (s *Server) importData(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	mode := manager.ImportMerge
+	if value := query.Get("mode"); value != "" {
+		mode = manager.ImportMode(value)
+		if !mode.Valid() {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "mode: ожидается merge или replace")
+			return
+		}
+	}
+	dryRun := false
+	if value := query.Get("dry_run"); value != "" {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "dry_run: ожидается true или false")
+			return
+		}
+		dryRun = b
+	}
+
+	var data manager.Export
+	if !decodeJSONLimit(w, r, &data, maxImportSize) {
+		return
+	}
+	report, err := s.tasks.ImportForUser(currentUser(r).ID, &data, mode, dryRun)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"todo-app/internal/manager"
+)
+
+type SavedFilterList struct {
+	Filters []manager.SavedFilter `json:"filters"`
+}
+
+func (s *Server) listFilters(w http.ResponseWriter, r *http.Request) {
+	filters, err := s.filters.GetFilters(currentUser(r).ID)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, SavedFilterList{Filters: filters})
+}
+
+func (s *Server) createFilter(w http.ResponseWriter, r *http.Request) {
+	var req manager.SavedFilterRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	filter, err := s.filters.CreateFilter(currentUser(r).ID, req)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/filters/%d", filter.ID))
+	writeJSON(w, http.StatusCreated, filter)
+}
+
+func (s *Server) getFilter(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	filter, err := s.filters.GetFilter(currentUser(r).ID, id)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, filter)
+}
+
+// updateFilter заменяет фильтр целиком: поля, которых нет в теле, сбрасываются
+func (s *Server) updateFilter(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var req manager.SavedFilterRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	filter, err := s.filters.UpdateFilter(currentUser(r).ID, id, req)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, filter)
+}
+
+func (s *Server) deleteFilter(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	if err := s.filters.DeleteFilter(currentUser(r).ID, id); err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// filterTasks возвращает задачи, подходящие под сохраненный фильтр, в его порядке
+func (s *Server) filterTasks(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	_, tasks, err := s.filters.RunFilter(currentUser(r).ID, id)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, taskList(tasks))
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"net/http"
+
+	"todo-app/internal/manager"
+)
+
+type MemberList struct {
+	Members []manager.Member `json:"members"`
+}
+
+// ShareProjectRequest - пригласить пользователя по имени или сменить его роль
+type ShareProjectRequest struct {
+	Username string       `json:"username"`
+	Role     manager.Role `json:"role"`
+}
+
+type CreateInviteRequest struct {
+	Role manager.Role `json:"role"`
+}
+
+type JoinProjectRequest struct {
+	Code string `json:"code"`
+}
+
+// listMembers возвращает владельца и участников проекта
+func (s *Server) listMembers(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	members, err := s.tasks.GetMembersForUser(currentUser(r).ID, id)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, MemberList{Members: members})
+}
+
+// shareProject добавляет пользователя в проект или меняет его роль
+func (s *Server) shareProject(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var req ShareProjectRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	member, err := s.users.GetUserByUsername(req.Username)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	m, err := s.tasks.ShareProjectForUser(currentUser(r).ID, id, *member, req.Role)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, m)
+}
+
+// removeMember исключает участника; участник может так выйти из проекта сам
+func (s *Server) removeMember(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	userID, ok := pathInt(w, r, "userID")
+	if !ok {
+		return
+	}
+	if err := s.tasks.RemoveMemberForUser(currentUser(r).ID, id, userID); err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// createInvite выдает одноразовый код приглашения в проект
+func (s *Server) createInvite(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var req CreateInviteRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	invite, err := s.tasks.CreateProjectInviteForUser(currentUser(r).ID, id, req.Role)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, invite)
+}
+
+// joinProject присоединяет текущего пользователя к проекту по коду приглашения
+func (s *Server) joinProject(w http.ResponseWriter, r *http.Request) {
+	var req JoinProjectRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	project, err := s.tasks.JoinProjectForUser(*currentUser(r), req.Code)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, project)
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"todo-app/internal/manager"
+	"todo-app/internal/models"
+	"todo-app/internal/openapi"
+)
+
+// specChecker сверяет запросы и ответы API со спецификацией
+func specChecker(t *testing.T) func(next http.Handler) http.Handler {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc.Checker("/api/v1", func(err error) {
+		t.Errorf("Расхождение со спецификацией: %v", err)
+	})
+}
+
+// TestSchemasMatchTypes сверяет схемы спецификации с типами, которые API
+// разбирает из запросов и отдает в ответах
+func TestSchemasMatchTypes(t *testing.T) {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := map[string]interface{}{
+		"Error":                  ErrorBody{},
+		"ErrorDetail":            ErrorDetail{},
+		"Task":                   Task{},
+		"TaskList":               TaskList{},
+		"CreateTaskRequest":      models.CreateTaskRequest{},
+		"UpdateTaskRequest":      manager.UpdateTaskRequest{},
+		"SubTask":                manager.SubTask{},
+		"SubTaskList":            SubTaskList{},
+		"CreateSubTaskRequest":   CreateSubTaskRequest{},
+		"FieldChange":            manager.FieldChange{},
+		"TaskEvent":              manager.TaskEvent{},
+		"TaskEventList":          TaskEventList{},
+		"Tombstone":              manager.Tombstone{},
+		"ChangeSet":              ChangeSet{},
+		"ClientChange":           manager.ClientChange{},
+		"PushRequest":            PushRequest{},
+		"ChangeResult":           ChangeResult{},
+		"PushResponse":           PushResponse{},
+		"Export":                 manager.Export{},
+		"ExportProject":          manager.ExportProject{},
+		"ExportTask":             manager.ExportTask{},
+		"ExportSubTask":          manager.ExportSubTask{},
+		"ExportReminder":         manager.ExportReminder{},
+		"ImportReport":           manager.ImportReport{},
+		"Comment":                manager.Comment{},
+		"CommentList":            CommentList{},
+		"CommentRequest":         CommentRequest{},
+		"Attachment":             manager.Attachment{},
+		"AttachmentList":         AttachmentList{},
+		"TagCount":               manager.TagCount{},
+		"TagList":                TagList{},
+		"SearchHit":              SearchHit{},
+		"SearchResults":          SearchResults{},
+		"RenameTagRequest":       RenameTagRequest{},
+		"MergeTagsRequest":       MergeTagsRequest{},
+		"TagChangeResult":        TagChangeResult{},
+		"User":                   manager.User{},
+		"RegisterRequest":        RegisterRequest{},
+		"LoginRequest":           LoginRequest{},
+		"SessionResponse":        SessionResponse{},
+		"LinkCodeResponse":       LinkCodeResponse{},
+		"Reminder":               manager.Reminder{},
+		"CreateReminderRequest":  manager.CreateReminderRequest{},
+		"ReminderSettings":       manager.ReminderSettings{},
+		"FilterOptions":          manager.FilterOptions{},
+		"SavedFilter":            manager.SavedFilter{},
+		"SavedFilterRequest":     manager.SavedFilterRequest{},
+		"SavedFilterList":        SavedFilterList{},
+		"Project":                manager.Project{},
+		"ProjectList":            ProjectList{},
+		"CreateProjectRequest":   manager.CreateProjectRequest{},
+		"UpdateProjectRequest":   manager.UpdateProjectRequest{},
+		"ArchiveProjectsRequest": ArchiveProjectsRequest{},
+		"ArchiveProjectsResult":  ArchiveProjectsResult{},
+		"MoveTasksRequest":       MoveTasksRequest{},
+		"MoveTasksResult":        MoveTasksResult{},
+		"Member":                 manager.Member{},
+		"MemberList":             MemberList{},
+		"ShareProjectRequest":    ShareProjectRequest{},
+		"CreateInviteRequest":    CreateInviteRequest{},
+		"ProjectInvite":          manager.ProjectInvite{},
+		"JoinProjectRequest":     JoinProjectRequest{},
+	}
+	for name, v := range types {
+		if err := doc.MatchType(name, v); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"todo-app/internal/manager"
+)
+
+type ProjectList struct {
+	Projects []manager.Project `json:"projects"`
+}
+
+type ArchiveProjectsRequest struct {
+	IDs      []int `json:"ids"`
+	Archived bool  `json:"archived"`
+}
+
+// ArchiveProjectsResult - сколько проектов сменили архивность
+type ArchiveProjectsResult struct {
+	Projects int `json:"projects"`
+}
+
+type MoveTasksRequest struct {
+	TaskIDs   []int `json:"task_ids"`
+	ProjectID int   `json:"project_id"`
+}
+
+// MoveTasksResult - сколько задач перешло в другой проект при переносе
+// или удалении проекта
+type MoveTasksResult struct {
+	Tasks int `json:"tasks"`
+}
+
+// listProjects возвращает проекты пользователя: Входящие, затем по порядку.
+// ?archived=false оставляет только активные, ?archived=true - только архив.
+func (s *Server) listProjects(w http.ResponseWriter, r *http.Request) {
+	var archived *bool
+	if value := r.URL.Query().Get("archived"); value != "" {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "archived: ожидается true или false")
+			return
+		}
+		archived = &b
+	}
+	projects, err := s.tasks.GetProjectsForUser(currentUser(r).ID)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	list := ProjectList{Projects: []manager.Project{}}
+	for _, project := range projects {
+		if archived == nil || project.Archived == *archived {
+			list.Projects = append(list.Projects, project)
+		}
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) createProject(w http.ResponseWriter, r *http.Request) {
+	var req manager.CreateProjectRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	project, err := s.tasks.CreateProjectForUser(currentUser(r).ID, req)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/projects/%d", project.ID))
+	writeJSON(w, http.StatusCreated, project)
+}
+
+func (s *Server) getProject(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	project, err := s.tasks.GetProjectForUser(currentUser(r).ID, id)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, project)
+}
+
+func (s *Server) updateProject(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var req manager.UpdateProjectRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	project, err := s.tasks.UpdateProjectForUser(currentUser(r).ID, id, req)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, project)
+}
+
+// deleteProject удаляет проект; его задачи переходят во Входящие
+func (s *Server) deleteProject(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	n, err := s.tasks.DeleteProjectForUser(currentUser(r).ID, id)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, MoveTasksResult{Tasks: n})
+}
+
+// projectTasks возвращает задачи проекта, ближайший срок первым
+func (s *Server) projectTasks(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	userID := currentUser(r).ID
+	if _, err := s.tasks.GetProjectForUser(userID, id); err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	tasks, err := s.tasks.FilterTasksAdvancedForUser(userID, manager.FilterOptions{ProjectID: &id})
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	manager.SortTasks(tasks, manager.SortByDueDate)
+	writeJSON(w, http.StatusOK, taskList(tasks))
+}
+
+// archiveProjects архивирует или возвращает из архива несколько проектов сразу
+func (s *Server) archiveProjects(w http.ResponseWriter, r *http.Request) {
+	var req ArchiveProjectsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	n, err := s.tasks.ArchiveProjectsForUser(currentUser(r).ID, req.IDs, req.Archived)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ArchiveProjectsResult{Projects: n})
+}
+
+func (s *Server) moveTasks(w http.ResponseWriter, r *http.Request) {
+	var req MoveTasksRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	n, err := s.tasks.MoveTasksForUser(currentUser(r).ID, req.TaskIDs, req.ProjectID)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, MoveTasksResult{Tasks: n})
+}
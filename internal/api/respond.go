@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"todo-app/internal/logger"
+	"todo-app/internal/manager"
+)
+
+// maxBodySize ограничивает тело запроса
+const maxBodySize = 1 << 20
+
+// Коды ошибок в конверте {"error": {"code": ..., "message": ...}}
+const (
+	codeBadRequest       = "bad_request"
+	codeUnauthorized     = "unauthorized"
+	codeForbidden        = "forbidden"
+	codeNotFound         = "not_found"
+	codeMethodNotAllowed = "method_not_allowed"
+	codeConflict         = "conflict"
+	codePrecondition     = "precondition_failed"
+	codeUnsupportedMedia = "unsupported_media_type"
+	codeValidation       = "validation_failed"
+	codeTooManyRequests  = "too_many_requests"
+	codeInternal         = "internal"
+)
+
+// ErrorBody - тело любого ответа с ошибкой
+type ErrorBody struct {
+	Error ErrorDetail `json:"error"`
+}
+
+type ErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, ErrorBody{Error: ErrorDetail{Code: code, Message: message}})
+}
+
+// writeManagerError переводит типизированную ошибку менеджера в статус и код.
+// Нетипизированные ошибки - сбои хранилища: они логируются, а клиент
+// получает 500 без подробностей.
+func writeManagerError(w http.ResponseWriter, r *http.Request, err error) {
+	status, detail := managerError(r, err)
+	writeError(w, status, detail.Code, detail.Message)
+}
+
+// managerError - статус и тело ошибки для writeManagerError
+func managerError(r *http.Request, err error) (int, ErrorDetail) {
+	switch {
+	case errors.Is(err, manager.ErrNotFound):
+		return http.StatusNotFound, ErrorDetail{codeNotFound, err.Error()}
+	case errors.Is(err, manager.ErrForbidden):
+		return http.StatusForbidden, ErrorDetail{codeForbidden, err.Error()}
+	case errors.Is(err, manager.ErrConflict):
+		return http.StatusConflict, ErrorDetail{codeConflict, err.Error()}
+	case errors.Is(err, manager.ErrInvalid):
+		return http.StatusUnprocessableEntity, ErrorDetail{codeValidation, err.Error()}
+	case errors.Is(err, manager.ErrInvalidCredentials):
+		return http.StatusUnauthorized, ErrorDetail{codeUnauthorized, err.Error()}
+	case errors.Is(err, manager.ErrTooManyLoginAttempts):
+		return http.StatusTooManyRequests, ErrorDetail{codeTooManyRequests, err.Error()}
+	default:
+		logger.Error(r.Context(), err, "Ошибка обработки запроса API", "path", r.URL.Path)
+		return http.StatusInternalServerError, ErrorDetail{codeInternal, "внутренняя ошибка сервера"}
+	}
+}
+
+// decodeJSON читает тело запроса в v. Неизвестные поля - ошибка, чтобы опечатка
+// в имени поля не превращалась в молча проигнорированное изменение.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	return decodeJSONLimit(w, r, v, maxBodySize)
+}
+
+// decodeJSONLimit - decodeJSON с другим ограничением размера тела
+func decodeJSONLimit(w http.ResponseWriter, r *http.Request, v interface{}, limit int64) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, codeUnsupportedMedia, "ожидается Content-Type: application/json")
+		return false
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, limit))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "некорректный JSON: "+err.Error())
+		return false
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "после JSON-объекта есть лишние данные")
+		return false
+	}
+	return true
+}
+
+// pathID разбирает {id} из пути
+func pathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	return pathInt(w, r, "id")
+}
+
+// pathInt разбирает положительный целый параметр пути name
+func pathInt(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, name))
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "некорректный ID")
+		return 0, false
+	}
+	return id, true
+}
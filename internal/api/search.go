@@ -0,0 +1,40 @@
+package api
+
+import (
+	"html"
+	"net/http"
+)
+
+// SearchHit - найденная задача. Snippet - фрагмент описания или подзадач,
+// экранированный для HTML, совпадения обернуты в <mark>.
+type SearchHit struct {
+	Task    Task   `json:"task"`
+	Snippet string `json:"snippet"`
+}
+
+type SearchResults struct {
+	Results []SearchHit `json:"results"`
+}
+
+// search ищет задачи по тексту: ?q=отчет*&completed=false, фильтры как у GET /tasks
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	options, err := parseFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	results, err := s.tasks.SearchForUser(currentUser(r).ID, r.URL.Query().Get("q"), options)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+
+	list := SearchResults{Results: make([]SearchHit, 0, len(results))}
+	for _, result := range results {
+		list.Results = append(list.Results, SearchHit{
+			Task:    taskView(result.Task),
+			Snippet: result.Highlight("<mark>", "</mark>", html.EscapeString),
+		})
+	}
+	writeJSON(w, http.StatusOK, list)
+}
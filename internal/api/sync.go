@@ -0,0 +1,104 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"todo-app/internal/manager"
+)
+
+// maxSyncLimit ограничивает ?limit= в ленте изменений
+const maxSyncLimit = 1000
+
+// ChangeSet - изменения из ленты пользователя. Задачи в том же виде,
+// что и в /tasks; удаленные задачи и подзадачи - в deleted.
+type ChangeSet struct {
+	Tasks    []Task              `json:"tasks"`
+	SubTasks []manager.SubTask   `json:"subtasks"`
+	Deleted  []manager.Tombstone `json:"deleted"`
+	Next     int                 `json:"next"`
+	HasMore  bool                `json:"has_more"`
+}
+
+type PushRequest struct {
+	Changes []manager.ClientChange `json:"changes"`
+}
+
+// ChangeResult - итог одного изменения из пакета, в порядке пакета
+type ChangeResult struct {
+	manager.ChangeResult
+	Task  *Task        `json:"task,omitempty"`
+	Error *ErrorDetail `json:"error,omitempty"`
+}
+
+type PushResponse struct {
+	Results []ChangeResult `json:"results"`
+}
+
+// listChanges отдает изменения после ?since=N. Без since - полный снимок;
+// следующий запрос делается с next из ответа, пока has_more.
+func (s *Server) listChanges(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	since, limit := 0, 0
+	if value := query.Get("since"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "since: ожидается неотрицательное целое число")
+			return
+		}
+		since = n
+	}
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxSyncLimit {
+			writeError(w, http.StatusBadRequest, codeBadRequest, "limit: ожидается число от 1 до "+strconv.Itoa(maxSyncLimit))
+			return
+		}
+		limit = n
+	}
+
+	set, err := s.tasks.Changes(currentUser(r).ID, since, limit)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ChangeSet{
+		Tasks:    taskList(set.Tasks).Tasks,
+		SubTasks: set.SubTasks,
+		Deleted:  set.Deleted,
+		Next:     set.Next,
+		HasMore:  set.HasMore,
+	})
+}
+
+// pushChanges применяет изменения, сделанные клиентом без связи. Ответ
+// всегда 200: итог каждого изменения - в results, конфликт версий
+// возвращает текущую задачу, чтобы клиент мог объединить правки.
+func (s *Server) pushChanges(w http.ResponseWriter, r *http.Request) {
+	var req PushRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	results, err := s.tasks.ApplyChanges(currentUser(r).ID, s.subtasks, req.Changes)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+
+	response := PushResponse{Results: make([]ChangeResult, 0, len(results))}
+	for _, result := range results {
+		view := ChangeResult{ChangeResult: result}
+		if result.Task != nil {
+			task := taskView(*result.Task)
+			view.Task = &task
+		}
+		if result.Status == manager.ChangeConflict {
+			view.Error = &ErrorDetail{Code: codeConflict, Message: result.Err.Error()}
+		} else if result.Err != nil {
+			_, detail := managerError(r, result.Err)
+			view.Error = &detail
+		}
+		response.Results = append(response.Results, view)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+	"todo-app/internal/manager"
+)
+
+type TagList struct {
+	Tags []manager.TagCount `json:"tags"`
+}
+
+type RenameTagRequest struct {
+	Name string `json:"name"`
+}
+
+type MergeTagsRequest struct {
+	Sources []string `json:"sources"`
+	Target  string   `json:"target"`
+}
+
+// TagChangeResult - сколько задач затронуло переименование, объединение
+// или удаление тега
+type TagChangeResult struct {
+	Tasks int `json:"tasks"`
+}
+
+// listTags возвращает теги пользователя с числом задач, самые частые первыми
+func (s *Server) listTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := s.tasks.GetTagsForUser(currentUser(r).ID)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, TagList{Tags: tags})
+}
+
+func (s *Server) renameTag(w http.ResponseWriter, r *http.Request) {
+	name, ok := pathTag(w, r)
+	if !ok {
+		return
+	}
+	var req RenameTagRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	n, err := s.tasks.RenameTagForUser(currentUser(r).ID, name, req.Name)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, TagChangeResult{Tasks: n})
+}
+
+func (s *Server) mergeTags(w http.ResponseWriter, r *http.Request) {
+	var req MergeTagsRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	n, err := s.tasks.MergeTagsForUser(currentUser(r).ID, req.Sources, req.Target)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, TagChangeResult{Tasks: n})
+}
+
+func (s *Server) deleteTag(w http.ResponseWriter, r *http.Request) {
+	name, ok := pathTag(w, r)
+	if !ok {
+		return
+	}
+	n, err := s.tasks.DeleteTagForUser(currentUser(r).ID, name)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, TagChangeResult{Tasks: n})
+}
+
+// pathTag читает имя тега из пути. Если в пути есть экранирование, которое
+// нельзя восстановить из r.URL.Path (например, %2F), chi маршрутизирует по
+// RawPath и отдает параметр нераскодированным.
+func pathTag(w http.ResponseWriter, r *http.Request) (string, bool) {
+	name := chi.URLParam(r, "name")
+	if r.URL.RawPath == "" {
+		return name, true
+	}
+	name, err := url.PathUnescape(name)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "некорректное имя тега")
+		return "", false
+	}
+	return name, true
+}
@@ -0,0 +1,424 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-app/internal/manager"
+	"todo-app/internal/models"
+)
+
+// Task - задача в ответах API. Отсутствующий срок отдается как null,
+// а не как нулевое время.
+type Task struct {
+	manager.Task
+	DueDate *time.Time `json:"due_date"`
+}
+
+type TaskList struct {
+	Tasks []Task `json:"tasks"`
+}
+
+type SubTaskList struct {
+	SubTasks []manager.SubTask `json:"subtasks"`
+}
+
+type TaskEventList struct {
+	Events []manager.TaskEvent `json:"events"`
+}
+
+type CreateSubTaskRequest struct {
+	Description string `json:"description"`
+}
+
+// dateLayout - формат дат в параметрах запроса
+const dateLayout = "2006-01-02"
+
+func taskView(task manager.Task) Task {
+	view := Task{Task: task}
+	if !task.DueDate.IsZero() {
+		due := task.DueDate
+		view.DueDate = &due
+	}
+	if view.Tags == nil {
+		view.Tags = []string{}
+	}
+	return view
+}
+
+// writeTask отдает задачу с ETag ее версии. ETag передается обратно
+// в If-Match, чтобы изменение не затерло чужую правку.
+func writeTask(w http.ResponseWriter, status int, task manager.Task) {
+	w.Header().Set("ETag", taskETag(task))
+	writeJSON(w, status, taskView(task))
+}
+
+func taskETag(task manager.Task) string {
+	return strconv.Quote(strconv.Itoa(task.Version))
+}
+
+// ifMatchVersion переносит версию из If-Match в req.Version. Без заголовка
+// и с «*» изменение безусловное, как и без version в теле.
+func ifMatchVersion(w http.ResponseWriter, r *http.Request, req *manager.UpdateTaskRequest) bool {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return true
+	}
+	value, err := strconv.Unquote(header)
+	version, convErr := strconv.Atoi(value)
+	if err != nil || convErr != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "If-Match: ожидается ETag задачи, например \"3\"")
+		return false
+	}
+	req.Version = &version
+	return true
+}
+
+// writeUpdateError - writeManagerError для условных изменений: при
+// расхождении версий отдает ETag текущей задачи, а для If-Match - 412
+func writeUpdateError(w http.ResponseWriter, r *http.Request, err error) {
+	var conflict *manager.VersionConflictError
+	if !errors.As(err, &conflict) {
+		writeManagerError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", taskETag(conflict.Current))
+	if r.Header.Get("If-Match") != "" {
+		writeError(w, http.StatusPreconditionFailed, codePrecondition, err.Error())
+		return
+	}
+	writeError(w, http.StatusConflict, codeConflict, err.Error())
+}
+
+func taskList(tasks []manager.Task) TaskList {
+	list := TaskList{Tasks: make([]Task, 0, len(tasks))}
+	for _, task := range tasks {
+		list.Tasks = append(list.Tasks, taskView(task))
+	}
+	return list
+}
+
+// parseFilter строит FilterOptions из строки запроса:
+// ?completed=true&priority=high&tags=work,home&start_date=2026-10-01&end_date=2026-10-31&has_due_date=true
+// &project_id=3&assignee=me&query=due<%2B7d -tag:later
+func parseFilter(r *http.Request) (manager.FilterOptions, error) {
+	query := r.URL.Query()
+	options := manager.FilterOptions{}
+
+	parseBool := func(name string) (*bool, error) {
+		value := query.Get(name)
+		if value == "" {
+			return nil, nil
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: ожидается true или false", name)
+		}
+		return &b, nil
+	}
+	parseDate := func(name string) (*time.Time, error) {
+		value := query.Get(name)
+		if value == "" {
+			return nil, nil
+		}
+		t, err := time.Parse(dateLayout, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: ожидается дата ГГГГ-ММ-ДД", name)
+		}
+		return &t, nil
+	}
+
+	var err error
+	if options.Completed, err = parseBool("completed"); err != nil {
+		return options, err
+	}
+	if options.HasDueDate, err = parseBool("has_due_date"); err != nil {
+		return options, err
+	}
+	if options.StartDate, err = parseDate("start_date"); err != nil {
+		return options, err
+	}
+	if options.EndDate, err = parseDate("end_date"); err != nil {
+		return options, err
+	}
+	if value := query.Get("priority"); value != "" {
+		priority := manager.Priority(value)
+		if !priority.Valid() {
+			return options, fmt.Errorf("priority: ожидается low, medium или high")
+		}
+		options.Priority = &priority
+	}
+	// Теги можно передать списком через запятую или повторив параметр
+	for _, value := range query["tags"] {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				options.Tags = append(options.Tags, tag)
+			}
+		}
+	}
+	if value := query.Get("project_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil || id < 1 {
+			return options, fmt.Errorf("project_id: ожидается положительное целое число")
+		}
+		options.ProjectID = &id
+	}
+	// Исполнитель: me - текущий пользователь, none - задачи без исполнителя
+	switch value := query.Get("assignee"); value {
+	case "":
+	case "me":
+		me := currentUser(r).ID
+		options.AssigneeID = &me
+	case "none":
+		none := 0
+		options.AssigneeID = &none
+	default:
+		id, err := strconv.Atoi(value)
+		if err != nil || id < 1 {
+			return options, fmt.Errorf("assignee: ожидается me, none или ID пользователя")
+		}
+		options.AssigneeID = &id
+	}
+	// Запрос разбирает менеджер: ошибка в нем - 422, как и другие ошибки проверки
+	options.Query = strings.TrimSpace(query.Get("query"))
+	return options, nil
+}
+
+func (s *Server) listTasks(w http.ResponseWriter, r *http.Request) {
+	options, err := parseFilter(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeBadRequest, err.Error())
+		return
+	}
+	sortOrder := manager.SortOrder(r.URL.Query().Get("sort"))
+	if sortOrder != "" && !sortOrder.Valid() {
+		writeError(w, http.StatusBadRequest, codeBadRequest, "sort: ожидается due_date, priority или created")
+		return
+	}
+	tasks, err := s.tasks.FilterTasksAdvancedForUser(currentUser(r).ID, options)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	if sortOrder != "" {
+		manager.SortTasks(tasks, sortOrder)
+	}
+	writeJSON(w, http.StatusOK, taskList(tasks))
+}
+
+func (s *Server) createTask(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateTaskRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+
+	var options manager.UpdateTaskRequest
+	if req.Priority != "" {
+		priority := manager.Priority(req.Priority)
+		options.Priority = &priority
+	}
+	options.DueDate = req.DueDate
+	if req.Recurrence != "" {
+		options.Recurrence = &req.Recurrence
+	}
+	options.ProjectID = req.ProjectID
+	options.AssigneeID = req.AssigneeID
+
+	task, err := s.tasks.CreateTaskForUser(currentUser(r).ID, strings.TrimSpace(req.Description), req.Tags, options)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/tasks/%d", task.ID))
+	writeTask(w, http.StatusCreated, *task)
+}
+
+func (s *Server) getTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	task, err := s.tasks.GetTaskForUser(currentUser(r).ID, id)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeTask(w, http.StatusOK, *task)
+}
+
+func (s *Server) updateTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var req manager.UpdateTaskRequest
+	if !decodeJSON(w, r, &req) || !ifMatchVersion(w, r, &req) {
+		return
+	}
+	task, err := s.tasks.UpdateTaskForUser(currentUser(r).ID, id, req)
+	if err != nil {
+		writeUpdateError(w, r, err)
+		return
+	}
+	writeTask(w, http.StatusOK, *task)
+}
+
+// updateSeries применяет изменения ко всем открытым экземплярам серии
+func (s *Server) updateSeries(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var req manager.UpdateTaskRequest
+	if !decodeJSON(w, r, &req) || !ifMatchVersion(w, r, &req) {
+		return
+	}
+	tasks, err := s.tasks.UpdateSeriesForUser(currentUser(r).ID, id, req)
+	if err != nil {
+		writeUpdateError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, taskList(tasks))
+}
+
+func (s *Server) deleteTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	if err := s.tasks.DeleteTaskForUser(currentUser(r).ID, id); err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) toggleTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	task, _, err := s.tasks.ToggleCompleteForUser(currentUser(r).ID, id)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeTask(w, http.StatusOK, *task)
+}
+
+// restoreTask возвращает задачу из корзины
+func (s *Server) restoreTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	task, err := s.tasks.RestoreTaskForUser(currentUser(r).ID, id)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeTask(w, http.StatusOK, *task)
+}
+
+// listTrash возвращает задачи из корзины, недавно удаленные первыми
+func (s *Server) listTrash(w http.ResponseWriter, r *http.Request) {
+	tasks, err := s.tasks.GetTrashForUser(currentUser(r).ID)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, taskList(tasks))
+}
+
+// listTaskEvents возвращает историю изменений задачи, новые события первыми
+func (s *Server) listTaskEvents(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	events, err := s.tasks.GetTaskEvents(currentUser(r).ID, id)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, TaskEventList{Events: events})
+}
+
+func (s *Server) listSubTasks(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	userID := currentUser(r).ID
+	if _, err := s.tasks.GetTaskForUser(userID, id); err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	subtasks, err := s.subtasks.GetSubTasks(userID, id)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, SubTaskList{SubTasks: subtasks})
+}
+
+func (s *Server) createSubTask(w http.ResponseWriter, r *http.Request) {
+	taskID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var req CreateSubTaskRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	userID := currentUser(r).ID
+	if _, err := s.tasks.GetTaskForUser(userID, taskID); err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	id, err := s.subtasks.AddSubTask(userID, taskID, strings.TrimSpace(req.Description))
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	subtasks, err := s.subtasks.GetSubTasks(userID, taskID)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	for _, st := range subtasks {
+		if st.ID == id {
+			writeJSON(w, http.StatusCreated, st)
+			return
+		}
+	}
+	writeManagerError(w, r, manager.NotFound("подзадача с ID %d не найдена", id))
+}
+
+func (s *Server) toggleSubTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	if err := s.subtasks.ToggleSubTask(currentUser(r).ID, id); err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) deleteSubTask(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	if err := s.subtasks.DeleteSubTask(currentUser(r).ID, id); err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"todo-app/internal/logger"
+	"todo-app/internal/manager"
+)
+
+type RegisterRequest struct {
+	Username string `json:"username"`
+	Email    string `json:"email,omitempty"`
+	Password string `json:"password"`
+}
+
+type LoginRequest struct {
+	Login    string `json:"login"` // имя пользователя или email
+	Password string `json:"password"`
+}
+
+// SessionResponse - токен для заголовка "Authorization: Bearer"
+type SessionResponse struct {
+	Token     string        `json:"token"`
+	ExpiresAt time.Time     `json:"expires_at"`
+	User      *manager.User `json:"user"`
+}
+
+type LinkCodeResponse struct {
+	Code      string    `json:"code"`
+	Command   string    `json:"command"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (s *Server) register(w http.ResponseWriter, r *http.Request) {
+	var req RegisterRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	user, err := s.users.Register(req.Username, req.Email, req.Password)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	s.startSession(w, r, user, http.StatusCreated)
+}
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	user, err := s.users.Authenticate(req.Login, req.Password)
+	if err != nil {
+		logger.Info(r.Context(), "Неудачная попытка входа через API", "login", req.Login)
+		writeManagerError(w, r, err)
+		return
+	}
+	s.startSession(w, r, user, http.StatusOK)
+}
+
+func (s *Server) startSession(w http.ResponseWriter, r *http.Request, user *manager.User, status int) {
+	token, session, err := s.users.CreateSession(user.ID)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	logger.Info(r.Context(), "Пользователь вошел через API", "userID", user.ID)
+	writeJSON(w, status, SessionResponse{Token: token, ExpiresAt: session.ExpiresAt, User: user})
+}
+
+// logout завершает сессию, с которой пришел запрос
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
+	token, _ := r.Context().Value(ctxToken).(string)
+	if err := s.users.DeleteSession(token); err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) me(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, currentUser(r))
+}
+
+func (s *Server) createLinkCode(w http.ResponseWriter, r *http.Request) {
+	code, linkCode, err := s.users.CreateLinkCode(currentUser(r).ID)
+	if err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, LinkCodeResponse{
+		Code:      code,
+		Command:   "/link " + code,
+		ExpiresAt: linkCode.ExpiresAt,
+	})
+}
+
+func (s *Server) unlinkTelegram(w http.ResponseWriter, r *http.Request) {
+	if err := s.users.UnlinkTelegram(currentUser(r).ID); err != nil {
+		writeManagerError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,52 @@
+package manager
+
+import "errors"
+
+// checkAssignee проверяет, что задачу из проекта projectID можно назначить
+// пользователю assigneeID: исполнитель должен сам иметь право менять задачи
+// проекта. 0 снимает назначение. Вызывающий должен удерживать tm.mu.
+func (tm *TaskManager) checkAssignee(task Task, projectID, assigneeID int) error {
+	if assigneeID == 0 {
+		return nil
+	}
+	if assigneeID < 0 {
+		return Invalid("assignee_id: ожидается ID пользователя или 0")
+	}
+	if projectID == 0 {
+		if assigneeID != task.UserID {
+			return Invalid("задачу без проекта можно назначить только ее автору")
+		}
+		return nil
+	}
+	project, err := tm.lookupProject(assigneeID, projectID)
+	switch {
+	case errors.Is(err, ErrForbidden):
+		return Invalid("пользователь %d не участник проекта", assigneeID)
+	case err != nil:
+		return err
+	case !project.Role.Allows(ActionEdit):
+		return Invalid("исполнителем может быть только владелец или редактор проекта %q", project.Name)
+	}
+	return nil
+}
+
+// keepAssignee снимает назначение, если после переноса в проект projectID
+// исполнитель больше не может менять задачу. Вызывающий должен удерживать tm.mu.
+func (tm *TaskManager) keepAssignee(task Task, projectID int) int {
+	if task.AssigneeID == 0 || tm.checkAssignee(task, projectID, task.AssigneeID) == nil {
+		return task.AssigneeID
+	}
+	return 0
+}
+
+// notifyAssigned ставит исполнителю уведомление, если задачу назначили ему
+// или переназначили с другого. Себе уведомление не отправляется.
+// Вызывается под tm.mu.
+func (tm *TaskManager) notifyAssigned(task Task, previousAssignee, actorID int) {
+	if task.AssigneeID == 0 || task.AssigneeID == previousAssignee || task.AssigneeID == actorID {
+		return
+	}
+	if tm.reminders != nil {
+		tm.reminders.taskAssigned(task)
+	}
+}
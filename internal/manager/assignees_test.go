@@ -0,0 +1,79 @@
+package manager
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTaskAssignees(t *testing.T) {
+	tm := NewTaskManager()
+	rm := NewReminderManager(tm)
+	alice := User{ID: 1, Username: "alice"}
+	bob := User{ID: 2, Username: "bob"}
+	carol := User{ID: 3, Username: "carol"}
+
+	family, _ := tm.CreateProjectForUser(alice.ID, CreateProjectRequest{Name: "Семья"})
+	tm.ShareProjectForUser(alice.ID, family.ID, bob, RoleEditor)
+	tm.ShareProjectForUser(alice.ID, family.ID, carol, RoleViewer)
+	personal, _ := tm.AddTaskForUser(alice.ID, "Личное", nil)
+	shopping, _ := tm.CreateTaskForUser(alice.ID, "Купить продукты", nil, UpdateTaskRequest{ProjectID: &family.ID})
+
+	assign := func(userID, taskID, assigneeID int) (*Task, error) {
+		return tm.UpdateTaskForUser(userID, taskID, UpdateTaskRequest{AssigneeID: &assigneeID})
+	}
+	for _, tc := range []struct {
+		name       string
+		taskID     int
+		assigneeID int
+	}{
+		{"читатель", shopping.ID, carol.ID},
+		{"не участник", shopping.ID, 42},
+		{"чужая задача без проекта", personal, bob.ID},
+		{"отрицательный ID", shopping.ID, -1},
+	} {
+		if _, err := assign(alice.ID, tc.taskID, tc.assigneeID); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: ожидался ErrInvalid, получено %v", tc.name, err)
+		}
+	}
+	if _, err := assign(carol.ID, shopping.ID, carol.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Назначение читателем: ожидался ErrForbidden, получено %v", err)
+	}
+
+	// Назначение редактору ставит ему уведомление, себе - нет
+	task, err := assign(alice.ID, shopping.ID, bob.ID)
+	if err != nil || task.AssigneeID != bob.ID || task.UserID != alice.ID {
+		t.Fatalf("Назначение редактору: %+v, %v", task, err)
+	}
+	if _, err := assign(bob.ID, shopping.ID, bob.ID); err != nil {
+		t.Fatalf("Повторное назначение: %v", err)
+	}
+	if _, err := assign(alice.ID, personal, alice.ID); err != nil {
+		t.Fatalf("Назначение себе: %v", err)
+	}
+	notified := map[int]int{}
+	for _, r := range rm.reminders {
+		if r.Type == ReminderTypeAssignment {
+			notified[r.UserID]++
+		}
+	}
+	if len(notified) != 1 || notified[bob.ID] != 1 {
+		t.Errorf("Ожидалось одно уведомление для bob, получено %v", notified)
+	}
+
+	mine, _ := tm.FilterTasksAdvancedForUser(bob.ID, FilterOptions{AssigneeID: &bob.ID})
+	if len(mine) != 1 || mine[0].ID != shopping.ID {
+		t.Errorf("Назначено bob: ожидалась задача %d, получено %v", shopping.ID, taskIDs(mine))
+	}
+	none := 0
+	if unassigned, _ := tm.FilterTasksAdvancedForUser(alice.ID, FilterOptions{AssigneeID: &none}); len(unassigned) != 0 {
+		t.Errorf("Все задачи назначены, получено без исполнителя %v", taskIDs(unassigned))
+	}
+
+	// Исключение из проекта снимает назначения участника
+	if err := tm.RemoveMemberForUser(alice.ID, family.ID, bob.ID); err != nil {
+		t.Fatalf("Исключение участника: %v", err)
+	}
+	if task, _ := tm.GetTaskForUser(alice.ID, shopping.ID); task.AssigneeID != 0 {
+		t.Errorf("После исключения исполнитель должен сняться, получено %d", task.AssigneeID)
+	}
+}
@@ -0,0 +1,369 @@
+package manager
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"todo-app/internal/logger"
+)
+
+// DefaultAttachmentsDir - каталог файлов вложений, если не задан другой
+const DefaultAttachmentsDir = "data/attachments"
+
+// MaxAttachmentSize - предельный размер одного файла
+const MaxAttachmentSize = 10 << 20
+
+// attachmentTypes - типы файлов, которые можно прикрепить. Тип определяется
+// по содержимому файла, а не по имени или заголовку клиента.
+var attachmentTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+	"text/plain":      true,
+}
+
+// orphanBlobAge - сколько файл без записи в attachments живет до удаления
+// в PruneBlobs: запись появляется уже после того, как файл сохранен
+const orphanBlobAge = time.Hour
+
+// Attachment - файл, прикрепленный к задаче. Содержимое лежит в каталоге
+// вложений под именем SHA256, поэтому одинаковые файлы хранятся один раз.
+type Attachment struct {
+	ID          int       `json:"id"`
+	TaskID      int       `json:"task_id"`
+	UserID      int       `json:"user_id"` // Кто загрузил
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Inline сообщает, можно ли показывать файл в браузере, а не только скачивать
+func (a Attachment) Inline() bool {
+	return strings.HasPrefix(a.ContentType, "image/")
+}
+
+type AttachmentManager struct {
+	mu          sync.Mutex
+	attachments map[int]Attachment
+	nextID      int
+	storage     Storage
+	tasks       *TaskManager // Проверяет права на задачу вложения
+	dir         string
+}
+
+func NewAttachmentManager(tasks *TaskManager, dir string) *AttachmentManager {
+	return &AttachmentManager{
+		attachments: make(map[int]Attachment),
+		nextID:      1,
+		tasks:       tasks,
+		dir:         dir,
+	}
+}
+
+func NewAttachmentManagerWithStorage(storage Storage, tasks *TaskManager, dir string) *AttachmentManager {
+	am := NewAttachmentManager(tasks, dir)
+	am.storage = storage
+	return am
+}
+
+// cleanFilename оставляет от имени файла клиента только базовое имя
+// без управляющих символов
+func cleanFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" || name == "." || name == "/" {
+		return "file"
+	}
+	if runes := []rune(name); len(runes) > 255 {
+		name = string(runes[len(runes)-255:])
+	}
+	return name
+}
+
+// blobPath - путь к содержимому по хешу: подкаталог из первых двух символов
+func (am *AttachmentManager) blobPath(hash string) string {
+	return filepath.Join(am.dir, hash[:2], hash)
+}
+
+// AddAttachment сохраняет файл и прикрепляет его к задаче, если роль
+// пользователя позволяет менять задачу. Файл больше MaxAttachmentSize
+// или неподдерживаемого типа - ErrInvalid.
+func (am *AttachmentManager) AddAttachment(userID, taskID int, filename string, content io.Reader) (*Attachment, error) {
+	if _, err := am.tasks.AuthorizeTask(userID, taskID, ActionEdit); err != nil {
+		return nil, err
+	}
+
+	attachment, tmp, err := am.receiveBlob(content)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp)
+	attachment.TaskID = taskID
+	attachment.UserID = userID
+	attachment.Filename = cleanFilename(filename)
+	attachment.CreatedAt = time.Now()
+
+	// Файл ставится на место и получает запись под одной блокировкой:
+	// иначе DeleteAttachment последней ссылки на тот же хеш мог бы удалить
+	// уже существующий файл между проверкой и сохранением записи
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	if err := am.placeBlob(tmp, attachment.SHA256); err != nil {
+		return nil, err
+	}
+	if am.storage != nil {
+		id, err := am.storage.CreateAttachment(attachment)
+		if err != nil {
+			return nil, err
+		}
+		attachment.ID = id
+	} else {
+		attachment.ID = am.nextID
+		am.nextID++
+		am.attachments[attachment.ID] = *attachment
+	}
+	logger.Info(context.Background(), "Файл прикреплен к задаче", "attachmentID", attachment.ID, "taskID", taskID,
+		"userID", userID, "size", attachment.Size, "type", attachment.ContentType)
+	return attachment, nil
+}
+
+// receiveBlob читает содержимое во временный файл, считая хеш и размер,
+// и проверяет тип. Возвращает путь к временному файлу: его переносит
+// placeBlob или удаляет вызывающий.
+func (am *AttachmentManager) receiveBlob(content io.Reader) (*Attachment, string, error) {
+	if err := os.MkdirAll(am.dir, 0755); err != nil {
+		return nil, "", err
+	}
+	tmp, err := os.CreateTemp(am.dir, "upload-*")
+	if err != nil {
+		return nil, "", err
+	}
+	attachment, err := readBlob(tmp, content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return nil, "", err
+	}
+	return attachment, tmp.Name(), nil
+}
+
+// readBlob копирует содержимое в tmp и определяет хеш, размер и тип
+func readBlob(tmp *os.File, content io.Reader) (*Attachment, error) {
+	hash := sha256.New()
+	head := &prefixWriter{limit: 512}
+	size, err := io.Copy(io.MultiWriter(tmp, hash, head), io.LimitReader(content, MaxAttachmentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		return nil, Invalid("файл пустой")
+	}
+	if size > MaxAttachmentSize {
+		return nil, Invalid("файл больше %d МБ", MaxAttachmentSize>>20)
+	}
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head.data))
+	if !attachmentTypes[contentType] {
+		return nil, Invalid("тип файла %s не поддерживается: можно прикрепить изображения PNG, JPEG, GIF, WebP, PDF и текст", contentType)
+	}
+	return &Attachment{ContentType: contentType, Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// placeBlob переносит временный файл на место по хешу, если такого
+// содержимого еще нет. Вызывается под am.mu.
+func (am *AttachmentManager) placeBlob(tmp, hash string) error {
+	path := am.blobPath(hash)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return os.Rename(tmp, path)
+	}
+	return nil
+}
+
+// prefixWriter запоминает первые limit байт потока для определения типа
+type prefixWriter struct {
+	limit int
+	data  []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	if rest := w.limit - len(w.data); rest > 0 {
+		if len(p) < rest {
+			rest = len(p)
+		}
+		w.data = append(w.data, p[:rest]...)
+	}
+	return len(p), nil
+}
+
+// GetAttachments возвращает вложения задачи от старых к новым
+func (am *AttachmentManager) GetAttachments(userID, taskID int) ([]Attachment, error) {
+	if _, err := am.tasks.AuthorizeTask(userID, taskID, ActionView); err != nil {
+		return nil, err
+	}
+
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	if am.storage != nil {
+		return am.storage.GetAttachments(taskID)
+	}
+	attachments := []Attachment{}
+	for _, attachment := range am.attachments {
+		if attachment.TaskID == taskID {
+			attachments = append(attachments, attachment)
+		}
+	}
+	sort.Slice(attachments, func(i, j int) bool {
+		return attachments[i].ID < attachments[j].ID
+	})
+	return attachments, nil
+}
+
+// OpenAttachment возвращает вложение и его содержимое, если пользователь
+// видит задачу. Содержимое закрывает вызывающий.
+func (am *AttachmentManager) OpenAttachment(userID, id int) (*Attachment, *os.File, error) {
+	attachment, err := am.authorizeAttachment(userID, id, ActionView)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := os.Open(am.blobPath(attachment.SHA256))
+	if os.IsNotExist(err) {
+		return nil, nil, NotFound("файл вложения %d отсутствует", id)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return attachment, file, nil
+}
+
+// DeleteAttachment открепляет файл от задачи. Содержимое удаляется, если
+// на него больше не ссылается ни одно вложение.
+func (am *AttachmentManager) DeleteAttachment(userID, id int) error {
+	attachment, err := am.authorizeAttachment(userID, id, ActionEdit)
+	if err != nil {
+		return err
+	}
+
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	used := 0
+	if am.storage != nil {
+		if err := am.storage.DeleteAttachment(id); err != nil {
+			return err
+		}
+		if used, err = am.storage.CountAttachmentsByHash(attachment.SHA256); err != nil {
+			return err
+		}
+	} else {
+		delete(am.attachments, id)
+		for _, other := range am.attachments {
+			if other.SHA256 == attachment.SHA256 {
+				used++
+			}
+		}
+	}
+	if used == 0 {
+		if err := os.Remove(am.blobPath(attachment.SHA256)); err != nil && !os.IsNotExist(err) {
+			logger.Error(context.Background(), err, "Ошибка удаления файла вложения", "attachmentID", id)
+		}
+	}
+	logger.Info(context.Background(), "Вложение удалено", "attachmentID", id, "userID", userID)
+	return nil
+}
+
+// PruneBlobs удаляет из каталога вложений файлы, на которые не ссылается
+// ни одно вложение (например, после удаления задач). Свежие файлы не
+// трогает: их запись может еще сохраняться. Возвращает число удаленных.
+func (am *AttachmentManager) PruneBlobs() (int, error) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	used := make(map[string]bool)
+	if am.storage != nil {
+		hashes, err := am.storage.GetAttachmentHashes()
+		if err != nil {
+			return 0, err
+		}
+		for _, hash := range hashes {
+			used[hash] = true
+		}
+	} else {
+		for _, attachment := range am.attachments {
+			used[attachment.SHA256] = true
+		}
+	}
+
+	removed := 0
+	cutoff := time.Now().Add(-orphanBlobAge)
+	err := filepath.WalkDir(am.dir, func(path string, entry os.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return filepath.SkipDir
+		}
+		if err != nil || entry.IsDir() || used[entry.Name()] {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	return removed, err
+}
+
+// authorizeAttachment возвращает вложение, если пользователь может
+// выполнить action с его задачей. Не вызывать под am.mu: проверка берет tm.mu.
+func (am *AttachmentManager) authorizeAttachment(userID, id int, action Action) (*Attachment, error) {
+	am.mu.Lock()
+	var attachment Attachment
+	var err error
+	if am.storage != nil {
+		var found *Attachment
+		if found, err = am.storage.GetAttachment(id); err == nil {
+			attachment = *found
+		}
+	} else if found, exists := am.attachments[id]; exists {
+		attachment = found
+	} else {
+		err = NotFound("вложение с ID %d не найдено", id)
+	}
+	am.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := am.tasks.AuthorizeTask(userID, attachment.TaskID, action); err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
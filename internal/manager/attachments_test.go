@@ -0,0 +1,121 @@
+package manager
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pngData - начало PNG-файла: тип определяется по сигнатуре
+var pngData = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR скриншот")
+
+func TestAttachments(t *testing.T) {
+	tm := NewTaskManager()
+	am := NewAttachmentManager(tm, t.TempDir())
+	alice := User{ID: 1, Username: "alice"}
+	bob := User{ID: 2, Username: "bob"}
+	carol := User{ID: 3, Username: "carol"}
+
+	family, _ := tm.CreateProjectForUser(alice.ID, CreateProjectRequest{Name: "Семья"})
+	tm.ShareProjectForUser(alice.ID, family.ID, bob, RoleViewer)
+	task, _ := tm.CreateTaskForUser(alice.ID, "Оплатить счет", nil, UpdateTaskRequest{ProjectID: &family.ID})
+
+	for _, tc := range []struct {
+		name    string
+		userID  int
+		content io.Reader
+		want    error
+	}{
+		{"пустой", alice.ID, strings.NewReader(""), ErrInvalid},
+		{"исполняемый файл", alice.ID, strings.NewReader("MZ\x90\x00\x03"), ErrInvalid},
+		{"HTML", alice.ID, strings.NewReader("<html><script>alert(1)</script>"), ErrInvalid},
+		{"слишком большой", alice.ID, io.MultiReader(bytes.NewReader(pngData), strings.NewReader(strings.Repeat("x", MaxAttachmentSize))), ErrInvalid},
+		{"читатель", bob.ID, bytes.NewReader(pngData), ErrForbidden},
+		{"не участник", carol.ID, bytes.NewReader(pngData), ErrForbidden},
+	} {
+		if _, err := am.AddAttachment(tc.userID, task.ID, "file", tc.content); !errors.Is(err, tc.want) {
+			t.Errorf("%s: ожидалось %v, получено %v", tc.name, tc.want, err)
+		}
+	}
+
+	// Имя очищается от пути, тип определяется по содержимому
+	first, err := am.AddAttachment(alice.ID, task.ID, `C:\Users\alice\счет.png`, bytes.NewReader(pngData))
+	if err != nil || first.Filename != "счет.png" || first.ContentType != "image/png" || first.Size != int64(len(pngData)) {
+		t.Fatalf("Загрузка PNG: %+v, %v", first, err)
+	}
+	duplicate, err := am.AddAttachment(alice.ID, task.ID, "копия.png", bytes.NewReader(pngData))
+	if err != nil || duplicate.SHA256 != first.SHA256 {
+		t.Fatalf("Одинаковое содержимое должно давать один хеш: %+v, %v", duplicate, err)
+	}
+	text, err := am.AddAttachment(alice.ID, task.ID, "заметка.txt", strings.NewReader("Реквизиты"))
+	if err != nil || text.ContentType != "text/plain" || text.Inline() {
+		t.Fatalf("Загрузка текста: %+v, %v", text, err)
+	}
+
+	// Читатель видит и скачивает вложения, но не удаляет
+	attachments, err := am.GetAttachments(bob.ID, task.ID)
+	if err != nil || len(attachments) != 3 || attachments[0].ID != first.ID {
+		t.Fatalf("Вложения для читателя: %+v, %v", attachments, err)
+	}
+	_, file, err := am.OpenAttachment(bob.ID, first.ID)
+	if err != nil {
+		t.Fatalf("Скачивание читателем: %v", err)
+	}
+	data, _ := io.ReadAll(file)
+	file.Close()
+	if !bytes.Equal(data, pngData) {
+		t.Errorf("Содержимое вложения не совпадает: %q", data)
+	}
+	if _, _, err := am.OpenAttachment(carol.ID, first.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Скачивание не участником: ожидался ErrForbidden, получено %v", err)
+	}
+	if err := am.DeleteAttachment(bob.ID, first.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Удаление читателем: ожидался ErrForbidden, получено %v", err)
+	}
+
+	// Файл удаляется вместе с последним вложением, которое на него ссылается
+	if err := am.DeleteAttachment(alice.ID, first.ID); err != nil {
+		t.Fatalf("Удаление вложения: %v", err)
+	}
+	if _, err := os.Stat(am.blobPath(first.SHA256)); err != nil {
+		t.Errorf("Файл нужен копии и должен остаться: %v", err)
+	}
+	if err := am.DeleteAttachment(alice.ID, duplicate.ID); err != nil {
+		t.Fatalf("Удаление копии: %v", err)
+	}
+	if _, err := os.Stat(am.blobPath(first.SHA256)); !os.IsNotExist(err) {
+		t.Errorf("Файл без ссылок должен удалиться: %v", err)
+	}
+	if _, _, err := am.OpenAttachment(alice.ID, first.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Удаленное вложение: ожидался ErrNotFound, получено %v", err)
+	}
+
+	// PruneBlobs убирает старые файлы без записей и не трогает остальные
+	saveBlob := func(content string) *Attachment {
+		blob, tmp, err := am.receiveBlob(strings.NewReader(content))
+		if err != nil {
+			t.Fatalf("Сохранение файла: %v", err)
+		}
+		if err := am.placeBlob(tmp, blob.SHA256); err != nil {
+			t.Fatalf("Сохранение файла: %v", err)
+		}
+		return blob
+	}
+	orphan := saveBlob("Осиротевший файл")
+	fresh := saveBlob("Запись еще сохраняется")
+	old := time.Now().Add(-2 * orphanBlobAge)
+	os.Chtimes(am.blobPath(orphan.SHA256), old, old)
+	os.Chtimes(am.blobPath(text.SHA256), old, old)
+	if removed, err := am.PruneBlobs(); err != nil || removed != 1 {
+		t.Fatalf("PruneBlobs: удалено %d, %v", removed, err)
+	}
+	for _, hash := range []string{text.SHA256, fresh.SHA256} {
+		if _, err := os.Stat(am.blobPath(hash)); err != nil {
+			t.Errorf("Файл %s не должен удаляться: %v", hash, err)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package manager
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"todo-app/internal/logger"
+)
+
+// LegacyDeviceID - пользователь, которому принадлежали все задачи до появления учетных записей
+const LegacyDeviceID = "default_legacy_user"
+
+// SessionTTL - время жизни сессии после входа
+const SessionTTL = 30 * 24 * time.Hour
+
+// ErrInvalidCredentials не уточняет, что именно неверно - логин или пароль
+var ErrInvalidCredentials = errors.New("неверное имя пользователя или пароль")
+
+// ErrTooManyLoginAttempts - вход под логином временно закрыт после серии
+// неверных паролей
+var ErrTooManyLoginAttempts = errors.New("слишком много неудачных попыток входа, попробуйте через минуту")
+
+// Защита от подбора пароля: после maxLoginFailures неудачных попыток
+// подряд каждая следующая закрывает вход под этим логином на loginLockout.
+// Счетчик забывается, если попыток не было loginFailureTTL.
+const (
+	maxLoginFailures = 5
+	loginLockout     = time.Minute
+	loginFailureTTL  = 15 * time.Minute
+)
+
+// loginFailure - неудачные попытки входа под одним логином
+type loginFailure struct {
+	count       int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// dummyPasswordHash сверяется с паролем, если пользователя нет: иначе
+// быстрый ответ выдавал бы, какие имена и email зарегистрированы
+var dummyPasswordHash = sync.OnceValue(func() string {
+	hash, _ := HashPassword("dummy-password")
+	return hash
+})
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{3,32}$`)
+
+// Session - серверная сессия. Сам токен хранится только в cookie.
+type Session struct {
+	TokenHash string
+	UserID    int
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// validateAccount проверяет данные регистрации
+func validateAccount(username, email, password string) error {
+	if !usernamePattern.MatchString(username) {
+		return Invalid("имя пользователя: 3-32 символа, латиница, цифры, точка, дефис или подчеркивание")
+	}
+	if email != "" && (len(email) > 254 || !strings.Contains(email, "@")) {
+		return Invalid("некорректный email")
+	}
+	if len([]rune(password)) < 8 {
+		return Invalid("пароль должен быть не короче 8 символов")
+	}
+	if len(password) > 128 {
+		return Invalid("пароль слишком длинный (максимум 128 байт)")
+	}
+	return nil
+}
+
+// Register создает учетную запись. Задачи пользователя LegacyDeviceID,
+// созданные до появления учетных записей, регистрация не забирает: их
+// передает оператор командой migrate claim-legacy.
+func (um *UserManager) Register(username, email, password string) (*User, error) {
+	username = strings.TrimSpace(username)
+	email = strings.ToLower(strings.TrimSpace(email))
+	if err := validateAccount(username, email, password); err != nil {
+		return nil, err
+	}
+
+	// Хеширование медленное намеренно, поэтому делаем его без блокировки
+	hash, err := HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	if um.findByLogin(username) != nil {
+		return nil, Conflict("имя пользователя уже занято")
+	}
+	if email != "" && um.findByLogin(email) != nil {
+		return nil, Conflict("email уже зарегистрирован")
+	}
+
+	now := time.Now()
+	user := &User{
+		DeviceID:     um.GenerateDeviceID(),
+		Username:     username,
+		Email:        email,
+		PasswordHash: hash,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+
+	if um.storage != nil {
+		id, err := um.storage.CreateUser(user)
+		if err != nil {
+			return nil, err
+		}
+		user.ID = id
+	} else {
+		user.ID = um.nextID
+		um.users[user.ID] = user
+		um.nextID++
+	}
+
+	logger.Info(context.Background(), "Зарегистрирован пользователь", "userID", user.ID, "username", username)
+	return user, nil
+}
+
+// Authenticate проверяет имя пользователя (или email) и пароль. После
+// maxLoginFailures неверных паролей подряд вход под этим логином временно
+// закрыт - ErrTooManyLoginAttempts.
+func (um *UserManager) Authenticate(login, password string) (*User, error) {
+	login = strings.TrimSpace(login)
+	key := strings.ToLower(login)
+
+	um.mu.Lock()
+	user := um.findByLogin(login)
+	locked := time.Now().Before(um.loginFailures[key].lockedUntil)
+	um.mu.Unlock()
+
+	if locked {
+		return nil, ErrTooManyLoginAttempts
+	}
+
+	// Неизвестный пользователь проверяется так же долго, как известный
+	hash := dummyPasswordHash()
+	if user != nil && user.PasswordHash != "" {
+		hash = user.PasswordHash
+	}
+	if !CheckPassword(hash, password) || user == nil || user.PasswordHash == "" {
+		um.loginFailed(key)
+		return nil, ErrInvalidCredentials
+	}
+
+	um.mu.Lock()
+	delete(um.loginFailures, key)
+	um.mu.Unlock()
+	return user, nil
+}
+
+// loginFailed учитывает неудачную попытку входа под логином key
+func (um *UserManager) loginFailed(key string) {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	now := time.Now()
+	// Старые счетчики убираются не чаще раза в loginFailureTTL, чтобы
+	// перебор несуществующих логинов не копил их в памяти
+	if now.After(um.loginFailuresPruned.Add(loginFailureTTL)) {
+		for login, failure := range um.loginFailures {
+			if now.Sub(failure.last) > loginFailureTTL {
+				delete(um.loginFailures, login)
+			}
+		}
+		um.loginFailuresPruned = now
+	}
+
+	failure := um.loginFailures[key]
+	if now.Sub(failure.last) > loginFailureTTL {
+		failure = loginFailure{}
+	}
+	failure.count++
+	failure.last = now
+	if failure.count >= maxLoginFailures {
+		failure.lockedUntil = now.Add(loginLockout)
+		logger.Info(context.Background(), "Вход временно закрыт после неудачных попыток",
+			"login", key, "failures", failure.count)
+	}
+	um.loginFailures[key] = failure
+}
+
+// CreateSession открывает сессию и возвращает токен для cookie
+func (um *UserManager) CreateSession(userID int) (string, *Session, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	now := time.Now().UTC()
+	session := &Session{
+		TokenHash: hashToken(token),
+		UserID:    userID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(SessionTTL),
+	}
+
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	if um.storage != nil {
+		if err := um.storage.CreateSession(session); err != nil {
+			return "", nil, err
+		}
+	} else {
+		um.sessions[session.TokenHash] = *session
+	}
+	return token, session, nil
+}
+
+// GetUserBySession возвращает пользователя по токену из cookie
+func (um *UserManager) GetUserBySession(token string) (*User, error) {
+	if token == "" {
+		return nil, NotFound("сессия не найдена")
+	}
+
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	now := time.Now().UTC()
+	if um.storage != nil {
+		return um.storage.GetUserBySession(hashToken(token), now)
+	}
+
+	session, exists := um.sessions[hashToken(token)]
+	if !exists || !session.ExpiresAt.After(now) {
+		return nil, NotFound("сессия не найдена или истекла")
+	}
+	user, exists := um.users[session.UserID]
+	if !exists {
+		return nil, NotFound("пользователь не найден")
+	}
+	return user, nil
+}
+
+// DeleteSession завершает сессию (выход)
+func (um *UserManager) DeleteSession(token string) error {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	if um.storage != nil {
+		return um.storage.DeleteSession(hashToken(token))
+	}
+	delete(um.sessions, hashToken(token))
+	return nil
+}
+
+// CleanupSessions удаляет истекшие сессии
+func (um *UserManager) CleanupSessions() (int, error) {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	now := time.Now().UTC()
+	if um.storage != nil {
+		return um.storage.DeleteExpiredSessions(now)
+	}
+
+	removed := 0
+	for hash, session := range um.sessions {
+		if !session.ExpiresAt.After(now) {
+			delete(um.sessions, hash)
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// GetUserByUsername ищет учетную запись по имени без учета регистра;
+// ведущий @ (как в упоминаниях) отбрасывается
+func (um *UserManager) GetUserByUsername(username string) (*User, error) {
+	username = strings.TrimPrefix(strings.TrimSpace(username), "@")
+
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	user := um.findByLogin(username)
+	if user == nil || !strings.EqualFold(user.Username, username) {
+		return nil, NotFound("пользователь %q не найден", username)
+	}
+	return user, nil
+}
+
+// findByLogin ищет пользователя по имени или email; вызывается под um.mu
+func (um *UserManager) findByLogin(login string) *User {
+	if login == "" {
+		return nil
+	}
+	if um.storage != nil {
+		user, err := um.storage.GetUserByLogin(login)
+		if err != nil {
+			return nil
+		}
+		return user
+	}
+	for _, user := range um.users {
+		if strings.EqualFold(user.Username, login) || strings.EqualFold(user.Email, login) {
+			return user
+		}
+	}
+	return nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,172 @@
+package manager
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPasswordHash(t *testing.T) {
+	hash, err := HashPassword("секретный пароль")
+	if err != nil {
+		t.Fatalf("Ошибка хеширования: %v", err)
+	}
+	if !strings.HasPrefix(hash, "pbkdf2-sha256$") || strings.Contains(hash, "секретный") {
+		t.Errorf("Неожиданный формат хеша: %s", hash)
+	}
+	if !CheckPassword(hash, "секретный пароль") {
+		t.Error("Верный пароль не прошел проверку")
+	}
+	if CheckPassword(hash, "другой пароль") {
+		t.Error("Неверный пароль прошел проверку")
+	}
+	if CheckPassword("мусор", "секретный пароль") {
+		t.Error("Хеш неизвестного формата не должен проходить проверку")
+	}
+
+	again, _ := HashPassword("секретный пароль")
+	if again == hash {
+		t.Error("У одинаковых паролей должна быть разная соль")
+	}
+}
+
+func TestRegisterAndAuthenticate(t *testing.T) {
+	um := NewUserManager(nil)
+
+	tests := []struct {
+		name     string
+		username string
+		email    string
+		password string
+	}{
+		{"Короткое имя", "ab", "", "password123"},
+		{"Недопустимые символы", "имя пользователя", "", "password123"},
+		{"Короткий пароль", "alice", "", "short"},
+		{"Плохой email", "alice", "alice.example.com", "password123"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := um.Register(tt.username, tt.email, tt.password); err == nil {
+				t.Error("Ожидалась ошибка валидации")
+			}
+		})
+	}
+
+	user, err := um.Register("alice", "Alice@Example.com", "password123")
+	if err != nil {
+		t.Fatalf("Ошибка регистрации: %v", err)
+	}
+	if user.Email != "alice@example.com" {
+		t.Errorf("Email должен сохраняться в нижнем регистре, получено %q", user.Email)
+	}
+
+	if _, err := um.Register("ALICE", "", "password123"); !errors.Is(err, ErrConflict) {
+		t.Errorf("Ожидался ErrConflict для занятого имени, получено %v", err)
+	}
+	if _, err := um.Register("alice2", "alice@example.com", "password123"); !errors.Is(err, ErrConflict) {
+		t.Errorf("Ожидался ErrConflict для занятого email, получено %v", err)
+	}
+
+	for _, login := range []string{"alice", "Alice", "alice@example.com"} {
+		if got, err := um.Authenticate(login, "password123"); err != nil || got.ID != user.ID {
+			t.Errorf("Вход по %q: ожидался пользователь %d, получено %v, %v", login, user.ID, got, err)
+		}
+	}
+	if _, err := um.Authenticate("alice", "wrong-password"); err != ErrInvalidCredentials {
+		t.Errorf("Ожидалась ErrInvalidCredentials, получено %v", err)
+	}
+	if _, err := um.Authenticate("nobody", "password123"); err != ErrInvalidCredentials {
+		t.Errorf("Ожидалась ErrInvalidCredentials для неизвестного пользователя, получено %v", err)
+	}
+}
+
+func TestAuthenticateLockout(t *testing.T) {
+	um := NewUserManager(nil)
+	user, _ := um.Register("alice", "alice@example.com", "password123")
+
+	// Неизвестный логин закрывается так же, как существующий
+	for _, login := range []string{"Alice", "nobody"} {
+		for i := 0; i < maxLoginFailures; i++ {
+			if _, err := um.Authenticate(login, "wrong-password"); err != ErrInvalidCredentials {
+				t.Fatalf("%s, попытка %d: ожидалась ErrInvalidCredentials, получено %v", login, i+1, err)
+			}
+		}
+	}
+	if _, err := um.Authenticate("alice", "password123"); err != ErrTooManyLoginAttempts {
+		t.Errorf("Ожидалась ErrTooManyLoginAttempts даже с верным паролем, получено %v", err)
+	}
+	if _, err := um.Authenticate("nobody", "password123"); err != ErrTooManyLoginAttempts {
+		t.Errorf("Ожидалась ErrTooManyLoginAttempts для неизвестного логина, получено %v", err)
+	}
+
+	// После паузы вход открывается, успешный вход сбрасывает счетчик
+	failure := um.loginFailures["alice"]
+	failure.lockedUntil = time.Now()
+	um.loginFailures["alice"] = failure
+	if got, err := um.Authenticate("alice", "password123"); err != nil || got.ID != user.ID {
+		t.Fatalf("Вход после паузы: %v, %v", got, err)
+	}
+	if _, exists := um.loginFailures["alice"]; exists {
+		t.Error("Успешный вход должен сбросить счетчик неудачных попыток")
+	}
+}
+
+func TestRegisterLeavesLegacyTasks(t *testing.T) {
+	um := NewUserManager(nil)
+	tm := NewTaskManager()
+	legacy, _ := um.CreateUser(LegacyDeviceID, 0)
+	tm.AddTaskForUser(legacy.ID, "Задача до учетных записей", nil)
+
+	for _, username := range []string{"owner", "colleague"} {
+		user, err := um.Register(username, "", "password123")
+		if err != nil {
+			t.Fatalf("Ошибка регистрации: %v", err)
+		}
+		if user.ID == legacy.ID {
+			t.Errorf("%s занял пользователя %s", username, LegacyDeviceID)
+		}
+		if tasks, _ := tm.GetAllTasksForUser(user.ID); len(tasks) != 0 {
+			t.Errorf("%s видит задачи без владельца: %+v", username, tasks)
+		}
+	}
+}
+
+func TestSessions(t *testing.T) {
+	um := NewUserManager(nil)
+	user, _ := um.Register("bob", "", "password123")
+
+	token, session, err := um.CreateSession(user.ID)
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+	if session.TokenHash == token {
+		t.Error("Токен не должен храниться в открытом виде")
+	}
+
+	got, err := um.GetUserBySession(token)
+	if err != nil || got.ID != user.ID {
+		t.Fatalf("Ожидался пользователь %d, получено %v, %v", user.ID, got, err)
+	}
+	if _, err := um.GetUserBySession("чужой-токен"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидался ErrNotFound для неизвестного токена, получено %v", err)
+	}
+
+	if err := um.DeleteSession(token); err != nil {
+		t.Fatalf("Ошибка удаления сессии: %v", err)
+	}
+	if _, err := um.GetUserBySession(token); !errors.Is(err, ErrNotFound) {
+		t.Error("После выхода сессия должна быть недействительна")
+	}
+
+	// Истекшая сессия не принимается и удаляется при очистке
+	token, session, _ = um.CreateSession(user.ID)
+	session.ExpiresAt = time.Now().Add(-time.Minute)
+	um.sessions[session.TokenHash] = *session
+	if _, err := um.GetUserBySession(token); !errors.Is(err, ErrNotFound) {
+		t.Error("Истекшая сессия должна быть недействительна")
+	}
+	if removed, _ := um.CleanupSessions(); removed != 1 {
+		t.Errorf("Ожидалось удаление 1 сессии, удалено %d", removed)
+	}
+}
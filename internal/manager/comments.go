@@ -0,0 +1,201 @@
+package manager
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"todo-app/internal/logger"
+)
+
+// maxCommentLength - предельная длина комментария в символах
+const maxCommentLength = 4000
+
+// Comment - комментарий к задаче. Комментировать может любой, кто видит
+// задачу, менять и удалять комментарий - только его автор.
+type Comment struct {
+	ID        int       `json:"id"`
+	TaskID    int       `json:"task_id"`
+	UserID    int       `json:"user_id"`
+	Username  string    `json:"username,omitempty"` // Имя автора на момент чтения
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type CommentManager struct {
+	mu       sync.Mutex
+	comments map[int]Comment
+	nextID   int
+	storage  Storage
+	tasks    *TaskManager // Проверяет, что пользователь видит задачу
+}
+
+func NewCommentManager(tasks *TaskManager) *CommentManager {
+	return &CommentManager{
+		comments: make(map[int]Comment),
+		nextID:   1,
+		tasks:    tasks,
+	}
+}
+
+func NewCommentManagerWithStorage(storage Storage, tasks *TaskManager) *CommentManager {
+	cm := NewCommentManager(tasks)
+	cm.storage = storage
+	return cm
+}
+
+// prepareComment обрезает пробелы и проверяет длину текста
+func prepareComment(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", Invalid("текст комментария обязателен")
+	}
+	if len([]rune(body)) > maxCommentLength {
+		return "", Invalid("комментарий не может превышать %d символов", maxCommentLength)
+	}
+	return body, nil
+}
+
+// AddComment добавляет комментарий автора к задаче, которую он видит
+func (cm *CommentManager) AddComment(author User, taskID int, body string) (*Comment, error) {
+	body, err := prepareComment(body)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := cm.tasks.AuthorizeTask(author.ID, taskID, ActionView); err != nil {
+		return nil, err
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	now := time.Now()
+	comment := Comment{
+		TaskID:    taskID,
+		UserID:    author.ID,
+		Username:  author.Username,
+		Body:      body,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if cm.storage != nil {
+		id, err := cm.storage.CreateComment(&comment)
+		if err != nil {
+			return nil, err
+		}
+		comment.ID = id
+	} else {
+		comment.ID = cm.nextID
+		cm.nextID++
+		cm.comments[comment.ID] = comment
+	}
+	logger.Info(context.Background(), "Комментарий добавлен", "commentID", comment.ID, "taskID", taskID, "userID", author.ID)
+	return &comment, nil
+}
+
+// GetComments возвращает комментарии задачи от старых к новым
+func (cm *CommentManager) GetComments(userID, taskID int) ([]Comment, error) {
+	if _, err := cm.tasks.AuthorizeTask(userID, taskID, ActionView); err != nil {
+		return nil, err
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if cm.storage != nil {
+		return cm.storage.GetComments(taskID)
+	}
+	comments := []Comment{}
+	for _, comment := range cm.comments {
+		if comment.TaskID == taskID {
+			comments = append(comments, comment)
+		}
+	}
+	sort.Slice(comments, func(i, j int) bool {
+		if !comments[i].CreatedAt.Equal(comments[j].CreatedAt) {
+			return comments[i].CreatedAt.Before(comments[j].CreatedAt)
+		}
+		return comments[i].ID < comments[j].ID
+	})
+	return comments, nil
+}
+
+// UpdateComment меняет текст комментария; доступно только автору
+func (cm *CommentManager) UpdateComment(userID, id int, body string) (*Comment, error) {
+	body, err := prepareComment(body)
+	if err != nil {
+		return nil, err
+	}
+	comment, err := cm.authorizeComment(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	comment.Body = body
+	comment.UpdatedAt = time.Now()
+	if cm.storage != nil {
+		if err := cm.storage.UpdateComment(comment); err != nil {
+			return nil, err
+		}
+	} else {
+		cm.comments[id] = *comment
+	}
+	logger.Info(context.Background(), "Комментарий изменен", "commentID", id, "userID", userID)
+	return comment, nil
+}
+
+// DeleteComment удаляет комментарий; доступно только автору
+func (cm *CommentManager) DeleteComment(userID, id int) error {
+	if _, err := cm.authorizeComment(userID, id); err != nil {
+		return err
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if cm.storage != nil {
+		if err := cm.storage.DeleteComment(id); err != nil {
+			return err
+		}
+	} else {
+		delete(cm.comments, id)
+	}
+	logger.Info(context.Background(), "Комментарий удален", "commentID", id, "userID", userID)
+	return nil
+}
+
+// authorizeComment возвращает комментарий, если пользователь - его автор
+// и все еще видит задачу. Не вызывать под cm.mu: проверка берет tm.mu.
+func (cm *CommentManager) authorizeComment(userID, id int) (*Comment, error) {
+	cm.mu.Lock()
+	var comment Comment
+	var err error
+	if cm.storage != nil {
+		var found *Comment
+		if found, err = cm.storage.GetComment(id); err == nil {
+			comment = *found
+		}
+	} else if found, exists := cm.comments[id]; exists {
+		comment = found
+	} else {
+		err = NotFound("комментарий с ID %d не найден", id)
+	}
+	cm.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := cm.tasks.AuthorizeTask(userID, comment.TaskID, ActionView); err != nil {
+		return nil, err
+	}
+	if comment.UserID != userID {
+		return nil, Forbidden("комментарий с ID %d оставил другой пользователь", id)
+	}
+	return &comment, nil
+}
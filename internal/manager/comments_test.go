@@ -0,0 +1,77 @@
+package manager
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestComments(t *testing.T) {
+	tm := NewTaskManager()
+	cm := NewCommentManager(tm)
+	alice := User{ID: 1, Username: "alice"}
+	bob := User{ID: 2, Username: "bob"}
+	carol := User{ID: 3, Username: "carol"}
+
+	family, _ := tm.CreateProjectForUser(alice.ID, CreateProjectRequest{Name: "Семья"})
+	tm.ShareProjectForUser(alice.ID, family.ID, bob, RoleViewer)
+	task, _ := tm.CreateTaskForUser(alice.ID, "Купить продукты", nil, UpdateTaskRequest{ProjectID: &family.ID})
+
+	for _, tc := range []struct {
+		name   string
+		author User
+		body   string
+		want   error
+	}{
+		{"пустой", alice, "   ", ErrInvalid},
+		{"слишком длинный", alice, strings.Repeat("я", maxCommentLength+1), ErrInvalid},
+		{"не участник", carol, "Можно я?", ErrForbidden},
+	} {
+		if _, err := cm.AddComment(tc.author, task.ID, tc.body); !errors.Is(err, tc.want) {
+			t.Errorf("%s: ожидалось %v, получено %v", tc.name, tc.want, err)
+		}
+	}
+
+	// Читатель тоже участвует в обсуждении
+	first, err := cm.AddComment(alice, task.ID, " Молоко есть? ")
+	if err != nil || first.Body != "Молоко есть?" || first.Username != "alice" {
+		t.Fatalf("Комментарий автора задачи: %+v, %v", first, err)
+	}
+	reply, err := cm.AddComment(bob, task.ID, "Нет")
+	if err != nil {
+		t.Fatalf("Комментарий читателя: %v", err)
+	}
+
+	if _, err := cm.UpdateComment(alice.ID, reply.ID, "Есть"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Изменение чужого комментария: ожидался ErrForbidden, получено %v", err)
+	}
+	if err := cm.DeleteComment(alice.ID, reply.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Удаление чужого комментария: ожидался ErrForbidden, получено %v", err)
+	}
+	edited, err := cm.UpdateComment(bob.ID, reply.ID, "Нет, купи две")
+	if err != nil || edited.Body != "Нет, купи две" || edited.UpdatedAt.Before(edited.CreatedAt) {
+		t.Errorf("Изменение своего комментария: %+v, %v", edited, err)
+	}
+
+	comments, err := cm.GetComments(bob.ID, task.ID)
+	if err != nil || len(comments) != 2 || comments[0].ID != first.ID || comments[1].Body != "Нет, купи две" {
+		t.Fatalf("Обсуждение: %+v, %v", comments, err)
+	}
+	if _, err := cm.GetComments(carol.ID, task.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Чтение не участником: ожидался ErrForbidden, получено %v", err)
+	}
+
+	if err := cm.DeleteComment(bob.ID, reply.ID); err != nil {
+		t.Fatalf("Удаление своего комментария: %v", err)
+	}
+	if err := cm.DeleteComment(bob.ID, reply.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Повторное удаление: ожидался ErrNotFound, получено %v", err)
+	}
+
+	// Вышедший из проекта не может менять свои старые комментарии
+	old, _ := cm.AddComment(bob, task.ID, "Я выхожу")
+	tm.RemoveMemberForUser(bob.ID, family.ID, bob.ID)
+	if _, err := cm.UpdateComment(bob.ID, old.ID, "Передумал"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Изменение после выхода: ожидался ErrForbidden, получено %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Типизированные ошибки доступа. Хранилища и менеджеры оборачивают их,
+// а обработчики HTTP проверяют через errors.Is и выбирают статус ответа.
+var (
+	ErrNotFound  = errors.New("не найдено")
+	ErrForbidden = errors.New("доступ запрещен")
+	ErrConflict  = errors.New("конфликт")
+	ErrInvalid   = errors.New("некорректные данные")
+)
+
+// AccessError - ошибка доступа к объекту с понятным пользователю сообщением
+type AccessError struct {
+	Kind    error // ErrNotFound, ErrForbidden, ErrConflict или ErrInvalid
+	Message string
+}
+
+func (e *AccessError) Error() string {
+	return e.Message
+}
+
+func (e *AccessError) Unwrap() error {
+	return e.Kind
+}
+
+// NotFound возвращает ошибку, совместимую с ErrNotFound
+func NotFound(format string, args ...interface{}) error {
+	return &AccessError{Kind: ErrNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+// Forbidden возвращает ошибку, совместимую с ErrForbidden
+func Forbidden(format string, args ...interface{}) error {
+	return &AccessError{Kind: ErrForbidden, Message: fmt.Sprintf(format, args...)}
+}
+
+// Conflict возвращает ошибку, совместимую с ErrConflict
+func Conflict(format string, args ...interface{}) error {
+	return &AccessError{Kind: ErrConflict, Message: fmt.Sprintf(format, args...)}
+}
+
+// Invalid возвращает ошибку проверки входных данных, совместимую с ErrInvalid
+func Invalid(format string, args ...interface{}) error {
+	return &AccessError{Kind: ErrInvalid, Message: fmt.Sprintf(format, args...)}
+}
+
+// VersionConflictError - задачу изменили после того, как клиент прочитал
+// версию из UpdateTaskRequest.Version. Current - задача в текущем виде,
+// чтобы показать, что поменялось, и перезаписать с ее версией.
+type VersionConflictError struct {
+	Current  Task
+	Expected int
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("задача #%d изменена в другом месте: версия %d, ожидалась %d",
+		e.Current.ID, e.Current.Version, e.Expected)
+}
+
+// Unwrap делает ошибку совместимой с ErrConflict
+func (e *VersionConflictError) Unwrap() error {
+	return ErrConflict
+}
+
+// checkVersion сравнивает ожидаемую версию задачи с текущей
+func checkVersion(task Task, expected *int) error {
+	if expected != nil && *expected != task.Version {
+		return &VersionConflictError{Current: task, Expected: *expected}
+	}
+	return nil
+}
@@ -0,0 +1,595 @@
+package manager
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"todo-app/internal/logger"
+)
+
+// ExportVersion - версия формата выгрузки. Импорт принимает только
+// документы этой версии; при несовместимом изменении формата номер растет.
+const ExportVersion = 1
+
+// Export - данные пользователя в переносимом виде: между установками или из
+// памяти в SQLite. ID внутри документа только связывают записи между собой,
+// при импорте выдаются новые.
+type Export struct {
+	Version    int              `json:"version"`
+	ExportedAt time.Time        `json:"exported_at"`
+	Projects   []ExportProject  `json:"projects"`
+	Tasks      []ExportTask     `json:"tasks"`
+	SubTasks   []ExportSubTask  `json:"subtasks"`
+	Reminders  []ExportReminder `json:"reminders"`
+	Tags       []string         `json:"tags"` // теги задач справочно: при импорте теги берутся из задач
+}
+
+type ExportProject struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Color    string `json:"color"`
+	Archived bool   `json:"archived,omitempty"`
+	Position int    `json:"position"`
+	Inbox    bool   `json:"inbox,omitempty"` // при импорте становится Входящими пользователя
+}
+
+type ExportTask struct {
+	ID          int        `json:"id"`
+	ProjectID   int        `json:"project_id,omitempty"` // 0 - во Входящие
+	Description string     `json:"description"`
+	Completed   bool       `json:"completed,omitempty"`
+	Priority    Priority   `json:"priority,omitempty"`
+	DueDate     *time.Time `json:"due_date,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	Recurrence  string     `json:"recurrence,omitempty"`
+	SeriesID    int        `json:"series_id,omitempty"` // ID первой задачи серии в документе
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+type ExportSubTask struct {
+	ID          int       `json:"id"`
+	TaskID      int       `json:"task_id"`
+	Description string    `json:"description"`
+	Completed   bool      `json:"completed,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ExportReminder - ожидающее напоминание, созданное пользователем: за
+// DaysBefore дней до срока задачи или, без DaysBefore, на TriggerTime
+type ExportReminder struct {
+	TaskID      int       `json:"task_id"`
+	Message     string    `json:"message"`
+	DaysBefore  *int      `json:"days_before,omitempty"`
+	TriggerTime time.Time `json:"trigger_time"`
+}
+
+// ImportMode - как импорт обходится с данными, которые уже есть
+type ImportMode string
+
+const (
+	// ImportMerge добавляет задачи к существующим; проект с тем же
+	// названием, что и свой существующий, не создается заново
+	ImportMerge ImportMode = "merge"
+	// ImportReplace сначала перемещает задачи пользователя в корзину и
+	// удаляет его проекты, кроме Входящих; задачи участников этих проектов
+	// переходят во Входящие авторов
+	ImportReplace ImportMode = "replace"
+)
+
+// Valid сообщает, является ли значение известным режимом импорта
+func (m ImportMode) Valid() bool {
+	return m == ImportMerge || m == ImportReplace
+}
+
+// ImportReport - что сделал импорт, а с DryRun - что сделал бы
+type ImportReport struct {
+	Mode             ImportMode  `json:"mode"`
+	DryRun           bool        `json:"dry_run"`
+	ProjectsCreated  int         `json:"projects_created"`
+	ProjectsMerged   int         `json:"projects_merged"` // совпали с существующими: Входящие и проекты с тем же названием
+	TasksCreated     int         `json:"tasks_created"`
+	SubTasksCreated  int         `json:"subtasks_created"`
+	RemindersCreated int         `json:"reminders_created"`
+	RemindersSkipped int         `json:"reminders_skipped"` // время прошло или задача выполнена
+	Tags             int         `json:"tags"`
+	TasksTrashed     int         `json:"tasks_trashed"`         // replace: прежние задачи пользователя, перемещенные в корзину
+	TasksMoved       int         `json:"tasks_moved"`           // replace: задачи участников, перенесенные во Входящие авторов
+	ProjectsDeleted  int         `json:"projects_deleted"`      // replace: прежние проекты
+	ProjectIDs       map[int]int `json:"project_ids,omitempty"` // новый ID по ID из документа; без DryRun
+	TaskIDs          map[int]int `json:"task_ids,omitempty"`
+}
+
+// ExportForUser выгружает данные пользователя: его собственные проекты,
+// задачи этих проектов кроме корзины (включая задачи участников), их
+// подзадачи и ожидающие напоминания, которые пользователь создал сам.
+// Общие проекты других пользователей не выгружаются.
+func (tm *TaskManager) ExportForUser(userID int) (*Export, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if _, err := tm.inbox(userID); err != nil {
+		return nil, err
+	}
+	projects, err := tm.userProjects(userID)
+	if err != nil {
+		return nil, err
+	}
+	tasks, subtasks, err := tm.syncSnapshot(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &Export{
+		Version:    ExportVersion,
+		ExportedAt: time.Now(),
+		Projects:   []ExportProject{},
+		Tasks:      []ExportTask{},
+		SubTasks:   []ExportSubTask{},
+		Reminders:  []ExportReminder{},
+		Tags:       []string{},
+	}
+	own := make(map[int]bool)
+	for _, p := range projects {
+		if p.Role != RoleOwner {
+			continue
+		}
+		own[p.ID] = true
+		data.Projects = append(data.Projects, ExportProject{ID: p.ID, Name: p.Name, Color: p.Color,
+			Archived: p.Archived, Position: p.Position, Inbox: p.Inbox})
+	}
+
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+	exported := make(map[int]bool)
+	var taskIDs []int
+	tags := make(map[string]string)
+	for _, task := range tasks {
+		if !own[task.ProjectID] && (task.ProjectID != 0 || task.UserID != userID) {
+			continue
+		}
+		exported[task.ID] = true
+		taskIDs = append(taskIDs, task.ID)
+		item := ExportTask{ID: task.ID, ProjectID: task.ProjectID, Description: task.Description,
+			Completed: task.Completed, Priority: task.Priority, Tags: task.Tags, Recurrence: task.Recurrence,
+			SeriesID: task.SeriesID, CreatedAt: task.CreatedAt, UpdatedAt: task.UpdatedAt}
+		if !task.DueDate.IsZero() {
+			due := task.DueDate
+			item.DueDate = &due
+		}
+		for _, tag := range task.Tags {
+			if _, ok := tags[tagKey(tag)]; !ok {
+				tags[tagKey(tag)] = tag
+			}
+		}
+		data.Tasks = append(data.Tasks, item)
+	}
+	for _, tag := range tags {
+		data.Tags = append(data.Tags, tag)
+	}
+	sort.Slice(data.Tags, func(i, j int) bool { return tagKey(data.Tags[i]) < tagKey(data.Tags[j]) })
+
+	sort.Slice(subtasks, func(i, j int) bool { return subtasks[i].ID < subtasks[j].ID })
+	for _, st := range subtasks {
+		if exported[st.TaskID] {
+			data.SubTasks = append(data.SubTasks, ExportSubTask{ID: st.ID, TaskID: st.TaskID,
+				Description: st.Description, Completed: st.Completed, CreatedAt: st.CreatedAt, UpdatedAt: st.UpdatedAt})
+		}
+	}
+
+	reminders, err := tm.exportReminders(userID, taskIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range reminders {
+		data.Reminders = append(data.Reminders, ExportReminder{TaskID: r.TaskID, Message: r.Message,
+			DaysBefore: r.DaysBefore, TriggerTime: r.TriggerTime})
+	}
+	logger.Info(context.Background(), "Данные пользователя выгружены", "userID", userID,
+		"projects", len(data.Projects), "tasks", len(data.Tasks))
+	return data, nil
+}
+
+// exportReminders - ожидающие напоминания пользователя к задачам taskIDs,
+// кроме автонапоминаний: их заново создаст импорт задач.
+// Вызывающий должен удерживать tm.mu.
+func (tm *TaskManager) exportReminders(userID int, taskIDs []int) ([]Reminder, error) {
+	var all []Reminder
+	switch {
+	case tm.storage != nil:
+		for _, id := range taskIDs {
+			reminders, err := tm.storage.GetTaskReminders(userID, id)
+			if err != nil {
+				return nil, err
+			}
+			all = append(all, reminders...)
+		}
+	case tm.reminders != nil:
+		tm.reminders.mu.Lock()
+		for _, id := range taskIDs {
+			reminders, _ := tm.reminders.taskReminders(userID, id)
+			all = append(all, reminders...)
+		}
+		tm.reminders.mu.Unlock()
+	}
+	var result []Reminder
+	for _, r := range all {
+		if !r.Auto && r.Status == ReminderPending && r.Type != ReminderTypeAssignment {
+			result = append(result, r)
+		}
+	}
+	return result, nil
+}
+
+// ImportForUser загружает выгрузку в учетную запись пользователя. Документ
+// сначала проверяется целиком, затем применяется одной транзакцией: при
+// ошибке не остается ничего. С dryRun изменения не сохраняются, а отчет
+// показывает, что было бы сделано.
+func (tm *TaskManager) ImportForUser(userID int, data *Export, mode ImportMode, dryRun bool) (*ImportReport, error) {
+	if !mode.Valid() {
+		return nil, Invalid("режим импорта: ожидается merge или replace")
+	}
+	skipped, tags, err := prepareImport(data, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	var report *ImportReport
+	if tm.storage != nil {
+		if report, err = tm.storage.ImportUserData(userID, data, mode, dryRun); err != nil {
+			return nil, err
+		}
+	} else if report, err = tm.importToMemory(userID, data, mode, dryRun); err != nil {
+		return nil, err
+	}
+	report.RemindersSkipped += skipped
+	report.Tags = tags
+	if dryRun {
+		report.ProjectIDs, report.TaskIDs = nil, nil
+		return report, nil
+	}
+
+	// Лента синхронизации, автонапоминания и история - как у задач, созданных обычным путем
+	for _, item := range data.Tasks {
+		task, err := tm.findTask(report.TaskIDs[item.ID])
+		if err != nil {
+			return nil, err
+		}
+		tm.syncTask(task.ID)
+		tm.notifyChanged(task)
+		tm.recordEvent(TaskEvent{TaskID: task.ID, UserID: userID, Type: EventCreated, Source: SourceImport,
+			Changes: taskChanges(Task{}, task)})
+	}
+	logger.Info(context.Background(), "Данные пользователя импортированы", "userID", userID, "mode", mode,
+		"projects", report.ProjectsCreated, "tasks", report.TasksCreated, "trashed", report.TasksTrashed)
+	return report, nil
+}
+
+// prepareImport проверяет документ и приводит его к виду, который
+// сохраняется как есть: задачи по возрастанию ID, серии ссылаются на
+// задачу из документа, напоминания со временем срабатывания в будущем.
+// Возвращает число пропущенных напоминаний и число разных тегов.
+func prepareImport(data *Export, now time.Time) (int, int, error) {
+	if data == nil {
+		return 0, 0, Invalid("пустой документ")
+	}
+	if data.Version != ExportVersion {
+		return 0, 0, Invalid("неподдерживаемая версия выгрузки %d, ожидается %d", data.Version, ExportVersion)
+	}
+
+	projects := make(map[int]bool)
+	names := make(map[string]bool)
+	inbox := false
+	for i := range data.Projects {
+		p := &data.Projects[i]
+		if p.ID <= 0 || projects[p.ID] {
+			return 0, 0, Invalid("проект %d: ID должен быть положительным и не повторяться", p.ID)
+		}
+		projects[p.ID] = true
+		if p.Inbox {
+			if inbox {
+				return 0, 0, Invalid("в выгрузке несколько Входящих")
+			}
+			inbox = true
+			continue
+		}
+		name, err := prepareProjectName(p.Name)
+		if err != nil {
+			return 0, 0, Invalid("проект %d: %s", p.ID, err)
+		}
+		color, err := prepareProjectColor(p.Color)
+		if err != nil {
+			return 0, 0, Invalid("проект %d: %s", p.ID, err)
+		}
+		if names[projectNameKey(name)] || projectNameKey(name) == projectNameKey(InboxName) {
+			return 0, 0, Invalid("проект %q встречается дважды", name)
+		}
+		names[projectNameKey(name)] = true
+		p.Name, p.Color = name, color
+	}
+
+	sort.Slice(data.Tasks, func(i, j int) bool { return data.Tasks[i].ID < data.Tasks[j].ID })
+	tasks := make(map[int]*ExportTask)
+	tags := make(map[string]bool)
+	heads := make(map[int]int) // первая задача серии в документе по исходному series_id
+	for i := range data.Tasks {
+		t := &data.Tasks[i]
+		if t.ID <= 0 || tasks[t.ID] != nil {
+			return 0, 0, Invalid("задача %d: ID должен быть положительным и не повторяться", t.ID)
+		}
+		if t.ProjectID != 0 && !projects[t.ProjectID] {
+			return 0, 0, Invalid("задача %d: проекта %d нет в выгрузке", t.ID, t.ProjectID)
+		}
+		description := strings.TrimSpace(t.Description)
+		if t.Priority == "" {
+			t.Priority = PriorityMedium
+		}
+		req := UpdateTaskRequest{Description: &description, Priority: &t.Priority, Tags: &t.Tags, Recurrence: &t.Recurrence}
+		if err := prepareUpdate(&req); err != nil {
+			return 0, 0, Invalid("задача %d: %s", t.ID, err)
+		}
+		t.Description, t.Tags, t.Recurrence = description, *req.Tags, *req.Recurrence
+		for _, tag := range t.Tags {
+			tags[tagKey(tag)] = true
+		}
+		// Первая задача серии могла не попасть в выгрузку (например, она
+		// в корзине): тогда серию возглавляет первая из оставшихся
+		if t.SeriesID != 0 {
+			if head, ok := heads[t.SeriesID]; ok {
+				t.SeriesID = head
+			} else if tasks[t.SeriesID] == nil {
+				heads[t.SeriesID] = t.ID
+				t.SeriesID = t.ID
+			}
+		}
+		if t.CreatedAt.IsZero() {
+			t.CreatedAt = now
+		}
+		if t.UpdatedAt.IsZero() {
+			t.UpdatedAt = t.CreatedAt
+		}
+		tasks[t.ID] = t
+	}
+
+	subtasks := make(map[int]bool)
+	for i := range data.SubTasks {
+		st := &data.SubTasks[i]
+		if st.ID <= 0 || subtasks[st.ID] {
+			return 0, 0, Invalid("подзадача %d: ID должен быть положительным и не повторяться", st.ID)
+		}
+		subtasks[st.ID] = true
+		if tasks[st.TaskID] == nil {
+			return 0, 0, Invalid("подзадача %d: задачи %d нет в выгрузке", st.ID, st.TaskID)
+		}
+		if st.Description = strings.TrimSpace(st.Description); st.Description == "" {
+			return 0, 0, Invalid("подзадача %d: описание обязательно", st.ID)
+		}
+		if st.CreatedAt.IsZero() {
+			st.CreatedAt = now
+		}
+		if st.UpdatedAt.IsZero() {
+			st.UpdatedAt = st.CreatedAt
+		}
+	}
+	sort.Slice(data.SubTasks, func(i, j int) bool { return data.SubTasks[i].ID < data.SubTasks[j].ID })
+
+	skipped := 0
+	reminders := []ExportReminder{}
+	for _, r := range data.Reminders {
+		task := tasks[r.TaskID]
+		if task == nil {
+			return 0, 0, Invalid("напоминание: задачи %d нет в выгрузке", r.TaskID)
+		}
+		if r.Message = strings.TrimSpace(r.Message); r.Message == "" || len(r.Message) > 500 {
+			return 0, 0, Invalid("напоминание к задаче %d: текст обязателен и не длиннее 500 символов", r.TaskID)
+		}
+		if r.DaysBefore != nil {
+			if *r.DaysBefore < 0 || *r.DaysBefore > 365 || task.DueDate == nil {
+				return 0, 0, Invalid("напоминание к задаче %d: за 0-365 дней до срока, и у задачи должен быть срок", r.TaskID)
+			}
+			r.TriggerTime = reminderTriggerTime(*task.DueDate, *r.DaysBefore)
+		}
+		if task.Completed || !r.TriggerTime.After(now) {
+			skipped++
+			continue
+		}
+		reminders = append(reminders, r)
+	}
+	data.Reminders = reminders
+	return skipped, len(tags), nil
+}
+
+// importToMemory - ImportForUser для in-memory режима. Документ уже
+// проверен, поэтому изменения применяются без ошибок посередине.
+// Вызывающий должен удерживать tm.mu.
+func (tm *TaskManager) importToMemory(userID int, data *Export, mode ImportMode, dryRun bool) (*ImportReport, error) {
+	report := &ImportReport{Mode: mode, DryRun: dryRun, ProjectIDs: make(map[int]int), TaskIDs: make(map[int]int)}
+	inbox, err := tm.inbox(userID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	existing := make(map[string]int)
+	deleted := make(map[int]bool)
+	position := 0
+	for id, project := range tm.projects {
+		switch {
+		case project.UserID != userID || project.Inbox:
+		case mode == ImportReplace:
+			deleted[id] = true
+		default:
+			existing[projectNameKey(project.Name)] = id
+			if project.Position >= position {
+				position = project.Position + 1
+			}
+		}
+	}
+
+	if mode == ImportReplace {
+		// Задачи пользователя в его проектах уходят в корзину, как при
+		// удалении. Задачи участников удаляемых проектов не трогаются, а,
+		// как в DeleteProjectForUser, переходят во Входящие авторов.
+		rehome := func(task Task) (Task, error) {
+			if !deleted[task.ProjectID] {
+				return task, nil
+			}
+			authorInbox, err := tm.inbox(task.UserID)
+			if err != nil {
+				return task, err
+			}
+			task.ProjectID = authorInbox.ID
+			if task.AssigneeID != task.UserID {
+				task.AssigneeID = 0
+			}
+			task.Version++
+			return task, nil
+		}
+		for id, task := range tm.tasks {
+			if project, ok := tm.projects[task.ProjectID]; !ok || project.UserID != userID {
+				continue
+			}
+			if task.UserID != userID {
+				report.TasksMoved++
+				if !dryRun {
+					if tm.tasks[id], err = rehome(task); err != nil {
+						return nil, err
+					}
+					tm.syncTask(id)
+				}
+				continue
+			}
+			report.TasksTrashed++
+			if dryRun {
+				continue
+			}
+			if task, err = rehome(task); err != nil {
+				return nil, err
+			}
+			delete(tm.tasks, id)
+			deletedAt := now
+			task.DeletedAt = &deletedAt
+			task.Version++
+			tm.trash[id] = task
+			tm.syncTask(id)
+			tm.notifyDeleted(id)
+		}
+		for id, task := range tm.trash {
+			if deleted[task.ProjectID] && !dryRun {
+				if tm.trash[id], err = rehome(task); err != nil {
+					return nil, err
+				}
+				tm.syncTask(id)
+			}
+		}
+		for id := range deleted {
+			report.ProjectsDeleted++
+			if dryRun {
+				continue
+			}
+			delete(tm.projects, id)
+			delete(tm.members, id)
+			for hash, invite := range tm.invites {
+				if invite.ProjectID == id {
+					delete(tm.invites, hash)
+				}
+			}
+		}
+	}
+
+	for _, p := range data.Projects {
+		if p.Inbox {
+			report.ProjectIDs[p.ID] = inbox.ID
+			report.ProjectsMerged++
+			continue
+		}
+		if id, ok := existing[projectNameKey(p.Name)]; ok {
+			report.ProjectIDs[p.ID] = id
+			report.ProjectsMerged++
+			continue
+		}
+		report.ProjectsCreated++
+		if dryRun {
+			continue
+		}
+		project := Project{UserID: userID, Name: p.Name, Color: p.Color, Archived: p.Archived,
+			Position: position + p.Position, Role: RoleOwner, CreatedAt: now, UpdatedAt: now}
+		if err := tm.saveNewProject(&project); err != nil {
+			return nil, err
+		}
+		report.ProjectIDs[p.ID] = project.ID
+	}
+
+	report.TasksCreated = len(data.Tasks)
+	report.SubTasksCreated = len(data.SubTasks)
+	if tm.reminders != nil {
+		report.RemindersCreated = len(data.Reminders)
+	} else {
+		report.RemindersSkipped = len(data.Reminders)
+	}
+	if dryRun {
+		return report, nil
+	}
+
+	for _, item := range data.Tasks {
+		task := Task{ID: tm.nextID, UserID: userID, Description: item.Description, CreatedAt: item.CreatedAt,
+			UpdatedAt: item.UpdatedAt, Completed: item.Completed, Priority: item.Priority,
+			Tags: tm.canonicalTags(userID, item.Tags), Recurrence: item.Recurrence, ProjectID: inbox.ID, Version: 1}
+		if item.ProjectID != 0 {
+			task.ProjectID = report.ProjectIDs[item.ProjectID]
+		}
+		if item.DueDate != nil {
+			task.DueDate = *item.DueDate
+		}
+		if item.SeriesID == item.ID {
+			task.SeriesID = task.ID
+		} else if item.SeriesID != 0 {
+			task.SeriesID = report.TaskIDs[item.SeriesID]
+		}
+		tm.nextID++
+		tm.tasks[task.ID] = task
+		report.TaskIDs[item.ID] = task.ID
+	}
+
+	if stm := tm.subtasks; stm != nil {
+		stm.mu.Lock()
+		for _, item := range data.SubTasks {
+			stm.subtasks[stm.nextID] = SubTask{ID: stm.nextID, UserID: userID, TaskID: report.TaskIDs[item.TaskID],
+				Description: item.Description, CreatedAt: item.CreatedAt, UpdatedAt: item.UpdatedAt, Completed: item.Completed}
+			stm.nextID++
+		}
+		stm.mu.Unlock()
+	} else {
+		report.SubTasksCreated = 0
+	}
+
+	if rm := tm.reminders; rm != nil {
+		rm.mu.Lock()
+		for _, item := range data.Reminders {
+			reminder := item.Reminder(userID, report.TaskIDs[item.TaskID], now)
+			if err := rm.save(&reminder); err != nil {
+				rm.mu.Unlock()
+				return nil, err
+			}
+		}
+		rm.mu.Unlock()
+	}
+	return report, nil
+}
+
+// Reminder - напоминание из выгрузки для задачи taskID
+func (r ExportReminder) Reminder(userID, taskID int, now time.Time) Reminder {
+	reminder := Reminder{TaskID: taskID, UserID: userID, Type: ReminderTypeCustom, Message: r.Message,
+		TriggerTime: r.TriggerTime, Status: ReminderPending, Channel: "telegram", CreatedAt: now, UpdatedAt: now}
+	if r.DaysBefore != nil {
+		days := *r.DaysBefore
+		reminder.Type = ReminderTypeDeadline
+		reminder.DaysBefore = &days
+	}
+	return reminder
+}
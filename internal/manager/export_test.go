@@ -0,0 +1,113 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExportImport(t *testing.T) {
+	tm := NewTaskManager()
+	tm.SetSubTaskManager(NewSubTaskManager())
+	rm := NewReminderManager(tm)
+	alice := User{ID: 1, Username: "alice"}
+
+	work, _ := tm.CreateProjectForUser(alice.ID, CreateProjectRequest{Name: "Работа"})
+	due := time.Now().Add(72 * time.Hour).Truncate(time.Second)
+	report, _ := tm.CreateTaskForUser(alice.ID, "Отчет", []string{"срочно"}, UpdateTaskRequest{ProjectID: &work.ID, DueDate: &due})
+	weekly := "FREQ=WEEKLY;BYDAY=MO"
+	tm.CreateTaskForUser(alice.ID, "Планерка", []string{"Встречи"}, UpdateTaskRequest{Recurrence: &weekly})
+	trashed, _ := tm.AddTaskForUser(alice.ID, "Старое", nil)
+	tm.DeleteTaskForUser(alice.ID, trashed)
+	tm.subtasks.AddSubTask(alice.ID, report.ID, "Собрать цифры")
+	days := 1
+	if _, err := rm.CreateReminder(alice.ID, report.ID, CreateReminderRequest{DaysBefore: &days, Message: "Сдать отчет"}); err != nil {
+		t.Fatalf("Напоминание: %v", err)
+	}
+
+	data, err := tm.ExportForUser(alice.ID)
+	if err != nil {
+		t.Fatalf("Выгрузка: %v", err)
+	}
+	if data.Version != ExportVersion || len(data.Projects) != 2 || len(data.Tasks) != 2 || len(data.SubTasks) != 1 ||
+		len(data.Reminders) != 1 || len(data.Tags) != 2 || data.Tags[0] != "Встречи" {
+		t.Fatalf("Выгрузка: %+v", data)
+	}
+	// Документ переносится как JSON
+	raw, _ := json.Marshal(data)
+	parse := func() *Export {
+		var doc Export
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			t.Fatalf("Разбор выгрузки: %v", err)
+		}
+		return &doc
+	}
+
+	dst := NewTaskManager()
+	dst.SetSubTaskManager(NewSubTaskManager())
+	dstReminders := NewReminderManager(dst)
+	bob := User{ID: 7, Username: "bob"}
+
+	dry, err := dst.ImportForUser(bob.ID, parse(), ImportMerge, true)
+	if err != nil || !dry.DryRun || dry.TasksCreated != 2 || dry.ProjectsCreated != 1 || dry.RemindersCreated != 1 || dry.TaskIDs != nil {
+		t.Fatalf("Пробный импорт: %+v, %v", dry, err)
+	}
+	if tasks, _ := dst.GetAllTasksForUser(bob.ID); len(tasks) != 0 {
+		t.Fatalf("Пробный импорт сохранил задачи: %+v", tasks)
+	}
+
+	imported, err := dst.ImportForUser(bob.ID, parse(), ImportMerge, false)
+	if err != nil || imported.TasksCreated != 2 || imported.SubTasksCreated != 1 || imported.Tags != 2 {
+		t.Fatalf("Импорт: %+v, %v", imported, err)
+	}
+	copied, err := dst.GetTaskForUser(bob.ID, imported.TaskIDs[report.ID])
+	if err != nil || copied.Description != "Отчет" || !copied.DueDate.Equal(due) || copied.ProjectID != imported.ProjectIDs[work.ID] {
+		t.Fatalf("Задача после импорта: %+v, %v", copied, err)
+	}
+	if reminders, _ := dstReminders.GetReminders(bob.ID, copied.ID); len(reminders) == 0 {
+		t.Error("Напоминание не импортировано")
+	}
+	if events, _ := dst.GetTaskEvents(bob.ID, copied.ID); len(events) != 1 || events[0].Source != SourceImport {
+		t.Errorf("История импортированной задачи: %+v", events)
+	}
+
+	// Повторное слияние не дублирует проекты
+	again, _ := dst.ImportForUser(bob.ID, parse(), ImportMerge, false)
+	if again.ProjectsCreated != 0 || again.ProjectsMerged != 2 || again.TaskIDs[report.ID] == copied.ID {
+		t.Errorf("Повторное слияние: %+v", again)
+	}
+
+	// Замена не трогает задачи участника общего проекта: они переходят в его Входящие
+	carol := User{ID: 8, Username: "carol"}
+	dst.ShareProjectForUser(bob.ID, copied.ProjectID, carol, RoleEditor)
+	carolTask, _ := dst.CreateTaskForUser(carol.ID, "Задача Кэрол", nil, UpdateTaskRequest{ProjectID: &copied.ProjectID})
+	replaced, err := dst.ImportForUser(bob.ID, parse(), ImportReplace, false)
+	if err != nil || replaced.TasksTrashed != 4 || replaced.TasksMoved != 1 || replaced.ProjectsDeleted != 1 || replaced.ProjectsCreated != 1 {
+		t.Fatalf("Замена: %+v, %v", replaced, err)
+	}
+	tasks, _ := dst.GetAllTasksForUser(bob.ID)
+	trash, _ := dst.GetTrashForUser(bob.ID)
+	if len(tasks) != 2 || len(trash) != 4 {
+		t.Errorf("После замены: задач %d, в корзине %d", len(tasks), len(trash))
+	}
+	carolInbox, _ := dst.inbox(carol.ID)
+	if task, err := dst.GetTaskForUser(carol.ID, carolTask.ID); err != nil || task.ProjectID != carolInbox.ID {
+		t.Errorf("Задача участника после замены: %+v, %v", task, err)
+	}
+
+	// Ошибка в документе - ничего не импортируется
+	broken := parse()
+	broken.Tasks[1].ProjectID = 99
+	if _, err := dst.ImportForUser(bob.ID, broken, ImportMerge, false); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Ссылка на неизвестный проект: ожидался ErrInvalid, получено %v", err)
+	}
+	broken = parse()
+	broken.Version = ExportVersion + 1
+	if _, err := dst.ImportForUser(bob.ID, broken, ImportMerge, false); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Другая версия: ожидался ErrInvalid, получено %v", err)
+	}
+	if after, _ := dst.GetAllTasksForUser(bob.ID); len(after) != 2 {
+		t.Errorf("Ошибочный импорт изменил задачи: %d", len(after))
+	}
+}
@@ -0,0 +1,272 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"todo-app/internal/logger"
+)
+
+// ProjectInviteTTL - сколько действует код приглашения в проект
+const ProjectInviteTTL = 7 * 24 * time.Hour
+
+// Member - участник общего проекта. Владелец в project_members не хранится,
+// но GetMembersForUser возвращает его первым с ролью RoleOwner.
+type Member struct {
+	ProjectID int       `json:"project_id"`
+	UserID    int       `json:"user_id"`
+	Username  string    `json:"username,omitempty"`
+	Role      Role      `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ProjectInvite - одноразовый код приглашения в проект с заданной ролью.
+// Сам код виден только при создании, хранится его хеш.
+type ProjectInvite struct {
+	Code      string    `json:"code,omitempty"`
+	CodeHash  string    `json:"-"`
+	ProjectID int       `json:"project_id"`
+	Role      Role      `json:"role"`
+	CreatedBy int       `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// GetMembersForUser возвращает владельца и участников проекта.
+// Список виден всем участникам.
+func (tm *TaskManager) GetMembersForUser(userID, projectID int) ([]Member, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	project, err := tm.authorizeProject(userID, projectID, ActionView)
+	if err != nil {
+		return nil, err
+	}
+	return tm.projectMembers(project)
+}
+
+// projectMembers возвращает владельца и участников проекта без проверки
+// доступа. Вызывающий должен удерживать tm.mu.
+func (tm *TaskManager) projectMembers(project Project) ([]Member, error) {
+	if tm.storage != nil {
+		return tm.storage.GetProjectMembers(project.ID)
+	}
+	members := []Member{{ProjectID: project.ID, UserID: project.UserID, Role: RoleOwner, CreatedAt: project.CreatedAt}}
+	for _, m := range tm.members[project.ID] {
+		members = append(members, m)
+	}
+	sortMembers(members[1:])
+	return members, nil
+}
+
+// ShareProjectForUser добавляет пользователя member в проект с ролью role
+// или меняет роль, если он уже участник. Доступно только владельцу.
+func (tm *TaskManager) ShareProjectForUser(userID, projectID int, member User, role Role) (*Member, error) {
+	if !role.Valid() {
+		return nil, Invalid("роль участника: editor или viewer, получено %q", role)
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	project, err := tm.sharedProject(userID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if member.ID == project.UserID {
+		return nil, Conflict("%s - владелец проекта %q", member.Username, project.Name)
+	}
+	m := Member{
+		ProjectID: projectID,
+		UserID:    member.ID,
+		Username:  member.Username,
+		Role:      role,
+		CreatedAt: time.Now(),
+	}
+	if err := tm.saveMember(&m); err != nil {
+		return nil, err
+	}
+	logger.Info(context.Background(), "Участник проекта добавлен", "projectID", projectID, "memberID", member.ID, "role", role)
+	return &m, nil
+}
+
+// RemoveMemberForUser исключает участника из проекта. Владелец может
+// исключить любого участника, участник - только выйти сам. Задачи проекта,
+// назначенные участнику, остаются без исполнителя.
+func (tm *TaskManager) RemoveMemberForUser(userID, projectID, memberID int) error {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	project, err := tm.lookupProject(userID, projectID)
+	if err != nil {
+		return err
+	}
+	if memberID == project.UserID {
+		return Invalid("владельца нельзя исключить из проекта")
+	}
+	if memberID != userID && !project.Role.Allows(ActionManage) {
+		return Forbidden("исключать участников может только владелец проекта")
+	}
+
+	if tm.storage != nil {
+		if err := tm.storage.DeleteProjectMember(projectID, memberID); err != nil {
+			return err
+		}
+	} else {
+		if _, exists := tm.members[projectID][memberID]; !exists {
+			return NotFound("пользователь %d не участник проекта", memberID)
+		}
+		delete(tm.members[projectID], memberID)
+		for id, task := range tm.tasks {
+			if task.ProjectID == projectID && task.AssigneeID == memberID {
+				task.AssigneeID = 0
+				task.Version++
+				tm.tasks[id] = task
+				tm.syncTask(id)
+			}
+		}
+		tm.syncProject(projectID)
+	}
+	logger.Info(context.Background(), "Участник исключен из проекта", "projectID", projectID, "memberID", memberID, "by", userID)
+	return nil
+}
+
+// CreateProjectInviteForUser выдает одноразовый код, по которому можно
+// присоединиться к проекту с ролью role. Доступно только владельцу.
+func (tm *TaskManager) CreateProjectInviteForUser(userID, projectID int, role Role) (*ProjectInvite, error) {
+	if !role.Valid() {
+		return nil, Invalid("роль участника: editor или viewer, получено %q", role)
+	}
+	code, err := generateCode()
+	if err != nil {
+		return nil, err
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if _, err := tm.sharedProject(userID, projectID); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	invite := &ProjectInvite{
+		Code:      code,
+		CodeHash:  hashToken(normalizeLinkCode(code)),
+		ProjectID: projectID,
+		Role:      role,
+		CreatedBy: userID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ProjectInviteTTL),
+	}
+	if tm.storage != nil {
+		if err := tm.storage.CreateProjectInvite(invite); err != nil {
+			return nil, err
+		}
+	} else {
+		tm.invites[invite.CodeHash] = *invite
+	}
+	return invite, nil
+}
+
+// JoinProjectForUser присоединяет пользователя к проекту по коду
+// приглашения. Код одноразовый; участник получает роль из приглашения.
+func (tm *TaskManager) JoinProjectForUser(user User, code string) (*Project, error) {
+	codeHash := hashToken(normalizeLinkCode(code))
+	now := time.Now().UTC()
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	var invite ProjectInvite
+	if tm.storage != nil {
+		i, err := tm.storage.ConsumeProjectInvite(codeHash, now)
+		if err != nil {
+			return nil, err
+		}
+		invite = *i
+	} else {
+		i, exists := tm.invites[codeHash]
+		delete(tm.invites, codeHash)
+		if !exists || !i.ExpiresAt.After(now) {
+			return nil, NotFound("код приглашения не найден или истек")
+		}
+		invite = i
+	}
+
+	project, err := tm.lookupProject(user.ID, invite.ProjectID)
+	switch {
+	case err == nil && project.Role == RoleOwner:
+		return nil, Conflict("вы владелец проекта %q", project.Name)
+	case err != nil && !errors.Is(err, ErrForbidden):
+		return nil, err
+	}
+	m := Member{
+		ProjectID: invite.ProjectID,
+		UserID:    user.ID,
+		Username:  user.Username,
+		Role:      invite.Role,
+		CreatedAt: time.Now(),
+	}
+	if err := tm.saveMember(&m); err != nil {
+		return nil, err
+	}
+	logger.Info(context.Background(), "Пользователь присоединился к проекту по приглашению",
+		"projectID", invite.ProjectID, "userID", user.ID, "role", invite.Role)
+
+	project, err = tm.lookupProject(user.ID, invite.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+// sharedProject проверяет, что пользователь - владелец проекта и проект
+// можно сделать общим. Вызывающий должен удерживать tm.mu.
+func (tm *TaskManager) sharedProject(userID, projectID int) (Project, error) {
+	project, err := tm.authorizeProject(userID, projectID, ActionManage)
+	if err != nil {
+		return Project{}, err
+	}
+	if project.Inbox {
+		return Project{}, Invalid("Входящие нельзя сделать общими")
+	}
+	return project, nil
+}
+
+// saveMember добавляет участника или меняет его роль; дата добавления
+// остается прежней. Вызывающий должен удерживать tm.mu.
+func (tm *TaskManager) saveMember(m *Member) error {
+	if tm.storage != nil {
+		return tm.storage.SaveProjectMember(m)
+	}
+	if tm.members[m.ProjectID] == nil {
+		tm.members[m.ProjectID] = make(map[int]Member)
+	}
+	if old, exists := tm.members[m.ProjectID][m.UserID]; exists {
+		m.CreatedAt = old.CreatedAt
+	}
+	tm.members[m.ProjectID][m.UserID] = *m
+	tm.syncProject(m.ProjectID)
+	return nil
+}
+
+// memberRole - роль пользователя в проекте в памяти; "" - доступа нет.
+// Вызывающий должен удерживать tm.mu.
+func (tm *TaskManager) memberRole(userID int, project Project) Role {
+	if project.UserID == userID {
+		return RoleOwner
+	}
+	return tm.members[project.ID][userID].Role
+}
+
+// sortMembers упорядочивает участников по дате добавления
+func sortMembers(members []Member) {
+	sort.Slice(members, func(i, j int) bool {
+		if !members[i].CreatedAt.Equal(members[j].CreatedAt) {
+			return members[i].CreatedAt.Before(members[j].CreatedAt)
+		}
+		return members[i].UserID < members[j].UserID
+	})
+}
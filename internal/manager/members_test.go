@@ -0,0 +1,143 @@
+package manager
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSharedProjects(t *testing.T) {
+	tm := NewTaskManager()
+	stm := NewSubTaskManager()
+	tm.SetSubTaskManager(stm)
+	alice := User{ID: 1, Username: "alice"}
+	bob := User{ID: 2, Username: "bob"}
+
+	family, _ := tm.CreateProjectForUser(alice.ID, CreateProjectRequest{Name: "Семья"})
+	personal, _ := tm.AddTaskForUser(alice.ID, "Личное", nil)
+	shopping, _ := tm.CreateTaskForUser(alice.ID, "Купить продукты", nil, UpdateTaskRequest{ProjectID: &family.ID})
+	if _, err := tm.GetTaskForUser(bob.ID, shopping.ID); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("До приглашения: ожидался ErrForbidden, получено %v", err)
+	}
+
+	inbox, _ := tm.InboxForUser(alice.ID)
+	for _, tc := range []struct {
+		name      string
+		userID    int
+		projectID int
+		member    User
+		role      Role
+		want      error
+	}{
+		{"роль владельца", alice.ID, family.ID, bob, RoleOwner, ErrInvalid},
+		{"Входящие", alice.ID, inbox.ID, bob, RoleViewer, ErrInvalid},
+		{"сам владелец", alice.ID, family.ID, alice, RoleViewer, ErrConflict},
+		{"не владелец", bob.ID, family.ID, bob, RoleEditor, ErrForbidden},
+	} {
+		if _, err := tm.ShareProjectForUser(tc.userID, tc.projectID, tc.member, tc.role); !errors.Is(err, tc.want) {
+			t.Errorf("%s: ожидалось %v, получено %v", tc.name, tc.want, err)
+		}
+	}
+
+	if _, err := tm.ShareProjectForUser(alice.ID, family.ID, bob, RoleViewer); err != nil {
+		t.Fatalf("Ошибка приглашения: %v", err)
+	}
+	tasks, _ := tm.GetAllTasksForUser(bob.ID)
+	if len(tasks) != 1 || tasks[0].ID != shopping.ID {
+		t.Errorf("Участнику видны только задачи общего проекта, получено %v", taskIDs(tasks))
+	}
+	if _, err := tm.GetTaskForUser(bob.ID, personal); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Задача из Входящих владельца: ожидался ErrForbidden, получено %v", err)
+	}
+	if project, _ := tm.GetProjectForUser(bob.ID, family.ID); project == nil || project.Role != RoleViewer {
+		t.Errorf("Ожидалась роль viewer: %+v", project)
+	}
+	if projects, _ := tm.GetProjectsForUser(alice.ID); projects[len(projects)-1].Members != 1 {
+		t.Errorf("У проекта должен быть 1 участник: %+v", projects)
+	}
+
+	// Читатель ничего не меняет
+	description := "Купить хлеб"
+	if _, err := tm.UpdateTaskForUser(bob.ID, shopping.ID, UpdateTaskRequest{Description: &description}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Изменение читателем: ожидался ErrForbidden, получено %v", err)
+	}
+	if _, _, err := tm.ToggleCompleteForUser(bob.ID, shopping.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Выполнение читателем: ожидался ErrForbidden, получено %v", err)
+	}
+	if _, err := tm.CreateTaskForUser(bob.ID, "Новая", nil, UpdateTaskRequest{ProjectID: &family.ID}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Создание читателем: ожидался ErrForbidden, получено %v", err)
+	}
+	if _, err := stm.AddSubTask(bob.ID, shopping.ID, "Молоко"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Подзадача от читателя: ожидался ErrForbidden, получено %v", err)
+	}
+	subtaskID, _ := stm.AddSubTask(alice.ID, shopping.ID, "Молоко")
+	if subtasks, err := stm.GetSubTasks(bob.ID, shopping.ID); err != nil || len(subtasks) != 1 {
+		t.Errorf("Читатель видит подзадачи: %v, %v", subtasks, err)
+	}
+	if err := stm.ToggleSubTask(bob.ID, subtaskID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Подзадача читателем: ожидался ErrForbidden, получено %v", err)
+	}
+
+	// Код приглашения меняет роль на editor и срабатывает один раз
+	if _, err := tm.CreateProjectInviteForUser(bob.ID, family.ID, RoleEditor); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Приглашение от участника: ожидался ErrForbidden, получено %v", err)
+	}
+	invite, err := tm.CreateProjectInviteForUser(alice.ID, family.ID, RoleEditor)
+	if err != nil {
+		t.Fatalf("Ошибка создания приглашения: %v", err)
+	}
+	if _, err := tm.JoinProjectForUser(alice, invite.Code); !errors.Is(err, ErrConflict) {
+		t.Errorf("Владелец по своему коду: ожидался ErrConflict, получено %v", err)
+	}
+	invite, _ = tm.CreateProjectInviteForUser(alice.ID, family.ID, RoleEditor)
+	project, err := tm.JoinProjectForUser(bob, strings.ToLower(invite.Code))
+	if err != nil || project.Role != RoleEditor {
+		t.Fatalf("Присоединение по коду: %+v, %v", project, err)
+	}
+	if _, err := tm.JoinProjectForUser(bob, invite.Code); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Повторный код: ожидался ErrNotFound, получено %v", err)
+	}
+
+	// Редактор меняет задачи, но не проект; автор задачи не меняется
+	updated, err := tm.UpdateTaskForUser(bob.ID, shopping.ID, UpdateTaskRequest{Description: &description})
+	if err != nil || updated.Description != description || updated.UserID != alice.ID {
+		t.Errorf("Изменение редактором: %+v, %v", updated, err)
+	}
+	if err := stm.ToggleSubTask(bob.ID, subtaskID); err != nil {
+		t.Errorf("Подзадача редактором: %v", err)
+	}
+	created, err := tm.CreateTaskForUser(bob.ID, "Вынести мусор", nil, UpdateTaskRequest{ProjectID: &family.ID})
+	if err != nil {
+		t.Fatalf("Создание редактором: %v", err)
+	}
+	if tasks, _ := tm.FilterTasksAdvancedForUser(alice.ID, FilterOptions{ProjectID: &family.ID}); len(tasks) != 2 {
+		t.Errorf("Владелец видит задачи редактора, получено %v", taskIDs(tasks))
+	}
+	name := "Дом"
+	if _, err := tm.UpdateProjectForUser(bob.ID, family.ID, UpdateProjectRequest{Name: &name}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Переименование редактором: ожидался ErrForbidden, получено %v", err)
+	}
+	if _, err := tm.DeleteProjectForUser(bob.ID, family.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Удаление редактором: ожидался ErrForbidden, получено %v", err)
+	}
+	if _, err := tm.MoveTasksForUser(bob.ID, []int{created.ID, personal}, family.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Перенос чужой задачи: ожидался ErrForbidden, получено %v", err)
+	}
+
+	members, err := tm.GetMembersForUser(bob.ID, family.ID)
+	if err != nil || len(members) != 2 || members[0].Role != RoleOwner || members[1].UserID != bob.ID {
+		t.Fatalf("Участники: %+v, %v", members, err)
+	}
+	if err := tm.RemoveMemberForUser(bob.ID, family.ID, alice.ID); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Исключение владельца: ожидался ErrInvalid, получено %v", err)
+	}
+	if err := tm.RemoveMemberForUser(bob.ID, family.ID, bob.ID); err != nil {
+		t.Fatalf("Выход из проекта: %v", err)
+	}
+	if tasks, _ := tm.GetAllTasksForUser(bob.ID); len(tasks) != 0 {
+		t.Errorf("После выхода задачи проекта не видны, получено %v", taskIDs(tasks))
+	}
+	if err := tm.RemoveMemberForUser(alice.ID, family.ID, bob.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Повторное исключение: ожидался ErrNotFound, получено %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+package manager
+
+import "errors"
+
+// Role - роль пользователя в проекте. Владелец проекта (Project.UserID)
+// управляет им и его участниками, редактор меняет задачи, читатель их
+// только видит. Доступ к задаче определяется ролью в ее проекте.
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleEditor Role = "editor"
+	RoleViewer Role = "viewer"
+)
+
+// Valid сообщает, можно ли выдать роль участнику: владелец у проекта один
+func (r Role) Valid() bool {
+	return r == RoleEditor || r == RoleViewer
+}
+
+// Action - действие, право на которое проверяет слой доступа
+type Action int
+
+const (
+	ActionView   Action = iota // видеть проект, его задачи и подзадачи
+	ActionEdit                 // создавать, менять и удалять задачи и подзадачи
+	ActionManage               // менять сам проект и состав участников
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionView:
+		return "просматривать проект"
+	case ActionEdit:
+		return "изменять задачи"
+	case ActionManage:
+		return "управлять проектом"
+	}
+	return "выполнить действие"
+}
+
+// Allows сообщает, разрешено ли роли действие. Пустая роль - нет доступа.
+func (r Role) Allows(action Action) bool {
+	switch action {
+	case ActionView:
+		return r == RoleOwner || r == RoleEditor || r == RoleViewer
+	case ActionEdit:
+		return r == RoleOwner || r == RoleEditor
+	case ActionManage:
+		return r == RoleOwner
+	}
+	return false
+}
+
+// AuthorizeTask проверяет, что пользователь может выполнить action с задачей,
+// и возвращает ее. Через эту проверку проходят все изменения задач и подзадач.
+func (tm *TaskManager) AuthorizeTask(userID, id int, action Action) (*Task, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	task, err := tm.authorizeTask(userID, id, action)
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// authorizeTask - см. AuthorizeTask. Хранилище дальше вызывается с
+// task.UserID: задача остается в пространстве тегов своего автора.
+// Вызывающий должен удерживать tm.mu.
+func (tm *TaskManager) authorizeTask(userID, id int, action Action) (Task, error) {
+	task, err := tm.findTask(id)
+	if err != nil {
+		return Task{}, err
+	}
+	if err := tm.checkTaskAccess(userID, task, action); err != nil {
+		return Task{}, err
+	}
+	return task, nil
+}
+
+// checkTaskAccess проверяет, что роль пользователя в проекте найденной
+// задачи позволяет action. Вызывающий должен удерживать tm.mu.
+func (tm *TaskManager) checkTaskAccess(userID int, task Task, action Action) error {
+	role, err := tm.taskRole(userID, task)
+	if err != nil {
+		return err
+	}
+	if role == "" {
+		return Forbidden("задача с ID %d принадлежит другому пользователю", task.ID)
+	}
+	if !role.Allows(action) {
+		return Forbidden("роль %s не позволяет %s (задача #%d)", role, action, task.ID)
+	}
+	return nil
+}
+
+// authorizeProject возвращает проект, если роль пользователя в нем
+// позволяет action. Вызывающий должен удерживать tm.mu.
+func (tm *TaskManager) authorizeProject(userID, id int, action Action) (Project, error) {
+	project, err := tm.lookupProject(userID, id)
+	if err != nil {
+		return Project{}, err
+	}
+	if !project.Role.Allows(action) {
+		return Project{}, Forbidden("роль %s в проекте %q не позволяет %s", project.Role, project.Name, action)
+	}
+	return project, nil
+}
+
+// findTask ищет задачу по ID без проверки доступа.
+// Вызывающий должен удерживать tm.mu.
+func (tm *TaskManager) findTask(id int) (Task, error) {
+	if tm.storage != nil {
+		task, err := tm.storage.GetTaskByID(id)
+		if err != nil {
+			return Task{}, err
+		}
+		return *task, nil
+	}
+	task, exists := tm.tasks[id]
+	if !exists {
+		return Task{}, NotFound("задача с ID %d не найдена", id)
+	}
+	return task, nil
+}
+
+// taskRole возвращает роль пользователя в проекте задачи; "" - доступа нет.
+// Задача без проекта (из старых версий) доступна только автору.
+// Вызывающий должен удерживать tm.mu.
+func (tm *TaskManager) taskRole(userID int, task Task) (Role, error) {
+	if task.ProjectID != 0 {
+		project, err := tm.lookupProject(userID, task.ProjectID)
+		switch {
+		case err == nil:
+			return project.Role, nil
+		case errors.Is(err, ErrForbidden):
+			return "", nil
+		case !errors.Is(err, ErrNotFound):
+			return "", err
+		}
+	}
+	if task.UserID == userID {
+		return RoleOwner, nil
+	}
+	return "", nil
+}
+
+// visibleTasks возвращает проверку "пользователь видит задачу" для
+// in-memory режима: задачи проектов, где у него есть роль, и собственные
+// задачи без проекта. Вызывающий должен удерживать tm.mu.
+func (tm *TaskManager) visibleTasks(userID int) func(Task) bool {
+	visible := make(map[int]bool)
+	for id, project := range tm.projects {
+		if tm.memberRole(userID, project) != "" {
+			visible[id] = true
+		}
+	}
+	return func(task Task) bool {
+		if _, exists := tm.projects[task.ProjectID]; !exists {
+			return task.UserID == userID
+		}
+		return visible[task.ProjectID]
+	}
+}
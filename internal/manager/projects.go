@@ -0,0 +1,536 @@
+package manager
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"todo-app/internal/logger"
+)
+
+// InboxName - название проекта, в который попадают задачи без проекта
+const InboxName = "Входящие"
+
+// DefaultProjectColor - цвет проекта, если он не выбран
+const DefaultProjectColor = "#9e9e9e"
+
+// Project - проект (список), в котором лежат задачи. У каждого пользователя
+// есть Входящие: они создаются при первом обращении, их нельзя
+// переименовать, архивировать, удалить или сделать общими. Остальными
+// проектами владелец может поделиться, см. members.go.
+type Project struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	Archived  bool      `json:"archived"`
+	Position  int       `json:"position"` // порядок в списке проектов, по возрастанию
+	Inbox     bool      `json:"inbox"`
+	OpenTasks int       `json:"open_tasks"` // число невыполненных задач
+	Members   int       `json:"members"`    // число участников, кроме владельца
+	Role      Role      `json:"role"`       // роль пользователя, запросившего проект
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type CreateProjectRequest struct {
+	Name  string `json:"name"`
+	Color string `json:"color,omitempty"` // #rrggbb, по умолчанию DefaultProjectColor
+}
+
+type UpdateProjectRequest struct {
+	Name     *string `json:"name,omitempty"`
+	Color    *string `json:"color,omitempty"`
+	Archived *bool   `json:"archived,omitempty"`
+	Position *int    `json:"position,omitempty"`
+}
+
+var projectColor = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+
+// projectNameKey - ключ сравнения названий проектов, как name_key в projects
+func projectNameKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func prepareProjectName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", Invalid("название проекта обязательно")
+	}
+	if len([]rune(name)) > 100 {
+		return "", Invalid("название проекта не может превышать 100 символов")
+	}
+	return name, nil
+}
+
+func prepareProjectColor(color string) (string, error) {
+	color = strings.ToLower(strings.TrimSpace(color))
+	if color == "" {
+		return DefaultProjectColor, nil
+	}
+	if !projectColor.MatchString(color) {
+		return "", Invalid("цвет проекта: ожидается #rrggbb, получено %q", color)
+	}
+	return color, nil
+}
+
+// sortProjects упорядочивает проекты: Входящие, свои по Position и названию,
+// затем общие проекты других пользователей
+func sortProjects(projects []Project) {
+	sort.Slice(projects, func(i, j int) bool {
+		a, b := projects[i], projects[j]
+		if (a.Role == RoleOwner) != (b.Role == RoleOwner) {
+			return a.Role == RoleOwner
+		}
+		if a.Inbox != b.Inbox {
+			return a.Inbox
+		}
+		if a.Position != b.Position {
+			return a.Position < b.Position
+		}
+		if projectNameKey(a.Name) != projectNameKey(b.Name) {
+			return projectNameKey(a.Name) < projectNameKey(b.Name)
+		}
+		return a.ID < b.ID
+	})
+}
+
+// InboxForUser возвращает Входящие пользователя, создавая их при необходимости
+func (tm *TaskManager) InboxForUser(userID int) (*Project, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	inbox, err := tm.inbox(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &inbox, nil
+}
+
+// GetProjectsForUser возвращает проекты пользователя и общие проекты, где
+// он участник, включая архивные, с числом невыполненных задач
+func (tm *TaskManager) GetProjectsForUser(userID int) ([]Project, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if _, err := tm.inbox(userID); err != nil {
+		return nil, err
+	}
+	return tm.userProjects(userID)
+}
+
+// GetProjectForUser возвращает проект по ID, если у пользователя есть в нем роль
+func (tm *TaskManager) GetProjectForUser(userID, id int) (*Project, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	project, err := tm.lookupProject(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+// CreateProjectForUser создает проект в конце списка; название должно быть
+// свободно без учета регистра
+func (tm *TaskManager) CreateProjectForUser(userID int, req CreateProjectRequest) (*Project, error) {
+	name, err := prepareProjectName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	color, err := prepareProjectColor(req.Color)
+	if err != nil {
+		return nil, err
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	// Входящие создаются первыми, чтобы их название нельзя было занять
+	if _, err := tm.inbox(userID); err != nil {
+		return nil, err
+	}
+	projects, err := tm.userProjects(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkProjectNameFree(projects, 0, name); err != nil {
+		return nil, err
+	}
+	position := 0
+	for _, p := range projects {
+		if p.Position >= position {
+			position = p.Position + 1
+		}
+	}
+
+	now := time.Now()
+	project := Project{
+		UserID:    userID,
+		Name:      name,
+		Color:     color,
+		Position:  position,
+		Role:      RoleOwner,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := tm.saveNewProject(&project); err != nil {
+		return nil, err
+	}
+	logger.Info(context.Background(), "Проект создан", "userID", userID, "projectID", project.ID, "name", name)
+	return &project, nil
+}
+
+// UpdateProjectForUser меняет название, цвет, архивность и место проекта в списке
+func (tm *TaskManager) UpdateProjectForUser(userID, id int, req UpdateProjectRequest) (*Project, error) {
+	if req.Name != nil {
+		name, err := prepareProjectName(*req.Name)
+		if err != nil {
+			return nil, err
+		}
+		req.Name = &name
+	}
+	if req.Color != nil {
+		color, err := prepareProjectColor(*req.Color)
+		if err != nil {
+			return nil, err
+		}
+		req.Color = &color
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	project, err := tm.authorizeProject(userID, id, ActionManage)
+	if err != nil {
+		return nil, err
+	}
+	if project.Inbox && ((req.Name != nil && *req.Name != project.Name) || (req.Archived != nil && *req.Archived)) {
+		return nil, Invalid("Входящие нельзя переименовать или отправить в архив")
+	}
+	if req.Name != nil {
+		projects, err := tm.userProjects(userID)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkProjectNameFree(projects, id, *req.Name); err != nil {
+			return nil, err
+		}
+		project.Name = *req.Name
+	}
+	if req.Color != nil {
+		project.Color = *req.Color
+	}
+	if req.Archived != nil {
+		project.Archived = *req.Archived
+	}
+	if req.Position != nil {
+		project.Position = *req.Position
+	}
+	project.UpdatedAt = time.Now()
+
+	if tm.storage != nil {
+		if err := tm.storage.UpdateProject(&project); err != nil {
+			return nil, err
+		}
+	} else {
+		tm.projects[id] = project
+	}
+	return &project, nil
+}
+
+// ArchiveProjectsForUser отправляет проекты в архив (или возвращает из него)
+// разом: либо меняются все, либо ни один. Возвращает число измененных проектов.
+func (tm *TaskManager) ArchiveProjectsForUser(userID int, ids []int, archived bool) (int, error) {
+	if len(ids) == 0 {
+		return 0, Invalid("не выбрано ни одного проекта")
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	for _, id := range ids {
+		project, err := tm.authorizeProject(userID, id, ActionManage)
+		if err != nil {
+			return 0, err
+		}
+		if project.Inbox && archived {
+			return 0, Invalid("Входящие нельзя отправить в архив")
+		}
+	}
+
+	changed := 0
+	if tm.storage != nil {
+		n, err := tm.storage.ArchiveProjects(userID, ids, archived)
+		if err != nil {
+			return 0, err
+		}
+		changed = n
+	} else {
+		for _, id := range ids {
+			project := tm.projects[id]
+			if project.Archived != archived {
+				project.Archived = archived
+				project.UpdatedAt = time.Now()
+				tm.projects[id] = project
+				changed++
+			}
+		}
+	}
+	logger.Info(context.Background(), "Архив проектов изменен", "userID", userID, "archived", archived, "changed", changed)
+	return changed, nil
+}
+
+// DeleteProjectForUser удаляет проект вместе с участниками и приглашениями;
+// все его задачи, в том числе созданные участниками, переходят во Входящие
+// владельца. Возвращает число перенесенных задач.
+func (tm *TaskManager) DeleteProjectForUser(userID, id int) (int, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	project, err := tm.authorizeProject(userID, id, ActionManage)
+	if err != nil {
+		return 0, err
+	}
+	if project.Inbox {
+		return 0, Invalid("Входящие нельзя удалить")
+	}
+	inbox, err := tm.inbox(userID)
+	if err != nil {
+		return 0, err
+	}
+	// Задачи участников уходят в их собственные Входящие
+	members, err := tm.projectMembers(project)
+	if err != nil {
+		return 0, err
+	}
+	inboxes := map[int]int{userID: inbox.ID}
+	for _, m := range members {
+		memberInbox, err := tm.inbox(m.UserID)
+		if err != nil {
+			return 0, err
+		}
+		inboxes[m.UserID] = memberInbox.ID
+	}
+
+	moved := 0
+	if tm.storage != nil {
+		moved, err = tm.storage.DeleteProject(userID, id, inbox.ID)
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		rehome := func(task Task) Task {
+			task.ProjectID = inbox.ID
+			if authorInbox, ok := inboxes[task.UserID]; ok {
+				task.ProjectID = authorInbox
+			}
+			if task.AssigneeID != task.UserID {
+				task.AssigneeID = 0
+			}
+			task.UpdatedAt = time.Now()
+			task.Version++
+			return task
+		}
+		for taskID, task := range tm.tasks {
+			if task.ProjectID == id {
+				tm.tasks[taskID] = rehome(task)
+				tm.syncTask(taskID)
+				moved++
+			}
+		}
+		// Задачи из корзины восстановятся во Входящие авторов
+		for taskID, task := range tm.trash {
+			if task.ProjectID == id {
+				tm.trash[taskID] = rehome(task)
+				tm.syncTask(taskID)
+			}
+		}
+		delete(tm.projects, id)
+		delete(tm.members, id)
+		for hash, invite := range tm.invites {
+			if invite.ProjectID == id {
+				delete(tm.invites, hash)
+			}
+		}
+	}
+	logger.Info(context.Background(), "Проект удален", "userID", userID, "projectID", id, "movedTasks", moved)
+	return moved, nil
+}
+
+// MoveTasksForUser переносит задачи в проект. Задачи проверяются до переноса:
+// если хоть одну пользователь не может менять или она не найдена,
+// не переносится ни одна. Возвращает число перенесенных задач.
+func (tm *TaskManager) MoveTasksForUser(userID int, taskIDs []int, projectID int) (int, error) {
+	if len(taskIDs) == 0 {
+		return 0, Invalid("не выбрано ни одной задачи")
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if _, err := tm.targetProject(userID, projectID); err != nil {
+		return 0, err
+	}
+	// Для истории запоминаем задачи до переноса и то, какими они станут
+	var before, after []Task
+	for _, id := range taskIDs {
+		task, err := tm.authorizeTask(userID, id, ActionEdit)
+		if err != nil {
+			return 0, err
+		}
+		if task.ProjectID != projectID {
+			next := task
+			next.AssigneeID = tm.keepAssignee(task, projectID)
+			next.ProjectID = projectID
+			before, after = append(before, task), append(after, next)
+		}
+	}
+
+	moved := 0
+	if tm.storage != nil {
+		n, err := tm.storage.MoveTasks(taskIDs, projectID)
+		if err != nil {
+			return 0, err
+		}
+		moved = n
+	} else {
+		for _, id := range taskIDs {
+			task := tm.tasks[id]
+			if task.ProjectID != projectID {
+				task.AssigneeID = tm.keepAssignee(task, projectID)
+				task.ProjectID = projectID
+				task.UpdatedAt = time.Now()
+				task.Version++
+				tm.tasks[id] = task
+				tm.syncTask(id)
+				moved++
+			}
+		}
+	}
+	for i := range after {
+		tm.recordEvent(tm.newEvent(userID, after[i].ID, EventUpdated, taskChanges(before[i], after[i])))
+	}
+	logger.Info(context.Background(), "Задачи перенесены в проект", "userID", userID, "projectID", projectID, "moved", moved)
+	return moved, nil
+}
+
+// inbox возвращает Входящие пользователя, создавая их при необходимости.
+// Вызывающий должен удерживать tm.mu.
+func (tm *TaskManager) inbox(userID int) (Project, error) {
+	if tm.storage != nil {
+		project, err := tm.storage.GetInbox(userID)
+		if err != nil {
+			return Project{}, err
+		}
+		return *project, nil
+	}
+	for _, project := range tm.projects {
+		if project.UserID == userID && project.Inbox {
+			return project, nil
+		}
+	}
+	now := time.Now()
+	project := Project{
+		UserID:    userID,
+		Name:      InboxName,
+		Color:     DefaultProjectColor,
+		Inbox:     true,
+		Role:      RoleOwner,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := tm.saveNewProject(&project); err != nil {
+		return Project{}, err
+	}
+	return project, nil
+}
+
+// saveNewProject сохраняет новый проект и заполняет его ID.
+// Вызывающий должен удерживать tm.mu.
+func (tm *TaskManager) saveNewProject(project *Project) error {
+	if tm.storage != nil {
+		id, err := tm.storage.CreateProject(project)
+		if err != nil {
+			return err
+		}
+		project.ID = id
+		return nil
+	}
+	project.ID = tm.nextProjectID
+	tm.nextProjectID++
+	tm.projects[project.ID] = *project
+	return nil
+}
+
+// lookupProject ищет проект и заполняет Role - роль пользователя в нем.
+// Если роли нет, возвращает ErrForbidden. Вызывающий должен удерживать tm.mu.
+func (tm *TaskManager) lookupProject(userID, id int) (Project, error) {
+	if tm.storage != nil {
+		project, err := tm.storage.GetProject(userID, id)
+		if err != nil {
+			return Project{}, err
+		}
+		return *project, nil
+	}
+	project, ok := tm.projects[id]
+	if !ok {
+		return Project{}, NotFound("проект с ID %d не найден", id)
+	}
+	project.Role = tm.memberRole(userID, project)
+	if project.Role == "" {
+		return Project{}, Forbidden("проект с ID %d принадлежит другому пользователю", id)
+	}
+	project.Members = len(tm.members[id])
+	project.OpenTasks = 0
+	for _, task := range tm.tasks {
+		if task.ProjectID == id && !task.Completed {
+			project.OpenTasks++
+		}
+	}
+	return project, nil
+}
+
+// targetProject проверяет, что в проект можно добавлять задачи: роль
+// пользователя позволяет их менять и проект не в архиве.
+// Вызывающий должен удерживать tm.mu.
+func (tm *TaskManager) targetProject(userID, id int) (Project, error) {
+	project, err := tm.authorizeProject(userID, id, ActionEdit)
+	if err != nil {
+		return Project{}, err
+	}
+	if project.Archived {
+		return Project{}, Conflict("проект %q в архиве, сначала верните его из архива", project.Name)
+	}
+	return project, nil
+}
+
+// userProjects - проекты пользователя и общие проекты, где он участник,
+// по порядку. Вызывающий должен удерживать tm.mu.
+func (tm *TaskManager) userProjects(userID int) ([]Project, error) {
+	if tm.storage != nil {
+		return tm.storage.GetProjects(userID)
+	}
+	projects := []Project{}
+	for _, project := range tm.projects {
+		if tm.memberRole(userID, project) != "" {
+			project, _ = tm.lookupProject(userID, project.ID)
+			projects = append(projects, project)
+		}
+	}
+	sortProjects(projects)
+	return projects, nil
+}
+
+// checkProjectNameFree возвращает ErrConflict, если название занято
+// другим собственным проектом из projects: у общих проектов свои владельцы
+func checkProjectNameFree(projects []Project, id int, name string) error {
+	for _, project := range projects {
+		if project.Role == RoleOwner && project.ID != id && projectNameKey(project.Name) == projectNameKey(name) {
+			return Conflict("проект %q уже существует", project.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package manager
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestProjects(t *testing.T) {
+	tm := NewTaskManager()
+
+	id, _ := tm.AddTaskForUser(1, "Молоко", nil)
+	task, _ := tm.GetTaskForUser(1, id)
+	inbox, err := tm.InboxForUser(1)
+	if err != nil {
+		t.Fatalf("Ошибка получения Входящих: %v", err)
+	}
+	if !inbox.Inbox || inbox.Name != InboxName || task.ProjectID != inbox.ID {
+		t.Fatalf("Новая задача должна попасть во Входящие: задача %+v, Входящие %+v", task, inbox)
+	}
+
+	work, err := tm.CreateProjectForUser(1, CreateProjectRequest{Name: "  Работа ", Color: "#FF5722"})
+	if err != nil {
+		t.Fatalf("Ошибка создания проекта: %v", err)
+	}
+	if work.Name != "Работа" || work.Color != "#ff5722" {
+		t.Errorf("Проект не нормализован: %+v", work)
+	}
+	home, _ := tm.CreateProjectForUser(1, CreateProjectRequest{Name: "Дом"})
+	if home.Color != DefaultProjectColor || home.Position <= work.Position {
+		t.Errorf("Новый проект - в конце списка с цветом по умолчанию: %+v", home)
+	}
+
+	for _, req := range []CreateProjectRequest{
+		{Name: "РАБОТА"},
+		{Name: "входящие"},
+	} {
+		if _, err := tm.CreateProjectForUser(1, req); !errors.Is(err, ErrConflict) {
+			t.Errorf("%q: ожидался ErrConflict, получено %v", req.Name, err)
+		}
+	}
+	for _, req := range []CreateProjectRequest{
+		{Name: " "},
+		{Name: "Цвет", Color: "red"},
+	} {
+		if _, err := tm.CreateProjectForUser(1, req); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%+v: ожидался ErrInvalid, получено %v", req, err)
+		}
+	}
+	if _, err := tm.CreateProjectForUser(2, CreateProjectRequest{Name: "Работа"}); err != nil {
+		t.Errorf("У другого пользователя название свободно: %v", err)
+	}
+	if _, err := tm.GetProjectForUser(2, work.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Чужой проект: ожидался ErrForbidden, получено %v", err)
+	}
+
+	created, err := tm.CreateTaskForUser(1, "Отчет", nil, UpdateTaskRequest{ProjectID: &work.ID})
+	if err != nil || created.ProjectID != work.ID {
+		t.Fatalf("Создание задачи в проекте: %+v, %v", created, err)
+	}
+	foreign, _ := tm.CreateProjectForUser(2, CreateProjectRequest{Name: "Чужой"})
+	if _, err := tm.CreateTaskForUser(1, "Чужая", nil, UpdateTaskRequest{ProjectID: &foreign.ID}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Задача в чужом проекте: ожидался ErrForbidden, получено %v", err)
+	}
+	if tasks, _ := tm.GetAllTasksForUser(1); len(tasks) != 2 {
+		t.Errorf("Задача с чужим проектом не должна создаваться, задач: %d", len(tasks))
+	}
+
+	n, err := tm.MoveTasksForUser(1, []int{id, created.ID}, home.ID)
+	if err != nil || n != 2 {
+		t.Fatalf("Перенос задач: %d, %v", n, err)
+	}
+	if _, err := tm.MoveTasksForUser(1, []int{id, 999}, work.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Перенос с несуществующей задачей: ожидался ErrNotFound, получено %v", err)
+	}
+	if task, _ := tm.GetTaskForUser(1, id); task.ProjectID != home.ID {
+		t.Errorf("Неудачный перенос не должен менять задачи: %+v", task)
+	}
+	tasks, _ := tm.FilterTasksAdvancedForUser(1, FilterOptions{ProjectID: &home.ID})
+	if len(tasks) != 2 {
+		t.Errorf("Фильтр по проекту: ожидалось 2 задачи, получено %v", taskIDs(tasks))
+	}
+	if project, _ := tm.GetProjectForUser(1, home.ID); project.OpenTasks != 2 {
+		t.Errorf("Ожидалось 2 открытые задачи в проекте, получено %d", project.OpenTasks)
+	}
+
+	if _, err := tm.ArchiveProjectsForUser(1, []int{work.ID, inbox.ID}, true); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Архивация Входящих: ожидался ErrInvalid, получено %v", err)
+	}
+	if _, err := tm.ArchiveProjectsForUser(1, []int{work.ID, foreign.ID}, true); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Архивация чужого проекта: ожидался ErrForbidden, получено %v", err)
+	}
+	if project, _ := tm.GetProjectForUser(1, work.ID); project.Archived {
+		t.Error("Неудачная архивация не должна менять проекты")
+	}
+	n, err = tm.ArchiveProjectsForUser(1, []int{work.ID, home.ID}, true)
+	if err != nil || n != 2 {
+		t.Fatalf("Архивация: %d, %v", n, err)
+	}
+	if _, err := tm.MoveTasksForUser(1, []int{id}, work.ID); !errors.Is(err, ErrConflict) {
+		t.Errorf("Перенос в архивный проект: ожидался ErrConflict, получено %v", err)
+	}
+	if n, _ := tm.ArchiveProjectsForUser(1, []int{work.ID}, false); n != 1 {
+		t.Errorf("Возврат из архива: изменено %d проектов", n)
+	}
+
+	name, archived := "Переименованные", true
+	if _, err := tm.UpdateProjectForUser(1, inbox.ID, UpdateProjectRequest{Name: &name}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Переименование Входящих: ожидался ErrInvalid, получено %v", err)
+	}
+	if _, err := tm.UpdateProjectForUser(1, inbox.ID, UpdateProjectRequest{Archived: &archived}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Архивация Входящих: ожидался ErrInvalid, получено %v", err)
+	}
+	name = "дом"
+	if _, err := tm.UpdateProjectForUser(1, work.ID, UpdateProjectRequest{Name: &name}); !errors.Is(err, ErrConflict) {
+		t.Errorf("Переименование в занятое название: ожидался ErrConflict, получено %v", err)
+	}
+	position := -1
+	if _, err := tm.UpdateProjectForUser(1, home.ID, UpdateProjectRequest{Position: &position}); err != nil {
+		t.Fatalf("Ошибка смены порядка: %v", err)
+	}
+	projects, _ := tm.GetProjectsForUser(1)
+	if len(projects) != 3 || !projects[0].Inbox || projects[1].ID != home.ID || projects[2].ID != work.ID {
+		t.Errorf("Ожидались Входящие, Дом, Работа, получено %+v", projects)
+	}
+
+	if _, err := tm.DeleteProjectForUser(1, inbox.ID); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Удаление Входящих: ожидался ErrInvalid, получено %v", err)
+	}
+	moved, err := tm.DeleteProjectForUser(1, home.ID)
+	if err != nil || moved != 2 {
+		t.Fatalf("Удаление проекта: %d, %v", moved, err)
+	}
+	if task, _ := tm.GetTaskForUser(1, created.ID); task.ProjectID != inbox.ID {
+		t.Errorf("Задачи удаленного проекта должны перейти во Входящие: %+v", task)
+	}
+	if _, err := tm.GetProjectForUser(1, home.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Удаленный проект: ожидался ErrNotFound, получено %v", err)
+	}
+}
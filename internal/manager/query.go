@@ -0,0 +1,492 @@
+package manager
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Язык запросов к задачам:
+//
+//	priority:high tag:работа -tag:потом due<+7d is:open "отчет"
+//
+// Условия через пробел (или через AND) должны выполняться все, OR связывает
+// альтернативы и слабее AND, NOT или минус перед условием его отрицают,
+// скобки группируют. Слово или "фраза" без поля ищется в описании задачи
+// без учета регистра. Ключевые слова AND, OR, NOT пишутся заглавными.
+//
+//	priority:low|medium|high
+//	tag:имя                      или tag:"два слова"
+//	is:open|done|overdue|recurring
+//	has:due|tags
+//	due:ДАТА  due<ДАТА  due<=ДАТА  due>ДАТА  due>=ДАТА
+//
+// ДАТА - ГГГГ-ММ-ДД, ДД.ММ.ГГГГ, today, tomorrow, yesterday или сдвиг от
+// сегодняшнего дня: +7d, -1d, +2w. Задачи без срока не подходят ни под одно
+// сравнение со сроком. Запрос хранится строкой, поэтому относительные даты
+// вычисляются заново при каждом применении.
+type QueryExpr interface {
+	// Match проверяет задачу; today - день, от которого считаются относительные даты
+	Match(task Task, today time.Time) bool
+	// String возвращает запрос, который разбирается в то же выражение
+	String() string
+}
+
+// QueryAnd выполняется, если выполнены все условия
+type QueryAnd []QueryExpr
+
+// QueryOr выполняется, если выполнено хотя бы одно условие
+type QueryOr []QueryExpr
+
+// QueryNot отрицает условие
+type QueryNot struct {
+	Expr QueryExpr
+}
+
+// QueryText - текст, который должен встречаться в описании задачи
+type QueryText string
+
+// QueryField - условие на поле задачи. Op - ":" (равно), "<", "<=", ">" или ">=";
+// значения, кроме имени тега, приведены к нижнему регистру.
+type QueryField struct {
+	Field string
+	Op    string
+	Value string
+}
+
+func (q QueryAnd) Match(task Task, today time.Time) bool {
+	for _, expr := range q {
+		if !expr.Match(task, today) {
+			return false
+		}
+	}
+	return true
+}
+
+func (q QueryOr) Match(task Task, today time.Time) bool {
+	for _, expr := range q {
+		if expr.Match(task, today) {
+			return true
+		}
+	}
+	return false
+}
+
+func (q QueryNot) Match(task Task, today time.Time) bool {
+	return !q.Expr.Match(task, today)
+}
+
+func (q QueryText) Match(task Task, today time.Time) bool {
+	return strings.Contains(strings.ToLower(task.Description), strings.ToLower(string(q)))
+}
+
+func (q QueryField) Match(task Task, today time.Time) bool {
+	due := ""
+	if !task.DueDate.IsZero() {
+		due = dateOnly(task.DueDate)
+	}
+
+	switch q.Field {
+	case "priority":
+		return task.Priority == Priority(q.Value)
+	case "tag":
+		for _, tag := range task.Tags {
+			if tagKey(tag) == tagKey(q.Value) {
+				return true
+			}
+		}
+		return false
+	case "is":
+		switch q.Value {
+		case "open":
+			return !task.Completed
+		case "done":
+			return task.Completed
+		case "overdue":
+			return !task.Completed && due != "" && due < dateOnly(today)
+		case "recurring":
+			return task.Recurrence != ""
+		}
+	case "has":
+		switch q.Value {
+		case "due":
+			return due != ""
+		case "tags":
+			return len(task.Tags) > 0
+		}
+	case "due":
+		if due == "" {
+			return false
+		}
+		day := q.Day(today)
+		switch q.Op {
+		case "<":
+			return due < day
+		case "<=":
+			return due <= day
+		case ">":
+			return due > day
+		case ">=":
+			return due >= day
+		}
+		return due == day
+	}
+	return false
+}
+
+// Day возвращает дату условия по сроку в виде ГГГГ-ММ-ДД
+func (q QueryField) Day(today time.Time) string {
+	day, _ := queryDay(q.Value, today)
+	return day
+}
+
+func (q QueryAnd) String() string {
+	parts := make([]string, len(q))
+	for i, expr := range q {
+		parts[i] = expr.String()
+		if _, ok := expr.(QueryOr); ok {
+			parts[i] = "(" + parts[i] + ")"
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func (q QueryOr) String() string {
+	parts := make([]string, len(q))
+	for i, expr := range q {
+		parts[i] = expr.String()
+	}
+	return strings.Join(parts, " OR ")
+}
+
+func (q QueryNot) String() string {
+	switch q.Expr.(type) {
+	case QueryAnd, QueryOr:
+		return "-(" + q.Expr.String() + ")"
+	}
+	return "-" + q.Expr.String()
+}
+
+func (q QueryText) String() string {
+	return `"` + string(q) + `"`
+}
+
+func (q QueryField) String() string {
+	value := q.Value
+	if value == "" || strings.ContainsAny(value, " \t\n()") {
+		value = `"` + value + `"`
+	}
+	return q.Field + q.Op + value
+}
+
+// dateOnly - день в формате, в котором хранилище сравнивает сроки
+func dateOnly(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+var relativeDay = regexp.MustCompile(`^([+-]?)(\d{1,4})([dw])$`)
+
+// queryDay переводит значение условия по сроку в дату ГГГГ-ММ-ДД
+func queryDay(value string, today time.Time) (string, bool) {
+	switch value {
+	case "today":
+		return dateOnly(today), true
+	case "tomorrow":
+		return dateOnly(today.AddDate(0, 0, 1)), true
+	case "yesterday":
+		return dateOnly(today.AddDate(0, 0, -1)), true
+	}
+	if m := relativeDay.FindStringSubmatch(value); m != nil {
+		days, _ := strconv.Atoi(m[2])
+		if m[3] == "w" {
+			days *= 7
+		}
+		if m[1] == "-" {
+			days = -days
+		}
+		return dateOnly(today.AddDate(0, 0, days)), true
+	}
+	for _, layout := range []string{"2006-01-02", "02.01.2006"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return dateOnly(t), true
+		}
+	}
+	return "", false
+}
+
+// queryValues - допустимые значения полей is и has
+var queryValues = map[string][]string{
+	"is":  {"open", "done", "overdue", "recurring"},
+	"has": {"due", "tags"},
+}
+
+// newQueryField проверяет условие на поле и приводит его к каноническому виду
+func newQueryField(field, op, value string) (QueryField, error) {
+	field = strings.ToLower(field)
+	if op == "=" {
+		op = ":"
+	}
+	if field != "tag" {
+		value = strings.ToLower(value)
+	}
+	switch field {
+	case "priority":
+		if !Priority(value).Valid() {
+			return QueryField{}, Invalid("priority: ожидается low, medium или high, получено %q", value)
+		}
+	case "tag":
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return QueryField{}, Invalid("tag: не указано имя тега")
+		}
+	case "is", "has":
+		known := false
+		for _, v := range queryValues[field] {
+			known = known || v == value
+		}
+		if !known {
+			return QueryField{}, Invalid("%s: ожидается одно из %s, получено %q",
+				field, strings.Join(queryValues[field], ", "), value)
+		}
+	case "due":
+		if _, ok := queryDay(value, time.Now()); !ok {
+			return QueryField{}, Invalid("due: непонятная дата %q (примеры: 2026-10-20, 20.10.2026, today, +7d, -2w)", value)
+		}
+	default:
+		return QueryField{}, Invalid("неизвестное поле %q (текст с двоеточием возьмите в кавычки)", field)
+	}
+	if op != ":" && field != "due" {
+		return QueryField{}, Invalid("поле %s поддерживает только сравнение через двоеточие", field)
+	}
+	return QueryField{Field: field, Op: op, Value: value}, nil
+}
+
+type queryTokenKind int
+
+const (
+	tokenEnd queryTokenKind = iota
+	tokenText
+	tokenField
+	tokenNot
+	tokenAnd
+	tokenOr
+	tokenOpen
+	tokenClose
+)
+
+type queryToken struct {
+	kind  queryTokenKind
+	text  string
+	field QueryField
+}
+
+// queryOperators - операторы сравнения; двухсимвольные проверяются первыми
+var queryOperators = []string{"<=", ">=", "<", ">", ":", "="}
+
+// tokenizeQuery делит запрос на слова, фразы в кавычках, скобки
+// и ключевые слова
+func tokenizeQuery(query string) ([]queryToken, error) {
+	var tokens []queryToken
+	rest := query
+	for {
+		rest = strings.TrimLeft(rest, " \t\r\n")
+		if rest == "" {
+			return tokens, nil
+		}
+
+		switch rest[0] {
+		case '(':
+			tokens = append(tokens, queryToken{kind: tokenOpen})
+			rest = rest[1:]
+			continue
+		case ')':
+			tokens = append(tokens, queryToken{kind: tokenClose})
+			rest = rest[1:]
+			continue
+		case '-':
+			tokens = append(tokens, queryToken{kind: tokenNot})
+			rest = rest[1:]
+			continue
+		case '"':
+			var text string
+			text, rest = readQuoted(rest)
+			if text = strings.TrimSpace(text); text != "" {
+				tokens = append(tokens, queryToken{kind: tokenText, text: text})
+			}
+			continue
+		}
+
+		end := strings.IndexAny(rest, " \t\r\n()\"")
+		if end < 0 {
+			end = len(rest)
+		}
+		word := rest[:end]
+		rest = rest[end:]
+
+		switch word {
+		case "AND":
+			tokens = append(tokens, queryToken{kind: tokenAnd})
+			continue
+		case "OR":
+			tokens = append(tokens, queryToken{kind: tokenOr})
+			continue
+		case "NOT":
+			tokens = append(tokens, queryToken{kind: tokenNot})
+			continue
+		}
+
+		at := strings.IndexAny(word, ":<>=")
+		if at <= 0 {
+			tokens = append(tokens, queryToken{kind: tokenText, text: word})
+			continue
+		}
+		op := ""
+		for _, candidate := range queryOperators {
+			if strings.HasPrefix(word[at:], candidate) {
+				op = candidate
+				break
+			}
+		}
+		value := word[at+len(op):]
+		// tag:"два слова" - значение в кавычках сразу после оператора
+		if value == "" && strings.HasPrefix(rest, `"`) {
+			value, rest = readQuoted(rest)
+		}
+		field, err := newQueryField(word[:at], op, value)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, queryToken{kind: tokenField, field: field})
+	}
+}
+
+// readQuoted читает фразу в кавычках; незакрытая кавычка идет до конца запроса
+func readQuoted(s string) (text, rest string) {
+	end := strings.IndexByte(s[1:], '"')
+	if end < 0 {
+		return s[1:], ""
+	}
+	return s[1 : end+1], s[end+2:]
+}
+
+// ParseTaskQuery разбирает запрос на языке запросов к задачам.
+// Для пустого запроса возвращает nil: он не ограничивает выборку.
+func ParseTaskQuery(query string) (QueryExpr, error) {
+	tokens, err := tokenizeQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	p := &queryParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenEnd {
+		return nil, Invalid("лишняя закрывающая скобка в запросе")
+	}
+	return expr, nil
+}
+
+// queryParser - нисходящий разбор:
+//
+//	or    = and { "OR" and }
+//	and   = unary { ["AND"] unary }
+//	unary = ("NOT" | "-") unary | "(" or ")" | условие
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+func (p *queryParser) peek() queryToken {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return queryToken{kind: tokenEnd}
+}
+
+func (p *queryParser) next() queryToken {
+	token := p.peek()
+	if p.pos < len(p.tokens) {
+		p.pos++
+	}
+	return token
+}
+
+func (p *queryParser) parseOr() (QueryExpr, error) {
+	expr, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	or := QueryOr{expr}
+	for p.peek().kind == tokenOr {
+		p.next()
+		expr, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, expr)
+	}
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+func (p *queryParser) parseAnd() (QueryExpr, error) {
+	var and QueryAnd
+	for {
+		switch p.peek().kind {
+		case tokenEnd, tokenOr, tokenClose:
+			if len(and) == 0 {
+				return nil, Invalid("в запросе пропущено условие")
+			}
+			if len(and) == 1 {
+				return and[0], nil
+			}
+			return and, nil
+		case tokenAnd:
+			if len(and) == 0 {
+				return nil, Invalid("в запросе пропущено условие перед AND")
+			}
+			p.next()
+		}
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, expr)
+	}
+}
+
+func (p *queryParser) parseUnary() (QueryExpr, error) {
+	token := p.next()
+	switch token.kind {
+	case tokenNot:
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return QueryNot{Expr: expr}, nil
+	case tokenOpen:
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokenClose {
+			return nil, Invalid("в запросе не закрыта скобка")
+		}
+		return expr, nil
+	case tokenText:
+		return QueryText(token.text), nil
+	case tokenField:
+		return token.field, nil
+	case tokenClose:
+		return nil, Invalid("лишняя закрывающая скобка в запросе")
+	}
+	return nil, Invalid("в запросе пропущено условие")
+}
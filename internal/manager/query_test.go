@@ -0,0 +1,105 @@
+package manager
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTaskQuery(t *testing.T) {
+	tests := map[string]string{
+		`priority:high tag:work -tag:later due<+7d is:open "report"`: `priority:high tag:work -tag:later due<+7d is:open "report"`,
+		"Priority=HIGH":              "priority:high",
+		"tag:Работа":                 "tag:Работа",
+		`tag:"два слова"`:            `tag:"два слова"`,
+		"due>=20.10.2026":            "due>=20.10.2026",
+		"a OR b c":                   `"a" OR "b" "c"`,
+		"(a OR b) c":                 `("a" OR "b") "c"`,
+		"a AND -b":                   `"a" -"b"`,
+		"NOT (a b)":                  `-("a" "b")`,
+		"--a":                        `--"a"`,
+		"e-mail 12.5":                `"e-mail" "12.5"`,
+		`"годовой отчет" OR is:done`: `"годовой отчет" OR is:done`,
+	}
+	for query, want := range tests {
+		expr, err := ParseTaskQuery(query)
+		if err != nil {
+			t.Errorf("%s: %v", query, err)
+			continue
+		}
+		if got := expr.String(); got != want {
+			t.Errorf("%s: получено %s, ожидалось %s", query, got, want)
+		}
+		// Строковое представление разбирается в то же выражение
+		again, err := ParseTaskQuery(expr.String())
+		if err != nil || again.String() != expr.String() {
+			t.Errorf("%s: повторный разбор дал %v, %v", query, again, err)
+		}
+	}
+
+	if expr, err := ParseTaskQuery("  "); expr != nil || err != nil {
+		t.Errorf("Пустой запрос: %v, %v", expr, err)
+	}
+
+	for _, query := range []string{
+		"foo:bar", "12:30", "priority:urgent", "priority<high", "is:later", "has:notes",
+		"due<soon", "tag:", "(a", "a)", "()", "a OR", "AND a", "a AND", "-", "NOT",
+	} {
+		if _, err := ParseTaskQuery(query); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%q: ожидалась ErrInvalid, получено %v", query, err)
+		}
+	}
+}
+
+func TestFilterByQueryInMemory(t *testing.T) {
+	tm := NewTaskManager()
+	today := time.Now()
+	day := func(offset int) *time.Time {
+		d := time.Date(today.Year(), today.Month(), today.Day()+offset, 12, 0, 0, 0, time.Local)
+		return &d
+	}
+	high, low := PriorityHigh, PriorityLow
+	done := true
+	weekly := "FREQ=WEEKLY"
+
+	tm.CreateTaskForUser(1, "Годовой отчет", []string{"Работа"}, UpdateTaskRequest{Priority: &high, DueDate: day(3)})
+	tm.CreateTaskForUser(1, "Позвонить в банк", []string{"работа", "потом"}, UpdateTaskRequest{DueDate: day(-1)})
+	tm.CreateTaskForUser(1, "Купить молоко", []string{"дом"}, UpdateTaskRequest{Priority: &low, Completed: &done})
+	tm.CreateTaskForUser(1, "Планерка", nil, UpdateTaskRequest{DueDate: day(10), Recurrence: &weekly})
+	tm.CreateTaskForUser(2, "Чужой отчет", []string{"работа"}, UpdateTaskRequest{Priority: &high})
+
+	tests := map[string]string{
+		`priority:high tag:работа -tag:потом due<+7d is:open "ОТЧЕТ"`: "Годовой отчет",
+		"tag:работа":                          "Годовой отчет, Позвонить в банк",
+		"tag:работа -tag:потом":               "Годовой отчет",
+		"is:overdue":                          "Позвонить в банк",
+		"is:done OR is:recurring":             "Купить молоко, Планерка",
+		"-has:due":                            "Купить молоко",
+		"has:tags -(tag:дом OR отч)":          "Позвонить в банк",
+		"due>=today due<=+1w":                 "Годовой отчет",
+		"NOT due<+1w":                         "Купить молоко, Планерка",
+		"due:" + day(10).Format("02.01.2006"): "Планерка",
+		"priority:medium банк OR молоко":      "Купить молоко, Позвонить в банк",
+	}
+	for query, want := range tests {
+		tasks, err := tm.FilterTasksAdvancedForUser(1, FilterOptions{Query: query})
+		if err != nil {
+			t.Errorf("%s: %v", query, err)
+			continue
+		}
+		var got []string
+		for _, task := range tasks {
+			got = append(got, task.Description)
+		}
+		sort.Strings(got)
+		if strings.Join(got, ", ") != want {
+			t.Errorf("%s: получено %q, ожидалось %q", query, strings.Join(got, ", "), want)
+		}
+	}
+
+	if _, err := tm.FilterTasksAdvancedForUser(1, FilterOptions{Query: "priority:urgent"}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Ошибка в запросе должна быть ErrInvalid, получено %v", err)
+	}
+}
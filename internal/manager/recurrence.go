@@ -0,0 +1,257 @@
+package manager
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Частоты повторения (FREQ из RFC 5545)
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+	FreqYearly  = "YEARLY"
+)
+
+// Recurrence - правило повторения задачи, подмножество RRULE из RFC 5545:
+//
+//	FREQ=DAILY;INTERVAL=3              каждые 3 дня
+//	FREQ=WEEKLY;BYDAY=MO,TH            по понедельникам и четвергам
+//	FREQ=MONTHLY;BYMONTHDAY=15         15 числа каждого месяца (-1 - последний день)
+//	FREQ=YEARLY;COUNT=5                раз в год, всего 5 раз
+//	FREQ=WEEKLY;UNTIL=20261231         еженедельно до конца 2026 года
+//
+// Остальные части RRULE (BYSETPOS, BYHOUR, BYDAY с номером и т.п.) отклоняются.
+type Recurrence struct {
+	Freq       string
+	Interval   int
+	ByDay      []time.Weekday
+	ByMonthDay int
+	Count      int
+	Until      time.Time
+}
+
+var weekdayCodes = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// ParseRecurrence разбирает правило; префикс "RRULE:" допускается
+func ParseRecurrence(rule string) (*Recurrence, error) {
+	rule = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(rule)), "RRULE:")
+	if rule == "" {
+		return nil, Invalid("пустое правило повторения")
+	}
+
+	r := &Recurrence{Interval: 1}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(rule, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return nil, Invalid("некорректная часть правила повторения: %q", part)
+		}
+		if seen[key] {
+			return nil, Invalid("%s указан в правиле повторения дважды", key)
+		}
+		seen[key] = true
+
+		switch key {
+		case "FREQ":
+			switch value {
+			case FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+				r.Freq = value
+			default:
+				return nil, Invalid("частота %s не поддерживается", value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > 366 {
+				return nil, Invalid("INTERVAL должен быть от 1 до 366")
+			}
+			r.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, Invalid("COUNT должен быть положительным числом")
+			}
+			r.Count = n
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return nil, err
+			}
+			r.Until = until
+		case "BYDAY":
+			for _, code := range strings.Split(value, ",") {
+				day := -1
+				for i, c := range weekdayCodes {
+					if c == code {
+						day = i
+					}
+				}
+				if day < 0 {
+					return nil, Invalid("некорректный день недели %q (ожидается MO, TU, WE, TH, FR, SA или SU)", code)
+				}
+				r.ByDay = append(r.ByDay, time.Weekday(day))
+			}
+		case "BYMONTHDAY":
+			n, err := strconv.Atoi(value)
+			if err != nil || n == 0 || n < -1 || n > 31 {
+				return nil, Invalid("BYMONTHDAY должен быть от 1 до 31 или -1")
+			}
+			r.ByMonthDay = n
+		default:
+			return nil, Invalid("%s в правиле повторения не поддерживается", key)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, Invalid("в правиле повторения нет FREQ")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, Invalid("COUNT и UNTIL нельзя указывать вместе")
+	}
+	if len(r.ByDay) > 0 && r.Freq != FreqWeekly {
+		return nil, Invalid("BYDAY поддерживается только с FREQ=WEEKLY")
+	}
+	if r.ByMonthDay != 0 && r.Freq != FreqMonthly {
+		return nil, Invalid("BYMONTHDAY поддерживается только с FREQ=MONTHLY")
+	}
+	return r, nil
+}
+
+// parseUntil принимает дату (20261231) или время в UTC (20261231T235959Z)
+func parseUntil(value string) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("20060102", value, time.Local); err == nil {
+		// Дата без времени включает весь день
+		return t.AddDate(0, 0, 1).Add(-time.Second), nil
+	}
+	return time.Time{}, Invalid("UNTIL должен быть в формате ГГГГММДД или ГГГГММДДTЧЧММССZ")
+}
+
+// String возвращает правило в каноническом виде, в котором оно хранится
+func (r *Recurrence) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		// В порядке недели, начиная с понедельника, без повторов
+		var codes []string
+		for i := 1; i <= 7; i++ {
+			day := time.Weekday(i % 7)
+			if r.hasDay(day) {
+				codes = append(codes, weekdayCodes[day])
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+	if r.ByMonthDay != 0 {
+		parts = append(parts, "BYMONTHDAY="+strconv.Itoa(r.ByMonthDay))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
+func (r *Recurrence) hasDay(day time.Weekday) bool {
+	for _, d := range r.ByDay {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Next возвращает первое повторение строго после from с тем же временем суток.
+// ok = false, если серия закончилась по UNTIL. COUNT учитывает вызывающий:
+// правило не знает, сколько экземпляров уже создано.
+func (r *Recurrence) Next(from time.Time) (next time.Time, ok bool) {
+	switch r.Freq {
+	case FreqDaily:
+		next = from.AddDate(0, 0, r.Interval)
+	case FreqWeekly:
+		next = r.nextWeekly(from)
+	case FreqMonthly:
+		next = r.nextMonthly(from)
+	case FreqYearly:
+		next = r.nextYearly(from)
+	}
+	if next.IsZero() || (!r.Until.IsZero() && next.After(r.Until)) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+func (r *Recurrence) nextWeekly(from time.Time) time.Time {
+	if len(r.ByDay) == 0 {
+		return from.AddDate(0, 0, 7*r.Interval)
+	}
+	// Недели считаются с понедельника; подходят только недели,
+	// отстоящие от недели from на кратное INTERVAL число
+	weekStart := from.AddDate(0, 0, -((int(from.Weekday()) + 6) % 7))
+	for i := 1; i <= 7*r.Interval+7; i++ {
+		day := from.AddDate(0, 0, i)
+		weeks := daysBetween(weekStart, day) / 7
+		if weeks%r.Interval == 0 && r.hasDay(day.Weekday()) {
+			return day
+		}
+	}
+	return time.Time{}
+}
+
+func (r *Recurrence) nextMonthly(from time.Time) time.Time {
+	y, m, d := from.Date()
+	hour, min, sec := from.Clock()
+	// Как в RFC 5545: месяцы, в которых нет нужного числа (31 февраля), пропускаются
+	for k := 0; k <= 12*r.Interval*4; k += r.Interval {
+		first := time.Date(y, m+time.Month(k), 1, hour, min, sec, 0, from.Location())
+		day := d
+		switch {
+		case r.ByMonthDay == -1:
+			day = daysIn(first)
+		case r.ByMonthDay > 0:
+			day = r.ByMonthDay
+		}
+		if day > daysIn(first) {
+			continue
+		}
+		candidate := first.AddDate(0, 0, day-1)
+		if candidate.After(from) {
+			return candidate
+		}
+	}
+	return time.Time{}
+}
+
+func (r *Recurrence) nextYearly(from time.Time) time.Time {
+	y, m, d := from.Date()
+	hour, min, sec := from.Clock()
+	// 29 февраля повторяется только в високосные годы
+	for k := r.Interval; k <= r.Interval*8; k += r.Interval {
+		first := time.Date(y+k, m, 1, hour, min, sec, 0, from.Location())
+		if d <= daysIn(first) {
+			return first.AddDate(0, 0, d-1)
+		}
+	}
+	return time.Time{}
+}
+
+// daysIn - число дней в месяце t
+func daysIn(t time.Time) int {
+	return time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// daysBetween - число календарных дней между датами (без учета перевода часов)
+func daysBetween(a, b time.Time) int {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	da := time.Date(ay, am, ad, 0, 0, 0, 0, time.UTC)
+	db := time.Date(by, bm, bd, 0, 0, 0, 0, time.UTC)
+	return int(db.Sub(da).Hours() / 24)
+}
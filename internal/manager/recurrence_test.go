@@ -0,0 +1,283 @@
+package manager
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseRecurrence(t *testing.T) {
+	valid := map[string]string{
+		"FREQ=DAILY":                         "FREQ=DAILY",
+		"rrule:freq=daily;interval=3":        "FREQ=DAILY;INTERVAL=3",
+		"FREQ=WEEKLY;BYDAY=FR,MO,MO":         "FREQ=WEEKLY;BYDAY=MO,FR",
+		"FREQ=MONTHLY;BYMONTHDAY=-1":         "FREQ=MONTHLY;BYMONTHDAY=-1",
+		"FREQ=YEARLY;COUNT=5":                "FREQ=YEARLY;COUNT=5",
+		"FREQ=WEEKLY;UNTIL=20261231T090000Z": "FREQ=WEEKLY;UNTIL=20261231T090000Z",
+	}
+	for rule, want := range valid {
+		r, err := ParseRecurrence(rule)
+		if err != nil {
+			t.Errorf("%q: неожиданная ошибка %v", rule, err)
+			continue
+		}
+		if got := r.String(); got != want {
+			t.Errorf("%q: ожидалось %q, получено %q", rule, want, got)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;BYDAY=MO",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=DAILY;COUNT=3;UNTIL=20261231",
+		"FREQ=DAILY;BYSETPOS=1",
+		"FREQ=DAILY;FREQ=WEEKLY",
+	}
+	for _, rule := range invalid {
+		if _, err := ParseRecurrence(rule); err == nil {
+			t.Errorf("%q: ожидалась ошибка", rule)
+		}
+	}
+}
+
+func TestRecurrenceNext(t *testing.T) {
+	date := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 18, 30, 0, 0, time.Local)
+	}
+	tests := []struct {
+		rule string
+		from time.Time
+		want time.Time
+	}{
+		{"FREQ=DAILY", date(2026, 10, 16), date(2026, 10, 17)},
+		{"FREQ=DAILY;INTERVAL=3", date(2026, 10, 30), date(2026, 11, 2)},
+		{"FREQ=WEEKLY", date(2026, 10, 16), date(2026, 10, 23)},
+		// 16.10.2026 - пятница
+		{"FREQ=WEEKLY;BYDAY=MO,TH", date(2026, 10, 16), date(2026, 10, 19)},
+		{"FREQ=WEEKLY;BYDAY=MO,TH", date(2026, 10, 19), date(2026, 10, 22)},
+		{"FREQ=WEEKLY;BYDAY=SA", date(2026, 10, 16), date(2026, 10, 17)},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR", date(2026, 10, 12), date(2026, 10, 16)},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR", date(2026, 10, 16), date(2026, 10, 26)},
+		{"FREQ=MONTHLY", date(2026, 10, 16), date(2026, 11, 16)},
+		{"FREQ=MONTHLY;BYMONTHDAY=20", date(2026, 10, 16), date(2026, 10, 20)},
+		{"FREQ=MONTHLY;BYMONTHDAY=5", date(2026, 10, 16), date(2026, 11, 5)},
+		{"FREQ=MONTHLY;BYMONTHDAY=-1", date(2027, 1, 31), date(2027, 2, 28)},
+		// 31 числа нет в ноябре - по RFC 5545 месяц пропускается
+		{"FREQ=MONTHLY", date(2026, 10, 31), date(2026, 12, 31)},
+		{"FREQ=MONTHLY;INTERVAL=3", date(2026, 11, 10), date(2027, 2, 10)},
+		{"FREQ=YEARLY", date(2026, 10, 16), date(2027, 10, 16)},
+		{"FREQ=YEARLY", date(2028, 2, 29), date(2032, 2, 29)},
+	}
+	for _, tt := range tests {
+		r, err := ParseRecurrence(tt.rule)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.rule, err)
+		}
+		got, ok := r.Next(tt.from)
+		if !ok || !got.Equal(tt.want) {
+			t.Errorf("%s от %s: ожидалось %s, получено %s", tt.rule,
+				tt.from.Format("02.01.2006"), tt.want.Format("02.01.2006 15:04"), got.Format("02.01.2006 15:04"))
+		}
+	}
+
+	r, _ := ParseRecurrence("FREQ=WEEKLY;UNTIL=20261025")
+	if _, ok := r.Next(date(2026, 10, 16)); !ok {
+		t.Error("23.10 входит в серию до 25.10")
+	}
+	if _, ok := r.Next(date(2026, 10, 23)); ok {
+		t.Error("30.10 после UNTIL, серия должна закончиться")
+	}
+}
+
+func TestCompleteRecurringTask(t *testing.T) {
+	tm := NewTaskManager()
+	stm := NewSubTaskManager()
+	tm.SetSubTaskManager(stm)
+
+	id, _ := tm.AddTaskForUser(1, "Вынести мусор", []string{"дом"})
+	due := time.Now().AddDate(0, 0, 1)
+	high := PriorityHigh
+	rule := "freq=weekly"
+	task, err := tm.UpdateTaskForUser(1, id, UpdateTaskRequest{DueDate: &due, Priority: &high, Recurrence: &rule})
+	if err != nil {
+		t.Fatalf("Ошибка установки повторения: %v", err)
+	}
+	if task.Recurrence != "FREQ=WEEKLY" || task.SeriesID != id {
+		t.Fatalf("Правило должно сохраниться в каноническом виде и открыть серию: %+v", task)
+	}
+	stm.AddSubTask(1, id, "Пакеты")
+
+	tm.ToggleCompleteForUser(1, id)
+	tasks, _ := tm.GetAllTasksForUser(1)
+	if len(tasks) != 2 {
+		t.Fatalf("Ожидался следующий экземпляр, задач: %d", len(tasks))
+	}
+	var next Task
+	for _, t := range tasks {
+		if t.ID != id {
+			next = t
+		}
+	}
+	if next.Completed || !next.DueDate.Equal(due.AddDate(0, 0, 7)) || next.Priority != PriorityHigh ||
+		next.SeriesID != id || len(next.Tags) != 1 || next.Tags[0] != "дом" {
+		t.Errorf("Следующий экземпляр скопирован неверно: %+v", next)
+	}
+	if subtasks, _ := stm.GetSubTasks(1, next.ID); len(subtasks) != 1 || subtasks[0].Completed {
+		t.Errorf("Подзадачи должны скопироваться невыполненными: %+v", subtasks)
+	}
+
+	// Повторное выполнение после снятия отметки не плодит экземпляры
+	tm.ToggleCompleteForUser(1, id)
+	tm.ToggleCompleteForUser(1, id)
+	if tasks, _ := tm.GetAllTasksForUser(1); len(tasks) != 2 {
+		t.Errorf("Ожидалось 2 задачи, получено %d", len(tasks))
+	}
+
+	// Просроченная задача сдвигается не в прошлое
+	overdue := time.Now().AddDate(0, 0, -20)
+	tm.UpdateTaskForUser(1, next.ID, UpdateTaskRequest{DueDate: &overdue})
+	tm.ToggleCompleteForUser(1, next.ID)
+	tasks, _ = tm.GetAllTasksForUser(1)
+	for _, t2 := range tasks {
+		if !t2.Completed && t2.DueDate.Before(time.Now().AddDate(0, 0, -1)) {
+			t.Errorf("Следующий срок в прошлом: %s", t2.DueDate)
+		}
+	}
+}
+
+func TestUndoRecurringCompletion(t *testing.T) {
+	tm := NewTaskManager()
+	id, _ := tm.AddTaskForUser(1, "Полить цветы", nil)
+	rule := "FREQ=DAILY"
+	tm.UpdateTaskForUser(1, id, UpdateTaskRequest{Recurrence: &rule})
+
+	_, spawnedID, err := tm.ToggleCompleteForUser(1, id)
+	if err != nil || spawnedID == 0 {
+		t.Fatalf("Выполнение должно создать следующий экземпляр: %d, %v", spawnedID, err)
+	}
+	task, err := tm.UndoCompleteForUser(1, id, spawnedID)
+	if err != nil || task.Completed {
+		t.Fatalf("Отмена выполнения: %+v, %v", task, err)
+	}
+	// Отмена убирает и созданный экземпляр: открытым остается один
+	if tasks, _ := tm.GetAllTasksForUser(1); len(tasks) != 1 || tasks[0].ID != id {
+		t.Errorf("После отмены ожидалась одна задача, получено %+v", tasks)
+	}
+	if trash, _ := tm.GetTrashForUser(1); len(trash) != 1 || trash[0].ID != spawnedID {
+		t.Errorf("Созданный экземпляр должен быть в корзине: %+v", trash)
+	}
+
+	// Повторное выполнение снова создает экземпляр; выполненный экземпляр
+	// отмена не трогает
+	_, spawnedID, _ = tm.ToggleCompleteForUser(1, id)
+	if spawnedID == 0 {
+		t.Fatal("Повторное выполнение после отмены должно создать экземпляр")
+	}
+	tm.ToggleCompleteForUser(1, spawnedID)
+	tm.UndoCompleteForUser(1, id, spawnedID)
+	if next, err := tm.GetTaskForUser(1, spawnedID); err != nil || !next.Completed {
+		t.Errorf("Выполненный экземпляр не должен попасть в корзину: %+v, %v", next, err)
+	}
+}
+
+func TestRecurringSeriesLimits(t *testing.T) {
+	tm := NewTaskManager()
+	id, _ := tm.AddTaskForUser(1, "Три тренировки", nil)
+	rule := "FREQ=DAILY;COUNT=2"
+	tm.UpdateTaskForUser(1, id, UpdateTaskRequest{Recurrence: &rule})
+
+	tm.ToggleCompleteForUser(1, id)
+	tasks, _ := tm.GetAllTasksForUser(1)
+	if len(tasks) != 2 {
+		t.Fatalf("Ожидалось 2 экземпляра, получено %d", len(tasks))
+	}
+	for _, task := range tasks {
+		if task.ID != id {
+			tm.ToggleCompleteForUser(1, task.ID)
+		}
+	}
+	if tasks, _ := tm.GetAllTasksForUser(1); len(tasks) != 2 {
+		t.Errorf("COUNT=2: третий экземпляр не создается, получено %d задач", len(tasks))
+	}
+
+	// Снятое правило прекращает серию
+	once, _ := tm.AddTaskForUser(1, "Разовая", nil)
+	daily := "FREQ=DAILY"
+	empty := ""
+	tm.UpdateTaskForUser(1, once, UpdateTaskRequest{Recurrence: &daily})
+	tm.UpdateTaskForUser(1, once, UpdateTaskRequest{Recurrence: &empty})
+	tm.ToggleCompleteForUser(1, once)
+	if tasks, _ := tm.GetAllTasksForUser(1); len(tasks) != 3 {
+		t.Errorf("Задача без правила не повторяется, получено %d задач", len(tasks))
+	}
+
+	bad := "FREQ=SECONDLY"
+	if _, err := tm.UpdateTaskForUser(1, once, UpdateTaskRequest{Recurrence: &bad}); err == nil {
+		t.Error("Ожидалась ошибка для неподдерживаемого правила")
+	}
+}
+
+func TestUpdateSeries(t *testing.T) {
+	tm := NewTaskManager()
+	id, _ := tm.AddTaskForUser(1, "Полить цветы", nil)
+	rule := "FREQ=DAILY;INTERVAL=2"
+	tm.UpdateTaskForUser(1, id, UpdateTaskRequest{Recurrence: &rule})
+	tm.ToggleCompleteForUser(1, id)
+
+	tasks, _ := tm.GetAllTasksForUser(1)
+	var open int
+	for _, task := range tasks {
+		if !task.Completed {
+			open = task.ID
+		}
+	}
+
+	// Правка экземпляра не трогает серию
+	single := "Полить цветы и кактус"
+	tm.UpdateTaskForUser(1, open, UpdateTaskRequest{Description: &single})
+	if done, _ := tm.GetTaskForUser(1, id); done.Description != "Полить цветы" {
+		t.Errorf("Правка экземпляра изменила другой: %q", done.Description)
+	}
+
+	desc := "Полить все цветы"
+	weekly := "FREQ=WEEKLY"
+	updated, err := tm.UpdateSeriesForUser(1, id, UpdateTaskRequest{Description: &desc, Recurrence: &weekly})
+	if err != nil {
+		t.Fatalf("Ошибка правки серии: %v", err)
+	}
+	if len(updated) != 1 || updated[0].ID != open || updated[0].Description != desc || updated[0].Recurrence != weekly {
+		t.Errorf("Правка серии должна изменить открытый экземпляр: %+v", updated)
+	}
+	if done, _ := tm.GetTaskForUser(1, id); done.Description != "Полить цветы" {
+		t.Errorf("Выполненные экземпляры остаются историей, получено %q", done.Description)
+	}
+
+	// Теги серии приводятся к уже используемому написанию, как у экземпляра
+	tags := []string{"Work"}
+	tm.UpdateTaskForUser(1, id, UpdateTaskRequest{Tags: &tags})
+	lower := []string{"work"}
+	updated, err = tm.UpdateSeriesForUser(1, id, UpdateTaskRequest{Tags: &lower})
+	if err != nil {
+		t.Fatalf("Ошибка правки тегов серии: %v", err)
+	}
+	if len(updated) != 1 || !slices.Equal(updated[0].Tags, []string{"Work"}) {
+		t.Errorf("Ожидались теги [Work], получено %+v", updated)
+	}
+
+	due := time.Now()
+	if _, err := tm.UpdateSeriesForUser(1, id, UpdateTaskRequest{DueDate: &due}); err == nil {
+		t.Error("Срок серии менять нельзя")
+	}
+	plain, _ := tm.AddTaskForUser(1, "Обычная", nil)
+	if _, err := tm.UpdateSeriesForUser(1, plain, UpdateTaskRequest{Description: &desc}); err == nil {
+		t.Error("У неповторяющейся задачи нет серии")
+	}
+	if _, err := tm.UpdateSeriesForUser(2, id, UpdateTaskRequest{Description: &desc}); err == nil {
+		t.Error("Чужую серию менять нельзя")
+	}
+}
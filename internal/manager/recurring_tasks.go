@@ -0,0 +1,288 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"todo-app/internal/logger"
+)
+
+// completeRecurring создает следующий экземпляр, если выполнена повторяющаяся
+// задача; в истории его создание записывается на выполнившего actorID.
+// Выполнение уже сохранено, поэтому ошибка только логируется. Возвращает
+// ID созданного экземпляра, 0 - не создан. Вызывается под tm.mu.
+func (tm *TaskManager) completeRecurring(task Task, actorID int) int {
+	if !task.Completed || task.Recurrence == "" {
+		return 0
+	}
+	next, err := tm.spawnNext(task)
+	if err != nil {
+		logger.Error(context.Background(), err, "Ошибка создания следующего повторения задачи", "taskID", task.ID)
+		return 0
+	}
+	if next == nil {
+		return 0
+	}
+	logger.Info(context.Background(), "🔁 Создано следующее повторение задачи",
+		"taskID", task.ID, "nextID", next.ID, "dueDate", next.DueDate)
+	tm.recordEvent(tm.newEvent(actorID, next.ID, EventCreated, taskChanges(Task{}, *next)))
+	return next.ID
+}
+
+// UndoCompleteForUser отменяет переключение статуса задачи id целиком:
+// переключает статус обратно и убирает в корзину экземпляр spawnedID,
+// созданный тем выполнением (см. ToggleCompleteForUser), если его еще не
+// выполнили. Иначе после отмены в серии остались бы два открытых экземпляра.
+func (tm *TaskManager) UndoCompleteForUser(userID, id, spawnedID int) (*Task, error) {
+	task, _, err := tm.ToggleCompleteForUser(userID, id)
+	if err != nil || spawnedID == 0 || task.Completed {
+		return task, err
+	}
+	next, err := tm.GetTaskForUser(userID, spawnedID)
+	if errors.Is(err, ErrNotFound) {
+		return task, nil // экземпляр уже удалили
+	}
+	if err != nil {
+		return nil, err
+	}
+	seriesID := task.SeriesID
+	if seriesID == 0 {
+		seriesID = task.ID
+	}
+	if next.Completed || next.SeriesID != seriesID {
+		return task, nil
+	}
+	if err := tm.DeleteTaskForUser(userID, next.ID); err != nil {
+		return nil, err
+	}
+	logger.Info(context.Background(), "🔁 Отмена выполнения убрала следующее повторение задачи",
+		"taskID", id, "nextID", next.ID)
+	return task, nil
+}
+
+// spawnNext создает следующий экземпляр серии со сдвинутым сроком, теми же
+// тегами, приоритетом и копией подзадач. Возвращает nil, если серия
+// закончилась или открытый экземпляр уже есть (задачу выполнили повторно
+// после снятия отметки). Вызывается под tm.mu.
+func (tm *TaskManager) spawnNext(done Task) (*Task, error) {
+	rule, err := ParseRecurrence(done.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+	seriesID := done.SeriesID
+	if seriesID == 0 {
+		seriesID = done.ID
+	}
+
+	series, err := tm.seriesTasks(done.UserID, seriesID)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range series {
+		if !t.Completed && t.ID != done.ID {
+			return nil, nil
+		}
+	}
+	if rule.Count > 0 && len(series) >= rule.Count {
+		return nil, nil
+	}
+
+	// Без срока серия отсчитывается от дня выполнения
+	y, m, d := time.Now().Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	due := done.DueDate
+	if due.IsZero() {
+		due = today
+	}
+	// Пропущенные повторения не создаем: следующий срок - не раньше сегодняшнего дня
+	for {
+		next, ok := rule.Next(due)
+		if !ok {
+			return nil, nil
+		}
+		due = next
+		if !due.Before(today) {
+			break
+		}
+	}
+
+	now := time.Now()
+	next := Task{
+		UserID:      done.UserID,
+		Description: done.Description,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Priority:    done.Priority,
+		DueDate:     due,
+		Tags:        append([]string{}, done.Tags...),
+		Recurrence:  done.Recurrence,
+		SeriesID:    seriesID,
+		ProjectID:   done.ProjectID,
+		AssigneeID:  done.AssigneeID,
+		Version:     1,
+	}
+
+	if tm.storage != nil {
+		// Экземпляр и копии подзадач сохраняются одной транзакцией
+		subtasks, err := tm.storage.GetSubTasks(done.UserID, done.ID)
+		if err != nil {
+			return nil, err
+		}
+		descriptions := make([]string, len(subtasks))
+		for i, st := range subtasks {
+			descriptions[i] = st.Description
+		}
+		if next.ID, err = tm.storage.CreateTask(&next, descriptions); err != nil {
+			return nil, err
+		}
+	} else {
+		next.ID = tm.nextID
+		tm.tasks[next.ID] = next
+		tm.nextID++
+		if tm.subtasks != nil {
+			subtasks, err := tm.subtasks.listSubTasks(done.UserID, done.ID)
+			if err != nil {
+				return nil, err
+			}
+			for _, st := range subtasks {
+				if _, err := tm.subtasks.addSubTask(done.UserID, next.ID, st.Description); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	tm.syncTask(next.ID)
+	tm.notifyChanged(next)
+	return &next, nil
+}
+
+// seriesTasks возвращает все экземпляры серии; вызывается под tm.mu
+func (tm *TaskManager) seriesTasks(userID, seriesID int) ([]Task, error) {
+	if tm.storage != nil {
+		return tm.storage.GetSeriesTasks(userID, seriesID)
+	}
+	tasks := []Task{}
+	for _, task := range tm.tasks {
+		if task.UserID == userID && (task.SeriesID == seriesID || task.ID == seriesID) {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks, nil
+}
+
+// UpdateSeriesForUser применяет изменения ко всей серии, в которую входит
+// задача id: ко всем невыполненным экземплярам и к правилу, по которому
+// будут создаваться следующие. Выполненные экземпляры остаются историей.
+// Срок и статус у каждого экземпляра свои и меняются через UpdateTaskForUser.
+func (tm *TaskManager) UpdateSeriesForUser(userID, id int, req UpdateTaskRequest) ([]Task, error) {
+	if req.DueDate != nil || req.Completed != nil {
+		return nil, Invalid("срок и статус меняются у отдельного экземпляра, а не у серии")
+	}
+	if err := prepareUpdate(&req); err != nil {
+		return nil, err
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	task, err := tm.authorizeTask(userID, id, ActionEdit)
+	if err != nil {
+		return nil, err
+	}
+	// Версия относится к задаче id, у остальных экземпляров она своя
+	if err := checkVersion(task, req.Version); err != nil {
+		return nil, err
+	}
+	req.Version = nil
+	if task.SeriesID == 0 {
+		return nil, Invalid("задача не повторяется")
+	}
+	if req.ProjectID != nil {
+		if _, err := tm.targetProject(userID, *req.ProjectID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Серия принадлежит автору задачи; экземпляры могли разойтись по
+	// проектам, поэтому права и исполнитель проверяются у каждого до изменений
+	series, err := tm.seriesTasks(task.UserID, task.SeriesID)
+	if err != nil {
+		return nil, err
+	}
+	requests := make(map[int]UpdateTaskRequest)
+	for _, t := range series {
+		if t.Completed {
+			continue
+		}
+		if _, err := tm.authorizeTask(userID, t.ID, ActionEdit); err != nil {
+			return nil, err
+		}
+		instanceReq := req
+		projectID := t.ProjectID
+		if req.ProjectID != nil {
+			projectID = *req.ProjectID
+		}
+		if req.AssigneeID != nil {
+			if err := tm.checkAssignee(t, projectID, *req.AssigneeID); err != nil {
+				return nil, err
+			}
+		} else if assignee := tm.keepAssignee(t, projectID); assignee != t.AssigneeID {
+			instanceReq.AssigneeID = &assignee
+		}
+		requests[t.ID] = instanceReq
+	}
+
+	// В SQLite вся серия сохраняется одной транзакцией: ошибка на одном
+	// экземпляре не оставляет серию исправленной наполовину
+	var saved map[int]Task
+	if tm.storage != nil {
+		if saved, err = tm.storage.UpdateTasks(task.UserID, requests); err != nil {
+			return nil, err
+		}
+	}
+
+	updated := []Task{}
+	for _, t := range series {
+		if t.Completed {
+			continue
+		}
+		req := requests[t.ID]
+		before := t
+		previousAssignee := t.AssigneeID
+		if tm.storage != nil {
+			t = saved[t.ID]
+		} else {
+			if req.Description != nil {
+				t.Description = *req.Description
+			}
+			if req.Priority != nil {
+				t.Priority = *req.Priority
+			}
+			if req.Tags != nil {
+				t.Tags = tm.canonicalTags(t.UserID, *req.Tags)
+			}
+			if req.Recurrence != nil {
+				t.Recurrence = *req.Recurrence
+			}
+			if req.ProjectID != nil {
+				t.ProjectID = *req.ProjectID
+			}
+			if req.AssigneeID != nil {
+				t.AssigneeID = *req.AssigneeID
+			}
+			t.UpdatedAt = time.Now()
+			t.Version++
+			tm.tasks[t.ID] = t
+			tm.syncTask(t.ID)
+		}
+		tm.notifyChanged(t)
+		tm.notifyAssigned(t, previousAssignee, userID)
+		tm.recordEvent(tm.newEvent(userID, t.ID, EventUpdated, taskChanges(before, t)))
+		updated = append(updated, t)
+	}
+
+	logger.Info(context.Background(), "Серия задач обновлена", "seriesID", task.SeriesID, "updated", len(updated))
+	return updated, nil
+}
@@ -0,0 +1,137 @@
+package manager
+
+import (
+	"sort"
+	"time"
+)
+
+// ReminderClaimLease - на сколько процесс забирает напоминание на отправку.
+// Если процесс упал, не отметив результат, по истечении аренды напоминание
+// снова сможет забрать любой процесс.
+const ReminderClaimLease = 5 * time.Minute
+
+// reminderClaim - кто и до какого времени отправляет напоминание (для хранения в памяти)
+type reminderClaim struct {
+	owner string
+	until time.Time
+}
+
+// Changes сигналит о новых напоминаниях, чтобы планировщик не ждал очередного опроса
+func (rm *ReminderManager) Changes() <-chan struct{} {
+	return rm.changed
+}
+
+// ClaimDue забирает на отправку до limit напоминаний, время которых наступило:
+// ожидающие со временем срабатывания не позже now и неудачные, у которых
+// подошло время повтора. Забранное напоминание получает статус pending
+// и увеличенный счетчик попыток; другой процесс его не получит.
+func (rm *ReminderManager) ClaimDue(owner string, now time.Time, limit int) ([]Reminder, error) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if rm.storage != nil {
+		return rm.storage.ClaimDueReminders(owner, now, now.Add(ReminderClaimLease), limit)
+	}
+
+	due := []Reminder{}
+	for _, r := range rm.reminders {
+		if claim, ok := rm.claims[r.ID]; ok && claim.until.After(now) {
+			continue
+		}
+		ready := r.Status == ReminderPending && !r.TriggerTime.After(now)
+		retry := r.Status == ReminderFailed && r.NextAttemptAt != nil && !r.NextAttemptAt.After(now)
+		if ready || retry {
+			due = append(due, r)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].TriggerTime.Before(due[j].TriggerTime)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	for i := range due {
+		r := &due[i]
+		r.Status = ReminderPending
+		r.Attempts++
+		r.UpdatedAt = time.Now()
+		rm.reminders[r.ID] = *r
+		rm.claims[r.ID] = reminderClaim{owner: owner, until: now.Add(ReminderClaimLease)}
+	}
+	return due, nil
+}
+
+// MarkSent отмечает забранное напоминание отправленным
+func (rm *ReminderManager) MarkSent(owner string, reminder *Reminder) error {
+	now := time.Now()
+	reminder.Status = ReminderSent
+	reminder.SentAt = &now
+	reminder.LastError = ""
+	reminder.NextAttemptAt = nil
+	return rm.finishDelivery(owner, reminder)
+}
+
+// MarkFailed отмечает неудачную отправку. retryAt - когда повторить;
+// nil означает, что попытки исчерпаны.
+func (rm *ReminderManager) MarkFailed(owner string, reminder *Reminder, deliveryErr error, retryAt *time.Time) error {
+	reminder.Status = ReminderFailed
+	reminder.LastError = deliveryErr.Error()
+	reminder.NextAttemptAt = retryAt
+	return rm.finishDelivery(owner, reminder)
+}
+
+// NextDue возвращает ближайшее время, когда появится работа для планировщика.
+// ok = false, если ждать нечего.
+func (rm *ReminderManager) NextDue(now time.Time) (next time.Time, ok bool, err error) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if rm.storage != nil {
+		t, err := rm.storage.NextReminderTime(now)
+		if err != nil || t == nil {
+			return time.Time{}, false, err
+		}
+		return *t, true, nil
+	}
+
+	for _, r := range rm.reminders {
+		if claim, claimed := rm.claims[r.ID]; claimed && claim.until.After(now) {
+			continue
+		}
+		var at time.Time
+		switch {
+		case r.Status == ReminderPending:
+			at = r.TriggerTime
+		case r.Status == ReminderFailed && r.NextAttemptAt != nil:
+			at = *r.NextAttemptAt
+		default:
+			continue
+		}
+		if !ok || at.Before(next) {
+			next, ok = at, true
+		}
+	}
+	return next, ok, nil
+}
+
+// finishDelivery сохраняет результат отправки, если напоминание все еще
+// забрано owner и не отменено за время отправки
+func (rm *ReminderManager) finishDelivery(owner string, reminder *Reminder) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	reminder.UpdatedAt = time.Now()
+	if rm.storage != nil {
+		return rm.storage.FinishReminderDelivery(owner, reminder)
+	}
+
+	current, exists := rm.reminders[reminder.ID]
+	claim, claimed := rm.claims[reminder.ID]
+	if !exists || !claimed || claim.owner != owner || current.Status != ReminderPending {
+		return Conflict("напоминание с ID %d уже обработано или отменено", reminder.ID)
+	}
+	delete(rm.claims, reminder.ID)
+	rm.reminders[reminder.ID] = *reminder
+	return nil
+}
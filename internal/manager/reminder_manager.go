@@ -0,0 +1,468 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"todo-app/internal/logger"
+)
+
+// ReminderStatus - состояние напоминания.
+// Переходы: pending -> sent | failed | cancelled, failed -> pending | cancelled.
+// sent и cancelled - конечные.
+type ReminderStatus string
+
+const (
+	ReminderPending   ReminderStatus = "pending"
+	ReminderSent      ReminderStatus = "sent"
+	ReminderFailed    ReminderStatus = "failed"
+	ReminderCancelled ReminderStatus = "cancelled"
+)
+
+// CanTransition проверяет, допустим ли переход в статус to
+func (s ReminderStatus) CanTransition(to ReminderStatus) bool {
+	switch s {
+	case ReminderPending:
+		return to == ReminderSent || to == ReminderFailed || to == ReminderCancelled
+	case ReminderFailed:
+		return to == ReminderPending || to == ReminderCancelled
+	}
+	return false
+}
+
+// Типы напоминаний (колонка type сохранилась от первой версии бота)
+const (
+	ReminderTypeDeadline   = "deadline"   // за N дней до срока задачи
+	ReminderTypeCustom     = "custom"     // на конкретное время
+	ReminderTypeAssignment = "assignment" // задачу назначили пользователю, отправляется сразу
+)
+
+// ReminderHour - в котором часу приходят напоминания "за N дней до срока"
+const ReminderHour = 9
+
+type Reminder struct {
+	ID          int            `json:"id"`
+	TaskID      int            `json:"task_id"`
+	UserID      int            `json:"user_id"`
+	Type        string         `json:"type"`
+	Message     string         `json:"message"`
+	TriggerTime time.Time      `json:"trigger_time"`
+	DaysBefore  *int           `json:"days_before,omitempty"`
+	Status      ReminderStatus `json:"status"`
+	Channel     string         `json:"channel"`
+	Auto        bool           `json:"auto"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+
+	// Доставка (заполняет планировщик)
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+}
+
+// CreateReminderRequest - либо DaysBefore относительно срока задачи, либо TriggerTime
+type CreateReminderRequest struct {
+	DaysBefore  *int       `json:"days_before,omitempty"`
+	TriggerTime *time.Time `json:"trigger_time,omitempty"`
+	Message     string     `json:"message"`
+}
+
+// ReminderSettings - настройки автоматических напоминаний пользователя
+type ReminderSettings struct {
+	UserID                int  `json:"user_id"`
+	Enabled               bool `json:"enabled"`
+	RemindBeforeDays      int  `json:"remind_before_days"`
+	TelegramNotifications bool `json:"telegram_notifications"`
+	PushNotifications     bool `json:"push_notifications"`
+}
+
+// DefaultReminderSettings совпадают со значениями по умолчанию в таблице reminder_settings
+func DefaultReminderSettings(userID int) *ReminderSettings {
+	return &ReminderSettings{
+		UserID:                userID,
+		Enabled:               true,
+		RemindBeforeDays:      1,
+		TelegramNotifications: true,
+	}
+}
+
+type ReminderManager struct {
+	mu        sync.Mutex
+	reminders map[int]Reminder
+	settings  map[int]ReminderSettings
+	claims    map[int]reminderClaim
+	nextID    int
+	storage   Storage
+	tasks     *TaskManager
+	changed   chan struct{}
+}
+
+// NewReminderManager создает менеджер напоминаний и подключает его к задачам:
+// изменение срока, выполнение и удаление задачи обновляют ее автонапоминания
+func NewReminderManager(tasks *TaskManager) *ReminderManager {
+	rm := &ReminderManager{
+		reminders: make(map[int]Reminder),
+		settings:  make(map[int]ReminderSettings),
+		claims:    make(map[int]reminderClaim),
+		nextID:    1,
+		tasks:     tasks,
+		changed:   make(chan struct{}, 1),
+	}
+	tasks.SetReminderManager(rm)
+	return rm
+}
+
+func NewReminderManagerWithStorage(storage Storage, tasks *TaskManager) *ReminderManager {
+	rm := NewReminderManager(tasks)
+	rm.storage = storage
+	return rm
+}
+
+// reminderTriggerTime - ReminderHour по местному времени за daysBefore дней до срока
+func reminderTriggerTime(due time.Time, daysBefore int) time.Time {
+	y, m, d := due.Date()
+	return time.Date(y, m, d-daysBefore, ReminderHour, 0, 0, 0, time.Local)
+}
+
+// CreateReminder добавляет напоминание к задаче пользователя
+func (rm *ReminderManager) CreateReminder(userID, taskID int, req CreateReminderRequest) (*Reminder, error) {
+	message := strings.TrimSpace(req.Message)
+	if message == "" {
+		return nil, Invalid("текст напоминания обязателен")
+	}
+	if len(message) > 500 {
+		return nil, Invalid("текст напоминания не может превышать 500 символов")
+	}
+
+	task, err := rm.tasks.GetTaskForUser(userID, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	reminder := Reminder{
+		TaskID:    taskID,
+		UserID:    userID,
+		Message:   message,
+		Status:    ReminderPending,
+		Channel:   "telegram",
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	switch {
+	case req.DaysBefore != nil:
+		if *req.DaysBefore < 0 || *req.DaysBefore > 365 {
+			return nil, Invalid("напомнить можно за 0-365 дней до срока")
+		}
+		if task.DueDate.IsZero() {
+			return nil, Invalid("у задачи нет срока выполнения")
+		}
+		days := *req.DaysBefore
+		reminder.Type = ReminderTypeDeadline
+		reminder.DaysBefore = &days
+		reminder.TriggerTime = reminderTriggerTime(task.DueDate, days)
+	case req.TriggerTime != nil:
+		reminder.Type = ReminderTypeCustom
+		reminder.TriggerTime = *req.TriggerTime
+	default:
+		return nil, Invalid("укажите days_before или trigger_time")
+	}
+
+	if !reminder.TriggerTime.After(now) {
+		return nil, Invalid("время напоминания уже прошло (%s)", reminder.TriggerTime.Format("02.01.2006 15:04"))
+	}
+
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if err := rm.save(&reminder); err != nil {
+		return nil, err
+	}
+	logger.Info(context.Background(), "Напоминание создано", "reminderID", reminder.ID, "taskID", taskID, "trigger", reminder.TriggerTime)
+	return &reminder, nil
+}
+
+// GetReminders возвращает напоминания задачи пользователя по времени срабатывания
+func (rm *ReminderManager) GetReminders(userID, taskID int) ([]Reminder, error) {
+	if _, err := rm.tasks.GetTaskForUser(userID, taskID); err != nil {
+		return nil, err
+	}
+
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	return rm.taskReminders(userID, taskID)
+}
+
+// GetReminder возвращает напоминание пользователя
+func (rm *ReminderManager) GetReminder(userID, id int) (*Reminder, error) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	return rm.lookup(userID, id)
+}
+
+// CancelReminder отменяет напоминание, которое еще не отправлено
+func (rm *ReminderManager) CancelReminder(userID, id int) (*Reminder, error) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	reminder, err := rm.lookup(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := rm.setStatus(reminder, ReminderCancelled); err != nil {
+		return nil, err
+	}
+	return reminder, nil
+}
+
+// DeleteReminder удаляет напоминание пользователя
+func (rm *ReminderManager) DeleteReminder(userID, id int) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if rm.storage != nil {
+		return rm.storage.DeleteReminder(userID, id)
+	}
+	if _, err := rm.lookup(userID, id); err != nil {
+		return err
+	}
+	delete(rm.reminders, id)
+	return nil
+}
+
+// GetSettings возвращает настройки пользователя или значения по умолчанию
+func (rm *ReminderManager) GetSettings(userID int) (*ReminderSettings, error) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	return rm.settingsFor(userID)
+}
+
+// SaveSettings сохраняет настройки и пересчитывает автонапоминания по всем задачам пользователя
+func (rm *ReminderManager) SaveSettings(settings ReminderSettings) (*ReminderSettings, error) {
+	if settings.RemindBeforeDays < 0 || settings.RemindBeforeDays > 365 {
+		return nil, Invalid("напомнить можно за 0-365 дней до срока")
+	}
+
+	tasks, err := rm.tasks.GetAllTasksForUser(settings.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if rm.storage != nil {
+		if err := rm.storage.SaveReminderSettings(&settings); err != nil {
+			return nil, err
+		}
+	} else {
+		rm.settings[settings.UserID] = settings
+	}
+
+	for _, task := range tasks {
+		if err := rm.syncTask(task); err != nil {
+			return nil, err
+		}
+	}
+	return &settings, nil
+}
+
+// taskChanged вызывается TaskManager после изменения задачи
+func (rm *ReminderManager) taskChanged(task Task) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if err := rm.syncTask(task); err != nil {
+		logger.Error(context.Background(), err, "Ошибка обновления напоминаний задачи", "taskID", task.ID)
+	}
+}
+
+// taskAssigned вызывается TaskManager, когда задачу назначили исполнителю.
+// Уведомление уходит через ту же очередь, что и напоминания: его отправит
+// планировщик веб-сервера или бота с повторами при ошибках.
+func (rm *ReminderManager) taskAssigned(task Task) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	now := time.Now()
+	reminder := Reminder{
+		TaskID:      task.ID,
+		UserID:      task.AssigneeID,
+		Type:        ReminderTypeAssignment,
+		Message:     fmt.Sprintf("#%d %s", task.ID, task.Description),
+		TriggerTime: now,
+		Status:      ReminderPending,
+		Channel:     "telegram",
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := rm.save(&reminder); err != nil {
+		logger.Error(context.Background(), err, "Ошибка постановки уведомления о назначении", "taskID", task.ID)
+	}
+}
+
+// taskDeleted вызывается TaskManager после удаления задачи.
+// SQLite удаляет напоминания вместе с задачей, здесь чистим только память.
+func (rm *ReminderManager) taskDeleted(taskID int) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	for id, r := range rm.reminders {
+		if r.TaskID == taskID {
+			delete(rm.reminders, id)
+			delete(rm.claims, id)
+		}
+	}
+}
+
+// syncTask приводит автонапоминание задачи в соответствие с ее сроком и настройками.
+// Выполненная задача теряет все ожидающие напоминания. Вызывается под rm.mu.
+func (rm *ReminderManager) syncTask(task Task) error {
+	reminders, err := rm.taskReminders(task.UserID, task.ID)
+	if err != nil {
+		return err
+	}
+	settings, err := rm.settingsFor(task.UserID)
+	if err != nil {
+		return err
+	}
+
+	var want *time.Time
+	if settings.Enabled && !task.Completed && !task.DueDate.IsZero() {
+		trigger := reminderTriggerTime(task.DueDate, settings.RemindBeforeDays)
+		if trigger.After(time.Now()) {
+			want = &trigger
+		}
+	}
+
+	for i := range reminders {
+		r := &reminders[i]
+		if want != nil && r.Auto && r.TriggerTime.Equal(*want) && r.Status != ReminderCancelled {
+			// Такое автонапоминание уже есть (или уже отправлено)
+			want = nil
+			continue
+		}
+		waiting := r.Status == ReminderPending || r.Status == ReminderFailed
+		if waiting && (r.Auto || task.Completed) {
+			if err := rm.setStatus(r, ReminderCancelled); err != nil {
+				return err
+			}
+		}
+	}
+
+	if want == nil {
+		return nil
+	}
+	days := settings.RemindBeforeDays
+	now := time.Now()
+	reminder := Reminder{
+		TaskID:      task.ID,
+		UserID:      task.UserID,
+		Type:        ReminderTypeDeadline,
+		Message:     "Срок задачи: " + task.Description,
+		TriggerTime: *want,
+		DaysBefore:  &days,
+		Status:      ReminderPending,
+		Channel:     "telegram",
+		Auto:        true,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	return rm.save(&reminder)
+}
+
+// save добавляет новое напоминание; вызывается под rm.mu
+func (rm *ReminderManager) save(reminder *Reminder) error {
+	if rm.storage != nil {
+		id, err := rm.storage.CreateReminder(reminder)
+		if err != nil {
+			return err
+		}
+		reminder.ID = id
+	} else {
+		reminder.ID = rm.nextID
+		rm.reminders[reminder.ID] = *reminder
+		rm.nextID++
+	}
+	rm.signal()
+	return nil
+}
+
+// signal будит планировщик, не дожидаясь очередного опроса
+func (rm *ReminderManager) signal() {
+	select {
+	case rm.changed <- struct{}{}:
+	default:
+	}
+}
+
+// setStatus меняет статус с проверкой перехода; вызывается под rm.mu
+func (rm *ReminderManager) setStatus(reminder *Reminder, status ReminderStatus) error {
+	if !reminder.Status.CanTransition(status) {
+		return Conflict("нельзя перевести напоминание из %s в %s", reminder.Status, status)
+	}
+	reminder.Status = status
+	reminder.UpdatedAt = time.Now()
+	if rm.storage != nil {
+		return rm.storage.UpdateReminder(reminder)
+	}
+	rm.reminders[reminder.ID] = *reminder
+	return nil
+}
+
+// lookup ищет напоминание и проверяет владельца; вызывается под rm.mu
+func (rm *ReminderManager) lookup(userID, id int) (*Reminder, error) {
+	if rm.storage != nil {
+		return rm.storage.GetReminder(userID, id)
+	}
+	reminder, exists := rm.reminders[id]
+	if !exists {
+		return nil, NotFound("напоминание с ID %d не найдено", id)
+	}
+	if reminder.UserID != userID {
+		return nil, Forbidden("напоминание с ID %d принадлежит другому пользователю", id)
+	}
+	return &reminder, nil
+}
+
+// taskReminders возвращает напоминания задачи; вызывается под rm.mu
+func (rm *ReminderManager) taskReminders(userID, taskID int) ([]Reminder, error) {
+	if rm.storage != nil {
+		return rm.storage.GetTaskReminders(userID, taskID)
+	}
+	reminders := []Reminder{}
+	for _, r := range rm.reminders {
+		if r.TaskID == taskID && r.UserID == userID {
+			reminders = append(reminders, r)
+		}
+	}
+	sort.Slice(reminders, func(i, j int) bool {
+		return reminders[i].TriggerTime.Before(reminders[j].TriggerTime)
+	})
+	return reminders, nil
+}
+
+// settingsFor возвращает настройки пользователя; вызывается под rm.mu
+func (rm *ReminderManager) settingsFor(userID int) (*ReminderSettings, error) {
+	if rm.storage != nil {
+		settings, err := rm.storage.GetReminderSettings(userID)
+		if errors.Is(err, ErrNotFound) {
+			return DefaultReminderSettings(userID), nil
+		}
+		return settings, err
+	}
+	if settings, exists := rm.settings[userID]; exists {
+		return &settings, nil
+	}
+	return DefaultReminderSettings(userID), nil
+}
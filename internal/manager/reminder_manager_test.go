@@ -0,0 +1,142 @@
+package manager
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReminderStatusTransitions(t *testing.T) {
+	tests := []struct {
+		from, to ReminderStatus
+		want     bool
+	}{
+		{ReminderPending, ReminderSent, true},
+		{ReminderPending, ReminderFailed, true},
+		{ReminderPending, ReminderCancelled, true},
+		{ReminderFailed, ReminderPending, true},
+		{ReminderFailed, ReminderCancelled, true},
+		{ReminderSent, ReminderPending, false},
+		{ReminderCancelled, ReminderPending, false},
+		{ReminderPending, ReminderPending, false},
+	}
+	for _, tt := range tests {
+		if got := tt.from.CanTransition(tt.to); got != tt.want {
+			t.Errorf("%s -> %s: ожидалось %v, получено %v", tt.from, tt.to, tt.want, got)
+		}
+	}
+}
+
+func TestCreateReminder(t *testing.T) {
+	tm := NewTaskManager()
+	rm := NewReminderManager(tm)
+	rm.SaveSettings(ReminderSettings{UserID: 1, Enabled: false})
+
+	taskID, _ := tm.AddTaskForUser(1, "Сдать отчет", nil)
+	days := 2
+
+	if _, err := rm.CreateReminder(1, taskID, CreateReminderRequest{DaysBefore: &days, Message: "Скоро срок"}); err == nil {
+		t.Error("Ожидалась ошибка для задачи без срока")
+	}
+
+	due := time.Now().AddDate(0, 0, 10)
+	tm.UpdateTaskForUser(1, taskID, UpdateTaskRequest{DueDate: &due})
+
+	reminder, err := rm.CreateReminder(1, taskID, CreateReminderRequest{DaysBefore: &days, Message: "Скоро срок"})
+	if err != nil {
+		t.Fatalf("Ошибка создания напоминания: %v", err)
+	}
+	want := reminderTriggerTime(due, 2)
+	if !reminder.TriggerTime.Equal(want) || reminder.Status != ReminderPending {
+		t.Errorf("Ожидалось pending на %v, получено %s на %v", want, reminder.Status, reminder.TriggerTime)
+	}
+
+	past := time.Now().Add(-time.Hour)
+	if _, err := rm.CreateReminder(1, taskID, CreateReminderRequest{TriggerTime: &past, Message: "Поздно"}); err == nil {
+		t.Error("Ожидалась ошибка для времени в прошлом")
+	}
+	if _, err := rm.CreateReminder(1, taskID, CreateReminderRequest{Message: "Без времени"}); err == nil {
+		t.Error("Ожидалась ошибка без days_before и trigger_time")
+	}
+	if _, err := rm.CreateReminder(2, taskID, CreateReminderRequest{DaysBefore: &days, Message: "Чужая"}); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Ожидался ErrForbidden для чужой задачи, получено %v", err)
+	}
+
+	if _, err := rm.GetReminder(2, reminder.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Чужое напоминание не должно возвращаться, получено %v", err)
+	}
+	if _, err := rm.CancelReminder(1, reminder.ID); err != nil {
+		t.Fatalf("Ошибка отмены: %v", err)
+	}
+	if _, err := rm.CancelReminder(1, reminder.ID); !errors.Is(err, ErrConflict) {
+		t.Errorf("Повторная отмена: ожидался ErrConflict, получено %v", err)
+	}
+	if err := rm.DeleteReminder(1, reminder.ID); err != nil {
+		t.Fatalf("Ошибка удаления: %v", err)
+	}
+	if list, _ := rm.GetReminders(1, taskID); len(list) != 0 {
+		t.Errorf("После удаления ожидалось 0 напоминаний, получено %d", len(list))
+	}
+}
+
+func TestAutoReminders(t *testing.T) {
+	tm := NewTaskManager()
+	rm := NewReminderManager(tm)
+	taskID, _ := tm.AddTaskForUser(1, "Оплатить счета", nil)
+
+	pending := func() []Reminder {
+		list, _ := rm.GetReminders(1, taskID)
+		var result []Reminder
+		for _, r := range list {
+			if r.Auto && r.Status == ReminderPending {
+				result = append(result, r)
+			}
+		}
+		return result
+	}
+
+	// Настройки по умолчанию: за 1 день до срока
+	due := time.Now().AddDate(0, 0, 5)
+	tm.UpdateTaskForUser(1, taskID, UpdateTaskRequest{DueDate: &due})
+	auto := pending()
+	if len(auto) != 1 || !auto[0].TriggerTime.Equal(reminderTriggerTime(due, 1)) {
+		t.Fatalf("Ожидалось одно автонапоминание за день до срока, получено %+v", auto)
+	}
+
+	// Изменение описания не плодит дубликаты
+	desc := "Оплатить счета за свет"
+	tm.UpdateTaskForUser(1, taskID, UpdateTaskRequest{Description: &desc})
+	if len(pending()) != 1 {
+		t.Errorf("Ожидалось одно автонапоминание, получено %d", len(pending()))
+	}
+
+	// Перенос срока переносит напоминание
+	due = due.AddDate(0, 0, 3)
+	tm.UpdateTaskForUser(1, taskID, UpdateTaskRequest{DueDate: &due})
+	if auto := pending(); len(auto) != 1 || !auto[0].TriggerTime.Equal(reminderTriggerTime(due, 1)) {
+		t.Errorf("Напоминание должно переехать на новый срок, получено %+v", auto)
+	}
+
+	// Настройки применяются к существующим задачам
+	if _, err := rm.SaveSettings(ReminderSettings{UserID: 1, Enabled: true, RemindBeforeDays: 3}); err != nil {
+		t.Fatalf("Ошибка сохранения настроек: %v", err)
+	}
+	if auto := pending(); len(auto) != 1 || !auto[0].TriggerTime.Equal(reminderTriggerTime(due, 3)) {
+		t.Errorf("Ожидалось напоминание за 3 дня, получено %+v", auto)
+	}
+
+	// Выполнение отменяет, возврат в работу восстанавливает
+	tm.ToggleCompleteForUser(1, taskID)
+	if len(pending()) != 0 {
+		t.Error("У выполненной задачи не должно быть ожидающих напоминаний")
+	}
+	tm.ToggleCompleteForUser(1, taskID)
+	if len(pending()) != 1 {
+		t.Error("После возврата задачи в работу напоминание должно появиться снова")
+	}
+
+	tm.DeleteTaskForUser(1, taskID)
+	if len(rm.reminders) != 0 {
+		t.Errorf("Напоминания удаленной задачи должны быть удалены, осталось %d", len(rm.reminders))
+	}
+}
@@ -0,0 +1,316 @@
+package manager
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"todo-app/internal/logger"
+)
+
+// SortOrder - порядок задач в списке
+type SortOrder string
+
+const (
+	SortByDueDate  SortOrder = "due_date" // ближайший срок первым, задачи без срока в конце
+	SortByPriority SortOrder = "priority" // высокий приоритет первым, внутри - по сроку
+	SortByCreated  SortOrder = "created"  // новые первыми
+)
+
+func (s SortOrder) Valid() bool {
+	switch s {
+	case SortByDueDate, SortByPriority, SortByCreated:
+		return true
+	}
+	return false
+}
+
+// priorityRank - для сортировки: чем важнее, тем меньше
+var priorityRank = map[Priority]int{PriorityHigh: 0, PriorityMedium: 1, PriorityLow: 2}
+
+// SortTasks упорядочивает задачи; при равенстве - по ID
+func SortTasks(tasks []Task, order SortOrder) {
+	byDue := func(a, b Task) int {
+		switch {
+		case a.DueDate.IsZero() && b.DueDate.IsZero():
+			return 0
+		case a.DueDate.IsZero():
+			return 1
+		case b.DueDate.IsZero():
+			return -1
+		}
+		return a.DueDate.Compare(b.DueDate)
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		a, b := tasks[i], tasks[j]
+		c := 0
+		switch order {
+		case SortByPriority:
+			if c = priorityRank[a.Priority] - priorityRank[b.Priority]; c == 0 {
+				c = byDue(a, b)
+			}
+		case SortByCreated:
+			c = b.CreatedAt.Compare(a.CreatedAt)
+		default:
+			c = byDue(a, b)
+		}
+		if c != 0 {
+			return c < 0
+		}
+		return a.ID < b.ID
+	})
+}
+
+// SavedFilter - именованный набор условий расширенной фильтрации
+// с порядком сортировки (умный список)
+type SavedFilter struct {
+	ID        int           `json:"id"`
+	UserID    int           `json:"user_id"`
+	Name      string        `json:"name"`
+	Options   FilterOptions `json:"options"`
+	Sort      SortOrder     `json:"sort"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// SavedFilterRequest - создание или замена сохраненного фильтра.
+// Пустой Sort означает SortByDueDate.
+type SavedFilterRequest struct {
+	Name    string        `json:"name"`
+	Options FilterOptions `json:"options"`
+	Sort    SortOrder     `json:"sort,omitempty"`
+}
+
+type SavedFilterManager struct {
+	mu      sync.Mutex
+	filters map[int]SavedFilter
+	nextID  int
+	storage Storage
+	tasks   *TaskManager
+}
+
+func NewSavedFilterManager(tasks *TaskManager) *SavedFilterManager {
+	return &SavedFilterManager{
+		filters: make(map[int]SavedFilter),
+		nextID:  1,
+		tasks:   tasks,
+	}
+}
+
+func NewSavedFilterManagerWithStorage(storage Storage, tasks *TaskManager) *SavedFilterManager {
+	fm := NewSavedFilterManager(tasks)
+	fm.storage = storage
+	return fm
+}
+
+// filterNameKey - ключ сравнения имен фильтров, как name_key в saved_filters
+func filterNameKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// prepareFilter проверяет запрос и приводит его к сохраняемому виду
+func prepareFilter(req *SavedFilterRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return Invalid("название фильтра обязательно")
+	}
+	if len([]rune(req.Name)) > 100 {
+		return Invalid("название фильтра не может превышать 100 символов")
+	}
+	if req.Sort == "" {
+		req.Sort = SortByDueDate
+	}
+	if !req.Sort.Valid() {
+		return Invalid("сортировка: ожидается due_date, priority или created, получено %q", req.Sort)
+	}
+	if req.Options.Priority != nil && !req.Options.Priority.Valid() {
+		return Invalid("приоритет: ожидается low, medium или high")
+	}
+	req.Options.Tags = normalizeTags(req.Options.Tags)
+	req.Options.Query = strings.TrimSpace(req.Options.Query)
+	if _, err := ParseTaskQuery(req.Options.Query); err != nil {
+		return err
+	}
+	return nil
+}
+
+// CreateFilter сохраняет фильтр пользователя; имя должно быть свободно
+func (fm *SavedFilterManager) CreateFilter(userID int, req SavedFilterRequest) (*SavedFilter, error) {
+	if err := prepareFilter(&req); err != nil {
+		return nil, err
+	}
+
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	if err := fm.checkNameFree(userID, 0, req.Name); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	filter := SavedFilter{
+		UserID:    userID,
+		Name:      req.Name,
+		Options:   req.Options,
+		Sort:      req.Sort,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if fm.storage != nil {
+		id, err := fm.storage.CreateSavedFilter(&filter)
+		if err != nil {
+			return nil, err
+		}
+		filter.ID = id
+	} else {
+		filter.ID = fm.nextID
+		fm.nextID++
+		fm.filters[filter.ID] = filter
+	}
+	logger.Info(context.Background(), "Фильтр сохранен", "userID", userID, "filterID", filter.ID, "name", filter.Name)
+	return &filter, nil
+}
+
+// GetFilters возвращает фильтры пользователя по алфавиту
+func (fm *SavedFilterManager) GetFilters(userID int) ([]SavedFilter, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	return fm.userFilters(userID)
+}
+
+// GetFilter возвращает фильтр пользователя по ID
+func (fm *SavedFilterManager) GetFilter(userID, id int) (*SavedFilter, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	return fm.lookup(userID, id)
+}
+
+// FindFilter ищет фильтр пользователя по имени без учета регистра
+func (fm *SavedFilterManager) FindFilter(userID int, name string) (*SavedFilter, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	filters, err := fm.userFilters(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, filter := range filters {
+		if filterNameKey(filter.Name) == filterNameKey(name) {
+			return &filter, nil
+		}
+	}
+	return nil, NotFound("фильтр %q не найден", strings.TrimSpace(name))
+}
+
+// UpdateFilter заменяет название, условия и сортировку фильтра
+func (fm *SavedFilterManager) UpdateFilter(userID, id int, req SavedFilterRequest) (*SavedFilter, error) {
+	if err := prepareFilter(&req); err != nil {
+		return nil, err
+	}
+
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	filter, err := fm.lookup(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := fm.checkNameFree(userID, id, req.Name); err != nil {
+		return nil, err
+	}
+	filter.Name = req.Name
+	filter.Options = req.Options
+	filter.Sort = req.Sort
+	filter.UpdatedAt = time.Now()
+	if fm.storage != nil {
+		if err := fm.storage.UpdateSavedFilter(filter); err != nil {
+			return nil, err
+		}
+	} else {
+		fm.filters[id] = *filter
+	}
+	return filter, nil
+}
+
+// DeleteFilter удаляет фильтр пользователя
+func (fm *SavedFilterManager) DeleteFilter(userID, id int) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+
+	if fm.storage != nil {
+		return fm.storage.DeleteSavedFilter(userID, id)
+	}
+	if _, err := fm.lookup(userID, id); err != nil {
+		return err
+	}
+	delete(fm.filters, id)
+	return nil
+}
+
+// RunFilter возвращает фильтр и задачи пользователя, которые под него
+// подходят, в порядке сортировки фильтра
+func (fm *SavedFilterManager) RunFilter(userID, id int) (*SavedFilter, []Task, error) {
+	filter, err := fm.GetFilter(userID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	tasks, err := fm.tasks.FilterTasksAdvancedForUser(userID, filter.Options)
+	if err != nil {
+		return nil, nil, err
+	}
+	SortTasks(tasks, filter.Sort)
+	return filter, tasks, nil
+}
+
+// lookup ищет фильтр и проверяет владельца. Вызывающий должен удерживать fm.mu.
+func (fm *SavedFilterManager) lookup(userID, id int) (*SavedFilter, error) {
+	if fm.storage != nil {
+		return fm.storage.GetSavedFilter(userID, id)
+	}
+	filter, ok := fm.filters[id]
+	if !ok {
+		return nil, NotFound("фильтр с ID %d не найден", id)
+	}
+	if filter.UserID != userID {
+		return nil, Forbidden("фильтр с ID %d принадлежит другому пользователю", id)
+	}
+	return &filter, nil
+}
+
+// userFilters - фильтры пользователя по алфавиту. Вызывающий должен удерживать fm.mu.
+func (fm *SavedFilterManager) userFilters(userID int) ([]SavedFilter, error) {
+	if fm.storage != nil {
+		return fm.storage.GetSavedFilters(userID)
+	}
+	filters := []SavedFilter{}
+	for _, filter := range fm.filters {
+		if filter.UserID == userID {
+			filters = append(filters, filter)
+		}
+	}
+	sort.Slice(filters, func(i, j int) bool {
+		if filterNameKey(filters[i].Name) != filterNameKey(filters[j].Name) {
+			return filterNameKey(filters[i].Name) < filterNameKey(filters[j].Name)
+		}
+		return filters[i].ID < filters[j].ID
+	})
+	return filters, nil
+}
+
+// checkNameFree возвращает ErrConflict, если имя занято другим фильтром
+// пользователя. Вызывающий должен удерживать fm.mu.
+func (fm *SavedFilterManager) checkNameFree(userID, id int, name string) error {
+	filters, err := fm.userFilters(userID)
+	if err != nil {
+		return err
+	}
+	for _, filter := range filters {
+		if filter.ID != id && filterNameKey(filter.Name) == filterNameKey(name) {
+			return Conflict("фильтр %q уже существует", filter.Name)
+		}
+	}
+	return nil
+}
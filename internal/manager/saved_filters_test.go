@@ -0,0 +1,117 @@
+package manager
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSortTasks(t *testing.T) {
+	day := func(offset int) time.Time { return time.Date(2026, 10, 16+offset, 12, 0, 0, 0, time.Local) }
+	tasks := []Task{
+		{ID: 1, Priority: PriorityLow, DueDate: day(1), CreatedAt: day(-3)},
+		{ID: 2, Priority: PriorityHigh, CreatedAt: day(-1)},
+		{ID: 3, Priority: PriorityHigh, DueDate: day(5), CreatedAt: day(-2)},
+		{ID: 4, Priority: PriorityMedium, DueDate: day(1), CreatedAt: day(-1)},
+	}
+	tests := map[SortOrder][]int{
+		SortByDueDate:  {1, 4, 3, 2},
+		SortByPriority: {3, 2, 4, 1},
+		SortByCreated:  {2, 4, 3, 1},
+	}
+	for order, want := range tests {
+		SortTasks(tasks, order)
+		for i, task := range tasks {
+			if task.ID != want[i] {
+				t.Errorf("%s: порядок %v, ожидался %v", order, taskIDs(tasks), want)
+				break
+			}
+		}
+	}
+}
+
+func taskIDs(tasks []Task) []int {
+	ids := make([]int, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+	return ids
+}
+
+func TestSavedFilters(t *testing.T) {
+	tm := NewTaskManager()
+	fm := NewSavedFilterManager(tm)
+	high, low := PriorityHigh, PriorityLow
+	soon, later := time.Now().AddDate(0, 0, 2), time.Now().AddDate(0, 0, 5)
+
+	tm.CreateTaskForUser(1, "Отчет", []string{"работа"}, UpdateTaskRequest{Priority: &low, DueDate: &soon})
+	tm.CreateTaskForUser(1, "Презентация", []string{"работа"}, UpdateTaskRequest{Priority: &high, DueDate: &later})
+	tm.CreateTaskForUser(1, "Молоко", []string{"дом"}, UpdateTaskRequest{Priority: &high})
+
+	filter, err := fm.CreateFilter(1, SavedFilterRequest{
+		Name:    "  Этот спринт ",
+		Options: FilterOptions{Tags: []string{" Работа "}, Query: "due<+1w"},
+		Sort:    SortByPriority,
+	})
+	if err != nil {
+		t.Fatalf("Ошибка создания фильтра: %v", err)
+	}
+	if filter.Name != "Этот спринт" || filter.Options.Tags[0] != "Работа" {
+		t.Errorf("Фильтр не нормализован: %+v", filter)
+	}
+
+	_, tasks, err := fm.RunFilter(1, filter.ID)
+	if err != nil {
+		t.Fatalf("Ошибка применения фильтра: %v", err)
+	}
+	if ids := taskIDs(tasks); len(ids) != 2 || ids[0] != 2 || ids[1] != 1 {
+		t.Errorf("Ожидались задачи [2 1] по приоритету, получено %v", ids)
+	}
+
+	if _, err := fm.CreateFilter(1, SavedFilterRequest{Name: "ЭТОТ СПРИНТ"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("Имя без учета регистра уже занято: ожидался ErrConflict, получено %v", err)
+	}
+	if _, err := fm.CreateFilter(2, SavedFilterRequest{Name: "Этот спринт"}); err != nil {
+		t.Errorf("У другого пользователя имя свободно: %v", err)
+	}
+	for _, req := range []SavedFilterRequest{
+		{Name: " "},
+		{Name: "Сортировка", Sort: "alphabet"},
+		{Name: "Запрос", Options: FilterOptions{Query: "priority:urgent"}},
+	} {
+		if _, err := fm.CreateFilter(1, req); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%+v: ожидался ErrInvalid, получено %v", req, err)
+		}
+	}
+
+	if found, err := fm.FindFilter(1, "этот спринт"); err != nil || found.ID != filter.ID {
+		t.Errorf("Поиск по имени: %+v, %v", found, err)
+	}
+	if _, err := fm.GetFilter(2, filter.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Чужой фильтр: ожидался ErrForbidden, получено %v", err)
+	}
+
+	other, _ := fm.CreateFilter(1, SavedFilterRequest{Name: "Дом", Options: FilterOptions{Tags: []string{"дом"}}})
+	if _, err := fm.UpdateFilter(1, other.ID, SavedFilterRequest{Name: "этот спринт"}); !errors.Is(err, ErrConflict) {
+		t.Errorf("Переименование в занятое имя: ожидался ErrConflict, получено %v", err)
+	}
+	updated, err := fm.UpdateFilter(1, filter.ID, SavedFilterRequest{Name: "Спринт", Options: FilterOptions{Query: "tag:работа"}})
+	if err != nil || updated.Sort != SortByDueDate || updated.Options.Query != "tag:работа" {
+		t.Errorf("Обновление: %+v, %v", updated, err)
+	}
+
+	filters, _ := fm.GetFilters(1)
+	if len(filters) != 2 || filters[0].Name != "Дом" || filters[1].Name != "Спринт" {
+		t.Errorf("Ожидались фильтры по алфавиту, получено %+v", filters)
+	}
+
+	if err := fm.DeleteFilter(2, filter.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Удаление чужого фильтра: ожидался ErrForbidden, получено %v", err)
+	}
+	if err := fm.DeleteFilter(1, filter.ID); err != nil {
+		t.Fatalf("Ошибка удаления: %v", err)
+	}
+	if _, _, err := fm.RunFilter(1, filter.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Удаленный фильтр: ожидался ErrNotFound, получено %v", err)
+	}
+}
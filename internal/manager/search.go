@@ -0,0 +1,313 @@
+package manager
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Границы совпадений во фрагменте SearchResult.Snippet. Управляющие
+// символы не встречаются в описаниях, поэтому фрагмент можно безопасно
+// экранировать для HTML или Markdown и только потом выделить совпадения.
+const (
+	HighlightStart = "\x02"
+	HighlightEnd   = "\x03"
+)
+
+// SearchLimit - сколько результатов поиска возвращается за раз
+const SearchLimit = 50
+
+// SnippetWords - длина фрагмента результата поиска в словах
+const SnippetWords = 12
+
+// SearchResult - найденная задача и фрагмент описания или подзадач
+// с отмеченными совпадениями
+type SearchResult struct {
+	Task    Task   `json:"task"`
+	Snippet string `json:"snippet"`
+}
+
+// Highlight возвращает фрагмент, в котором текст обработан escape,
+// а совпадения обернуты в open и close
+func (r SearchResult) Highlight(open, close string, escape func(string) string) string {
+	var b strings.Builder
+	rest := r.Snippet
+	for rest != "" {
+		start := strings.Index(rest, HighlightStart)
+		if start < 0 {
+			b.WriteString(escape(rest))
+			break
+		}
+		b.WriteString(escape(rest[:start]))
+		rest = rest[start+len(HighlightStart):]
+		end := strings.Index(rest, HighlightEnd)
+		if end < 0 {
+			end = len(rest)
+		}
+		b.WriteString(open + escape(rest[:end]) + close)
+		rest = strings.TrimPrefix(rest[end:], HighlightEnd)
+	}
+	return b.String()
+}
+
+// SearchQuery - разобранный поисковый запрос. Все условия должны
+// выполняться одновременно.
+//
+//	отчет квартал   - оба слова в любом месте
+//	"годовой отчет" - слова подряд
+//	отч*            - слова, начинающиеся с "отч"
+type SearchQuery struct {
+	terms []searchTerm
+}
+
+// searchTerm - слово или фраза; prefix относится к последнему слову
+type searchTerm struct {
+	words  []string
+	prefix bool
+}
+
+// ParseSearchQuery разбирает запрос пользователя. Операторы FTS5 в запросе
+// не поддерживаются: все, кроме букв и цифр, разделяет слова, поэтому
+// любой ввод дает корректное выражение для MATCH.
+func ParseSearchQuery(query string) (SearchQuery, error) {
+	var q SearchQuery
+	rest := strings.TrimSpace(query)
+	for rest != "" {
+		var chunk string
+		if rest[0] == '"' {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				chunk, rest = rest[1:], ""
+			} else {
+				chunk, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			end := strings.IndexFunc(rest, func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+			if end < 0 {
+				end = len(rest)
+			}
+			chunk, rest = rest[:end], rest[end:]
+		}
+		prefix := strings.HasPrefix(rest, "*") || strings.HasSuffix(chunk, "*")
+		rest = strings.TrimLeft(rest, "* \t\n")
+
+		if words := searchWords(chunk); len(words) > 0 {
+			q.terms = append(q.terms, searchTerm{words: words, prefix: prefix})
+		}
+	}
+	if len(q.terms) == 0 {
+		return SearchQuery{}, Invalid("поисковый запрос не содержит слов")
+	}
+	return q, nil
+}
+
+// MatchExpression возвращает запрос в синтаксисе FTS5 MATCH
+func (q SearchQuery) MatchExpression() string {
+	parts := make([]string, len(q.terms))
+	for i, term := range q.terms {
+		parts[i] = `"` + strings.Join(term.words, " ") + `"`
+		if term.prefix {
+			parts[i] += "*"
+		}
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// searchWords делит текст на слова так же, как токенизатор unicode61
+func searchWords(text string) []string {
+	var words []string
+	for _, span := range wordSpans(text) {
+		words = append(words, strings.ToLower(text[span[0]:span[1]]))
+	}
+	return words
+}
+
+// wordSpans возвращает границы слов в байтах
+func wordSpans(text string) [][2]int {
+	var spans [][2]int
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(text)})
+	}
+	return spans
+}
+
+// matches отмечает слова текста, входящие в совпадения условия
+func (t searchTerm) matches(words []string, marked []bool) int {
+	n := 0
+	for i := 0; i+len(t.words) <= len(words); i++ {
+		ok := true
+		for j, want := range t.words {
+			got := words[i+j]
+			last := j == len(t.words)-1
+			if got != want && !(last && t.prefix && strings.HasPrefix(got, want)) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			n++
+			for j := range t.words {
+				marked[i+j] = true
+			}
+		}
+	}
+	return n
+}
+
+// match ищет запрос в описании и тексте подзадач. Возвращает оценку
+// (совпадения в описании весят вдвое больше, как в bm25 хранилища)
+// и фрагмент; ok == false, если какое-то условие не выполнено.
+func (q SearchQuery) match(description, subtasks string) (score int, snippet string, ok bool) {
+	texts := []string{description, subtasks}
+	weights := []int{2, 1}
+	hits := make([]int, len(texts))
+	marks := make([][]bool, len(texts))
+	spans := make([][][2]int, len(texts))
+	for i, text := range texts {
+		spans[i] = wordSpans(text)
+		marks[i] = make([]bool, len(spans[i]))
+	}
+
+	for _, term := range q.terms {
+		found := false
+		for i, text := range texts {
+			n := term.matches(searchWords(text), marks[i])
+			hits[i] += n
+			score += n * weights[i]
+			found = found || n > 0
+		}
+		if !found {
+			return 0, "", false
+		}
+	}
+
+	best := 0
+	if hits[1] > hits[0] {
+		best = 1
+	}
+	return score, buildSnippet(texts[best], spans[best], marks[best]), true
+}
+
+// buildSnippet вырезает из текста SnippetWords слов вокруг первого
+// совпадения и отмечает совпадения
+func buildSnippet(text string, spans [][2]int, marked []bool) string {
+	if len(spans) == 0 {
+		return ""
+	}
+	first := 0
+	for i, m := range marked {
+		if m {
+			first = i
+			break
+		}
+	}
+	from := first - 2
+	if from < 0 || len(spans) <= SnippetWords {
+		from = 0
+	}
+	to := from + SnippetWords
+	if to > len(spans) {
+		to = len(spans)
+		if from = to - SnippetWords; from < 0 {
+			from = 0
+		}
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	pos := spans[from][0]
+	if from == 0 {
+		pos = 0
+	}
+	for i := from; i < to; i++ {
+		b.WriteString(text[pos:spans[i][0]])
+		word := text[spans[i][0]:spans[i][1]]
+		if marked[i] {
+			word = HighlightStart + word + HighlightEnd
+		}
+		b.WriteString(word)
+		pos = spans[i][1]
+	}
+	if to < len(spans) {
+		b.WriteString("…")
+	} else {
+		b.WriteString(text[pos:])
+	}
+	return b.String()
+}
+
+// SearchForUser ищет задачи пользователя по тексту описания и подзадач,
+// дополнительно применяя options. Лучшие совпадения идут первыми.
+func (tm *TaskManager) SearchForUser(userID int, query string, options FilterOptions) ([]SearchResult, error) {
+	q, err := ParseSearchQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	match, err := taskFilter(options)
+	if err != nil {
+		return nil, err
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if tm.storage != nil {
+		return tm.storage.Search(userID, query, options)
+	}
+
+	subtasks := make(map[int][]SubTask)
+	if tm.subtasks != nil {
+		tm.subtasks.mu.Lock()
+		for _, subtask := range tm.subtasks.subtasks {
+			subtasks[subtask.TaskID] = append(subtasks[subtask.TaskID], subtask)
+		}
+		tm.subtasks.mu.Unlock()
+	}
+
+	type scored struct {
+		SearchResult
+		score int
+	}
+	visible := tm.visibleTasks(userID)
+	var found []scored
+	for _, task := range tm.tasks {
+		if !visible(task) || !match(task) {
+			continue
+		}
+		list := subtasks[task.ID]
+		sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+		descriptions := make([]string, len(list))
+		for i, subtask := range list {
+			descriptions[i] = subtask.Description
+		}
+		score, snippet, ok := q.match(task.Description, strings.Join(descriptions, "\n"))
+		if ok {
+			found = append(found, scored{SearchResult{Task: task, Snippet: snippet}, score})
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].score != found[j].score {
+			return found[i].score > found[j].score
+		}
+		return found[i].Task.ID < found[j].Task.ID
+	})
+	results := make([]SearchResult, 0, len(found))
+	for i := 0; i < len(found) && i < SearchLimit; i++ {
+		results = append(results, found[i].SearchResult)
+	}
+	return results, nil
+}
@@ -0,0 +1,85 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"testing"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := map[string]string{
+		"отчет":                `"отчет"`,
+		"Годовой  ОТЧЕТ":       `"годовой" AND "отчет"`,
+		"отч*":                 `"отч"*`,
+		`"годовой отчет" банк`: `"годовой отчет" AND "банк"`,
+		`"годовой отч"*`:       `"годовой отч"*`,
+		`NEAR(a b) OR c:d`:     `"near a" AND "b" AND "or" AND "c d"`,
+		`"незакрытая кавычка`:  `"незакрытая кавычка"`,
+		"e-mail":               `"e mail"`,
+	}
+	for query, want := range tests {
+		q, err := ParseSearchQuery(query)
+		if err != nil {
+			t.Errorf("%s: %v", query, err)
+			continue
+		}
+		if got := q.MatchExpression(); got != want {
+			t.Errorf("%s: получено %s, ожидалось %s", query, got, want)
+		}
+	}
+
+	for _, query := range []string{"", "  ", `""`, "* -"} {
+		if _, err := ParseSearchQuery(query); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%q: ожидалась ErrInvalid, получено %v", query, err)
+		}
+	}
+}
+
+func TestSearchInMemory(t *testing.T) {
+	tm := NewTaskManager()
+	stm := NewSubTaskManager()
+	tm.SetSubTaskManager(stm)
+
+	reportID, _ := tm.AddTaskForUser(1, "Годовой отчет для бухгалтерии", []string{"работа"})
+	callID, _ := tm.AddTaskForUser(1, "Позвонить в банк", nil)
+	stm.AddSubTask(1, callID, "Уточнить отчет по карте")
+	tm.AddTaskForUser(2, "Чужой отчет", nil)
+
+	search := func(query string, options FilterOptions) []SearchResult {
+		t.Helper()
+		results, err := tm.SearchForUser(1, query, options)
+		if err != nil {
+			t.Fatalf("%s: ошибка поиска: %v", query, err)
+		}
+		return results
+	}
+
+	results := search("отчет", FilterOptions{})
+	if len(results) != 2 || results[0].Task.ID != reportID || results[1].Task.ID != callID {
+		t.Fatalf("Совпадение в описании должно быть выше совпадения в подзадаче: %+v", results)
+	}
+	if got := results[1].Highlight("<b>", "</b>", html.EscapeString); got != "Уточнить <b>отчет</b> по карте" {
+		t.Errorf("Неожиданный фрагмент: %q", got)
+	}
+	if results := search("бухгалт*", FilterOptions{}); len(results) != 1 {
+		t.Errorf("Префиксный запрос: %+v", results)
+	}
+	if results := search(`"отчет годовой"`, FilterOptions{}); len(results) != 0 {
+		t.Errorf("Фраза должна совпадать только подряд: %+v", results)
+	}
+	completed := true
+	if results := search("отчет", FilterOptions{Completed: &completed}); len(results) != 0 {
+		t.Errorf("Фильтр не применен: %+v", results)
+	}
+}
+
+func TestSearchSnippet(t *testing.T) {
+	q, _ := ParseSearchQuery("цель")
+	long := "раз два три четыре пять шесть семь восемь девять десять одиннадцать цель двенадцать тринадцать четырнадцать"
+	_, snippet, ok := q.match(long, "")
+	want := fmt.Sprintf("…четыре пять шесть семь восемь девять десять одиннадцать %sцель%s двенадцать тринадцать четырнадцать", HighlightStart, HighlightEnd)
+	if !ok || snippet != want {
+		t.Errorf("Получено %q, ожидалось %q", snippet, want)
+	}
+}
@@ -0,0 +1,563 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"todo-app/internal/logger"
+)
+
+// SyncEntity - вид записи в ленте изменений
+type SyncEntity string
+
+const (
+	SyncTask    SyncEntity = "task"
+	SyncSubTask SyncEntity = "subtask"
+)
+
+const (
+	// DefaultSyncLimit - сколько изменений отдается за один запрос ленты
+	DefaultSyncLimit = 500
+	// MaxClientChanges ограничивает пакет изменений клиента
+	MaxClientChanges = 500
+)
+
+// SyncEntry - запись ленты пользователя о задаче или подзадаче: Seq -
+// номер ее последнего изменения в ленте, Deleted - пользователь ее больше
+// не видит. Номер выдается при записи изменения: в SQLite - триггерами
+// (миграция 021), в памяти - syncTask и syncSubTask.
+type SyncEntry struct {
+	Entity  SyncEntity
+	ID      int
+	Seq     int
+	Deleted bool
+}
+
+type syncKey struct {
+	entity SyncEntity
+	id     int
+}
+
+// Tombstone - задача или подзадача, которую пользователь больше не видит:
+// удалена, в корзине или он потерял доступ к проекту
+type Tombstone struct {
+	Entity SyncEntity `json:"entity"`
+	ID     int        `json:"id"`
+}
+
+// ChangeSet - изменения в ленте пользователя после номера since
+type ChangeSet struct {
+	Tasks    []Task      `json:"tasks"`
+	SubTasks []SubTask   `json:"subtasks"`
+	Deleted  []Tombstone `json:"deleted"`
+	Next     int         `json:"next"`     // since для следующего запроса
+	HasMore  bool        `json:"has_more"` // изменений больше limit, запросить еще раз с Next
+}
+
+// Changes возвращает изменения задач и подзадач, которые видит пользователь,
+// после номера since в его ленте, не больше limit. since = 0 - полный снимок
+// без удаленных. Номера изменений выдаются при записи, поэтому запрос
+// читает только записи ленты после since.
+func (tm *TaskManager) Changes(userID, since, limit int) (*ChangeSet, error) {
+	if since < 0 {
+		return nil, Invalid("since не может быть отрицательным")
+	}
+	if limit <= 0 {
+		limit = DefaultSyncLimit
+	}
+
+	if tm.storage == nil {
+		tm.mu.Lock()
+		defer tm.mu.Unlock()
+	}
+	entries, last, err := tm.syncEntries(userID, since, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	if since > last {
+		return nil, Conflict("изменения %d нет в ленте (последнее - %d): выполните полную синхронизацию с since=0", since, last)
+	}
+
+	set := &ChangeSet{Tasks: []Task{}, SubTasks: []SubTask{}, Deleted: []Tombstone{}, Next: last}
+	if len(entries) > limit {
+		entries = entries[:limit]
+		set.HasMore = true
+		set.Next = entries[limit-1].Seq
+	}
+	var taskIDs, subtaskIDs []int
+	for _, entry := range entries {
+		switch {
+		case entry.Deleted:
+			set.Deleted = append(set.Deleted, Tombstone{Entity: entry.Entity, ID: entry.ID})
+		case entry.Entity == SyncTask:
+			taskIDs = append(taskIDs, entry.ID)
+		default:
+			subtaskIDs = append(subtaskIDs, entry.ID)
+		}
+	}
+	tasks, subtasks, err := tm.syncItems(taskIDs, subtaskIDs)
+	if err != nil {
+		return nil, err
+	}
+	// Задачи и подзадачи - в порядке ленты. Той, что успели удалить после
+	// чтения ленты, нет: ее удаление придет следующим запросом.
+	taskByID := make(map[int]Task, len(tasks))
+	for _, task := range tasks {
+		taskByID[task.ID] = task
+	}
+	subtaskByID := make(map[int]SubTask, len(subtasks))
+	for _, subtask := range subtasks {
+		subtaskByID[subtask.ID] = subtask
+	}
+	for _, id := range taskIDs {
+		if task, exists := taskByID[id]; exists {
+			set.Tasks = append(set.Tasks, task)
+		}
+	}
+	for _, id := range subtaskIDs {
+		if subtask, exists := subtaskByID[id]; exists {
+			set.SubTasks = append(set.SubTasks, subtask)
+		}
+	}
+	return set, nil
+}
+
+// syncSnapshot возвращает задачи и подзадачи, которые видит пользователь
+// (см. ExportForUser). Вызывающий должен удерживать tm.mu.
+func (tm *TaskManager) syncSnapshot(userID int) ([]Task, []SubTask, error) {
+	if tm.storage != nil {
+		tasks, err := tm.storage.GetAllTasksForUser(userID)
+		if err != nil {
+			return nil, nil, err
+		}
+		subtasks, err := tm.storage.GetVisibleSubTasks(userID)
+		if err != nil {
+			return nil, nil, err
+		}
+		return tasks, subtasks, nil
+	}
+
+	visible := tm.visibleTasks(userID)
+	ids := make(map[int]bool)
+	var tasks []Task
+	for _, task := range tm.tasks {
+		if visible(task) {
+			tasks = append(tasks, task)
+			ids[task.ID] = true
+		}
+	}
+	var subtasks []SubTask
+	if tm.subtasks != nil {
+		tm.subtasks.mu.Lock()
+		for _, subtask := range tm.subtasks.subtasks {
+			if ids[subtask.TaskID] {
+				subtasks = append(subtasks, subtask)
+			}
+		}
+		tm.subtasks.mu.Unlock()
+	}
+	return tasks, subtasks, nil
+}
+
+// syncEntries возвращает номер последнего изменения в ленте пользователя и
+// не больше limit записей после since по порядку; с since = 0 - только не
+// удаленные. В памяти вызывающий должен удерживать tm.mu.
+func (tm *TaskManager) syncEntries(userID, since, limit int) ([]SyncEntry, int, error) {
+	if tm.storage != nil {
+		return tm.storage.GetSyncEntries(userID, since, limit)
+	}
+	entries := []SyncEntry{}
+	for _, entry := range tm.syncLog[userID] {
+		if entry.Seq > since && (since > 0 || !entry.Deleted) {
+			entries = append(entries, entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Seq < entries[j].Seq })
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, tm.syncSeq[userID], nil
+}
+
+// syncItems возвращает задачи не из корзины и подзадачи по ID из ленты.
+// В памяти вызывающий должен удерживать tm.mu.
+func (tm *TaskManager) syncItems(taskIDs, subtaskIDs []int) ([]Task, []SubTask, error) {
+	if tm.storage != nil {
+		return tm.storage.GetSyncItems(taskIDs, subtaskIDs)
+	}
+	var tasks []Task
+	for _, id := range taskIDs {
+		if task, exists := tm.tasks[id]; exists {
+			tasks = append(tasks, task)
+		}
+	}
+	var subtasks []SubTask
+	if tm.subtasks != nil && len(subtaskIDs) > 0 {
+		tm.subtasks.mu.Lock()
+		for _, id := range subtaskIDs {
+			if subtask, exists := tm.subtasks.subtasks[id]; exists {
+				subtasks = append(subtasks, subtask)
+			}
+		}
+		tm.subtasks.mu.Unlock()
+	}
+	return tasks, subtasks, nil
+}
+
+// syncTask записывает в ленты в памяти изменение задачи id: каждый, кто ее
+// видит, получает запись со следующим номером, а кто видел и потерял
+// доступ - удаление. Подзадачи попадают в ленты, только если изменилась
+// их видимость. Вызывается после изменения под tm.mu; в SQLite то же
+// делают триггеры.
+func (tm *TaskManager) syncTask(id int) {
+	tm.syncTaskEntries(id, true)
+}
+
+// syncProject записывает в ленты изменение видимости задач проекта
+// (участник добавлен или исключен). Вызывается под tm.mu.
+func (tm *TaskManager) syncProject(projectID int) {
+	var ids []int
+	for _, tasks := range []map[int]Task{tm.tasks, tm.trash} {
+		for id, task := range tasks {
+			if task.ProjectID == projectID {
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		tm.syncTaskEntries(id, false)
+	}
+}
+
+// syncTaskEntries - syncTask; без touch задача, как и ее подзадачи,
+// попадает только в ленты, где изменилась ее видимость
+func (tm *TaskManager) syncTaskEntries(id int, touch bool) {
+	if tm.storage != nil {
+		return
+	}
+	viewers, deleted := tm.taskViewers(id)
+	tm.syncEntity(syncKey{SyncTask, id}, viewers, deleted, touch)
+	if tm.subtasks == nil {
+		return
+	}
+	var ids []int
+	tm.subtasks.mu.Lock()
+	for _, subtask := range tm.subtasks.subtasks {
+		if subtask.TaskID == id {
+			ids = append(ids, subtask.ID)
+		}
+	}
+	tm.subtasks.mu.Unlock()
+	sort.Ints(ids)
+	for _, subtaskID := range ids {
+		tm.syncEntity(syncKey{SyncSubTask, subtaskID}, viewers, deleted, false)
+	}
+}
+
+// syncSubTask записывает в ленты в памяти изменение подзадачи id задачи
+// taskID; подзадачу видят те же, кто видит задачу. Вызывается под tm.mu.
+func (tm *TaskManager) syncSubTask(id, taskID int) {
+	if tm.storage != nil || tm.subtasks == nil {
+		return
+	}
+	tm.subtasks.mu.Lock()
+	_, exists := tm.subtasks.subtasks[id]
+	tm.subtasks.mu.Unlock()
+	var viewers map[int]bool
+	deleted := false
+	if exists {
+		viewers, deleted = tm.taskViewers(taskID)
+	}
+	tm.syncEntity(syncKey{SyncSubTask, id}, viewers, deleted, true)
+}
+
+// syncSubTask записывает изменение подзадачи в ленты в памяти (см.
+// TaskManager.syncSubTask). Не вызывать под stm.mu: берет tm.mu.
+func (stm *SubTaskManager) syncSubTask(id, taskID int) {
+	stm.mu.Lock()
+	tasks := stm.tasks
+	stm.mu.Unlock()
+	if tasks == nil {
+		return
+	}
+	tasks.mu.Lock()
+	defer tasks.mu.Unlock()
+	tasks.syncSubTask(id, taskID)
+}
+
+// taskViewers возвращает тех, кто видит задачу id (как visibleTasks), и
+// лежит ли она в корзине. Удаленную насовсем не видит никто.
+func (tm *TaskManager) taskViewers(id int) (map[int]bool, bool) {
+	task, exists := tm.tasks[id]
+	deleted := false
+	if !exists {
+		if task, exists = tm.trash[id]; !exists {
+			return nil, false
+		}
+		deleted = true
+	}
+	project, exists := tm.projects[task.ProjectID]
+	if !exists {
+		return map[int]bool{task.UserID: true}, deleted
+	}
+	viewers := map[int]bool{project.UserID: true}
+	for userID := range tm.members[project.ID] {
+		viewers[userID] = true
+	}
+	return viewers, deleted
+}
+
+// syncEntity добавляет запись о key в ленты viewers, а удаление - в ленты,
+// где key был, но пользователь его больше не видит. Без touch лента
+// viewer меняется, только если у нее другая видимость key.
+func (tm *TaskManager) syncEntity(key syncKey, viewers map[int]bool, deleted, touch bool) {
+	users := make([]int, 0, len(viewers))
+	for userID := range viewers {
+		users = append(users, userID)
+	}
+	sort.Ints(users)
+	for _, userID := range users {
+		entry, exists := tm.syncLog[userID][key]
+		if touch || (exists && entry.Deleted != deleted) || (!exists && !deleted) {
+			tm.appendSync(userID, key, deleted)
+		}
+	}
+	for userID, feed := range tm.syncLog {
+		if entry, exists := feed[key]; exists && !entry.Deleted && !viewers[userID] {
+			tm.appendSync(userID, key, true)
+		}
+	}
+}
+
+// appendSync присваивает записи о key следующий номер ленты пользователя
+func (tm *TaskManager) appendSync(userID int, key syncKey, deleted bool) {
+	feed := tm.syncLog[userID]
+	if feed == nil {
+		feed = make(map[syncKey]SyncEntry)
+		tm.syncLog[userID] = feed
+	}
+	tm.syncSeq[userID]++
+	feed[key] = SyncEntry{Entity: key.entity, ID: key.id, Seq: tm.syncSeq[userID], Deleted: deleted}
+}
+
+// ChangeOp - действие в пакете изменений клиента
+type ChangeOp string
+
+const (
+	OpCreateTask    ChangeOp = "create_task"
+	OpUpdateTask    ChangeOp = "update_task"
+	OpDeleteTask    ChangeOp = "delete_task"
+	OpCreateSubTask ChangeOp = "create_subtask"
+	OpUpdateSubTask ChangeOp = "update_subtask"
+	OpDeleteSubTask ChangeOp = "delete_subtask"
+)
+
+// ClientChange - изменение, сделанное клиентом без связи с сервером.
+// Новая задача или подзадача получает на клиенте временный ClientID,
+// подзадача новой задачи ссылается на нее через TaskClientID. Сервер
+// запоминает ClientID созданного, поэтому повтор пакета ничего не
+// создает заново.
+type ClientChange struct {
+	Op           ChangeOp          `json:"op"`
+	ClientID     string            `json:"client_id,omitempty"`
+	TaskID       int               `json:"task_id,omitempty"`
+	TaskClientID string            `json:"task_client_id,omitempty"`
+	SubTaskID    int               `json:"subtask_id,omitempty"`
+	Version      *int              `json:"version,omitempty"` // версия задачи, от которой шел клиент; без нее изменение безусловное
+	Changes      UpdateTaskRequest `json:"changes"`           // поля задачи; у подзадачи - description при создании и completed
+}
+
+// ChangeStatus - итог изменения клиента
+type ChangeStatus string
+
+const (
+	ChangeApplied  ChangeStatus = "applied"
+	ChangeConflict ChangeStatus = "conflict" // задачу изменили после версии клиента
+	ChangeRejected ChangeStatus = "rejected" // нет прав, не найдено или некорректно, см. Err
+)
+
+// ChangeResult - итог одного изменения из пакета. Task - задача после
+// изменения, а при конфликте - текущая задача на сервере.
+type ChangeResult struct {
+	Status    ChangeStatus `json:"status"`
+	ClientID  string       `json:"client_id,omitempty"`
+	TaskID    int          `json:"task_id,omitempty"`
+	SubTaskID int          `json:"subtask_id,omitempty"`
+	Task      *Task        `json:"task,omitempty"`
+	Err       error        `json:"-"`
+}
+
+// ApplyChanges применяет пакет изменений клиента по порядку. Каждое
+// изменение проверяется и сохраняется отдельно, как обычный запрос: ошибка
+// или конфликт одного не отменяет остальные. Подзадачи меняются через stm.
+// Создание с уже известным ClientID (клиент не получил ответ и прислал
+// пакет снова) ничего не создает и возвращает ID созданного в первый раз.
+func (tm *TaskManager) ApplyChanges(userID int, stm *SubTaskManager, changes []ClientChange) ([]ChangeResult, error) {
+	if len(changes) > MaxClientChanges {
+		return nil, Invalid("в пакете не больше %d изменений", MaxClientChanges)
+	}
+	// Повтор, пришедший одновременно с исходным пакетом, не должен
+	// разминуться с ним между проверкой ClientID и созданием
+	tm.pushMu.Lock()
+	defer tm.pushMu.Unlock()
+
+	results := make([]ChangeResult, 0, len(changes))
+	applied, conflicts := 0, 0
+	for _, change := range changes {
+		result := tm.applyChange(userID, stm, change)
+		var conflict *VersionConflictError
+		switch {
+		case result.Err == nil:
+			result.Status = ChangeApplied
+			applied++
+		case errors.As(result.Err, &conflict):
+			result.Status = ChangeConflict
+			result.Task = &conflict.Current
+			conflicts++
+		default:
+			result.Status = ChangeRejected
+		}
+		results = append(results, result)
+	}
+	logger.Info(context.Background(), "Применен пакет изменений клиента", "userID", userID,
+		"changes", len(changes), "applied", applied, "conflicts", conflicts)
+	return results, nil
+}
+
+func (tm *TaskManager) applyChange(userID int, stm *SubTaskManager, change ClientChange) ChangeResult {
+	result := ChangeResult{ClientID: change.ClientID, TaskID: change.TaskID, SubTaskID: change.SubTaskID}
+	req := change.Changes
+	req.Version = change.Version
+
+	switch change.Op {
+	case OpCreateTask:
+		id, found, err := tm.clientChange(userID, change.ClientID, SyncTask)
+		if err != nil || found {
+			result.TaskID, result.Err = id, err
+			if found {
+				// Задачу могли удалить после первого пакета - тогда без Task
+				result.Task, _ = tm.GetTaskForUser(userID, id)
+			}
+			return result
+		}
+		if req.Description == nil {
+			result.Err = Invalid("create_task: описание задачи обязательно")
+			return result
+		}
+		description := strings.TrimSpace(*req.Description)
+		var tags []string
+		if req.Tags != nil {
+			tags = *req.Tags
+		}
+		req.Description, req.Tags, req.Version = nil, nil, nil
+		task, err := tm.CreateTaskForUser(userID, description, tags, req)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+		result.TaskID, result.Task = task.ID, task
+		result.Err = tm.saveClientChange(userID, change.ClientID, SyncTask, task.ID)
+	case OpUpdateTask:
+		result.Task, result.Err = tm.UpdateTaskForUser(userID, change.TaskID, req)
+	case OpDeleteTask:
+		result.Err = tm.deleteTask(userID, change.TaskID, change.Version)
+	case OpCreateSubTask:
+		id, found, err := tm.clientChange(userID, change.ClientID, SyncSubTask)
+		if err != nil || found {
+			result.SubTaskID, result.Err = id, err
+			if found {
+				subtask, _, _ := stm.subTaskOwner(userID, id)
+				result.TaskID = subtask.TaskID
+			}
+			return result
+		}
+		taskID := change.TaskID
+		if change.TaskClientID != "" {
+			id, found, err := tm.clientChange(userID, change.TaskClientID, SyncTask)
+			if err == nil && !found {
+				err = Invalid("create_subtask: задача %q не создана", change.TaskClientID)
+			}
+			if err != nil {
+				result.Err = err
+				return result
+			}
+			taskID = id
+		}
+		if req.Description == nil {
+			result.Err = Invalid("create_subtask: описание подзадачи обязательно")
+			return result
+		}
+		result.TaskID = taskID
+		if result.SubTaskID, result.Err = stm.AddSubTask(userID, taskID, strings.TrimSpace(*req.Description)); result.Err == nil {
+			result.Err = tm.saveClientChange(userID, change.ClientID, SyncSubTask, result.SubTaskID)
+		}
+	case OpUpdateSubTask:
+		if req.Completed == nil || req.Description != nil {
+			result.Err = Invalid("update_subtask: у подзадачи меняется только completed")
+			return result
+		}
+		subtask, _, err := stm.subTaskOwner(userID, change.SubTaskID)
+		if err == nil && subtask.Completed != *req.Completed {
+			err = stm.ToggleSubTask(userID, change.SubTaskID)
+		}
+		result.TaskID, result.Err = subtask.TaskID, err
+	case OpDeleteSubTask:
+		result.Err = stm.DeleteSubTask(userID, change.SubTaskID)
+	default:
+		result.Err = Invalid("неизвестное действие %q", change.Op)
+	}
+	return result
+}
+
+// clientChange возвращает ID задачи или подзадачи (entity), созданной
+// раньше изменением клиента clientID; found = false - такого не было.
+// ClientID, занятый другим видом записей, - ошибка клиента.
+func (tm *TaskManager) clientChange(userID int, clientID string, entity SyncEntity) (int, bool, error) {
+	if clientID == "" {
+		return 0, false, nil
+	}
+	var key syncKey
+	if tm.storage != nil {
+		var err error
+		key.entity, key.id, err = tm.storage.GetClientChange(userID, clientID)
+		if errors.Is(err, ErrNotFound) {
+			return 0, false, nil
+		}
+		if err != nil {
+			return 0, false, err
+		}
+	} else {
+		tm.mu.Lock()
+		var found bool
+		key, found = tm.clientChanges[userID][clientID]
+		tm.mu.Unlock()
+		if !found {
+			return 0, false, nil
+		}
+	}
+	if key.entity != entity {
+		return 0, false, Invalid("client_id %q уже использован для %s", clientID, key.entity)
+	}
+	return key.id, true, nil
+}
+
+// saveClientChange запоминает, что изменение клиента clientID создало
+// entity с ID id (см. clientChange)
+func (tm *TaskManager) saveClientChange(userID int, clientID string, entity SyncEntity, id int) error {
+	if clientID == "" {
+		return nil
+	}
+	if tm.storage != nil {
+		return tm.storage.SaveClientChange(userID, clientID, entity, id)
+	}
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	if tm.clientChanges[userID] == nil {
+		tm.clientChanges[userID] = make(map[string]syncKey)
+	}
+	tm.clientChanges[userID][clientID] = syncKey{entity, id}
+	return nil
+}
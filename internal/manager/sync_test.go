@@ -0,0 +1,157 @@
+package manager
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestChanges(t *testing.T) {
+	tm := NewTaskManager()
+	stm := NewSubTaskManager()
+	tm.SetSubTaskManager(stm)
+	alice := User{ID: 1, Username: "alice"}
+	bob := User{ID: 2, Username: "bob"}
+
+	family, _ := tm.CreateProjectForUser(alice.ID, CreateProjectRequest{Name: "Семья"})
+	tm.ShareProjectForUser(alice.ID, family.ID, bob, RoleEditor)
+	task, _ := tm.CreateTaskForUser(alice.ID, "Купить продукты", nil, UpdateTaskRequest{ProjectID: &family.ID})
+	tm.AddTaskForUser(alice.ID, "Личное", nil)
+	subtaskID, _ := stm.AddSubTask(alice.ID, task.ID, "Молоко")
+
+	// Первый запрос - снимок того, что видит пользователь
+	set, err := tm.Changes(bob.ID, 0, 0)
+	if err != nil {
+		t.Fatalf("Снимок: %v", err)
+	}
+	if len(set.Tasks) != 1 || set.Tasks[0].ID != task.ID || len(set.SubTasks) != 1 || len(set.Deleted) != 0 || set.Next == 0 {
+		t.Fatalf("Снимок: %+v", set)
+	}
+	cursor := set.Next
+	if set, _ := tm.Changes(bob.ID, cursor, 0); len(set.Tasks)+len(set.SubTasks)+len(set.Deleted) != 0 || set.Next != cursor {
+		t.Errorf("Без изменений: %+v", set)
+	}
+
+	description := "Купить продукты и хлеб"
+	tm.UpdateTaskForUser(alice.ID, task.ID, UpdateTaskRequest{Description: &description})
+	set, _ = tm.Changes(bob.ID, cursor, 0)
+	if len(set.Tasks) != 1 || set.Tasks[0].Description != description || len(set.SubTasks) != 0 || set.Next <= cursor {
+		t.Errorf("Изменение задачи: %+v", set)
+	}
+	cursor = set.Next
+
+	// Удаленная подзадача и задача в корзине приходят как удаленные
+	stm.DeleteSubTask(alice.ID, subtaskID)
+	tm.DeleteTaskForUser(alice.ID, task.ID)
+	set, _ = tm.Changes(bob.ID, cursor, 0)
+	if len(set.Tasks) != 0 || len(set.Deleted) != 2 ||
+		set.Deleted[0] != (Tombstone{SyncSubTask, subtaskID}) || set.Deleted[1] != (Tombstone{SyncTask, task.ID}) {
+		t.Errorf("Удаление: %+v", set)
+	}
+	if set, _ := tm.Changes(bob.ID, 0, 0); len(set.Tasks) != 0 || len(set.Deleted) != 0 {
+		t.Errorf("Снимок после удаления: %+v", set)
+	}
+	if _, err := tm.Changes(bob.ID, set.Next+1, 0); !errors.Is(err, ErrConflict) {
+		t.Errorf("Номер за концом ленты: ожидался ErrConflict, получено %v", err)
+	}
+
+	// У каждого пользователя своя лента; снимок можно забирать страницами
+	tm.AddTaskForUser(alice.ID, "Еще одна", nil)
+	set, _ = tm.Changes(alice.ID, 0, 1)
+	if len(set.Tasks) != 1 || !set.HasMore {
+		t.Fatalf("Первая страница: %+v", set)
+	}
+	tasks := len(set.Tasks)
+	for pages := 1; set.HasMore && pages < 10; pages++ {
+		set, _ = tm.Changes(alice.ID, set.Next, 1)
+		tasks += len(set.Tasks)
+	}
+	if tasks != 2 || set.HasMore {
+		t.Errorf("Страницы ленты: задач %d, последняя страница %+v", tasks, set)
+	}
+}
+
+func TestApplyChanges(t *testing.T) {
+	tm := NewTaskManager()
+	stm := NewSubTaskManager()
+	tm.SetSubTaskManager(stm)
+	alice := User{ID: 1, Username: "alice"}
+	bob := User{ID: 2, Username: "bob"}
+
+	task, _ := tm.CreateTaskForUser(alice.ID, "Купить продукты", nil, UpdateTaskRequest{})
+	stale := task.Version
+	description := "Купить хлеб"
+	tm.UpdateTaskForUser(alice.ID, task.ID, UpdateTaskRequest{Description: &description})
+
+	offline := "Позвонить маме"
+	done := true
+	results, err := tm.ApplyChanges(alice.ID, stm, []ClientChange{
+		{Op: OpCreateTask, ClientID: "c1", Changes: UpdateTaskRequest{Description: &offline}},
+		{Op: OpCreateSubTask, ClientID: "c2", TaskClientID: "c1", Changes: UpdateTaskRequest{Description: &description}},
+		{Op: OpUpdateTask, TaskID: task.ID, Version: &stale, Changes: UpdateTaskRequest{Completed: &done}},
+		{Op: OpDeleteTask, TaskID: task.ID, Version: &stale},
+		{Op: OpCreateSubTask, TaskClientID: "c9", Changes: UpdateTaskRequest{Description: &description}},
+		{Op: "rename"},
+	})
+	if err != nil || len(results) != 6 {
+		t.Fatalf("Пакет: %+v, %v", results, err)
+	}
+	created := results[0]
+	if created.Status != ChangeApplied || created.ClientID != "c1" || created.Task == nil || created.Task.Description != offline {
+		t.Errorf("Создание задачи: %+v", created)
+	}
+	if sub := results[1]; sub.Status != ChangeApplied || sub.TaskID != created.TaskID || sub.SubTaskID == 0 {
+		t.Errorf("Подзадача новой задачи: %+v", sub)
+	}
+	for _, i := range []int{2, 3} {
+		if r := results[i]; r.Status != ChangeConflict || r.Task == nil || r.Task.Description != description {
+			t.Errorf("Изменение %d по старой версии: %+v", i, r)
+		}
+	}
+	for _, i := range []int{4, 5} {
+		if r := results[i]; r.Status != ChangeRejected || !errors.Is(r.Err, ErrInvalid) {
+			t.Errorf("Изменение %d: ожидался отказ, получено %+v", i, r)
+		}
+	}
+	if current, _ := tm.GetTaskForUser(alice.ID, task.ID); current.Completed {
+		t.Error("Изменение по старой версии применено")
+	}
+
+	// Повтор пакета (клиент не получил ответ) не создает задачу и подзадачу
+	// заново и возвращает их прежние ID
+	before, _ := tm.GetAllTasksForUser(alice.ID)
+	replay, _ := tm.ApplyChanges(alice.ID, stm, []ClientChange{
+		{Op: OpCreateTask, ClientID: "c1", Changes: UpdateTaskRequest{Description: &offline}},
+		{Op: OpCreateSubTask, ClientID: "c2", TaskClientID: "c1", Changes: UpdateTaskRequest{Description: &description}},
+	})
+	if r := replay[0]; r.Status != ChangeApplied || r.TaskID != created.TaskID || r.Task == nil {
+		t.Errorf("Повтор создания задачи: %+v", r)
+	}
+	if r := replay[1]; r.Status != ChangeApplied || r.SubTaskID != results[1].SubTaskID || r.TaskID != created.TaskID {
+		t.Errorf("Повтор создания подзадачи: %+v", r)
+	}
+	if after, _ := tm.GetAllTasksForUser(alice.ID); len(after) != len(before) {
+		t.Errorf("Повтор пакета создал задачи: было %d, стало %d", len(before), len(after))
+	}
+	if subtasks, _ := stm.GetSubTasks(alice.ID, created.TaskID); len(subtasks) != 1 {
+		t.Errorf("Повтор пакета создал подзадачи: %d", len(subtasks))
+	}
+	reused, _ := tm.ApplyChanges(alice.ID, stm, []ClientChange{
+		{Op: OpCreateSubTask, ClientID: "c1", TaskID: created.TaskID, Changes: UpdateTaskRequest{Description: &description}},
+	})
+	if r := reused[0]; r.Status != ChangeRejected || !errors.Is(r.Err, ErrInvalid) {
+		t.Errorf("client_id задачи для подзадачи: %+v", r)
+	}
+
+	// Чужие задачи пакет не меняет
+	results, _ = tm.ApplyChanges(bob.ID, stm, []ClientChange{{Op: OpDeleteTask, TaskID: task.ID}})
+	if results[0].Status != ChangeRejected || !errors.Is(results[0].Err, ErrForbidden) {
+		t.Errorf("Удаление чужой задачи: %+v", results[0])
+	}
+
+	results, _ = tm.ApplyChanges(alice.ID, stm, []ClientChange{
+		{Op: OpUpdateSubTask, SubTaskID: 99, Changes: UpdateTaskRequest{Completed: &done}},
+	})
+	if results[0].Status != ChangeRejected {
+		t.Errorf("Несуществующая подзадача: %+v", results[0])
+	}
+}
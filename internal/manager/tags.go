@@ -0,0 +1,263 @@
+package manager
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"todo-app/internal/logger"
+)
+
+// TagCount - тег пользователя и число задач с ним
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// tagKey - ключ сравнения тегов: теги, отличающиеся регистром
+// и пробелами по краям, считаются одним тегом (см. normalizeTags)
+func tagKey(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// canonicalTags приводит теги к написанию, которое уже используется
+// в задачах пользователя. Так же ведет себя SQLite: у пользователя одна
+// строка tags на ключ. Вызывающий должен удерживать tm.mu.
+func (tm *TaskManager) canonicalTags(userID int, tags []string) []string {
+	if len(tags) == 0 {
+		return tags
+	}
+	spelling := tm.tagSpellings(userID)
+	result := make([]string, len(tags))
+	for i, tag := range tags {
+		if known, ok := spelling[tagKey(tag)]; ok {
+			tag = known
+		}
+		result[i] = tag
+	}
+	return result
+}
+
+// tagSpellings возвращает написание каждого тега пользователя по ключу.
+// Задачи обходятся по возрастанию ID, чтобы выбор был детерминированным.
+// Вызывающий должен удерживать tm.mu.
+func (tm *TaskManager) tagSpellings(userID int) map[string]string {
+	spelling := make(map[string]string)
+	for _, task := range tm.userTasksByID(userID) {
+		for _, tag := range task.Tags {
+			if _, ok := spelling[tagKey(tag)]; !ok {
+				spelling[tagKey(tag)] = tag
+			}
+		}
+	}
+	return spelling
+}
+
+// userTasksByID возвращает задачи пользователя из памяти по возрастанию ID.
+// Вызывающий должен удерживать tm.mu.
+func (tm *TaskManager) userTasksByID(userID int) []Task {
+	var tasks []Task
+	for _, task := range tm.tasks {
+		if task.UserID == userID {
+			tasks = append(tasks, task)
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+	return tasks
+}
+
+// GetTagsForUser возвращает теги пользователя с числом задач,
+// самые частые первыми
+func (tm *TaskManager) GetTagsForUser(userID int) ([]TagCount, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if tm.storage != nil {
+		return tm.storage.GetTags(userID)
+	}
+
+	spelling := tm.tagSpellings(userID)
+	counts := make(map[string]int)
+	for _, task := range tm.tasks {
+		if task.UserID != userID {
+			continue
+		}
+		for _, tag := range task.Tags {
+			counts[tagKey(tag)]++
+		}
+	}
+
+	tags := make([]TagCount, 0, len(counts))
+	for key, count := range counts {
+		tags = append(tags, TagCount{Name: spelling[key], Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tagKey(tags[i].Name) < tagKey(tags[j].Name)
+	})
+	return tags, nil
+}
+
+// RenameTagForUser переименовывает тег во всех задачах пользователя
+// и возвращает число затронутых задач. Если новое имя уже занято другим
+// тегом, возвращается ErrConflict: такие теги объединяет MergeTagsForUser.
+func (tm *TaskManager) RenameTagForUser(userID int, name, newName string) (int, error) {
+	newName = strings.TrimSpace(newName)
+	if newName == "" {
+		return 0, Invalid("новое имя тега не может быть пустым")
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if tm.storage != nil {
+		n, err := tm.storage.RenameTag(userID, name, newName)
+		if err != nil {
+			return 0, err
+		}
+		logger.Info(context.Background(), "Тег переименован в хранилище", "userID", userID, "tag", name, "newName", newName, "tasks", n)
+		return n, nil
+	}
+
+	spelling := tm.tagSpellings(userID)
+	if _, ok := spelling[tagKey(name)]; !ok {
+		return 0, NotFound("тег %q не найден", strings.TrimSpace(name))
+	}
+	if _, ok := spelling[tagKey(newName)]; ok && tagKey(newName) != tagKey(name) {
+		return 0, Conflict("тег %q уже существует", newName)
+	}
+
+	n := tm.rewriteTags(userID, func(tags []string) ([]string, bool) {
+		changed := false
+		result := make([]string, len(tags))
+		for i, tag := range tags {
+			if tagKey(tag) == tagKey(name) {
+				tag, changed = newName, true
+			}
+			result[i] = tag
+		}
+		return result, changed
+	})
+	logger.Info(context.Background(), "Тег переименован", "userID", userID, "tag", name, "newName", newName, "tasks", n)
+	return n, nil
+}
+
+// MergeTagsForUser заменяет теги sources тегом target во всех задачах
+// пользователя и возвращает число задач, у которых был хотя бы один из
+// sources. Объединенный тег встает на место первого из них в задаче.
+func (tm *TaskManager) MergeTagsForUser(userID int, sources []string, target string) (int, error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return 0, Invalid("имя итогового тега не может быть пустым")
+	}
+	if len(normalizeTags(sources)) == 0 {
+		return 0, Invalid("укажите теги для объединения")
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if tm.storage != nil {
+		n, err := tm.storage.MergeTags(userID, sources, target)
+		if err != nil {
+			return 0, err
+		}
+		logger.Info(context.Background(), "Теги объединены в хранилище", "userID", userID, "sources", sources, "target", target, "tasks", n)
+		return n, nil
+	}
+
+	spelling := tm.tagSpellings(userID)
+	merged := make(map[string]bool)
+	for _, source := range sources {
+		if tagKey(source) == tagKey(target) {
+			continue
+		}
+		if _, ok := spelling[tagKey(source)]; !ok {
+			return 0, NotFound("тег %q не найден", strings.TrimSpace(source))
+		}
+		merged[tagKey(source)] = true
+	}
+
+	n := tm.rewriteTags(userID, func(tags []string) ([]string, bool) {
+		hasTarget, hadSource := false, false
+		for _, tag := range tags {
+			hasTarget = hasTarget || tagKey(tag) == tagKey(target)
+		}
+		result := make([]string, 0, len(tags))
+		for _, tag := range tags {
+			switch {
+			case merged[tagKey(tag)]:
+				hadSource = true
+				if !hasTarget {
+					result = append(result, target)
+					hasTarget = true
+				}
+			case tagKey(tag) == tagKey(target):
+				result = append(result, target)
+			default:
+				result = append(result, tag)
+			}
+		}
+		return result, hadSource
+	})
+	logger.Info(context.Background(), "Теги объединены", "userID", userID, "sources", sources, "target", target, "tasks", n)
+	return n, nil
+}
+
+// DeleteTagForUser снимает тег со всех задач пользователя и возвращает их число
+func (tm *TaskManager) DeleteTagForUser(userID int, name string) (int, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if tm.storage != nil {
+		n, err := tm.storage.DeleteTag(userID, name)
+		if err != nil {
+			return 0, err
+		}
+		logger.Info(context.Background(), "Тег удален из хранилища", "userID", userID, "tag", name, "tasks", n)
+		return n, nil
+	}
+
+	n := tm.rewriteTags(userID, func(tags []string) ([]string, bool) {
+		result := make([]string, 0, len(tags))
+		for _, tag := range tags {
+			if tagKey(tag) != tagKey(name) {
+				result = append(result, tag)
+			}
+		}
+		return result, len(result) != len(tags)
+	})
+	if n == 0 {
+		return 0, NotFound("тег %q не найден", strings.TrimSpace(name))
+	}
+	logger.Info(context.Background(), "Тег удален", "userID", userID, "tag", name, "tasks", n)
+	return n, nil
+}
+
+// rewriteTags применяет rewrite к тегам каждой задачи пользователя
+// и сохраняет задачи, для которых rewrite сообщил об изменении.
+// Написание тега общее для всех задач, поэтому rewrite может поменять
+// и задачи, которые не попадут в счетчик. Вызывающий должен удерживать tm.mu.
+func (tm *TaskManager) rewriteTags(userID int, rewrite func([]string) ([]string, bool)) int {
+	n := 0
+	now := time.Now()
+	for _, task := range tm.userTasksByID(userID) {
+		tags, counted := rewrite(task.Tags)
+		if counted {
+			n++
+			task.UpdatedAt = now
+			task.Version++
+		}
+		changed := counted || !slices.Equal(tags, task.Tags)
+		task.Tags = tags
+		tm.tasks[task.ID] = task
+		if changed {
+			tm.syncTask(task.ID)
+		}
+	}
+	return n
+}
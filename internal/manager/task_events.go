@@ -0,0 +1,177 @@
+package manager
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"todo-app/internal/logger"
+)
+
+// Source - откуда пришло изменение задачи
+type Source string
+
+const (
+	SourceWeb      Source = "web"
+	SourceAPI      Source = "api"
+	SourceTelegram Source = "telegram"
+	SourceImport   Source = "import" // задача создана импортом выгрузки, см. export.go
+)
+
+// EventType - вид события в истории задачи
+type EventType string
+
+const (
+	EventCreated        EventType = "created"
+	EventUpdated        EventType = "updated"
+	EventToggled        EventType = "toggled"
+	EventDeleted        EventType = "deleted"
+	EventRestored       EventType = "restored"
+	EventSubTaskAdded   EventType = "subtask_added"
+	EventSubTaskToggled EventType = "subtask_toggled"
+	EventSubTaskDeleted EventType = "subtask_deleted"
+)
+
+// FieldChange - изменение одного поля. Поля называются как в
+// UpdateTaskRequest, значения записаны строками: сроки в RFC 3339,
+// теги через запятую, пустая строка - значения не было.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// TaskEvent - запись в истории задачи. История только дописывается:
+// события не меняются и не удаляются вместе с задачей.
+type TaskEvent struct {
+	ID        int           `json:"id"`
+	TaskID    int           `json:"task_id"`
+	UserID    int           `json:"user_id"`            // Кто изменил
+	Username  string        `json:"username,omitempty"` // Имя на момент чтения
+	Type      EventType     `json:"type"`
+	Source    Source        `json:"source"`
+	SubTaskID int           `json:"subtask_id,omitempty"`
+	Changes   []FieldChange `json:"changes"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// WithSource возвращает TaskManager с общим состоянием, изменения через
+// который записываются в историю с источником source. Без вызова - web.
+func (tm *TaskManager) WithSource(source Source) *TaskManager {
+	return &TaskManager{taskState: tm.taskState, source: source}
+}
+
+// WithSource - то же для подзадач
+func (stm *SubTaskManager) WithSource(source Source) *SubTaskManager {
+	return &SubTaskManager{subTaskState: stm.subTaskState, source: source}
+}
+
+// taskChanges сравнивает поля задачи, которые меняет UpdateTaskRequest.
+// Для новой задачи before - нулевая Task.
+func taskChanges(before, after Task) []FieldChange {
+	changes := []FieldChange{}
+	add := func(field, oldValue, newValue string) {
+		if oldValue != newValue {
+			changes = append(changes, FieldChange{Field: field, Old: oldValue, New: newValue})
+		}
+	}
+	add("description", before.Description, after.Description)
+	add("completed", boolField(before.Completed), boolField(after.Completed))
+	add("priority", string(before.Priority), string(after.Priority))
+	add("due_date", timeField(before.DueDate), timeField(after.DueDate))
+	add("tags", strings.Join(before.Tags, ", "), strings.Join(after.Tags, ", "))
+	add("recurrence", before.Recurrence, after.Recurrence)
+	add("project_id", intField(before.ProjectID), intField(after.ProjectID))
+	add("assignee_id", intField(before.AssigneeID), intField(after.AssigneeID))
+	return changes
+}
+
+func boolField(v bool) string {
+	if !v {
+		return ""
+	}
+	return "true"
+}
+
+func timeField(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func intField(v int) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.Itoa(v)
+}
+
+// newEvent - событие задачи от имени userID с источником этого TaskManager
+func (tm *TaskManager) newEvent(userID, taskID int, eventType EventType, changes []FieldChange) TaskEvent {
+	return TaskEvent{TaskID: taskID, UserID: userID, Type: eventType, Source: tm.source, Changes: changes}
+}
+
+// recordEvent дописывает событие в историю. Изменение задачи к этому
+// моменту уже сохранено, поэтому ошибка записи только логируется.
+// Правка без изменений полей не записывается. Вызывается под tm.mu.
+func (tm *TaskManager) recordEvent(event TaskEvent) {
+	if event.Type == EventUpdated && len(event.Changes) == 0 {
+		return
+	}
+	if event.Changes == nil {
+		event.Changes = []FieldChange{}
+	}
+	event.CreatedAt = time.Now()
+	if tm.storage != nil {
+		if _, err := tm.storage.AddTaskEvent(&event); err != nil {
+			logger.Error(context.Background(), err, "Ошибка записи истории задачи", "taskID", event.TaskID, "type", event.Type)
+		}
+		return
+	}
+	event.ID = tm.nextEventID
+	tm.nextEventID++
+	tm.events = append(tm.events, event)
+}
+
+// recordEvent записывает событие подзадачи в историю ее задачи. Без связи
+// с задачами (см. SetSubTaskManager) история подзадач не ведется.
+// Не вызывать под stm.mu: запись берет tm.mu.
+func (stm *SubTaskManager) recordEvent(userID int, subtask SubTask, eventType EventType, changes []FieldChange) {
+	stm.mu.Lock()
+	tasks := stm.tasks
+	stm.mu.Unlock()
+	if tasks == nil {
+		return
+	}
+	event := TaskEvent{TaskID: subtask.TaskID, UserID: userID, Type: eventType, Source: stm.source,
+		SubTaskID: subtask.ID, Changes: changes}
+	tasks.mu.Lock()
+	defer tasks.mu.Unlock()
+	tasks.recordEvent(event)
+}
+
+// GetTaskEvents возвращает историю задачи, новые события первыми
+func (tm *TaskManager) GetTaskEvents(userID, taskID int) ([]TaskEvent, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if _, err := tm.authorizeTask(userID, taskID, ActionView); err != nil {
+		return nil, err
+	}
+	if tm.storage != nil {
+		return tm.storage.GetTaskEvents(taskID)
+	}
+	events := []TaskEvent{}
+	for _, event := range tm.events {
+		if event.TaskID == taskID {
+			events = append(events, event)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].ID > events[j].ID
+	})
+	return events, nil
+}
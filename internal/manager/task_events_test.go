@@ -0,0 +1,113 @@
+package manager
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTaskEvents(t *testing.T) {
+	tm := NewTaskManager()
+	stm := NewSubTaskManager()
+	tm.SetSubTaskManager(stm)
+	alice := User{ID: 1, Username: "alice"}
+	bob := User{ID: 2, Username: "bob"}
+	carol := User{ID: 3, Username: "carol"}
+
+	family, _ := tm.CreateProjectForUser(alice.ID, CreateProjectRequest{Name: "Семья"})
+	tm.ShareProjectForUser(alice.ID, family.ID, bob, RoleEditor)
+	high := PriorityHigh
+	task, err := tm.CreateTaskForUser(alice.ID, "Купить продукты", []string{"дом"},
+		UpdateTaskRequest{Priority: &high, ProjectID: &family.ID})
+	if err != nil {
+		t.Fatalf("Создание задачи: %v", err)
+	}
+
+	// Изменения через API записываются от имени редактора с источником api
+	api := tm.WithSource(SourceAPI)
+	description := "Купить продукты и хлеб"
+	tags := []string{"дом", "магазин"}
+	if _, err := api.UpdateTaskForUser(bob.ID, task.ID, UpdateTaskRequest{Description: &description, Tags: &tags}); err != nil {
+		t.Fatalf("Изменение задачи: %v", err)
+	}
+	if _, _, err := tm.ToggleCompleteForUser(alice.ID, task.ID); err != nil {
+		t.Fatalf("Отметка выполнения: %v", err)
+	}
+	subtaskID, _ := stm.WithSource(SourceTelegram).AddSubTask(bob.ID, task.ID, "Молоко")
+	stm.ToggleSubTask(alice.ID, subtaskID)
+	stm.DeleteSubTask(alice.ID, subtaskID)
+
+	events, err := tm.GetTaskEvents(bob.ID, task.ID)
+	if err != nil {
+		t.Fatalf("История: %v", err)
+	}
+	want := []struct {
+		typ    EventType
+		source Source
+		user   int
+	}{
+		{EventSubTaskDeleted, SourceWeb, alice.ID},
+		{EventSubTaskToggled, SourceWeb, alice.ID},
+		{EventSubTaskAdded, SourceTelegram, bob.ID},
+		{EventToggled, SourceWeb, alice.ID},
+		{EventUpdated, SourceAPI, bob.ID},
+		{EventCreated, SourceWeb, alice.ID},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("Ожидалось %d событий, получено %d: %+v", len(want), len(events), events)
+	}
+	for i, w := range want {
+		if e := events[i]; e.Type != w.typ || e.Source != w.source || e.UserID != w.user || e.TaskID != task.ID {
+			t.Errorf("Событие %d: ожидалось %v/%v от %d, получено %+v", i, w.typ, w.source, w.user, e)
+		}
+	}
+
+	created := events[5].Changes
+	if findChange(created, "priority") != (FieldChange{"priority", "", "high"}) ||
+		findChange(created, "project_id").New == "" || findChange(created, "tags").New != "дом" {
+		t.Errorf("Поля новой задачи: %+v", created)
+	}
+	updated := events[4].Changes
+	if len(updated) != 2 || findChange(updated, "description") != (FieldChange{"description", "Купить продукты", description}) ||
+		findChange(updated, "tags") != (FieldChange{"tags", "дом", "дом, магазин"}) {
+		t.Errorf("Изменения полей: %+v", updated)
+	}
+	if events[3].Changes[0] != (FieldChange{"completed", "", "true"}) {
+		t.Errorf("Отметка выполнения: %+v", events[3].Changes)
+	}
+	if events[2].SubTaskID != subtaskID || events[2].Changes[0] != (FieldChange{"description", "", "Молоко"}) {
+		t.Errorf("Добавление подзадачи: %+v", events[2])
+	}
+
+	// Правка, которая ничего не поменяла, в историю не попадает
+	if _, err := tm.UpdateTaskForUser(alice.ID, task.ID, UpdateTaskRequest{Description: &description}); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := tm.GetTaskEvents(alice.ID, task.ID); len(again) != len(events) {
+		t.Errorf("Пустая правка записана: %+v", again[0])
+	}
+
+	if _, err := tm.GetTaskEvents(carol.ID, task.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("История чужой задачи: ожидался ErrForbidden, получено %v", err)
+	}
+
+	// Удаление записывается, но историю удаленной задачи уже не прочитать
+	if err := tm.DeleteTaskForUser(alice.ID, task.ID); err != nil {
+		t.Fatal(err)
+	}
+	last := tm.events[len(tm.events)-1]
+	if last.Type != EventDeleted || last.TaskID != task.ID || last.UserID != alice.ID {
+		t.Errorf("Удаление задачи: %+v", last)
+	}
+	if _, err := tm.GetTaskEvents(alice.ID, task.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("История удаленной задачи: ожидался ErrNotFound, получено %v", err)
+	}
+}
+
+func findChange(changes []FieldChange, field string) FieldChange {
+	for _, change := range changes {
+		if change.Field == field {
+			return change
+		}
+	}
+	return FieldChange{}
+}
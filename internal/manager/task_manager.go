@@ -2,8 +2,6 @@ package manager
 
 import (
 	"context"
-	"errors"
-	"fmt"
 	"sort"
 	"strings"
 	"sync"
@@ -74,6 +72,11 @@ const (
 	PriorityHigh   Priority = "high"
 )
 
+// Valid сообщает, является ли значение одним из известных приоритетов
+func (p Priority) Valid() bool {
+	return p == PriorityLow || p == PriorityMedium || p == PriorityHigh
+}
+
 type Task struct {
 	ID          int       `json:"id"`
 	UserID      int       `json:"user_id"`
@@ -84,6 +87,12 @@ type Task struct {
 	Priority    Priority  `json:"priority"`
 	DueDate     time.Time `json:"due_date"`
 	Tags        []string  `json:"tags"`
+	Recurrence  string    `json:"recurrence,omitempty"` // правило повторения (RRULE), см. Recurrence
+	SeriesID    int       `json:"series_id,omitempty"`  // ID первой задачи серии повторений
+	ProjectID   int       `json:"project_id"`           // проект задачи, по умолчанию Входящие
+	AssigneeID  int       `json:"assignee_id,omitempty"` // исполнитель, 0 - не назначен; автор - UserID
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"` // когда задача попала в корзину, см. trash.go
+	Version     int       `json:"version"`              // растет с каждым изменением, см. UpdateTaskRequest.Version
 }
 
 type SubTask struct {
@@ -102,20 +111,62 @@ type UpdateTaskRequest struct {
 	Priority    *Priority  `json:"priority,omitempty"`
 	DueDate     *time.Time `json:"due_date,omitempty"`
 	Tags        *[]string  `json:"tags,omitempty"`
+	Recurrence  *string    `json:"recurrence,omitempty"` // "" - перестать повторять
+	ProjectID   *int       `json:"project_id,omitempty"` // перенести в проект (не архивный)
+	AssigneeID  *int       `json:"assignee_id,omitempty"` // назначить исполнителя, 0 - снять назначение
+
+	// Version - версия задачи, которую видел клиент. Если задан, изменение
+	// применяется, только пока задачу никто не поменял, иначе возвращается
+	// VersionConflictError. Без него последнее изменение побеждает.
+	Version *int `json:"version,omitempty"`
 }
 
+// TaskManager - задачи пользователя. Копии из WithSource делят одно
+// состояние taskState и отличаются только источником изменений в истории.
 type TaskManager struct {
+	*taskState
+	source Source
+}
+
+type taskState struct {
 	mu     sync.Mutex
 	tasks  map[int]Task
+	trash  map[int]Task // Удаленные задачи в памяти, см. trash.go
 	nextID int
 	storage Storage
+
+	reminders *ReminderManager // Получает изменения сроков и статусов задач
+	subtasks  *SubTaskManager  // Подзадачи в памяти, копируются в следующий экземпляр серии
+
+	projects      map[int]Project // Проекты в памяти, см. projects.go
+	nextProjectID int
+	members       map[int]map[int]Member   // Участники проектов в памяти по ID проекта и пользователя
+	invites       map[string]ProjectInvite // Приглашения в проекты по хешу кода
+
+	events      []TaskEvent // История изменений в памяти, см. task_events.go
+	nextEventID int
+
+	syncLog map[int]map[syncKey]SyncEntry // Ленты синхронизации в памяти по ID пользователя, см. sync.go
+	syncSeq map[int]int                   // Последний номер в ленте пользователя
+
+	pushMu        sync.Mutex                 // Пакеты изменений клиентов применяются по одному, см. ApplyChanges
+	clientChanges map[int]map[string]syncKey // Созданное пакетами в памяти по ID пользователя и client_id
 }
 
+// SubTaskManager - подзадачи; как и TaskManager, копии из WithSource
+// делят одно состояние
 type SubTaskManager struct {
+	*subTaskState
+	source Source
+}
+
+type subTaskState struct {
 	mu       sync.Mutex
 	subtasks map[int]SubTask
 	nextID   int
 	storage  Storage
+
+	tasks *TaskManager // Проверяет права на задачу, см. SetSubTaskManager
 }
 
 type FilterOptions struct {
@@ -125,6 +176,9 @@ type FilterOptions struct {
 	StartDate   *time.Time `json:"start_date,omitempty"`
 	EndDate     *time.Time `json:"end_date,omitempty"`
 	HasDueDate  *bool      `json:"has_due_date,omitempty"`
+	Query       string     `json:"query,omitempty"` // Язык запросов, см. QueryExpr
+	ProjectID   *int       `json:"project_id,omitempty"`
+	AssigneeID  *int       `json:"assignee_id,omitempty"` // 0 - задачи без исполнителя
 }
 
 type User struct {
@@ -132,24 +186,71 @@ type User struct {
     DeviceID     string    `json:"device_id"`
     TelegramID   int64     `json:"telegram_id,omitempty"`
     FCMToken     string    `json:"fcm_token,omitempty"`
+    Username     string    `json:"username,omitempty"`
+    Email        string    `json:"email,omitempty"`
+    PasswordHash string    `json:"-"`
     CreatedAt    time.Time `json:"created_at"`
     UpdatedAt    time.Time `json:"updated_at"`
 }
 
 func NewTaskManager() *TaskManager {
-	return &TaskManager{
+	return &TaskManager{taskState: &taskState{
 		tasks:  make(map[int]Task),
+		trash:  make(map[int]Task),
 		nextID: 1,
 		storage: nil,
-	}
+		projects:      make(map[int]Project),
+		nextProjectID: 1,
+		members:       make(map[int]map[int]Member),
+		invites:       make(map[string]ProjectInvite),
+		nextEventID:   1,
+		syncLog:       make(map[int]map[syncKey]SyncEntry),
+		syncSeq:       make(map[int]int),
+		clientChanges: make(map[int]map[string]syncKey),
+	}, source: SourceWeb}
 }
 
 func NewSubTaskManager() *SubTaskManager {
-	return &SubTaskManager{
+	return &SubTaskManager{subTaskState: &subTaskState{
 		subtasks: make(map[int]SubTask),
 		nextID:   1,
 		storage:  nil,
+	}, source: SourceWeb}
+}
+
+// prepareUpdate проверяет запрос на изменение задачи и приводит теги
+// и правило повторения к виду, в котором они хранятся
+func prepareUpdate(req *UpdateTaskRequest) error {
+	if req.Description != nil {
+		if *req.Description == "" {
+			return Invalid("описание не может быть пустым")
+		}
+		if len(*req.Description) > 1000 {
+			return Invalid("описание не может превышать 1000 символов")
+		}
+	}
+
+	if req.Priority != nil && !req.Priority.Valid() {
+		return Invalid("приоритет должен быть low, medium или high")
+	}
+
+	if req.Tags != nil {
+		tags := normalizeTags(*req.Tags)
+		req.Tags = &tags
 	}
+
+	if req.Recurrence != nil {
+		canonical := ""
+		if strings.TrimSpace(*req.Recurrence) != "" {
+			rule, err := ParseRecurrence(*req.Recurrence)
+			if err != nil {
+				return err
+			}
+			canonical = rule.String()
+		}
+		req.Recurrence = &canonical
+	}
+	return nil
 }
 
 func normalizeTags(tags []string) []string {
@@ -168,6 +269,29 @@ func normalizeTags(tags []string) []string {
 
 // AddTaskForUser - новый метод для добавления задач с указанием пользователя
 func (tm *TaskManager) AddTaskForUser(userID int, description string, tags []string) (int, error) {
+	id, err := tm.addTask(userID, description, tags)
+	if err != nil {
+		return 0, err
+	}
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.recordCreated(userID, id)
+	return id, nil
+}
+
+// recordCreated записывает в историю создание задачи со всеми ее полями.
+// Вызывается под tm.mu.
+func (tm *TaskManager) recordCreated(userID, id int) {
+	task, err := tm.findTask(id)
+	if err != nil {
+		logger.Error(context.Background(), err, "Ошибка записи истории задачи", "taskID", id)
+		return
+	}
+	tm.recordEvent(tm.newEvent(userID, id, EventCreated, taskChanges(Task{}, task)))
+}
+
+// addTask добавляет задачу без записи в историю: ее пишет вызывающий
+func (tm *TaskManager) addTask(userID int, description string, tags []string) (int, error) {
 	start := time.Now()
 	defer func() {
 		AddTaskDuration.Observe(time.Since(start).Seconds())
@@ -175,13 +299,15 @@ func (tm *TaskManager) AddTaskForUser(userID int, description string, tags []str
 	
 	if description == "" {
 		AddTaskCount.WithLabelValues("error").Inc()
-		return 0, errors.New("описание задачи обязательно")
+		return 0, Invalid("описание задачи обязательно")
 	}
 	if len(description) > 1000 {
 		AddTaskCount.WithLabelValues("error").Inc()
-		return 0, errors.New("описание не может превышать 1000 символов")
+		return 0, Invalid("описание не может превышать 1000 символов")
 	}
 	
+	tags = normalizeTags(tags)
+
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 
@@ -201,6 +327,11 @@ func (tm *TaskManager) AddTaskForUser(userID int, description string, tags []str
 	}
 
 	log.Printf("💾 Используем in-memory хранилище для задачи пользователя %d: %s", userID, description)
+	inbox, err := tm.inbox(userID)
+	if err != nil {
+		AddTaskCount.WithLabelValues("error").Inc()
+		return 0, err
+	}
 	id := tm.nextID
 	tm.tasks[id] = Task{
 		ID:          id,
@@ -210,9 +341,12 @@ func (tm *TaskManager) AddTaskForUser(userID int, description string, tags []str
 		UpdatedAt:   time.Now(),
 		Completed:   false,
 		Priority:    PriorityMedium,
-		Tags:        normalizeTags(tags),
+		Tags:        tm.canonicalTags(userID, tags),
+		ProjectID:   inbox.ID,
+		Version:     1,
 	}
 	tm.nextID++
+	tm.syncTask(id)
 	log.Printf("✅ Задача #%d добавлена в память для пользователя %d", id, userID)
 	TaskDescLength.Observe(float64(len(description)))
 	AddTaskCount.WithLabelValues("success").Inc()
@@ -220,46 +354,109 @@ func (tm *TaskManager) AddTaskForUser(userID int, description string, tags []str
 	return id, nil
 }
 
-func (tm *TaskManager) AddTask(description string, tags []string) (int, error) {
-	// Для обратной совместимости - используем user_id = 1
-	return tm.AddTaskForUser(1, description, tags)
+// CreateTaskForUser создает задачу сразу с приоритетом, сроком и правилом
+// повторения из req. Запрос проверяется до создания, чтобы некорректные
+// поля не оставляли после себя наполовину созданную задачу.
+func (tm *TaskManager) CreateTaskForUser(userID int, description string, tags []string, req UpdateTaskRequest) (*Task, error) {
+	if err := prepareUpdate(&req); err != nil {
+		return nil, err
+	}
+	if req.ProjectID != nil {
+		tm.mu.Lock()
+		_, err := tm.targetProject(userID, *req.ProjectID)
+		tm.mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+	}
+	id, err := tm.addTask(userID, description, tags)
+	if err != nil {
+		return nil, err
+	}
+	if req.Priority == nil && req.DueDate == nil && req.Recurrence == nil && req.Completed == nil && req.ProjectID == nil &&
+		req.AssigneeID == nil {
+		tm.mu.Lock()
+		tm.recordCreated(userID, id)
+		tm.mu.Unlock()
+		return tm.GetTaskForUser(userID, id)
+	}
+	// Остальные поля задаются обновлением, в истории это одно событие создания
+	return tm.updateTask(userID, id, req, EventCreated)
 }
 
-func (tm *TaskManager) UpdateTask(id int, req UpdateTaskRequest) (*Task, error) {
+// UpdateTaskForUser обновляет задачу, если роль пользователя в ее проекте
+// позволяет менять задачи
+func (tm *TaskManager) UpdateTaskForUser(userID, id int, req UpdateTaskRequest) (*Task, error) {
+	return tm.updateTask(userID, id, req, EventUpdated)
+}
+
+// updateTask - см. UpdateTaskForUser; eventType - событие для истории.
+// Для EventCreated в историю попадают все поля задачи, а не только измененные.
+func (tm *TaskManager) updateTask(userID, id int, req UpdateTaskRequest, eventType EventType) (*Task, error) {
 	start := time.Now()
 	defer func() {
 		UpdateTaskDuration.Observe(time.Since(start).Seconds())
 	}()
+
+	if err := prepareUpdate(&req); err != nil {
+		UpdateTaskCount.WithLabelValues("error").Inc()
+		return nil, err
+	}
+
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
+
+	task, err := tm.authorizeTask(userID, id, ActionEdit)
+	if err != nil {
+		UpdateTaskCount.WithLabelValues("error").Inc()
+		return nil, err
+	}
+	if err := checkVersion(task, req.Version); err != nil {
+		UpdateTaskCount.WithLabelValues("error").Inc()
+		return nil, err
+	}
+	if req.ProjectID != nil {
+		if _, err := tm.targetProject(userID, *req.ProjectID); err != nil {
+			UpdateTaskCount.WithLabelValues("error").Inc()
+			return nil, err
+		}
+	}
+	if req.AssigneeID != nil {
+		projectID := task.ProjectID
+		if req.ProjectID != nil {
+			projectID = *req.ProjectID
+		}
+		if err := tm.checkAssignee(task, projectID, *req.AssigneeID); err != nil {
+			UpdateTaskCount.WithLabelValues("error").Inc()
+			return nil, err
+		}
+	} else if req.ProjectID != nil {
+		if assignee := tm.keepAssignee(task, *req.ProjectID); assignee != task.AssigneeID {
+			req.AssigneeID = &assignee
+		}
+	}
+	previousAssignee := task.AssigneeID
+	before := task
+	if eventType == EventCreated {
+		before = Task{}
+	}
 	
 	if tm.storage != nil {
 		log.Printf("📦 Используем хранилище для обновления задачи #%d", id)
-		task, err := tm.storage.UpdateTask(id, req)
+		saved, err := tm.storage.UpdateTask(task.UserID, id, req)
 		if err != nil {
 			UpdateTaskCount.WithLabelValues("error").Inc()
 			return nil, err
 		}
 		UpdateTaskCount.WithLabelValues("success").Inc()
-		logger.Info(context.Background(), "Задача обновлена в хранилище", "taskID", id, "tags", task.Tags)
-		return task, nil
-	}
-	
-	task, exists := tm.tasks[id]
-	if !exists {
-		UpdateTaskCount.WithLabelValues("error").Inc()
-		return nil, fmt.Errorf("задача с ID %d не найдена", id)
+		logger.Info(context.Background(), "Задача обновлена в хранилище", "taskID", id, "userID", userID, "tags", saved.Tags)
+		tm.notifyChanged(*saved)
+		tm.notifyAssigned(*saved, previousAssignee, userID)
+		tm.recordEvent(tm.newEvent(userID, id, eventType, taskChanges(before, *saved)))
+		return saved, nil
 	}
 	
 	if req.Description != nil {
-		if *req.Description == "" {
-			UpdateTaskCount.WithLabelValues("error").Inc()
-			return nil, errors.New("описание не может быть пустым")
-		}
-		if len(*req.Description) > 1000 {
-			UpdateTaskCount.WithLabelValues("error").Inc()
-			return nil, errors.New("описание не может превышать 1000 символов")
-		}
 		task.Description = *req.Description
 	}
 	
@@ -276,157 +473,238 @@ func (tm *TaskManager) UpdateTask(id int, req UpdateTaskRequest) (*Task, error)
 	}
 	
 	if req.Tags != nil {
-		task.Tags = normalizeTags(*req.Tags)
+		task.Tags = tm.canonicalTags(task.UserID, *req.Tags)
+	}
+
+	if req.Recurrence != nil {
+		task.Recurrence = *req.Recurrence
+		if task.Recurrence != "" && task.SeriesID == 0 {
+			task.SeriesID = task.ID
+		}
+	}
+
+	if req.ProjectID != nil {
+		task.ProjectID = *req.ProjectID
+	}
+
+	if req.AssigneeID != nil {
+		task.AssigneeID = *req.AssigneeID
 	}
 	
 	task.UpdatedAt = time.Now()
+	task.Version++
 	tm.tasks[id] = task
+	tm.syncTask(id)
 	UpdateTaskCount.WithLabelValues("success").Inc()
 	logger.Info(context.Background(), "Задача обновлена", "taskID", id, "tags", task.Tags)
+	tm.notifyChanged(task)
+	tm.notifyAssigned(task, previousAssignee, userID)
+	tm.recordEvent(tm.newEvent(userID, id, eventType, taskChanges(before, task)))
 	return &task, nil
 }
 
-func (tm *TaskManager) DeleteTask(id int) error {
+// DeleteTaskForUser перемещает задачу в корзину, если роль пользователя
+// в ее проекте позволяет менять задачи. Восстановить - RestoreTaskForUser
+func (tm *TaskManager) DeleteTaskForUser(userID, id int) error {
+	return tm.deleteTask(userID, id, nil)
+}
+
+// deleteTask - см. DeleteTaskForUser; с version задача удаляется, только
+// если ее никто не изменил после этой версии (см. ApplyChanges)
+func (tm *TaskManager) deleteTask(userID, id int, version *int) error {
 	start := time.Now()
 	defer func() {
 		DeleteTaskDuration.Observe(time.Since(start).Seconds())
 	}()
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
+
+	task, err := tm.authorizeTask(userID, id, ActionEdit)
+	if err != nil {
+		DeleteTaskCount.WithLabelValues("error").Inc()
+		return err
+	}
+	if err := checkVersion(task, version); err != nil {
+		DeleteTaskCount.WithLabelValues("error").Inc()
+		return err
+	}
 	
 	if tm.storage != nil {
 		log.Printf("📦 Используем хранилище для удаления задачи #%d", id)
-		err := tm.storage.DeleteTask(id)
+		err := tm.storage.DeleteTask(task.UserID, id)
 		if err != nil {
 			DeleteTaskCount.WithLabelValues("error").Inc()
 			return err
 		}
 		DeleteTaskCount.WithLabelValues("success").Inc()
-		logger.Info(context.Background(), "Задача удалена из хранилище", "taskID", id)
+		logger.Info(context.Background(), "Задача перемещена в корзину", "taskID", id)
+		tm.notifyDeleted(id)
+		tm.recordEvent(tm.newEvent(userID, id, EventDeleted, nil))
 		return nil
 	}
 	
-	if _, exists := tm.tasks[id]; !exists {
-		DeleteTaskCount.WithLabelValues("error").Inc()
-		return fmt.Errorf("задача с ID %d не найдена", id)
-	}
 	delete(tm.tasks, id)
+	deletedAt := time.Now()
+	task.DeletedAt = &deletedAt
+	task.Version++
+	tm.trash[id] = task
+	tm.syncTask(id)
 	DeleteTaskCount.WithLabelValues("success").Inc()
-	logger.Info(context.Background(), "Задача удалена из памяти", "taskID", id)
+	logger.Info(context.Background(), "Задача перемещена в корзину в памяти", "taskID", id)
+	tm.notifyDeleted(id)
+	tm.recordEvent(tm.newEvent(userID, id, EventDeleted, nil))
 	return nil
 }
 
-func (tm *TaskManager) GetAllTasks() []Task {
+// GetTaskForUser возвращает задачу, если пользователь видит ее проект
+func (tm *TaskManager) GetTaskForUser(userID, id int) (*Task, error) {
+	return tm.AuthorizeTask(userID, id, ActionView)
+}
+
+// SetSubTaskManager связывает задачи и подзадачи: подзадачи проверяют
+// права через роль в проекте задачи, а подзадачи в памяти копируются
+// в следующий экземпляр повторяющейся задачи. Без вызова подзадачи
+// доступны только автору задачи.
+func (tm *TaskManager) SetSubTaskManager(stm *SubTaskManager) {
+	tm.mu.Lock()
+	tm.subtasks = stm
+	tm.mu.Unlock()
+
+	stm.mu.Lock()
+	stm.tasks = tm
+	stm.mu.Unlock()
+}
+
+// SetReminderManager подключает напоминания к изменениям задач
+func (tm *TaskManager) SetReminderManager(rm *ReminderManager) {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
+	tm.reminders = rm
+}
 
-	if tm.storage != nil {
-		log.Printf("📦 Загружаем задачи из SQLite хранилища")
-		tasks, err := tm.storage.GetAllTasks()
-		if err != nil {
-			log.Printf("❌ Ошибка загрузки из хранилища: %v", err)
-			return []Task{}
-		}
-		log.Printf("✅ Загружено %d задач из SQLite хранилища", len(tasks))
-		return tasks
+// notifyChanged и notifyDeleted сообщают напоминаниям об изменении задачи.
+// Вызываются под tm.mu; ReminderManager не обращается к TaskManager в ответ.
+func (tm *TaskManager) notifyChanged(task Task) {
+	if tm.reminders != nil {
+		tm.reminders.taskChanged(task)
 	}
+}
 
-	log.Printf("💾 Загружаем задачи из памяти")
-	tasks := make([]Task, 0, len(tm.tasks))
-	for _, task := range tm.tasks {
-		tasks = append(tasks, task)
+func (tm *TaskManager) notifyDeleted(id int) {
+	if tm.reminders != nil {
+		tm.reminders.taskDeleted(id)
 	}
-	log.Printf("✅ Загружено %d задач из памяти", len(tasks))
-	return tasks
 }
 
-func (tm *TaskManager) ToggleComplete(id int) (*Task, error) {
+// ToggleCompleteForUser переключает статус задачи, если роль пользователя
+// в ее проекте позволяет менять задачи. Выполнение повторяющейся задачи
+// создает следующий экземпляр серии - его ID возвращается вторым (0 - не
+// создан), чтобы отмена могла его убрать (см. UndoCompleteForUser).
+func (tm *TaskManager) ToggleCompleteForUser(userID, id int) (*Task, int, error) {
 	start := time.Now()
 	defer func() {
 		UpdateTaskDuration.Observe(time.Since(start).Seconds())
 	}()
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
+
+	task, err := tm.authorizeTask(userID, id, ActionEdit)
+	if err != nil {
+		UpdateTaskCount.WithLabelValues("error").Inc()
+		return nil, 0, err
+	}
 	
 	if tm.storage != nil {
 		log.Printf("📦 Используем хранилище для переключения задачи #%d", id)
-		task, err := tm.storage.ToggleComplete(id)
+		saved, err := tm.storage.ToggleComplete(task.UserID, id)
 		if err != nil {
 			UpdateTaskCount.WithLabelValues("error").Inc()
-			return nil, err
+			return nil, 0, err
 		}
 		UpdateTaskCount.WithLabelValues("success").Inc()
-		logger.Info(context.Background(), "Статус задачи изменен в хранилище", "taskID", id, "completed", task.Completed)
-		return task, nil
+		logger.Info(context.Background(), "Статус задачи изменен в хранилище", "taskID", id, "userID", userID, "completed", saved.Completed)
+		tm.notifyChanged(*saved)
+		tm.recordEvent(tm.newEvent(userID, id, EventToggled, taskChanges(task, *saved)))
+		return saved, tm.completeRecurring(*saved, userID), nil
 	}
 	
-	task, exists := tm.tasks[id]
-	if !exists {
-		UpdateTaskCount.WithLabelValues("error").Inc()
-		return nil, fmt.Errorf("задача с ID %d не найдена", id)
-	}
+	previous := task
 	task.Completed = !task.Completed
 	task.UpdatedAt = time.Now()
+	task.Version++
 	tm.tasks[id] = task
+	tm.syncTask(id)
 	UpdateTaskCount.WithLabelValues("success").Inc()
 	logger.Info(context.Background(), "Статус задачи изменен в памяти", "taskID", id, "completed", task.Completed)
-	return &task, nil
+	tm.notifyChanged(task)
+	tm.recordEvent(tm.newEvent(userID, id, EventToggled, taskChanges(previous, task)))
+	return &task, tm.completeRecurring(task, userID), nil
 }
 
-func (tm *TaskManager) FilterTasks(completed *bool) []Task {
+// FilterTasksForUser возвращает задачи, которые видит пользователь, по статусу выполнения
+func (tm *TaskManager) FilterTasksForUser(userID int, completed *bool) ([]Task, error) {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 	
 	if tm.storage != nil {
 		log.Printf("📦 Используем хранилище для фильтрации задач")
-		tasks, err := tm.storage.FilterTasks(completed)
+		tasks, err := tm.storage.FilterTasks(userID, completed)
 		if err != nil {
-			log.Printf("❌ Ошибка фильтрации в хранилище: %v", err)
-			return []Task{}
+			return nil, err
 		}
 		log.Printf("✅ Отфильтровано %d задач из хранилища", len(tasks))
-		return tasks
+		return tasks, nil
 	}
 	
+	visible := tm.visibleTasks(userID)
 	tasks := make([]Task, 0)
 	for _, task := range tm.tasks {
+		if !visible(task) {
+			continue
+		}
 		if completed == nil || task.Completed == *completed {
 			tasks = append(tasks, task)
 		}
 	}
-	return tasks
+	return tasks, nil
 }
 
-func (tm *TaskManager) FilterByPriority(priority Priority) []Task {
-    tm.mu.Lock()
-    defer tm.mu.Unlock()
-    
-    if tm.storage != nil {
-        tasks, err := tm.storage.FilterByPriority(priority)
-        if err != nil {
-            log.Printf("❌ Ошибка фильтрации по приоритету: %v", err)
-            return []Task{}
-        }
-        return tasks
-    }
+// FilterByPriorityForUser возвращает задачи пользователя с указанным приоритетом
+func (tm *TaskManager) FilterByPriorityForUser(userID int, priority Priority) ([]Task, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
 
+	if tm.storage != nil {
+		return tm.storage.FilterByPriority(userID, priority)
+	}
+
+	visible := tm.visibleTasks(userID)
 	tasks := make([]Task, 0)
 	for _, task := range tm.tasks {
-		if task.Priority == priority {
+		if visible(task) && task.Priority == priority {
 			tasks = append(tasks, task)
 		}
 	}
-	return tasks
+	return tasks, nil
 }
 
-func (tm *TaskManager) FilterByTag(tag string) []Task {
+// FilterByTagForUser возвращает задачи пользователя с тегом (без учета регистра)
+func (tm *TaskManager) FilterByTagForUser(userID int, tag string) ([]Task, error) {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 	
+	if tm.storage != nil {
+		return tm.storage.FilterByTag(userID, tag)
+	}
+
 	tag = strings.TrimSpace(strings.ToLower(tag))
+	visible := tm.visibleTasks(userID)
 	var result []Task
 	
 	for _, task := range tm.tasks {
+		if !visible(task) {
+			continue
+		}
 		for _, t := range task.Tags {
 			if strings.ToLower(t) == tag {
 				result = append(result, task)
@@ -434,41 +712,26 @@ func (tm *TaskManager) FilterByTag(tag string) []Task {
 			}
 		}
 	}
-	return result
+	return result, nil
 }
 
-func (tm *TaskManager) GetAllTags() []string {
+// GetUpcomingTasksForUser возвращает невыполненные задачи пользователя
+// со сроком от сегодня до сегодня + days включительно
+func (tm *TaskManager) GetUpcomingTasksForUser(userID, days int) ([]Task, error) {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
-	
-	tagsMap := make(map[string]bool)
-	for _, task := range tm.tasks {
-		for _, tag := range task.Tags {
-			normalized := strings.ToLower(strings.TrimSpace(tag))
-			if normalized != "" {
-				tagsMap[normalized] = true
-			}
-		}
-	}
-	
-	tags := make([]string, 0, len(tagsMap))
-	for tag := range tagsMap {
-		tags = append(tags, tag)
+
+	if tm.storage != nil {
+		return tm.storage.GetUpcomingTasks(userID, days)
 	}
-	
-	sort.Strings(tags)
-	return tags
-}
 
-func (tm *TaskManager) GetUpcomingTasks(days int) []Task {
-	tm.mu.Lock()
-	defer tm.mu.Unlock()
 	now := time.Now()
 	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
 	endDate := today.AddDate(0, 0, days+1)
+	visible := tm.visibleTasks(userID)
 	tasks := make([]Task, 0)
 	for _, task := range tm.tasks {
-		if task.DueDate.IsZero() || task.Completed {
+		if !visible(task) || task.DueDate.IsZero() || task.Completed {
 			continue
 		}
 		taskDate := time.Date(
@@ -485,16 +748,23 @@ func (tm *TaskManager) GetUpcomingTasks(days int) []Task {
 	sort.Slice(tasks, func(i, j int) bool {
 		return tasks[i].DueDate.Before(tasks[j].DueDate)
 	})
-	return tasks
+	return tasks, nil
 }
 
-func (tm *TaskManager) FilterByDateRange(start, end time.Time) []Task {
+// FilterByDateRangeForUser возвращает задачи пользователя со сроком в диапазоне
+func (tm *TaskManager) FilterByDateRangeForUser(userID int, start, end time.Time) ([]Task, error) {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
+
+	if tm.storage != nil {
+		return tm.storage.FilterByDateRange(userID, start, end)
+	}
 	
+	visible := tm.visibleTasks(userID)
 	var result []Task
 	for _, task := range tm.tasks {
-		if !task.DueDate.IsZero() && 
+		if visible(task) &&
+		   !task.DueDate.IsZero() && 
 		   !task.DueDate.Before(start) && 
 		   !task.DueDate.After(end) {
 			result = append(result, task)
@@ -505,20 +775,48 @@ func (tm *TaskManager) FilterByDateRange(start, end time.Time) []Task {
 		return result[i].DueDate.Before(result[j].DueDate)
 	})
 	
-	return result
+	return result, nil
 }
 
-func (stm *SubTaskManager) AddSubTask(taskID int, description string) (int, error) {
+// AddSubTask добавляет подзадачу, если роль пользователя в проекте задачи
+// позволяет менять задачи. Подзадача сохраняется от имени автора задачи.
+func (stm *SubTaskManager) AddSubTask(userID, taskID int, description string) (int, error) {
 	if description == "" {
-		return 0, errors.New("описание подзадачи обязательно")
+		return 0, Invalid("описание подзадачи обязательно")
 	}
-	
+	ownerID, err := stm.taskOwner(userID, taskID, ActionEdit)
+	if err != nil {
+		return 0, err
+	}
+	id, err := stm.addSubTask(ownerID, taskID, description)
+	if err != nil {
+		return 0, err
+	}
+	stm.syncSubTask(id, taskID)
+	stm.recordEvent(userID, SubTask{ID: id, TaskID: taskID}, EventSubTaskAdded,
+		[]FieldChange{{Field: "description", New: description}})
+	return id, nil
+}
+
+// addSubTask добавляет подзадачу без проверки прав (см. spawnNext)
+func (stm *SubTaskManager) addSubTask(userID, taskID int, description string) (int, error) {
 	stm.mu.Lock()
 	defer stm.mu.Unlock()
 	
+	if stm.storage != nil {
+		id, err := stm.storage.AddSubTask(userID, taskID, description)
+		if err != nil {
+			log.Printf("❌ Ошибка добавления подзадачи в хранилище: %v", err)
+			return 0, err
+		}
+		logger.Info(context.Background(), "Подзадача добавлена в хранилище", "subtaskID", id, "taskID", taskID, "userID", userID)
+		return id, nil
+	}
+	
 	id := stm.nextID
 	stm.subtasks[id] = SubTask{
 		ID:          id,
+		UserID:      userID,
 		TaskID:      taskID,
 		Description: description,
 		CreatedAt:   time.Now(),
@@ -527,17 +825,31 @@ func (stm *SubTaskManager) AddSubTask(taskID int, description string) (int, erro
 	}
 	stm.nextID++
 	
-	logger.Info(context.Background(), "Подзадача добавлена", "subtaskID", id, "taskID", taskID)
+	logger.Info(context.Background(), "Подзадача добавлена", "subtaskID", id, "taskID", taskID, "userID", userID)
 	return id, nil
 }
 
-func (stm *SubTaskManager) GetSubTasks(taskID int) []SubTask {
+// GetSubTasks возвращает подзадачи задачи, если пользователь видит ее проект
+func (stm *SubTaskManager) GetSubTasks(userID, taskID int) ([]SubTask, error) {
+	ownerID, err := stm.taskOwner(userID, taskID, ActionView)
+	if err != nil {
+		return nil, err
+	}
+	return stm.listSubTasks(ownerID, taskID)
+}
+
+// listSubTasks возвращает подзадачи без проверки прав (см. spawnNext)
+func (stm *SubTaskManager) listSubTasks(userID, taskID int) ([]SubTask, error) {
 	stm.mu.Lock()
 	defer stm.mu.Unlock()
 	
-	var result []SubTask
+	if stm.storage != nil {
+		return stm.storage.GetSubTasks(userID, taskID)
+	}
+	
+	result := []SubTask{}
 	for _, subtask := range stm.subtasks {
-		if subtask.TaskID == taskID {
+		if subtask.TaskID == taskID && subtask.UserID == userID {
 			result = append(result, subtask)
 		}
 	}
@@ -546,16 +858,38 @@ func (stm *SubTaskManager) GetSubTasks(taskID int) []SubTask {
 		return result[i].CreatedAt.Before(result[j].CreatedAt)
 	})
 	
-	return result
+	return result, nil
+}
+
+func (stm *SubTaskManager) ToggleSubTask(userID, id int) error {
+	subtask, ownerID, err := stm.subTaskOwner(userID, id)
+	if err != nil {
+		return err
+	}
+	if err := stm.toggleSubTask(userID, ownerID, id); err != nil {
+		return err
+	}
+	stm.syncSubTask(id, subtask.TaskID)
+	stm.recordEvent(userID, subtask, EventSubTaskToggled,
+		[]FieldChange{{Field: "completed", Old: boolField(subtask.Completed), New: boolField(!subtask.Completed)}})
+	return nil
 }
 
-func (stm *SubTaskManager) ToggleSubTask(id int) error {
+func (stm *SubTaskManager) toggleSubTask(userID, ownerID, id int) error {
 	stm.mu.Lock()
 	defer stm.mu.Unlock()
 	
-	subtask, exists := stm.subtasks[id]
-	if !exists {
-		return fmt.Errorf("подзадача с ID %d не найдена", id)
+	if stm.storage != nil {
+		if err := stm.storage.ToggleSubTask(ownerID, id); err != nil {
+			return err
+		}
+		logger.Info(context.Background(), "Статус подзадачи изменен в хранилище", "subtaskID", id, "userID", userID)
+		return nil
+	}
+	
+	subtask, err := stm.lookupSubTask(ownerID, id)
+	if err != nil {
+		return err
 	}
 	
 	subtask.Completed = !subtask.Completed
@@ -566,12 +900,34 @@ func (stm *SubTaskManager) ToggleSubTask(id int) error {
 	return nil
 }
 
-func (stm *SubTaskManager) DeleteSubTask(id int) error {
+func (stm *SubTaskManager) DeleteSubTask(userID, id int) error {
+	subtask, ownerID, err := stm.subTaskOwner(userID, id)
+	if err != nil {
+		return err
+	}
+	if err := stm.deleteSubTask(userID, ownerID, id); err != nil {
+		return err
+	}
+	stm.syncSubTask(id, subtask.TaskID)
+	stm.recordEvent(userID, subtask, EventSubTaskDeleted,
+		[]FieldChange{{Field: "description", Old: subtask.Description}})
+	return nil
+}
+
+func (stm *SubTaskManager) deleteSubTask(userID, ownerID, id int) error {
 	stm.mu.Lock()
 	defer stm.mu.Unlock()
 	
-	if _, exists := stm.subtasks[id]; !exists {
-		return fmt.Errorf("подзадача с ID %d не найдена", id)
+	if stm.storage != nil {
+		if err := stm.storage.DeleteSubTask(ownerID, id); err != nil {
+			return err
+		}
+		logger.Info(context.Background(), "Подзадача удалена из хранилища", "subtaskID", id, "userID", userID)
+		return nil
+	}
+	
+	if _, err := stm.lookupSubTask(ownerID, id); err != nil {
+		return err
 	}
 	
 	delete(stm.subtasks, id)
@@ -579,70 +935,91 @@ func (stm *SubTaskManager) DeleteSubTask(id int) error {
 	return nil
 }
 
-func (tm *TaskManager) FilterTasksAdvanced(options FilterOptions) []Task {
+// taskOwner проверяет право пользователя на action с задачей taskID и
+// возвращает ее автора: подзадачи хранятся от его имени. Без связи с
+// задачами (см. SetSubTaskManager) пользователь считается автором.
+// Не вызывать под stm.mu: проверка берет tm.mu.
+func (stm *SubTaskManager) taskOwner(userID, taskID int, action Action) (int, error) {
+	stm.mu.Lock()
+	tasks := stm.tasks
+	stm.mu.Unlock()
+	if tasks == nil {
+		return userID, nil
+	}
+	task, err := tasks.AuthorizeTask(userID, taskID, action)
+	if err != nil {
+		return 0, err
+	}
+	return task.UserID, nil
+}
+
+// subTaskOwner - taskOwner для задачи подзадачи id с правом на изменение;
+// возвращает и саму подзадачу (без связи с задачами - пустую)
+func (stm *SubTaskManager) subTaskOwner(userID, id int) (SubTask, int, error) {
+	stm.mu.Lock()
+	if stm.tasks == nil {
+		stm.mu.Unlock()
+		return SubTask{}, userID, nil
+	}
+	var subtask SubTask
+	var err error
+	if stm.storage != nil {
+		var found *SubTask
+		if found, err = stm.storage.GetSubTaskByID(id); err == nil {
+			subtask = *found
+		}
+	} else if found, exists := stm.subtasks[id]; exists {
+		subtask = found
+	} else {
+		err = NotFound("подзадача с ID %d не найдена", id)
+	}
+	stm.mu.Unlock()
+	if err != nil {
+		return SubTask{}, 0, err
+	}
+	ownerID, err := stm.taskOwner(userID, subtask.TaskID, ActionEdit)
+	return subtask, ownerID, err
+}
+
+// lookupSubTask ищет подзадачу в памяти и проверяет владельца.
+// Вызывающий должен удерживать stm.mu.
+func (stm *SubTaskManager) lookupSubTask(userID, id int) (SubTask, error) {
+	subtask, exists := stm.subtasks[id]
+	if !exists {
+		return SubTask{}, NotFound("подзадача с ID %d не найдена", id)
+	}
+	if subtask.UserID != userID {
+		return SubTask{}, Forbidden("подзадача с ID %d принадлежит другому пользователю", id)
+	}
+	return subtask, nil
+}
+
+// FilterTasksAdvancedForUser применяет к задачам, которые видит пользователь,
+// все условия options
+func (tm *TaskManager) FilterTasksAdvancedForUser(userID int, options FilterOptions) ([]Task, error) {
+	match, err := taskFilter(options)
+	if err != nil {
+		return nil, err
+	}
+
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
-	 if tm.storage != nil {
-        log.Printf("📦 Используем хранилище для расширенной фильтрации")
-        tasks, err := tm.storage.FilterTasksAdvanced(options)
-        if err != nil {
-            log.Printf("❌ Ошибка расширенной фильтрации в хранилище: %v", err)
-            return []Task{}
-        }
-        log.Printf("✅ Отфильтровано %d задач расширенным фильтром", len(tasks))
-        return tasks
-    }
+	if tm.storage != nil {
+		log.Printf("📦 Используем хранилище для расширенной фильтрации")
+		tasks, err := tm.storage.FilterTasksAdvanced(userID, options)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("✅ Отфильтровано %d задач расширенным фильтром", len(tasks))
+		return tasks, nil
+	}
+	visible := tm.visibleTasks(userID)
 	tasks := make([]Task, 0)
 	
 	for _, task := range tm.tasks {
-		if options.Completed != nil && task.Completed != *options.Completed {
-			continue
-		}
-		
-		if options.Priority != nil && task.Priority != *options.Priority {
-			continue
-		}
-		
-		if len(options.Tags) > 0 {
-			hasMatchingTag := false
-			for _, filterTag := range options.Tags {
-				filterTag = strings.TrimSpace(strings.ToLower(filterTag))
-				for _, taskTag := range task.Tags {
-					if strings.ToLower(taskTag) == filterTag {
-						hasMatchingTag = true
-						break
-					}
-				}
-				if hasMatchingTag {
-					break
-				}
-			}
-			if !hasMatchingTag {
-				continue
-			}
-		}
-		
-		if options.HasDueDate != nil {
-			hasDueDate := !task.DueDate.IsZero()
-			if hasDueDate != *options.HasDueDate {
-				continue
-			}
-		}
-		
-		if options.StartDate != nil || options.EndDate != nil {
-			if task.DueDate.IsZero() {
-				continue
-			}
-			
-			if options.StartDate != nil && task.DueDate.Before(*options.StartDate) {
-				continue
-			}
-			if options.EndDate != nil && task.DueDate.After(*options.EndDate) {
-				continue
-			}
+		if visible(task) && match(task) {
+			tasks = append(tasks, task)
 		}
-		
-		tasks = append(tasks, task)
 	}
 	
 	sort.Slice(tasks, func(i, j int) bool {
@@ -658,30 +1035,99 @@ func (tm *TaskManager) FilterTasksAdvanced(options FilterOptions) []Task {
 		return tasks[i].DueDate.Before(tasks[j].DueDate)
 	})
 	
-	return tasks
+	return tasks, nil
 }
 
-func NewTaskManagerWithStorage(storage Storage) *TaskManager {
-	return &TaskManager{
-		tasks:  make(map[int]Task),
-		nextID: 1,
-		storage: storage,
+// taskFilter разбирает запрос из options и возвращает проверку задачи
+// на все условия options для in-memory режима
+func taskFilter(options FilterOptions) (func(Task) bool, error) {
+	query, err := ParseTaskQuery(options.Query)
+	if err != nil {
+		return nil, err
 	}
+	today := time.Now()
+	return func(task Task) bool {
+		return matchesFilter(task, options) && (query == nil || query.Match(task, today))
+	}, nil
 }
 
-func NewSubTaskManagerWithStorage(storage Storage) *SubTaskManager {
-	return &SubTaskManager{
-		subtasks: make(map[int]SubTask),
-		nextID:   1,
-		storage:  storage,
+// matchesFilter проверяет задачу на все условия options, кроме запроса
+func matchesFilter(task Task, options FilterOptions) bool {
+	if options.Completed != nil && task.Completed != *options.Completed {
+		return false
+	}
+	
+	if options.Priority != nil && task.Priority != *options.Priority {
+		return false
+	}
+
+	if options.ProjectID != nil && task.ProjectID != *options.ProjectID {
+		return false
 	}
+
+	if options.AssigneeID != nil && task.AssigneeID != *options.AssigneeID {
+		return false
+	}
+	
+	if len(options.Tags) > 0 {
+		hasMatchingTag := false
+		for _, filterTag := range options.Tags {
+			filterTag = strings.TrimSpace(strings.ToLower(filterTag))
+			for _, taskTag := range task.Tags {
+				if strings.ToLower(taskTag) == filterTag {
+					hasMatchingTag = true
+					break
+				}
+			}
+			if hasMatchingTag {
+				break
+			}
+		}
+		if !hasMatchingTag {
+			return false
+		}
+	}
+	
+	if options.HasDueDate != nil {
+		hasDueDate := !task.DueDate.IsZero()
+		if hasDueDate != *options.HasDueDate {
+			return false
+		}
+	}
+	
+	if options.StartDate != nil || options.EndDate != nil {
+		if task.DueDate.IsZero() {
+			return false
+		}
+		
+		if options.StartDate != nil && task.DueDate.Before(*options.StartDate) {
+			return false
+		}
+		if options.EndDate != nil && task.DueDate.After(*options.EndDate) {
+			return false
+		}
+	}
+	return true
+}
+
+func NewTaskManagerWithStorage(storage Storage) *TaskManager {
+	tm := NewTaskManager()
+	tm.storage = storage
+	return tm
+}
+
+func NewSubTaskManagerWithStorage(storage Storage) *SubTaskManager {
+	stm := NewSubTaskManager()
+	stm.storage = storage
+	return stm
 }
 
 func (tm *TaskManager) GetStorage() Storage {
 	return tm.storage
 }
 
-// 🆕 Добавляем метод для получения задач пользователя
+// GetAllTasksForUser возвращает задачи пользователя и задачи общих
+// проектов, где он участник
 func (tm *TaskManager) GetAllTasksForUser(userID int) ([]Task, error) {
     if tm.storage != nil {
         return tm.storage.GetAllTasksForUser(userID)
@@ -690,9 +1136,10 @@ func (tm *TaskManager) GetAllTasksForUser(userID int) ([]Task, error) {
     tm.mu.Lock()
     defer tm.mu.Unlock()
     
+    visible := tm.visibleTasks(userID)
     var userTasks []Task
     for _, task := range tm.tasks {
-        if task.UserID == userID {
+        if visible(task) {
             userTasks = append(userTasks, task)
         }
     }
@@ -700,35 +1147,114 @@ func (tm *TaskManager) GetAllTasksForUser(userID int) ([]Task, error) {
 }
 
 type Storage interface {
-	AddTask(description string, tags []string) (int, error)
 	AddTaskForUser(userID int, description string, tags []string) (int, error)
-	GetAllTasks() ([]Task, error)
-	GetTask(id int) (*Task, error)
-	UpdateTask(id int, req UpdateTaskRequest) (*Task, error)
-	DeleteTask(id int) error
-	ToggleComplete(id int) (*Task, error)
-	
-	FilterTasks(completed *bool) ([]Task, error)
-	FilterByPriority(priority Priority) ([]Task, error)
-	FilterByTag(tag string) ([]Task, error)
-	GetUpcomingTasks(days int) ([]Task, error)
-	FilterByDateRange(start, end time.Time) ([]Task, error)
-	FilterTasksAdvanced(options FilterOptions) ([]Task, error)
-
-	AddSubTask(taskID int, description string) (int, error)
-	GetSubTasks(taskID int) ([]SubTask, error)
-	ToggleSubTask(id int) error
-	DeleteSubTask(id int) error
+	GetTask(userID, id int) (*Task, error)
+	GetTaskByID(id int) (*Task, error)
+	UpdateTask(userID, id int, req UpdateTaskRequest) (*Task, error)
+	UpdateTasks(userID int, requests map[int]UpdateTaskRequest) (map[int]Task, error)
+	DeleteTask(userID, id int) error
+	ToggleComplete(userID, id int) (*Task, error)
+
+	GetDeletedTaskByID(id int) (*Task, error)
+	GetDeletedTasks(userID int) ([]Task, error)
+	RestoreTask(userID, id int) (*Task, error)
+	PurgeDeletedTasks(before time.Time) (int, error)
+	
+	FilterTasks(userID int, completed *bool) ([]Task, error)
+	FilterByPriority(userID int, priority Priority) ([]Task, error)
+	FilterByTag(userID int, tag string) ([]Task, error)
+	GetUpcomingTasks(userID, days int) ([]Task, error)
+	FilterByDateRange(userID int, start, end time.Time) ([]Task, error)
+	FilterTasksAdvanced(userID int, options FilterOptions) ([]Task, error)
+	Search(userID int, query string, options FilterOptions) ([]SearchResult, error)
+
+	GetTags(userID int) ([]TagCount, error)
+	RenameTag(userID int, name, newName string) (int, error)
+	MergeTags(userID int, sources []string, target string) (int, error)
+	DeleteTag(userID int, name string) (int, error)
+
+	GetInbox(userID int) (*Project, error)
+	CreateProject(project *Project) (int, error)
+	GetProjects(userID int) ([]Project, error)
+	GetProject(userID, id int) (*Project, error)
+	UpdateProject(project *Project) error
+	ArchiveProjects(userID int, ids []int, archived bool) (int, error)
+	DeleteProject(userID, id, inboxID int) (int, error)
+	MoveTasks(taskIDs []int, projectID int) (int, error)
+
+	GetProjectMembers(projectID int) ([]Member, error)
+	SaveProjectMember(member *Member) error
+	DeleteProjectMember(projectID, userID int) error
+	CreateProjectInvite(invite *ProjectInvite) error
+	ConsumeProjectInvite(codeHash string, now time.Time) (*ProjectInvite, error)
+
+	CreateSavedFilter(filter *SavedFilter) (int, error)
+	GetSavedFilters(userID int) ([]SavedFilter, error)
+	GetSavedFilter(userID, id int) (*SavedFilter, error)
+	UpdateSavedFilter(filter *SavedFilter) error
+	DeleteSavedFilter(userID, id int) error
+
+	AddSubTask(userID, taskID int, description string) (int, error)
+	GetSubTaskByID(id int) (*SubTask, error)
+	GetSubTasks(userID, taskID int) ([]SubTask, error)
+	ToggleSubTask(userID, id int) error
+	DeleteSubTask(userID, id int) error
+
+	CreateComment(comment *Comment) (int, error)
+	GetComment(id int) (*Comment, error)
+	GetComments(taskID int) ([]Comment, error)
+	UpdateComment(comment *Comment) error
+	DeleteComment(id int) error
+
+	CreateAttachment(attachment *Attachment) (int, error)
+	GetAttachment(id int) (*Attachment, error)
+	GetAttachments(taskID int) ([]Attachment, error)
+	DeleteAttachment(id int) error
+	CountAttachmentsByHash(hash string) (int, error)
+	GetAttachmentHashes() ([]string, error)
+
+	AddTaskEvent(event *TaskEvent) (int, error)
+	GetTaskEvents(taskID int) ([]TaskEvent, error)
+
+	GetVisibleSubTasks(userID int) ([]SubTask, error)
+	GetSyncEntries(userID, since, limit int) ([]SyncEntry, int, error)
+	GetSyncItems(taskIDs, subtaskIDs []int) ([]Task, []SubTask, error)
+	GetClientChange(userID int, clientID string) (SyncEntity, int, error)
+	SaveClientChange(userID int, clientID string, entity SyncEntity, id int) error
+	ImportUserData(userID int, data *Export, mode ImportMode, dryRun bool) (*ImportReport, error)
 
     CreateUser(user *User) (int, error)
     GetUserByDeviceID(deviceID string) (*User, error)
     GetUserByTelegramID(telegramID int64) (*User, error)
 	GetUserByID(userID int) (*User, error)
+	GetUserByLogin(login string) (*User, error)
     UpdateUser(user *User) error
 
+	CreateSession(session *Session) error
+	GetUserBySession(tokenHash string, now time.Time) (*User, error)
+	DeleteSession(tokenHash string) error
+	DeleteExpiredSessions(now time.Time) (int, error)
+
+	CreateLinkCode(code *LinkCode) error
+	ConsumeLinkCode(codeHash string, now time.Time) (int, error)
+	LinkTelegram(userID int, telegramID int64) (int, error)
+
+	CreateReminder(reminder *Reminder) (int, error)
+	GetReminder(userID, id int) (*Reminder, error)
+	GetTaskReminders(userID, taskID int) ([]Reminder, error)
+	UpdateReminder(reminder *Reminder) error
+	DeleteReminder(userID, id int) error
+	GetReminderSettings(userID int) (*ReminderSettings, error)
+	SaveReminderSettings(settings *ReminderSettings) error
+	ClaimDueReminders(owner string, now, claimUntil time.Time, limit int) ([]Reminder, error)
+	FinishReminderDelivery(owner string, reminder *Reminder) error
+	NextReminderTime(now time.Time) (*time.Time, error)
+
     GetAllTasksForUser(userID int) ([]Task, error)
+	CreateTask(task *Task, subtasks []string) (int, error)
+	GetSeriesTasks(userID, seriesID int) ([]Task, error)
     
     MigrateExistingTasksToUser(userID int, deviceID string) error
 
 	Close() error
-}
+}
\ No newline at end of file
@@ -1,6 +1,7 @@
 package manager
 
 import (
+	"errors"
 	"strings"
 	"sync"
 	"testing"
@@ -28,7 +29,7 @@ func TestAddTask(t *testing.T) {
 	tm := NewTaskManager()
 
 	t.Run("Успешное добавление задачи", func(t *testing.T) {
-		id, err := tm.AddTask("Новая задача", []string{"тег1", "тег2"})
+		id, err := tm.AddTaskForUser(1, "Новая задача", []string{"тег1", "тег2"})
 		if err != nil {
 			t.Fatalf("Ошибка при добавлении задачи: %v", err)
 		}
@@ -36,14 +37,14 @@ func TestAddTask(t *testing.T) {
 			t.Errorf("Ожидался ID=1, получен %d", id)
 		}
 		
-		task, _ := tm.GetTask(id)
+		task, _ := tm.GetTaskForUser(1, id)
 		if len(task.Tags) != 2 {
 			t.Errorf("Ожидалось 2 тега, получено %d", len(task.Tags))
 		}
 	})
 
 	t.Run("Пустое описание задачи", func(t *testing.T) {
-		_, err := tm.AddTask("", nil)
+		_, err := tm.AddTaskForUser(1, "", nil)
 		if err == nil {
 			t.Error("Ожидалась ошибка при пустом описании")
 		}
@@ -51,15 +52,15 @@ func TestAddTask(t *testing.T) {
 
 	t.Run("Слишком длинное описание", func(t *testing.T) {
 		longDesc := strings.Repeat("a", 1001)
-		_, err := tm.AddTask(longDesc, nil)
+		_, err := tm.AddTaskForUser(1, longDesc, nil)
 		if err == nil {
 			t.Error("Ожидалась ошибка при слишком длинном описании")
 		}
 	})
 
 	t.Run("Нормализация тегов", func(t *testing.T) {
-		id, _ := tm.AddTask("Задача", []string{" ТЕГ1 ", " тег1 ", "тег2", "", "  "})
-		task, _ := tm.GetTask(id)
+		id, _ := tm.AddTaskForUser(1, "Задача", []string{" ТЕГ1 ", " тег1 ", "тег2", "", "  "})
+		task, _ := tm.GetTaskForUser(1, id)
 		
 		if len(task.Tags) != 2 {
 			t.Errorf("Ожидалось 2 уникальных тега после нормализации, получено %d: %v", 
@@ -75,8 +76,8 @@ func TestAddTask(t *testing.T) {
 	})
 
 	t.Run("Нормализация регистра тегов", func(t *testing.T) {
-		id, _ := tm.AddTask("Задача", []string{"Тег", "тег", "ТЕГ"})
-		task, _ := tm.GetTask(id)
+		id, _ := tm.AddTaskForUser(1, "Задача", []string{"Тег", "тег", "ТЕГ"})
+		task, _ := tm.GetTaskForUser(1, id)
 		
 		if len(task.Tags) != 1 {
 			t.Errorf("Ожидалось 1 уникальный тег (регистронезависимый), получено %d: %v", 
@@ -87,11 +88,11 @@ func TestAddTask(t *testing.T) {
 
 func TestUpdateTask(t *testing.T) {
 	tm := NewTaskManager()
-	id, _ := tm.AddTask("Исходная задача", nil)
+	id, _ := tm.AddTaskForUser(1, "Исходная задача", nil)
 
 	t.Run("Обновление только описания", func(t *testing.T) {
 		newDesc := "Новое описание"
-		updated, err := tm.UpdateTask(id, UpdateTaskRequest{Description: &newDesc})
+		updated, err := tm.UpdateTaskForUser(1, id, UpdateTaskRequest{Description: &newDesc})
 		if err != nil {
 			t.Fatalf("Ошибка при обновлении: %v", err)
 		}
@@ -102,7 +103,7 @@ func TestUpdateTask(t *testing.T) {
 
 	t.Run("Обновление тегов", func(t *testing.T) {
 		newTags := []string{"новый", "тег"}
-		updated, err := tm.UpdateTask(id, UpdateTaskRequest{Tags: &newTags})
+		updated, err := tm.UpdateTaskForUser(1, id, UpdateTaskRequest{Tags: &newTags})
 		if err != nil {
 			t.Fatalf("Ошибка при обновлении тегов: %v", err)
 		}
@@ -113,7 +114,7 @@ func TestUpdateTask(t *testing.T) {
 
 	t.Run("Обновление только статуса", func(t *testing.T) {
 		completed := true
-		updated, err := tm.UpdateTask(id, UpdateTaskRequest{Completed: &completed})
+		updated, err := tm.UpdateTaskForUser(1, id, UpdateTaskRequest{Completed: &completed})
 		if err != nil {
 			t.Fatalf("Ошибка при обновлении: %v", err)
 		}
@@ -124,7 +125,7 @@ func TestUpdateTask(t *testing.T) {
 
 	t.Run("Пустое описание", func(t *testing.T) {
 		empty := ""
-		_, err := tm.UpdateTask(id, UpdateTaskRequest{Description: &empty})
+		_, err := tm.UpdateTaskForUser(1, id, UpdateTaskRequest{Description: &empty})
 		if err == nil {
 			t.Error("Ожидалась ошибка при пустом описании")
 		}
@@ -132,14 +133,14 @@ func TestUpdateTask(t *testing.T) {
 
 	t.Run("Слишком длинное описание", func(t *testing.T) {
 		longDesc := strings.Repeat("a", 1001)
-		_, err := tm.UpdateTask(id, UpdateTaskRequest{Description: &longDesc})
+		_, err := tm.UpdateTaskForUser(1, id, UpdateTaskRequest{Description: &longDesc})
 		if err == nil {
 			t.Error("Ожидалась ошибка при слишком длинном описании")
 		}
 	})
 
 	t.Run("Несуществующая задача", func(t *testing.T) {
-		_, err := tm.UpdateTask(999, UpdateTaskRequest{})
+		_, err := tm.UpdateTaskForUser(1, 999, UpdateTaskRequest{})
 		if err == nil {
 			t.Error("Ожидалась ошибка для несуществующего ID")
 		}
@@ -148,17 +149,17 @@ func TestUpdateTask(t *testing.T) {
 
 func TestDeleteTask(t *testing.T) {
 	tm := NewTaskManager()
-	id, _ := tm.AddTask("Задача для удаления", nil)
+	id, _ := tm.AddTaskForUser(1, "Задача для удаления", nil)
 
 	t.Run("Успешное удаление", func(t *testing.T) {
-		err := tm.DeleteTask(id)
+		err := tm.DeleteTaskForUser(1, id)
 		if err != nil {
 			t.Fatalf("Ошибка при удалении: %v", err)
 		}
 	})
 
 	t.Run("Удаление несуществующей задачи", func(t *testing.T) {
-		err := tm.DeleteTask(999)
+		err := tm.DeleteTaskForUser(1, 999)
 		if err == nil {
 			t.Error("Ожидалась ошибка при удалении несуществующей задачи")
 		}
@@ -167,10 +168,10 @@ func TestDeleteTask(t *testing.T) {
 
 func TestGetTask(t *testing.T) {
 	tm := NewTaskManager()
-	id, _ := tm.AddTask("Тестовая задача", []string{"тест"})
+	id, _ := tm.AddTaskForUser(1, "Тестовая задача", []string{"тест"})
 
 	t.Run("Получение существующей задачи", func(t *testing.T) {
-		task, err := tm.GetTask(id)
+		task, err := tm.GetTaskForUser(1, id)
 		if err != nil {
 			t.Fatalf("Ошибка при получении задачи: %v", err)
 		}
@@ -186,7 +187,7 @@ func TestGetTask(t *testing.T) {
 	})
 
 	t.Run("Получение несуществующей задачи", func(t *testing.T) {
-		_, err := tm.GetTask(999)
+		_, err := tm.GetTaskForUser(1, 999)
 		if err == nil {
 			t.Error("Ожидалась ошибка при получении несуществующей задачи")
 		}
@@ -197,16 +198,16 @@ func TestGetAllTasks(t *testing.T) {
 	tm := NewTaskManager()
 
 	t.Run("Пустой список задач", func(t *testing.T) {
-		tasks := tm.GetAllTasks()
+		tasks, _ := tm.GetAllTasksForUser(1)
 		if len(tasks) != 0 {
 			t.Errorf("Ожидался пустой список, получено %d задач", len(tasks))
 		}
 	})
 
 	t.Run("Список с задачами", func(t *testing.T) {
-		tm.AddTask("Задача 1", []string{"тег1"})
-		tm.AddTask("Задача 2", []string{"тег2"})
-		tasks := tm.GetAllTasks()
+		tm.AddTaskForUser(1, "Задача 1", []string{"тег1"})
+		tm.AddTaskForUser(1, "Задача 2", []string{"тег2"})
+		tasks, _ := tm.GetAllTasksForUser(1)
 		if len(tasks) != 2 {
 			t.Errorf("Ожидалось 2 задачи, получено %d", len(tasks))
 		}
@@ -222,7 +223,7 @@ func TestConcurrentAccess(t *testing.T) {
 	for i := 0; i < count; i++ {
 		go func() {
 			defer wg.Done()
-			_, _ = tm.AddTask("Конкурентная задача", nil)
+			_, _ = tm.AddTaskForUser(1, "Конкурентная задача", nil)
 		}()
 	}
 	wg.Wait()
@@ -234,10 +235,10 @@ func TestConcurrentAccess(t *testing.T) {
 
 func TestToggleComplete(t *testing.T) {
 	tm := NewTaskManager()
-	id, _ := tm.AddTask("Тестовая задача", nil)
+	id, _ := tm.AddTaskForUser(1, "Тестовая задача", nil)
 
 	t.Run("Переключение с false на true", func(t *testing.T) {
-		task, err := tm.ToggleComplete(id)
+		task, _, err := tm.ToggleCompleteForUser(1, id)
 		if err != nil {
 			t.Fatalf("Ошибка при переключении статуса: %v", err)
 		}
@@ -247,7 +248,7 @@ func TestToggleComplete(t *testing.T) {
 	})
 
 	t.Run("Переключение с true на false", func(t *testing.T) {
-		task, err := tm.ToggleComplete(id)
+		task, _, err := tm.ToggleCompleteForUser(1, id)
 		if err != nil {
 			t.Fatalf("Ошибка при переключении статуса: %v", err)
 		}
@@ -257,17 +258,17 @@ func TestToggleComplete(t *testing.T) {
 	})
 
 	t.Run("Несуществующая задача", func(t *testing.T) {
-		_, err := tm.ToggleComplete(999)
+		_, _, err := tm.ToggleCompleteForUser(1, 999)
 		if err == nil {
 			t.Error("Ожидалась ошибка для несуществующего ID")
 		}
 	})
 
 	t.Run("Обновление времени модификации", func(t *testing.T) {
-		initialTask, _ := tm.GetTask(id)
+		initialTask, _ := tm.GetTaskForUser(1, id)
 		time.Sleep(10 * time.Millisecond)
 		
-		task, err := tm.ToggleComplete(id)
+		task, _, err := tm.ToggleCompleteForUser(1, id)
 		if err != nil {
 			t.Fatalf("Ошибка при переключении статуса: %v", err)
 		}
@@ -280,7 +281,7 @@ func TestToggleComplete(t *testing.T) {
 
 func TestConcurrentToggle(t *testing.T) {
 	tm := NewTaskManager()
-	id, _ := tm.AddTask("Конкурентное переключение", nil)
+	id, _ := tm.AddTaskForUser(1, "Конкурентное переключение", nil)
 	var wg sync.WaitGroup
 	iterations := 100
 
@@ -288,17 +289,73 @@ func TestConcurrentToggle(t *testing.T) {
 	for i := 0; i < iterations; i++ {
 		go func() {
 			defer wg.Done()
-			_, _ = tm.ToggleComplete(id)
+			_, _, _ = tm.ToggleCompleteForUser(1, id)
 		}()
 	}
 	wg.Wait()
 
-	task, _ := tm.GetTask(id)
+	task, _ := tm.GetTaskForUser(1, id)
 	if task.Completed != (iterations%2 == 1) {
 		t.Errorf("Неожиданное состояние задачи после %d переключений", iterations)
 	}
 }
 
+func TestTaskVersions(t *testing.T) {
+	tm := NewTaskManager()
+	id, _ := tm.AddTaskForUser(1, "Версии", nil)
+	task, _ := tm.GetTaskForUser(1, id)
+	if task.Version != 1 {
+		t.Fatalf("Версия новой задачи: %d", task.Version)
+	}
+
+	description := "Изменено в вебе"
+	if updated, _ := tm.UpdateTaskForUser(1, id, UpdateTaskRequest{Description: &description}); updated.Version != 2 {
+		t.Errorf("Версия после изменения: %d", updated.Version)
+	}
+
+	// Изменение с устаревшей версией не применяется
+	stale, fromBot := 1, "Изменено в боте"
+	_, err := tm.UpdateTaskForUser(1, id, UpdateTaskRequest{Description: &fromBot, Version: &stale})
+	var conflict *VersionConflictError
+	if !errors.As(err, &conflict) || !errors.Is(err, ErrConflict) || conflict.Current.Description != description ||
+		conflict.Current.Version != 2 || conflict.Expected != 1 {
+		t.Fatalf("Ожидался VersionConflictError, получено %v", err)
+	}
+	if task, _ := tm.GetTaskForUser(1, id); task.Description != description {
+		t.Errorf("Устаревшее изменение применено: %q", task.Description)
+	}
+
+	// Перезапись с текущей версией проходит; отметка выполнения тоже меняет версию
+	current := conflict.Current.Version
+	if updated, err := tm.UpdateTaskForUser(1, id, UpdateTaskRequest{Description: &fromBot, Version: &current}); err != nil || updated.Version != 3 {
+		t.Fatalf("Перезапись: %+v, %v", updated, err)
+	}
+	if toggled, _, _ := tm.ToggleCompleteForUser(1, id); toggled.Version != 4 {
+		t.Errorf("Версия после отметки выполнения: %d", toggled.Version)
+	}
+
+	// Из одновременных правок одной версии проходит только одна
+	version := 4
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	applied := 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := tm.UpdateTaskForUser(1, id, UpdateTaskRequest{Description: &description, Version: &version}); err == nil {
+				mu.Lock()
+				applied++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if applied != 1 {
+		t.Errorf("Применено %d одновременных правок, ожидалась одна", applied)
+	}
+}
+
 func TestMetrics(t *testing.T) {
 	AddTaskCount.Reset()
 	UpdateTaskCount.Reset()
@@ -307,7 +364,7 @@ func TestMetrics(t *testing.T) {
 	tm := NewTaskManager()
 
 	t.Run("Метрики AddTask", func(t *testing.T) {
-		_, err := tm.AddTask("Тестовая задача", nil)
+		_, err := tm.AddTaskForUser(1, "Тестовая задача", nil)
 		if err != nil {
 			t.Fatalf("Ошибка при добавлении задачи: %v", err)
 		}
@@ -316,7 +373,7 @@ func TestMetrics(t *testing.T) {
 			t.Errorf("AddTaskCount success = %v, want 1", got)
 		}
 
-		_, err = tm.AddTask("", nil)
+		_, err = tm.AddTaskForUser(1, "", nil)
 		if err == nil {
 			t.Error("Ожидалась ошибка при пустом описании")
 		}
@@ -327,10 +384,10 @@ func TestMetrics(t *testing.T) {
 	})
 
 	t.Run("Метрики UpdateTask", func(t *testing.T) {
-		id, _ := tm.AddTask("Тестовая задача", nil)
+		id, _ := tm.AddTaskForUser(1, "Тестовая задача", nil)
 
 		completed := true
-		_, err := tm.UpdateTask(id, UpdateTaskRequest{Completed: &completed})
+		_, err := tm.UpdateTaskForUser(1, id, UpdateTaskRequest{Completed: &completed})
 		if err != nil {
 			t.Fatalf("Ошибка при обновлении задачи: %v", err)
 		}
@@ -339,7 +396,7 @@ func TestMetrics(t *testing.T) {
 			t.Errorf("UpdateTaskCount success = %v, want 1", got)
 		}
 
-		_, err = tm.UpdateTask(999, UpdateTaskRequest{})
+		_, err = tm.UpdateTaskForUser(1, 999, UpdateTaskRequest{})
 		if err == nil {
 			t.Error("Ожидалась ошибка для несуществующего ID")
 		}
@@ -350,9 +407,9 @@ func TestMetrics(t *testing.T) {
 	})
 
 	t.Run("Метрики DeleteTask", func(t *testing.T) {
-		id, _ := tm.AddTask("Тестовая задача", nil)
+		id, _ := tm.AddTaskForUser(1, "Тестовая задача", nil)
 
-		err := tm.DeleteTask(id)
+		err := tm.DeleteTaskForUser(1, id)
 		if err != nil {
 			t.Fatalf("Ошибка при удалении задачи: %v", err)
 		}
@@ -361,7 +418,7 @@ func TestMetrics(t *testing.T) {
 			t.Errorf("DeleteTaskCount success = %v, want 1", got)
 		}
 
-		err = tm.DeleteTask(999)
+		err = tm.DeleteTaskForUser(1, 999)
 		if err == nil {
 			t.Error("Ожидалась ошибка для несуществующего ID")
 		}
@@ -372,9 +429,9 @@ func TestMetrics(t *testing.T) {
 	})
 
 	t.Run("Метрики ToggleComplete", func(t *testing.T) {
-		id, _ := tm.AddTask("Тестовая задача для toggle", nil)
+		id, _ := tm.AddTaskForUser(1, "Тестовая задача для toggle", nil)
 
-		_, err := tm.ToggleComplete(id)
+		_, _, err := tm.ToggleCompleteForUser(1, id)
 		if err != nil {
 			t.Fatalf("Ошибка при переключении статуса: %v", err)
 		}
@@ -387,7 +444,7 @@ func TestMetrics(t *testing.T) {
 			t.Errorf("UpdateTaskDuration не был записан")
 		}
 
-		_, err = tm.ToggleComplete(999)
+		_, _, err = tm.ToggleCompleteForUser(1, 999)
 		if err == nil {
 			t.Error("Ожидалась ошибка для несуществующего ID")
 		}
@@ -400,12 +457,12 @@ func TestMetrics(t *testing.T) {
 
 func TestFilterTasks(t *testing.T) {
 	tm := NewTaskManager()
-	tm.AddTask("Активная задача", nil)
-	completedID, _ := tm.AddTask("Выполненная задача", nil)
-	tm.ToggleComplete(completedID)
+	tm.AddTaskForUser(1, "Активная задача", nil)
+	completedID, _ := tm.AddTaskForUser(1, "Выполненная задача", nil)
+	tm.ToggleCompleteForUser(1, completedID)
 
 	t.Run("Фильтр Все", func(t *testing.T) {
-		tasks := tm.FilterTasks(nil)
+		tasks, _ := tm.FilterTasksForUser(1, nil)
 		if len(tasks) != 2 {
 			t.Errorf("Ожидалось 2 задачи, получено %d", len(tasks))
 		}
@@ -413,7 +470,7 @@ func TestFilterTasks(t *testing.T) {
 
 	t.Run("Фильтр Выполненные", func(t *testing.T) {
 		completed := true
-		tasks := tm.FilterTasks(&completed)
+		tasks, _ := tm.FilterTasksForUser(1, &completed)
 		if len(tasks) != 1 || !tasks[0].Completed {
 			t.Error("Ожидалась 1 выполненная задача")
 		}
@@ -421,7 +478,7 @@ func TestFilterTasks(t *testing.T) {
 
 	t.Run("Фильтр Активные", func(t *testing.T) {
 		active := false
-		tasks := tm.FilterTasks(&active)
+		tasks, _ := tm.FilterTasksForUser(1, &active)
 		if len(tasks) != 1 || tasks[0].Completed {
 			t.Error("Ожидалась 1 активная задача")
 		}
@@ -431,20 +488,20 @@ func TestFilterTasks(t *testing.T) {
 func TestFilterByPriority(t *testing.T) {
 	tm := NewTaskManager()
 	
-	lowID, _ := tm.AddTask("Низкий приоритет", nil)
-	medID, _ := tm.AddTask("Средний приоритет", nil)
-	highID, _ := tm.AddTask("Высокий приоритет", nil)
+	lowID, _ := tm.AddTaskForUser(1, "Низкий приоритет", nil)
+	medID, _ := tm.AddTaskForUser(1, "Средний приоритет", nil)
+	highID, _ := tm.AddTaskForUser(1, "Высокий приоритет", nil)
 	
 	lowPriority := PriorityLow
 	medPriority := PriorityMedium
 	highPriority := PriorityHigh
 	
-	tm.UpdateTask(lowID, UpdateTaskRequest{Priority: &lowPriority})
-	tm.UpdateTask(medID, UpdateTaskRequest{Priority: &medPriority})
-	tm.UpdateTask(highID, UpdateTaskRequest{Priority: &highPriority})
+	tm.UpdateTaskForUser(1, lowID, UpdateTaskRequest{Priority: &lowPriority})
+	tm.UpdateTaskForUser(1, medID, UpdateTaskRequest{Priority: &medPriority})
+	tm.UpdateTaskForUser(1, highID, UpdateTaskRequest{Priority: &highPriority})
 	
 	t.Run("Фильтр по высокому приоритету", func(t *testing.T) {
-		tasks := tm.FilterByPriority(PriorityHigh)
+		tasks, _ := tm.FilterByPriorityForUser(1, PriorityHigh)
 		if len(tasks) != 1 {
 			t.Fatalf("Ожидалась 1 задача с высоким приоритетом, получено %d", len(tasks))
 		}
@@ -454,7 +511,7 @@ func TestFilterByPriority(t *testing.T) {
 	})
 	
 	t.Run("Фильтр по среднему приоритету", func(t *testing.T) {
-		tasks := tm.FilterByPriority(PriorityMedium)
+		tasks, _ := tm.FilterByPriorityForUser(1, PriorityMedium)
 		if len(tasks) != 1 {
 			t.Fatalf("Ожидалась 1 задача со средним приоритетом, получено %d", len(tasks))
 		}
@@ -464,7 +521,7 @@ func TestFilterByPriority(t *testing.T) {
 	})
 	
 	t.Run("Фильтр по низкому приоритету", func(t *testing.T) {
-		tasks := tm.FilterByPriority(PriorityLow)
+		tasks, _ := tm.FilterByPriorityForUser(1, PriorityLow)
 		if len(tasks) != 1 {
 			t.Fatalf("Ожидалась 1 задача с низким приоритетом, получено %d", len(tasks))
 		}
@@ -474,7 +531,7 @@ func TestFilterByPriority(t *testing.T) {
 	})
 	
 	t.Run("Нет задач с указанным приоритетом", func(t *testing.T) {
-		tasks := tm.FilterByPriority("unknown")
+		tasks, _ := tm.FilterByPriorityForUser(1, "unknown")
 		if len(tasks) != 0 {
 			t.Errorf("Ожидалось 0 задач, получено %d", len(tasks))
 		}
@@ -484,34 +541,34 @@ func TestFilterByPriority(t *testing.T) {
 func TestFilterByTag(t *testing.T) {
 	tm := NewTaskManager()
 	
-	tm.AddTask("Задача 1", []string{"тег1"})
-	tm.AddTask("Задача 2", []string{"тег2"})
-	tm.AddTask("Задача 3", []string{"тег1", "тег2"})
-	tm.AddTask("Задача 4", []string{"ТеГ1"}) // Тест на регистронезависимость
+	tm.AddTaskForUser(1, "Задача 1", []string{"тег1"})
+	tm.AddTaskForUser(1, "Задача 2", []string{"тег2"})
+	tm.AddTaskForUser(1, "Задача 3", []string{"тег1", "тег2"})
+	tm.AddTaskForUser(1, "Задача 4", []string{"ТеГ1"}) // Тест на регистронезависимость
 	
 	t.Run("Фильтр по тегу1", func(t *testing.T) {
-		tasks := tm.FilterByTag("тег1")
+		tasks, _ := tm.FilterByTagForUser(1, "тег1")
 		if len(tasks) != 3 {
 			t.Errorf("Ожидалось 3 задачи с тегом 'тег1', получено %d", len(tasks))
 		}
 	})
 	
 	t.Run("Фильтр по тегу2", func(t *testing.T) {
-		tasks := tm.FilterByTag("тег2")
+		tasks, _ := tm.FilterByTagForUser(1, "тег2")
 		if len(tasks) != 2 {
 			t.Errorf("Ожидалось 2 задачи с тегом 'тег2', получено %d", len(tasks))
 		}
 	})
 	
 	t.Run("Фильтр по несуществующему тегу", func(t *testing.T) {
-		tasks := tm.FilterByTag("тег3")
+		tasks, _ := tm.FilterByTagForUser(1, "тег3")
 		if len(tasks) != 0 {
 			t.Errorf("Ожидалось 0 задач, получено %d", len(tasks))
 		}
 	})
 	
 	t.Run("Регистронезависимый поиск", func(t *testing.T) {
-		tasks := tm.FilterByTag("ТЕГ1")
+		tasks, _ := tm.FilterByTagForUser(1, "ТЕГ1")
 		if len(tasks) != 3 {
 			t.Errorf("Ожидалось 3 задачи при регистронезависимом поиске, получено %d", len(tasks))
 		}
@@ -541,8 +598,8 @@ func TestGetUpcomingTasks(t *testing.T) {
 	}
 	
 	for _, tt := range testTasks {
-		id, _ := tm.AddTask(tt.desc, nil)
-		tm.UpdateTask(id, UpdateTaskRequest{
+		id, _ := tm.AddTaskForUser(1, tt.desc, nil)
+		tm.UpdateTaskForUser(1, id, UpdateTaskRequest{
 			DueDate:  &tt.dueDate,
 			Priority: &tt.priority,
 			Completed: &tt.completed,
@@ -573,7 +630,7 @@ func TestGetUpcomingTasks(t *testing.T) {
 	
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := tm.GetUpcomingTasks(tt.days)
+			got, _ := tm.GetUpcomingTasksForUser(1, tt.days)
 			
 			if len(got) != len(tt.want) {
 				t.Errorf("GetUpcomingTasks() returned %d tasks (%v), want %d (%v)", 
@@ -599,44 +656,86 @@ func getTaskDescriptions(tasks []Task) []string {
 	return descs
 }
 
-func TestGetAllTags(t *testing.T) {
-    tm := NewTaskManager()
+func TestGetTagsForUser(t *testing.T) {
+	tm := NewTaskManager()
+
+	// Разные написания одного тега сводятся к первому встреченному
+	tm.AddTaskForUser(1, "Задача 1", []string{"тег1", "тег2"})
+	tm.AddTaskForUser(1, "Задача 2", []string{"ТЕГ2", "тег3"})
+	tm.AddTaskForUser(1, "Задача 3", []string{"ТеГ1", "тег4"})
+	tm.AddTaskForUser(2, "Чужая задача", []string{"тег1"})
 
-    // Добавляем задачи с тегами (включая разные регистры)
-    tm.AddTask("Задача 1", []string{"тег1", "тег2"})
-    tm.AddTask("Задача 2", []string{"ТЕГ2", "тег3"})
-    tm.AddTask("Задача 3", []string{"ТеГ1", "тег4"})
+	tags, err := tm.GetTagsForUser(1)
+	if err != nil {
+		t.Fatalf("Ошибка получения тегов: %v", err)
+	}
+	expected := []TagCount{{"тег1", 2}, {"тег2", 2}, {"тег3", 1}, {"тег4", 1}}
+	if len(tags) != len(expected) {
+		t.Fatalf("Ожидалось %v, получено %v", expected, tags)
+	}
+	for i := range expected {
+		if tags[i] != expected[i] {
+			t.Errorf("Ожидалось %v, получено %v", expected, tags)
+			break
+		}
+	}
 
-    // Получаем все уникальные теги (должны быть нормализованы)
-    tags := tm.GetAllTags()
+	task, _ := tm.GetTaskForUser(1, 2)
+	if task.Tags[0] != "тег2" {
+		t.Errorf("Тег должен храниться в общем написании, получено %v", task.Tags)
+	}
+}
 
-    // Ожидаемые теги (в нижнем регистре)
-    expectedTags := []string{"тег1", "тег2", "тег3", "тег4"}
-    
-    // Проверка количества
-    if len(tags) != len(expectedTags) {
-        t.Fatalf("Ожидалось %d уникальных тегов, получено %d: %v", 
-            len(expectedTags), len(tags), tags)
-    }
+func TestRenameMergeDeleteTags(t *testing.T) {
+	tm := NewTaskManager()
+	tm.AddTaskForUser(1, "Отчет", []string{"работа", "срочно"})
+	tm.AddTaskForUser(1, "Звонок", []string{"Звонки", "офис", "работа"})
+	tm.AddTaskForUser(1, "Покупки", []string{"дом"})
+	tm.AddTaskForUser(2, "Чужая", []string{"работа"})
 
-    // Проверка наличия всех тегов
-    tagMap := make(map[string]bool)
-    for _, tag := range tags {
-        tagMap[tag] = true
-    }
+	if n, err := tm.RenameTagForUser(1, "РАБОТА", "Работа"); err != nil || n != 2 {
+		t.Fatalf("Переименование: %d, %v", n, err)
+	}
+	if task, _ := tm.GetTaskForUser(1, 2); task.Tags[2] != "Работа" {
+		t.Errorf("Тег не переименован: %v", task.Tags)
+	}
+	if other, _ := tm.GetTaskForUser(2, 4); other.Tags[0] != "работа" {
+		t.Errorf("Переименование не должно задевать других пользователей: %v", other.Tags)
+	}
+	if _, err := tm.RenameTagForUser(1, "работа", "дом"); !errors.Is(err, ErrConflict) {
+		t.Errorf("Переименование в занятое имя: ожидалась ErrConflict, получено %v", err)
+	}
+	if _, err := tm.RenameTagForUser(1, "отпуск", "каникулы"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидалась ErrNotFound, получено %v", err)
+	}
+	if _, err := tm.RenameTagForUser(1, "работа", "  "); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Ожидалась ErrInvalid, получено %v", err)
+	}
 
-    for _, expectedTag := range expectedTags {
-        if !tagMap[expectedTag] {
-            t.Errorf("Отсутствует ожидаемый тег: %s", expectedTag)
-        }
-    }
+	// Объединенный тег встает на место первого из объединяемых
+	if n, err := tm.MergeTagsForUser(1, []string{"офис", "звонки"}, "работа"); err != nil || n != 1 {
+		t.Fatalf("Объединение: %d, %v", n, err)
+	}
+	if task, _ := tm.GetTaskForUser(1, 2); len(task.Tags) != 1 || task.Tags[0] != "работа" {
+		t.Errorf("Ожидался один тег работа, получено %v", task.Tags)
+	}
+	if task, _ := tm.GetTaskForUser(1, 1); task.Tags[0] != "работа" || task.Tags[1] != "срочно" {
+		t.Errorf("Задача с итоговым тегом должна получить его написание: %v", task.Tags)
+	}
+	if _, err := tm.MergeTagsForUser(1, []string{"отпуск"}, "работа"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Ожидалась ErrNotFound, получено %v", err)
+	}
 
-    // Проверка сортировки
-    for i := 0; i < len(tags)-1; i++ {
-        if tags[i] > tags[i+1] {
-            t.Errorf("Теги не отсортированы: %s > %s", tags[i], tags[i+1])
-        }
-    }
+	if n, err := tm.DeleteTagForUser(1, "Срочно"); err != nil || n != 1 {
+		t.Fatalf("Удаление: %d, %v", n, err)
+	}
+	if _, err := tm.DeleteTagForUser(1, "срочно"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Повторное удаление: ожидалась ErrNotFound, получено %v", err)
+	}
+	tags, _ := tm.GetTagsForUser(1)
+	if len(tags) != 2 || tags[0] != (TagCount{"работа", 2}) || tags[1] != (TagCount{"дом", 1}) {
+		t.Errorf("Неожиданные теги: %v", tags)
+	}
 }
 
 func TestFilterByDateRange(t *testing.T) {
@@ -659,9 +758,9 @@ func TestFilterByDateRange(t *testing.T) {
     // Добавляем тестовые задачи
     for _, td := range testDates {
         dueDate := now.AddDate(0, 0, td.dateOffset)
-        id, _ := tm.AddTask(td.desc, nil)
+        id, _ := tm.AddTaskForUser(1, td.desc, nil)
         
-        _, err := tm.UpdateTask(id, UpdateTaskRequest{
+        _, err := tm.UpdateTaskForUser(1, id, UpdateTaskRequest{
             DueDate: &dueDate,
         })
         if err != nil {
@@ -674,7 +773,7 @@ func TestFilterByDateRange(t *testing.T) {
     end := now.AddDate(0, 0, 5)
 
     // Фильтруем
-    filtered := tm.FilterByDateRange(start, end)
+    filtered, _ := tm.FilterByDateRangeForUser(1, start, end)
 
     // Проверяем количество
     expectedCount := 3
@@ -696,7 +795,7 @@ func TestFilterByDateRange_Empty(t *testing.T) {
     start := time.Now()
     end := start.AddDate(0, 0, 7)
     
-    filtered := tm.FilterByDateRange(start, end)
+    filtered, _ := tm.FilterByDateRangeForUser(1, start, end)
     
     if len(filtered) != 0 {
         t.Errorf("Ожидалось 0 задач, получено %d", len(filtered))
@@ -0,0 +1,196 @@
+package manager
+
+import (
+	"context"
+	"crypto/rand"
+	"strings"
+	"time"
+
+	"todo-app/internal/logger"
+)
+
+// LinkCodeTTL - сколько живет код привязки Telegram
+const LinkCodeTTL = 10 * time.Minute
+
+// Без похожих символов (0/O, 1/I/L), чтобы код было легко перепечатать
+const linkCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+const linkCodeLen = 8
+
+// LinkCode - одноразовый код привязки Telegram к учетной записи
+type LinkCode struct {
+	CodeHash  string
+	UserID    int
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// normalizeLinkCode убирает пробелы и дефисы, приводит к верхнему регистру
+func normalizeLinkCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, code)
+}
+
+// generateCode возвращает случайный код из linkCodeAlphabet в виде ABCD-EFGH.
+// Хешировать его нужно после normalizeLinkCode.
+func generateCode() (string, error) {
+	// Байты вне последнего полного цикла алфавита отбрасываем, чтобы не было смещения
+	limit := byte(256 - 256%len(linkCodeAlphabet))
+	code := make([]byte, 0, linkCodeLen)
+	buf := make([]byte, linkCodeLen)
+	for len(code) < linkCodeLen {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if b < limit && len(code) < linkCodeLen {
+				code = append(code, linkCodeAlphabet[int(b)%len(linkCodeAlphabet)])
+			}
+		}
+	}
+	return string(code[:4]) + "-" + string(code[4:]), nil
+}
+
+// CreateLinkCode выдает новый код для команды /link в боте.
+// Прежние коды пользователя перестают действовать.
+func (um *UserManager) CreateLinkCode(userID int) (string, *LinkCode, error) {
+	display, err := generateCode()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now().UTC()
+	linkCode := &LinkCode{
+		CodeHash:  hashToken(normalizeLinkCode(display)),
+		UserID:    userID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(LinkCodeTTL),
+	}
+
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	if um.storage != nil {
+		if err := um.storage.CreateLinkCode(linkCode); err != nil {
+			return "", nil, err
+		}
+	} else {
+		for hash, c := range um.linkCodes {
+			if c.UserID == userID {
+				delete(um.linkCodes, hash)
+			}
+		}
+		um.linkCodes[linkCode.CodeHash] = *linkCode
+	}
+	return display, linkCode, nil
+}
+
+// LinkTelegram привязывает Telegram ID к владельцу кода. Задачи, созданные
+// ботом для этого Telegram до привязки, переходят к учетной записи.
+// Возвращает пользователя и число перенесенных задач.
+func (um *UserManager) LinkTelegram(code string, telegramID int64) (*User, int, error) {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	codeHash := hashToken(normalizeLinkCode(code))
+	now := time.Now().UTC()
+
+	var userID int
+	if um.storage != nil {
+		id, err := um.storage.ConsumeLinkCode(codeHash, now)
+		if err != nil {
+			return nil, 0, err
+		}
+		userID = id
+	} else {
+		c, exists := um.linkCodes[codeHash]
+		delete(um.linkCodes, codeHash)
+		if !exists || !c.ExpiresAt.After(now) {
+			return nil, 0, NotFound("код привязки не найден или истек")
+		}
+		userID = c.UserID
+	}
+
+	user, err := um.getUserByID(userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if user.TelegramID == telegramID {
+		return user, 0, nil
+	}
+	if user.TelegramID != 0 {
+		return nil, 0, Conflict("к учетной записи уже привязан другой Telegram, сначала отвяжите его")
+	}
+
+	moved := 0
+	if um.storage != nil {
+		moved, err = um.storage.LinkTelegram(userID, telegramID)
+		if err != nil {
+			return nil, 0, err
+		}
+	} else {
+		// В памяти задачи хранит TaskManager, поэтому переносить нечего
+		for id, other := range um.users {
+			if other.TelegramID != telegramID || id == userID {
+				continue
+			}
+			if other.PasswordHash != "" {
+				return nil, 0, Conflict("этот Telegram уже привязан к другой учетной записи")
+			}
+			delete(um.users, id)
+		}
+	}
+	user.TelegramID = telegramID
+	user.UpdatedAt = time.Now()
+
+	logger.Info(context.Background(), "Telegram привязан к учетной записи",
+		"userID", userID, "telegramID", telegramID, "movedTasks", moved)
+	return user, moved, nil
+}
+
+// UnlinkTelegram отвязывает Telegram от учетной записи. Аккаунт без пароля
+// существует только в Telegram, отвязать его нельзя.
+func (um *UserManager) UnlinkTelegram(userID int) error {
+	um.mu.Lock()
+	defer um.mu.Unlock()
+
+	user, err := um.getUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.TelegramID == 0 {
+		return NotFound("Telegram не привязан")
+	}
+	if user.PasswordHash == "" {
+		return Forbidden("у аккаунта нет пароля: после отвязки войти в него будет невозможно")
+	}
+
+	telegramID := user.TelegramID
+	user.TelegramID = 0
+	user.UpdatedAt = time.Now()
+	if um.storage != nil {
+		if err := um.storage.UpdateUser(user); err != nil {
+			return err
+		}
+	} else {
+		um.users[user.ID] = user
+	}
+
+	logger.Info(context.Background(), "Telegram отвязан от учетной записи", "userID", userID, "telegramID", telegramID)
+	return nil
+}
+
+// getUserByID ищет пользователя по ID; вызывается под um.mu
+func (um *UserManager) getUserByID(userID int) (*User, error) {
+	if um.storage != nil {
+		return um.storage.GetUserByID(userID)
+	}
+	if user, exists := um.users[userID]; exists {
+		return user, nil
+	}
+	return nil, NotFound("пользователь не найден")
+}
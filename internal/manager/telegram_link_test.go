@@ -0,0 +1,74 @@
+package manager
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLinkTelegram(t *testing.T) {
+	um := NewUserManager(nil)
+	user, _ := um.Register("carol", "", "password123")
+	botUser, _ := um.GetOrCreateUserByTelegramID(555)
+
+	code, _, err := um.CreateLinkCode(user.ID)
+	if err != nil {
+		t.Fatalf("Ошибка создания кода: %v", err)
+	}
+	if len(code) != 9 || code[4] != '-' {
+		t.Errorf("Ожидался код вида ABCD-EFGH, получено %q", code)
+	}
+
+	// Регистр и дефис при вводе не важны
+	linked, _, err := um.LinkTelegram(strings.ToLower(strings.ReplaceAll(code, "-", "")), 555)
+	if err != nil {
+		t.Fatalf("Ошибка привязки: %v", err)
+	}
+	if linked.ID != user.ID || linked.TelegramID != 555 {
+		t.Errorf("Telegram должен быть привязан к %d, получено %+v", user.ID, linked)
+	}
+	if got, _ := um.GetUserByTelegramID(555); got.ID != user.ID {
+		t.Errorf("Telegram 555 должен указывать на учетную запись %d, получено %d", user.ID, got.ID)
+	}
+	if _, err := um.GetUserByID(botUser.ID); err == nil {
+		t.Error("Отдельный пользователь бота должен быть объединен с учетной записью")
+	}
+
+	t.Run("Код одноразовый", func(t *testing.T) {
+		if _, _, err := um.LinkTelegram(code, 555); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Ожидался ErrNotFound для использованного кода, получено %v", err)
+		}
+	})
+
+	t.Run("Истекший код", func(t *testing.T) {
+		other, _ := um.Register("dave", "", "password123")
+		code, linkCode, _ := um.CreateLinkCode(other.ID)
+		linkCode.ExpiresAt = time.Now().Add(-time.Second)
+		um.linkCodes[linkCode.CodeHash] = *linkCode
+		if _, _, err := um.LinkTelegram(code, 777); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Ожидался ErrNotFound для истекшего кода, получено %v", err)
+		}
+	})
+
+	t.Run("Telegram другой учетной записи", func(t *testing.T) {
+		other, _ := um.Register("erin", "", "password123")
+		code, _, _ := um.CreateLinkCode(other.ID)
+		if _, _, err := um.LinkTelegram(code, 555); !errors.Is(err, ErrConflict) {
+			t.Errorf("Ожидался ErrConflict, получено %v", err)
+		}
+	})
+
+	t.Run("Отвязка", func(t *testing.T) {
+		if err := um.UnlinkTelegram(user.ID); err != nil {
+			t.Fatalf("Ошибка отвязки: %v", err)
+		}
+		if err := um.UnlinkTelegram(user.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Повторная отвязка: ожидался ErrNotFound, получено %v", err)
+		}
+		botOnly, _ := um.GetOrCreateUserByTelegramID(999)
+		if err := um.UnlinkTelegram(botOnly.ID); !errors.Is(err, ErrForbidden) {
+			t.Errorf("Аккаунт без пароля нельзя отвязать, получено %v", err)
+		}
+	})
+}
@@ -0,0 +1,118 @@
+package manager
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"todo-app/internal/logger"
+)
+
+// DefaultTrashRetention - сколько задача лежит в корзине до окончательного
+// удаления, если не задан другой срок
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// findDeletedTask ищет задачу в корзине по ID без проверки доступа.
+// Вызывающий должен удерживать tm.mu.
+func (tm *TaskManager) findDeletedTask(id int) (Task, error) {
+	if tm.storage != nil {
+		task, err := tm.storage.GetDeletedTaskByID(id)
+		if err != nil {
+			return Task{}, err
+		}
+		return *task, nil
+	}
+	task, exists := tm.trash[id]
+	if !exists {
+		return Task{}, NotFound("задачи с ID %d нет в корзине", id)
+	}
+	return task, nil
+}
+
+// GetTrashForUser возвращает задачи из корзины, которые пользователь видел
+// бы в списках, недавно удаленные первыми
+func (tm *TaskManager) GetTrashForUser(userID int) ([]Task, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	if tm.storage != nil {
+		return tm.storage.GetDeletedTasks(userID)
+	}
+	visible := tm.visibleTasks(userID)
+	tasks := []Task{}
+	for _, task := range tm.trash {
+		if visible(task) {
+			tasks = append(tasks, task)
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		if !tasks[i].DeletedAt.Equal(*tasks[j].DeletedAt) {
+			return tasks[i].DeletedAt.After(*tasks[j].DeletedAt)
+		}
+		return tasks[i].ID > tasks[j].ID
+	})
+	return tasks, nil
+}
+
+// RestoreTaskForUser возвращает задачу из корзины, если роль пользователя
+// в ее проекте позволяет менять задачи. Подзадачи, комментарии и вложения
+// возвращаются вместе с ней.
+func (tm *TaskManager) RestoreTaskForUser(userID, id int) (*Task, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	task, err := tm.findDeletedTask(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := tm.checkTaskAccess(userID, task, ActionEdit); err != nil {
+		return nil, err
+	}
+
+	if tm.storage != nil {
+		restored, err := tm.storage.RestoreTask(task.UserID, id)
+		if err != nil {
+			return nil, err
+		}
+		task = *restored
+	} else {
+		delete(tm.trash, id)
+		task.DeletedAt = nil
+		task.UpdatedAt = time.Now()
+		task.Version++
+		tm.tasks[id] = task
+		tm.syncTask(id)
+	}
+	logger.Info(context.Background(), "Задача восстановлена из корзины", "taskID", id, "userID", userID)
+	tm.notifyChanged(task)
+	tm.recordEvent(tm.newEvent(userID, id, EventRestored, nil))
+	return &task, nil
+}
+
+// PurgeTrash окончательно удаляет задачи, попавшие в корзину раньше before,
+// вместе с подзадачами, напоминаниями, комментариями и вложениями.
+// История задач остается. Возвращает число удаленных задач.
+func (tm *TaskManager) PurgeTrash(before time.Time) (int, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	purged := 0
+	if tm.storage != nil {
+		var err error
+		if purged, err = tm.storage.PurgeDeletedTasks(before); err != nil {
+			return 0, err
+		}
+	} else {
+		for id, task := range tm.trash {
+			if task.DeletedAt.Before(before) {
+				delete(tm.trash, id)
+				tm.syncTask(id)
+				purged++
+			}
+		}
+	}
+	if purged > 0 {
+		logger.Info(context.Background(), "Корзина очищена", "count", purged)
+	}
+	return purged, nil
+}
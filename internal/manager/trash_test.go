@@ -0,0 +1,71 @@
+package manager
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTrash(t *testing.T) {
+	tm := NewTaskManager()
+	alice := User{ID: 1, Username: "alice"}
+	bob := User{ID: 2, Username: "bob"}
+	carol := User{ID: 3, Username: "carol"}
+
+	family, _ := tm.CreateProjectForUser(alice.ID, CreateProjectRequest{Name: "Семья"})
+	tm.ShareProjectForUser(alice.ID, family.ID, bob, RoleEditor)
+	tm.ShareProjectForUser(alice.ID, family.ID, carol, RoleViewer)
+	task, _ := tm.CreateTaskForUser(alice.ID, "Купить продукты", nil, UpdateTaskRequest{ProjectID: &family.ID})
+	personal, _ := tm.AddTaskForUser(alice.ID, "Личное", nil)
+
+	if err := tm.DeleteTaskForUser(bob.ID, task.ID); err != nil {
+		t.Fatalf("Удаление редактором: %v", err)
+	}
+	if _, err := tm.GetTaskForUser(alice.ID, task.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Задача из корзины: ожидался ErrNotFound, получено %v", err)
+	}
+	if tasks, _ := tm.GetAllTasksForUser(alice.ID); len(tasks) != 1 || tasks[0].ID != personal {
+		t.Errorf("Задача из корзины в списке: %+v", tasks)
+	}
+	tm.DeleteTaskForUser(alice.ID, personal)
+
+	// Корзину общего проекта видят участники, личную - только автор
+	if trash, _ := tm.GetTrashForUser(alice.ID); len(trash) != 2 || trash[0].ID != personal || trash[0].DeletedAt == nil {
+		t.Errorf("Корзина автора: %+v", trash)
+	}
+	if trash, _ := tm.GetTrashForUser(carol.ID); len(trash) != 1 || trash[0].ID != task.ID {
+		t.Errorf("Корзина читателя: %+v", trash)
+	}
+	if _, err := tm.RestoreTaskForUser(carol.ID, task.ID); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Восстановление читателем: ожидался ErrForbidden, получено %v", err)
+	}
+	if _, err := tm.RestoreTaskForUser(bob.ID, personal); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Восстановление чужой задачи: ожидался ErrForbidden, получено %v", err)
+	}
+
+	restored, err := tm.RestoreTaskForUser(bob.ID, task.ID)
+	if err != nil || restored.DeletedAt != nil || restored.ProjectID != family.ID {
+		t.Fatalf("Восстановление редактором: %+v, %v", restored, err)
+	}
+	if events, _ := tm.GetTaskEvents(alice.ID, task.ID); events[0].Type != EventRestored || events[1].Type != EventDeleted {
+		t.Errorf("История восстановления: %+v", events)
+	}
+
+	// Задача удаленного проекта восстанавливается во Входящие автора
+	tm.DeleteTaskForUser(alice.ID, task.ID)
+	tm.DeleteProjectForUser(alice.ID, family.ID)
+	restored, err = tm.RestoreTaskForUser(alice.ID, task.ID)
+	if inbox, _ := tm.InboxForUser(alice.ID); err != nil || restored.ProjectID != inbox.ID {
+		t.Errorf("Восстановление после удаления проекта: %+v, %v", restored, err)
+	}
+
+	if purged, _ := tm.PurgeTrash(time.Now().Add(-time.Hour)); purged != 0 {
+		t.Errorf("Очищены свежие задачи: %d", purged)
+	}
+	if purged, _ := tm.PurgeTrash(time.Now().Add(time.Minute)); purged != 1 {
+		t.Errorf("Очищено %d задач, ожидалась 1", purged)
+	}
+	if _, err := tm.RestoreTaskForUser(alice.ID, personal); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Восстановление после очистки: ожидался ErrNotFound, получено %v", err)
+	}
+}
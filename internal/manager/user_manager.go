@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -16,6 +17,14 @@ type UserManager struct {
 	storage Storage
 	users   map[int]*User // 🆕 Добавляем in-memory хранилище
 	nextID  int           // 🆕 Счетчик для in-memory режима
+
+	sessions  map[string]Session  // Сессии in-memory режима по хешу токена
+	linkCodes map[string]LinkCode // Коды привязки Telegram по хешу кода
+
+	// Неудачные попытки входа по логину в нижнем регистре; в памяти
+	// процесса и в режиме SQLite
+	loginFailures       map[string]loginFailure
+	loginFailuresPruned time.Time
 }
 
 func NewUserManager(storage Storage) *UserManager {
@@ -23,6 +32,10 @@ func NewUserManager(storage Storage) *UserManager {
 		storage: storage,
 		users:   make(map[int]*User), // 🆕 Инициализируем
 		nextID:  1,                   // 🆕 Начинаем с 1
+
+		sessions:      make(map[string]Session),
+		linkCodes:     make(map[string]LinkCode),
+		loginFailures: make(map[string]loginFailure),
 	}
 }
 
@@ -79,7 +92,7 @@ func (um *UserManager) GetUserByDeviceID(deviceID string) (*User, error) {
 			return user, nil
 		}
 	}
-	return nil, fmt.Errorf("пользователь не найден")
+	return nil, NotFound("пользователь не найден")
 }
 
 // UpdateUser обновляет данные пользователя
@@ -93,7 +106,7 @@ func (um *UserManager) UpdateUser(user *User) error {
 
 	// In-memory обновление
 	if _, exists := um.users[user.ID]; !exists {
-		return fmt.Errorf("пользователь не найден")
+		return NotFound("пользователь не найден")
 	}
 
 	user.UpdatedAt = time.Now()
@@ -114,7 +127,7 @@ func (um *UserManager) GetUserByID(userID int) (*User, error) {
 	if user, exists := um.users[userID]; exists {
 		return user, nil
 	}
-	return nil, fmt.Errorf("пользователь не найден")
+	return nil, NotFound("пользователь не найден")
 }
 
 // GetOrCreateUserByTelegramID - новый метод для получения или создания пользователя по Telegram ID
@@ -128,6 +141,9 @@ func (um *UserManager) GetOrCreateUserByTelegramID(telegramID int64) (*User, err
 		if err == nil {
 			return user, nil
 		}
+		if !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
 		
 		// Если не найден - создаем нового
 		deviceID := fmt.Sprintf("telegram_%d", telegramID)
@@ -185,5 +201,5 @@ func (um *UserManager) GetUserByTelegramID(telegramID int64) (*User, error) {
 			return user, nil
 		}
 	}
-	return nil, fmt.Errorf("пользователь не найден")
+	return nil, NotFound("пользователь не найден")
 }
\ No newline at end of file
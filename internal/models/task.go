@@ -11,6 +11,11 @@ type Task struct {
 
 // Новая структура только для HTTP-запроса
 type CreateTaskRequest struct {
-	Description string   `json:"description"`
-	Tags        []string `json:"tags,omitempty"` // omitempty - поле необязательное
-}
\ No newline at end of file
+	Description string     `json:"description"`
+	Tags        []string   `json:"tags,omitempty"` // omitempty - поле необязательное
+	Priority    string     `json:"priority,omitempty"`   // low, medium (по умолчанию) или high
+	DueDate     *time.Time `json:"due_date,omitempty"`
+	Recurrence  string     `json:"recurrence,omitempty"` // правило RRULE, например FREQ=WEEKLY;BYDAY=MO
+	ProjectID   *int       `json:"project_id,omitempty"` // по умолчанию - Входящие
+	AssigneeID  *int       `json:"assignee_id,omitempty"` // исполнитель - владелец или редактор проекта
+}
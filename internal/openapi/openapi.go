@@ -0,0 +1,320 @@
+// Package openapi содержит спецификацию OpenAPI 3 веб-сервера и JSON API
+// и средства проверки ответов обработчиков на соответствие ей.
+//
+// Спецификация пишется вручную в openapi.json и встраивается в бинарник.
+// Тесты сверяют ее с роутером cmd/todo-app и прогоняют через валидатор
+// настоящие запросы и ответы, поэтому расхождение кода и описания
+// ломает сборку.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+// Spec возвращает документ в том виде, в каком он отдается по /openapi.json
+func Spec() []byte {
+	return spec
+}
+
+// Handler отдает спецификацию
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write(spec)
+	})
+}
+
+// Document - подмножество OpenAPI 3.0, которым пользуется спецификация
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+type Components struct {
+	Schemas       map[string]*Schema      `json:"schemas"`
+	Parameters    map[string]*Parameter   `json:"parameters"`
+	RequestBodies map[string]*RequestBody `json:"requestBodies"`
+	Responses     map[string]*Response    `json:"responses"`
+}
+
+// PathItem - операции одного пути по HTTP-методу (GET, POST, ...)
+type PathItem struct {
+	Parameters []*Parameter
+	Operations map[string]*Operation
+}
+
+func (p *PathItem) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	p.Operations = make(map[string]*Operation)
+	for key, value := range raw {
+		switch key {
+		case "parameters":
+			if err := json.Unmarshal(value, &p.Parameters); err != nil {
+				return err
+			}
+		case "get", "put", "post", "delete", "patch", "head", "options":
+			var op Operation
+			if err := json.Unmarshal(value, &op); err != nil {
+				return fmt.Errorf("%s: %v", key, err)
+			}
+			p.Operations[strings.ToUpper(key)] = &op
+		case "summary", "description":
+		default:
+			return fmt.Errorf("неподдерживаемое поле пути %q", key)
+		}
+	}
+	return nil
+}
+
+type Operation struct {
+	Summary     string               `json:"summary"`
+	Tags        []string             `json:"tags"`
+	Parameters  []*Parameter         `json:"parameters"`
+	RequestBody *RequestBody         `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Ref      string  `json:"$ref"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Ref      string               `json:"$ref"`
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Ref         string               `json:"$ref"`
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema - подмножество JSON Schema, которое понимает Validate
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Nullable             bool               `json:"nullable"`
+	Enum                 []interface{}      `json:"enum"`
+	Minimum              *float64           `json:"minimum"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+}
+
+// Route - операция спецификации
+type Route struct {
+	Method    string
+	Path      string
+	Operation *Operation
+	// Parameters - параметры пути и операции вместе
+	Parameters []*Parameter
+}
+
+// Load разбирает встроенную спецификацию
+func Load() (*Document, error) {
+	return Parse(spec)
+}
+
+// Parse разбирает спецификацию и подставляет ссылки на параметры, тела
+// запросов и ответы. Ссылки на схемы проверяются, но остаются ссылками:
+// схемы бывают рекурсивными.
+func Parse(data []byte) (*Document, error) {
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("некорректная спецификация: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("ожидается OpenAPI 3, указано %q", doc.OpenAPI)
+	}
+
+	for path, item := range doc.Paths {
+		if err := doc.resolveParameters(item.Parameters); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		for method, op := range item.Operations {
+			where := method + " " + path
+			if err := doc.resolveParameters(op.Parameters); err != nil {
+				return nil, fmt.Errorf("%s: %v", where, err)
+			}
+			if op.RequestBody != nil && op.RequestBody.Ref != "" {
+				body, ok := doc.Components.RequestBodies[refName(op.RequestBody.Ref, "requestBodies")]
+				if !ok {
+					return nil, fmt.Errorf("%s: неизвестная ссылка %s", where, op.RequestBody.Ref)
+				}
+				op.RequestBody = body
+			}
+			if len(op.Responses) == 0 {
+				return nil, fmt.Errorf("%s: не описаны ответы", where)
+			}
+			for status, resp := range op.Responses {
+				if resp.Ref == "" {
+					continue
+				}
+				target, ok := doc.Components.Responses[refName(resp.Ref, "responses")]
+				if !ok {
+					return nil, fmt.Errorf("%s %s: неизвестная ссылка %s", where, status, resp.Ref)
+				}
+				op.Responses[status] = target
+			}
+		}
+	}
+
+	if err := doc.checkSchemaRefs(); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+func (d *Document) resolveParameters(params []*Parameter) error {
+	for i, p := range params {
+		if p.Ref == "" {
+			continue
+		}
+		target, ok := d.Components.Parameters[refName(p.Ref, "parameters")]
+		if !ok {
+			return fmt.Errorf("неизвестная ссылка %s", p.Ref)
+		}
+		params[i] = target
+	}
+	return nil
+}
+
+// checkSchemaRefs проверяет, что все $ref на схемы указывают на существующие схемы
+func (d *Document) checkSchemaRefs() error {
+	var check func(where string, s *Schema) error
+	check = func(where string, s *Schema) error {
+		if s == nil {
+			return nil
+		}
+		if s.Ref != "" {
+			if _, ok := d.Components.Schemas[refName(s.Ref, "schemas")]; !ok {
+				return fmt.Errorf("%s: неизвестная ссылка %s", where, s.Ref)
+			}
+		}
+		for name, prop := range s.Properties {
+			if err := check(where+"."+name, prop); err != nil {
+				return err
+			}
+		}
+		return check(where+"[]", s.Items)
+	}
+
+	for name, s := range d.Components.Schemas {
+		if err := check(name, s); err != nil {
+			return err
+		}
+	}
+	for _, route := range d.Routes() {
+		where := route.Method + " " + route.Path
+		for _, p := range route.Parameters {
+			if err := check(where+" "+p.Name, p.Schema); err != nil {
+				return err
+			}
+		}
+		if body := route.Operation.RequestBody; body != nil {
+			for mediaType, content := range body.Content {
+				if err := check(where+" "+mediaType, content.Schema); err != nil {
+					return err
+				}
+			}
+		}
+		for status, resp := range route.Operation.Responses {
+			for _, content := range resp.Content {
+				if err := check(where+" "+status, content.Schema); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// refName возвращает имя из "#/components/<kind>/<name>"
+func refName(ref, kind string) string {
+	return strings.TrimPrefix(ref, "#/components/"+kind+"/")
+}
+
+// methodOrder - порядок методов одного пути в Routes
+var methodOrder = map[string]int{"GET": 0, "POST": 1, "PUT": 2, "PATCH": 3, "DELETE": 4}
+
+// Routes возвращает все операции, упорядоченные по пути и методу
+func (d *Document) Routes() []Route {
+	var routes []Route
+	for path, item := range d.Paths {
+		for method, op := range item.Operations {
+			params := append(append([]*Parameter{}, item.Parameters...), op.Parameters...)
+			routes = append(routes, Route{Method: method, Path: path, Operation: op, Parameters: params})
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return methodOrder[routes[i].Method] < methodOrder[routes[j].Method]
+	})
+	return routes
+}
+
+// Find ищет операцию для запроса. Как и chi, конкретный сегмент пути
+// предпочитается шаблону: /tasks/filter/date, а не /tasks/filter/{status}.
+func (d *Document) Find(method, path string) (Route, bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	best, bestScore := Route{}, -1
+	for _, route := range d.Routes() {
+		if route.Method != method {
+			continue
+		}
+		template := strings.Split(strings.Trim(route.Path, "/"), "/")
+		if len(template) != len(segments) {
+			continue
+		}
+		score := 0
+		for i, part := range template {
+			if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+				if segments[i] == "" {
+					score = -1
+					break
+				}
+				continue
+			}
+			if part != segments[i] {
+				score = -1
+				break
+			}
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = route, score
+		}
+	}
+	return best, bestScore >= 0
+}
+
+// Schema возвращает схему из components.schemas
+func (d *Document) Schema(name string) (*Schema, bool) {
+	s, ok := d.Components.Schemas[name]
+	return s, ok
+}
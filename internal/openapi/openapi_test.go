@@ -0,0 +1,162 @@
+package openapi
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadEmbeddedSpec(t *testing.T) {
+	doc, err := Load()
+	if err != nil {
+		t.Fatalf("Спецификация не разбирается: %v", err)
+	}
+	for _, route := range doc.Routes() {
+		if route.Operation.Summary == "" {
+			t.Errorf("%s %s: нет summary", route.Method, route.Path)
+		}
+		// Каждый параметр пути должен быть описан
+		for _, part := range strings.Split(route.Path, "/") {
+			if !strings.HasPrefix(part, "{") {
+				continue
+			}
+			name := strings.Trim(part, "{}")
+			found := false
+			for _, p := range route.Parameters {
+				if p.In == "path" && p.Name == name {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("%s %s: не описан параметр пути %s", route.Method, route.Path, name)
+			}
+		}
+	}
+}
+
+func TestParseRejectsBrokenRefs(t *testing.T) {
+	tests := map[string]string{
+		"schema": `{"openapi": "3.0.3", "paths": {"/a": {"get": {"responses": {"200": {"description": "ok",
+			"content": {"application/json": {"schema": {"$ref": "#/components/schemas/Missing"}}}}}}}}}`,
+		"response":   `{"openapi": "3.0.3", "paths": {"/a": {"get": {"responses": {"200": {"$ref": "#/components/responses/Missing"}}}}}}`,
+		"parameter":  `{"openapi": "3.0.3", "paths": {"/a/{id}": {"parameters": [{"$ref": "#/components/parameters/Missing"}]}}}`,
+		"responses":  `{"openapi": "3.0.3", "paths": {"/a": {"get": {"summary": "без ответов"}}}}`,
+		"version":    `{"openapi": "2.0", "paths": {}}`,
+		"path field": `{"openapi": "3.0.3", "paths": {"/a": {"trace": {}}}}`,
+	}
+	for name, spec := range tests {
+		if _, err := Parse([]byte(spec)); err == nil {
+			t.Errorf("%s: ожидалась ошибка", name)
+		}
+	}
+}
+
+func TestFindPrefersLiteralSegments(t *testing.T) {
+	doc, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		method, path, want string
+	}{
+		{"GET", "/tasks/filter/date", "/tasks/filter/date"},
+		{"GET", "/tasks/filter/active", "/tasks/filter/{status}"},
+		{"POST", "/tasks/7/subtasks", "/tasks/{taskID}/subtasks"},
+		{"PATCH", "/api/v1/tasks/7", "/api/v1/tasks/{id}"},
+		{"GET", "/", "/"},
+	}
+	for _, tt := range tests {
+		route, ok := doc.Find(tt.method, tt.path)
+		if !ok || route.Path != tt.want {
+			t.Errorf("Find(%s %s) = %q, %v; ожидалось %q", tt.method, tt.path, route.Path, ok, tt.want)
+		}
+	}
+	if _, ok := doc.Find("PUT", "/api/v1/tasks"); ok {
+		t.Error("PUT /api/v1/tasks не описан и не должен находиться")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	doc, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, _ := doc.Schema("Task")
+	now := time.Now().Format(time.RFC3339)
+	valid := `{"id": 1, "user_id": 2, "description": "A", "created_at": "` + now + `", "updated_at": "` + now + `",
+		"completed": false, "priority": "high", "due_date": null, "tags": [], "project_id": 3, "version": 1}`
+	if err := doc.ValidateJSON(task, []byte(valid)); err != nil {
+		t.Errorf("Корректная задача не прошла проверку: %v", err)
+	}
+
+	broken := map[string]string{
+		"лишнее поле":     strings.Replace(valid, `"tags": []`, `"tags": [], "color": "red"`, 1),
+		"нет поля":        strings.Replace(valid, `"completed": false, `, "", 1),
+		"не тот тип":      strings.Replace(valid, `"id": 1`, `"id": "1"`, 1),
+		"дробное":         strings.Replace(valid, `"id": 1`, `"id": 1.5`, 1),
+		"вне enum":        strings.Replace(valid, `"high"`, `"urgent"`, 1),
+		"формат даты":     strings.Replace(valid, `"created_at": "`+now+`"`, `"created_at": "вчера"`, 1),
+		"null без права":  strings.Replace(valid, `"tags": []`, `"tags": null`, 1),
+		"элемент массива": strings.Replace(valid, `"tags": []`, `"tags": [1]`, 1),
+	}
+	for name, body := range broken {
+		if err := doc.ValidateJSON(task, []byte(body)); err == nil {
+			t.Errorf("%s: ожидалась ошибка", name)
+		}
+	}
+}
+
+func TestValidateResponseStatus(t *testing.T) {
+	doc, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	jsonHeader := http.Header{"Content-Type": []string{"application/json; charset=utf-8"}}
+	errorBody := []byte(`{"error": {"code": "not_found", "message": "задача не найдена"}}`)
+
+	if err := doc.ValidateResponse("GET", "/api/v1/tasks/5", 404, jsonHeader, errorBody); err != nil {
+		t.Errorf("Ошибка должна подходить под default: %v", err)
+	}
+	if err := doc.ValidateResponse("DELETE", "/api/v1/tasks/5", 200, jsonHeader, nil); err == nil {
+		t.Error("Неописанный успешный статус должен быть ошибкой")
+	}
+	if err := doc.ValidateResponse("GET", "/api/v1/tasks/5", 404, http.Header{"Content-Type": []string{"text/plain"}}, errorBody); err == nil {
+		t.Error("Неописанный тип ответа должен быть ошибкой")
+	}
+	if err := doc.ValidateResponse("GET", "/api/v1/tasks/5", 404, jsonHeader, []byte(`{"message": "нет"}`)); err == nil {
+		t.Error("Ошибка вне конверта должна быть ошибкой")
+	}
+}
+
+func TestMatchType(t *testing.T) {
+	doc, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	type base struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	type detail struct {
+		base
+		Internal string `json:"-"`
+	}
+	type drifted struct {
+		Code  string `json:"code"`
+		Extra string `json:"extra,omitempty"`
+	}
+
+	if err := doc.MatchType("TagCount", struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}{}); err != nil {
+		t.Errorf("Совпадающий тип: %v", err)
+	}
+	if err := doc.MatchType("ErrorDetail", detail{}); err != nil {
+		t.Errorf("Встроенная структура: %v", err)
+	}
+	if err := doc.MatchType("ErrorDetail", drifted{}); err == nil {
+		t.Error("Расхождение полей должно быть ошибкой")
+	}
+}
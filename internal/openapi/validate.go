@@ -0,0 +1,406 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Validate проверяет значение, разобранное encoding/json, по схеме
+func (d *Document) Validate(s *Schema, value interface{}) error {
+	return d.validate(s, value, "$")
+}
+
+// ValidateJSON разбирает data и проверяет по схеме
+func (d *Document) ValidateJSON(s *Schema, data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("некорректный JSON: %v", err)
+	}
+	return d.Validate(s, value)
+}
+
+func (d *Document) validate(s *Schema, value interface{}, at string) error {
+	if s == nil {
+		return nil
+	}
+	if s.Ref != "" {
+		target, ok := d.Components.Schemas[refName(s.Ref, "schemas")]
+		if !ok {
+			return fmt.Errorf("%s: неизвестная ссылка %s", at, s.Ref)
+		}
+		return d.validate(target, value, at)
+	}
+
+	if value == nil {
+		if s.Nullable || s.Type == "" {
+			return nil
+		}
+		return fmt.Errorf("%s: null, ожидается %s", at, s.Type)
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, allowed := range s.Enum {
+			if allowed == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: значение %v не входит в %v", at, value, s.Enum)
+		}
+	}
+
+	switch s.Type {
+	case "":
+		return nil
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return typeError(at, s.Type, value)
+		}
+		return checkFormat(at, s.Format, str)
+	case "integer", "number":
+		n, ok := value.(float64)
+		if !ok {
+			return typeError(at, s.Type, value)
+		}
+		if s.Type == "integer" && n != math.Trunc(n) {
+			return fmt.Errorf("%s: %v - не целое число", at, n)
+		}
+		if s.Minimum != nil && n < *s.Minimum {
+			return fmt.Errorf("%s: %v меньше минимума %v", at, n, *s.Minimum)
+		}
+		return nil
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return typeError(at, s.Type, value)
+		}
+		return nil
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return typeError(at, s.Type, value)
+		}
+		for i, item := range items {
+			if err := d.validate(s.Items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return typeError(at, s.Type, value)
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s: нет обязательного поля %q", at, name)
+			}
+		}
+		for _, name := range sortedKeys(obj) {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fmt.Errorf("%s: поле %q не описано в спецификации", at, name)
+				}
+				continue
+			}
+			if err := d.validate(prop, obj[name], at+"."+name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("%s: неподдерживаемый тип схемы %q", at, s.Type)
+}
+
+func typeError(at, want string, value interface{}) error {
+	return fmt.Errorf("%s: %T, ожидается %s", at, value, want)
+}
+
+func checkFormat(at, format, value string) error {
+	var err error
+	switch format {
+	case "date-time":
+		_, err = time.Parse(time.RFC3339Nano, value)
+	case "date":
+		_, err = time.Parse("2006-01-02", value)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %q не соответствует формату %s", at, value, format)
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ValidateRequest проверяет параметры строки запроса и тело запроса,
+// который обработчик принял. Отклоненные запросы не проверяются:
+// тесты нарочно отправляют некорректные данные.
+func (d *Document) ValidateRequest(r *http.Request, body []byte) error {
+	route, ok := d.Find(r.Method, r.URL.Path)
+	if !ok {
+		return fmt.Errorf("%s %s: операция не описана в спецификации", r.Method, r.URL.Path)
+	}
+	where := route.Method + " " + route.Path
+
+	query := r.URL.Query()
+	declared := make(map[string]*Parameter)
+	for _, p := range route.Parameters {
+		if p.In != "query" {
+			continue
+		}
+		declared[p.Name] = p
+		if p.Required && query.Get(p.Name) == "" {
+			return fmt.Errorf("%s: нет обязательного параметра %q", where, p.Name)
+		}
+	}
+	for name, values := range query {
+		p, ok := declared[name]
+		if !ok {
+			return fmt.Errorf("%s: параметр %q не описан в спецификации", where, name)
+		}
+		for _, value := range values {
+			if err := d.validate(p.Schema, queryValue(p.Schema, d, value), name); err != nil {
+				return fmt.Errorf("%s: %v", where, err)
+			}
+		}
+	}
+
+	if len(body) == 0 {
+		if route.Operation.RequestBody != nil && route.Operation.RequestBody.Required {
+			return fmt.Errorf("%s: нет обязательного тела запроса", where)
+		}
+		return nil
+	}
+	if route.Operation.RequestBody == nil {
+		return fmt.Errorf("%s: тело запроса не описано в спецификации", where)
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	content, ok := route.Operation.RequestBody.Content[mediaType]
+	if !ok {
+		return fmt.Errorf("%s: тип тела %q не описан в спецификации", where, mediaType)
+	}
+
+	var value interface{}
+	switch mediaType {
+	case "application/json":
+		if err := json.Unmarshal(body, &value); err != nil {
+			return fmt.Errorf("%s: некорректный JSON: %v", where, err)
+		}
+	case "application/x-www-form-urlencoded":
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return fmt.Errorf("%s: некорректная форма: %v", where, err)
+		}
+		fields := make(map[string]interface{})
+		for name := range form {
+			fields[name] = form.Get(name)
+		}
+		value = fields
+	default:
+		return nil
+	}
+	if err := d.validate(content.Schema, value, "body"); err != nil {
+		return fmt.Errorf("%s: %v", where, err)
+	}
+	return nil
+}
+
+// queryValue приводит строку запроса к типу схемы параметра
+func queryValue(s *Schema, d *Document, value string) interface{} {
+	for s != nil && s.Ref != "" {
+		s = d.Components.Schemas[refName(s.Ref, "schemas")]
+	}
+	if s == nil {
+		return value
+	}
+	switch s.Type {
+	case "boolean":
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	case "integer", "number":
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	}
+	return value
+}
+
+// ValidateResponse проверяет статус, тип содержимого и тело ответа.
+// Успешные статусы должны быть описаны явно, ошибки могут попадать
+// под "default".
+func (d *Document) ValidateResponse(method, path string, status int, header http.Header, body []byte) error {
+	route, ok := d.Find(method, path)
+	if !ok {
+		return fmt.Errorf("%s %s: операция не описана в спецификации", method, path)
+	}
+	where := fmt.Sprintf("%s %s -> %d", route.Method, route.Path, status)
+
+	resp, ok := route.Operation.Responses[strconv.Itoa(status)]
+	if !ok && status >= 400 {
+		resp, ok = route.Operation.Responses["default"]
+	}
+	if !ok {
+		return fmt.Errorf("%s: статус не описан в спецификации", where)
+	}
+	if len(resp.Content) == 0 {
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	content, ok := resp.Content[mediaType]
+	if !ok {
+		return fmt.Errorf("%s: тип ответа %q не описан в спецификации", where, mediaType)
+	}
+	if mediaType != "application/json" {
+		return nil
+	}
+	if err := d.ValidateJSON(content.Schema, body); err != nil {
+		return fmt.Errorf("%s: %v", where, err)
+	}
+	return nil
+}
+
+// MatchType сверяет поля схемы с JSON-полями Go-типа v: схема должна
+// описывать ровно те поля, которые тип читает или пишет. Так новое поле
+// структуры без описания в спецификации не проходит незамеченным, даже
+// если тесты его не заполняют.
+func (d *Document) MatchType(schemaName string, v interface{}) error {
+	s, ok := d.Components.Schemas[schemaName]
+	if !ok {
+		return fmt.Errorf("схема %s не найдена", schemaName)
+	}
+
+	fields := jsonFields(reflect.TypeOf(v))
+	var missing, extra []string
+	for name := range fields {
+		if _, ok := s.Properties[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	for name := range s.Properties {
+		if !fields[name] {
+			extra = append(extra, name)
+		}
+	}
+	if len(missing) > 0 || len(extra) > 0 {
+		sort.Strings(missing)
+		sort.Strings(extra)
+		return fmt.Errorf("схема %s расходится с %T: не описаны %v, лишние %v", schemaName, v, missing, extra)
+	}
+	return nil
+}
+
+// jsonFields - имена полей, которые encoding/json использует для типа t,
+// с учетом встроенных структур и тегов
+func jsonFields(t reflect.Type) map[string]bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	fields := make(map[string]bool)
+	var embedded []reflect.Type
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			embedded = append(embedded, f.Type)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = true
+	}
+	// Поля внешней структуры перекрывают одноименные поля встроенной
+	for _, et := range embedded {
+		for name := range jsonFields(et) {
+			fields[name] = true
+		}
+	}
+	return fields
+}
+
+// Checker возвращает middleware, которое сверяет каждый обслуженный запрос
+// и ответ со спецификацией и сообщает о расхождениях в report. prefix
+// добавляется к пути, если обработчик смонтирован не в корне (/api/v1).
+// Предназначено для тестов: ответ целиком держится в памяти.
+func (d *Document) Checker(prefix string, report func(error)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body []byte
+			if r.Body != nil {
+				body, _ = io.ReadAll(r.Body)
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+
+			rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			checked := r.Clone(r.Context())
+			checked.URL.Path = prefix + r.URL.Path
+			// Ответы роутера на несуществующие пути и методы описывать незачем
+			if _, ok := d.Find(r.Method, checked.URL.Path); !ok &&
+				(rec.status == http.StatusNotFound || rec.status == http.StatusMethodNotAllowed) {
+				return
+			}
+			if rec.status < 400 {
+				if err := d.ValidateRequest(checked, body); err != nil {
+					report(err)
+				}
+			}
+			if err := d.ValidateResponse(r.Method, checked.URL.Path, rec.status, w.Header(), rec.body.Bytes()); err != nil {
+				report(err)
+			}
+		})
+	}
+}
+
+// recorder запоминает статус и тело ответа, передавая их дальше
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
@@ -0,0 +1,93 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"todo-app/internal/manager"
+)
+
+// Notifier доставляет напоминание пользователю
+type Notifier interface {
+	Notify(ctx context.Context, user *manager.User, reminder manager.Reminder) error
+}
+
+// ErrNoRecipient - доставить некуда; повторять такую отправку бессмысленно
+var ErrNoRecipient = errors.New("некуда отправить напоминание")
+
+// commentHint - последняя строка уведомления: по ней бот узнает задачу,
+// когда пользователь отвечает на уведомление комментарием
+const commentHint = "💬 Ответьте на это сообщение, чтобы прокомментировать задачу #"
+
+// reminderText - текст уведомления для пользователя
+func reminderText(reminder manager.Reminder) string {
+	title := "⏰ Напоминание"
+	if reminder.Type == manager.ReminderTypeAssignment {
+		title = "👤 Вам назначена задача"
+	}
+	return fmt.Sprintf("%s\n\n%s\n\n%s%d", title, reminder.Message, commentHint, reminder.TaskID)
+}
+
+// NotificationTaskID возвращает ID задачи из текста уведомления, на
+// которое ответил пользователь; false - это не уведомление о задаче
+func NotificationTaskID(text string) (int, bool) {
+	i := strings.LastIndex(text, commentHint)
+	if i < 0 {
+		return 0, false
+	}
+	id, err := strconv.Atoi(strings.TrimSpace(text[i+len(commentHint):]))
+	if err != nil || id < 1 {
+		return 0, false
+	}
+	return id, true
+}
+
+// TelegramNotifier отправляет напоминания в личный чат с ботом.
+// ID личного чата совпадает с Telegram ID пользователя.
+type TelegramNotifier struct {
+	api *tgbotapi.BotAPI
+}
+
+func NewTelegramNotifier(api *tgbotapi.BotAPI) *TelegramNotifier {
+	return &TelegramNotifier{api: api}
+}
+
+func (n *TelegramNotifier) Notify(ctx context.Context, user *manager.User, reminder manager.Reminder) error {
+	if user.TelegramID == 0 {
+		return fmt.Errorf("%w: Telegram не привязан", ErrNoRecipient)
+	}
+	// Без ParseMode: текст задачи может содержать символы разметки
+	msg := tgbotapi.NewMessage(user.TelegramID, reminderText(reminder))
+	_, err := n.api.Send(msg)
+	return err
+}
+
+// LogNotifier пишет напоминания в w (по умолчанию stdout).
+// Нужен для разработки без бота и для тестов.
+type LogNotifier struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewLogNotifier(w io.Writer) *LogNotifier {
+	if w == nil {
+		w = os.Stdout
+	}
+	return &LogNotifier{w: w}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, user *manager.User, reminder manager.Reminder) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	_, err := fmt.Fprintf(n.w, "[reminder] user=%d task=%d reminder=%d: %s\n",
+		user.ID, reminder.TaskID, reminder.ID, reminder.Message)
+	return err
+}
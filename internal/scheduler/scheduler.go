@@ -0,0 +1,163 @@
+// Package scheduler отправляет напоминания, время которых наступило.
+//
+// Планировщик запускают и веб-сервер, и бот. Каждое напоминание сначала
+// атомарно забирается (ReminderManager.ClaimDue), поэтому при двух
+// процессах над одной базой оно отправляется только одним из них.
+package scheduler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"todo-app/internal/logger"
+	"todo-app/internal/manager"
+)
+
+const (
+	// MaxAttempts - после стольких неудачных попыток напоминание остается failed
+	MaxAttempts = 5
+	// Пауза перед повтором: 1, 2, 4, 8... минут, но не больше часа
+	retryBaseDelay = time.Minute
+	retryMaxDelay  = time.Hour
+
+	defaultPollInterval = time.Minute
+	defaultBatchSize    = 50
+	// Чтобы при ошибках базы цикл не крутился вхолостую
+	minWait = time.Second
+)
+
+// RetryDelay - пауза перед следующей попыткой после attempts неудачных
+func RetryDelay(attempts int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempts && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	return delay
+}
+
+type Scheduler struct {
+	reminders *manager.ReminderManager
+	users     *manager.UserManager
+	notifier  Notifier
+	owner     string
+
+	// PollInterval - как часто проверять базу, даже если ближайших напоминаний нет:
+	// другой процесс мог добавить напоминание или упасть, не отметив отправку
+	PollInterval time.Duration
+	BatchSize    int
+}
+
+// New создает планировщик. name попадает в метку захвата вместе с PID,
+// чтобы в базе было видно, какой процесс отправляет напоминание.
+func New(name string, reminders *manager.ReminderManager, users *manager.UserManager, notifier Notifier) *Scheduler {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return &Scheduler{
+		reminders:    reminders,
+		users:        users,
+		notifier:     notifier,
+		owner:        fmt.Sprintf("%s:%d:%s", name, os.Getpid(), hex.EncodeToString(suffix)),
+		PollInterval: defaultPollInterval,
+		BatchSize:    defaultBatchSize,
+	}
+}
+
+// Run отправляет напоминания до отмены ctx. Между проходами спит до
+// ближайшего срабатывания, но не дольше PollInterval; новое напоминание
+// в этом процессе будит его сразу.
+func (s *Scheduler) Run(ctx context.Context) {
+	logger.Info(ctx, "Планировщик напоминаний запущен", "owner", s.owner)
+
+	for {
+		now := time.Now()
+		s.RunOnce(ctx, now)
+
+		wait := s.PollInterval
+		next, ok, err := s.reminders.NextDue(now)
+		if err != nil {
+			logger.Error(ctx, err, "Ошибка поиска ближайшего напоминания")
+		} else if ok && time.Until(next) < wait {
+			wait = time.Until(next)
+		}
+		if wait < minWait {
+			wait = minWait
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			logger.Info(ctx, "Планировщик напоминаний остановлен")
+			return
+		case <-s.reminders.Changes():
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// RunOnce забирает и отправляет напоминания, время которых наступило к now.
+// Возвращает число успешно отправленных.
+func (s *Scheduler) RunOnce(ctx context.Context, now time.Time) int {
+	reminders, err := s.reminders.ClaimDue(s.owner, now, s.BatchSize)
+	if err != nil {
+		logger.Error(ctx, err, "Ошибка выборки напоминаний")
+		return 0
+	}
+
+	sent := 0
+	for _, reminder := range reminders {
+		if s.deliver(ctx, reminder, now) {
+			sent++
+		}
+	}
+	return sent
+}
+
+// deliver отправляет одно забранное напоминание и сохраняет результат
+func (s *Scheduler) deliver(ctx context.Context, reminder manager.Reminder, now time.Time) bool {
+	err := s.send(ctx, reminder)
+	if err == nil {
+		if err := s.reminders.MarkSent(s.owner, &reminder); err != nil {
+			logger.Error(ctx, err, "Напоминание отправлено, но статус не сохранен", "reminderID", reminder.ID)
+			return false
+		}
+		logger.Info(ctx, "📨 Напоминание отправлено", "reminderID", reminder.ID, "userID", reminder.UserID)
+		return true
+	}
+
+	var retryAt *time.Time
+	if !errors.Is(err, ErrNoRecipient) && reminder.Attempts < MaxAttempts {
+		at := now.Add(RetryDelay(reminder.Attempts))
+		retryAt = &at
+	}
+	logger.Error(ctx, err, "Ошибка отправки напоминания",
+		"reminderID", reminder.ID, "attempt", reminder.Attempts, "retryAt", retryAt)
+	if markErr := s.reminders.MarkFailed(s.owner, &reminder, err, retryAt); markErr != nil {
+		logger.Error(ctx, markErr, "Ошибка сохранения статуса напоминания", "reminderID", reminder.ID)
+	}
+	return false
+}
+
+func (s *Scheduler) send(ctx context.Context, reminder manager.Reminder) error {
+	user, err := s.users.GetUserByID(reminder.UserID)
+	if err != nil {
+		return err
+	}
+	settings, err := s.reminders.GetSettings(reminder.UserID)
+	if err != nil {
+		return err
+	}
+	if reminder.Channel == "telegram" && !settings.TelegramNotifications {
+		return fmt.Errorf("%w: уведомления в Telegram отключены", ErrNoRecipient)
+	}
+	return s.notifier.Notify(ctx, user, reminder)
+}
@@ -0,0 +1,171 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"todo-app/internal/manager"
+)
+
+// flakyNotifier падает failures раз, затем доставляет
+type flakyNotifier struct {
+	failures  int
+	delivered []int
+}
+
+func (n *flakyNotifier) Notify(ctx context.Context, user *manager.User, reminder manager.Reminder) error {
+	if n.failures > 0 {
+		n.failures--
+		return errors.New("сеть недоступна")
+	}
+	n.delivered = append(n.delivered, reminder.ID)
+	return nil
+}
+
+func setup(t *testing.T, telegramID int64) (*manager.ReminderManager, *manager.UserManager, *manager.Reminder) {
+	t.Helper()
+	tm := manager.NewTaskManager()
+	rm := manager.NewReminderManager(tm)
+	um := manager.NewUserManager(nil)
+
+	user, err := um.CreateUser("device", telegramID)
+	if err != nil {
+		t.Fatalf("Ошибка создания пользователя: %v", err)
+	}
+	taskID, _ := tm.AddTaskForUser(user.ID, "Позвонить врачу", nil)
+	trigger := time.Now().Add(time.Hour)
+	reminder, err := rm.CreateReminder(user.ID, taskID, manager.CreateReminderRequest{TriggerTime: &trigger, Message: "Позвонить врачу"})
+	if err != nil {
+		t.Fatalf("Ошибка создания напоминания: %v", err)
+	}
+	return rm, um, reminder
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := map[int]time.Duration{
+		1:  time.Minute,
+		2:  2 * time.Minute,
+		4:  8 * time.Minute,
+		7:  time.Hour,
+		50: time.Hour,
+	}
+	for attempts, want := range tests {
+		if got := RetryDelay(attempts); got != want {
+			t.Errorf("RetryDelay(%d) = %v, ожидалось %v", attempts, got, want)
+		}
+	}
+}
+
+func TestRunOnceSendsDueReminders(t *testing.T) {
+	rm, um, reminder := setup(t, 42)
+	var out bytes.Buffer
+	s := New("test", rm, um, NewLogNotifier(&out))
+	ctx := context.Background()
+
+	if sent := s.RunOnce(ctx, time.Now()); sent != 0 {
+		t.Errorf("Напоминание отправлено раньше времени")
+	}
+
+	later := reminder.TriggerTime.Add(time.Second)
+	if sent := s.RunOnce(ctx, later); sent != 1 {
+		t.Fatalf("Ожидалось 1 отправленное напоминание, получено %d", sent)
+	}
+	if !strings.Contains(out.String(), "Позвонить врачу") {
+		t.Errorf("Текст напоминания не попал в лог: %q", out.String())
+	}
+
+	got, _ := rm.GetReminder(reminder.UserID, reminder.ID)
+	if got.Status != manager.ReminderSent || got.SentAt == nil || got.Attempts != 1 {
+		t.Errorf("Ожидался статус sent после одной попытки, получено %+v", got)
+	}
+	if sent := s.RunOnce(ctx, later.Add(time.Hour)); sent != 0 {
+		t.Error("Отправленное напоминание не должно уходить повторно")
+	}
+	if _, ok, _ := rm.NextDue(later); ok {
+		t.Error("После отправки ждать нечего")
+	}
+}
+
+func TestRunOnceRetriesWithBackoff(t *testing.T) {
+	rm, um, reminder := setup(t, 42)
+	notifier := &flakyNotifier{failures: 2}
+	s := New("test", rm, um, notifier)
+	ctx := context.Background()
+
+	now := reminder.TriggerTime.Add(time.Second)
+	s.RunOnce(ctx, now)
+	got, _ := rm.GetReminder(reminder.UserID, reminder.ID)
+	if got.Status != manager.ReminderFailed || got.LastError == "" || got.NextAttemptAt == nil {
+		t.Fatalf("Ожидался failed с временем повтора, получено %+v", got)
+	}
+	if !got.NextAttemptAt.Equal(now.Add(RetryDelay(1))) {
+		t.Errorf("Повтор через %v, ожидалось через %v", got.NextAttemptAt.Sub(now), RetryDelay(1))
+	}
+	if next, ok, _ := rm.NextDue(now); !ok || !next.Equal(*got.NextAttemptAt) {
+		t.Errorf("Ближайшее время должно совпадать с повтором, получено %v", next)
+	}
+
+	// До времени повтора напоминание не трогаем
+	if s.RunOnce(ctx, now.Add(30*time.Second)); len(notifier.delivered) != 0 || notifier.failures != 1 {
+		t.Error("Повтор до наступления времени")
+	}
+
+	now = *got.NextAttemptAt
+	s.RunOnce(ctx, now)
+	got, _ = rm.GetReminder(reminder.UserID, reminder.ID)
+	now = *got.NextAttemptAt
+	if sent := s.RunOnce(ctx, now); sent != 1 {
+		t.Fatalf("Третья попытка должна пройти")
+	}
+	got, _ = rm.GetReminder(reminder.UserID, reminder.ID)
+	if got.Status != manager.ReminderSent || got.Attempts != 3 || got.LastError != "" {
+		t.Errorf("Ожидался sent после трех попыток, получено %+v", got)
+	}
+}
+
+func TestRunOnceGivesUpWithoutTelegram(t *testing.T) {
+	rm, um, reminder := setup(t, 0)
+	s := New("test", rm, um, NewTelegramNotifier(nil))
+
+	s.RunOnce(context.Background(), reminder.TriggerTime)
+	got, _ := rm.GetReminder(reminder.UserID, reminder.ID)
+	if got.Status != manager.ReminderFailed || got.NextAttemptAt != nil {
+		t.Errorf("Без Telegram повторять бессмысленно, получено %+v", got)
+	}
+}
+
+func TestClaimIsExclusive(t *testing.T) {
+	rm, um, reminder := setup(t, 42)
+	now := reminder.TriggerTime
+
+	first, _ := rm.ClaimDue("first", now, 10)
+	second, _ := rm.ClaimDue("second", now, 10)
+	if len(first) != 1 || len(second) != 0 {
+		t.Fatalf("Напоминание должен забрать только один процесс: %d и %d", len(first), len(second))
+	}
+	if err := rm.MarkSent("second", &first[0]); !errors.Is(err, manager.ErrConflict) {
+		t.Errorf("Чужой захват нельзя завершить, получено %v", err)
+	}
+
+	// Процесс упал, не отметив результат: после аренды напоминание снова доступно
+	s := New("test", rm, um, &flakyNotifier{})
+	if sent := s.RunOnce(context.Background(), now.Add(manager.ReminderClaimLease)); sent != 1 {
+		t.Error("После истечения аренды напоминание должно отправиться")
+	}
+}
+
+func TestNotificationTaskID(t *testing.T) {
+	text := reminderText(manager.Reminder{TaskID: 12, Type: manager.ReminderTypeAssignment, Message: "#12 Купить продукты"})
+	if id, ok := NotificationTaskID(text); !ok || id != 12 {
+		t.Errorf("Из уведомления %q получено %d, %v", text, id, ok)
+	}
+	for _, text := range []string{"Привет", "#12 Купить продукты", commentHint + "abc"} {
+		if id, ok := NotificationTaskID(text); ok {
+			t.Errorf("%q: не уведомление, получено %d", text, id)
+		}
+	}
+}
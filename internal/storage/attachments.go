@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"database/sql"
+
+	"todo-app/internal/manager"
+)
+
+const attachmentColumns = "id, task_id, user_id, filename, content_type, size, sha256, created_at"
+
+func scanAttachment(row rowScanner) (*manager.Attachment, error) {
+	var a manager.Attachment
+	if err := row.Scan(&a.ID, &a.TaskID, &a.UserID, &a.Filename, &a.ContentType, &a.Size, &a.SHA256, &a.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (s *SQLiteStorage) CreateAttachment(a *manager.Attachment) (int, error) {
+	result, err := s.db.Exec(`
+	INSERT INTO attachments (task_id, user_id, filename, content_type, size, sha256, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		a.TaskID, a.UserID, a.Filename, a.ContentType, a.Size, a.SHA256, a.CreatedAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// GetAttachment ищет вложение по ID; права проверяет AttachmentManager
+func (s *SQLiteStorage) GetAttachment(id int) (*manager.Attachment, error) {
+	a, err := scanAttachment(s.db.QueryRow("SELECT "+attachmentColumns+" FROM attachments WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, manager.NotFound("вложение с ID %d не найдено", id)
+	}
+	return a, err
+}
+
+// GetAttachments возвращает вложения задачи в порядке загрузки
+func (s *SQLiteStorage) GetAttachments(taskID int) ([]manager.Attachment, error) {
+	rows, err := s.db.Query("SELECT "+attachmentColumns+" FROM attachments WHERE task_id = ? ORDER BY id", taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := []manager.Attachment{}
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, *a)
+	}
+	return attachments, rows.Err()
+}
+
+func (s *SQLiteStorage) DeleteAttachment(id int) error {
+	result, err := s.db.Exec("DELETE FROM attachments WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return manager.NotFound("вложение с ID %d не найдено", id)
+	}
+	return nil
+}
+
+// CountAttachmentsByHash - сколько вложений ссылается на файл с этим хешем
+func (s *SQLiteStorage) CountAttachmentsByHash(hash string) (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM attachments WHERE sha256 = ?", hash).Scan(&count)
+	return count, err
+}
+
+// GetAttachmentHashes возвращает хеши всех файлов, на которые есть ссылки
+func (s *SQLiteStorage) GetAttachmentHashes() ([]string, error) {
+	rows, err := s.db.Query("SELECT DISTINCT sha256 FROM attachments")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hashes := []string{}
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, rows.Err()
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"database/sql"
+
+	"todo-app/internal/manager"
+)
+
+// commentColumns - колонки комментария с именем автора из users
+const commentColumns = "c.id, c.task_id, c.user_id, COALESCE(u.username, ''), c.body, c.created_at, c.updated_at"
+
+const commentFrom = " FROM comments c LEFT JOIN users u ON u.id = c.user_id"
+
+func scanComment(row rowScanner) (*manager.Comment, error) {
+	var c manager.Comment
+	if err := row.Scan(&c.ID, &c.TaskID, &c.UserID, &c.Username, &c.Body, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (s *SQLiteStorage) CreateComment(c *manager.Comment) (int, error) {
+	result, err := s.db.Exec(`
+	INSERT INTO comments (task_id, user_id, body, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?)`,
+		c.TaskID, c.UserID, c.Body, c.CreatedAt, c.UpdatedAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// GetComment ищет комментарий по ID; права проверяет CommentManager
+func (s *SQLiteStorage) GetComment(id int) (*manager.Comment, error) {
+	c, err := scanComment(s.db.QueryRow("SELECT "+commentColumns+commentFrom+" WHERE c.id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, manager.NotFound("комментарий с ID %d не найден", id)
+	}
+	return c, err
+}
+
+// GetComments возвращает комментарии задачи от старых к новым
+func (s *SQLiteStorage) GetComments(taskID int) ([]manager.Comment, error) {
+	rows, err := s.db.Query("SELECT "+commentColumns+commentFrom+" WHERE c.task_id = ? ORDER BY c.created_at, c.id", taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []manager.Comment{}
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, *c)
+	}
+	return comments, rows.Err()
+}
+
+// UpdateComment сохраняет текст комментария
+func (s *SQLiteStorage) UpdateComment(c *manager.Comment) error {
+	result, err := s.db.Exec("UPDATE comments SET body = ?, updated_at = ? WHERE id = ?", c.Body, c.UpdatedAt, c.ID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return manager.NotFound("комментарий с ID %d не найден", c.ID)
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) DeleteComment(id int) error {
+	result, err := s.db.Exec("DELETE FROM comments WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return manager.NotFound("комментарий с ID %d не найден", id)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"todo-app/internal/manager"
+)
+
+// ImportUserData загружает проверенную manager выгрузку одной
+// транзакцией. С dryRun транзакция откатывается, а отчет показывает,
+// что было бы сделано.
+func (s *SQLiteStorage) ImportUserData(userID int, data *manager.Export, mode manager.ImportMode, dryRun bool) (*manager.ImportReport, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	report := &manager.ImportReport{Mode: mode, DryRun: dryRun, ProjectIDs: make(map[int]int), TaskIDs: make(map[int]int)}
+	now := time.Now()
+	inboxID, err := ensureInbox(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if mode == manager.ImportReplace {
+		if err := replaceUserData(tx, userID, report, now); err != nil {
+			return nil, err
+		}
+	}
+
+	existing := make(map[string]int)
+	rows, err := tx.Query("SELECT id, name_key FROM projects WHERE user_id = ? AND NOT inbox", userID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int
+		var key string
+		if err := rows.Scan(&id, &key); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		existing[key] = id
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	var position int
+	if err := tx.QueryRow("SELECT COALESCE(MAX(position) + 1, 0) FROM projects WHERE user_id = ?", userID).Scan(&position); err != nil {
+		return nil, err
+	}
+
+	for _, p := range data.Projects {
+		if p.Inbox {
+			report.ProjectIDs[p.ID] = inboxID
+			report.ProjectsMerged++
+			continue
+		}
+		if id, ok := existing[projectNameKey(p.Name)]; ok {
+			report.ProjectIDs[p.ID] = id
+			report.ProjectsMerged++
+			continue
+		}
+		result, err := tx.Exec(`
+		INSERT INTO projects (user_id, name, name_key, color, archived, position, inbox, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, FALSE, ?, ?)`,
+			userID, p.Name, projectNameKey(p.Name), p.Color, p.Archived, position+p.Position, now, now)
+		if err != nil {
+			return nil, projectConstraintError(err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		report.ProjectIDs[p.ID] = int(id)
+		report.ProjectsCreated++
+	}
+
+	for _, item := range data.Tasks {
+		task := manager.Task{UserID: userID, Description: item.Description, CreatedAt: item.CreatedAt,
+			UpdatedAt: item.UpdatedAt, Completed: item.Completed, Priority: item.Priority, Tags: item.Tags,
+			Recurrence: item.Recurrence, ProjectID: inboxID}
+		if item.ProjectID != 0 {
+			task.ProjectID = report.ProjectIDs[item.ProjectID]
+		}
+		if item.DueDate != nil {
+			task.DueDate = *item.DueDate
+		}
+		if item.SeriesID != item.ID {
+			task.SeriesID = report.TaskIDs[item.SeriesID]
+		}
+		id, err := insertTask(tx, &task)
+		if err != nil {
+			return nil, err
+		}
+		// Серию возглавляет сама задача: ее ID известен только после вставки
+		if item.SeriesID == item.ID {
+			if _, err := tx.Exec("UPDATE tasks SET series_id = id WHERE id = ?", id); err != nil {
+				return nil, err
+			}
+		}
+		report.TaskIDs[item.ID] = id
+		report.TasksCreated++
+	}
+
+	for _, item := range data.SubTasks {
+		_, err := tx.Exec(`
+		INSERT INTO subtasks (task_id, user_id, description, created_at, updated_at, completed)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+			report.TaskIDs[item.TaskID], userID, item.Description, item.CreatedAt, item.UpdatedAt, item.Completed)
+		if err != nil {
+			return nil, err
+		}
+		report.SubTasksCreated++
+	}
+
+	for _, item := range data.Reminders {
+		reminder := item.Reminder(userID, report.TaskIDs[item.TaskID], now)
+		if _, err := insertReminder(tx, &reminder); err != nil {
+			return nil, err
+		}
+		report.RemindersCreated++
+	}
+
+	if dryRun {
+		return report, nil
+	}
+	return report, tx.Commit()
+}
+
+// replaceUserData перемещает задачи пользователя в его проектах в корзину
+// и удаляет его проекты, кроме Входящих. Задачи участников в корзину не
+// попадают: как в DeleteProject, они переходят во Входящие авторов.
+func replaceUserData(tx *sql.Tx, userID int, report *manager.ImportReport, now time.Time) error {
+	const ownProjects = "SELECT id FROM projects WHERE user_id = ? AND NOT inbox"
+	rows, err := tx.Query("SELECT DISTINCT user_id FROM tasks WHERE project_id IN ("+ownProjects+")", userID)
+	if err != nil {
+		return err
+	}
+	var authors []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		authors = append(authors, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, id := range authors {
+		if _, err := ensureInbox(tx, id); err != nil {
+			return err
+		}
+	}
+
+	// В корзину - только задачи самого пользователя
+	result, err := tx.Exec(`
+	UPDATE tasks SET deleted_at = ?, version = version + 1
+	WHERE deleted_at IS NULL AND user_id = ? AND project_id IN (SELECT id FROM projects WHERE user_id = ?)`,
+		now, userID, userID)
+	if err != nil {
+		return err
+	}
+	trashed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	report.TasksTrashed = int(trashed)
+
+	if err := tx.QueryRow("SELECT COUNT(*) FROM tasks WHERE deleted_at IS NULL AND user_id != ? AND project_id IN ("+ownProjects+")",
+		userID, userID).Scan(&report.TasksMoved); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+	UPDATE tasks SET project_id = (SELECT p.id FROM projects p WHERE p.user_id = tasks.user_id AND p.inbox),
+		assignee_id = CASE WHEN assignee_id = user_id THEN assignee_id END, updated_at = ?,
+		version = version + 1
+	WHERE project_id IN (`+ownProjects+")", now, userID)
+	if err != nil {
+		return err
+	}
+	for _, query := range []string{
+		"DELETE FROM project_members WHERE project_id IN (" + ownProjects + ")",
+		"DELETE FROM project_invites WHERE project_id IN (" + ownProjects + ")",
+	} {
+		if _, err := tx.Exec(query, userID); err != nil {
+			return err
+		}
+	}
+	result, err = tx.Exec("DELETE FROM projects WHERE user_id = ? AND NOT inbox", userID)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	report.ProjectsDeleted = int(deleted)
+	return nil
+}
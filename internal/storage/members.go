@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"todo-app/internal/manager"
+)
+
+// GetProjectMembers возвращает владельца проекта, затем участников по дате добавления
+func (s *SQLiteStorage) GetProjectMembers(projectID int) ([]manager.Member, error) {
+	rows, err := s.db.Query(`
+	SELECT p.id, p.user_id AS user_id, u.username, 'owner', p.created_at AS created_at, 0 AS ord
+	FROM projects p LEFT JOIN users u ON u.id = p.user_id WHERE p.id = ?
+	UNION ALL
+	SELECT m.project_id, m.user_id, u.username, m.role, m.created_at, 1
+	FROM project_members m LEFT JOIN users u ON u.id = m.user_id WHERE m.project_id = ?
+	ORDER BY ord, created_at, user_id`, projectID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []manager.Member{}
+	for rows.Next() {
+		var m manager.Member
+		var username sql.NullString
+		var role string
+		var ord int
+		if err := rows.Scan(&m.ProjectID, &m.UserID, &username, &role, &m.CreatedAt, &ord); err != nil {
+			return nil, err
+		}
+		m.Username = username.String
+		m.Role = manager.Role(role)
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
+
+// SaveProjectMember добавляет участника или меняет его роль;
+// дата добавления остается прежней и возвращается в member
+func (s *SQLiteStorage) SaveProjectMember(member *manager.Member) error {
+	return s.db.QueryRow(`
+	INSERT INTO project_members (project_id, user_id, role, created_at)
+	VALUES (?, ?, ?, ?)
+	ON CONFLICT (project_id, user_id) DO UPDATE SET role = excluded.role
+	RETURNING created_at`,
+		member.ProjectID, member.UserID, string(member.Role), member.CreatedAt,
+	).Scan(&member.CreatedAt)
+}
+
+// DeleteProjectMember исключает участника и снимает с него задачи проекта
+func (s *SQLiteStorage) DeleteProjectMember(projectID, userID int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM project_members WHERE project_id = ? AND user_id = ?", projectID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return manager.NotFound("пользователь %d не участник проекта", userID)
+	}
+	if _, err := tx.Exec("UPDATE tasks SET assignee_id = NULL, version = version + 1 WHERE project_id = ? AND assignee_id = ?", projectID, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLiteStorage) CreateProjectInvite(invite *manager.ProjectInvite) error {
+	_, err := s.db.Exec(`
+	INSERT INTO project_invites (code_hash, project_id, role, created_by, created_at, expires_at)
+	VALUES (?, ?, ?, ?, ?, ?)`,
+		invite.CodeHash, invite.ProjectID, string(invite.Role), invite.CreatedBy, invite.CreatedAt, invite.ExpiresAt)
+	return err
+}
+
+// ConsumeProjectInvite удаляет приглашение и возвращает его. Как и код
+// привязки Telegram, истекший код после попытки использования пропадает.
+func (s *SQLiteStorage) ConsumeProjectInvite(codeHash string, now time.Time) (*manager.ProjectInvite, error) {
+	var invite manager.ProjectInvite
+	var role string
+	err := s.db.QueryRow(`
+	DELETE FROM project_invites WHERE code_hash = ?
+	RETURNING code_hash, project_id, role, created_by, created_at, expires_at`, codeHash).
+		Scan(&invite.CodeHash, &invite.ProjectID, &role, &invite.CreatedBy, &invite.CreatedAt, &invite.ExpiresAt)
+	if err == sql.ErrNoRows || (err == nil && !invite.ExpiresAt.After(now)) {
+		return nil, manager.NotFound("код приглашения не найден или истек")
+	}
+	if err != nil {
+		return nil, err
+	}
+	invite.Role = manager.Role(role)
+	return &invite, nil
+}
+
+// moveMemberships передает участие в общих проектах от fromUserID к toUserID
+// (см. LinkTelegram). Если toUserID уже участник, его роль сохраняется;
+// участие в собственных проектах toUserID снимается.
+func moveMemberships(tx *sql.Tx, fromUserID, toUserID int) error {
+	for _, query := range []string{
+		"UPDATE OR IGNORE project_members SET user_id = ? WHERE user_id = ?",
+		"UPDATE project_invites SET created_by = ? WHERE created_by = ?",
+	} {
+		if _, err := tx.Exec(query, toUserID, fromUserID); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM project_members WHERE user_id = ?", fromUserID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DELETE FROM project_members
+	WHERE user_id = ? AND project_id IN (SELECT id FROM projects WHERE user_id = ?)`, toUserID, toUserID)
+	return err
+}
@@ -0,0 +1,395 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"todo-app/migrations"
+)
+
+// Migration - одна версия схемы с SQL для применения и отката
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus - состояние миграции в конкретной базе
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator применяет и откатывает миграции, ведя учет в schema_migrations
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+var migrationFileRe = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// NewMigrator создает раннер со встроенными миграциями из пакета migrations
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	list, err := LoadMigrations(migrations.FS)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: list}, nil
+}
+
+// LoadMigrations читает пары NNN_name.up.sql / NNN_name.down.sql из fsys
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения миграций: %v", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationFileRe.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		body, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения %s: %v", entry.Name(), err)
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("миграция %d объявлена дважды: %s и %s", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("миграция %03d_%s должна иметь up и down файлы", m.Version, m.Name)
+		}
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Version < list[j].Version
+	})
+	return list, nil
+}
+
+// Latest возвращает номер последней известной миграции
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version возвращает номер последней примененной миграции
+func (m *Migrator) Version() (int, error) {
+	exists, err := tableExists(m.db, "schema_migrations")
+	if err != nil || !exists {
+		return 0, err
+	}
+	var version sql.NullInt64
+	err = m.db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// Status возвращает список всех миграций с отметкой о применении
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	applied, err := m.appliedVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		result = append(result, MigrationStatus{
+			Migration: migration,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		})
+	}
+	return result, nil
+}
+
+// appliedVersions не создает schema_migrations, чтобы status оставался
+// операцией только для чтения
+func (m *Migrator) appliedVersions() (map[int]time.Time, error) {
+	applied := make(map[int]time.Time)
+	exists, err := tableExists(m.db, "schema_migrations")
+	if err != nil || !exists {
+		return applied, err
+	}
+
+	rows, err := m.db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// Up применяет все непримененные миграции и возвращает их количество
+func (m *Migrator) Up() (int, error) {
+	return m.migrateUp(m.Latest())
+}
+
+// Down откатывает последнюю примененную миграцию
+func (m *Migrator) Down() error {
+	current, err := m.Version()
+	if err != nil {
+		return err
+	}
+	if current == 0 {
+		return fmt.Errorf("нет примененных миграций")
+	}
+
+	target := 0
+	for _, migration := range m.migrations {
+		if migration.Version < current {
+			target = migration.Version
+		}
+	}
+	_, err = m.migrateDown(target)
+	return err
+}
+
+// To приводит схему к указанной версии, применяя или откатывая миграции
+func (m *Migrator) To(version int) error {
+	if version != 0 && m.find(version) == nil {
+		return fmt.Errorf("неизвестная версия миграции: %d", version)
+	}
+
+	current, err := m.Version()
+	if err != nil {
+		return err
+	}
+	if version >= current {
+		_, err = m.migrateUp(version)
+	} else {
+		_, err = m.migrateDown(version)
+	}
+	return err
+}
+
+func (m *Migrator) migrateUp(target int) (int, error) {
+	if err := m.ensureVersionTable(); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, migration := range m.migrations {
+		if migration.Version > target {
+			break
+		}
+		applied, err := m.apply(migration)
+		if err != nil {
+			return count, err
+		}
+		if applied {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *Migrator) migrateDown(target int) (int, error) {
+	if err := m.ensureVersionTable(); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if migration.Version <= target {
+			break
+		}
+		reverted, err := m.revert(migration)
+		if err != nil {
+			return count, err
+		}
+		if reverted {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// apply выполняет одну миграцию в транзакции. Запись в schema_migrations
+// вставляется первой: она захватывает блокировку на запись, и второй
+// процесс, стартующий одновременно, дождется ее и пропустит миграцию.
+func (m *Migrator) apply(migration Migration) (bool, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"INSERT OR IGNORE INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+		migration.Version, migration.Name, time.Now(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("ошибка записи версии %d: %v", migration.Version, err)
+	}
+	if inserted, _ := result.RowsAffected(); inserted == 0 {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(migration.Up); err != nil {
+		return false, fmt.Errorf("ошибка применения миграции %03d_%s: %v", migration.Version, migration.Name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	log.Printf("✅ Применена миграция %03d_%s", migration.Version, migration.Name)
+	return true, nil
+}
+
+func (m *Migrator) revert(migration Migration) (bool, error) {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM schema_migrations WHERE version = ?", migration.Version)
+	if err != nil {
+		return false, fmt.Errorf("ошибка удаления версии %d: %v", migration.Version, err)
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(migration.Down); err != nil {
+		return false, fmt.Errorf("ошибка отката миграции %03d_%s: %v", migration.Version, migration.Name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	log.Printf("↩️ Откачена миграция %03d_%s", migration.Version, migration.Name)
+	return true, nil
+}
+
+func (m *Migrator) find(version int) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// ensureVersionTable создает schema_migrations. Если таблицы еще не было,
+// а задачи уже есть, база создана до появления миграций и ее схему
+// нужно подтянуть до 001_initial_schema.
+func (m *Migrator) ensureVersionTable() error {
+	exists, err := tableExists(m.db, "schema_migrations")
+	if err != nil || exists {
+		return err
+	}
+
+	legacy, err := tableExists(m.db, "tasks")
+	if err != nil {
+		return err
+	}
+	if legacy {
+		if err := adoptLegacySchema(m.db); err != nil {
+			return err
+		}
+	}
+
+	_, err = m.db.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("ошибка создания таблицы schema_migrations: %v", err)
+	}
+	return nil
+}
+
+// adoptLegacySchema добавляет колонки, которых нет в базах, созданных
+// старыми createTables и cmd/add_user_id_column
+func adoptLegacySchema(db *sql.DB) error {
+	required := []struct {
+		table, column, definition string
+	}{
+		{"tasks", "user_id", "INTEGER REFERENCES users(id)"},
+		{"subtasks", "user_id", "INTEGER REFERENCES users(id)"},
+	}
+
+	for _, col := range required {
+		exists, err := tableExists(db, col.table)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		has, err := columnExists(db, col.table, col.column)
+		if err != nil {
+			return err
+		}
+		if has {
+			continue
+		}
+		query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", col.table, col.column, col.definition)
+		if _, err := db.Exec(query); err != nil {
+			return fmt.Errorf("ошибка добавления колонки %s.%s: %v", col.table, col.column, err)
+		}
+		log.Printf("🔧 В старую базу добавлена колонка %s.%s", col.table, col.column)
+	}
+	return nil
+}
+
+func tableExists(db *sql.DB, name string) (bool, error) {
+	var count int
+	err := db.QueryRow(
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name,
+	).Scan(&count)
+	return count > 0, err
+}
+
+func columnExists(db *sql.DB, table, column string) (bool, error) {
+	var count int
+	err := db.QueryRow(
+		"SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column,
+	).Scan(&count)
+	return count > 0, err
+}
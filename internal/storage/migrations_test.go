@@ -0,0 +1,264 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := OpenDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Ошибка открытия БД: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestLoadMigrations(t *testing.T) {
+	t.Run("Сортировка по версии", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"002_second.up.sql":   {Data: []byte("SELECT 2")},
+			"002_second.down.sql": {Data: []byte("SELECT -2")},
+			"001_first.up.sql":    {Data: []byte("SELECT 1")},
+			"001_first.down.sql":  {Data: []byte("SELECT -1")},
+			"README.md":           {Data: []byte("игнорируется")},
+		}
+		list, err := LoadMigrations(fsys)
+		if err != nil {
+			t.Fatalf("Неожиданная ошибка: %v", err)
+		}
+		if len(list) != 2 || list[0].Version != 1 || list[1].Version != 2 {
+			t.Fatalf("Ожидались версии [1 2], получено %+v", list)
+		}
+		if list[0].Name != "first" || list[0].Down != "SELECT -1" {
+			t.Errorf("Неверно прочитана миграция: %+v", list[0])
+		}
+	})
+
+	t.Run("Нет down-файла", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"001_first.up.sql": {Data: []byte("SELECT 1")},
+		}
+		if _, err := LoadMigrations(fsys); err == nil {
+			t.Error("Ожидалась ошибка для миграции без down-файла")
+		}
+	})
+
+	t.Run("Разные имена у одной версии", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"001_first.up.sql":   {Data: []byte("SELECT 1")},
+			"001_other.down.sql": {Data: []byte("SELECT -1")},
+		}
+		if _, err := LoadMigrations(fsys); err == nil {
+			t.Error("Ожидалась ошибка для конфликтующих имен")
+		}
+	})
+}
+
+func TestMigratorUpDown(t *testing.T) {
+	db := openTestDB(t)
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		t.Fatalf("Ошибка создания раннера: %v", err)
+	}
+
+	applied, err := migrator.Up()
+	if err != nil {
+		t.Fatalf("Ошибка применения миграций: %v", err)
+	}
+	if applied != migrator.Latest() {
+		t.Errorf("Ожидалось %d примененных миграций, получено %d", migrator.Latest(), applied)
+	}
+
+	// Повторный запуск ничего не делает
+	applied, err = migrator.Up()
+	if err != nil || applied != 0 {
+		t.Errorf("Повторный Up: ожидалось 0 миграций без ошибки, получено %d, %v", applied, err)
+	}
+
+	if err := migrator.Down(); err != nil {
+		t.Fatalf("Ошибка отката: %v", err)
+	}
+	version, _ := migrator.Version()
+	if version != migrator.Latest()-1 {
+		t.Errorf("После Down ожидалась версия %d, получена %d", migrator.Latest()-1, version)
+	}
+
+	if err := migrator.To(0); err != nil {
+		t.Fatalf("Ошибка отката до 0: %v", err)
+	}
+	if exists, _ := tableExists(db, "tasks"); exists {
+		t.Error("Таблица tasks должна быть удалена после отката всех миграций")
+	}
+	if err := migrator.Down(); err == nil {
+		t.Error("Ожидалась ошибка при откате пустой схемы")
+	}
+
+	if err := migrator.To(migrator.Latest()); err != nil {
+		t.Fatalf("Ошибка повторного применения: %v", err)
+	}
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatalf("Ошибка получения статуса: %v", err)
+	}
+	for _, s := range statuses {
+		if !s.Applied {
+			t.Errorf("Миграция %03d_%s должна быть применена", s.Version, s.Name)
+		}
+	}
+
+	if err := migrator.To(9999); err == nil {
+		t.Error("Ожидалась ошибка для неизвестной версии")
+	}
+}
+
+func TestNormalizedTagsMigration(t *testing.T) {
+	db := openTestDB(t)
+	migrator, _ := NewMigrator(db)
+	if err := migrator.To(8); err != nil {
+		t.Fatalf("Ошибка миграции до 8: %v", err)
+	}
+	_, err := db.Exec(`
+	INSERT INTO tasks (description, created_at, updated_at, tags, user_id) VALUES
+		('первая', datetime('now'), datetime('now'), 'Работа, дом,работа', 1),
+		('вторая', datetime('now'), datetime('now'), 'РАБОТА,,срочно', 1),
+		('без тегов', datetime('now'), datetime('now'), '', 1),
+		('чужая', datetime('now'), datetime('now'), 'работа', 2)`)
+	if err != nil {
+		t.Fatalf("Ошибка вставки задач: %v", err)
+	}
+
+	// Задачи читаются запросами последней версии схемы
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Ошибка миграции: %v", err)
+	}
+	s := &SQLiteStorage{db: db}
+	first, _ := s.GetTask(1, 1)
+	second, _ := s.GetTask(1, 2)
+	if strings.Join(first.Tags, ",") != "Работа,дом" || strings.Join(second.Tags, ",") != "Работа,срочно" {
+		t.Errorf("Неожиданные теги после миграции: %v и %v", first.Tags, second.Tags)
+	}
+	if none, _ := s.GetTask(1, 3); none.Tags == nil || len(none.Tags) != 0 {
+		t.Errorf("У задачи без тегов ожидался пустой список, получено %#v", none.Tags)
+	}
+	if tags, _ := s.GetTags(2); len(tags) != 1 || tags[0].Name != "работа" {
+		t.Errorf("Теги разных пользователей не должны смешиваться: %+v", tags)
+	}
+
+	if err := migrator.To(8); err != nil {
+		t.Fatalf("Ошибка отката: %v", err)
+	}
+	var tags string
+	db.QueryRow("SELECT tags FROM tasks WHERE id = 2").Scan(&tags)
+	if tags != "Работа,срочно" {
+		t.Errorf("После отката ожидалась строка тегов, получено %q", tags)
+	}
+}
+
+func TestMigratorStatusIsReadOnly(t *testing.T) {
+	db := openTestDB(t)
+	migrator, _ := NewMigrator(db)
+
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatalf("Ошибка получения статуса: %v", err)
+	}
+	if len(statuses) == 0 || statuses[0].Applied {
+		t.Errorf("Ожидались непримененные миграции, получено %+v", statuses)
+	}
+	if exists, _ := tableExists(db, "schema_migrations"); exists {
+		t.Error("Status не должен создавать schema_migrations")
+	}
+}
+
+func TestMigratorAdoptsLegacyDatabase(t *testing.T) {
+	db := openTestDB(t)
+
+	// Схема из первых версий приложения: без user_id и без schema_migrations
+	_, err := db.Exec(`
+	CREATE TABLE users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		device_id TEXT UNIQUE NOT NULL,
+		telegram_id INTEGER,
+		fcm_token TEXT,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);
+	CREATE TABLE tasks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		description TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		completed BOOLEAN NOT NULL DEFAULT FALSE,
+		priority TEXT NOT NULL DEFAULT 'medium',
+		due_date DATETIME,
+		tags TEXT
+	);
+	CREATE TABLE subtasks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id INTEGER NOT NULL,
+		description TEXT NOT NULL,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		completed BOOLEAN NOT NULL DEFAULT FALSE
+	);
+	INSERT INTO tasks (description, created_at, updated_at) VALUES ('старая задача', datetime('now'), datetime('now'));`)
+	if err != nil {
+		t.Fatalf("Ошибка создания старой схемы: %v", err)
+	}
+
+	migrator, _ := NewMigrator(db)
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Ошибка миграции старой базы: %v", err)
+	}
+
+	for _, table := range []string{"tasks", "subtasks"} {
+		if has, _ := columnExists(db, table, "user_id"); !has {
+			t.Errorf("В таблице %s должна появиться колонка user_id", table)
+		}
+	}
+
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM tasks").Scan(&count)
+	if count != 1 {
+		t.Errorf("Старые задачи должны сохраниться, найдено %d", count)
+	}
+}
+
+func TestProjectsMigration(t *testing.T) {
+	db := openTestDB(t)
+	migrator, _ := NewMigrator(db)
+	if err := migrator.To(11); err != nil {
+		t.Fatalf("Ошибка миграции до 11: %v", err)
+	}
+	_, err := db.Exec(`
+	INSERT INTO tasks (description, created_at, updated_at, user_id) VALUES
+		('первая', datetime('now'), datetime('now'), 1),
+		('вторая', datetime('now'), datetime('now'), 1),
+		('чужая', datetime('now'), datetime('now'), 2)`)
+	if err != nil {
+		t.Fatalf("Ошибка вставки задач: %v", err)
+	}
+	if err := migrator.To(12); err != nil {
+		t.Fatalf("Ошибка миграции: %v", err)
+	}
+	// Запросы к проектам учитывают участников (миграция 013)
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Ошибка миграции до последней версии: %v", err)
+	}
+
+	s := &SQLiteStorage{db: db}
+	for userID, want := range map[int]int{1: 2, 2: 1} {
+		projects, err := s.GetProjects(userID)
+		if err != nil || len(projects) != 1 || !projects[0].Inbox || projects[0].OpenTasks != want {
+			t.Errorf("Пользователь %d: ожидались Входящие с %d задачами, получено %+v, %v", userID, want, projects, err)
+		}
+	}
+	if task, _ := s.GetTask(2, 3); task.ProjectID == 0 {
+		t.Error("Существующие задачи должны попасть во Входящие")
+	}
+}
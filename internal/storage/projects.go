@@ -0,0 +1,282 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"todo-app/internal/manager"
+)
+
+// Колонки проекта в порядке, который ожидает scanProject; open_tasks
+// считается по задачам проекта. Роль считается для пользователя из
+// projectArgs, поэтому эти аргументы идут перед аргументами WHERE.
+const projectColumns = `id, user_id, name, color, archived, position, inbox, created_at, updated_at,
+	(SELECT COUNT(*) FROM tasks t WHERE t.project_id = projects.id AND NOT t.completed AND t.deleted_at IS NULL),
+	(SELECT COUNT(*) FROM project_members m WHERE m.project_id = projects.id),
+	CASE WHEN user_id = ? THEN 'owner'
+		ELSE COALESCE((SELECT m.role FROM project_members m WHERE m.project_id = projects.id AND m.user_id = ?), '')
+	END`
+
+func projectArgs(userID int) []interface{} {
+	return []interface{}{userID, userID}
+}
+
+func scanProject(row rowScanner) (*manager.Project, error) {
+	var p manager.Project
+	var role string
+	err := row.Scan(&p.ID, &p.UserID, &p.Name, &p.Color, &p.Archived, &p.Position, &p.Inbox,
+		&p.CreatedAt, &p.UpdatedAt, &p.OpenTasks, &p.Members, &role)
+	if err != nil {
+		return nil, err
+	}
+	p.Role = manager.Role(role)
+	return &p, nil
+}
+
+// projectNameKey - ключ уникальности названия, как в manager
+func projectNameKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// ensureInbox возвращает ID Входящих пользователя, создавая их при необходимости
+func ensureInbox(tx *sql.Tx, userID int) (int, error) {
+	now := time.Now()
+	_, err := tx.Exec(`
+	INSERT INTO projects (user_id, name, name_key, color, inbox, created_at, updated_at)
+	VALUES (?, ?, ?, ?, TRUE, ?, ?)
+	ON CONFLICT DO NOTHING`,
+		userID, manager.InboxName, projectNameKey(manager.InboxName), manager.DefaultProjectColor, now, now)
+	if err != nil {
+		return 0, err
+	}
+	var id int
+	err = tx.QueryRow("SELECT id FROM projects WHERE user_id = ? AND inbox", userID).Scan(&id)
+	return id, err
+}
+
+// GetInbox возвращает Входящие пользователя, создавая их при необходимости
+func (s *SQLiteStorage) GetInbox(userID int) (*manager.Project, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	id, err := ensureInbox(tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetProject(userID, id)
+}
+
+func (s *SQLiteStorage) CreateProject(p *manager.Project) (int, error) {
+	result, err := s.db.Exec(`
+	INSERT INTO projects (user_id, name, name_key, color, archived, position, inbox, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.UserID, p.Name, projectNameKey(p.Name), p.Color, p.Archived, p.Position, p.Inbox, p.CreatedAt, p.UpdatedAt,
+	)
+	if err != nil {
+		return 0, projectConstraintError(err)
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// projectConstraintError превращает нарушение уникальности названия в ErrConflict
+func projectConstraintError(err error) error {
+	if err != nil && strings.Contains(err.Error(), "projects.name_key") {
+		return manager.Conflict("проект с таким названием уже существует")
+	}
+	return err
+}
+
+// GetProjects возвращает проекты пользователя: Входящие, свои по position
+// и названию, затем общие проекты, где он участник
+func (s *SQLiteStorage) GetProjects(userID int) ([]manager.Project, error) {
+	args := append(projectArgs(userID), userID, userID, userID)
+	rows, err := s.db.Query("SELECT "+projectColumns+` FROM projects
+	WHERE user_id = ? OR id IN (SELECT project_id FROM project_members WHERE user_id = ?)
+	ORDER BY user_id != ?, inbox DESC, position, name_key, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	projects := []manager.Project{}
+	for rows.Next() {
+		p, err := scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		projects = append(projects, *p)
+	}
+	return projects, rows.Err()
+}
+
+// GetProject возвращает проект с ролью пользователя в нем; без роли - ErrForbidden
+func (s *SQLiteStorage) GetProject(userID, id int) (*manager.Project, error) {
+	p, err := scanProject(s.db.QueryRow("SELECT "+projectColumns+" FROM projects WHERE id = ?",
+		append(projectArgs(userID), id)...))
+	if err == sql.ErrNoRows {
+		return nil, manager.NotFound("проект с ID %d не найден", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.Role == "" {
+		return nil, manager.Forbidden("проект с ID %d принадлежит другому пользователю", id)
+	}
+	return p, nil
+}
+
+// UpdateProject сохраняет название, цвет, архивность и порядок проекта
+func (s *SQLiteStorage) UpdateProject(p *manager.Project) error {
+	result, err := s.db.Exec(`
+	UPDATE projects SET name = ?, name_key = ?, color = ?, archived = ?, position = ?, updated_at = ?
+	WHERE id = ? AND user_id = ?`,
+		p.Name, projectNameKey(p.Name), p.Color, p.Archived, p.Position, p.UpdatedAt, p.ID, p.UserID,
+	)
+	if err != nil {
+		return projectConstraintError(err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return manager.NotFound("проект с ID %d не найден", p.ID)
+	}
+	return nil
+}
+
+// ArchiveProjects меняет архивность проектов пользователя (кроме Входящих)
+// и возвращает число измененных
+func (s *SQLiteStorage) ArchiveProjects(userID int, ids []int, archived bool) (int, error) {
+	args := []interface{}{archived, time.Now(), userID, archived}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	result, err := s.db.Exec(`
+	UPDATE projects SET archived = ?, updated_at = ?
+	WHERE user_id = ? AND NOT inbox AND archived != ? AND id IN (`+placeholders(len(ids))+`)`, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// DeleteProject удаляет проект с участниками и приглашениями. Задачи
+// переносятся во Входящие их авторов, а если Входящих у автора нет -
+// в inboxID; назначение другим пользователям снимается. Возвращает число
+// перенесенных задач без учета задач из корзины, которые переносятся тоже.
+func (s *SQLiteStorage) DeleteProject(userID, id, inboxID int) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM projects WHERE id = ? AND user_id = ? AND NOT inbox", id, userID)
+	if err != nil {
+		return 0, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return 0, manager.NotFound("проект с ID %d не найден", id)
+	}
+	var moved int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM tasks WHERE project_id = ? AND deleted_at IS NULL", id).Scan(&moved); err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec(`
+	UPDATE tasks SET project_id = COALESCE(
+		(SELECT p.id FROM projects p WHERE p.user_id = tasks.user_id AND p.inbox), ?),
+		assignee_id = CASE WHEN assignee_id = user_id THEN assignee_id END, updated_at = ?,
+		version = version + 1
+	WHERE project_id = ?`, inboxID, time.Now(), id)
+	if err != nil {
+		return 0, err
+	}
+	for _, query := range []string{
+		"DELETE FROM project_members WHERE project_id = ?",
+		"DELETE FROM project_invites WHERE project_id = ?",
+	} {
+		if _, err := tx.Exec(query, id); err != nil {
+			return 0, err
+		}
+	}
+	return moved, tx.Commit()
+}
+
+// MoveTasks переносит задачи в проект и возвращает число задач, которые
+// были в другом проекте. Права на задачи проверяет manager. Назначение
+// снимается, если исполнитель не может менять задачи нового проекта.
+func (s *SQLiteStorage) MoveTasks(taskIDs []int, projectID int) (int, error) {
+	args := []interface{}{projectID, projectID, projectID, time.Now(), projectID}
+	for _, id := range taskIDs {
+		args = append(args, id)
+	}
+	result, err := s.db.Exec(`
+	UPDATE tasks SET project_id = ?,
+		assignee_id = CASE WHEN assignee_id IN (SELECT user_id FROM projects WHERE id = ?
+			UNION SELECT user_id FROM project_members WHERE project_id = ? AND role = 'editor')
+			THEN assignee_id END,
+		updated_at = ?, version = version + 1
+	WHERE project_id IS NOT ? AND id IN (`+placeholders(len(taskIDs))+`)`, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// moveProjects передает проекты fromUserID пользователю toUserID (см. LinkTelegram).
+// Проекты с совпадающим названием объединяются вместе с участниками; Входящие
+// не переименовываются, поэтому Входящие объединяются с Входящими.
+func moveProjects(tx *sql.Tx, fromUserID, toUserID int) error {
+	if _, err := ensureInbox(tx, toUserID); err != nil {
+		return err
+	}
+	// newProject - проект toUserID с тем же названием, что у o
+	const newProject = `SELECT n.id FROM projects o JOIN projects n ON n.name_key = o.name_key AND n.user_id = ?`
+	for _, query := range []string{
+		`INSERT INTO projects (user_id, name, name_key, color, archived, position, inbox, created_at, updated_at)
+		SELECT ?, name, name_key, color, archived, position, FALSE, created_at, updated_at
+		FROM projects WHERE user_id = ? AND NOT inbox
+		ON CONFLICT (user_id, name_key) DO NOTHING`,
+		`UPDATE tasks SET project_id = (` + newProject + ` WHERE o.id = tasks.project_id), version = version + 1
+		WHERE project_id IN (SELECT id FROM projects WHERE user_id = ?)`,
+		`UPDATE OR IGNORE project_members SET project_id = (` + newProject + ` WHERE o.id = project_members.project_id)
+		WHERE project_id IN (SELECT id FROM projects WHERE user_id = ?)`,
+		`UPDATE project_invites SET project_id = (` + newProject + ` WHERE o.id = project_invites.project_id)
+		WHERE project_id IN (SELECT id FROM projects WHERE user_id = ?)`,
+	} {
+		if _, err := tx.Exec(query, toUserID, fromUserID); err != nil {
+			return err
+		}
+	}
+	for _, query := range []string{
+		// Участники, которые уже были в объединенном проекте
+		"DELETE FROM project_members WHERE project_id IN (SELECT id FROM projects WHERE user_id = ?)",
+		"DELETE FROM projects WHERE user_id = ?",
+	} {
+		if _, err := tx.Exec(query, fromUserID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// placeholders возвращает "?, ?, ?" для n аргументов
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// intArgs - аргументы запроса для placeholders(len(ids))
+func intArgs(ids []int) []interface{} {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return args
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"strings"
+	"time"
+
+	"todo-app/internal/manager"
+)
+
+// queryCondition переводит выражение языка запросов в условие WHERE по таблице
+// tasks. Каждое условие дает 0 или 1, но не NULL: иначе NOT вел бы себя
+// не так, как QueryExpr.Match в памяти.
+func queryCondition(expr manager.QueryExpr, today time.Time) (string, []interface{}) {
+	switch e := expr.(type) {
+	case manager.QueryAnd:
+		return joinConditions([]manager.QueryExpr(e), " AND ", today)
+	case manager.QueryOr:
+		return joinConditions([]manager.QueryExpr(e), " OR ", today)
+	case manager.QueryNot:
+		condition, args := queryCondition(e.Expr, today)
+		return "NOT " + condition, args
+	case manager.QueryText:
+		return "instr(casefold(description), ?) > 0", []interface{}{strings.ToLower(string(e))}
+	case manager.QueryField:
+		return fieldCondition(e, today)
+	}
+	// Неизвестное условие ничего не находит
+	return "0", nil
+}
+
+func joinConditions(exprs []manager.QueryExpr, separator string, today time.Time) (string, []interface{}) {
+	conditions := make([]string, len(exprs))
+	var args []interface{}
+	for i, expr := range exprs {
+		condition, exprArgs := queryCondition(expr, today)
+		conditions[i] = condition
+		args = append(args, exprArgs...)
+	}
+	return "(" + strings.Join(conditions, separator) + ")", args
+}
+
+// fieldCondition - условие на поле задачи, см. manager.QueryField.Match
+func fieldCondition(f manager.QueryField, today time.Time) (string, []interface{}) {
+	switch f.Field {
+	case "priority":
+		return "priority = ?", []interface{}{f.Value}
+	case "tag":
+		return tagCondition, []interface{}{tagKey(f.Value)}
+	case "is":
+		switch f.Value {
+		case "open":
+			return "completed = ?", []interface{}{false}
+		case "done":
+			return "completed = ?", []interface{}{true}
+		case "overdue":
+			return "(completed = ? AND due_date IS NOT NULL AND " + dueDay + " < ?)", []interface{}{false, dateOnly(today)}
+		case "recurring":
+			return "COALESCE(recurrence, '') != ''", nil
+		}
+	case "has":
+		switch f.Value {
+		case "due":
+			return "due_date IS NOT NULL", nil
+		case "tags":
+			return "EXISTS (SELECT 1 FROM task_tags tt WHERE tt.task_id = tasks.id)", nil
+		}
+	case "due":
+		// Оператор подставляется в текст запроса, поэтому только из списка
+		op := "="
+		switch f.Op {
+		case "<", "<=", ">", ">=":
+			op = f.Op
+		}
+		return "(due_date IS NOT NULL AND " + dueDay + " " + op + " ?)", []interface{}{f.Day(today)}
+	}
+	return "0", nil
+}
@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"todo-app/internal/manager"
+)
+
+// Словарь случайных задач и запросов: запросы должны часто совпадать
+// с задачами, иначе сравнивать будет нечего
+var (
+	queryWords = []string{"Годовой отчет", "позвонить в банк", "Купить молоко", "ОТЧЕТ по карте", "планерка", "e-mail"}
+	queryTags  = []string{"работа", "Дом", "срочно", "два слова"}
+)
+
+// randomTaskQuery - случайный запрос на языке запросов для quick.Check
+type randomTaskQuery string
+
+func (randomTaskQuery) Generate(r *rand.Rand, size int) reflect.Value {
+	return reflect.ValueOf(randomTaskQuery(randomExpr(r, 3)))
+}
+
+func randomExpr(r *rand.Rand, depth int) string {
+	if depth == 0 || r.Intn(3) == 0 {
+		return randomCondition(r)
+	}
+	left, right := randomExpr(r, depth-1), randomExpr(r, depth-1)
+	if r.Intn(2) == 0 {
+		left = "(" + left + ")"
+	}
+	switch r.Intn(5) {
+	case 0:
+		return "-(" + left + ")"
+	case 1:
+		return "NOT " + left
+	case 2:
+		return left + " " + right
+	case 3:
+		return left + " AND " + right
+	}
+	return left + " OR " + right
+}
+
+func randomCondition(r *rand.Rand) string {
+	pick := func(values ...string) string { return values[r.Intn(len(values))] }
+	switch r.Intn(6) {
+	case 0:
+		return "priority:" + pick("low", "medium", "high")
+	case 1:
+		tag := queryTags[r.Intn(len(queryTags))]
+		if r.Intn(2) == 0 {
+			tag = strings.ToUpper(tag)
+		}
+		return `tag:"` + tag + `"`
+	case 2:
+		return "is:" + pick("open", "done", "overdue", "recurring")
+	case 3:
+		return "has:" + pick("due", "tags")
+	case 4:
+		day := time.Now().AddDate(0, 0, r.Intn(11)-5)
+		date := pick("today", "tomorrow", "yesterday", "+3d", "-2d", "+1w", day.Format("2006-01-02"), day.Format("02.01.2006"))
+		return "due" + pick(":", "=", "<", "<=", ">", ">=") + date
+	}
+	words := strings.Fields(queryWords[r.Intn(len(queryWords))])
+	word := words[r.Intn(len(words))]
+	if r.Intn(2) == 0 {
+		word = strings.ToLower(word)
+	}
+	if r.Intn(3) == 0 {
+		// Часть слова: текст ищется подстрокой
+		word = string([]rune(word)[:len([]rune(word))/2+1])
+	}
+	return `"` + word + `"`
+}
+
+// TestQueryBackendsAgree проверяет, что SQL-условие и проверка в памяти
+// выбирают одни и те же задачи для случайных запросов
+func TestQueryBackendsAgree(t *testing.T) {
+	memory := manager.NewTaskManager()
+	sqlite := manager.NewTaskManagerWithStorage(newTestStorage(t))
+
+	r := rand.New(rand.NewSource(14))
+	today := time.Now()
+	for i := 0; i < 60; i++ {
+		userID := 1
+		if i%10 == 9 {
+			userID = 2
+		}
+		description := queryWords[r.Intn(len(queryWords))]
+		var tags []string
+		for _, tag := range queryTags {
+			if r.Intn(3) == 0 {
+				tags = append(tags, tag)
+			}
+		}
+		var req manager.UpdateTaskRequest
+		priority := []manager.Priority{manager.PriorityLow, manager.PriorityMedium, manager.PriorityHigh}[r.Intn(3)]
+		req.Priority = &priority
+		if r.Intn(4) != 0 {
+			// Время тоже случайное: сравниваются только дни
+			due := time.Date(today.Year(), today.Month(), today.Day()+r.Intn(15)-7, r.Intn(24), 30, 0, 0, time.Local)
+			req.DueDate = &due
+			if r.Intn(5) == 0 {
+				rule := "FREQ=WEEKLY"
+				req.Recurrence = &rule
+			}
+		}
+		if req.Recurrence == nil && r.Intn(3) == 0 {
+			completed := true
+			req.Completed = &completed
+		}
+
+		a, err := memory.CreateTaskForUser(userID, description, tags, req)
+		if err != nil {
+			t.Fatalf("Ошибка создания задачи в памяти: %v", err)
+		}
+		b, err := sqlite.CreateTaskForUser(userID, description, tags, req)
+		if err != nil {
+			t.Fatalf("Ошибка создания задачи в SQLite: %v", err)
+		}
+		if a.ID != b.ID {
+			t.Fatalf("ID задач разошлись: %d и %d", a.ID, b.ID)
+		}
+	}
+
+	ids := func(tm *manager.TaskManager, query string) []int {
+		tasks, err := tm.FilterTasksAdvancedForUser(1, manager.FilterOptions{Query: query})
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		result := []int{}
+		for _, task := range tasks {
+			result = append(result, task.ID)
+		}
+		sort.Ints(result)
+		return result
+	}
+
+	property := func(query randomTaskQuery) bool {
+		inMemory, inSQLite := ids(memory, string(query)), ids(sqlite, string(query))
+		if !reflect.DeepEqual(inMemory, inSQLite) {
+			t.Logf("%s: в памяти %v, в SQLite %v", query, inMemory, inSQLite)
+			return false
+		}
+		return true
+	}
+	config := &quick.Config{MaxCount: 500, Rand: rand.New(rand.NewSource(1))}
+	if err := quick.Check(property, config); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,262 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"todo-app/internal/manager"
+)
+
+const reminderColumns = "id, task_id, user_id, type, trigger_time, message, status, channel, days_before, auto, created_at, updated_at, " +
+	"attempts, last_error, next_attempt_at, sent_at"
+
+func scanReminder(row rowScanner) (*manager.Reminder, error) {
+	var r manager.Reminder
+	var status string
+	var daysBefore sql.NullInt64
+	var createdAt, updatedAt, nextAttemptAt, sentAt sql.NullTime
+	var lastError sql.NullString
+	err := row.Scan(
+		&r.ID, &r.TaskID, &r.UserID, &r.Type, &r.TriggerTime, &r.Message,
+		&status, &r.Channel, &daysBefore, &r.Auto, &createdAt, &updatedAt,
+		&r.Attempts, &lastError, &nextAttemptAt, &sentAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	r.Status = manager.ReminderStatus(status)
+	if daysBefore.Valid {
+		days := int(daysBefore.Int64)
+		r.DaysBefore = &days
+	}
+	r.CreatedAt = createdAt.Time
+	r.UpdatedAt = updatedAt.Time
+	r.LastError = lastError.String
+	r.NextAttemptAt = nullTimePtr(nextAttemptAt)
+	r.SentAt = nullTimePtr(sentAt)
+	return &r, nil
+}
+
+func scanReminders(rows *sql.Rows) ([]manager.Reminder, error) {
+	reminders := []manager.Reminder{}
+	for rows.Next() {
+		r, err := scanReminder(rows)
+		if err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, *r)
+	}
+	return reminders, rows.Err()
+}
+
+// nullDays сохраняет отсутствие days_before как NULL
+func nullDays(days *int) sql.NullInt64 {
+	if days == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(*days), Valid: true}
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+// utcPtr сохраняет отсутствие времени как NULL, остальное - в UTC
+func utcPtr(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+// CreateReminder сохраняет напоминание. Время хранится в UTC,
+// чтобы планировщик мог сравнивать его прямо в SQL.
+func (s *SQLiteStorage) CreateReminder(r *manager.Reminder) (int, error) {
+	return insertReminder(s.db, r)
+}
+
+// execer - общее у *sql.DB и *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func insertReminder(db execer, r *manager.Reminder) (int, error) {
+	result, err := db.Exec(`
+	INSERT INTO reminders (task_id, user_id, type, trigger_time, message, status, channel, days_before, auto, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.TaskID, r.UserID, r.Type, r.TriggerTime.UTC(), r.Message, string(r.Status),
+		r.Channel, nullDays(r.DaysBefore), r.Auto, r.CreatedAt, r.UpdatedAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+func (s *SQLiteStorage) GetReminder(userID, id int) (*manager.Reminder, error) {
+	query := "SELECT " + reminderColumns + " FROM reminders WHERE id = ?"
+	r, err := scanReminder(s.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, manager.NotFound("напоминание с ID %d не найдено", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if r.UserID != userID {
+		return nil, manager.Forbidden("напоминание с ID %d принадлежит другому пользователю", id)
+	}
+	return r, nil
+}
+
+func (s *SQLiteStorage) GetTaskReminders(userID, taskID int) ([]manager.Reminder, error) {
+	query := "SELECT " + reminderColumns + ` FROM reminders
+		WHERE task_id = ? AND user_id = ?
+		ORDER BY trigger_time, id`
+	rows, err := s.db.Query(query, taskID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanReminders(rows)
+}
+
+// UpdateReminder сохраняет изменяемые поля напоминания
+func (s *SQLiteStorage) UpdateReminder(r *manager.Reminder) error {
+	result, err := s.db.Exec(`
+	UPDATE reminders SET trigger_time = ?, message = ?, status = ?, days_before = ?, updated_at = ?
+	WHERE id = ? AND user_id = ?`,
+		r.TriggerTime.UTC(), r.Message, string(r.Status), nullDays(r.DaysBefore), time.Now(), r.ID, r.UserID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return manager.NotFound("напоминание с ID %d не найдено", r.ID)
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) DeleteReminder(userID, id int) error {
+	if _, err := s.GetReminder(userID, id); err != nil {
+		return err
+	}
+	_, err := s.db.Exec("DELETE FROM reminders WHERE id = ? AND user_id = ?", id, userID)
+	return err
+}
+
+func (s *SQLiteStorage) GetReminderSettings(userID int) (*manager.ReminderSettings, error) {
+	var settings manager.ReminderSettings
+	err := s.db.QueryRow(`
+	SELECT user_id, enabled, remind_before_days, telegram_notifications, push_notifications
+	FROM reminder_settings WHERE user_id = ?`, userID).Scan(
+		&settings.UserID, &settings.Enabled, &settings.RemindBeforeDays,
+		&settings.TelegramNotifications, &settings.PushNotifications,
+	)
+	if err == sql.ErrNoRows {
+		return nil, manager.NotFound("настройки напоминаний не заданы")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (s *SQLiteStorage) SaveReminderSettings(settings *manager.ReminderSettings) error {
+	_, err := s.db.Exec(`
+	INSERT INTO reminder_settings (user_id, enabled, remind_before_days, telegram_notifications, push_notifications, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(user_id) DO UPDATE SET
+		enabled = excluded.enabled,
+		remind_before_days = excluded.remind_before_days,
+		telegram_notifications = excluded.telegram_notifications,
+		push_notifications = excluded.push_notifications,
+		updated_at = excluded.updated_at`,
+		settings.UserID, settings.Enabled, settings.RemindBeforeDays,
+		settings.TelegramNotifications, settings.PushNotifications, time.Now(), time.Now(),
+	)
+	return err
+}
+
+// inTrash - условие на напоминания задач из корзины: они ждут восстановления
+// задачи или удаляются вместе с ней
+const inTrash = "task_id IN (SELECT id FROM tasks WHERE deleted_at IS NOT NULL)"
+
+// ClaimDueReminders атомарно забирает напоминания, время которых наступило.
+// Один UPDATE выполняется в собственной транзакции записи, поэтому два
+// процесса над одной базой никогда не заберут одно напоминание одновременно.
+func (s *SQLiteStorage) ClaimDueReminders(owner string, now, claimUntil time.Time, limit int) ([]manager.Reminder, error) {
+	now = now.UTC()
+	rows, err := s.db.Query(`
+	UPDATE reminders SET status = 'pending', attempts = attempts + 1,
+		claimed_by = ?, claimed_until = ?, updated_at = ?
+	WHERE id IN (
+		SELECT id FROM reminders
+		WHERE ((status = 'pending' AND trigger_time <= ?)
+			OR (status = 'failed' AND next_attempt_at <= ?))
+			AND (claimed_until IS NULL OR claimed_until <= ?) AND NOT `+inTrash+`
+		ORDER BY trigger_time, id
+		LIMIT ?
+	)
+	RETURNING `+reminderColumns,
+		owner, claimUntil.UTC(), time.Now(), now, now, now, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanReminders(rows)
+}
+
+// FinishReminderDelivery сохраняет результат отправки и снимает захват.
+// Если напоминание успели отменить или его забрал другой процесс - Conflict.
+func (s *SQLiteStorage) FinishReminderDelivery(owner string, r *manager.Reminder) error {
+	result, err := s.db.Exec(`
+	UPDATE reminders SET status = ?, last_error = ?, next_attempt_at = ?, sent_at = ?,
+		claimed_by = NULL, claimed_until = NULL, updated_at = ?
+	WHERE id = ? AND claimed_by = ? AND status = 'pending'`,
+		string(r.Status), nullString(r.LastError), utcPtr(r.NextAttemptAt), utcPtr(r.SentAt),
+		time.Now(), r.ID, owner,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return manager.Conflict("напоминание с ID %d уже обработано или отменено", r.ID)
+	}
+	return nil
+}
+
+// NextReminderTime возвращает ближайшее время срабатывания или повтора
+// среди незабранных напоминаний; nil - ждать нечего
+func (s *SQLiteStorage) NextReminderTime(now time.Time) (*time.Time, error) {
+	now = now.UTC()
+	queries := []string{
+		`SELECT trigger_time FROM reminders
+		WHERE status = 'pending' AND (claimed_until IS NULL OR claimed_until <= ?) AND NOT ` + inTrash + `
+		ORDER BY trigger_time LIMIT 1`,
+		`SELECT next_attempt_at FROM reminders
+		WHERE status = 'failed' AND next_attempt_at IS NOT NULL AND (claimed_until IS NULL OR claimed_until <= ?)
+			AND NOT ` + inTrash + `
+		ORDER BY next_attempt_at LIMIT 1`,
+	}
+
+	var next *time.Time
+	for _, query := range queries {
+		var t time.Time
+		err := s.db.QueryRow(query, now).Scan(&t)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if next == nil || t.Before(*next) {
+			next = &t
+		}
+	}
+	return next, nil
+}
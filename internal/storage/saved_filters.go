@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"todo-app/internal/manager"
+)
+
+const savedFilterColumns = "id, user_id, name, options, sort, created_at, updated_at"
+
+func scanSavedFilter(row rowScanner) (*manager.SavedFilter, error) {
+	var f manager.SavedFilter
+	var options, sort string
+	if err := row.Scan(&f.ID, &f.UserID, &f.Name, &options, &sort, &f.CreatedAt, &f.UpdatedAt); err != nil {
+		return nil, err
+	}
+	f.Sort = manager.SortOrder(sort)
+	if err := json.Unmarshal([]byte(options), &f.Options); err != nil {
+		return nil, fmt.Errorf("условия фильтра %d: %v", f.ID, err)
+	}
+	return &f, nil
+}
+
+// filterNameKey - ключ уникальности имени, как в SavedFilterManager
+func filterNameKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// CreateSavedFilter сохраняет фильтр; условия хранятся в JSON
+func (s *SQLiteStorage) CreateSavedFilter(f *manager.SavedFilter) (int, error) {
+	options, err := json.Marshal(f.Options)
+	if err != nil {
+		return 0, err
+	}
+	result, err := s.db.Exec(`
+	INSERT INTO saved_filters (user_id, name, name_key, options, sort, created_at, updated_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		f.UserID, f.Name, filterNameKey(f.Name), string(options), string(f.Sort), f.CreatedAt, f.UpdatedAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// GetSavedFilters возвращает фильтры пользователя по алфавиту
+func (s *SQLiteStorage) GetSavedFilters(userID int) ([]manager.SavedFilter, error) {
+	rows, err := s.db.Query("SELECT "+savedFilterColumns+" FROM saved_filters WHERE user_id = ? ORDER BY name_key, id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	filters := []manager.SavedFilter{}
+	for rows.Next() {
+		f, err := scanSavedFilter(rows)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, *f)
+	}
+	return filters, rows.Err()
+}
+
+func (s *SQLiteStorage) GetSavedFilter(userID, id int) (*manager.SavedFilter, error) {
+	f, err := scanSavedFilter(s.db.QueryRow("SELECT "+savedFilterColumns+" FROM saved_filters WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, manager.NotFound("фильтр с ID %d не найден", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if f.UserID != userID {
+		return nil, manager.Forbidden("фильтр с ID %d принадлежит другому пользователю", id)
+	}
+	return f, nil
+}
+
+// UpdateSavedFilter сохраняет название, условия и сортировку фильтра
+func (s *SQLiteStorage) UpdateSavedFilter(f *manager.SavedFilter) error {
+	options, err := json.Marshal(f.Options)
+	if err != nil {
+		return err
+	}
+	result, err := s.db.Exec(`
+	UPDATE saved_filters SET name = ?, name_key = ?, options = ?, sort = ?, updated_at = ?
+	WHERE id = ? AND user_id = ?`,
+		f.Name, filterNameKey(f.Name), string(options), string(f.Sort), f.UpdatedAt, f.ID, f.UserID,
+	)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return manager.NotFound("фильтр с ID %d не найден", f.ID)
+	}
+	return nil
+}
+
+func (s *SQLiteStorage) DeleteSavedFilter(userID, id int) error {
+	if _, err := s.GetSavedFilter(userID, id); err != nil {
+		return err
+	}
+	_, err := s.db.Exec("DELETE FROM saved_filters WHERE id = ? AND user_id = ?", id, userID)
+	return err
+}
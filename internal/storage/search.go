@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"todo-app/internal/manager"
+)
+
+// Search ищет задачи, которые видит пользователь, через FTS5-таблицу task_search
+// (миграция 010). Совпадения в описании весят вдвое больше, чем в
+// подзадачах; лучшие результаты идут первыми.
+func (s *SQLiteStorage) Search(userID int, query string, options manager.FilterOptions) ([]manager.SearchResult, error) {
+	q, err := manager.ParseSearchQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	conditions, filterArgs, err := filterConditions(options)
+	if err != nil {
+		return nil, err
+	}
+	sqlQuery := "SELECT " + taskColumns + `, m.snippet FROM tasks
+	JOIN (
+		SELECT rowid, snippet(task_search, -1, ?, ?, '…', ?) AS snippet, bm25(task_search, 2.0, 1.0) AS score
+		FROM task_search WHERE task_search MATCH ?
+	) m ON m.rowid = tasks.id
+	WHERE ` + visibleTask + conditions + `
+	ORDER BY m.score, tasks.id
+	LIMIT ?`
+	args := []interface{}{manager.HighlightStart, manager.HighlightEnd, manager.SnippetWords, q.MatchExpression()}
+	args = append(args, visibleArgs(userID)...)
+	args = append(args, filterArgs...)
+	args = append(args, manager.SearchLimit)
+
+	rows, err := s.db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []manager.SearchResult{}
+	for rows.Next() {
+		var snippet string
+		task, err := scanTask(withExtraColumns(rows, &snippet))
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, manager.SearchResult{Task: *task, Snippet: snippet})
+	}
+	return results, rows.Err()
+}
+
+// extraColumns дочитывает колонки, выбранные после taskColumns
+type extraColumns struct {
+	row   rowScanner
+	extra []interface{}
+}
+
+func withExtraColumns(row rowScanner, extra ...interface{}) rowScanner {
+	return extraColumns{row: row, extra: extra}
+}
+
+func (e extraColumns) Scan(dest ...interface{}) error {
+	return e.row.Scan(append(dest, e.extra...)...)
+}
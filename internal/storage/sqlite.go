@@ -2,96 +2,116 @@ package storage
 
 import (
 	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"maps"
+	"net/url"
+	"slices"
 	"strings"
 	"time"
 	"todo-app/internal/manager"
-	
 
-	_ "modernc.org/sqlite"
+	"modernc.org/sqlite"
 )
 
+func init() {
+	// Встроенный lower() в SQLite понимает только ASCII,
+	// а теги у нас в основном на кириллице
+	sqlite.MustRegisterDeterministicScalarFunction("casefold", 1,
+		func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+			switch v := args[0].(type) {
+			case string:
+				return strings.ToLower(v), nil
+			case []byte:
+				return strings.ToLower(string(v)), nil
+			}
+			return args[0], nil
+		})
+}
+
 type SQLiteStorage struct {
 	db *sql.DB
 }
 
 func NewSQLiteStorage(dbPath string) (*SQLiteStorage, error) {
-	db, err := sql.Open("sqlite", dbPath) // "sqlite" вместо "sqlite3"
+	db, err := OpenDB(dbPath)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка открытия БД: %v", err)
+		return nil, err
 	}
 
-	// Проверяем соединение
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("ошибка подключения к БД: %v", err)
+	// Приводим схему к последней версии
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		db.Close()
+		return nil, err
 	}
-
-	// Создаем таблицы
-	if err := createTables(db); err != nil {
+	if _, err := migrator.Up(); err != nil {
+		db.Close()
 		return nil, err
 	}
 
-	log.Printf("SQLite база данных инициализирована: %s", dbPath)
+	log.Printf("SQLite база данных инициализирована: %s (версия схемы %d)", dbPath, migrator.Latest())
 	return &SQLiteStorage{db: db}, nil
 }
 
-func createTables(db *sql.DB) error {
-    // Таблица пользователей (ДОБАВЛЯЕМ ПЕРВОЙ)
-    createUsersTable := `
-    CREATE TABLE IF NOT EXISTS users (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        device_id TEXT UNIQUE NOT NULL,
-        telegram_id INTEGER UNIQUE,
-        fcm_token TEXT,
-        created_at DATETIME NOT NULL,
-        updated_at DATETIME NOT NULL
-    )`
-
-    // Таблица задач (уже существует)
-    createTasksTable := `
-    CREATE TABLE IF NOT EXISTS tasks (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        user_id INTEGER REFERENCES users(id),
-        description TEXT NOT NULL,
-        created_at DATETIME NOT NULL,
-        updated_at DATETIME NOT NULL,
-        completed BOOLEAN NOT NULL DEFAULT FALSE,
-        priority TEXT NOT NULL DEFAULT 'medium',
-        due_date DATETIME,
-        tags TEXT
-    )`
-
-    // Таблица подзадач (уже существует)
-    createSubTasksTable := `
-    CREATE TABLE IF NOT EXISTS subtasks (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        user_id INTEGER REFERENCES users(id),
-        task_id INTEGER NOT NULL,
-        description TEXT NOT NULL,
-        created_at DATETIME NOT NULL,
-        updated_at DATETIME NOT NULL,
-        completed BOOLEAN NOT NULL DEFAULT FALSE,
-        FOREIGN KEY (task_id) REFERENCES tasks (id) ON DELETE CASCADE
-    )`
-
-    // Создаем таблицы в правильном порядке
-    _, err := db.Exec(createUsersTable)
-    if err != nil {
-        return fmt.Errorf("ошибка создания таблицы users: %v", err)
-    }
+// OpenDB открывает базу и проверяет соединение. Таймаут ожидания
+// блокировки нужен, потому что веб-сервер и бот работают с одним файлом.
+// Транзакции сразу берут блокировку записи: иначе при одновременной записи
+// SQLite видит взаимную блокировку и отвечает SQLITE_BUSY, не дожидаясь таймаута.
+// Формат времени sqlite позволяет сравнивать даты прямо в SQL.
+func OpenDB(dbPath string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", sqliteDSN(dbPath)) // "sqlite" вместо "sqlite3"
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия БД: %v", err)
+	}
 
-    _, err = db.Exec(createTasksTable)
-    if err != nil {
-        return fmt.Errorf("ошибка создания таблицы tasks: %v", err)
-    }
+	// Проверяем соединение
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ошибка подключения к БД: %v", err)
+	}
+	return db, nil
+}
 
-    _, err = db.Exec(createSubTasksTable)
-    if err != nil {
-        return fmt.Errorf("ошибка создания таблицы subtasks: %v", err)
-    }
+// sqliteDSN дописывает к пути параметры из OpenDB, которых в нем нет.
+// Параметры, заданные в пути явно (например, ?_txlock=deferred), остаются.
+func sqliteDSN(dbPath string) string {
+	query := ""
+	if i := strings.Index(dbPath, "?"); i >= 0 {
+		query = dbPath[i+1:]
+	}
+	values, _ := url.ParseQuery(query)
 
-    return nil
+	var missing []string
+	busyTimeout := false
+	for _, pragma := range values["_pragma"] {
+		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(pragma)), "busy_timeout") {
+			busyTimeout = true
+		}
+	}
+	if !busyTimeout {
+		missing = append(missing, "_pragma=busy_timeout(5000)")
+	}
+	if !values.Has("_time_format") {
+		missing = append(missing, "_time_format=sqlite")
+	}
+	if !values.Has("_txlock") {
+		missing = append(missing, "_txlock=immediate")
+	}
+	if len(missing) == 0 {
+		return dbPath
+	}
+
+	separator := "?"
+	if strings.HasSuffix(dbPath, "?") || strings.HasSuffix(dbPath, "&") {
+		separator = ""
+	} else if strings.Contains(dbPath, "?") {
+		separator = "&"
+	}
+	return dbPath + separator + strings.Join(missing, "&")
 }
 
 // Закрытие соединения
@@ -99,122 +119,250 @@ func (s *SQLiteStorage) Close() error {
 	return s.db.Close()
 }
 
-// Методы для работы с задачами
-func (s *SQLiteStorage) AddTask(description string, tags []string) (int, error) {
-    // Для обратной совместимости - используем user_id = 1
-    return s.AddTaskForUser(1, description, tags)
+// Колонки задачи в порядке, который ожидают scanTask и scanTasks.
+// Теги собираются из task_tags в JSON-массив: запятая в имени тега
+// не ломает разбор.
+const taskColumns = "id, description, created_at, updated_at, completed, priority, due_date, " + taskTags + ", user_id, recurrence, series_id, project_id, assignee_id, deleted_at, version"
+
+const taskTags = `(SELECT json_group_array(g.name ORDER BY tt.position)
+	FROM task_tags tt JOIN tags g ON g.id = tt.tag_id WHERE tt.task_id = tasks.id)`
+
+// Срок хранится с временем, а фильтры работают с датами.
+// substr одинаково работает и со старым форматом времени, и с новым.
+const dueDay = "substr(due_date, 1, 10)"
+
+// visibleTask - условие на задачи, которые видит пользователь: задачи его
+// проектов и общих проектов, где он участник, а также его задачи без
+// проекта. Задачи в корзине не видны. Аргументы - visibleArgs.
+const visibleTask = "tasks.deleted_at IS NULL AND " + accessibleTask
+
+// accessibleTask - то же без учета корзины
+const accessibleTask = `(tasks.project_id IN (SELECT id FROM projects WHERE user_id = ?
+		UNION SELECT project_id FROM project_members WHERE user_id = ?)
+	OR (tasks.project_id IS NULL AND tasks.user_id = ?))`
+
+func visibleArgs(userID int) []interface{} {
+	return []interface{}{userID, userID, userID}
+}
+
+func dateOnly(t time.Time) string {
+	return t.Format("2006-01-02")
 }
 
+// Методы для работы с задачами
+
 // AddTaskForUser - новый метод для добавления задач с указанием пользователя
 func (s *SQLiteStorage) AddTaskForUser(userID int, description string, tags []string) (int, error) {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return 0, err
+    }
+    defer tx.Rollback()
+
+    // Новая задача попадает во Входящие
+    inboxID, err := ensureInbox(tx, userID)
+    if err != nil {
+        return 0, err
+    }
+
     query := `
-    INSERT INTO tasks (description, created_at, updated_at, completed, priority, due_date, tags, user_id)
+    INSERT INTO tasks (description, created_at, updated_at, completed, priority, due_date, user_id, project_id)
     VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
 
     now := time.Now()
-    tagsStr := ""
-    if len(tags) > 0 {
-        tagsStr = strings.Join(tags, ",")
+    result, err := tx.Exec(query, 
+        description, now, now, false, "medium", nil, userID, inboxID)
+    if err != nil {
+        return 0, err
     }
 
-    result, err := s.db.Exec(query, 
-        description, now, now, false, "medium", nil, tagsStr, userID)
+    id, err := result.LastInsertId()
     if err != nil {
         return 0, err
     }
+    if err := setTaskTags(tx, userID, int(id), tags); err != nil {
+        return 0, err
+    }
+    return int(id), tx.Commit()
+}
 
-    id, err := result.LastInsertId()
-    return int(id), err
+// CreateTask сохраняет задачу со всеми полями и подзадачами с описаниями
+// subtasks одной транзакцией (следующий экземпляр серии с копией подзадач)
+func (s *SQLiteStorage) CreateTask(task *manager.Task, subtasks []string) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id, err := insertTask(tx, task)
+	if err != nil {
+		return 0, err
+	}
+	for _, description := range subtasks {
+		if _, err := tx.Exec(`
+		INSERT INTO subtasks (task_id, user_id, description, created_at, updated_at, completed)
+		VALUES (?, ?, ?, ?, ?, FALSE)`,
+			id, task.UserID, description, task.CreatedAt, task.CreatedAt); err != nil {
+			return 0, err
+		}
+	}
+	return id, tx.Commit()
 }
 
-func (s *SQLiteStorage) GetAllTasks() ([]manager.Task, error) {
-	query := `
-	SELECT id, description, created_at, updated_at, completed, priority, due_date, tags, user_id
-	FROM tasks ORDER BY created_at DESC`
+// insertTask сохраняет задачу со всеми полями и тегами в транзакции tx;
+// без ProjectID - во Входящие автора
+func insertTask(tx *sql.Tx, task *manager.Task) (int, error) {
+	var dueDate interface{}
+	if !task.DueDate.IsZero() {
+		dueDate = task.DueDate
+	}
+
+	projectID := task.ProjectID
+	if projectID == 0 {
+		var err error
+		if projectID, err = ensureInbox(tx, task.UserID); err != nil {
+			return 0, err
+		}
+	}
+
+	result, err := tx.Exec(`
+	INSERT INTO tasks (description, created_at, updated_at, completed, priority, due_date, user_id, recurrence, series_id, project_id, assignee_id)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		task.Description, task.CreatedAt, task.UpdatedAt, task.Completed, string(task.Priority),
+		dueDate, task.UserID,
+		nullString(task.Recurrence), nullInt64(int64(task.SeriesID)), projectID, nullInt64(int64(task.AssigneeID)),
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err := setTaskTags(tx, task.UserID, int(id), task.Tags); err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
 
-	rows, err := s.db.Query(query)
+// GetSeriesTasks возвращает экземпляры серии повторений по сроку
+func (s *SQLiteStorage) GetSeriesTasks(userID, seriesID int) ([]manager.Task, error) {
+	query := "SELECT " + taskColumns + " FROM tasks WHERE user_id = ? AND series_id = ? AND deleted_at IS NULL ORDER BY due_date, id"
+	rows, err := s.db.Query(query, userID, seriesID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
+	return scanTasks(rows)
+}
 
-	var tasks []manager.Task
-	for rows.Next() {
-		var task manager.Task
-		var dueDate sql.NullTime
-		var tagsStr sql.NullString
-		var priority string
-		var userID int
-
-		err := rows.Scan(
-			&task.ID, &task.Description, &task.CreatedAt, &task.UpdatedAt,
-			&task.Completed, &priority, &dueDate, &tagsStr, &userID,
-		)
-		if err != nil {
-			return nil, err
-		}
+// GetTask возвращает задачу автора userID. Права участников общих
+// проектов проверяет manager, см. TaskManager.AuthorizeTask.
+func (s *SQLiteStorage) GetTask(userID, id int) (*manager.Task, error) {
+	return ownTask(s.db, userID, id)
+}
 
-		task.UserID = userID
-		task.Priority = manager.Priority(priority)
+// GetTaskByID возвращает задачу без проверки доступа; задачи в корзине
+// не находятся, см. GetDeletedTaskByID
+func (s *SQLiteStorage) GetTaskByID(id int) (*manager.Task, error) {
+	return taskByID(s.db, id)
+}
 
-		if dueDate.Valid {
-			task.DueDate = dueDate.Time
-		}
+// queryRower - общее у *sql.DB и *sql.Tx для чтения одной строки
+type queryRower interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
 
-		if tagsStr.Valid && tagsStr.String != "" {
-			task.Tags = strings.Split(tagsStr.String, ",")
-		} else {
-			task.Tags = []string{}
-		}
+func ownTask(db queryRower, userID, id int) (*manager.Task, error) {
+	task, err := taskByID(db, id)
+	if err != nil {
+		return nil, err
+	}
 
-		tasks = append(tasks, task)
+	if task.UserID != userID {
+		return nil, manager.Forbidden("задача с ID %d принадлежит другому пользователю", id)
 	}
 
-	return tasks, nil
+	return task, nil
 }
 
-func (s *SQLiteStorage) GetTask(id int) (*manager.Task, error) {
-	query := `
-	SELECT id, description, created_at, updated_at, completed, priority, due_date, tags, user_id
-	FROM tasks WHERE id = ?`
+func taskByID(db queryRower, id int) (*manager.Task, error) {
+	query := "SELECT " + taskColumns + " FROM tasks WHERE id = ? AND deleted_at IS NULL"
 
-	var task manager.Task
-	var dueDate sql.NullTime
-	var tagsStr sql.NullString
-	var priority string
-	var userID int
+	task, err := scanTask(db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, manager.NotFound("задача с ID %d не найдена", id)
+	}
+	return task, err
+}
 
-	err := s.db.QueryRow(query, id).Scan(
-		&task.ID, &task.Description, &task.CreatedAt, &task.UpdatedAt,
-		&task.Completed, &priority, &dueDate, &tagsStr, &userID,
-	)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("задача с ID %d не найдена", id)
+// updateRetries - сколько раз UpdateTask без ожидаемой версии применяет
+// изменение заново, если задачу успели поменять между чтением и записью
+const updateRetries = 3
+
+// UpdateTask читает задачу, применяет изменения и записывает ее, только
+// если версия с момента чтения не изменилась. С req.Version расхождение
+// возвращается как manager.VersionConflictError, без него изменение
+// применяется заново к свежей задаче, чтобы не затереть чужую правку.
+func (s *SQLiteStorage) UpdateTask(userID, id int, req manager.UpdateTaskRequest) (*manager.Task, error) {
+	for attempt := 1; ; attempt++ {
+		task, err := s.updateTaskVersion(userID, id, req)
+		var conflict *manager.VersionConflictError
+		if req.Version == nil && attempt < updateRetries && errors.As(err, &conflict) {
+			continue
 		}
-		return nil, err
+		return task, err
 	}
+}
 
-	task.UserID = userID
-	task.Priority = manager.Priority(priority)
+func (s *SQLiteStorage) updateTaskVersion(userID, id int, req manager.UpdateTaskRequest) (*manager.Task, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
 
-	if dueDate.Valid {
-		task.DueDate = dueDate.Time
+	task, err := updateTask(tx, userID, id, req)
+	if err != nil {
+		return nil, err
 	}
+	return task, tx.Commit()
+}
 
-	if tagsStr.Valid && tagsStr.String != "" {
-		task.Tags = strings.Split(tagsStr.String, ",")
+// UpdateTasks применяет изменения к задачам автора userID одной
+// транзакцией (правка серии повторений): если не сохранилась одна,
+// не меняется ни одна. Возвращает сохраненные задачи по ID.
+func (s *SQLiteStorage) UpdateTasks(userID int, requests map[int]manager.UpdateTaskRequest) (map[int]manager.Task, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
 	}
+	defer tx.Rollback()
 
-	return &task, nil
+	updated := make(map[int]manager.Task, len(requests))
+	for _, id := range slices.Sorted(maps.Keys(requests)) {
+		task, err := updateTask(tx, userID, id, requests[id])
+		if err != nil {
+			return nil, err
+		}
+		updated[id] = *task
+	}
+	return updated, tx.Commit()
 }
 
-func (s *SQLiteStorage) UpdateTask(id int, req manager.UpdateTaskRequest) (*manager.Task, error) {
-	// Сначала получаем текущую задачу
-	task, err := s.GetTask(id)
+// updateTask читает задачу автора userID, применяет изменения и
+// записывает ее в транзакции tx, если версия с момента чтения не изменилась
+func updateTask(tx *sql.Tx, userID, id int, req manager.UpdateTaskRequest) (*manager.Task, error) {
+	// Сначала получаем текущую задачу (заодно проверяем владельца)
+	task, err := ownTask(tx, userID, id)
 	if err != nil {
 		return nil, err
 	}
+	if req.Version != nil && *req.Version != task.Version {
+		return nil, &manager.VersionConflictError{Current: *task, Expected: *req.Version}
+	}
+	readVersion := task.Version
 
 	// Обновляем поля
 	if req.Description != nil {
@@ -232,19 +380,27 @@ func (s *SQLiteStorage) UpdateTask(id int, req manager.UpdateTaskRequest) (*mana
 	if req.Tags != nil {
 		task.Tags = *req.Tags
 	}
+	if req.Recurrence != nil {
+		task.Recurrence = *req.Recurrence
+		if task.Recurrence != "" && task.SeriesID == 0 {
+			task.SeriesID = task.ID
+		}
+	}
+	if req.ProjectID != nil {
+		task.ProjectID = *req.ProjectID
+	}
+	if req.AssigneeID != nil {
+		task.AssigneeID = *req.AssigneeID
+	}
 
 	task.UpdatedAt = time.Now()
 
 	// Обновляем в базе
 	query := `
 	UPDATE tasks 
-	SET description = ?, updated_at = ?, completed = ?, priority = ?, due_date = ?, tags = ?
-	WHERE id = ?`
-
-	tagsStr := ""
-	if len(task.Tags) > 0 {
-		tagsStr = strings.Join(task.Tags, ",")
-	}
+	SET description = ?, updated_at = ?, completed = ?, priority = ?, due_date = ?,
+		recurrence = ?, series_id = ?, project_id = ?, assignee_id = ?, version = version + 1
+	WHERE id = ? AND user_id = ? AND deleted_at IS NULL AND version = ?`
 
 	var dueDate interface{}
 	if task.DueDate.IsZero() {
@@ -253,20 +409,45 @@ func (s *SQLiteStorage) UpdateTask(id int, req manager.UpdateTaskRequest) (*mana
 		dueDate = task.DueDate
 	}
 
-	_, err = s.db.Exec(query,
+	result, err := tx.Exec(query,
 		task.Description, task.UpdatedAt, task.Completed,
-		string(task.Priority), dueDate, tagsStr, id,
+		string(task.Priority), dueDate,
+		nullString(task.Recurrence), nullInt64(int64(task.SeriesID)), nullInt64(int64(task.ProjectID)),
+		nullInt64(int64(task.AssigneeID)), id, userID, readVersion,
 	)
 	if err != nil {
 		return nil, err
 	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		// Задачу изменили или удалили после чтения
+		current, err := ownTask(tx, userID, id)
+		if err != nil {
+			return nil, err
+		}
+		return nil, &manager.VersionConflictError{Current: *current, Expected: readVersion}
+	}
+	task.Version = readVersion + 1
 
+	if req.Tags != nil {
+		if err := setTaskTags(tx, userID, id, task.Tags); err != nil {
+			return nil, err
+		}
+		if err := pruneTags(tx, userID); err != nil {
+			return nil, err
+		}
+		// Перечитываем задачу: теги хранятся в написании, общем для всех задач
+		return ownTask(tx, userID, id)
+	}
 	return task, nil
 }
 
-func (s *SQLiteStorage) DeleteTask(id int) error {
-	query := "DELETE FROM tasks WHERE id = ?"
-	result, err := s.db.Exec(query, id)
+// DeleteTask перемещает задачу в корзину. Подзадачи, напоминания,
+// комментарии и вложения остаются до очистки корзины, см. PurgeDeletedTasks
+func (s *SQLiteStorage) DeleteTask(userID, id int) error {
+	result, err := s.db.Exec("UPDATE tasks SET deleted_at = ?, version = version + 1 WHERE id = ? AND user_id = ? AND deleted_at IS NULL",
+		time.Now(), id, userID)
 	if err != nil {
 		return err
 	}
@@ -277,62 +458,202 @@ func (s *SQLiteStorage) DeleteTask(id int) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("задача с ID %d не найдена", id)
+		return s.taskAccessError(id)
 	}
-
 	return nil
 }
 
-func (s *SQLiteStorage) ToggleComplete(id int) (*manager.Task, error) {
-	task, err := s.GetTask(id)
+// GetDeletedTaskByID возвращает задачу из корзины без проверки доступа
+func (s *SQLiteStorage) GetDeletedTaskByID(id int) (*manager.Task, error) {
+	query := "SELECT " + taskColumns + " FROM tasks WHERE id = ? AND deleted_at IS NOT NULL"
+
+	task, err := scanTask(s.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, manager.NotFound("задачи с ID %d нет в корзине", id)
+	}
+	return task, err
+}
+
+// GetDeletedTasks возвращает задачи из корзины, которые видит
+// пользователь, недавно удаленные первыми
+func (s *SQLiteStorage) GetDeletedTasks(userID int) ([]manager.Task, error) {
+	query := "SELECT " + taskColumns + " FROM tasks WHERE deleted_at IS NOT NULL AND " + accessibleTask +
+		" ORDER BY deleted_at DESC, id DESC"
+	rows, err := s.db.Query(query, visibleArgs(userID)...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+	return scanTasks(rows)
+}
 
-	task.Completed = !task.Completed
-	task.UpdatedAt = time.Now()
+// RestoreTask возвращает задачу автора userID из корзины
+func (s *SQLiteStorage) RestoreTask(userID, id int) (*manager.Task, error) {
+	result, err := s.db.Exec("UPDATE tasks SET deleted_at = NULL, updated_at = ?, version = version + 1 WHERE id = ? AND user_id = ? AND deleted_at IS NOT NULL",
+		time.Now(), id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, manager.NotFound("задачи с ID %d нет в корзине", id)
+	}
+	return s.GetTask(userID, id)
+}
 
-	query := "UPDATE tasks SET completed = ?, updated_at = ? WHERE id = ?"
-	_, err = s.db.Exec(query, task.Completed, task.UpdatedAt, id)
+// PurgeDeletedTasks окончательно удаляет задачи, попавшие в корзину
+// раньше before, и возвращает их число
+func (s *SQLiteStorage) PurgeDeletedTasks(before time.Time) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Авторы задач - чтобы убрать теги, которые остались без задач
+	rows, err := tx.Query("SELECT DISTINCT user_id FROM tasks WHERE deleted_at < ?", before)
+	if err != nil {
+		return 0, err
+	}
+	var authors []int
+	for rows.Next() {
+		var userID sql.NullInt64
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		authors = append(authors, int(userID.Int64))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// ON DELETE CASCADE не срабатывает без PRAGMA foreign_keys,
+	// поэтому подзадачи, напоминания, комментарии и вложения удаляем явно.
+	// Файлы вложений без записей убирает AttachmentManager.PruneBlobs.
+	// История задач (task_events) сохраняется
+	const purged = "SELECT id FROM tasks WHERE deleted_at < ?"
+	for _, query := range []string{
+		"DELETE FROM subtasks WHERE task_id IN (" + purged + ")",
+		"DELETE FROM reminders WHERE task_id IN (" + purged + ")",
+		"DELETE FROM comments WHERE task_id IN (" + purged + ")",
+		"DELETE FROM attachments WHERE task_id IN (" + purged + ")",
+		"DELETE FROM task_tags WHERE task_id IN (" + purged + ")",
+	} {
+		if _, err := tx.Exec(query, before); err != nil {
+			return 0, err
+		}
+	}
+	result, err := tx.Exec("DELETE FROM tasks WHERE deleted_at < ?", before)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	for _, userID := range authors {
+		if err := pruneTags(tx, userID); err != nil {
+			return 0, err
+		}
+	}
+	return int(n), tx.Commit()
+}
+
+func (s *SQLiteStorage) ToggleComplete(userID, id int) (*manager.Task, error) {
+	query := "UPDATE tasks SET completed = NOT completed, updated_at = ?, version = version + 1 WHERE id = ? AND user_id = ? AND deleted_at IS NULL"
+	result, err := s.db.Exec(query, time.Now(), id, userID)
 	if err != nil {
 		return nil, err
 	}
 
-	return task, nil
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if rowsAffected == 0 {
+		return nil, s.taskAccessError(id)
+	}
+
+	return s.GetTask(userID, id)
+}
+
+// taskAccessError объясняет, почему запрос к задаче пользователя не
+// затронул ни одной строки: задачи нет (или она в корзине) или она чужая
+func (s *SQLiteStorage) taskAccessError(id int) error {
+	var ownerID sql.NullInt64
+	err := s.db.QueryRow("SELECT user_id FROM tasks WHERE id = ? AND deleted_at IS NULL", id).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		return manager.NotFound("задача с ID %d не найдена", id)
+	}
+	if err != nil {
+		return err
+	}
+	return manager.Forbidden("задача с ID %d принадлежит другому пользователю", id)
 }
 
 // Методы для подзадач
-func (s *SQLiteStorage) AddSubTask(taskID int, description string) (int, error) {
+func (s *SQLiteStorage) AddSubTask(userID, taskID int, description string) (int, error) {
+	// Подзадача создается только у задачи того же пользователя
 	query := `
-	INSERT INTO subtasks (task_id, description, created_at, updated_at, completed)
-	VALUES (?, ?, ?, ?, ?)`
+	INSERT INTO subtasks (task_id, user_id, description, created_at, updated_at, completed)
+	SELECT id, user_id, ?, ?, ?, ? FROM tasks WHERE id = ? AND user_id = ? AND deleted_at IS NULL`
 
 	now := time.Now()
-	result, err := s.db.Exec(query, taskID, description, now, now, false)
+	result, err := s.db.Exec(query, description, now, now, false, taskID, userID)
 	if err != nil {
 		return 0, err
 	}
 
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if rowsAffected == 0 {
+		return 0, s.taskAccessError(taskID)
+	}
+
 	id, err := result.LastInsertId()
 	return int(id), err
 }
 
-func (s *SQLiteStorage) GetSubTasks(taskID int) ([]manager.SubTask, error) {
+// GetSubTaskByID возвращает подзадачу без проверки доступа
+func (s *SQLiteStorage) GetSubTaskByID(id int) (*manager.SubTask, error) {
+	var subtask manager.SubTask
+	err := s.db.QueryRow(`
+	SELECT id, user_id, task_id, description, created_at, updated_at, completed
+	FROM subtasks WHERE id = ?`, id).Scan(
+		&subtask.ID, &subtask.UserID, &subtask.TaskID, &subtask.Description,
+		&subtask.CreatedAt, &subtask.UpdatedAt, &subtask.Completed,
+	)
+	if err == sql.ErrNoRows {
+		return nil, manager.NotFound("подзадача с ID %d не найдена", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &subtask, nil
+}
+
+func (s *SQLiteStorage) GetSubTasks(userID, taskID int) ([]manager.SubTask, error) {
 	query := `
-	SELECT id, task_id, description, created_at, updated_at, completed
-	FROM subtasks WHERE task_id = ? ORDER BY created_at`
+	SELECT id, user_id, task_id, description, created_at, updated_at, completed
+	FROM subtasks WHERE task_id = ? AND user_id = ? ORDER BY created_at`
 
-	rows, err := s.db.Query(query, taskID)
+	rows, err := s.db.Query(query, taskID, userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var subtasks []manager.SubTask
+	subtasks := []manager.SubTask{}
 	for rows.Next() {
 		var subtask manager.SubTask
 		err := rows.Scan(
-			&subtask.ID, &subtask.TaskID, &subtask.Description,
+			&subtask.ID, &subtask.UserID, &subtask.TaskID, &subtask.Description,
 			&subtask.CreatedAt, &subtask.UpdatedAt, &subtask.Completed,
 		)
 		if err != nil {
@@ -341,26 +662,32 @@ func (s *SQLiteStorage) GetSubTasks(taskID int) ([]manager.SubTask, error) {
 		subtasks = append(subtasks, subtask)
 	}
 
-	return subtasks, nil
+	return subtasks, rows.Err()
 }
 
-func (s *SQLiteStorage) ToggleSubTask(id int) error {
-	// Получаем текущий статус
-	var completed bool
-	err := s.db.QueryRow("SELECT completed FROM subtasks WHERE id = ?", id).Scan(&completed)
+func (s *SQLiteStorage) ToggleSubTask(userID, id int) error {
+	// Инвертируем статус одним запросом
+	query := "UPDATE subtasks SET completed = NOT completed, updated_at = ? WHERE id = ? AND user_id = ?"
+	result, err := s.db.Exec(query, time.Now(), id, userID)
 	if err != nil {
 		return err
 	}
 
-	// Инвертируем статус
-	query := "UPDATE subtasks SET completed = ?, updated_at = ? WHERE id = ?"
-	_, err = s.db.Exec(query, !completed, time.Now(), id)
-	return err
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return s.subTaskAccessError(id)
+	}
+
+	return nil
 }
 
-func (s *SQLiteStorage) DeleteSubTask(id int) error {
-	query := "DELETE FROM subtasks WHERE id = ?"
-	result, err := s.db.Exec(query, id)
+func (s *SQLiteStorage) DeleteSubTask(userID, id int) error {
+	query := "DELETE FROM subtasks WHERE id = ? AND user_id = ?"
+	result, err := s.db.Exec(query, id, userID)
 	if err != nil {
 		return err
 	}
@@ -371,29 +698,36 @@ func (s *SQLiteStorage) DeleteSubTask(id int) error {
 	}
 
 	if rowsAffected == 0 {
-		return fmt.Errorf("подзадача с ID %d не найдена", id)
+		return s.subTaskAccessError(id)
 	}
 
 	return nil
 }
 
-// Методы фильтрации (упрощенные версии)
-func (s *SQLiteStorage) FilterTasks(completed *bool) ([]manager.Task, error) {
-    query := "SELECT id, description, created_at, updated_at, completed, priority, due_date, tags, user_id FROM tasks"
-    if completed != nil {
-        query += " WHERE completed = ?"
-    }
-    query += " ORDER BY created_at DESC"
-
-    var rows *sql.Rows
-    var err error
+// subTaskAccessError - аналог taskAccessError для подзадач
+func (s *SQLiteStorage) subTaskAccessError(id int) error {
+	var ownerID sql.NullInt64
+	err := s.db.QueryRow("SELECT user_id FROM subtasks WHERE id = ?", id).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		return manager.NotFound("подзадача с ID %d не найдена", id)
+	}
+	if err != nil {
+		return err
+	}
+	return manager.Forbidden("подзадача с ID %d принадлежит другому пользователю", id)
+}
 
+// Методы фильтрации
+func (s *SQLiteStorage) FilterTasks(userID int, completed *bool) ([]manager.Task, error) {
+    query := "SELECT " + taskColumns + " FROM tasks WHERE " + visibleTask
+    args := visibleArgs(userID)
     if completed != nil {
-        rows, err = s.db.Query(query, *completed)
-    } else {
-        rows, err = s.db.Query(query)
+        query += " AND completed = ?"
+        args = append(args, *completed)
     }
+    query += " ORDER BY created_at DESC"
 
+    rows, err := s.db.Query(query, args...)
     if err != nil {
         return nil, err
     }
@@ -402,48 +736,67 @@ func (s *SQLiteStorage) FilterTasks(completed *bool) ([]manager.Task, error) {
     return scanTasks(rows)
 }
 
+// rowScanner - общий интерфейс *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanTask читает одну задачу, выбранную с колонками taskColumns
+func scanTask(row rowScanner) (*manager.Task, error) {
+	var task manager.Task
+	var dueDate, deletedAt sql.NullTime
+	var tagsJSON string
+	var priority string
+	var userID, seriesID, projectID, assigneeID sql.NullInt64
+	var recurrence sql.NullString
+
+	err := row.Scan(
+		&task.ID, &task.Description, &task.CreatedAt, &task.UpdatedAt,
+		&task.Completed, &priority, &dueDate, &tagsJSON, &userID,
+		&recurrence, &seriesID, &projectID, &assigneeID, &deletedAt, &task.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	task.UserID = int(userID.Int64)
+	task.Recurrence = recurrence.String
+	task.SeriesID = int(seriesID.Int64)
+	task.ProjectID = int(projectID.Int64)
+	task.AssigneeID = int(assigneeID.Int64)
+	task.Priority = manager.Priority(priority)
+
+	if dueDate.Valid {
+		task.DueDate = dueDate.Time
+	}
+	task.DeletedAt = nullTimePtr(deletedAt)
+
+	if err := json.Unmarshal([]byte(tagsJSON), &task.Tags); err != nil {
+		return nil, fmt.Errorf("теги задачи %d: %v", task.ID, err)
+	}
+
+	return &task, nil
+}
+
 // Вспомогательная функция для сканирования задач
 func scanTasks(rows *sql.Rows) ([]manager.Task, error) {
-	var tasks []manager.Task
+	tasks := []manager.Task{}
 	for rows.Next() {
-		var task manager.Task
-		var dueDate sql.NullTime
-		var tagsStr sql.NullString
-		var priority string
-		var userID int
-
-		err := rows.Scan(
-			&task.ID, &task.Description, &task.CreatedAt, &task.UpdatedAt,
-			&task.Completed, &priority, &dueDate, &tagsStr, &userID,
-		)
+		task, err := scanTask(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		task.UserID = userID
-		task.Priority = manager.Priority(priority)
-
-		if dueDate.Valid {
-			task.DueDate = dueDate.Time
-		}
-
-		if tagsStr.Valid && tagsStr.String != "" {
-			task.Tags = strings.Split(tagsStr.String, ",")
-		} else {
-			task.Tags = []string{}
-		}
-
-		tasks = append(tasks, task)
+		tasks = append(tasks, *task)
 	}
 
-	return tasks, nil
+	return tasks, rows.Err()
 }
 
 // Фильтрация по приоритету
-func (s *SQLiteStorage) FilterByPriority(priority manager.Priority) ([]manager.Task, error) {
-	query := "SELECT id, description, created_at, updated_at, completed, priority, due_date, tags, user_id FROM tasks WHERE priority = ? ORDER BY created_at DESC"
+func (s *SQLiteStorage) FilterByPriority(userID int, priority manager.Priority) ([]manager.Task, error) {
+	query := "SELECT " + taskColumns + " FROM tasks WHERE " + visibleTask + " AND priority = ? ORDER BY created_at DESC"
 	
-	rows, err := s.db.Query(query, string(priority))
+	rows, err := s.db.Query(query, append(visibleArgs(userID), string(priority))...)
 	if err != nil {
 		return nil, err
 	}
@@ -452,15 +805,15 @@ func (s *SQLiteStorage) FilterByPriority(priority manager.Priority) ([]manager.T
 	return scanTasks(rows)
 }
 
+// tagCondition ищет тег задачи по ключу tagKey, без учета регистра
+const tagCondition = `EXISTS (SELECT 1 FROM task_tags tt JOIN tags g ON g.id = tt.tag_id
+	WHERE tt.task_id = tasks.id AND g.name_key = ?)`
+
 // Фильтрация по тегу
-func (s *SQLiteStorage) FilterByTag(tag string) ([]manager.Task, error) {
-    query := `
-        SELECT id, description, created_at, updated_at, completed, priority, due_date, tags, user_id 
-        FROM tasks 
-        WHERE tags LIKE ? 
-        ORDER BY created_at DESC`
+func (s *SQLiteStorage) FilterByTag(userID int, tag string) ([]manager.Task, error) {
+    query := "SELECT " + taskColumns + " FROM tasks WHERE " + visibleTask + " AND " + tagCondition + " ORDER BY created_at DESC"
     
-    rows, err := s.db.Query(query, "%"+strings.TrimSpace(tag)+"%")
+    rows, err := s.db.Query(query, append(visibleArgs(userID), tagKey(tag))...)
     if err != nil {
         return nil, err
     }
@@ -469,16 +822,15 @@ func (s *SQLiteStorage) FilterByTag(tag string) ([]manager.Task, error) {
     return scanTasks(rows)
 }
 
-// Предстоящие задачи
-func (s *SQLiteStorage) GetUpcomingTasks(days int) ([]manager.Task, error) {
-	query := `
-	SELECT id, description, created_at, updated_at, completed, priority, due_date, tags, user_id 
-	FROM tasks 
-	WHERE due_date BETWEEN date('now') AND date('now', ? || ' days') 
+// Предстоящие задачи: срок от сегодня до сегодня + days включительно
+func (s *SQLiteStorage) GetUpcomingTasks(userID, days int) ([]manager.Task, error) {
+	query := "SELECT " + taskColumns + ` FROM tasks 
+	WHERE ` + visibleTask + ` AND ` + dueDay + ` BETWEEN ? AND ?
 	AND completed = false 
 	ORDER BY due_date`
 	
-	rows, err := s.db.Query(query, fmt.Sprintf("+%d", days))
+	today := time.Now()
+	rows, err := s.db.Query(query, append(visibleArgs(userID), dateOnly(today), dateOnly(today.AddDate(0, 0, days)))...)
 	if err != nil {
 		return nil, err
 	}
@@ -487,14 +839,12 @@ func (s *SQLiteStorage) GetUpcomingTasks(days int) ([]manager.Task, error) {
 	return scanTasks(rows)
 }
 
-func (s *SQLiteStorage) FilterByDateRange(start, end time.Time) ([]manager.Task, error) {
-    query := `
-        SELECT id, description, created_at, updated_at, completed, priority, due_date, tags, user_id 
-        FROM tasks 
-        WHERE due_date BETWEEN ? AND ?
+func (s *SQLiteStorage) FilterByDateRange(userID int, start, end time.Time) ([]manager.Task, error) {
+    query := "SELECT " + taskColumns + ` FROM tasks 
+        WHERE ` + visibleTask + ` AND ` + dueDay + ` BETWEEN ? AND ?
         ORDER BY due_date`
     
-    rows, err := s.db.Query(query, start.Format("2006-01-02"), end.Format("2006-01-02"))
+    rows, err := s.db.Query(query, append(visibleArgs(userID), dateOnly(start), dateOnly(end))...)
     if err != nil {
         return nil, err
     }
@@ -504,9 +854,42 @@ func (s *SQLiteStorage) FilterByDateRange(start, end time.Time) ([]manager.Task,
 }
 
 // FilterTasksAdvanced - расширенная фильтрация
-func (s *SQLiteStorage) FilterTasksAdvanced(options manager.FilterOptions) ([]manager.Task, error) {
-    query := "SELECT id, description, created_at, updated_at, completed, priority, due_date, tags, user_id FROM tasks WHERE 1=1"
+func (s *SQLiteStorage) FilterTasksAdvanced(userID int, options manager.FilterOptions) ([]manager.Task, error) {
+    conditions, args, err := filterConditions(options)
+    if err != nil {
+        return nil, err
+    }
+    query := "SELECT " + taskColumns + " FROM tasks WHERE " + visibleTask + conditions
+    args = append(visibleArgs(userID), args...)
+    
+    // Сначала задачи со сроком, как в in-memory режиме
+    query += " ORDER BY due_date IS NULL, due_date, id"
+    
+    rows, err := s.db.Query(query, args...)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    return scanTasks(rows)
+}
+
+// filterConditions превращает options в условия " AND ..." для WHERE
+// по таблице tasks и их аргументы
+func filterConditions(options manager.FilterOptions) (string, []interface{}, error) {
+    query := ""
     var args []interface{}
+
+    // Запрос на языке запросов; ошибка разбора - ErrInvalid
+    expr, err := manager.ParseTaskQuery(options.Query)
+    if err != nil {
+        return "", nil, err
+    }
+    if expr != nil {
+        condition, exprArgs := queryCondition(expr, time.Now())
+        query += " AND " + condition
+        args = append(args, exprArgs...)
+    }
     
     // Фильтр по статусу
     if options.Completed != nil {
@@ -519,25 +902,37 @@ func (s *SQLiteStorage) FilterTasksAdvanced(options manager.FilterOptions) ([]ma
         query += " AND priority = ?"
         args = append(args, string(*options.Priority))
     }
+
+    // Фильтр по проекту
+    if options.ProjectID != nil {
+        query += " AND project_id = ?"
+        args = append(args, *options.ProjectID)
+    }
+
+    // Фильтр по исполнителю; 0 - задачи без исполнителя
+    if options.AssigneeID != nil {
+        query += " AND COALESCE(assignee_id, 0) = ?"
+        args = append(args, *options.AssigneeID)
+    }
     
-    // Фильтр по тегам (простая реализация)
+    // Фильтр по тегам: достаточно совпадения любого из них
     if len(options.Tags) > 0 {
+        var conditions []string
         for _, tag := range options.Tags {
-            query += " AND tags LIKE ?"
-            args = append(args, "%"+tag+"%")
+            conditions = append(conditions, tagCondition)
+            args = append(args, tagKey(tag))
         }
+        query += " AND (" + strings.Join(conditions, " OR ") + ")"
     }
     
     // Фильтр по диапазону дат
-    if options.StartDate != nil && options.EndDate != nil {
-        query += " AND due_date BETWEEN ? AND ?"
-        args = append(args, *options.StartDate, *options.EndDate)
-    } else if options.StartDate != nil {
-        query += " AND due_date >= ?"
-        args = append(args, *options.StartDate)
-    } else if options.EndDate != nil {
-        query += " AND due_date <= ?"
-        args = append(args, *options.EndDate)
+    if options.StartDate != nil {
+        query += " AND " + dueDay + " >= ?"
+        args = append(args, dateOnly(*options.StartDate))
+    }
+    if options.EndDate != nil {
+        query += " AND " + dueDay + " <= ?"
+        args = append(args, dateOnly(*options.EndDate))
     }
     
     // Фильтр по наличию дата
@@ -548,65 +943,100 @@ func (s *SQLiteStorage) FilterTasksAdvanced(options manager.FilterOptions) ([]ma
             query += " AND due_date IS NULL"
         }
     }
-    
-    query += " ORDER BY created_at DESC"
-    
-    rows, err := s.db.Query(query, args...)
+    return query, args, nil
+}
+
+// 🆕 Методы для работы с пользователями
+const userColumns = "id, device_id, telegram_id, fcm_token, username, email, password_hash, created_at, updated_at"
+
+// nullString и nullInt64 сохраняют пустые значения как NULL:
+// у telegram_id, username и email уникальные индексы
+func nullString(v string) sql.NullString {
+    return sql.NullString{String: v, Valid: v != ""}
+}
+
+func nullInt64(v int64) sql.NullInt64 {
+    return sql.NullInt64{Int64: v, Valid: v != 0}
+}
+
+func scanUser(row rowScanner) (*manager.User, error) {
+    var user manager.User
+    var telegramID sql.NullInt64
+    var fcmToken, username, email, passwordHash sql.NullString
+    err := row.Scan(
+        &user.ID,
+        &user.DeviceID,
+        &telegramID,
+        &fcmToken,
+        &username,
+        &email,
+        &passwordHash,
+        &user.CreatedAt,
+        &user.UpdatedAt,
+    )
+    if err == sql.ErrNoRows {
+        return nil, manager.NotFound("пользователь не найден")
+    }
     if err != nil {
         return nil, err
     }
-    defer rows.Close()
-
-    return scanTasks(rows)
+    user.TelegramID = telegramID.Int64
+    user.FCMToken = fcmToken.String
+    user.Username = username.String
+    user.Email = email.String
+    user.PasswordHash = passwordHash.String
+    return &user, nil
 }
 
-// 🆕 Методы для работы с пользователями
 func (s *SQLiteStorage) CreateUser(user *manager.User) (int, error) {
     query := `
-    INSERT INTO users (device_id, telegram_id, fcm_token, created_at, updated_at)
-    VALUES (?, ?, ?, ?, ?)`
+    INSERT INTO users (device_id, telegram_id, fcm_token, username, email, password_hash, created_at, updated_at)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
 
     result, err := s.db.Exec(query,
         user.DeviceID,
-        user.TelegramID,
+        nullInt64(user.TelegramID),
         user.FCMToken,
+        nullString(user.Username),
+        nullString(user.Email),
+        nullString(user.PasswordHash),
         user.CreatedAt,
         user.UpdatedAt,
     )
     if err != nil {
-        return 0, err
+        return 0, userConstraintError(err)
     }
 
     id, err := result.LastInsertId()
     return int(id), err
 }
 
-func (s *SQLiteStorage) GetUserByTelegramID(telegramID int64) (*manager.User, error) {
-    query := `SELECT id, device_id, telegram_id, fcm_token, created_at, updated_at 
-              FROM users WHERE telegram_id = ?`
-
-    var user manager.User
-    err := s.db.QueryRow(query, telegramID).Scan(
-        &user.ID,
-        &user.DeviceID,
-        &user.TelegramID,
-        &user.FCMToken,
-        &user.CreatedAt,
-        &user.UpdatedAt,
-    )
-    if err != nil {
-        return nil, err
+// userConstraintError превращает нарушение уникальности в понятную ошибку
+func userConstraintError(err error) error {
+    if err == nil {
+        return nil
+    }
+    msg := err.Error()
+    switch {
+    case strings.Contains(msg, "users.username"):
+        return manager.Conflict("имя пользователя уже занято")
+    case strings.Contains(msg, "users.email"):
+        return manager.Conflict("email уже зарегистрирован")
+    case strings.Contains(msg, "users.telegram_id"):
+        return manager.Conflict("Telegram аккаунт уже привязан к другому пользователю")
     }
+    return err
+}
 
-    return &user, nil
+func (s *SQLiteStorage) GetUserByTelegramID(telegramID int64) (*manager.User, error) {
+    query := "SELECT " + userColumns + " FROM users WHERE telegram_id = ?"
+    return scanUser(s.db.QueryRow(query, telegramID))
 }
 
 func (s *SQLiteStorage) GetAllTasksForUser(userID int) ([]manager.Task, error) {
-    query := `
-    SELECT id, description, created_at, updated_at, completed, priority, due_date, tags, user_id
-    FROM tasks WHERE user_id = ? ORDER BY created_at DESC`
+    query := "SELECT " + taskColumns + " FROM tasks WHERE " + visibleTask + " ORDER BY created_at DESC"
 
-    rows, err := s.db.Query(query, userID)
+    rows, err := s.db.Query(query, visibleArgs(userID)...)
     if err != nil {
         return nil, err
     }
@@ -616,59 +1046,274 @@ func (s *SQLiteStorage) GetAllTasksForUser(userID int) ([]manager.Task, error) {
 }
 
 func (s *SQLiteStorage) GetUserByDeviceID(deviceID string) (*manager.User, error) {
-    query := `SELECT id, device_id, telegram_id, fcm_token, created_at, updated_at 
-              FROM users WHERE device_id = ?`
-
-    var user manager.User
-    err := s.db.QueryRow(query, deviceID).Scan(
-        &user.ID,
-        &user.DeviceID,
-        &user.TelegramID,
-        &user.FCMToken,
-        &user.CreatedAt,
-        &user.UpdatedAt,
-    )
-    if err != nil {
-        return nil, err
-    }
+    query := "SELECT " + userColumns + " FROM users WHERE device_id = ?"
+    return scanUser(s.db.QueryRow(query, deviceID))
+}
 
-    return &user, nil
+// GetUserByLogin ищет учетную запись по имени пользователя или email без учета регистра
+func (s *SQLiteStorage) GetUserByLogin(login string) (*manager.User, error) {
+    query := "SELECT " + userColumns + ` FROM users
+        WHERE username = ? COLLATE NOCASE OR email = ? COLLATE NOCASE`
+    return scanUser(s.db.QueryRow(query, login, login))
 }
 
 func (s *SQLiteStorage) UpdateUser(user *manager.User) error {
     query := `
     UPDATE users 
-    SET device_id = ?, telegram_id = ?, fcm_token = ?, updated_at = ?
+    SET device_id = ?, telegram_id = ?, fcm_token = ?, username = ?, email = ?, password_hash = ?, updated_at = ?
     WHERE id = ?`
 
     _, err := s.db.Exec(query,
         user.DeviceID,
-        user.TelegramID,
+        nullInt64(user.TelegramID),
         user.FCMToken,
+        nullString(user.Username),
+        nullString(user.Email),
+        nullString(user.PasswordHash),
         time.Now(),
         user.ID,
     )
-    return err
+    return userConstraintError(err)
 }
 
 func (s *SQLiteStorage) GetUserByID(userID int) (*manager.User, error) {
-    query := `SELECT id, device_id, telegram_id, fcm_token, created_at, updated_at 
-              FROM users WHERE id = ?`
+    query := "SELECT " + userColumns + " FROM users WHERE id = ?"
+    return scanUser(s.db.QueryRow(query, userID))
+}
 
-    var user manager.User
-    err := s.db.QueryRow(query, userID).Scan(
-        &user.ID,
-        &user.DeviceID,
-        &user.TelegramID,
-        &user.FCMToken,
-        &user.CreatedAt,
-        &user.UpdatedAt,
-    )
+// 🆕 Сессии
+func (s *SQLiteStorage) CreateSession(session *manager.Session) error {
+    _, err := s.db.Exec(`
+    INSERT INTO sessions (token_hash, user_id, created_at, expires_at)
+    VALUES (?, ?, ?, ?)`,
+        session.TokenHash, session.UserID, session.CreatedAt, session.ExpiresAt)
+    return err
+}
+
+// GetUserBySession возвращает владельца действующей сессии
+func (s *SQLiteStorage) GetUserBySession(tokenHash string, now time.Time) (*manager.User, error) {
+    query := "SELECT " + prefixColumns("u.", userColumns) + ` FROM sessions s
+        JOIN users u ON u.id = s.user_id
+        WHERE s.token_hash = ? AND s.expires_at > ?`
+    user, err := scanUser(s.db.QueryRow(query, tokenHash, now))
+    if errors.Is(err, manager.ErrNotFound) {
+        return nil, manager.NotFound("сессия не найдена или истекла")
+    }
+    return user, err
+}
+
+func (s *SQLiteStorage) DeleteSession(tokenHash string) error {
+    _, err := s.db.Exec("DELETE FROM sessions WHERE token_hash = ?", tokenHash)
+    return err
+}
+
+// DeleteExpiredSessions удаляет истекшие сессии и возвращает их количество
+func (s *SQLiteStorage) DeleteExpiredSessions(now time.Time) (int, error) {
+    result, err := s.db.Exec("DELETE FROM sessions WHERE expires_at <= ?", now)
     if err != nil {
-        return nil, err
+        return 0, err
     }
+    n, err := result.RowsAffected()
+    return int(n), err
+}
 
-    return &user, nil
+// 🆕 Привязка Telegram
+// CreateLinkCode сохраняет код, заменяя прежние коды пользователя
+func (s *SQLiteStorage) CreateLinkCode(code *manager.LinkCode) error {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    if _, err := tx.Exec("DELETE FROM link_codes WHERE user_id = ?", code.UserID); err != nil {
+        return err
+    }
+    if _, err := tx.Exec(`
+    INSERT INTO link_codes (code_hash, user_id, created_at, expires_at)
+    VALUES (?, ?, ?, ?)`,
+        code.CodeHash, code.UserID, code.CreatedAt, code.ExpiresAt); err != nil {
+        return err
+    }
+    return tx.Commit()
+}
+
+// ConsumeLinkCode удаляет код и возвращает его владельца. Код одноразовый:
+// даже истекший код после попытки использования пропадает.
+func (s *SQLiteStorage) ConsumeLinkCode(codeHash string, now time.Time) (int, error) {
+    var userID int
+    var expiresAt time.Time
+    err := s.db.QueryRow("DELETE FROM link_codes WHERE code_hash = ? RETURNING user_id, expires_at", codeHash).
+        Scan(&userID, &expiresAt)
+    if err == sql.ErrNoRows || (err == nil && !expiresAt.After(now)) {
+        return 0, manager.NotFound("код привязки не найден или истек")
+    }
+    if err != nil {
+        return 0, err
+    }
+    return userID, nil
+}
+
+// LinkTelegram привязывает Telegram ID к пользователю. Если у этого Telegram
+// уже был отдельный пользователь без пароля (созданный ботом), его задачи
+// и подзадачи переходят к userID, а сам он удаляется. Возвращает число
+// перенесенных задач.
+func (s *SQLiteStorage) LinkTelegram(userID int, telegramID int64) (int, error) {
+    tx, err := s.db.Begin()
+    if err != nil {
+        return 0, err
+    }
+    defer tx.Rollback()
+
+    moved := 0
+    var otherID int
+    var otherPassword sql.NullString
+    err = tx.QueryRow("SELECT id, password_hash FROM users WHERE telegram_id = ? AND id != ?", telegramID, userID).
+        Scan(&otherID, &otherPassword)
+    switch {
+    case err == sql.ErrNoRows:
+        // Telegram еще ни к кому не привязан
+    case err != nil:
+        return 0, err
+    case otherPassword.Valid && otherPassword.String != "":
+        return 0, manager.Conflict("этот Telegram уже привязан к другой учетной записи")
+    default:
+        result, err := tx.Exec("UPDATE tasks SET user_id = ?, version = version + 1 WHERE user_id = ?", userID, otherID)
+        if err != nil {
+            return 0, err
+        }
+        n, _ := result.RowsAffected()
+        moved = int(n)
+
+        for _, query := range []string{
+            "UPDATE subtasks SET user_id = ? WHERE user_id = ?",
+            "UPDATE reminders SET user_id = ? WHERE user_id = ?",
+            "UPDATE tasks SET assignee_id = ?, version = version + 1 WHERE assignee_id = ?",
+            "UPDATE comments SET user_id = ? WHERE user_id = ?",
+            "UPDATE attachments SET user_id = ? WHERE user_id = ?",
+            "UPDATE task_events SET user_id = ? WHERE user_id = ?",
+        } {
+            if _, err := tx.Exec(query, userID, otherID); err != nil {
+                return 0, err
+            }
+        }
+        if err := moveTags(tx, otherID, userID); err != nil {
+            return 0, err
+        }
+        if err := moveProjects(tx, otherID, userID); err != nil {
+            return 0, err
+        }
+        if err := moveMemberships(tx, otherID, userID); err != nil {
+            return 0, err
+        }
+        for _, query := range []string{
+            "DELETE FROM sessions WHERE user_id = ?",
+            "DELETE FROM link_codes WHERE user_id = ?",
+            "DELETE FROM reminder_settings WHERE user_id = ?",
+            "DELETE FROM users WHERE id = ?",
+        } {
+            if _, err := tx.Exec(query, otherID); err != nil {
+                return 0, err
+            }
+        }
+    }
+
+    result, err := tx.Exec("UPDATE users SET telegram_id = ?, updated_at = ? WHERE id = ?", telegramID, time.Now(), userID)
+    if err != nil {
+        return 0, userConstraintError(err)
+    }
+    if n, _ := result.RowsAffected(); n == 0 {
+        return 0, manager.NotFound("пользователь %d не найден", userID)
+    }
+    return moved, tx.Commit()
+}
+
+// prefixColumns добавляет псевдоним таблицы к списку колонок для JOIN
+func prefixColumns(prefix, columns string) string {
+    parts := strings.Split(columns, ", ")
+    for i, c := range parts {
+        parts[i] = prefix + c
+    }
+    return strings.Join(parts, ", ")
+}
+
+// ClaimLegacyTasks передает учетной записи userID задачи, созданные до
+// появления учетных записей: задачи пользователя LegacyDeviceID и задачи
+// без автора вместе с подзадачами, тегами, напоминаниями и проектами.
+// Это явный шаг оператора (migrate claim-legacy), регистрация задачи не
+// забирает. Возвращает число переданных задач.
+func (s *SQLiteStorage) ClaimLegacyTasks(userID int) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var legacyID int
+	err = tx.QueryRow("SELECT id FROM users WHERE device_id = ?", manager.LegacyDeviceID).Scan(&legacyID)
+	if err == sql.ErrNoRows {
+		return 0, manager.NotFound("пользователя %s нет: задач без владельца не осталось", manager.LegacyDeviceID)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if legacyID == userID {
+		return 0, manager.Invalid("задачи нельзя передать самому пользователю %s", manager.LegacyDeviceID)
+	}
+	inboxID, err := ensureInbox(tx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Теги принадлежат пользователю: переносим связи на теги с тем же ключом
+	_, err = tx.Exec(`
+	INSERT INTO tags (user_id, name, name_key)
+	SELECT ?, name, name_key FROM tags WHERE user_id = ?
+	ON CONFLICT DO NOTHING`, userID, legacyID)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec(`
+	UPDATE task_tags SET tag_id = (
+		SELECT t.id FROM tags t JOIN tags old ON old.id = task_tags.tag_id
+		WHERE t.user_id = ? AND t.name_key = old.name_key)
+	WHERE tag_id IN (SELECT id FROM tags WHERE user_id = ?)`, userID, legacyID)
+	if err != nil {
+		return 0, err
+	}
+
+	// Проекты, кроме Входящих, переходят целиком; задачи из Входящих -
+	// во Входящие нового владельца
+	if _, err := tx.Exec("UPDATE projects SET user_id = ? WHERE user_id = ? AND NOT inbox", userID, legacyID); err != nil {
+		return 0, projectConstraintError(err)
+	}
+	result, err := tx.Exec(`
+	UPDATE tasks SET user_id = ?,
+		project_id = CASE WHEN project_id IN (SELECT id FROM projects WHERE user_id = ?) THEN project_id ELSE ? END,
+		assignee_id = CASE WHEN assignee_id = ? THEN ? ELSE assignee_id END,
+		version = version + 1
+	WHERE user_id = ? OR user_id IS NULL`, userID, userID, inboxID, legacyID, userID, legacyID)
+	if err != nil {
+		return 0, err
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	for _, step := range []struct {
+		query string
+		args  []interface{}
+	}{
+		{"UPDATE subtasks SET user_id = ? WHERE user_id = ? OR user_id IS NULL", []interface{}{userID, legacyID}},
+		{"UPDATE reminders SET user_id = ? WHERE user_id = ?", []interface{}{userID, legacyID}},
+		{"DELETE FROM tags WHERE user_id = ?", []interface{}{legacyID}},
+		{"DELETE FROM sync_entries WHERE user_id = ?", []interface{}{legacyID}},
+	} {
+		if _, err := tx.Exec(step.query, step.args...); err != nil {
+			return 0, err
+		}
+	}
+	return int(claimed), tx.Commit()
 }
 
 func (s *SQLiteStorage) MigrateExistingTasksToUser(userID int, deviceID string) error {
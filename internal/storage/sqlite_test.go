@@ -0,0 +1,1373 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"todo-app/internal/manager"
+)
+
+func newTestStorage(t *testing.T) *SQLiteStorage {
+	t.Helper()
+	s, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "todo.db"))
+	if err != nil {
+		t.Fatalf("Ошибка создания хранилища: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSQLiteDSN(t *testing.T) {
+	const defaults = "_pragma=busy_timeout(5000)&_time_format=sqlite&_txlock=immediate"
+	cases := map[string]string{
+		"todo.db":                            "todo.db?" + defaults,
+		"todo.db?":                           "todo.db?" + defaults,
+		"todo.db?_pragma=foreign_keys(1)":    "todo.db?_pragma=foreign_keys(1)&" + defaults,
+		"todo.db?_txlock=deferred":           "todo.db?_txlock=deferred&_pragma=busy_timeout(5000)&_time_format=sqlite",
+		"todo.db?_pragma=busy_timeout(100)&": "todo.db?_pragma=busy_timeout(100)&_time_format=sqlite&_txlock=immediate",
+		"todo.db?" + defaults:                "todo.db?" + defaults,
+	}
+	for path, want := range cases {
+		if got := sqliteDSN(path); got != want {
+			t.Errorf("%q: ожидалось %q, получено %q", path, want, got)
+		}
+	}
+
+	// Свой параметр в пути не отключает таймаут блокировки
+	db, err := OpenDB(filepath.Join(t.TempDir(), "todo.db") + "?_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatalf("Открытие базы: %v", err)
+	}
+	defer db.Close()
+	var timeout int
+	if err := db.QueryRow("PRAGMA busy_timeout").Scan(&timeout); err != nil || timeout != 5000 {
+		t.Errorf("busy_timeout: %d, %v", timeout, err)
+	}
+}
+
+func TestSubTasksPersistence(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "todo.db")
+	s, err := NewSQLiteStorage(dbPath)
+	if err != nil {
+		t.Fatalf("Ошибка создания хранилища: %v", err)
+	}
+
+	taskID, _ := s.AddTaskForUser(1, "Задача", nil)
+	subID, err := s.AddSubTask(1, taskID, "Подзадача")
+	if err != nil {
+		t.Fatalf("Ошибка добавления подзадачи: %v", err)
+	}
+	s.Close()
+
+	// Подзадача должна пережить перезапуск
+	s, err = NewSQLiteStorage(dbPath)
+	if err != nil {
+		t.Fatalf("Ошибка повторного открытия: %v", err)
+	}
+	defer s.Close()
+
+	subtasks, err := s.GetSubTasks(1, taskID)
+	if err != nil {
+		t.Fatalf("Ошибка загрузки подзадач: %v", err)
+	}
+	if len(subtasks) != 1 || subtasks[0].ID != subID || subtasks[0].UserID != 1 {
+		t.Fatalf("Ожидалась одна подзадача #%d пользователя 1, получено %+v", subID, subtasks)
+	}
+}
+
+func TestSubTasksOwnership(t *testing.T) {
+	s := newTestStorage(t)
+	sm := manager.NewSubTaskManagerWithStorage(s)
+
+	taskID, _ := s.AddTaskForUser(1, "Задача первого", nil)
+	subID, err := sm.AddSubTask(1, taskID, "Подзадача")
+	if err != nil {
+		t.Fatalf("Ошибка добавления подзадачи: %v", err)
+	}
+
+	t.Run("Чужая задача", func(t *testing.T) {
+		if _, err := sm.AddSubTask(2, taskID, "Взлом"); err == nil {
+			t.Error("Ожидалась ошибка при добавлении подзадачи к чужой задаче")
+		}
+		subtasks, _ := sm.GetSubTasks(2, taskID)
+		if len(subtasks) != 0 {
+			t.Errorf("Чужие подзадачи не должны возвращаться, получено %d", len(subtasks))
+		}
+	})
+
+	t.Run("Переключение", func(t *testing.T) {
+		if err := sm.ToggleSubTask(2, subID); err == nil {
+			t.Error("Ожидалась ошибка при переключении чужой подзадачи")
+		}
+		if err := sm.ToggleSubTask(1, subID); err != nil {
+			t.Fatalf("Ошибка переключения: %v", err)
+		}
+		subtasks, _ := sm.GetSubTasks(1, taskID)
+		if !subtasks[0].Completed {
+			t.Error("Подзадача должна быть выполнена")
+		}
+	})
+
+	t.Run("Удаление", func(t *testing.T) {
+		if err := sm.DeleteSubTask(2, subID); err == nil {
+			t.Error("Ожидалась ошибка при удалении чужой подзадачи")
+		}
+		if err := sm.DeleteSubTask(1, subID); err != nil {
+			t.Fatalf("Ошибка удаления: %v", err)
+		}
+		if err := sm.DeleteSubTask(1, subID); err == nil {
+			t.Error("Ожидалась ошибка при повторном удалении")
+		}
+	})
+}
+
+func TestTaskOwnership(t *testing.T) {
+	s := newTestStorage(t)
+	taskID, _ := s.AddTaskForUser(1, "Задача первого", nil)
+
+	if _, err := s.GetTask(2, taskID); !errors.Is(err, manager.ErrForbidden) {
+		t.Errorf("Ожидалась ErrForbidden для чужой задачи, получено %v", err)
+	}
+	if _, err := s.GetTask(1, 9999); !errors.Is(err, manager.ErrNotFound) {
+		t.Errorf("Ожидалась ErrNotFound для несуществующей задачи, получено %v", err)
+	}
+	if _, err := s.ToggleComplete(2, taskID); !errors.Is(err, manager.ErrForbidden) {
+		t.Errorf("Чужую задачу нельзя переключить, получено %v", err)
+	}
+	if err := s.DeleteTask(2, taskID); !errors.Is(err, manager.ErrForbidden) {
+		t.Errorf("Чужую задачу нельзя удалить, получено %v", err)
+	}
+	if tasks, _ := s.FilterTasks(2, nil); len(tasks) != 0 {
+		t.Errorf("Второй пользователь не должен видеть задачи первого, получено %d", len(tasks))
+	}
+	if err := s.DeleteTask(1, taskID); err != nil {
+		t.Fatalf("Ошибка удаления своей задачи: %v", err)
+	}
+}
+
+func TestFilterByTagExactMatch(t *testing.T) {
+	s := newTestStorage(t)
+	s.AddTaskForUser(1, "Домашка", []string{"homework"})
+	s.AddTaskForUser(1, "Работа", []string{"work", "Срочно"})
+
+	tasks, err := s.FilterByTag(1, "work")
+	if err != nil {
+		t.Fatalf("Ошибка фильтрации: %v", err)
+	}
+	if len(tasks) != 1 || tasks[0].Description != "Работа" {
+		t.Errorf("Тег work не должен совпадать с homework, получено %+v", tasks)
+	}
+
+	tasks, _ = s.FilterByTag(1, "срочно")
+	if len(tasks) != 1 {
+		t.Errorf("Поиск по тегу должен быть регистронезависимым, получено %d", len(tasks))
+	}
+}
+
+func TestTagOperations(t *testing.T) {
+	s := newTestStorage(t)
+	tm := manager.NewTaskManagerWithStorage(s)
+	reportID, _ := tm.AddTaskForUser(1, "Отчет", []string{"Работа", "срочно"})
+	callID, _ := tm.AddTaskForUser(1, "Звонок", []string{"звонки", "офис", "РАБОТА"})
+	tm.AddTaskForUser(1, "Покупки", []string{"дом"})
+	tm.AddTaskForUser(2, "Чужая", []string{"работа"})
+
+	// У пользователя одно написание тега на все задачи
+	if call, _ := s.GetTask(1, callID); strings.Join(call.Tags, ",") != "звонки,офис,Работа" {
+		t.Errorf("Ожидались теги в общем написании и исходном порядке, получено %v", call.Tags)
+	}
+	tags, err := s.GetTags(1)
+	if err != nil {
+		t.Fatalf("Ошибка получения тегов: %v", err)
+	}
+	if len(tags) != 5 || tags[0] != (manager.TagCount{Name: "Работа", Count: 2}) || tags[1].Name != "дом" {
+		t.Errorf("Неожиданные теги: %+v", tags)
+	}
+
+	if n, err := tm.RenameTagForUser(1, "работа", "работа"); err != nil || n != 2 {
+		t.Fatalf("Переименование: %d, %v", n, err)
+	}
+	if other, _ := s.GetTags(2); other[0].Name != "работа" {
+		t.Errorf("Переименование не должно задевать других пользователей: %+v", other)
+	}
+	if _, err := tm.RenameTagForUser(1, "работа", "Дом"); !errors.Is(err, manager.ErrConflict) {
+		t.Errorf("Переименование в занятое имя: ожидалась ErrConflict, получено %v", err)
+	}
+
+	if n, err := tm.MergeTagsForUser(1, []string{"офис", "Звонки"}, "работа"); err != nil || n != 1 {
+		t.Fatalf("Объединение: %d, %v", n, err)
+	}
+	if call, _ := s.GetTask(1, callID); strings.Join(call.Tags, ",") != "работа" {
+		t.Errorf("Ожидался один тег работа, получено %v", call.Tags)
+	}
+	if tasks, _ := s.FilterByTag(1, "офис"); len(tasks) != 0 {
+		t.Errorf("Объединенный тег не должен находиться, получено %d задач", len(tasks))
+	}
+	if _, err := tm.MergeTagsForUser(1, []string{"отпуск"}, "работа"); !errors.Is(err, manager.ErrNotFound) {
+		t.Errorf("Ожидалась ErrNotFound, получено %v", err)
+	}
+
+	if n, err := tm.DeleteTagForUser(1, "СРОЧНО"); err != nil || n != 1 {
+		t.Fatalf("Удаление: %d, %v", n, err)
+	}
+	if report, _ := s.GetTask(1, reportID); strings.Join(report.Tags, ",") != "работа" {
+		t.Errorf("Тег не снят с задачи: %v", report.Tags)
+	}
+
+	// Тег без задач пропадает из списка
+	empty := []string{}
+	tm.UpdateTaskForUser(1, 3, manager.UpdateTaskRequest{Tags: &empty})
+	tags, _ = s.GetTags(1)
+	if len(tags) != 1 || tags[0] != (manager.TagCount{Name: "работа", Count: 2}) {
+		t.Errorf("Неожиданные теги: %+v", tags)
+	}
+	if _, err := tm.DeleteTagForUser(1, "дом"); !errors.Is(err, manager.ErrNotFound) {
+		t.Errorf("Ожидалась ErrNotFound для тега без задач, получено %v", err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	s := newTestStorage(t)
+	tm := manager.NewTaskManagerWithStorage(s)
+	reportID, _ := tm.AddTaskForUser(1, "Годовой отчет для бухгалтерии", []string{"работа"})
+	callID, _ := tm.AddTaskForUser(1, "Позвонить в банк", nil)
+	s.AddSubTask(1, callID, "Уточнить отчет по карте")
+	tm.AddTaskForUser(1, "Отчетность за квартал", nil)
+	tm.AddTaskForUser(2, "Чужой отчет", nil)
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"отчет", []int{reportID, callID}},
+		{"ОТЧЕТ бухгалтерии", []int{reportID}},
+		// bm25 поднимает короткое описание выше, подзадачи ниже описаний
+		{"отчет*", []int{3, reportID, callID}},
+		{`"годовой отчет"`, []int{reportID}},
+		{`"отчет годовой"`, nil},
+		{"банк, карте", []int{callID}},
+	}
+	for _, tt := range tests {
+		results, err := tm.SearchForUser(1, tt.query, manager.FilterOptions{})
+		if err != nil {
+			t.Fatalf("%s: ошибка поиска: %v", tt.query, err)
+		}
+		var got []int
+		for _, r := range results {
+			got = append(got, r.Task.ID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: получено %v, ожидалось %v", tt.query, got, tt.want)
+		}
+	}
+
+	results, _ := tm.SearchForUser(1, "отчет", manager.FilterOptions{Tags: []string{"работа"}})
+	if len(results) != 1 || results[0].Task.Tags[0] != "работа" {
+		t.Errorf("Фильтр по тегу не применен: %+v", results)
+	}
+	if got := results[0].Highlight("[", "]", strings.ToUpper); got != "ГОДОВОЙ [ОТЧЕТ] ДЛЯ БУХГАЛТЕРИИ" {
+		t.Errorf("Неожиданный фрагмент: %q", got)
+	}
+	results, _ = tm.SearchForUser(1, "карте", manager.FilterOptions{})
+	if len(results) != 1 || results[0].Snippet != "Уточнить отчет по "+manager.HighlightStart+"карте"+manager.HighlightEnd {
+		t.Errorf("Фрагмент должен браться из подзадачи: %+v", results)
+	}
+
+	// Индекс следует за изменениями задач и подзадач
+	desc := "Квартальный план"
+	tm.UpdateTaskForUser(1, reportID, manager.UpdateTaskRequest{Description: &desc})
+	subtasks, _ := s.GetSubTasks(1, callID)
+	s.DeleteSubTask(1, subtasks[0].ID)
+	tm.DeleteTaskForUser(1, 3)
+	if results, _ := tm.SearchForUser(1, "отчет*", manager.FilterOptions{}); len(results) != 0 {
+		t.Errorf("Ожидался пустой результат после изменений, получено %+v", results)
+	}
+	if results, _ := tm.SearchForUser(1, "план", manager.FilterOptions{}); len(results) != 1 {
+		t.Errorf("Новое описание должно находиться, получено %+v", results)
+	}
+
+	if _, err := tm.SearchForUser(1, " * -- ", manager.FilterOptions{}); !errors.Is(err, manager.ErrInvalid) {
+		t.Errorf("Запрос без слов: ожидалась ErrInvalid, получено %v", err)
+	}
+}
+
+func TestFilterByDateRangeInclusive(t *testing.T) {
+	s := newTestStorage(t)
+	day := time.Date(2026, 3, 10, 18, 30, 0, 0, time.Local)
+	id, _ := s.AddTaskForUser(1, "Срок вечером", nil)
+	if _, err := s.UpdateTask(1, id, manager.UpdateTaskRequest{DueDate: &day}); err != nil {
+		t.Fatalf("Ошибка установки срока: %v", err)
+	}
+
+	tasks, err := s.FilterByDateRange(1, day, day)
+	if err != nil {
+		t.Fatalf("Ошибка фильтрации: %v", err)
+	}
+	if len(tasks) != 1 {
+		t.Errorf("Задача со сроком в последний день диапазона должна попасть в выборку, получено %d", len(tasks))
+	}
+}
+
+func TestAccountsAndSessions(t *testing.T) {
+	s := newTestStorage(t)
+	um := manager.NewUserManager(s)
+
+	// Пользователи без Telegram не должны конфликтовать по telegram_id
+	alice, err := um.Register("alice", "alice@example.com", "password123")
+	if err != nil {
+		t.Fatalf("Ошибка регистрации: %v", err)
+	}
+	if _, err := um.Register("bob", "", "password123"); err != nil {
+		t.Fatalf("Ошибка регистрации второго пользователя: %v", err)
+	}
+	if _, err := um.Register("Alice", "", "password123"); !errors.Is(err, manager.ErrConflict) {
+		t.Errorf("Ожидался ErrConflict для занятого имени, получено %v", err)
+	}
+
+	if _, err := um.Authenticate("ALICE@example.com", "password123"); err != nil {
+		t.Errorf("Вход по email без учета регистра: %v", err)
+	}
+
+	token, _, err := um.CreateSession(alice.ID)
+	if err != nil {
+		t.Fatalf("Ошибка создания сессии: %v", err)
+	}
+	user, err := um.GetUserBySession(token)
+	if err != nil || user.ID != alice.ID || user.Username != "alice" {
+		t.Fatalf("Ожидалась alice, получено %+v, %v", user, err)
+	}
+
+	if err := um.DeleteSession(token); err != nil {
+		t.Fatalf("Ошибка удаления сессии: %v", err)
+	}
+	if _, err := um.GetUserBySession(token); !errors.Is(err, manager.ErrNotFound) {
+		t.Errorf("Ожидался ErrNotFound после выхода, получено %v", err)
+	}
+
+	past := time.Now().UTC().Add(-time.Hour)
+	s.CreateSession(&manager.Session{TokenHash: "old", UserID: alice.ID, CreatedAt: past, ExpiresAt: past})
+	if removed, _ := um.CleanupSessions(); removed != 1 {
+		t.Errorf("Ожидалось удаление 1 истекшей сессии, удалено %d", removed)
+	}
+}
+
+func TestTelegramUsersHaveSeparateTasks(t *testing.T) {
+	s := newTestStorage(t)
+	um := manager.NewUserManager(s)
+	tm := manager.NewTaskManagerWithStorage(s)
+
+	first, err := um.GetOrCreateUserByTelegramID(1001)
+	if err != nil {
+		t.Fatalf("Ошибка создания пользователя: %v", err)
+	}
+	again, _ := um.GetOrCreateUserByTelegramID(1001)
+	if again.ID != first.ID {
+		t.Errorf("Повторное обращение должно вернуть того же пользователя: %d != %d", again.ID, first.ID)
+	}
+	second, _ := um.GetOrCreateUserByTelegramID(2002)
+
+	taskID, _ := tm.AddTaskForUser(first.ID, "Задача первого", nil)
+	if tasks, _ := tm.GetAllTasksForUser(second.ID); len(tasks) != 0 {
+		t.Errorf("Второй аккаунт не должен видеть чужие задачи, получено %d", len(tasks))
+	}
+	if _, _, err := tm.ToggleCompleteForUser(second.ID, taskID); !errors.Is(err, manager.ErrForbidden) {
+		t.Errorf("Ожидался ErrForbidden при /done чужой задачи, получено %v", err)
+	}
+	if err := tm.DeleteTaskForUser(second.ID, taskID); !errors.Is(err, manager.ErrForbidden) {
+		t.Errorf("Ожидался ErrForbidden при /delete чужой задачи, получено %v", err)
+	}
+}
+
+func TestLinkTelegramMergesTasks(t *testing.T) {
+	s := newTestStorage(t)
+	um := manager.NewUserManager(s)
+	tm := manager.NewTaskManagerWithStorage(s)
+	sm := manager.NewSubTaskManagerWithStorage(s)
+
+	account, _ := um.Register("frank", "", "password123")
+	tm.AddTaskForUser(account.ID, "Задача с сайта", []string{"Работа"})
+
+	botUser, _ := um.GetOrCreateUserByTelegramID(4242)
+	botTaskID, _ := tm.AddTaskForUser(botUser.ID, "Задача из бота", []string{"работа", "бот"})
+	sm.AddSubTask(botUser.ID, botTaskID, "Подзадача из бота")
+
+	code, _, err := um.CreateLinkCode(account.ID)
+	if err != nil {
+		t.Fatalf("Ошибка создания кода: %v", err)
+	}
+	linked, moved, err := um.LinkTelegram(code, 4242)
+	if err != nil {
+		t.Fatalf("Ошибка привязки: %v", err)
+	}
+	if linked.ID != account.ID || moved != 1 {
+		t.Errorf("Ожидалась привязка к %d с переносом 1 задачи, получено %d и %d", account.ID, linked.ID, moved)
+	}
+
+	tasks, _ := tm.GetAllTasksForUser(account.ID)
+	if len(tasks) != 2 {
+		t.Errorf("После объединения ожидалось 2 задачи, получено %d", len(tasks))
+	}
+	if subtasks, _ := sm.GetSubTasks(account.ID, botTaskID); len(subtasks) != 1 {
+		t.Errorf("Подзадачи должны перейти вместе с задачей, получено %d", len(subtasks))
+	}
+	if _, err := s.GetUserByID(botUser.ID); !errors.Is(err, manager.ErrNotFound) {
+		t.Errorf("Пользователь бота должен быть удален, получено %v", err)
+	}
+	if tags, _ := s.GetTags(account.ID); len(tags) != 2 || tags[0] != (manager.TagCount{Name: "Работа", Count: 2}) {
+		t.Errorf("Теги бота должны объединиться с тегами учетной записи, получено %+v", tags)
+	}
+
+	// Теперь бот находит учетную запись по Telegram ID
+	if user, _ := um.GetOrCreateUserByTelegramID(4242); user.ID != account.ID {
+		t.Errorf("Бот должен работать с учетной записью %d, получено %d", account.ID, user.ID)
+	}
+
+	if _, _, err := um.LinkTelegram(code, 4242); !errors.Is(err, manager.ErrNotFound) {
+		t.Errorf("Код должен быть одноразовым, получено %v", err)
+	}
+}
+
+func TestRemindersPersistence(t *testing.T) {
+	s := newTestStorage(t)
+	tm := manager.NewTaskManagerWithStorage(s)
+	rm := manager.NewReminderManagerWithStorage(s, tm)
+
+	taskID, _ := tm.AddTaskForUser(1, "Задача со сроком", nil)
+	due := time.Now().AddDate(0, 0, 7)
+	tm.UpdateTaskForUser(1, taskID, manager.UpdateTaskRequest{DueDate: &due})
+
+	trigger := time.Now().Add(48 * time.Hour).Truncate(time.Second)
+	custom, err := rm.CreateReminder(1, taskID, manager.CreateReminderRequest{TriggerTime: &trigger, Message: "Свое"})
+	if err != nil {
+		t.Fatalf("Ошибка создания напоминания: %v", err)
+	}
+
+	reminders, err := rm.GetReminders(1, taskID)
+	if err != nil {
+		t.Fatalf("Ошибка загрузки: %v", err)
+	}
+	if len(reminders) != 2 {
+		t.Fatalf("Ожидалось автонапоминание и свое, получено %+v", reminders)
+	}
+	if got := reminders[0]; got.ID != custom.ID || !got.TriggerTime.Equal(trigger) || got.DaysBefore != nil {
+		t.Errorf("Свое напоминание прочитано неверно: %+v", got)
+	}
+	if got := reminders[1]; !got.Auto || got.DaysBefore == nil || *got.DaysBefore != 1 {
+		t.Errorf("Автонапоминание прочитано неверно: %+v", got)
+	}
+
+	if _, err := rm.SaveSettings(manager.ReminderSettings{UserID: 1, Enabled: false}); err != nil {
+		t.Fatalf("Ошибка сохранения настроек: %v", err)
+	}
+	if settings, _ := rm.GetSettings(1); settings.Enabled {
+		t.Error("Настройки должны сохраниться")
+	}
+	reminders, _ = rm.GetReminders(1, taskID)
+	if reminders[1].Status != manager.ReminderCancelled || reminders[0].Status != manager.ReminderPending {
+		t.Errorf("Отключение должно отменить только автонапоминание, получено %+v", reminders)
+	}
+
+	if err := tm.DeleteTaskForUser(1, taskID); err != nil {
+		t.Fatalf("Ошибка удаления задачи: %v", err)
+	}
+	if _, err := tm.PurgeTrash(time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("Ошибка очистки корзины: %v", err)
+	}
+	if _, err := rm.GetReminder(1, custom.ID); !errors.Is(err, manager.ErrNotFound) {
+		t.Errorf("Напоминания удаляются вместе с задачей, получено %v", err)
+	}
+}
+
+func TestMigratorAdoptsLegacyReminders(t *testing.T) {
+	db := openTestDB(t)
+	migrator, _ := NewMigrator(db)
+	if err := migrator.To(5); err != nil {
+		t.Fatalf("Ошибка миграции до версии 5: %v", err)
+	}
+
+	// Таблица, которую создавала первая версия бота
+	_, err := db.Exec(`
+	CREATE TABLE reminders (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		type TEXT NOT NULL DEFAULT 'deadline',
+		trigger_time DATETIME NOT NULL,
+		message TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		channel TEXT NOT NULL DEFAULT 'telegram',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	INSERT INTO reminders (task_id, user_id, trigger_time, message, status, created_at)
+	VALUES (21, 1, '2025-08-23 00:00:00 +0000 UTC', 'напоминание!', 'sent', '2025-08-24 09:27:44.7435927 +0300 MSK m=+108.408158601');`)
+	if err != nil {
+		t.Fatalf("Ошибка создания старой таблицы: %v", err)
+	}
+
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Ошибка миграции: %v", err)
+	}
+
+	s := &SQLiteStorage{db: db}
+	reminders, err := s.GetTaskReminders(1, 21)
+	if err != nil {
+		t.Fatalf("Ошибка чтения старого напоминания: %v", err)
+	}
+	want := time.Date(2025, 8, 23, 0, 0, 0, 0, time.UTC)
+	if len(reminders) != 1 || !reminders[0].TriggerTime.Equal(want) || reminders[0].Status != manager.ReminderSent {
+		t.Errorf("Старое напоминание прочитано неверно: %+v", reminders)
+	}
+}
+
+func TestReminderDeliveryClaims(t *testing.T) {
+	s := newTestStorage(t)
+	tm := manager.NewTaskManagerWithStorage(s)
+	rm := manager.NewReminderManagerWithStorage(s, tm)
+
+	taskID, _ := tm.AddTaskForUser(1, "Задача", nil)
+	var ids []int
+	for i := 1; i <= 3; i++ {
+		trigger := time.Now().Add(time.Duration(i) * time.Hour)
+		r, err := rm.CreateReminder(1, taskID, manager.CreateReminderRequest{TriggerTime: &trigger, Message: "Напоминание"})
+		if err != nil {
+			t.Fatalf("Ошибка создания напоминания: %v", err)
+		}
+		ids = append(ids, r.ID)
+	}
+
+	now := time.Now().Add(150 * time.Minute)
+	next, ok, err := rm.NextDue(time.Now())
+	if err != nil || !ok {
+		t.Fatalf("Ожидалось ближайшее напоминание: %v", err)
+	}
+	if want := time.Now().Add(time.Hour); next.Sub(want).Abs() > time.Minute {
+		t.Errorf("Ближайшее напоминание %v, ожидалось около %v", next, want)
+	}
+
+	// Два процесса забирают одновременно - каждое напоминание достается одному
+	results := make(chan []manager.Reminder, 2)
+	for _, owner := range []string{"web", "bot"} {
+		go func(owner string) {
+			claimed, err := rm.ClaimDue(owner, now, 10)
+			if err != nil {
+				t.Errorf("Ошибка захвата: %v", err)
+			}
+			results <- claimed
+		}(owner)
+	}
+	claimed := append(<-results, <-results...)
+	if len(claimed) != 2 || claimed[0].ID == claimed[1].ID {
+		t.Fatalf("Ожидались два разных напоминания, получено %+v", claimed)
+	}
+	for _, r := range claimed {
+		if r.Attempts != 1 || r.ID == ids[2] {
+			t.Errorf("Забрано неверное напоминание: %+v", r)
+		}
+	}
+
+	owner := func(r manager.Reminder) string {
+		var claimedBy string
+		s.db.QueryRow("SELECT claimed_by FROM reminders WHERE id = ?", r.ID).Scan(&claimedBy)
+		return claimedBy
+	}
+	sent, failed := claimed[0], claimed[1]
+	if err := rm.MarkSent(owner(sent), &sent); err != nil {
+		t.Fatalf("Ошибка отметки отправки: %v", err)
+	}
+	retryAt := now.Add(time.Minute)
+	if err := rm.MarkFailed(owner(failed), &failed, errors.New("таймаут"), &retryAt); err != nil {
+		t.Fatalf("Ошибка отметки сбоя: %v", err)
+	}
+	if err := rm.MarkSent(owner(failed), &failed); !errors.Is(err, manager.ErrConflict) {
+		t.Errorf("Повторное завершение должно вернуть ErrConflict, получено %v", err)
+	}
+
+	got, _ := rm.GetReminder(1, failed.ID)
+	if got.Status != manager.ReminderFailed || got.LastError != "таймаут" || !got.NextAttemptAt.Equal(retryAt) {
+		t.Errorf("Сбой сохранен неверно: %+v", got)
+	}
+	if got, _ := rm.GetReminder(1, sent.ID); got.Status != manager.ReminderSent || got.SentAt == nil {
+		t.Errorf("Отправка сохранена неверно: %+v", got)
+	}
+
+	if again, _ := rm.ClaimDue("web", now, 10); len(again) != 0 {
+		t.Errorf("До времени повтора забирать нечего, получено %+v", again)
+	}
+	again, _ := rm.ClaimDue("web", retryAt, 10)
+	if len(again) != 1 || again[0].ID != failed.ID || again[0].Status != manager.ReminderPending || again[0].Attempts != 2 {
+		t.Errorf("Ожидался повтор неудачного напоминания, получено %+v", again)
+	}
+}
+
+func TestRecurringTaskPersistence(t *testing.T) {
+	s := newTestStorage(t)
+	tm := manager.NewTaskManagerWithStorage(s)
+
+	id, _ := tm.AddTaskForUser(1, "Отчет за месяц", []string{"работа"})
+	s.AddSubTask(1, id, "Собрать цифры")
+	due := time.Date(2030, 1, 31, 0, 0, 0, 0, time.Local)
+	rule := "FREQ=MONTHLY;BYMONTHDAY=-1"
+	if _, err := tm.UpdateTaskForUser(1, id, manager.UpdateTaskRequest{DueDate: &due, Recurrence: &rule}); err != nil {
+		t.Fatalf("Ошибка установки повторения: %v", err)
+	}
+
+	if _, _, err := tm.ToggleCompleteForUser(1, id); err != nil {
+		t.Fatalf("Ошибка выполнения: %v", err)
+	}
+	series, err := s.GetSeriesTasks(1, id)
+	if err != nil || len(series) != 2 {
+		t.Fatalf("Ожидалось 2 экземпляра серии, получено %d (%v)", len(series), err)
+	}
+	next := series[1]
+	want := time.Date(2030, 2, 28, 0, 0, 0, 0, time.Local)
+	if next.Completed || !next.DueDate.Equal(want) || next.Recurrence != rule || next.SeriesID != id || next.Tags[0] != "работа" {
+		t.Errorf("Следующий экземпляр сохранен неверно: %+v", next)
+	}
+	if subtasks, _ := s.GetSubTasks(1, next.ID); len(subtasks) != 1 || subtasks[0].Description != "Собрать цифры" {
+		t.Errorf("Подзадачи не скопированы: %+v", subtasks)
+	}
+
+	desc := "Отчет"
+	if _, err := tm.UpdateSeriesForUser(1, next.ID, manager.UpdateTaskRequest{Description: &desc}); err != nil {
+		t.Fatalf("Ошибка правки серии: %v", err)
+	}
+	if got, _ := s.GetTask(1, next.ID); got.Description != desc {
+		t.Errorf("Правка серии не сохранена: %+v", got)
+	}
+	if got, _ := s.GetTask(1, id); got.Description != "Отчет за месяц" {
+		t.Errorf("Выполненный экземпляр не должен меняться: %+v", got)
+	}
+
+	// Серия сохраняется целиком или не сохраняется совсем
+	partial := "Отчет за квартал"
+	requests := map[int]manager.UpdateTaskRequest{
+		next.ID:       {Description: &partial},
+		next.ID + 100: {Description: &partial},
+	}
+	if _, err := s.UpdateTasks(1, requests); !errors.Is(err, manager.ErrNotFound) {
+		t.Errorf("Ожидался ErrNotFound для несуществующего экземпляра, получено %v", err)
+	}
+	if got, _ := s.GetTask(1, next.ID); got.Description != desc {
+		t.Errorf("Неудачная правка серии не должна менять экземпляры: %+v", got)
+	}
+}
+
+func TestSavedFiltersPersistence(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "todo.db")
+	s, err := NewSQLiteStorage(dbPath)
+	if err != nil {
+		t.Fatalf("Ошибка создания хранилища: %v", err)
+	}
+	um := manager.NewUserManager(s)
+	alice, _ := um.Register("alice", "", "password123")
+	bob, _ := um.Register("bob", "", "password123")
+	fm := manager.NewSavedFilterManagerWithStorage(s, manager.NewTaskManagerWithStorage(s))
+
+	completed := false
+	high := manager.PriorityHigh
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	options := manager.FilterOptions{
+		Completed: &completed,
+		Priority:  &high,
+		Tags:      []string{"работа"},
+		StartDate: &start,
+		Query:     `tag:"два слова" OR due<+7d`,
+	}
+	filter, err := fm.CreateFilter(alice.ID, manager.SavedFilterRequest{Name: "Срочное", Options: options, Sort: manager.SortByPriority})
+	if err != nil {
+		t.Fatalf("Ошибка создания фильтра: %v", err)
+	}
+	fm.CreateFilter(alice.ID, manager.SavedFilterRequest{Name: "архив"})
+	if _, err := fm.CreateFilter(alice.ID, manager.SavedFilterRequest{Name: "СРОЧНОЕ"}); !errors.Is(err, manager.ErrConflict) {
+		t.Errorf("Ожидался ErrConflict, получено %v", err)
+	}
+	s.Close()
+
+	s, err = NewSQLiteStorage(dbPath)
+	if err != nil {
+		t.Fatalf("Ошибка повторного открытия: %v", err)
+	}
+	defer s.Close()
+	fm = manager.NewSavedFilterManagerWithStorage(s, manager.NewTaskManagerWithStorage(s))
+
+	got, err := fm.GetFilter(alice.ID, filter.ID)
+	if err != nil {
+		t.Fatalf("Ошибка загрузки фильтра: %v", err)
+	}
+	if got.Name != "Срочное" || got.Sort != manager.SortByPriority || got.Options.Query != options.Query ||
+		*got.Options.Completed || *got.Options.Priority != high || !got.Options.StartDate.Equal(start) ||
+		len(got.Options.Tags) != 1 || got.Options.EndDate != nil {
+		t.Errorf("Фильтр прочитан неверно: %+v", got)
+	}
+	if _, err := fm.GetFilter(bob.ID, filter.ID); !errors.Is(err, manager.ErrForbidden) {
+		t.Errorf("Чужой фильтр: ожидался ErrForbidden, получено %v", err)
+	}
+
+	filters, _ := fm.GetFilters(alice.ID)
+	if len(filters) != 2 || filters[0].Name != "архив" {
+		t.Errorf("Ожидались фильтры по алфавиту без учета регистра, получено %+v", filters)
+	}
+	if _, err := fm.UpdateFilter(alice.ID, filter.ID, manager.SavedFilterRequest{Name: "Горит"}); err != nil {
+		t.Fatalf("Ошибка обновления: %v", err)
+	}
+	if err := fm.DeleteFilter(bob.ID, filter.ID); !errors.Is(err, manager.ErrForbidden) {
+		t.Errorf("Удаление чужого фильтра: ожидался ErrForbidden, получено %v", err)
+	}
+	if err := fm.DeleteFilter(alice.ID, filter.ID); err != nil {
+		t.Fatalf("Ошибка удаления: %v", err)
+	}
+	if _, err := fm.FindFilter(alice.ID, "горит"); !errors.Is(err, manager.ErrNotFound) {
+		t.Errorf("Удаленный фильтр: ожидался ErrNotFound, получено %v", err)
+	}
+}
+
+func TestProjectsPersistence(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "todo.db")
+	s, err := NewSQLiteStorage(dbPath)
+	if err != nil {
+		t.Fatalf("Ошибка создания хранилища: %v", err)
+	}
+	um := manager.NewUserManager(s)
+	alice, _ := um.Register("alice", "", "password123")
+	bob, _ := um.Register("bob", "", "password123")
+	tm := manager.NewTaskManagerWithStorage(s)
+
+	work, err := tm.CreateProjectForUser(alice.ID, manager.CreateProjectRequest{Name: "Работа", Color: "#ff5722"})
+	if err != nil {
+		t.Fatalf("Ошибка создания проекта: %v", err)
+	}
+	home, _ := tm.CreateProjectForUser(alice.ID, manager.CreateProjectRequest{Name: "Дом"})
+	inboxTask, _ := tm.AddTaskForUser(alice.ID, "Разобрать", nil)
+	report, _ := tm.CreateTaskForUser(alice.ID, "Отчет", nil, manager.UpdateTaskRequest{ProjectID: &work.ID})
+	tm.CreateTaskForUser(alice.ID, "Молоко", nil, manager.UpdateTaskRequest{ProjectID: &home.ID})
+	s.Close()
+
+	s, err = NewSQLiteStorage(dbPath)
+	if err != nil {
+		t.Fatalf("Ошибка повторного открытия: %v", err)
+	}
+	defer s.Close()
+	um = manager.NewUserManager(s)
+	tm = manager.NewTaskManagerWithStorage(s)
+
+	projects, _ := tm.GetProjectsForUser(alice.ID)
+	if len(projects) != 3 || !projects[0].Inbox || projects[1].Name != "Работа" || projects[1].Color != "#ff5722" ||
+		projects[1].OpenTasks != 1 {
+		t.Fatalf("Проекты прочитаны неверно: %+v", projects)
+	}
+	inbox := projects[0]
+	if task, _ := tm.GetTaskForUser(alice.ID, inboxTask); task.ProjectID != inbox.ID {
+		t.Errorf("Задача без проекта должна быть во Входящих: %+v", task)
+	}
+	if _, err := tm.CreateProjectForUser(alice.ID, manager.CreateProjectRequest{Name: "работа"}); !errors.Is(err, manager.ErrConflict) {
+		t.Errorf("Ожидался ErrConflict, получено %v", err)
+	}
+	if _, err := tm.GetProjectForUser(bob.ID, work.ID); !errors.Is(err, manager.ErrForbidden) {
+		t.Errorf("Чужой проект: ожидался ErrForbidden, получено %v", err)
+	}
+	if _, err := tm.MoveTasksForUser(bob.ID, []int{report.ID}, work.ID); !errors.Is(err, manager.ErrForbidden) {
+		t.Errorf("Перенос в чужой проект: ожидался ErrForbidden, получено %v", err)
+	}
+
+	if n, err := tm.MoveTasksForUser(alice.ID, []int{inboxTask, report.ID}, work.ID); err != nil || n != 1 {
+		t.Errorf("Перенос: ожидалась 1 перенесенная задача, получено %d, %v", n, err)
+	}
+	if tasks, _ := tm.FilterTasksAdvancedForUser(alice.ID, manager.FilterOptions{ProjectID: &work.ID}); len(tasks) != 2 {
+		t.Errorf("Фильтр по проекту: ожидалось 2 задачи, получено %d", len(tasks))
+	}
+	if n, err := tm.ArchiveProjectsForUser(alice.ID, []int{work.ID, home.ID}, true); err != nil || n != 2 {
+		t.Errorf("Архивация: %d, %v", n, err)
+	}
+	if _, err := tm.UpdateTaskForUser(alice.ID, inboxTask, manager.UpdateTaskRequest{ProjectID: &home.ID}); !errors.Is(err, manager.ErrConflict) {
+		t.Errorf("Перенос в архивный проект: ожидался ErrConflict, получено %v", err)
+	}
+	if moved, err := tm.DeleteProjectForUser(alice.ID, work.ID); err != nil || moved != 2 {
+		t.Fatalf("Удаление проекта: %d, %v", moved, err)
+	}
+	if task, _ := tm.GetTaskForUser(alice.ID, report.ID); task.ProjectID != inbox.ID {
+		t.Errorf("Задачи удаленного проекта должны перейти во Входящие: %+v", task)
+	}
+
+	// Проекты бота объединяются с проектами учетной записи по названию
+	botUser, _ := um.GetOrCreateUserByTelegramID(4242)
+	botHome, _ := tm.CreateProjectForUser(botUser.ID, manager.CreateProjectRequest{Name: "ДОМ"})
+	botIdeas, _ := tm.CreateProjectForUser(botUser.ID, manager.CreateProjectRequest{Name: "Идеи"})
+	fromBotHome, _ := tm.CreateTaskForUser(botUser.ID, "Хлеб", nil, manager.UpdateTaskRequest{ProjectID: &botHome.ID})
+	fromBotIdeas, _ := tm.CreateTaskForUser(botUser.ID, "Книга", nil, manager.UpdateTaskRequest{ProjectID: &botIdeas.ID})
+	fromBotInbox, _ := tm.AddTaskForUser(botUser.ID, "Из бота", nil)
+	code, _, _ := um.CreateLinkCode(alice.ID)
+	if _, _, err := um.LinkTelegram(code, 4242); err != nil {
+		t.Fatalf("Ошибка привязки: %v", err)
+	}
+
+	projects, _ = tm.GetProjectsForUser(alice.ID)
+	if len(projects) != 3 || projects[2].Name != "Идеи" {
+		t.Fatalf("Ожидались Входящие, Дом и Идеи, получено %+v", projects)
+	}
+	for taskID, projectID := range map[int]int{fromBotHome.ID: home.ID, fromBotIdeas.ID: projects[2].ID, fromBotInbox: inbox.ID} {
+		if task, _ := tm.GetTaskForUser(alice.ID, taskID); task == nil || task.ProjectID != projectID {
+			t.Errorf("Задача %d должна перейти в проект %d, получено %+v", taskID, projectID, task)
+		}
+	}
+}
+
+func TestSharedProjectsPersistence(t *testing.T) {
+	s := newTestStorage(t)
+	um := manager.NewUserManager(s)
+	alice, _ := um.Register("alice", "", "password123")
+	bob, _ := um.Register("bob", "", "password123")
+	tm := manager.NewTaskManagerWithStorage(s)
+	stm := manager.NewSubTaskManagerWithStorage(s)
+	tm.SetSubTaskManager(stm)
+
+	family, _ := tm.CreateProjectForUser(alice.ID, manager.CreateProjectRequest{Name: "Семья"})
+	tm.AddTaskForUser(alice.ID, "Личное", nil)
+	shopping, _ := tm.CreateTaskForUser(alice.ID, "Купить продукты", []string{"дом"}, manager.UpdateTaskRequest{ProjectID: &family.ID})
+	bobsTask, _ := tm.AddTaskForUser(bob.ID, "Свое", nil)
+
+	if _, err := tm.ShareProjectForUser(alice.ID, family.ID, *bob, manager.RoleViewer); err != nil {
+		t.Fatalf("Ошибка приглашения: %v", err)
+	}
+	member, err := tm.ShareProjectForUser(alice.ID, family.ID, *bob, manager.RoleEditor)
+	if err != nil || member.Role != manager.RoleEditor {
+		t.Fatalf("Смена роли: %+v, %v", member, err)
+	}
+	members, _ := tm.GetMembersForUser(bob.ID, family.ID)
+	if len(members) != 2 || members[0].Username != "alice" || members[1].Username != "bob" || members[1].Role != manager.RoleEditor {
+		t.Errorf("Участники прочитаны неверно: %+v", members)
+	}
+
+	projects, _ := tm.GetProjectsForUser(bob.ID)
+	if len(projects) != 2 || projects[1].ID != family.ID || projects[1].Role != manager.RoleEditor || projects[1].OpenTasks != 1 {
+		t.Fatalf("Ожидались Входящие bob и общий проект: %+v", projects)
+	}
+	tasks, _ := tm.GetAllTasksForUser(bob.ID)
+	if len(tasks) != 2 {
+		t.Errorf("Ожидались своя и общая задачи, получено %d", len(tasks))
+	}
+	if results, _ := tm.SearchForUser(bob.ID, "продукты", manager.FilterOptions{}); len(results) != 1 {
+		t.Errorf("Поиск: общая задача должна находиться у участника, получено %d", len(results))
+	}
+	if tasks, _ := tm.FilterTasksAdvancedForUser(bob.ID, manager.FilterOptions{Query: "tag:дом"}); len(tasks) != 1 {
+		t.Errorf("Фильтр: общая задача должна находиться у участника, получено %d", len(tasks))
+	}
+
+	done, _, err := tm.ToggleCompleteForUser(bob.ID, shopping.ID)
+	if err != nil || !done.Completed || done.UserID != alice.ID {
+		t.Errorf("Выполнение редактором: %+v, %v", done, err)
+	}
+	if _, err := stm.AddSubTask(bob.ID, shopping.ID, "Молоко"); err != nil {
+		t.Errorf("Подзадача от редактора: %v", err)
+	}
+	if _, err := tm.MoveTasksForUser(bob.ID, []int{bobsTask}, family.ID); err != nil {
+		t.Errorf("Перенос своей задачи в общий проект: %v", err)
+	}
+
+	invite, _ := tm.CreateProjectInviteForUser(alice.ID, family.ID, manager.RoleViewer)
+	carol, _ := um.Register("carol", "", "password123")
+	if _, err := tm.JoinProjectForUser(*carol, invite.Code); err != nil {
+		t.Fatalf("Присоединение по коду: %v", err)
+	}
+	if _, err := tm.JoinProjectForUser(*carol, invite.Code); !errors.Is(err, manager.ErrNotFound) {
+		t.Errorf("Повторный код: ожидался ErrNotFound, получено %v", err)
+	}
+	if err := tm.DeleteTaskForUser(carol.ID, shopping.ID); !errors.Is(err, manager.ErrForbidden) {
+		t.Errorf("Удаление читателем: ожидался ErrForbidden, получено %v", err)
+	}
+
+	// Удаление проекта переносит задачи участников в их Входящие
+	if moved, err := tm.DeleteProjectForUser(alice.ID, family.ID); err != nil || moved != 2 {
+		t.Fatalf("Удаление проекта: %d, %v", moved, err)
+	}
+	if task, err := tm.GetTaskForUser(bob.ID, bobsTask); err != nil || task.ProjectID != projects[0].ID {
+		t.Errorf("Задача bob должна вернуться в его Входящие: %+v, %v", task, err)
+	}
+	if tasks, _ := tm.GetAllTasksForUser(carol.ID); len(tasks) != 0 {
+		t.Errorf("Удаленный проект больше не виден участникам, задач: %d", len(tasks))
+	}
+}
+
+func TestTaskAssigneesPersistence(t *testing.T) {
+	s := newTestStorage(t)
+	um := manager.NewUserManager(s)
+	alice, _ := um.Register("alice", "", "password123")
+	bob, _ := um.Register("bob", "", "password123")
+	tm := manager.NewTaskManagerWithStorage(s)
+	rm := manager.NewReminderManagerWithStorage(s, tm)
+
+	family, _ := tm.CreateProjectForUser(alice.ID, manager.CreateProjectRequest{Name: "Семья"})
+	tm.ShareProjectForUser(alice.ID, family.ID, *bob, manager.RoleEditor)
+	shopping, _ := tm.CreateTaskForUser(alice.ID, "Купить продукты", nil, manager.UpdateTaskRequest{ProjectID: &family.ID, AssigneeID: &bob.ID})
+	if shopping.AssigneeID != bob.ID {
+		t.Fatalf("Исполнитель не сохранен при создании: %+v", shopping)
+	}
+	if tasks, _ := tm.FilterTasksAdvancedForUser(bob.ID, manager.FilterOptions{AssigneeID: &bob.ID}); len(tasks) != 1 {
+		t.Errorf("Назначено bob: ожидалась 1 задача, получено %d", len(tasks))
+	}
+	reminders, err := rm.ClaimDue("test", time.Now().Add(time.Minute), 10)
+	if err != nil || len(reminders) != 1 || reminders[0].UserID != bob.ID || reminders[0].Type != manager.ReminderTypeAssignment {
+		t.Errorf("Ожидалось уведомление о назначении для bob: %+v, %v", reminders, err)
+	}
+
+	// Перенос во Входящие автора снимает исполнителя без прав на проект
+	inbox, _ := tm.InboxForUser(alice.ID)
+	tm.MoveTasksForUser(alice.ID, []int{shopping.ID}, inbox.ID)
+	if task, _ := tm.GetTaskForUser(alice.ID, shopping.ID); task.AssigneeID != 0 {
+		t.Errorf("После переноса исполнитель должен сняться, получено %d", task.AssigneeID)
+	}
+
+	tm.MoveTasksForUser(alice.ID, []int{shopping.ID}, family.ID)
+	tm.UpdateTaskForUser(alice.ID, shopping.ID, manager.UpdateTaskRequest{AssigneeID: &bob.ID})
+	if err := tm.RemoveMemberForUser(alice.ID, family.ID, bob.ID); err != nil {
+		t.Fatalf("Исключение участника: %v", err)
+	}
+	if task, _ := tm.GetTaskForUser(alice.ID, shopping.ID); task.AssigneeID != 0 {
+		t.Errorf("После исключения исполнитель должен сняться, получено %d", task.AssigneeID)
+	}
+}
+
+func TestCommentsPersistence(t *testing.T) {
+	s := newTestStorage(t)
+	um := manager.NewUserManager(s)
+	alice, _ := um.Register("alice", "", "password123")
+	bob, _ := um.Register("bob", "", "password123")
+	tm := manager.NewTaskManagerWithStorage(s)
+	cm := manager.NewCommentManagerWithStorage(s, tm)
+
+	family, _ := tm.CreateProjectForUser(alice.ID, manager.CreateProjectRequest{Name: "Семья"})
+	tm.ShareProjectForUser(alice.ID, family.ID, *bob, manager.RoleViewer)
+	task, _ := tm.CreateTaskForUser(alice.ID, "Купить продукты", nil, manager.UpdateTaskRequest{ProjectID: &family.ID})
+
+	first, err := cm.AddComment(*alice, task.ID, "Молоко есть?")
+	if err != nil {
+		t.Fatalf("Ошибка добавления комментария: %v", err)
+	}
+	reply, _ := cm.AddComment(*bob, task.ID, "Нет")
+	if _, err := cm.UpdateComment(alice.ID, reply.ID, "Есть"); !errors.Is(err, manager.ErrForbidden) {
+		t.Errorf("Изменение чужого комментария: ожидался ErrForbidden, получено %v", err)
+	}
+	if _, err := cm.UpdateComment(bob.ID, reply.ID, "Нет, купи две"); err != nil {
+		t.Fatalf("Изменение своего комментария: %v", err)
+	}
+
+	comments, err := cm.GetComments(bob.ID, task.ID)
+	if err != nil || len(comments) != 2 || comments[0].ID != first.ID || comments[0].Username != "alice" ||
+		comments[1].Body != "Нет, купи две" || !comments[1].UpdatedAt.After(comments[1].CreatedAt) {
+		t.Fatalf("Комментарии прочитаны неверно: %+v, %v", comments, err)
+	}
+	if err := cm.DeleteComment(alice.ID, first.ID); err != nil {
+		t.Fatalf("Удаление комментария: %v", err)
+	}
+
+	// Комментарии удаляются вместе с задачей при очистке корзины
+	if err := tm.DeleteTaskForUser(alice.ID, task.ID); err != nil {
+		t.Fatalf("Удаление задачи: %v", err)
+	}
+	if _, err := tm.PurgeTrash(time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("Очистка корзины: %v", err)
+	}
+	if _, err := s.GetComment(reply.ID); !errors.Is(err, manager.ErrNotFound) {
+		t.Errorf("Комментарий удаленной задачи: ожидался ErrNotFound, получено %v", err)
+	}
+}
+
+func TestAttachmentsPersistence(t *testing.T) {
+	s := newTestStorage(t)
+	um := manager.NewUserManager(s)
+	alice, _ := um.Register("alice", "", "password123")
+	tm := manager.NewTaskManagerWithStorage(s)
+	am := manager.NewAttachmentManagerWithStorage(s, tm, t.TempDir())
+
+	task, _ := tm.AddTaskForUser(alice.ID, "Оплатить счет", nil)
+	other, _ := tm.AddTaskForUser(alice.ID, "Сверить счет", nil)
+	first, err := am.AddAttachment(alice.ID, task, "счет.pdf", strings.NewReader("%PDF-1.4 счет"))
+	if err != nil {
+		t.Fatalf("Ошибка добавления вложения: %v", err)
+	}
+	copied, _ := am.AddAttachment(alice.ID, other, "счет.pdf", strings.NewReader("%PDF-1.4 счет"))
+
+	attachments, err := am.GetAttachments(alice.ID, task)
+	if err != nil || len(attachments) != 1 || attachments[0].ContentType != "application/pdf" ||
+		attachments[0].SHA256 != first.SHA256 || attachments[0].UserID != alice.ID {
+		t.Fatalf("Вложения прочитаны неверно: %+v, %v", attachments, err)
+	}
+	if count, err := s.CountAttachmentsByHash(first.SHA256); err != nil || count != 2 {
+		t.Errorf("Ожидалось 2 ссылки на файл, получено %d, %v", count, err)
+	}
+
+	// Вложения удаляются вместе с задачей при очистке корзины, файл остается у копии
+	if err := tm.DeleteTaskForUser(alice.ID, task); err != nil {
+		t.Fatalf("Удаление задачи: %v", err)
+	}
+	if _, err := tm.PurgeTrash(time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("Очистка корзины: %v", err)
+	}
+	if _, err := s.GetAttachment(first.ID); !errors.Is(err, manager.ErrNotFound) {
+		t.Errorf("Вложение удаленной задачи: ожидался ErrNotFound, получено %v", err)
+	}
+	if hashes, err := s.GetAttachmentHashes(); err != nil || len(hashes) != 1 || hashes[0] != copied.SHA256 {
+		t.Errorf("Хеши используемых файлов: %v, %v", hashes, err)
+	}
+	if _, file, err := am.OpenAttachment(alice.ID, copied.ID); err != nil {
+		t.Errorf("Копия должна открываться: %v", err)
+	} else {
+		file.Close()
+	}
+}
+
+func TestTaskEventsPersistence(t *testing.T) {
+	s := newTestStorage(t)
+	um := manager.NewUserManager(s)
+	alice, _ := um.Register("alice", "", "password123")
+	tm := manager.NewTaskManagerWithStorage(s)
+	stm := manager.NewSubTaskManagerWithStorage(s)
+	tm.SetSubTaskManager(stm)
+
+	task, _ := tm.AddTaskForUser(alice.ID, "Оплатить счет", []string{"дом"})
+	priority := manager.PriorityHigh
+	if _, err := tm.WithSource(manager.SourceAPI).UpdateTaskForUser(alice.ID, task, manager.UpdateTaskRequest{Priority: &priority}); err != nil {
+		t.Fatalf("Ошибка изменения задачи: %v", err)
+	}
+	subtask, _ := stm.AddSubTask(alice.ID, task, "Найти квитанцию")
+
+	events, err := tm.GetTaskEvents(alice.ID, task)
+	if err != nil || len(events) != 3 {
+		t.Fatalf("История прочитана неверно: %+v, %v", events, err)
+	}
+	if events[0].Type != manager.EventSubTaskAdded || events[0].SubTaskID != subtask || events[0].Username != "alice" {
+		t.Errorf("Событие подзадачи: %+v", events[0])
+	}
+	if e := events[1]; e.Type != manager.EventUpdated || e.Source != manager.SourceAPI || len(e.Changes) != 1 ||
+		e.Changes[0].Field != "priority" || e.Changes[0].New != "high" || e.CreatedAt.IsZero() {
+		t.Errorf("Событие изменения: %+v", e)
+	}
+	if e := events[2]; e.Type != manager.EventCreated || e.SubTaskID != 0 || len(e.Changes) == 0 {
+		t.Errorf("Событие создания: %+v", e)
+	}
+
+	// История остается после удаления задачи
+	if err := tm.DeleteTaskForUser(alice.ID, task); err != nil {
+		t.Fatalf("Ошибка удаления задачи: %v", err)
+	}
+	events, err = s.GetTaskEvents(task)
+	if err != nil || len(events) != 4 || events[0].Type != manager.EventDeleted {
+		t.Errorf("История удаленной задачи: %+v, %v", events, err)
+	}
+}
+
+func TestTrashPersistence(t *testing.T) {
+	s := newTestStorage(t)
+	um := manager.NewUserManager(s)
+	alice, _ := um.Register("alice", "", "password123")
+	tm := manager.NewTaskManagerWithStorage(s)
+	stm := manager.NewSubTaskManagerWithStorage(s)
+	tm.SetSubTaskManager(stm)
+	rm := manager.NewReminderManagerWithStorage(s, tm)
+
+	task, _ := tm.AddTaskForUser(alice.ID, "Оплатить счет", []string{"счета"})
+	tm.AddTaskForUser(alice.ID, "Позвонить маме", nil)
+	subtask, _ := stm.AddSubTask(alice.ID, task, "Найти квитанцию")
+	trigger := time.Now().Add(time.Hour)
+	if _, err := rm.CreateReminder(alice.ID, task, manager.CreateReminderRequest{TriggerTime: &trigger, Message: "Оплатить"}); err != nil {
+		t.Fatalf("Ошибка создания напоминания: %v", err)
+	}
+
+	if err := tm.DeleteTaskForUser(alice.ID, task); err != nil {
+		t.Fatalf("Ошибка удаления задачи: %v", err)
+	}
+	if tasks, _ := tm.GetAllTasksForUser(alice.ID); len(tasks) != 1 {
+		t.Errorf("Задача из корзины видна в списке: %+v", tasks)
+	}
+	if results, _ := tm.SearchForUser(alice.ID, "квитанцию", manager.FilterOptions{}); len(results) != 0 {
+		t.Errorf("Задача из корзины находится поиском: %+v", results)
+	}
+	if tags, _ := tm.GetTagsForUser(alice.ID); len(tags) != 0 {
+		t.Errorf("Теги задачи из корзины в списке тегов: %+v", tags)
+	}
+	if inbox, _ := s.GetInbox(alice.ID); inbox.OpenTasks != 1 {
+		t.Errorf("Задача из корзины в счетчике проекта: %d", inbox.OpenTasks)
+	}
+	if claimed, _ := rm.ClaimDue("test", trigger.Add(time.Minute), 10); len(claimed) != 0 {
+		t.Errorf("Напоминание задачи из корзины отправлено: %+v", claimed)
+	}
+	if _, _, err := tm.ToggleCompleteForUser(alice.ID, task); !errors.Is(err, manager.ErrNotFound) {
+		t.Errorf("Изменение задачи из корзины: ожидался ErrNotFound, получено %v", err)
+	}
+	if err := tm.DeleteTaskForUser(alice.ID, task); !errors.Is(err, manager.ErrNotFound) {
+		t.Errorf("Повторное удаление: ожидался ErrNotFound, получено %v", err)
+	}
+
+	trash, err := tm.GetTrashForUser(alice.ID)
+	if err != nil || len(trash) != 1 || trash[0].ID != task || trash[0].DeletedAt == nil || trash[0].Tags[0] != "счета" {
+		t.Fatalf("Корзина прочитана неверно: %+v, %v", trash, err)
+	}
+
+	// Задача возвращается вместе с подзадачами и напоминаниями
+	restored, err := tm.RestoreTaskForUser(alice.ID, task)
+	if err != nil || restored.DeletedAt != nil {
+		t.Fatalf("Восстановление: %+v, %v", restored, err)
+	}
+	if subtasks, _ := stm.GetSubTasks(alice.ID, task); len(subtasks) != 1 || subtasks[0].ID != subtask {
+		t.Errorf("Подзадачи восстановленной задачи: %+v", subtasks)
+	}
+	if claimed, _ := rm.ClaimDue("test", trigger.Add(time.Minute), 10); len(claimed) != 1 {
+		t.Errorf("Напоминание восстановленной задачи: %+v", claimed)
+	}
+	if _, err := tm.RestoreTaskForUser(alice.ID, task); !errors.Is(err, manager.ErrNotFound) {
+		t.Errorf("Восстановление задачи не из корзины: ожидался ErrNotFound, получено %v", err)
+	}
+
+	// Очистка удаляет только задачи, пролежавшие в корзине дольше срока
+	tm.DeleteTaskForUser(alice.ID, task)
+	if purged, err := tm.PurgeTrash(time.Now().Add(-time.Hour)); err != nil || purged != 0 {
+		t.Errorf("Очистка свежей корзины: %d, %v", purged, err)
+	}
+	if purged, err := tm.PurgeTrash(time.Now().Add(time.Minute)); err != nil || purged != 1 {
+		t.Fatalf("Очистка корзины: %d, %v", purged, err)
+	}
+	if trash, _ := tm.GetTrashForUser(alice.ID); len(trash) != 0 {
+		t.Errorf("Корзина после очистки: %+v", trash)
+	}
+	if _, err := s.GetSubTaskByID(subtask); !errors.Is(err, manager.ErrNotFound) {
+		t.Errorf("Подзадача удаленной задачи: ожидался ErrNotFound, получено %v", err)
+	}
+	if _, err := tm.RestoreTaskForUser(alice.ID, task); !errors.Is(err, manager.ErrNotFound) {
+		t.Errorf("Восстановление после очистки: ожидался ErrNotFound, получено %v", err)
+	}
+}
+
+// Веб-сервер и бот работают с одной базой через разные менеджеры
+func TestTaskVersionPersistence(t *testing.T) {
+	s := newTestStorage(t)
+	um := manager.NewUserManager(s)
+	alice, _ := um.Register("alice", "", "password123")
+	web := manager.NewTaskManagerWithStorage(s)
+	bot := manager.NewTaskManagerWithStorage(s)
+
+	id, _ := web.AddTaskForUser(alice.ID, "Отчет", []string{"работа"})
+	opened, _ := web.GetTaskForUser(alice.ID, id)
+	if opened.Version != 1 {
+		t.Fatalf("Версия новой задачи: %d", opened.Version)
+	}
+	if toggled, _, _ := bot.ToggleCompleteForUser(alice.ID, id); toggled.Version != 2 {
+		t.Errorf("Версия после отметки выполнения: %d", toggled.Version)
+	}
+
+	description := "Годовой отчет"
+	_, err := web.UpdateTaskForUser(alice.ID, id, manager.UpdateTaskRequest{Description: &description, Version: &opened.Version})
+	var conflict *manager.VersionConflictError
+	if !errors.As(err, &conflict) || !conflict.Current.Completed || conflict.Current.Version != 2 {
+		t.Fatalf("Ожидался VersionConflictError, получено %v", err)
+	}
+	if _, err := s.UpdateTask(alice.ID, id, manager.UpdateTaskRequest{Description: &description, Version: &opened.Version}); !errors.As(err, &conflict) {
+		t.Errorf("Хранилище приняло устаревшую версию: %v", err)
+	}
+
+	// Безусловные правки разных полей из двух менеджеров не теряются
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			text := fmt.Sprintf("Отчет %d", i)
+			if _, err := web.UpdateTaskForUser(alice.ID, id, manager.UpdateTaskRequest{Description: &text}); err != nil {
+				t.Errorf("Изменение описания: %v", err)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			tags := []string{"работа", "срочно"}
+			if _, err := bot.UpdateTaskForUser(alice.ID, id, manager.UpdateTaskRequest{Tags: &tags}); err != nil {
+				t.Errorf("Изменение тегов: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	task, _ := web.GetTaskForUser(alice.ID, id)
+	if !strings.HasPrefix(task.Description, "Отчет ") || len(task.Tags) != 2 || task.Version != 42 {
+		t.Errorf("Неожиданная задача после одновременных правок: %+v", task)
+	}
+}
+
+func TestSyncPersistence(t *testing.T) {
+	s := newTestStorage(t)
+	um := manager.NewUserManager(s)
+	alice, _ := um.Register("alice", "", "password123")
+	bob, _ := um.Register("bob", "", "password123")
+	tm := manager.NewTaskManagerWithStorage(s)
+	stm := manager.NewSubTaskManagerWithStorage(s)
+	tm.SetSubTaskManager(stm)
+
+	family, _ := tm.CreateProjectForUser(alice.ID, manager.CreateProjectRequest{Name: "Семья"})
+	tm.ShareProjectForUser(alice.ID, family.ID, *bob, manager.RoleEditor)
+	task, _ := tm.CreateTaskForUser(alice.ID, "Купить продукты", nil, manager.UpdateTaskRequest{ProjectID: &family.ID})
+	subtaskID, _ := stm.AddSubTask(alice.ID, task.ID, "Молоко")
+	tm.AddTaskForUser(alice.ID, "Личное", nil)
+
+	set, err := tm.Changes(bob.ID, 0, 0)
+	if err != nil || len(set.Tasks) != 1 || len(set.SubTasks) != 1 || set.SubTasks[0].ID != subtaskID {
+		t.Fatalf("Снимок: %+v, %v", set, err)
+	}
+
+	// Лента хранится в базе: другой экземпляр продолжает те же номера
+	other := manager.NewTaskManagerWithStorage(s)
+	stm.ToggleSubTask(bob.ID, subtaskID)
+	changed, _ := other.Changes(bob.ID, set.Next, 0)
+	if len(changed.SubTasks) != 1 || !changed.SubTasks[0].Completed || changed.Next != set.Next+1 {
+		t.Errorf("Изменение подзадачи: %+v", changed)
+	}
+
+	// Исключенный участник получает удаление задач проекта
+	tm.RemoveMemberForUser(alice.ID, family.ID, bob.ID)
+	removed, _ := tm.Changes(bob.ID, changed.Next, 0)
+	if len(removed.Deleted) != 2 || removed.Deleted[0] != (manager.Tombstone{Entity: manager.SyncSubTask, ID: subtaskID}) ||
+		removed.Deleted[1] != (manager.Tombstone{Entity: manager.SyncTask, ID: task.ID}) {
+		t.Errorf("Потеря доступа: %+v", removed)
+	}
+	if own, _ := tm.Changes(alice.ID, 0, 0); len(own.Tasks) != 2 || len(own.SubTasks) != 1 {
+		t.Errorf("Лента автора: %+v", own)
+	}
+
+	// Снова участник - снова видит задачу с подзадачей; корзина задачи
+	// убирает из ленты и подзадачу
+	tm.ShareProjectForUser(alice.ID, family.ID, *bob, manager.RoleViewer)
+	shared, _ := tm.Changes(bob.ID, removed.Next, 0)
+	if len(shared.Tasks) != 1 || len(shared.SubTasks) != 1 || len(shared.Deleted) != 0 {
+		t.Errorf("Повторное приглашение: %+v", shared)
+	}
+	tm.DeleteTaskForUser(alice.ID, task.ID)
+	trashed, _ := tm.Changes(bob.ID, shared.Next, 0)
+	if len(trashed.Tasks) != 0 || len(trashed.Deleted) != 2 {
+		t.Errorf("Корзина: %+v", trashed)
+	}
+
+	// ClientID созданного хранится в базе: повтор пакета после перезапуска
+	// возвращает ту же задачу
+	offline := "Записано без связи"
+	batch := []manager.ClientChange{{Op: manager.OpCreateTask, ClientID: "phone-1", Changes: manager.UpdateTaskRequest{Description: &offline}}}
+	first, _ := tm.ApplyChanges(bob.ID, stm, batch)
+	replay, _ := other.ApplyChanges(bob.ID, stm, batch)
+	if first[0].Status != manager.ChangeApplied || replay[0].Status != manager.ChangeApplied || replay[0].TaskID != first[0].TaskID {
+		t.Errorf("Повтор пакета: %+v, %+v", first[0], replay[0])
+	}
+	if tasks, _ := tm.GetAllTasksForUser(bob.ID); len(tasks) != 1 {
+		t.Errorf("Повтор пакета создал задачу заново: %d задач", len(tasks))
+	}
+}
+
+func TestImportUserData(t *testing.T) {
+	// Перенос из памяти в SQLite
+	mem := manager.NewTaskManager()
+	memSubTasks := manager.NewSubTaskManager()
+	mem.SetSubTaskManager(memSubTasks)
+	work, _ := mem.CreateProjectForUser(1, manager.CreateProjectRequest{Name: "Работа"})
+	due := time.Now().Add(72 * time.Hour).Truncate(time.Second)
+	first, _ := mem.CreateTaskForUser(1, "Отчет", []string{"срочно"}, manager.UpdateTaskRequest{ProjectID: &work.ID, DueDate: &due})
+	mem.AddTaskForUser(1, "Личное", nil)
+	memSubTasks.AddSubTask(1, first.ID, "Собрать цифры")
+	data, err := mem.ExportForUser(1)
+	if err != nil {
+		t.Fatalf("Выгрузка: %v", err)
+	}
+
+	s := newTestStorage(t)
+	um := manager.NewUserManager(s)
+	alice, _ := um.Register("alice", "", "password123")
+	bob, _ := um.Register("bob", "", "password123")
+	tm := manager.NewTaskManagerWithStorage(s)
+	stm := manager.NewSubTaskManagerWithStorage(s)
+	tm.SetSubTaskManager(stm)
+
+	// Пробный запуск откатывает транзакцию
+	dry, err := tm.ImportForUser(alice.ID, data, manager.ImportMerge, true)
+	if err != nil || dry.TasksCreated != 2 || dry.ProjectsCreated != 1 || dry.SubTasksCreated != 1 {
+		t.Fatalf("Пробный импорт: %+v, %v", dry, err)
+	}
+	if tasks, _ := tm.GetAllTasksForUser(alice.ID); len(tasks) != 0 {
+		t.Fatalf("Пробный импорт сохранил задачи: %d", len(tasks))
+	}
+
+	report, err := tm.ImportForUser(alice.ID, data, manager.ImportMerge, false)
+	if err != nil || report.TasksCreated != 2 || report.Tags != 1 {
+		t.Fatalf("Импорт: %+v, %v", report, err)
+	}
+	task, err := tm.GetTaskForUser(alice.ID, report.TaskIDs[first.ID])
+	if err != nil || task.ProjectID != report.ProjectIDs[work.ID] || !task.DueDate.Equal(due) || len(task.Tags) != 1 {
+		t.Fatalf("Задача после импорта: %+v, %v", task, err)
+	}
+	if subtasks, _ := stm.GetSubTasks(alice.ID, task.ID); len(subtasks) != 1 {
+		t.Errorf("Подзадачи после импорта: %+v", subtasks)
+	}
+
+	// Задача участника общего проекта при замене уходит в его Входящие
+	tm.ShareProjectForUser(alice.ID, task.ProjectID, *bob, manager.RoleEditor)
+	bobTask, _ := tm.CreateTaskForUser(bob.ID, "Задача Боба", nil, manager.UpdateTaskRequest{ProjectID: &task.ProjectID})
+	replaced, err := tm.ImportForUser(alice.ID, data, manager.ImportReplace, false)
+	if err != nil || replaced.TasksTrashed != 2 || replaced.TasksMoved != 1 || replaced.ProjectsDeleted != 1 || replaced.ProjectsCreated != 1 {
+		t.Fatalf("Замена: %+v, %v", replaced, err)
+	}
+	if tasks, _ := tm.GetAllTasksForUser(alice.ID); len(tasks) != 2 {
+		t.Errorf("После замены задач: %d", len(tasks))
+	}
+	bobInbox, _ := s.GetInbox(bob.ID)
+	moved, err := tm.GetTaskForUser(bob.ID, bobTask.ID)
+	if err != nil || moved.ProjectID != bobInbox.ID || moved.DeletedAt != nil {
+		t.Errorf("Задача участника после замены: %+v, %v", moved, err)
+	}
+	if trash, _ := tm.GetTrashForUser(bob.ID); len(trash) != 0 {
+		t.Errorf("Задачи участника в корзине: %+v", trash)
+	}
+
+	// Ошибка в документе - ничего не импортируется
+	broken := *data
+	broken.Tasks = append([]manager.ExportTask{}, data.Tasks...)
+	broken.Tasks[0].Description = ""
+	if _, err := tm.ImportForUser(alice.ID, &broken, manager.ImportMerge, false); !errors.Is(err, manager.ErrInvalid) {
+		t.Errorf("Пустое описание: ожидался ErrInvalid, получено %v", err)
+	}
+}
+
+func TestClaimLegacyTasks(t *testing.T) {
+	s := newTestStorage(t)
+	um := manager.NewUserManager(s)
+	tm := manager.NewTaskManagerWithStorage(s)
+	stm := manager.NewSubTaskManagerWithStorage(s)
+	tm.SetSubTaskManager(stm)
+
+	legacy, _ := um.CreateUser(manager.LegacyDeviceID, 0)
+	old, _ := tm.CreateTaskForUser(legacy.ID, "Задача до учетных записей", []string{"Дом"}, manager.UpdateTaskRequest{})
+	stm.AddSubTask(legacy.ID, old.ID, "Шаг")
+
+	// Регистрация не забирает задачи без владельца
+	alice, _ := um.Register("alice", "", "password123")
+	bob, _ := um.Register("bob", "", "password123")
+	for _, user := range []*manager.User{alice, bob} {
+		if tasks, _ := tm.GetAllTasksForUser(user.ID); len(tasks) != 0 {
+			t.Fatalf("%s видит задачи без владельца: %+v", user.Username, tasks)
+		}
+	}
+
+	claimed, err := s.ClaimLegacyTasks(alice.ID)
+	if err != nil || claimed != 1 {
+		t.Fatalf("Передача задач: %d, %v", claimed, err)
+	}
+	inbox, _ := s.GetInbox(alice.ID)
+	task, err := tm.GetTaskForUser(alice.ID, old.ID)
+	if err != nil || task.ProjectID != inbox.ID || len(task.Tags) != 1 || task.Tags[0] != "Дом" {
+		t.Fatalf("Задача после передачи: %+v, %v", task, err)
+	}
+	if subtasks, _ := stm.GetSubTasks(alice.ID, old.ID); len(subtasks) != 1 {
+		t.Errorf("Подзадачи после передачи: %+v", subtasks)
+	}
+	if tasks, _ := tm.GetAllTasksForUser(bob.ID); len(tasks) != 0 {
+		t.Errorf("Второй пользователь видит переданные задачи: %+v", tasks)
+	}
+	if claimed, _ := s.ClaimLegacyTasks(bob.ID); claimed != 0 {
+		t.Errorf("Повторная передача: %d задач", claimed)
+	}
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"todo-app/internal/manager"
+)
+
+// GetVisibleSubTasks возвращает подзадачи всех задач, которые видит пользователь
+func (s *SQLiteStorage) GetVisibleSubTasks(userID int) ([]manager.SubTask, error) {
+	rows, err := s.db.Query(`
+	SELECT s.id, s.user_id, s.task_id, s.description, s.created_at, s.updated_at, s.completed
+	FROM subtasks s JOIN tasks ON tasks.id = s.task_id
+	WHERE `+visibleTask+" ORDER BY s.id", visibleArgs(userID)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subtasks := []manager.SubTask{}
+	for rows.Next() {
+		var subtask manager.SubTask
+		if err := rows.Scan(&subtask.ID, &subtask.UserID, &subtask.TaskID, &subtask.Description,
+			&subtask.CreatedAt, &subtask.UpdatedAt, &subtask.Completed); err != nil {
+			return nil, err
+		}
+		subtasks = append(subtasks, subtask)
+	}
+	return subtasks, rows.Err()
+}
+
+// GetSyncEntries возвращает номер последнего изменения в ленте пользователя
+// и не больше limit записей после since по порядку номеров; с since = 0 -
+// только не удаленные. Номера выдают триггеры из миграции 021, поэтому
+// запрос читает диапазон индекса idx_sync_entries_seq.
+func (s *SQLiteStorage) GetSyncEntries(userID, since, limit int) ([]manager.SyncEntry, int, error) {
+	var last int
+	if err := s.db.QueryRow("SELECT COALESCE(MAX(seq), 0) FROM sync_entries WHERE user_id = ?", userID).Scan(&last); err != nil {
+		return nil, 0, err
+	}
+	// Записи после last могли появиться уже после первого запроса:
+	// они придут следующим запросом ленты
+	rows, err := s.db.Query(`
+	SELECT entity, entity_id, seq, deleted FROM sync_entries
+	WHERE user_id = ? AND seq > ? AND seq <= ? AND (? > 0 OR NOT deleted)
+	ORDER BY seq LIMIT ?`, userID, since, last, since, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entries := []manager.SyncEntry{}
+	for rows.Next() {
+		var e manager.SyncEntry
+		if err := rows.Scan(&e.Entity, &e.ID, &e.Seq, &e.Deleted); err != nil {
+			return nil, 0, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, last, rows.Err()
+}
+
+// GetSyncItems возвращает задачи не из корзины и подзадачи по ID. Права
+// не проверяются: ID берутся из ленты пользователя (см. GetSyncEntries).
+func (s *SQLiteStorage) GetSyncItems(taskIDs, subtaskIDs []int) ([]manager.Task, []manager.SubTask, error) {
+	tasks := []manager.Task{}
+	if len(taskIDs) > 0 {
+		rows, err := s.db.Query("SELECT "+taskColumns+" FROM tasks WHERE deleted_at IS NULL AND id IN ("+
+			placeholders(len(taskIDs))+")", intArgs(taskIDs)...)
+		if err != nil {
+			return nil, nil, err
+		}
+		tasks, err = scanTasks(rows)
+		rows.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	subtasks := []manager.SubTask{}
+	if len(subtaskIDs) > 0 {
+		rows, err := s.db.Query(`
+		SELECT id, user_id, task_id, description, created_at, updated_at, completed
+		FROM subtasks WHERE id IN (`+placeholders(len(subtaskIDs))+")", intArgs(subtaskIDs)...)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var subtask manager.SubTask
+			if err := rows.Scan(&subtask.ID, &subtask.UserID, &subtask.TaskID, &subtask.Description,
+				&subtask.CreatedAt, &subtask.UpdatedAt, &subtask.Completed); err != nil {
+				return nil, nil, err
+			}
+			subtasks = append(subtasks, subtask)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, nil, err
+		}
+	}
+	return tasks, subtasks, nil
+}
+
+// GetClientChange возвращает задачу или подзадачу, созданную изменением
+// клиента clientID; NotFound - такого изменения еще не было
+func (s *SQLiteStorage) GetClientChange(userID int, clientID string) (manager.SyncEntity, int, error) {
+	var entity manager.SyncEntity
+	var id int
+	err := s.db.QueryRow("SELECT entity, entity_id FROM client_changes WHERE user_id = ? AND client_id = ?",
+		userID, clientID).Scan(&entity, &id)
+	if err == sql.ErrNoRows {
+		return "", 0, manager.NotFound("изменение клиента %q не найдено", clientID)
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	return entity, id, nil
+}
+
+// SaveClientChange запоминает, что изменение клиента clientID создало entity с ID id
+func (s *SQLiteStorage) SaveClientChange(userID int, clientID string, entity manager.SyncEntity, id int) error {
+	_, err := s.db.Exec(`
+	INSERT INTO client_changes (user_id, client_id, entity, entity_id, created_at) VALUES (?, ?, ?, ?, ?)`,
+		userID, clientID, string(entity), id, time.Now())
+	return err
+}
@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"todo-app/internal/manager"
+)
+
+// tagKey - ключ сравнения тегов, как в normalizeTags и миграции 009
+func tagKey(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// setTaskTags заменяет теги задачи. Теги, которых у пользователя еще нет,
+// создаются; у существующих сохраняется их написание.
+func setTaskTags(tx *sql.Tx, userID, taskID int, tags []string) error {
+	if _, err := tx.Exec("DELETE FROM task_tags WHERE task_id = ?", taskID); err != nil {
+		return err
+	}
+	for position, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		tagID, err := ensureTag(tx, userID, tag)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT OR IGNORE INTO task_tags (task_id, tag_id, position) VALUES (?, ?, ?)",
+			taskID, tagID, position); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureTag возвращает ID тега пользователя, создавая его при необходимости
+func ensureTag(tx *sql.Tx, userID int, name string) (int, error) {
+	var id int
+	// DO UPDATE без изменений нужен, чтобы RETURNING вернул и существующую строку
+	err := tx.QueryRow(`
+	INSERT INTO tags (user_id, name, name_key) VALUES (?, ?, ?)
+	ON CONFLICT (user_id, name_key) DO UPDATE SET name_key = excluded.name_key
+	RETURNING id`, userID, name, tagKey(name)).Scan(&id)
+	return id, err
+}
+
+// pruneTags удаляет теги пользователя, которые не стоят ни на одной задаче
+func pruneTags(tx *sql.Tx, userID int) error {
+	_, err := tx.Exec(`DELETE FROM tags WHERE user_id = ?
+	AND NOT EXISTS (SELECT 1 FROM task_tags WHERE tag_id = tags.id)`, userID)
+	return err
+}
+
+// moveTags переносит теги задач fromUserID к toUserID, объединяя теги
+// с одинаковым ключом
+func moveTags(tx *sql.Tx, fromUserID, toUserID int) error {
+	for _, query := range []string{
+		`INSERT INTO tags (user_id, name, name_key)
+		SELECT ?, name, name_key FROM tags WHERE user_id = ?
+		ON CONFLICT (user_id, name_key) DO NOTHING`,
+		`UPDATE task_tags SET tag_id = (
+			SELECT n.id FROM tags o JOIN tags n ON n.name_key = o.name_key AND n.user_id = ?
+			WHERE o.id = task_tags.tag_id)
+		WHERE tag_id IN (SELECT id FROM tags WHERE user_id = ?)`,
+	} {
+		if _, err := tx.Exec(query, toUserID, fromUserID); err != nil {
+			return err
+		}
+	}
+	_, err := tx.Exec("DELETE FROM tags WHERE user_id = ?", fromUserID)
+	return err
+}
+
+// findTag ищет тег пользователя по имени без учета регистра
+func findTag(tx *sql.Tx, userID int, name string) (int, error) {
+	var id int
+	err := tx.QueryRow("SELECT id FROM tags WHERE user_id = ? AND name_key = ?", userID, tagKey(name)).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, manager.NotFound("тег %q не найден", strings.TrimSpace(name))
+	}
+	return id, err
+}
+
+// touchTagged обновляет updated_at задач с любым из тегов и возвращает их число
+func touchTagged(tx *sql.Tx, tagIDs ...int) (int, error) {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(tagIDs)), ", ")
+	args := []interface{}{time.Now()}
+	for _, id := range tagIDs {
+		args = append(args, id)
+	}
+	result, err := tx.Exec(`UPDATE tasks SET updated_at = ?, version = version + 1
+	WHERE id IN (SELECT task_id FROM task_tags WHERE tag_id IN (`+placeholders+`))`, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// GetTags возвращает теги пользователя с числом задач, самые частые первыми
+func (s *SQLiteStorage) GetTags(userID int) ([]manager.TagCount, error) {
+	rows, err := s.db.Query(`
+	SELECT g.name, COUNT(*) FROM tags g JOIN task_tags tt ON tt.tag_id = g.id
+	JOIN tasks t ON t.id = tt.task_id AND t.deleted_at IS NULL
+	WHERE g.user_id = ?
+	GROUP BY g.id
+	ORDER BY COUNT(*) DESC, g.name_key`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []manager.TagCount{}
+	for rows.Next() {
+		var tag manager.TagCount
+		if err := rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// RenameTag меняет имя тега во всех задачах пользователя и возвращает
+// число затронутых задач. Переименовать в имя другого существующего тега
+// нельзя - для этого есть MergeTags.
+func (s *SQLiteStorage) RenameTag(userID int, name, newName string) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id, err := findTag(tx, userID, name)
+	if err != nil {
+		return 0, err
+	}
+	if tagKey(newName) != tagKey(name) {
+		if _, err := findTag(tx, userID, newName); err == nil {
+			return 0, manager.Conflict("тег %q уже существует", strings.TrimSpace(newName))
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE tags SET name = ?, name_key = ? WHERE id = ?",
+		strings.TrimSpace(newName), tagKey(newName), id); err != nil {
+		return 0, err
+	}
+	n, err := touchTagged(tx, id)
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.Commit()
+}
+
+// MergeTags заменяет теги sources тегом target во всех задачах пользователя
+// и возвращает число задач, у которых был хотя бы один из sources.
+// Объединенный тег встает на место первого из них в задаче.
+func (s *SQLiteStorage) MergeTags(userID int, sources []string, target string) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var sourceIDs []int
+	for _, source := range sources {
+		if tagKey(source) == tagKey(target) {
+			continue
+		}
+		id, err := findTag(tx, userID, source)
+		if err != nil {
+			return 0, err
+		}
+		sourceIDs = append(sourceIDs, id)
+	}
+
+	targetID, err := ensureTag(tx, userID, strings.TrimSpace(target))
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec("UPDATE tags SET name = ? WHERE id = ?", strings.TrimSpace(target), targetID); err != nil {
+		return 0, err
+	}
+	if len(sourceIDs) == 0 {
+		// Объединять нечего: target мог быть создан впустую
+		if err := pruneTags(tx, userID); err != nil {
+			return 0, err
+		}
+		return 0, tx.Commit()
+	}
+
+	n, err := touchTagged(tx, sourceIDs...)
+	if err != nil {
+		return 0, err
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(sourceIDs)), ", ")
+	args := []interface{}{targetID}
+	for _, id := range sourceIDs {
+		args = append(args, id)
+	}
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO task_tags (task_id, tag_id, position)
+	SELECT task_id, ?, MIN(position) FROM task_tags WHERE tag_id IN (`+placeholders+`)
+	GROUP BY task_id`, args...); err != nil {
+		return 0, err
+	}
+	for _, query := range []string{
+		"DELETE FROM task_tags WHERE tag_id IN (" + placeholders + ")",
+		"DELETE FROM tags WHERE id IN (" + placeholders + ")",
+	} {
+		if _, err := tx.Exec(query, args[1:]...); err != nil {
+			return 0, err
+		}
+	}
+	return n, tx.Commit()
+}
+
+// DeleteTag снимает тег со всех задач пользователя и возвращает их число
+func (s *SQLiteStorage) DeleteTag(userID int, name string) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id, err := findTag(tx, userID, name)
+	if err != nil {
+		return 0, err
+	}
+	n, err := touchTagged(tx, id)
+	if err != nil {
+		return 0, err
+	}
+	for _, query := range []string{
+		"DELETE FROM task_tags WHERE tag_id = ?",
+		"DELETE FROM tags WHERE id = ?",
+	} {
+		if _, err := tx.Exec(query, id); err != nil {
+			return 0, err
+		}
+	}
+	return n, tx.Commit()
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"todo-app/internal/manager"
+)
+
+// taskEventColumns - колонки события с именем автора изменения из users
+const taskEventColumns = "e.id, e.task_id, e.user_id, COALESCE(u.username, ''), e.type, e.source, e.subtask_id, e.changes, e.created_at"
+
+const taskEventFrom = " FROM task_events e LEFT JOIN users u ON u.id = e.user_id"
+
+func scanTaskEvent(row rowScanner) (*manager.TaskEvent, error) {
+	var e manager.TaskEvent
+	var subtaskID sql.NullInt64
+	var changes string
+	if err := row.Scan(&e.ID, &e.TaskID, &e.UserID, &e.Username, &e.Type, &e.Source, &subtaskID, &changes, &e.CreatedAt); err != nil {
+		return nil, err
+	}
+	e.SubTaskID = int(subtaskID.Int64)
+	if err := json.Unmarshal([]byte(changes), &e.Changes); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// AddTaskEvent дописывает событие в историю задачи
+func (s *SQLiteStorage) AddTaskEvent(e *manager.TaskEvent) (int, error) {
+	changes, err := json.Marshal(e.Changes)
+	if err != nil {
+		return 0, err
+	}
+	var subtaskID interface{}
+	if e.SubTaskID != 0 {
+		subtaskID = e.SubTaskID
+	}
+	result, err := s.db.Exec(`
+	INSERT INTO task_events (task_id, user_id, type, source, subtask_id, changes, created_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		e.TaskID, e.UserID, e.Type, e.Source, subtaskID, string(changes), e.CreatedAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// GetTaskEvents возвращает историю задачи, новые события первыми
+func (s *SQLiteStorage) GetTaskEvents(taskID int) ([]manager.TaskEvent, error) {
+	rows, err := s.db.Query("SELECT "+taskEventColumns+taskEventFrom+" WHERE e.task_id = ? ORDER BY e.id DESC", taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []manager.TaskEvent{}
+	for rows.Next() {
+		e, err := scanTaskEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, *e)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,33 @@
+-- Базовая схема: пользователи, задачи и подзадачи.
+-- IF NOT EXISTS позволяет принять базы, созданные старым createTables.
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    device_id TEXT UNIQUE NOT NULL,
+    telegram_id INTEGER UNIQUE,
+    fcm_token TEXT,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS tasks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER REFERENCES users(id),
+    description TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    completed BOOLEAN NOT NULL DEFAULT FALSE,
+    priority TEXT NOT NULL DEFAULT 'medium',
+    due_date DATETIME,
+    tags TEXT
+);
+
+CREATE TABLE IF NOT EXISTS subtasks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER REFERENCES users(id),
+    task_id INTEGER NOT NULL,
+    description TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    completed BOOLEAN NOT NULL DEFAULT FALSE,
+    FOREIGN KEY (task_id) REFERENCES tasks (id) ON DELETE CASCADE
+);
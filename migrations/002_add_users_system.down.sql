@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS reminder_settings;
+DROP INDEX IF EXISTS idx_subtasks_user_id;
+DROP INDEX IF EXISTS idx_tasks_user_id;
@@ -0,0 +1,19 @@
+-- Колонки user_id у tasks и subtasks создаются в 001_initial_schema,
+-- а для баз, созданных до появления миграций, добавляются раннером
+-- (см. adoptLegacySchema в internal/storage/migrations.go).
+
+-- Создаем индекс для быстрого поиска задач пользователя
+CREATE INDEX IF NOT EXISTS idx_tasks_user_id ON tasks(user_id);
+CREATE INDEX IF NOT EXISTS idx_subtasks_user_id ON subtasks(user_id);
+
+-- Создаем таблицу для хранения настроек напоминаний
+CREATE TABLE IF NOT EXISTS reminder_settings (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER UNIQUE REFERENCES users(id),
+    enabled BOOLEAN DEFAULT TRUE,
+    remind_before_days INTEGER DEFAULT 1,
+    telegram_notifications BOOLEAN DEFAULT TRUE,
+    push_notifications BOOLEAN DEFAULT FALSE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
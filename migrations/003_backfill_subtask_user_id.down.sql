@@ -0,0 +1,3 @@
+-- Заполненные user_id не откатываются: до миграции они были NULL,
+-- и отличить их от выставленных приложением уже нельзя
+DROP INDEX IF EXISTS idx_subtasks_task_id;
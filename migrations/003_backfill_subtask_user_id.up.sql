@@ -0,0 +1,7 @@
+-- Подзадачи, созданные до появления пользователей, получают владельца
+-- родительской задачи
+UPDATE subtasks
+SET user_id = (SELECT tasks.user_id FROM tasks WHERE tasks.id = subtasks.task_id)
+WHERE user_id IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_subtasks_task_id ON subtasks(task_id);
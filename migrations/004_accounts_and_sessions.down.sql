@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS sessions;
+
+DROP INDEX IF EXISTS idx_users_email;
+DROP INDEX IF EXISTS idx_users_username;
+
+ALTER TABLE users DROP COLUMN password_hash;
+ALTER TABLE users DROP COLUMN email;
+ALTER TABLE users DROP COLUMN username;
@@ -0,0 +1,23 @@
+-- Учетные записи: вход по имени пользователя или email и паролю.
+-- Колонки допускают NULL: пользователи Telegram и старые устройства
+-- живут без пароля.
+ALTER TABLE users ADD COLUMN username TEXT;
+ALTER TABLE users ADD COLUMN email TEXT;
+ALTER TABLE users ADD COLUMN password_hash TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users(username COLLATE NOCASE);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users(email COLLATE NOCASE);
+
+-- telegram_id уникален, поэтому "нет Telegram" должно храниться как NULL, а не 0
+UPDATE users SET telegram_id = NULL WHERE telegram_id = 0;
+
+-- Серверные сессии. В cookie лежит случайный токен, в базе - только его SHA-256.
+CREATE TABLE IF NOT EXISTS sessions (
+    token_hash TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions(expires_at);
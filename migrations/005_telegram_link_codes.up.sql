@@ -0,0 +1,10 @@
+-- Одноразовые коды привязки Telegram к учетной записи.
+-- Код живет несколько минут, в базе хранится только его SHA-256.
+CREATE TABLE IF NOT EXISTS link_codes (
+    code_hash TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_link_codes_user_id ON link_codes(user_id);
@@ -0,0 +1,31 @@
+-- Напоминания о задачах. В старых базах таблица уже создана прежним кодом
+-- бота с колонками до channel включительно, поэтому сначала IF NOT EXISTS
+-- в той же форме, затем добавляем новые колонки.
+CREATE TABLE IF NOT EXISTS reminders (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    type TEXT NOT NULL DEFAULT 'deadline',
+    trigger_time DATETIME NOT NULL,
+    message TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    channel TEXT NOT NULL DEFAULT 'telegram',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (task_id) REFERENCES tasks (id) ON DELETE CASCADE
+);
+
+-- За сколько дней до срока (NULL - напоминание на конкретное время)
+ALTER TABLE reminders ADD COLUMN days_before INTEGER;
+-- Создано автоматически по reminder_settings
+ALTER TABLE reminders ADD COLUMN auto BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE reminders ADD COLUMN updated_at DATETIME;
+
+-- Старый код писал время в формате Go String() ("... +0000 UTC"),
+-- приводим к виду, который можно сравнивать в SQL
+UPDATE reminders SET trigger_time = substr(trigger_time, 1, 19)
+WHERE trigger_time LIKE '% +0000 UTC';
+UPDATE reminders SET updated_at = COALESCE(created_at, CURRENT_TIMESTAMP);
+
+CREATE INDEX IF NOT EXISTS idx_reminders_trigger_time ON reminders(trigger_time);
+CREATE INDEX IF NOT EXISTS idx_reminders_status ON reminders(status);
+CREATE INDEX IF NOT EXISTS idx_reminders_task_id ON reminders(task_id);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_reminders_next_attempt_at;
+
+ALTER TABLE reminders DROP COLUMN sent_at;
+ALTER TABLE reminders DROP COLUMN claimed_until;
+ALTER TABLE reminders DROP COLUMN claimed_by;
+ALTER TABLE reminders DROP COLUMN next_attempt_at;
+ALTER TABLE reminders DROP COLUMN last_error;
+ALTER TABLE reminders DROP COLUMN attempts;
@@ -0,0 +1,13 @@
+-- Доставка напоминаний планировщиком.
+-- claimed_by/claimed_until - кто из процессов (веб-сервер или бот) сейчас
+-- отправляет напоминание и до какого времени; по истечении аренды
+-- напоминание снова может забрать любой процесс.
+ALTER TABLE reminders ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE reminders ADD COLUMN last_error TEXT;
+-- Когда повторить отправку после ошибки (NULL - попытки исчерпаны)
+ALTER TABLE reminders ADD COLUMN next_attempt_at DATETIME;
+ALTER TABLE reminders ADD COLUMN claimed_by TEXT;
+ALTER TABLE reminders ADD COLUMN claimed_until DATETIME;
+ALTER TABLE reminders ADD COLUMN sent_at DATETIME;
+
+CREATE INDEX IF NOT EXISTS idx_reminders_next_attempt_at ON reminders(next_attempt_at);
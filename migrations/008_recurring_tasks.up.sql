@@ -0,0 +1,6 @@
+-- Повторяющиеся задачи. recurrence - правило в виде RRULE (FREQ=WEEKLY;BYDAY=MO),
+-- series_id - ID первой задачи серии, общий у всех ее экземпляров.
+ALTER TABLE tasks ADD COLUMN recurrence TEXT;
+ALTER TABLE tasks ADD COLUMN series_id INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_series_id ON tasks(series_id);
@@ -0,0 +1,10 @@
+ALTER TABLE tasks ADD COLUMN tags TEXT;
+
+UPDATE tasks SET tags = (
+    SELECT group_concat(t.name, ',' ORDER BY tt.position)
+    FROM task_tags tt JOIN tags t ON t.id = tt.tag_id
+    WHERE tt.task_id = tasks.id
+);
+
+DROP TABLE IF EXISTS task_tags;
+DROP TABLE IF EXISTS tags;
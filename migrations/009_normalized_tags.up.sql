@@ -0,0 +1,53 @@
+-- Теги в отдельных таблицах вместо строки через запятую в tasks.tags.
+-- name - написание тега, которое видит пользователь, name_key - ключ
+-- сравнения (casefold(trim(name))): у пользователя один тег на ключ.
+CREATE TABLE tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    name_key TEXT NOT NULL,
+    UNIQUE (user_id, name_key)
+);
+
+-- position сохраняет порядок тегов в задаче
+CREATE TABLE task_tags (
+    task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (task_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_tags_tag_id ON task_tags(tag_id);
+
+-- Разбиваем старые строки тегов по запятым
+CREATE TEMP TABLE split_tags AS
+WITH RECURSIVE split(task_id, user_id, position, tag, rest) AS (
+    SELECT id, COALESCE(user_id, 0), 0, '', tags || ','
+    FROM tasks WHERE tags IS NOT NULL AND tags != ''
+    UNION ALL
+    SELECT task_id, user_id, position + 1,
+           trim(substr(rest, 1, instr(rest, ',') - 1)),
+           substr(rest, instr(rest, ',') + 1)
+    FROM split WHERE rest != ''
+)
+SELECT task_id, user_id, position, tag, casefold(tag) AS tag_key
+FROM split WHERE tag != '';
+
+-- Из разных написаний одного тега остается первое встреченное
+INSERT INTO tags (user_id, name, name_key)
+SELECT s.user_id, s.tag, s.tag_key FROM split_tags s
+WHERE NOT EXISTS (
+    SELECT 1 FROM split_tags e
+    WHERE e.user_id = s.user_id AND e.tag_key = s.tag_key
+      AND (e.task_id < s.task_id OR (e.task_id = s.task_id AND e.position < s.position))
+);
+
+-- Повторы тега в одной задаче схлопываются в первое вхождение
+INSERT INTO task_tags (task_id, tag_id, position)
+SELECT s.task_id, t.id, MIN(s.position)
+FROM split_tags s JOIN tags t ON t.user_id = s.user_id AND t.name_key = s.tag_key
+GROUP BY s.task_id, t.id;
+
+DROP TABLE split_tags;
+
+ALTER TABLE tasks DROP COLUMN tags;
@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS task_search_task_insert;
+DROP TRIGGER IF EXISTS task_search_task_update;
+DROP TRIGGER IF EXISTS task_search_task_delete;
+DROP TRIGGER IF EXISTS task_search_subtask_insert;
+DROP TRIGGER IF EXISTS task_search_subtask_update;
+DROP TRIGGER IF EXISTS task_search_subtask_delete;
+DROP TABLE IF EXISTS task_search;
@@ -0,0 +1,42 @@
+-- Полнотекстовый поиск по задачам. rowid строки - ID задачи, subtasks -
+-- описания ее подзадач через перевод строки. Таблица заполняется
+-- триггерами, приложение только читает ее.
+CREATE VIRTUAL TABLE task_search USING fts5(description, subtasks, tokenize = 'unicode61');
+
+INSERT INTO task_search (rowid, description, subtasks)
+SELECT id, description,
+       COALESCE((SELECT group_concat(s.description, char(10) ORDER BY s.id) FROM subtasks s WHERE s.task_id = tasks.id), '')
+FROM tasks;
+
+CREATE TRIGGER task_search_task_insert AFTER INSERT ON tasks BEGIN
+    INSERT INTO task_search (rowid, description, subtasks) VALUES (new.id, new.description, '');
+END;
+
+CREATE TRIGGER task_search_task_update AFTER UPDATE OF description ON tasks BEGIN
+    UPDATE task_search SET description = new.description WHERE rowid = new.id;
+END;
+
+CREATE TRIGGER task_search_task_delete AFTER DELETE ON tasks BEGIN
+    DELETE FROM task_search WHERE rowid = old.id;
+END;
+
+CREATE TRIGGER task_search_subtask_insert AFTER INSERT ON subtasks BEGIN
+    UPDATE task_search SET subtasks = COALESCE(
+        (SELECT group_concat(description, char(10) ORDER BY id) FROM subtasks WHERE task_id = new.task_id), '')
+    WHERE rowid = new.task_id;
+END;
+
+CREATE TRIGGER task_search_subtask_update AFTER UPDATE OF description, task_id ON subtasks BEGIN
+    UPDATE task_search SET subtasks = COALESCE(
+        (SELECT group_concat(description, char(10) ORDER BY id) FROM subtasks WHERE task_id = old.task_id), '')
+    WHERE rowid = old.task_id;
+    UPDATE task_search SET subtasks = COALESCE(
+        (SELECT group_concat(description, char(10) ORDER BY id) FROM subtasks WHERE task_id = new.task_id), '')
+    WHERE rowid = new.task_id;
+END;
+
+CREATE TRIGGER task_search_subtask_delete AFTER DELETE ON subtasks BEGIN
+    UPDATE task_search SET subtasks = COALESCE(
+        (SELECT group_concat(description, char(10) ORDER BY id) FROM subtasks WHERE task_id = old.task_id), '')
+    WHERE rowid = old.task_id;
+END;
@@ -0,0 +1,13 @@
+-- Сохраненные фильтры (умные списки). options - FilterOptions в JSON,
+-- имя уникально у пользователя без учета регистра (name_key).
+CREATE TABLE saved_filters (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    name TEXT NOT NULL,
+    name_key TEXT NOT NULL,
+    options TEXT NOT NULL DEFAULT '{}',
+    sort TEXT NOT NULL DEFAULT 'due_date',
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    UNIQUE (user_id, name_key)
+);
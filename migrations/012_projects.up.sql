@@ -0,0 +1,31 @@
+-- Проекты (списки) задач. У каждой задачи есть проект, по умолчанию -
+-- Входящие (inbox) владельца. name_key - ключ уникальности названия
+-- без учета регистра, position - порядок в списке проектов.
+CREATE TABLE projects (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    name_key TEXT NOT NULL,
+    color TEXT NOT NULL DEFAULT '#9e9e9e',
+    archived BOOLEAN NOT NULL DEFAULT FALSE,
+    position INTEGER NOT NULL DEFAULT 0,
+    inbox BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    UNIQUE (user_id, name_key)
+);
+
+-- У пользователя одни Входящие
+CREATE UNIQUE INDEX idx_projects_inbox ON projects(user_id) WHERE inbox;
+
+ALTER TABLE tasks ADD COLUMN project_id INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_project_id ON tasks(project_id);
+
+-- Существующие задачи попадают во Входящие своего владельца
+INSERT INTO projects (user_id, name, name_key, inbox, created_at, updated_at)
+SELECT DISTINCT user_id, 'Входящие', 'входящие', TRUE, datetime('now'), datetime('now')
+FROM tasks WHERE user_id IS NOT NULL;
+
+UPDATE tasks SET project_id = (SELECT p.id FROM projects p WHERE p.user_id = tasks.user_id AND p.inbox)
+WHERE user_id IS NOT NULL;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS project_invites;
+
+DROP TABLE IF EXISTS project_members;
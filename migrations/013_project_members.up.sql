@@ -0,0 +1,24 @@
+-- Участники общих проектов. Владелец проекта - projects.user_id,
+-- здесь только приглашенные: editor меняет задачи, viewer их только видит.
+CREATE TABLE project_members (
+    project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('editor', 'viewer')),
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (project_id, user_id)
+);
+
+CREATE INDEX idx_project_members_user_id ON project_members(user_id);
+
+-- Приглашения в проект по коду. Код одноразовый, в базе хранится
+-- только его SHA-256, как у кодов привязки Telegram.
+CREATE TABLE project_invites (
+    code_hash TEXT PRIMARY KEY,
+    project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('editor', 'viewer')),
+    created_by INTEGER NOT NULL,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_project_invites_project_id ON project_invites(project_id);
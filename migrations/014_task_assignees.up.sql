@@ -0,0 +1,5 @@
+-- Исполнитель задачи - участник ее проекта, отдельно от автора (user_id).
+-- NULL - задача никому не назначена.
+ALTER TABLE tasks ADD COLUMN assignee_id INTEGER REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_assignee_id ON tasks(assignee_id);
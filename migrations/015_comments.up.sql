@@ -0,0 +1,12 @@
+-- Комментарии к задачам. Автор (user_id) может быть не автором задачи:
+-- комментировать может любой, кто видит задачу.
+CREATE TABLE comments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_comments_task_id ON comments(task_id, created_at);
@@ -0,0 +1,14 @@
+-- История изменений задач. Таблица только дописывается: без ссылки на
+-- tasks, чтобы история удаленной задачи сохранялась.
+CREATE TABLE task_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    type TEXT NOT NULL,
+    source TEXT NOT NULL,
+    subtask_id INTEGER,
+    changes TEXT NOT NULL DEFAULT '[]', -- JSON-массив {field, old, new}
+    created_at DATETIME NOT NULL
+);
+
+CREATE INDEX idx_task_events_task_id ON task_events(task_id, id);
@@ -0,0 +1,11 @@
+-- Задачи из корзины при откате удаляются окончательно
+DELETE FROM subtasks WHERE task_id IN (SELECT id FROM tasks WHERE deleted_at IS NOT NULL);
+DELETE FROM reminders WHERE task_id IN (SELECT id FROM tasks WHERE deleted_at IS NOT NULL);
+DELETE FROM comments WHERE task_id IN (SELECT id FROM tasks WHERE deleted_at IS NOT NULL);
+DELETE FROM attachments WHERE task_id IN (SELECT id FROM tasks WHERE deleted_at IS NOT NULL);
+DELETE FROM task_tags WHERE task_id IN (SELECT id FROM tasks WHERE deleted_at IS NOT NULL);
+DELETE FROM tasks WHERE deleted_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_tasks_deleted_at;
+
+ALTER TABLE tasks DROP COLUMN deleted_at;
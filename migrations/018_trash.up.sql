@@ -0,0 +1,5 @@
+-- Мягкое удаление: удаленная задача остается в корзине до восстановления
+-- или очистки. NULL - задача не удалена.
+ALTER TABLE tasks ADD COLUMN deleted_at DATETIME;
+
+CREATE INDEX IF NOT EXISTS idx_tasks_deleted_at ON tasks(deleted_at);
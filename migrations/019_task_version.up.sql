@@ -0,0 +1,3 @@
+-- Версия задачи для оптимистичной блокировки: каждое изменение строки
+-- увеличивает ее на 1, условное обновление проверяет, что версия не изменилась
+ALTER TABLE tasks ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_sync_entries_seq;
+DROP TABLE IF EXISTS sync_entries;
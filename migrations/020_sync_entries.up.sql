@@ -0,0 +1,15 @@
+-- Лента изменений для синхронизации клиентов: последнее известное состояние
+-- каждой задачи и подзадачи, которую видел пользователь. seq - номер
+-- изменения в ленте пользователя, растет с каждым обнаруженным изменением;
+-- deleted - задача или подзадача пропала (корзина, удаление, потеря доступа).
+CREATE TABLE sync_entries (
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    entity TEXT NOT NULL, -- task или subtask
+    entity_id INTEGER NOT NULL,
+    hash TEXT NOT NULL,
+    seq INTEGER NOT NULL,
+    deleted BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (user_id, entity, entity_id)
+);
+
+CREATE INDEX idx_sync_entries_seq ON sync_entries(user_id, seq);
@@ -0,0 +1,17 @@
+DROP TRIGGER IF EXISTS sync_project_owner;
+DROP TRIGGER IF EXISTS sync_member_update;
+DROP TRIGGER IF EXISTS sync_member_delete;
+DROP TRIGGER IF EXISTS sync_member_insert;
+DROP TRIGGER IF EXISTS sync_subtask_delete;
+DROP TRIGGER IF EXISTS sync_subtask_update;
+DROP TRIGGER IF EXISTS sync_subtask_insert;
+DROP TRIGGER IF EXISTS sync_task_delete;
+DROP TRIGGER IF EXISTS sync_task_subtasks;
+DROP TRIGGER IF EXISTS sync_task_update;
+DROP TRIGGER IF EXISTS sync_task_insert;
+DROP VIEW IF EXISTS task_viewers;
+DROP VIEW IF EXISTS project_viewers;
+DROP INDEX IF EXISTS idx_sync_entries_entity;
+-- Пустой отпечаток не совпадет ни с одной задачей: лента один раз
+-- отдаст все видимые задачи заново
+ALTER TABLE sync_entries ADD COLUMN hash TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,213 @@
+-- Номера ленты синхронизации выдаются при записи, а не при запросе ленты:
+-- триггеры на задачах, подзадачах, проектах и участниках добавляют запись
+-- со следующим seq в ленту каждого, кто видит изменившуюся задачу или
+-- перестал ее видеть. Приложение только читает sync_entries.
+--
+-- В одном INSERT ... SELECT номер считается от MAX(seq) до вставки, поэтому
+-- несколько записей одного пользователя нумеруются через ROW_NUMBER.
+-- WHERE true отделяет SELECT от ON CONFLICT.
+ALTER TABLE sync_entries DROP COLUMN hash;
+
+CREATE INDEX idx_sync_entries_entity ON sync_entries(entity, entity_id);
+
+-- Кто видит задачи проекта: владелец и участники
+CREATE VIEW project_viewers AS
+SELECT id AS project_id, user_id FROM projects
+UNION ALL
+SELECT project_id, user_id FROM project_members;
+
+-- Кто видит задачу (как accessibleTask в storage): роль в ее проекте,
+-- а задачу без проекта - автор (у задач до учетных записей его может не
+-- быть). deleted - задача в корзине.
+CREATE VIEW task_viewers AS
+SELECT t.id AS task_id, v.user_id, t.deleted_at IS NOT NULL AS deleted
+FROM tasks t JOIN project_viewers v ON v.project_id = t.project_id
+UNION ALL
+SELECT id, user_id, deleted_at IS NOT NULL FROM tasks WHERE project_id IS NULL AND user_id IS NOT NULL;
+
+-- Ленты, заведенные до триггеров, один раз получают все видимые задачи и
+-- подзадачи заново, а пропавшие из видимых - удаление
+WITH visible AS (
+    SELECT v.user_id, 'task' AS entity, v.task_id AS entity_id FROM task_viewers v WHERE NOT v.deleted
+    UNION
+    SELECT v.user_id, 'subtask', s.id FROM subtasks s JOIN task_viewers v ON v.task_id = s.task_id WHERE NOT v.deleted
+), changed AS (
+    SELECT user_id, entity, entity_id, FALSE AS deleted FROM visible
+    UNION ALL
+    SELECT e.user_id, e.entity, e.entity_id, TRUE FROM sync_entries e
+    WHERE NOT e.deleted AND NOT EXISTS (SELECT 1 FROM visible v
+        WHERE v.user_id = e.user_id AND v.entity = e.entity AND v.entity_id = e.entity_id)
+)
+INSERT INTO sync_entries (user_id, entity, entity_id, seq, deleted)
+SELECT c.user_id, c.entity, c.entity_id,
+       COALESCE((SELECT MAX(seq) FROM sync_entries s WHERE s.user_id = c.user_id), 0)
+           + ROW_NUMBER() OVER (PARTITION BY c.user_id ORDER BY c.entity DESC, c.entity_id),
+       c.deleted
+FROM changed c WHERE true
+ON CONFLICT (user_id, entity, entity_id) DO UPDATE SET seq = excluded.seq, deleted = excluded.deleted;
+
+-- Задача: запись для каждого, кто ее видит, и удаление для тех, кто
+-- видел ее раньше, но больше не видит (перенос в другой проект)
+CREATE TRIGGER sync_task_insert AFTER INSERT ON tasks BEGIN
+    INSERT INTO sync_entries (user_id, entity, entity_id, seq, deleted)
+    SELECT v.user_id, 'task', new.id,
+           COALESCE((SELECT MAX(seq) FROM sync_entries s WHERE s.user_id = v.user_id), 0) + 1, v.deleted
+    FROM task_viewers v WHERE v.task_id = new.id
+    ON CONFLICT (user_id, entity, entity_id) DO UPDATE SET seq = excluded.seq, deleted = excluded.deleted;
+END;
+
+CREATE TRIGGER sync_task_update AFTER UPDATE ON tasks BEGIN
+    INSERT INTO sync_entries (user_id, entity, entity_id, seq, deleted)
+    SELECT v.user_id, 'task', new.id,
+           COALESCE((SELECT MAX(seq) FROM sync_entries s WHERE s.user_id = v.user_id), 0) + 1, v.deleted
+    FROM task_viewers v WHERE v.task_id = new.id
+    ON CONFLICT (user_id, entity, entity_id) DO UPDATE SET seq = excluded.seq, deleted = excluded.deleted;
+
+    INSERT INTO sync_entries (user_id, entity, entity_id, seq, deleted)
+    SELECT e.user_id, 'task', new.id, (SELECT MAX(seq) FROM sync_entries s WHERE s.user_id = e.user_id) + 1, TRUE
+    FROM sync_entries e
+    WHERE e.entity = 'task' AND e.entity_id = new.id AND NOT e.deleted
+      AND e.user_id NOT IN (SELECT user_id FROM task_viewers WHERE task_id = new.id)
+    ON CONFLICT (user_id, entity, entity_id) DO UPDATE SET seq = excluded.seq, deleted = excluded.deleted;
+END;
+
+-- Корзина и перенос в другой проект меняют и видимость подзадач
+CREATE TRIGGER sync_task_subtasks AFTER UPDATE OF deleted_at, project_id ON tasks
+WHEN old.deleted_at IS NOT new.deleted_at OR old.project_id IS NOT new.project_id BEGIN
+    INSERT INTO sync_entries (user_id, entity, entity_id, seq, deleted)
+    SELECT v.user_id, 'subtask', s.id,
+           COALESCE((SELECT MAX(seq) FROM sync_entries e WHERE e.user_id = v.user_id), 0)
+               + ROW_NUMBER() OVER (PARTITION BY v.user_id ORDER BY s.id),
+           v.deleted
+    FROM subtasks s JOIN task_viewers v ON v.task_id = s.task_id
+    WHERE s.task_id = new.id
+    ON CONFLICT (user_id, entity, entity_id) DO UPDATE SET seq = excluded.seq, deleted = excluded.deleted;
+
+    INSERT INTO sync_entries (user_id, entity, entity_id, seq, deleted)
+    SELECT e.user_id, 'subtask', e.entity_id,
+           (SELECT MAX(seq) FROM sync_entries m WHERE m.user_id = e.user_id)
+               + ROW_NUMBER() OVER (PARTITION BY e.user_id ORDER BY e.entity_id),
+           TRUE
+    FROM subtasks s JOIN sync_entries e ON e.entity = 'subtask' AND e.entity_id = s.id
+    WHERE s.task_id = new.id AND NOT e.deleted
+      AND e.user_id NOT IN (SELECT user_id FROM task_viewers WHERE task_id = new.id)
+    ON CONFLICT (user_id, entity, entity_id) DO UPDATE SET seq = excluded.seq, deleted = excluded.deleted;
+END;
+
+CREATE TRIGGER sync_task_delete AFTER DELETE ON tasks BEGIN
+    INSERT INTO sync_entries (user_id, entity, entity_id, seq, deleted)
+    SELECT e.user_id, 'task', old.id, (SELECT MAX(seq) FROM sync_entries s WHERE s.user_id = e.user_id) + 1, TRUE
+    FROM sync_entries e
+    WHERE e.entity = 'task' AND e.entity_id = old.id AND NOT e.deleted
+    ON CONFLICT (user_id, entity, entity_id) DO UPDATE SET seq = excluded.seq, deleted = excluded.deleted;
+END;
+
+-- Подзадача видна тем же, кому ее задача
+CREATE TRIGGER sync_subtask_insert AFTER INSERT ON subtasks BEGIN
+    INSERT INTO sync_entries (user_id, entity, entity_id, seq, deleted)
+    SELECT v.user_id, 'subtask', new.id,
+           COALESCE((SELECT MAX(seq) FROM sync_entries s WHERE s.user_id = v.user_id), 0) + 1, v.deleted
+    FROM task_viewers v WHERE v.task_id = new.task_id
+    ON CONFLICT (user_id, entity, entity_id) DO UPDATE SET seq = excluded.seq, deleted = excluded.deleted;
+END;
+
+CREATE TRIGGER sync_subtask_update AFTER UPDATE ON subtasks BEGIN
+    INSERT INTO sync_entries (user_id, entity, entity_id, seq, deleted)
+    SELECT v.user_id, 'subtask', new.id,
+           COALESCE((SELECT MAX(seq) FROM sync_entries s WHERE s.user_id = v.user_id), 0) + 1, v.deleted
+    FROM task_viewers v WHERE v.task_id = new.task_id
+    ON CONFLICT (user_id, entity, entity_id) DO UPDATE SET seq = excluded.seq, deleted = excluded.deleted;
+END;
+
+CREATE TRIGGER sync_subtask_delete AFTER DELETE ON subtasks BEGIN
+    INSERT INTO sync_entries (user_id, entity, entity_id, seq, deleted)
+    SELECT e.user_id, 'subtask', old.id, (SELECT MAX(seq) FROM sync_entries s WHERE s.user_id = e.user_id) + 1, TRUE
+    FROM sync_entries e
+    WHERE e.entity = 'subtask' AND e.entity_id = old.id AND NOT e.deleted
+    ON CONFLICT (user_id, entity, entity_id) DO UPDATE SET seq = excluded.seq, deleted = excluded.deleted;
+END;
+
+-- Новый участник получает задачи проекта не из корзины с подзадачами,
+-- исключенный - их удаление. То же при передаче проекта другому владельцу.
+CREATE TRIGGER sync_member_insert AFTER INSERT ON project_members BEGIN
+    INSERT INTO sync_entries (user_id, entity, entity_id, seq, deleted)
+    SELECT new.user_id, c.entity, c.id,
+           COALESCE((SELECT MAX(seq) FROM sync_entries s WHERE s.user_id = new.user_id), 0)
+               + ROW_NUMBER() OVER (ORDER BY c.entity DESC, c.id),
+           FALSE
+    FROM (SELECT 'task' AS entity, id FROM tasks WHERE project_id = new.project_id AND deleted_at IS NULL
+          UNION ALL
+          SELECT 'subtask', s.id FROM subtasks s JOIN tasks t ON t.id = s.task_id
+          WHERE t.project_id = new.project_id AND t.deleted_at IS NULL) c
+    WHERE true
+    ON CONFLICT (user_id, entity, entity_id) DO UPDATE SET seq = excluded.seq, deleted = excluded.deleted;
+END;
+
+CREATE TRIGGER sync_member_delete AFTER DELETE ON project_members BEGIN
+    INSERT INTO sync_entries (user_id, entity, entity_id, seq, deleted)
+    SELECT e.user_id, e.entity, e.entity_id,
+           (SELECT MAX(seq) FROM sync_entries s WHERE s.user_id = old.user_id)
+               + ROW_NUMBER() OVER (ORDER BY e.entity, e.entity_id),
+           TRUE
+    FROM sync_entries e
+    WHERE e.user_id = old.user_id AND NOT e.deleted AND (
+        (e.entity = 'task' AND e.entity_id IN (SELECT id FROM tasks WHERE project_id = old.project_id))
+        OR (e.entity = 'subtask' AND e.entity_id IN (SELECT s.id FROM subtasks s JOIN tasks t ON t.id = s.task_id
+            WHERE t.project_id = old.project_id)))
+    ON CONFLICT (user_id, entity, entity_id) DO UPDATE SET seq = excluded.seq, deleted = excluded.deleted;
+END;
+
+-- Участие, переданное другому пользователю или проекту (см. LinkTelegram)
+CREATE TRIGGER sync_member_update AFTER UPDATE OF project_id, user_id ON project_members BEGIN
+    INSERT INTO sync_entries (user_id, entity, entity_id, seq, deleted)
+    SELECT e.user_id, e.entity, e.entity_id,
+           (SELECT MAX(seq) FROM sync_entries s WHERE s.user_id = old.user_id)
+               + ROW_NUMBER() OVER (ORDER BY e.entity, e.entity_id),
+           TRUE
+    FROM sync_entries e
+    WHERE e.user_id = old.user_id AND NOT e.deleted AND (
+        (e.entity = 'task' AND e.entity_id IN (SELECT id FROM tasks WHERE project_id = old.project_id))
+        OR (e.entity = 'subtask' AND e.entity_id IN (SELECT s.id FROM subtasks s JOIN tasks t ON t.id = s.task_id
+            WHERE t.project_id = old.project_id)))
+    ON CONFLICT (user_id, entity, entity_id) DO UPDATE SET seq = excluded.seq, deleted = excluded.deleted;
+
+    INSERT INTO sync_entries (user_id, entity, entity_id, seq, deleted)
+    SELECT new.user_id, c.entity, c.id,
+           COALESCE((SELECT MAX(seq) FROM sync_entries s WHERE s.user_id = new.user_id), 0)
+               + ROW_NUMBER() OVER (ORDER BY c.entity DESC, c.id),
+           FALSE
+    FROM (SELECT 'task' AS entity, id FROM tasks WHERE project_id = new.project_id AND deleted_at IS NULL
+          UNION ALL
+          SELECT 'subtask', s.id FROM subtasks s JOIN tasks t ON t.id = s.task_id
+          WHERE t.project_id = new.project_id AND t.deleted_at IS NULL) c
+    WHERE true
+    ON CONFLICT (user_id, entity, entity_id) DO UPDATE SET seq = excluded.seq, deleted = excluded.deleted;
+END;
+
+CREATE TRIGGER sync_project_owner AFTER UPDATE OF user_id ON projects
+WHEN old.user_id IS NOT new.user_id BEGIN
+    INSERT INTO sync_entries (user_id, entity, entity_id, seq, deleted)
+    SELECT new.user_id, c.entity, c.id,
+           COALESCE((SELECT MAX(seq) FROM sync_entries s WHERE s.user_id = new.user_id), 0)
+               + ROW_NUMBER() OVER (ORDER BY c.entity DESC, c.id),
+           FALSE
+    FROM (SELECT 'task' AS entity, id FROM tasks WHERE project_id = new.id AND deleted_at IS NULL
+          UNION ALL
+          SELECT 'subtask', s.id FROM subtasks s JOIN tasks t ON t.id = s.task_id
+          WHERE t.project_id = new.id AND t.deleted_at IS NULL) c
+    WHERE true
+    ON CONFLICT (user_id, entity, entity_id) DO UPDATE SET seq = excluded.seq, deleted = excluded.deleted;
+
+    INSERT INTO sync_entries (user_id, entity, entity_id, seq, deleted)
+    SELECT e.user_id, e.entity, e.entity_id,
+           (SELECT MAX(seq) FROM sync_entries s WHERE s.user_id = old.user_id)
+               + ROW_NUMBER() OVER (ORDER BY e.entity, e.entity_id),
+           TRUE
+    FROM sync_entries e
+    WHERE e.user_id = old.user_id AND NOT e.deleted
+      AND old.user_id NOT IN (SELECT user_id FROM project_members WHERE project_id = new.id) AND (
+        (e.entity = 'task' AND e.entity_id IN (SELECT id FROM tasks WHERE project_id = new.id))
+        OR (e.entity = 'subtask' AND e.entity_id IN (SELECT s.id FROM subtasks s JOIN tasks t ON t.id = s.task_id
+            WHERE t.project_id = new.id)))
+    ON CONFLICT (user_id, entity, entity_id) DO UPDATE SET seq = excluded.seq, deleted = excluded.deleted;
+END;
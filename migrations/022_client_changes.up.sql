@@ -0,0 +1,11 @@
+-- Задачи и подзадачи, созданные пакетами изменений клиентов (POST /sync),
+-- по временному client_id: повтор пакета после обрыва связи возвращает
+-- созданное раньше, а не создает его заново
+CREATE TABLE client_changes (
+    user_id INTEGER NOT NULL REFERENCES users(id),
+    client_id TEXT NOT NULL,
+    entity TEXT NOT NULL, -- task или subtask
+    entity_id INTEGER NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (user_id, client_id)
+);
@@ -0,0 +1,13 @@
+// Package migrations содержит SQL-миграции схемы базы данных.
+//
+// Каждая миграция состоит из пары файлов NNN_name.up.sql и
+// NNN_name.down.sql. Файлы встраиваются в бинарник и применяются
+// раннером из internal/storage.
+package migrations
+
+import "embed"
+
+// FS - встроенные файлы миграций
+//
+//go:embed *.sql
+var FS embed.FS